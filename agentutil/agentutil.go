@@ -0,0 +1,81 @@
+// Package agentutil has helpers shared by agents reporting targets to the orchestrator and applying
+// versions assigned to them
+package agentutil
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/rs/zerolog"
+)
+
+// DefaultInterval between reports of agents
+const DefaultInterval = 30 * time.Second
+
+// Options of agents reporting to orchestrator, embedded in options of every agent
+type Options struct {
+	// Interval between reports, defaults to 30s
+	Interval time.Duration
+}
+
+// NewClient creates client agents report to orchestrator at baseURL with, authenticated with apiKey unless empty.
+// Failed requests are retried and fail fast for 30 seconds after 5 consecutive failures, so orchestrator
+// restarts do not fail agent loops
+func NewClient(baseURL, apiKey string) (*client.Client, error) {
+	opts := []client.Option{
+		client.WithRetry(httpclient.DefaultRetryPolicy()),
+		client.WithCircuitBreaker(httpclient.NewCircuitBreaker(5, 30*time.Second)),
+	}
+	if apiKey != "" {
+		opts = append(opts, client.WithAPIKey(apiKey))
+	}
+	return client.New(baseURL, opts...)
+}
+
+// Run reports and applies versions by calling sync every interval, DefaultInterval if not set, until ctx is done,
+// failures are logged with message and retried next interval
+func Run(ctx context.Context, interval time.Duration, logger zerolog.Logger, message string, sync func(ctx context.Context) error) error {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sync(ctx); err != nil {
+			logger.Error().Err(err).Msg(message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SplitImage returns repository and version of image, version is tag or digest if image has no tag
+func SplitImage(image string) (repository, version string) {
+	repository = image
+	if at := strings.Index(repository, "@"); at >= 0 {
+		version = repository[at+1:]
+		repository = repository[:at]
+	}
+	// tag follows last colon after last slash, colons before are registry ports
+	if colon := strings.LastIndex(repository, ":"); colon > strings.LastIndex(repository, "/") {
+		version = repository[colon+1:]
+		repository = repository[:colon]
+	}
+	return repository, version
+}
+
+// JoinImage returns image of repository at version, versions with algorithm prefix are digests
+func JoinImage(repository, version string) string {
+	if strings.Contains(version, ":") {
+		return repository + "@" + version
+	}
+	return repository + ":" + version
+}
@@ -0,0 +1,44 @@
+package agentutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitImage(t *testing.T) {
+	for image, expected := range map[string][2]string{
+		"nginx":                               {"nginx", ""},
+		"nginx:1.25":                          {"nginx", "1.25"},
+		"registry:5000/shop/web:v2":           {"registry:5000/shop/web", "v2"},
+		"registry:5000/shop/web":              {"registry:5000/shop/web", ""},
+		"shop/web@sha256:abcd":                {"shop/web", "sha256:abcd"},
+		"shop/web:v2@sha256:abcd":             {"shop/web", "v2"},
+		"ghcr.io/nixmade/orchestrator:latest": {"ghcr.io/nixmade/orchestrator", "latest"},
+	} {
+		repository, version := SplitImage(image)
+		assert.Equal(t, expected[0], repository, image)
+		assert.Equal(t, expected[1], version, image)
+	}
+	assert.Equal(t, "shop/web:v3", JoinImage("shop/web", "v3"))
+	assert.Equal(t, "shop/web@sha256:abcd", JoinImage("shop/web", "sha256:abcd"))
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	syncs := 0
+	err := Run(ctx, time.Millisecond, zerolog.Nop(), "Failed to sync", func(ctx context.Context) error {
+		syncs++
+		if syncs >= 3 {
+			cancel()
+		}
+		// failures do not stop syncing
+		return errors.New("sync failed")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3, syncs)
+}
@@ -0,0 +1,330 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/core"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+)
+
+// Orchestrate reports state of targets of entity and returns versions assigned to them
+func (c *Client) Orchestrate(ctx context.Context, namespace, entity string, targets []*engine.ClientState) ([]*engine.ClientState, error) {
+	var assigned []*engine.ClientState
+	if _, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil), targets, &assigned); err != nil {
+		return nil, err
+	}
+	return assigned, nil
+}
+
+// OrchestrateBatch orchestrates many entities in one request, results are in order of requests
+func (c *Client) OrchestrateBatch(ctx context.Context, requests []*engine.BulkOrchestrateRequest) ([]*engine.BulkOrchestrateResult, error) {
+	var results []*engine.BulkOrchestrateResult
+	if _, err := c.do(ctx, http.MethodPost, c.endpoint(nil, "orchestrate", "batch"), requests, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ReportStatus reports state of targets of entity without waiting for versions to be assigned
+func (c *Client) ReportStatus(ctx context.Context, namespace, entity string, targets []*engine.ClientState) error {
+	_, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "status"), targets, nil)
+	return err
+}
+
+// SetTargetVersion sets version to roll out to targets of entity
+func (c *Client) SetTargetVersion(ctx context.Context, namespace, entity string, version *engine.EntityTargetVersion) error {
+	_, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "version"), version, nil)
+	return err
+}
+
+// ForceTargetVersion sets version to roll out to targets of entity, skipping concurrency policy and downgrade
+// protection, rolling version is marked bad
+func (c *Client) ForceTargetVersion(ctx context.Context, namespace, entity string, version *engine.EntityTargetVersion) error {
+	_, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, url.Values{"force": {"true"}}, "version"), version, nil)
+	return err
+}
+
+// SetRolloutOptions sets options of rollouts of entity
+func (c *Client) SetRolloutOptions(ctx context.Context, namespace, entity string, options *engine.RolloutOptions) error {
+	_, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "options"), options, nil)
+	return err
+}
+
+// ValidateRolloutOptions checks options of rollouts of entity on server without applying them
+func (c *Client) ValidateRolloutOptions(ctx context.Context, namespace, entity string, options *engine.RolloutOptions) error {
+	_, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, url.Values{"validate": {"true"}}, "options"), options, nil)
+	return err
+}
+
+// Status returns state of every target of entity
+func (c *Client) Status(ctx context.Context, namespace, entity string) ([]*engine.ClientState, error) {
+	return c.getClientStates(ctx, c.entityEndpoint(namespace, entity, nil, "status"))
+}
+
+// GroupStatus returns state of targets of group of entity
+func (c *Client) GroupStatus(ctx context.Context, namespace, entity, group string) ([]*engine.ClientState, error) {
+	return c.getClientStates(ctx, c.entityEndpoint(namespace, entity, nil, group, "status"))
+}
+
+// StatusIfChanged returns state of every target of entity and their etag, unless targets still have etag
+// in which case httpclient.ErrNotModified is returned without transferring them. Empty etag always gets targets
+func (c *Client) StatusIfChanged(ctx context.Context, namespace, entity, etag string) ([]*engine.ClientState, string, error) {
+	return c.getClientStatesIfChanged(ctx, c.entityEndpoint(namespace, entity, nil, "status"), etag)
+}
+
+// GroupStatusIfChanged is StatusIfChanged of targets of group of entity
+func (c *Client) GroupStatusIfChanged(ctx context.Context, namespace, entity, group, etag string) ([]*engine.ClientState, string, error) {
+	return c.getClientStatesIfChanged(ctx, c.entityEndpoint(namespace, entity, nil, group, "status"), etag)
+}
+
+// StatusAtVersion returns state of targets of entity assigned version
+func (c *Client) StatusAtVersion(ctx context.Context, namespace, entity, version string) ([]*engine.ClientState, error) {
+	return c.getClientStates(ctx, c.entityEndpoint(namespace, entity, url.Values{"version": {version}}, "status"))
+}
+
+// StatusInState returns state of targets of entity in target state, e.g. engine.TargetStateQuarantined
+func (c *Client) StatusInState(ctx context.Context, namespace, entity string, state engine.TargetState) ([]*engine.ClientState, error) {
+	return c.getClientStates(ctx, c.entityEndpoint(namespace, entity, url.Values{"state": {string(state)}}, "status"))
+}
+
+// ReleaseQuarantinedTarget releases target of entity from quarantine, it is part of rollouts again
+func (c *Client) ReleaseQuarantinedTarget(ctx context.Context, namespace, entity string, target *engine.ClientState) error {
+	_, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "quarantine", "release"), target, nil)
+	return err
+}
+
+// ErrorStatus returns state of targets of entity in error
+func (c *Client) ErrorStatus(ctx context.Context, namespace, entity string) ([]*engine.ClientState, error) {
+	return c.getClientStates(ctx, c.entityEndpoint(namespace, entity, url.Values{"error": {"true"}}, "status"))
+}
+
+// StatusPage returns page of up to limit targets after cursor and cursor of next page, empty on last page
+func (c *Client) StatusPage(ctx context.Context, namespace, entity, cursor string, limit int) ([]*engine.ClientState, string, error) {
+	var clientStates []*engine.ClientState
+	header, err := c.do(ctx, http.MethodGet, c.entityEndpoint(namespace, entity, url.Values{"cursor": {cursor}, "limit": {strconv.Itoa(limit)}}, "status"), nil, &clientStates)
+	if err != nil {
+		return nil, "", err
+	}
+	return clientStates, header.Get(httpclient.NextCursorHeader), nil
+}
+
+// StreamStatus calls fn with state of each target of entity as it is received, targets are streamed
+// so entities of any size are not held in memory. Streams are not retried once targets are received
+func (c *Client) StreamStatus(ctx context.Context, namespace, entity string, fn func(*engine.ClientState) error) error {
+	resp, err := c.stream(ctx, c.entityEndpoint(namespace, entity, nil, "status"), httpclient.NDJSONContentType)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		clientState := &engine.ClientState{}
+		if err := decoder.Decode(clientState); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(clientState); err != nil {
+			return err
+		}
+	}
+}
+
+// Namespaces returns names of namespaces
+func (c *Client) Namespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	if _, err := c.do(ctx, http.MethodGet, c.endpoint(nil, "orchestrate", "namespaces"), nil, &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// Entities returns names of entities of namespace
+func (c *Client) Entities(ctx context.Context, namespace string) ([]string, error) {
+	var entities []string
+	if _, err := c.do(ctx, http.MethodGet, c.endpoint(nil, "orchestrate", namespace, "entities"), nil, &entities); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// RolloutInfo returns state of current rollout of entity
+func (c *Client) RolloutInfo(ctx context.Context, namespace, entity string) (*engine.RolloutState, error) {
+	rollout := &engine.RolloutState{}
+	if _, err := c.do(ctx, http.MethodGet, c.entityEndpoint(namespace, entity, nil, "rollout"), nil, rollout); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// RolloutProgress returns progress of rolling version, Stall is set if rollout made no progress for stall timeout
+func (c *Client) RolloutProgress(ctx context.Context, namespace, entity string) (*engine.RolloutProgress, error) {
+	progress := &engine.RolloutProgress{}
+	if _, err := c.do(ctx, http.MethodGet, c.entityEndpoint(namespace, entity, nil, "progress"), nil, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// RolloutHistory returns up to limit past rollouts of entity after offset, most recent first
+func (c *Client) RolloutHistory(ctx context.Context, namespace, entity string, offset, limit int) ([]*engine.RolloutHistory, error) {
+	var history []*engine.RolloutHistory
+	if _, err := c.do(ctx, http.MethodGet, c.entityEndpoint(namespace, entity, url.Values{"offset": {strconv.Itoa(offset)}, "limit": {strconv.Itoa(limit)}}, "rollouts"), nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Pause holds rollout of group of entity while other groups continue, empty group pauses the entity
+func (c *Client) Pause(ctx context.Context, namespace, entity, group string) (*engine.RolloutState, error) {
+	rollout := &engine.RolloutState{}
+	if _, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, url.Values{"group": {group}}, "pause"), nil, rollout); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// Resume continues paused rollout of group of entity, empty group resumes the entity
+func (c *Client) Resume(ctx context.Context, namespace, entity, group string) (*engine.RolloutState, error) {
+	rollout := &engine.RolloutState{}
+	if _, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, url.Values{"group": {group}}, "resume"), nil, rollout); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// SetLastKnownGood pins last known good version of entity to a version of its last known good history
+func (c *Client) SetLastKnownGood(ctx context.Context, namespace, entity string, override *engine.VersionOverride) (*engine.RolloutState, error) {
+	rollout := &engine.RolloutState{}
+	if _, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "lastknowngood"), override, rollout); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// SetLastKnownBad marks version of entity bad, it is not rolled out until cleared
+func (c *Client) SetLastKnownBad(ctx context.Context, namespace, entity string, override *engine.VersionOverride) (*engine.RolloutState, error) {
+	rollout := &engine.RolloutState{}
+	if _, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "lastknownbad"), override, rollout); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// ClearLastKnownBad clears last known bad version of entity
+func (c *Client) ClearLastKnownBad(ctx context.Context, namespace, entity string) (*engine.RolloutState, error) {
+	rollout := &engine.RolloutState{}
+	if _, err := c.do(ctx, http.MethodDelete, c.entityEndpoint(namespace, entity, nil, "lastknownbad"), nil, rollout); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// SimulateRollout returns batches rollout of version with proposed options would select, nothing is persisted
+func (c *Client) SimulateRollout(ctx context.Context, namespace, entity string, request *engine.RolloutSimulationRequest) (*engine.RolloutSimulation, error) {
+	simulation := &engine.RolloutSimulation{}
+	if _, err := c.do(ctx, http.MethodPost, c.entityEndpoint(namespace, entity, nil, "rollout", "simulate"), request, simulation); err != nil {
+		return nil, err
+	}
+	return simulation, nil
+}
+
+// SetDependencies sets entities of namespace whose target versions wait until entities they depend on reached them
+func (c *Client) SetDependencies(ctx context.Context, namespace string, dependencies *engine.EntityDependencies) (*engine.DependencyGraph, error) {
+	graph := &engine.DependencyGraph{}
+	if _, err := c.do(ctx, http.MethodPost, c.endpoint(nil, "orchestrate", "namespace", namespace, "dependencies"), dependencies, graph); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// DependencyGraph returns entity dependencies of namespace, their rollout order and blocked entities
+func (c *Client) DependencyGraph(ctx context.Context, namespace string) (*engine.DependencyGraph, error) {
+	graph := &engine.DependencyGraph{}
+	if _, err := c.do(ctx, http.MethodGet, c.endpoint(nil, "orchestrate", "namespace", namespace, "dependencies"), nil, graph); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// AgentSocketURL returns websocket url long lived agents of entity dial, with authorization of client
+// in their handshake, to report targets and receive assignments as soon as they are made
+func (c *Client) AgentSocketURL(namespace, entity string) string {
+	endpoint := c.entityEndpoint(namespace, entity, nil, "agent")
+	if strings.HasPrefix(endpoint, "https://") {
+		return "wss://" + strings.TrimPrefix(endpoint, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(endpoint, "http://")
+}
+
+// DeleteEntity deletes entity along with its targets and rollouts
+func (c *Client) DeleteEntity(ctx context.Context, namespace, entity string) error {
+	_, err := c.do(ctx, http.MethodDelete, c.entityEndpoint(namespace, entity, nil), nil, nil)
+	return err
+}
+
+// ReadOnly returns whether server rejects mutating requests
+func (c *Client) ReadOnly(ctx context.Context) (bool, error) {
+	state := &core.ReadOnlyState{}
+	if _, err := c.do(ctx, http.MethodGet, c.endpoint(nil, "admin", "readonly"), nil, state); err != nil {
+		return false, err
+	}
+	return state.ReadOnly, nil
+}
+
+// SetReadOnly switches server to read-only mode rejecting mutating requests, or back to serving them
+func (c *Client) SetReadOnly(ctx context.Context, readOnly bool) error {
+	_, err := c.do(ctx, http.MethodPost, c.endpoint(nil, "admin", "readonly"), &core.ReadOnlyState{ReadOnly: readOnly}, nil)
+	return err
+}
+
+// LogLevel returns log level of server and when it reverts if it is temporary
+func (c *Client) LogLevel(ctx context.Context) (*core.LogLevelState, error) {
+	state := &core.LogLevelState{}
+	if _, err := c.do(ctx, http.MethodGet, c.endpoint(nil, "admin", "loglevel"), nil, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SetLogLevel changes log level of server without restarting it, level reverts after duration if it is positive
+func (c *Client) SetLogLevel(ctx context.Context, level string, duration time.Duration) (*core.LogLevelState, error) {
+	state := &core.LogLevelState{}
+	request := &core.LogLevelState{Level: level, DurationSecs: int(duration.Seconds())}
+	if _, err := c.do(ctx, http.MethodPut, c.endpoint(nil, "admin", "loglevel"), request, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (c *Client) getClientStates(ctx context.Context, url string) ([]*engine.ClientState, error) {
+	var clientStates []*engine.ClientState
+	if _, err := c.do(ctx, http.MethodGet, url, nil, &clientStates); err != nil {
+		return nil, err
+	}
+	return clientStates, nil
+}
+
+func (c *Client) getClientStatesIfChanged(ctx context.Context, url, etag string) ([]*engine.ClientState, string, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	var clientStates []*engine.ClientState
+	respHeader, err := c.doWithHeader(ctx, http.MethodGet, url, header, nil, &clientStates)
+	if err != nil {
+		return nil, "", err
+	}
+	return clientStates, respHeader.Get("ETag"), nil
+}
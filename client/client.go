@@ -0,0 +1,244 @@
+// Package client is typed, context aware client of orchestrator api. It builds requests,
+// authenticates, retries and decodes responses into core types so callers only deal with values:
+//
+//	c, err := client.New("https://orchestrator.example.com", client.WithAPIKey(key))
+//	assigned, err := c.Orchestrate(ctx, "namespace", "entity", clientStates)
+//
+// Errors of non 200 responses are *httpclient.StatusError, use errors.Is with httpclient.ErrNotFound,
+// httpclient.ErrConflict or httpclient.ErrPreconditionFailed to branch on kind of error.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+)
+
+// DefaultTimeout is timeout of whole requests, including reading response, unless changed with WithTimeout
+const DefaultTimeout = 30 * time.Second
+
+// ErrInvalidBaseURL is returned by New for base urls without http or https scheme and host
+var ErrInvalidBaseURL = errors.New("invalid orchestrator base url")
+
+// Interface is the part of Client used by agent rollout loops, depend on it to test them
+// with the fake of httpclient/clienttest
+type Interface interface {
+	Orchestrate(ctx context.Context, namespace, entity string, targets []*engine.ClientState) ([]*engine.ClientState, error)
+	ReportStatus(ctx context.Context, namespace, entity string, targets []*engine.ClientState) error
+	SetTargetVersion(ctx context.Context, namespace, entity string, version *engine.EntityTargetVersion) error
+	SetRolloutOptions(ctx context.Context, namespace, entity string, options *engine.RolloutOptions) error
+	Status(ctx context.Context, namespace, entity string) ([]*engine.ClientState, error)
+	GroupStatus(ctx context.Context, namespace, entity, group string) ([]*engine.ClientState, error)
+}
+
+var _ Interface = (*Client)(nil)
+
+// Client calls orchestrator api, it is safe for concurrent use
+type Client struct {
+	baseURL       string
+	api           *httpclient.OrchestratorAPI
+	httpClient    *http.Client
+	authorization string
+	// streamClient is httpClient without timeout, streams last as long as their ctx
+	streamClient *http.Client
+
+	timeout    time.Duration
+	tlsConfig  *tls.Config
+	apiOptions []httpclient.ClientOption
+}
+
+// Option configures Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of client with DefaultTimeout and TLS 1.3 transport
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout limits time of whole requests, zero disables timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithTLSConfig connects to orchestrator with config, such as private CA or client certificates
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithAuthorization sends value as Authorization header of requests
+func WithAuthorization(value string) Option {
+	return func(c *Client) {
+		c.authorization = value
+	}
+}
+
+// WithAPIKey authenticates requests with api key as bearer token
+func WithAPIKey(key string) Option {
+	return WithAuthorization("Bearer " + key)
+}
+
+// WithRetry retries requests failing with transport errors or retryable status codes of policy
+func WithRetry(policy httpclient.RetryPolicy) Option {
+	return func(c *Client) {
+		c.apiOptions = append(c.apiOptions, httpclient.WithRetry(policy))
+	}
+}
+
+// WithCircuitBreaker fails requests fast while breaker is open
+func WithCircuitBreaker(breaker *httpclient.CircuitBreaker) Option {
+	return func(c *Client) {
+		c.apiOptions = append(c.apiOptions, httpclient.WithCircuitBreaker(breaker))
+	}
+}
+
+// New creates client of orchestrator at baseURL, such as https://orchestrator.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBaseURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q, expected http or https url", ErrInvalidBaseURL, baseURL)
+	}
+
+	c := &Client{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: c.timeout, Transport: httpclient.NewTransport()}
+	}
+	if c.tlsConfig != nil {
+		transport, ok := c.httpClient.Transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("tls config requires *http.Transport, http client has %T", c.httpClient.Transport)
+		}
+		transport = transport.Clone()
+		transport.TLSClientConfig = c.tlsConfig
+		httpClient := *c.httpClient
+		httpClient.Transport = transport
+		c.httpClient = &httpClient
+	}
+
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	c.streamClient = &streamClient
+
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+	c.api = httpclient.NewOrchestratorAPI(c.baseURL, c.apiOptions...)
+	return c, nil
+}
+
+// API returns url builders of endpoints without typed methods
+//
+// Deprecated: use typed methods of Client, url builders of httpclient are kept only for endpoints without them
+func (c *Client) API() *httpclient.OrchestratorAPI {
+	return c.api
+}
+
+// endpoint returns url of api resource at path elements, such as orchestrate, namespace and entity,
+// elements are escaped and query is added unless it is empty
+func (c *Client) endpoint(query url.Values, elements ...string) string {
+	escaped := make([]string, len(elements))
+	for i, element := range elements {
+		escaped[i] = url.PathEscape(element)
+	}
+	endpoint := c.baseURL + "/v1/" + strings.Join(escaped, "/")
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	return endpoint
+}
+
+// entityEndpoint returns url of resource of entity at path elements below it
+func (c *Client) entityEndpoint(namespace, entity string, query url.Values, elements ...string) string {
+	return c.endpoint(query, append([]string{"orchestrate", namespace, entity}, elements...)...)
+}
+
+// send sends request with header, retry policy and circuit breaker, body of returned response must be closed.
+// httpclient.ErrNotModified is returned for 304 responses of conditional requests
+func (c *Client) send(ctx context.Context, method, url string, header http.Header, in interface{}) (*http.Response, error) {
+	return c.sendWith(ctx, c.httpClient, method, url, header, in)
+}
+
+// stream is send without timeout of client, for responses read as they arrive
+func (c *Client) stream(ctx context.Context, url string, accept string) (*http.Response, error) {
+	return c.sendWith(ctx, c.streamClient, http.MethodGet, url, http.Header{"Accept": {accept}}, nil)
+}
+
+func (c *Client) sendWith(ctx context.Context, httpClient *http.Client, method, url string, header http.Header, in interface{}) (*http.Response, error) {
+	var resp *http.Response
+	err := c.api.Do(ctx, func() error {
+		req, err := httpclient.NewJSONRequest(ctx, method, url, in)
+		if err != nil {
+			return err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+
+		resp, err = httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			closeBody(resp)
+			return httpclient.ErrNotModified
+		}
+		if err := httpclient.CheckResponse(url, resp); err != nil {
+			closeBody(resp)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// do sends request and decodes json response into out unless it is nil, returns response headers
+func (c *Client) do(ctx context.Context, method, url string, in, out interface{}) (http.Header, error) {
+	return c.doWithHeader(ctx, method, url, http.Header{}, in, out)
+}
+
+// doWithHeader is do with additional request header, such as If-None-Match
+func (c *Client) doWithHeader(ctx context.Context, method, url string, header http.Header, in, out interface{}) (http.Header, error) {
+	header.Set("Accept", "application/json")
+	resp, err := c.send(ctx, method, url, header, in)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
+}
+
+// closeBody closes response body, there is nothing left to read from it on errors
+func closeBody(resp *http.Response) {
+	_ = resp.Body.Close()
+}
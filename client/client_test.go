@@ -0,0 +1,144 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/core/orchestratortest"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	_, err := client.New("orchestrator:8080")
+	require.ErrorIs(t, err, client.ErrInvalidBaseURL)
+
+	_, err = client.New("ftp://orchestrator")
+	require.ErrorIs(t, err, client.ErrInvalidBaseURL)
+
+	c, err := client.New("http://orchestrator:8080/")
+	require.NoError(t, err)
+	assert.Equal(t, "ws://orchestrator:8080/v1/orchestrate/namespace/entity/agent", c.AgentSocketURL("namespace", "entity"))
+}
+
+func TestClient(t *testing.T) {
+	h := orchestratortest.New(t)
+	ctx := context.Background()
+
+	c := h.Client
+
+	require.NoError(t, c.SetRolloutOptions(ctx, "namespace", "entity", &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, c.SetTargetVersion(ctx, "namespace", "entity", &engine.EntityTargetVersion{Version: "v1"}))
+
+	targets := []*engine.ClientState{
+		{Name: "target1", Group: "group1", Message: "running successfully"},
+		{Name: "target2", Group: "group1", Message: "running successfully"},
+	}
+	assigned, err := c.Orchestrate(ctx, "namespace", "entity", targets)
+	require.NoError(t, err)
+	require.Len(t, assigned, 2)
+
+	status, err := c.Status(ctx, "namespace", "entity")
+	require.NoError(t, err)
+	assert.Len(t, status, 2)
+
+	var streamed []string
+	require.NoError(t, c.StreamStatus(ctx, "namespace", "entity", func(clientState *engine.ClientState) error {
+		streamed = append(streamed, clientState.Name)
+		return nil
+	}))
+	assert.Equal(t, []string{"target1", "target2"}, streamed)
+
+	status, err = c.GroupStatus(ctx, "namespace", "entity", "group1")
+	require.NoError(t, err)
+	assert.Len(t, status, 2)
+
+	status, err = c.StatusAtVersion(ctx, "namespace", "entity", "v1")
+	require.NoError(t, err)
+	assert.Len(t, status, 2)
+
+	status, err = c.ErrorStatus(ctx, "namespace", "entity")
+	require.NoError(t, err)
+	assert.Empty(t, status)
+
+	page, cursor, err := c.StatusPage(ctx, "namespace", "entity", "", 1)
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.NotEmpty(t, cursor)
+
+	namespaces, err := c.Namespaces(ctx)
+	require.NoError(t, err)
+	assert.Len(t, namespaces, 1)
+
+	entities, err := c.Entities(ctx, "namespace")
+	require.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	rollout, err := c.Pause(ctx, "namespace", "entity", "group1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"group1"}, rollout.PausedGroups)
+
+	rollout, err = c.Resume(ctx, "namespace", "entity", "group1")
+	require.NoError(t, err)
+	assert.Empty(t, rollout.PausedGroups)
+
+	_, err = c.RolloutInfo(ctx, "namespace", "entity")
+	require.NoError(t, err)
+
+	readOnly, err := c.ReadOnly(ctx)
+	require.NoError(t, err)
+	assert.False(t, readOnly)
+
+	_, err = c.LogLevel(ctx)
+	require.NoError(t, err)
+
+	_, err = c.Status(ctx, "namespace", "missing")
+	assert.ErrorIs(t, err, httpclient.ErrNotFound)
+}
+
+func TestClientAuthorization(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/orchestrate/namespaces", r.URL.Path)
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL+"/", client.WithAPIKey("key"))
+	require.NoError(t, err)
+	_, err = c.Namespaces(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer key", authorization)
+}
+
+func TestWatchStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/orchestrate/namespace/entity/status/stream", r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: snapshot\ndata: [{\"name\":\"target1\",\"version\":\"v1\"}]\n\n" +
+			": heartbeat\n\n" +
+			"event: unknown\ndata: {}\n\n" +
+			"event: target\ndata: {\"name\":\"target1\",\"version\":\"v2\"}\n\n"))
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL)
+	require.NoError(t, err)
+
+	var events []*client.StatusEvent
+	require.NoError(t, c.WatchStatus(context.Background(), "namespace", "entity", func(event *client.StatusEvent) error {
+		events = append(events, event)
+		return nil
+	}))
+	require.Len(t, events, 2)
+	require.Len(t, events[0].Snapshot, 1)
+	assert.Equal(t, "v1", events[0].Snapshot[0].Version)
+	require.NotNil(t, events[1].Target)
+	assert.Equal(t, "v2", events[1].Target.Version)
+}
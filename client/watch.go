@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nixmade/orchestrator/engine"
+)
+
+// StatusEvent is received by WatchStatus, first event is Snapshot of every target, later events update a Target
+type StatusEvent struct {
+	// Snapshot replaces targets received so far
+	Snapshot []*engine.ClientState
+	// Target is state of a single target persisted by orchestrator
+	Target *engine.ClientState
+}
+
+// WatchStatus calls fn with events of status stream of entity until ctx is done, server closes stream
+// or fn fails. Updates dropped by server for slow consumers are not replayed, watch again for a new snapshot
+func (c *Client) WatchStatus(ctx context.Context, namespace, entity string, fn func(*StatusEvent) error) error {
+	resp, err := c.stream(ctx, c.entityEndpoint(namespace, entity, nil, "status", "stream"), "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	reader := bufio.NewReader(resp.Body)
+	var event string
+	var data bytes.Buffer
+	for {
+		// lines are not bounded, snapshots of large entities are a single line
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case len(line) == 0:
+			if data.Len() > 0 {
+				if err := dispatchStatusEvent(event, data.Bytes(), fn); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data.Reset()
+		case line[0] == ':':
+			// heartbeat comment
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(line[len("data:"):], []byte(" ")))
+		}
+	}
+}
+
+func dispatchStatusEvent(event string, data []byte, fn func(*StatusEvent) error) error {
+	statusEvent := &StatusEvent{}
+	switch event {
+	case "snapshot":
+		if err := json.Unmarshal(data, &statusEvent.Snapshot); err != nil {
+			return fmt.Errorf("invalid status snapshot: %w", err)
+		}
+	case "target":
+		statusEvent.Target = &engine.ClientState{}
+		if err := json.Unmarshal(data, statusEvent.Target); err != nil {
+			return fmt.Errorf("invalid status update: %w", err)
+		}
+	default:
+		// events added by newer servers
+		return nil
+	}
+	return fn(statusEvent)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nixmade/orchestrator/agentutil"
+	"github.com/nixmade/orchestrator/k8sagent"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	appCli := &cli.App{
+		Name:  "k8sagent",
+		Usage: "reports kubernetes deployments and statefulsets to orchestrator and rolls out assigned versions",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "orchestrator",
+				Usage:    "orchestrator url, e.g. http://orchestrator:8080",
+				EnvVars:  []string{"ORCHESTRATOR_URL"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "api-key",
+				Usage:   "jwt sent as bearer token if orchestrator requires authentication",
+				EnvVars: []string{"ORCHESTRATOR_API_KEY"},
+			},
+			&cli.StringFlag{
+				Name:     "namespace",
+				Usage:    "orchestrator namespace",
+				EnvVars:  []string{"ORCHESTRATOR_NAMESPACE"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "entity",
+				Usage:    "orchestrator entity workloads are targets of",
+				EnvVars:  []string{"ORCHESTRATOR_ENTITY"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "cluster",
+				Usage:    "cluster name, reported as group of targets",
+				EnvVars:  []string{"K8SAGENT_CLUSTER"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "kube-namespace",
+				Usage:   "kubernetes namespace of workloads, all namespaces if empty",
+				EnvVars: []string{"K8SAGENT_KUBE_NAMESPACE"},
+			},
+			&cli.StringFlag{
+				Name:    "selector",
+				Usage:   "label selector of workloads",
+				EnvVars: []string{"K8SAGENT_SELECTOR"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "resources",
+				Usage:   "workload resources, deployments and/or statefulsets",
+				EnvVars: []string{"K8SAGENT_RESOURCES"},
+				Value:   cli.NewStringSlice(k8sagent.Deployments, k8sagent.StatefulSets),
+			},
+			&cli.StringFlag{
+				Name:    "container",
+				Usage:   "container whose image tag is the version, first container if empty",
+				EnvVars: []string{"K8SAGENT_CONTAINER"},
+			},
+			&cli.DurationFlag{
+				Name:    "interval",
+				Usage:   "interval between reports",
+				EnvVars: []string{"K8SAGENT_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "kubeconfig",
+				Usage:   "kubeconfig file, in cluster service account is used if empty",
+				EnvVars: []string{"KUBECONFIG"},
+			},
+			&cli.StringFlag{
+				Name:  "context",
+				Usage: "kubeconfig context, current context if empty",
+			},
+			&cli.StringFlag{
+				Name:    "log-level",
+				Usage:   "log level",
+				EnvVars: []string{"K8SAGENT_LOG_LEVEL"},
+				Value:   "info",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			level, err := zerolog.ParseLevel(c.String("log-level"))
+			if err != nil {
+				return err
+			}
+			logger := zerolog.New(os.Stderr).With().Timestamp().Str("Cluster", c.String("cluster")).Logger().Level(level)
+
+			var kubeConfig *k8sagent.KubeConfig
+			if path := c.String("kubeconfig"); path != "" {
+				// KUBECONFIG may list files, first file is used
+				kubeConfig, err = k8sagent.LoadKubeconfig(strings.Split(path, string(os.PathListSeparator))[0], c.String("context"))
+			} else {
+				kubeConfig, err = k8sagent.InClusterConfig()
+			}
+			if err != nil {
+				return err
+			}
+			kube, err := k8sagent.NewClient(kubeConfig)
+			if err != nil {
+				return err
+			}
+
+			orchestrator, err := agentutil.NewClient(c.String("orchestrator"), c.String("api-key"))
+			if err != nil {
+				return err
+			}
+
+			agent := k8sagent.New(kube, orchestrator, k8sagent.Options{
+				Namespace:     c.String("namespace"),
+				Entity:        c.String("entity"),
+				Options:       agentutil.Options{Interval: c.Duration("interval")},
+				Cluster:       c.String("cluster"),
+				KubeNamespace: c.String("kube-namespace"),
+				Selector:      c.String("selector"),
+				Resources:     c.StringSlice("resources"),
+				Container:     c.String("container"),
+			}, logger)
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			logger.Info().Str("Orchestrator", c.String("orchestrator")).Str("Selector", c.String("selector")).Msg("Starting kubernetes agent")
+			if err := agent.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	if err := appCli.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
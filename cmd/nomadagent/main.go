@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nixmade/orchestrator/agentutil"
+	"github.com/nixmade/orchestrator/nomadagent"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	appCli := &cli.App{
+		Name:  "nomadagent",
+		Usage: "reports nomad job allocations to orchestrator and rolls out assigned versions",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "orchestrator",
+				Usage:    "orchestrator url, e.g. http://orchestrator:8080",
+				EnvVars:  []string{"ORCHESTRATOR_URL"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "api-key",
+				Usage:   "jwt sent as bearer token if orchestrator requires authentication",
+				EnvVars: []string{"ORCHESTRATOR_API_KEY"},
+			},
+			&cli.StringFlag{
+				Name:     "namespace",
+				Usage:    "orchestrator namespace",
+				EnvVars:  []string{"ORCHESTRATOR_NAMESPACE"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "entity",
+				Usage:    "orchestrator entity allocations are targets of",
+				EnvVars:  []string{"ORCHESTRATOR_ENTITY"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "cluster",
+				Usage:    "cluster or region name, reported as group of targets",
+				EnvVars:  []string{"NOMADAGENT_CLUSTER"},
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:     "jobs",
+				Usage:    "nomad jobs whose allocations are reported",
+				EnvVars:  []string{"NOMADAGENT_JOBS"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "task",
+				Usage:   "task whose image tag or artifact version is the version, first task of each group if empty",
+				EnvVars: []string{"NOMADAGENT_TASK"},
+			},
+			&cli.DurationFlag{
+				Name:    "interval",
+				Usage:   "interval between reports",
+				EnvVars: []string{"NOMADAGENT_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "nomad-address",
+				Usage:   "nomad http api address",
+				EnvVars: []string{"NOMAD_ADDR"},
+				Value:   "http://127.0.0.1:4646",
+			},
+			&cli.StringFlag{
+				Name:    "nomad-token",
+				Usage:   "nomad acl token",
+				EnvVars: []string{"NOMAD_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:    "nomad-namespace",
+				Usage:   "nomad namespace of jobs",
+				EnvVars: []string{"NOMAD_NAMESPACE"},
+			},
+			&cli.StringFlag{
+				Name:    "nomad-region",
+				Usage:   "nomad region of jobs",
+				EnvVars: []string{"NOMAD_REGION"},
+			},
+			&cli.StringFlag{
+				Name:    "log-level",
+				Usage:   "log level",
+				EnvVars: []string{"NOMADAGENT_LOG_LEVEL"},
+				Value:   "info",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			level, err := zerolog.ParseLevel(c.String("log-level"))
+			if err != nil {
+				return err
+			}
+			logger := zerolog.New(os.Stderr).With().Timestamp().Str("Cluster", c.String("cluster")).Logger().Level(level)
+
+			nomad := nomadagent.NewClient(c.String("nomad-address"), c.String("nomad-token"), c.String("nomad-namespace"), c.String("nomad-region"))
+
+			orchestrator, err := agentutil.NewClient(c.String("orchestrator"), c.String("api-key"))
+			if err != nil {
+				return err
+			}
+
+			agent := nomadagent.New(nomad, orchestrator, nomadagent.Options{
+				Namespace: c.String("namespace"),
+				Entity:    c.String("entity"),
+				Options:   agentutil.Options{Interval: c.Duration("interval")},
+				Cluster:   c.String("cluster"),
+				Jobs:      c.StringSlice("jobs"),
+				Task:      c.String("task"),
+			}, logger)
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			logger.Info().Str("Orchestrator", c.String("orchestrator")).Strs("Jobs", c.StringSlice("jobs")).Msg("Starting nomad agent")
+			if err := agent.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	if err := appCli.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
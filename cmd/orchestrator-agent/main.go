@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nixmade/orchestrator/agentutil"
+	"github.com/nixmade/orchestrator/hostagent"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+)
+
+// parseLabels parses key=value labels
+func parseLabels(values []string) (map[string]string, error) {
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		key, label, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", value)
+		}
+		labels[key] = label
+	}
+	return labels, nil
+}
+
+func main() {
+	hostname, _ := os.Hostname()
+
+	appCli := &cli.App{
+		Name:  "orchestrator-agent",
+		Usage: "reports version installed on host to orchestrator and runs upgrade command for assigned versions",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "orchestrator",
+				Usage:    "orchestrator url, e.g. http://orchestrator:8080",
+				EnvVars:  []string{"ORCHESTRATOR_URL"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "api-key",
+				Usage:   "jwt sent as bearer token if orchestrator requires authentication",
+				EnvVars: []string{"ORCHESTRATOR_API_KEY"},
+			},
+			&cli.StringFlag{
+				Name:     "namespace",
+				Usage:    "orchestrator namespace",
+				EnvVars:  []string{"ORCHESTRATOR_NAMESPACE"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "entity",
+				Usage:    "orchestrator entity host is a target of",
+				EnvVars:  []string{"ORCHESTRATOR_ENTITY"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "name",
+				Usage:   "target name",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_NAME"},
+				Value:   hostname,
+			},
+			&cli.StringFlag{
+				Name:     "group",
+				Usage:    "target group, e.g. datacenter",
+				EnvVars:  []string{"ORCHESTRATOR_AGENT_GROUP"},
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:    "label",
+				Usage:   "target label as key=value, may be repeated",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_LABELS"},
+			},
+			&cli.StringFlag{
+				Name:     "version-command",
+				Usage:    "shell command printing installed version",
+				EnvVars:  []string{"ORCHESTRATOR_AGENT_VERSION_COMMAND"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "upgrade-command",
+				Usage:    "shell command installing version in $ORCHESTRATOR_VERSION",
+				EnvVars:  []string{"ORCHESTRATOR_AGENT_UPGRADE_COMMAND"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "health-command",
+				Usage:   "optional shell command, target reports error while it fails",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_HEALTH_COMMAND"},
+			},
+			&cli.DurationFlag{
+				Name:    "command-timeout",
+				Usage:   "timeout of every command",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_COMMAND_TIMEOUT"},
+			},
+			&cli.IntFlag{
+				Name:    "retries",
+				Usage:   "retries of failed upgrade command, negative disables retries",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_RETRIES"},
+			},
+			&cli.DurationFlag{
+				Name:    "retry-backoff",
+				Usage:   "wait before first retry, doubled with every retry",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_RETRY_BACKOFF"},
+			},
+			&cli.DurationFlag{
+				Name:    "interval",
+				Usage:   "interval between reports",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_INTERVAL"},
+			},
+			&cli.BoolFlag{
+				Name:  "once",
+				Usage: "sync once and exit, non zero exit code if sync failed",
+			},
+			&cli.StringFlag{
+				Name:    "log-level",
+				Usage:   "log level",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_LOG_LEVEL"},
+				Value:   "info",
+			},
+			&cli.StringFlag{
+				Name:    "log-format",
+				Usage:   "json or console, journald adds timestamps to console logs",
+				EnvVars: []string{"ORCHESTRATOR_AGENT_LOG_FORMAT"},
+				Value:   "json",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			level, err := zerolog.ParseLevel(c.String("log-level"))
+			if err != nil {
+				return err
+			}
+			var logger zerolog.Logger
+			switch c.String("log-format") {
+			case "json":
+				logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+			case "console":
+				logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, NoColor: true, PartsExclude: []string{zerolog.TimestampFieldName}})
+			default:
+				return fmt.Errorf("invalid log format %q, expected json or console", c.String("log-format"))
+			}
+			logger = logger.With().Str("Target", c.String("name")).Logger().Level(level)
+
+			labels, err := parseLabels(c.StringSlice("label"))
+			if err != nil {
+				return err
+			}
+
+			orchestrator, err := agentutil.NewClient(c.String("orchestrator"), c.String("api-key"))
+			if err != nil {
+				return err
+			}
+
+			agent := hostagent.New(orchestrator, hostagent.Options{
+				Namespace:      c.String("namespace"),
+				Entity:         c.String("entity"),
+				Options:        agentutil.Options{Interval: c.Duration("interval")},
+				Name:           c.String("name"),
+				Group:          c.String("group"),
+				Labels:         labels,
+				VersionCommand: c.String("version-command"),
+				UpgradeCommand: c.String("upgrade-command"),
+				HealthCommand:  c.String("health-command"),
+				CommandTimeout: c.Duration("command-timeout"),
+				Retries:        c.Int("retries"),
+				RetryBackoff:   c.Duration("retry-backoff"),
+			}, logger)
+
+			// systemd stops units with SIGTERM, running commands are killed and agent exits cleanly
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if c.Bool("once") {
+				return agent.Sync(ctx)
+			}
+
+			logger.Info().Str("Orchestrator", c.String("orchestrator")).Str("Group", c.String("group")).Msg("Starting host agent")
+			if err := agent.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	if err := appCli.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
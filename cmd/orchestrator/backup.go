@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/urfave/cli/v2"
+)
+
+// openStore opens store configured by --config file, environment and server flags
+func openStore(c *cli.Context) (store.Store, error) {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return openConfigStore(cfg)
+}
+
+// openConfigStore opens store backend of cfg
+func openConfigStore(cfg *config.Config) (store.Store, error) {
+	opts := cfg.Store.Options()
+	if cfg.Store.KeyProvider.Enabled() {
+		provider, err := cfg.Store.KeyProvider.Provider()
+		if err != nil {
+			return nil, err
+		}
+		opts.KeyProvider = provider
+	}
+	return store.Open(cfg.Store.Backend, cfg.Store.DSN(), opts)
+}
+
+var backupCommand = &cli.Command{
+	Name:  "backup",
+	Usage: "exports all store keys as versioned json lines",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "backup file, defaults to stdout",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := openStore(c)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		var w io.Writer = os.Stdout
+		if output := c.String("output"); output != "" && output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		count, err := store.Export(s, w)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "exported %d keys\n", count)
+		return nil
+	},
+}
+
+var restoreCommand = &cli.Command{
+	Name:  "restore",
+	Usage: "imports store keys from backup, existing keys are overwritten",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Usage:   "backup file, defaults to stdin",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := openStore(c)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		var r io.Reader = os.Stdin
+		if input := c.String("input"); input != "" && input != "-" {
+			f, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		count, err := store.Import(s, r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "imported %d keys\n", count)
+		return nil
+	},
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/urfave/cli/v2"
+)
+
+var dependenciesCommand = &cli.Command{
+	Name:  "dependencies",
+	Usage: "shows entity dependency graph of namespace, or replaces dependencies with --depends",
+	Flags: connectionFlags(
+		&cli.StringFlag{
+			Name:     "namespace",
+			Aliases:  []string{"n"},
+			Usage:    "namespace of entities",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "depends",
+			Usage: "ENTITY=DEPENDENCY, entity waits until dependency reached its target version, repeat for every dependency",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace := c.String("namespace")
+
+		var graph *engine.DependencyGraph
+		if c.IsSet("depends") {
+			dependencies, err := parseDependencies(c.StringSlice("depends"))
+			if err != nil {
+				return err
+			}
+			graph, err = orchestrator.SetDependencies(c.Context, namespace, dependencies)
+			if err != nil {
+				return err
+			}
+		} else if graph, err = orchestrator.DependencyGraph(c.Context, namespace); err != nil {
+			return err
+		}
+
+		stages := map[string]int{}
+		for i, stage := range graph.Stages {
+			for _, entity := range stage {
+				stages[entity] = i + 1
+			}
+		}
+		return writeOutput(c.App.Writer, c.String("output"), graph, func(w *tabwriter.Writer) {
+			row(w, "STAGE", "ENTITY", "DEPENDS ON", "TARGET", "ROLLING", "LAST KNOWN GOOD", "BLOCKED BY")
+			for _, node := range graph.Nodes {
+				row(w, fmt.Sprint(stages[node.Entity]), node.Entity, strings.Join(node.DependsOn, ","),
+					node.TargetVersion, node.RollingVersion, node.LastKnownGoodVersion, strings.Join(node.BlockedBy, ","))
+			}
+		})
+	},
+}
+
+// parseDependencies groups ENTITY=DEPENDENCY pairs by entity, keeping order of first occurrence
+func parseDependencies(pairs []string) (*engine.EntityDependencies, error) {
+	dependencies := &engine.EntityDependencies{}
+	index := map[string]int{}
+	for _, pair := range pairs {
+		entity, dependsOn, ok := strings.Cut(pair, "=")
+		if !ok || entity == "" || dependsOn == "" {
+			return nil, fmt.Errorf("invalid dependency %q, expected ENTITY=DEPENDENCY", pair)
+		}
+		i, ok := index[entity]
+		if !ok {
+			i = len(dependencies.Dependencies)
+			index[entity] = i
+			dependencies.Dependencies = append(dependencies.Dependencies, engine.EntityDependency{Entity: entity})
+		}
+		dependencies.Dependencies[i].DependsOn = append(dependencies.Dependencies[i].DependsOn, dependsOn)
+	}
+	return dependencies, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/urfave/cli/v2"
+)
+
+// doctorReport is output of doctor, configuration has secrets redacted
+type doctorReport struct {
+	Healthy     bool                `json:"healthy"`
+	Backend     string              `json:"backend"`
+	Diagnostics *engine.Diagnostics `json:"diagnostics"`
+	Config      *config.Config      `json:"config"`
+}
+
+var doctorCommand = &cli.Command{
+	Name:  "doctor",
+	Usage: "checks store and controller endpoints, reports key counts and orphaned targets, prints configuration",
+	Description: "Reads store configured by --config, environment and server flags, badger store directory can not be " +
+		"open by a running server. Exits with status 1 if any check fails, attach output to issues.",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "how long to wait for each controller endpoint to accept a connection",
+			Value: engine.DefaultDialTimeout,
+		},
+		outputFlag,
+	},
+	Action: func(c *cli.Context) error {
+		if err := validateOutput(c); err != nil {
+			return err
+		}
+		cfg, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+
+		report := &doctorReport{Backend: cfg.Store.Backend, Config: cfg.Redacted()}
+		s, err := openConfigStore(cfg)
+		if err != nil {
+			report.Diagnostics = &engine.Diagnostics{StoreError: err.Error()}
+		} else {
+			defer s.Close()
+			report.Diagnostics = engine.Diagnose(c.Context, s, c.Duration("timeout"))
+		}
+		report.Healthy = report.Diagnostics.Healthy()
+
+		if err := writeOutput(c.App.Writer, c.String("output"), report, func(w *tabwriter.Writer) {
+			writeDoctorRows(w, report)
+		}); err != nil {
+			return err
+		}
+		if c.String("output") == outputTable {
+			if _, err := fmt.Fprintln(c.App.Writer, "\nCONFIG"); err != nil {
+				return err
+			}
+			if err := writeOutput(c.App.Writer, outputYAML, report.Config, nil); err != nil {
+				return err
+			}
+		}
+		if !report.Healthy {
+			return cli.Exit("doctor found problems", 1)
+		}
+		return nil
+	},
+}
+
+func writeDoctorRows(w *tabwriter.Writer, report *doctorReport) {
+	d := report.Diagnostics
+	row(w, "STORE", "STATUS", "LATENCY")
+	if d.StoreError != "" {
+		row(w, report.Backend, "error: "+d.StoreError, "")
+		return
+	}
+	row(w, report.Backend, "ok", d.StoreLatency.Round(time.Microsecond).String())
+
+	prefixes := make([]string, 0, len(d.KeyCounts))
+	for prefix := range d.KeyCounts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	_, _ = fmt.Fprintln(w)
+	row(w, "PREFIX", "KEYS")
+	for _, prefix := range prefixes {
+		row(w, prefix, fmt.Sprint(d.KeyCounts[prefix]))
+	}
+
+	_, _ = fmt.Fprintln(w)
+	row(w, "ORPHANED TARGETS", fmt.Sprint(d.OrphanedTargets))
+	for _, key := range d.OrphanedTargetKeys {
+		row(w, "  "+key)
+	}
+	if more := d.OrphanedTargets - len(d.OrphanedTargetKeys); more > 0 {
+		row(w, fmt.Sprintf("  ... %d more", more))
+	}
+
+	if len(d.Endpoints) > 0 {
+		_, _ = fmt.Fprintln(w)
+		row(w, "NAMESPACE", "ENTITY", "CONTROLLER", "ENDPOINT", "STATUS", "LATENCY")
+		for _, endpoint := range d.Endpoints {
+			status, latency := "ok", endpoint.Latency.Round(time.Microsecond).String()
+			if endpoint.Error != "" {
+				status, latency = "error: "+endpoint.Error, ""
+			}
+			controller := endpoint.Controller[strings.LastIndex(endpoint.Controller, ".")+1:]
+			row(w, endpoint.Namespace, endpoint.Entity, controller, endpoint.Endpoint, status, latency)
+		}
+	}
+
+	for _, err := range d.Errors {
+		row(w, "error: "+err)
+	}
+}
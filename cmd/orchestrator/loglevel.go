@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nixmade/orchestrator/core"
+	"github.com/urfave/cli/v2"
+)
+
+var logLevelCommand = &cli.Command{
+	Name:      "loglevel",
+	Usage:     "prints or changes log level of running server without restarting it",
+	ArgsUsage: "[trace|debug|info|warn|error|fatal|panic|disabled]",
+	Flags: connectionFlags(&cli.DurationFlag{
+		Name:  "for",
+		Usage: "revert to previous level after duration, e.g. 15m, level is kept until changed again if 0",
+	}),
+	Action: func(c *cli.Context) error {
+		if c.NArg() > 1 {
+			return fmt.Errorf("expected at most one level, got %d arguments", c.NArg())
+		}
+		if c.Duration("for") < 0 {
+			return fmt.Errorf("invalid --for %s, expected positive duration", c.Duration("for"))
+		}
+		api, err := newClient(c)
+		if err != nil {
+			return err
+		}
+
+		var state *core.LogLevelState
+		if level := c.Args().First(); level != "" {
+			state, err = api.SetLogLevel(c.Context, level, c.Duration("for"))
+		} else {
+			state, err = api.LogLevel(c.Context)
+		}
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(c.App.Writer, c.String("output"), state, func(w *tabwriter.Writer) {
+			revertAt := ""
+			if state.RevertAt != nil {
+				revertAt = state.RevertAt.Local().Format(time.RFC3339)
+			}
+			row(w, "LEVEL", "REVERTS TO", "REVERTS AT")
+			row(w, state.Level, state.RevertLevel, revertAt)
+		})
+	},
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
 
+	"github.com/nixmade/orchestrator/config"
 	"github.com/nixmade/orchestrator/core"
 	"github.com/nixmade/orchestrator/server"
 	"github.com/urfave/cli/v2"
@@ -12,9 +16,115 @@ import (
 func main() {
 	appCli := &cli.App{
 		Name:  "orchestrator",
-		Usage: "starts orchestrator server",
+		Usage: "starts orchestrator server, subcommands operate stores and rollouts",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "yaml, toml or json config file, environment variables override file values",
+				EnvVars: []string{"APP_CONFIG_FILE"},
+			},
+			&cli.BoolFlag{
+				Name:    "read-only",
+				Usage:   "start in read-only mode, mutating requests return 503",
+				EnvVars: []string{"APP_READ_ONLY"},
+			},
+			&cli.StringFlag{
+				Name:    "jwt-secret",
+				Usage:   "hmac secret validating bearer tokens",
+				EnvVars: []string{"APP_JWT_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:    "jwks-url",
+				Usage:   "json web key set url validating bearer tokens",
+				EnvVars: []string{"APP_JWKS_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "jwt-issuer",
+				Usage:   "expected issuer of bearer tokens",
+				EnvVars: []string{"APP_JWT_ISSUER"},
+			},
+			&cli.StringFlag{
+				Name:    "jwt-audience",
+				Usage:   "expected audience of bearer tokens",
+				EnvVars: []string{"APP_JWT_AUDIENCE"},
+			},
+			&cli.StringFlag{
+				Name:    "listen",
+				Usage:   "address server listens on, host or host:port",
+				EnvVars: []string{"APP_LISTEN_ADDRESS"},
+			},
+			&cli.IntFlag{
+				Name:    "port",
+				Usage:   "port server listens on, overrides port of --listen",
+				EnvVars: []string{"APP_PORT"},
+			},
+			&cli.StringFlag{
+				Name:    "store",
+				Usage:   "store backend, badger or postgres",
+				EnvVars: []string{"APP_STORE_BACKEND"},
+			},
+			&cli.StringFlag{
+				Name:    "db-url",
+				Usage:   "database url of store backends other than badger",
+				EnvVars: []string{"APP_DATABASE_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "data-dir",
+				Usage:   "badger store directory",
+				EnvVars: []string{"APP_CONFIG_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "log-level",
+				Usage:   "log level, debug, info, warn or error",
+				EnvVars: []string{"APP_LOG_LEVEL"},
+			},
+			&cli.StringFlag{
+				Name:    "log-format",
+				Usage:   "console or json",
+				EnvVars: []string{"APP_LOG_FORMAT"},
+			},
+		},
+		Commands: []*cli.Command{
+			backupCommand,
+			restoreCommand,
+			migrateCommand,
+			statusCommand,
+			progressCommand,
+			quarantineCommand,
+			setVersionCommand,
+			setOptionsCommand,
+			pauseRolloutCommand,
+			resumeRolloutCommand,
+			rollbackCommand,
+			markBadCommand,
+			setLastKnownGoodCommand,
+			versionHistoryCommand,
+			dependenciesCommand,
+			simulateCommand,
+			watchCommand,
+			doctorCommand,
+			logLevelCommand,
+		},
 		Action: func(c *cli.Context) error {
-			return server.Execute(core.NewApp())
+			cfg, err := loadConfig(c)
+			if err != nil {
+				return err
+			}
+			opts := []core.Option{core.WithConfig(cfg), core.WithReadOnly(c.Bool("read-only"))}
+			authConfig := &server.AuthConfig{
+				HMACSecret: c.String("jwt-secret"),
+				JWKSURL:    c.String("jwks-url"),
+				Issuer:     c.String("jwt-issuer"),
+				Audience:   c.String("jwt-audience"),
+			}
+			if authConfig.Enabled() {
+				auth, err := server.NewAuthenticator(authConfig)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, core.WithAuth(auth))
+			}
+			return server.Execute(core.NewApp(opts...))
 		},
 	}
 
@@ -23,3 +133,50 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// loadConfig loads --config file and environment, overridden by server flags
+func loadConfig(c *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(c.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	if err := applyServerFlags(c, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyServerFlags overrides config file and environment with server flags that are set
+func applyServerFlags(c *cli.Context, cfg *config.Config) error {
+	if c.IsSet("listen") {
+		listen := c.String("listen")
+		if host, port, err := net.SplitHostPort(listen); err == nil {
+			portNum, err := strconv.Atoi(port)
+			if err != nil {
+				return fmt.Errorf("invalid --listen port %q: %w", port, err)
+			}
+			cfg.Server.Address, cfg.Server.Port = host, portNum
+		} else {
+			cfg.Server.Address = listen
+		}
+	}
+	if c.IsSet("port") {
+		cfg.Server.Port = c.Int("port")
+	}
+	if c.IsSet("store") {
+		cfg.Store.Backend = c.String("store")
+	}
+	if c.IsSet("db-url") {
+		cfg.Store.DatabaseURL = c.String("db-url")
+	}
+	if c.IsSet("data-dir") {
+		cfg.Store.Directory = c.String("data-dir")
+	}
+	if c.IsSet("log-level") {
+		cfg.Log.Level = c.String("log-level")
+	}
+	if c.IsSet("log-format") {
+		cfg.Log.Format = c.String("log-format")
+	}
+	return cfg.Validate()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/urfave/cli/v2"
+)
+
+// openDSN opens store addressed as driver:dsn
+func openDSN(spec, encryptionKey string) (store.Store, error) {
+	driver, dsn, err := store.ParseDSN(spec)
+	if err != nil {
+		return nil, err
+	}
+	return store.Open(driver, dsn, store.Options{EncryptionKey: encryptionKey})
+}
+
+// setServerReadOnly toggles read-only mode of running server, returns previous state
+func setServerReadOnly(ctx context.Context, server *client.Client, readOnly bool) (bool, error) {
+	previous, err := server.ReadOnly(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := server.SetReadOnly(ctx, readOnly); err != nil {
+		return false, err
+	}
+	return previous, nil
+}
+
+var migrateCommand = &cli.Command{
+	Name:  "migrate",
+	Usage: "copies all keys between store backends and verifies checksums",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "source store as driver:dsn, e.g. badger:/var/lib/orch",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "destination store as driver:dsn, e.g. postgres://user@host/db",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "from-encryption-key",
+			Usage: "encryption key of source store, badger only",
+		},
+		&cli.StringFlag{
+			Name:  "to-encryption-key",
+			Usage: "encryption key of destination store, badger only",
+		},
+		&cli.BoolFlag{
+			Name:  "overwrite",
+			Usage: "copy into destination store that already has keys",
+		},
+		&cli.StringFlag{
+			Name:  "server",
+			Usage: "url of running server switched to read-only while migrating, e.g. http://localhost:8080",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			Usage:   "bearer token for server admin api",
+			EnvVars: []string{"APP_ADMIN_TOKEN"},
+		},
+	},
+	Action: func(c *cli.Context) (err error) {
+		src, err := openDSN(c.String("from"), c.String("from-encryption-key"))
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		dst, err := openDSN(c.String("to"), c.String("to-encryption-key"))
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+
+		if !c.Bool("overwrite") {
+			count, err := dst.Count("")
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("destination store has %d keys, use --overwrite to copy anyway", count)
+			}
+		}
+
+		if server := c.String("server"); server != "" {
+			var opts []client.Option
+			if token := c.String("token"); token != "" {
+				opts = append(opts, client.WithAPIKey(token))
+			}
+			serverClient, err := client.New(server, opts...)
+			if err != nil {
+				return err
+			}
+			previous, err := setServerReadOnly(c.Context, serverClient, true)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "server %s is read-only\n", server)
+			// server keeps serving reads from source store, restore writes only if migration failed
+			defer func() {
+				if err != nil && !previous {
+					if _, restoreErr := setServerReadOnly(c.Context, serverClient, false); restoreErr != nil {
+						err = errors.Join(err, restoreErr)
+					}
+				}
+			}()
+		}
+
+		result, err := store.Migrate(src, dst, func(copied int64) {
+			fmt.Fprintf(os.Stderr, "copied %d keys\n", copied)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "migrated %d keys, checksum %s\n", result.Keys, result.Checksum)
+		return nil
+	},
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// output formats of commands talking to a running server
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFlag = &cli.StringFlag{
+	Name:    "output",
+	Aliases: []string{"o"},
+	Usage:   "output format, table, json or yaml",
+	Value:   outputTable,
+}
+
+// validateOutput fails unknown --output formats before any request is sent
+func validateOutput(c *cli.Context) error {
+	switch c.String("output") {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid output %q, expected table, json or yaml", c.String("output"))
+}
+
+// writeOutput writes value as json or yaml document, or as table written by table.
+// yaml keys are json names of fields, same as api and config files
+func writeOutput(w io.Writer, format string, value interface{}, table func(*tabwriter.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case outputYAML:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+}
+
+// row writes tab separated columns of table row, empty columns are shown as -
+func row(w io.Writer, columns ...string) {
+	for i, column := range columns {
+		if column == "" {
+			columns[i] = "-"
+		}
+	}
+	_, _ = io.WriteString(w, strings.Join(columns, "\t")+"\n")
+}
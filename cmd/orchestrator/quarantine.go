@@ -0,0 +1,53 @@
+package main
+
+import (
+	"text/tabwriter"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/urfave/cli/v2"
+)
+
+var quarantineCommand = &cli.Command{
+	Name:  "quarantine",
+	Usage: "lists quarantined targets of entity, or releases target from quarantine with --release",
+	Flags: serverFlags(
+		&cli.StringFlag{
+			Name:  "release",
+			Usage: "name of target released from quarantine, it is part of rollouts again",
+		},
+		&cli.StringFlag{
+			Name:  "group",
+			Usage: "group of released target",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace, entity := c.String("namespace"), c.String("entity")
+
+		if name := c.String("release"); name != "" {
+			target := &engine.ClientState{Name: name, Group: c.String("group")}
+			if err := orchestrator.ReleaseQuarantinedTarget(c.Context, namespace, entity, target); err != nil {
+				return err
+			}
+		}
+
+		targets, err := orchestrator.StatusInState(c.Context, namespace, entity, engine.TargetStateQuarantined)
+		if err != nil {
+			return err
+		}
+		return writeOutput(c.App.Writer, c.String("output"), targets, func(w *tabwriter.Writer) {
+			row(w, "NAME", "GROUP", "VERSION", "LAST SEEN", "MESSAGE")
+			for _, target := range targets {
+				lastSeen := ""
+				if !target.LastSeen.IsZero() {
+					lastSeen = target.LastSeen.Format(time.RFC3339)
+				}
+				row(w, target.Name, target.Group, target.Version, lastSeen, target.Message)
+			}
+		})
+	},
+}
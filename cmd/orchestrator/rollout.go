@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// connectionFlags address running server, shared by commands talking to it
+func connectionFlags(flags ...cli.Flag) []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "server",
+			Usage:   "url of running server",
+			EnvVars: []string{"ORCHESTRATOR_SERVER"},
+			Value:   "http://localhost:8080",
+		},
+		&cli.StringFlag{
+			Name:    "api-key",
+			Usage:   "bearer token of server api",
+			EnvVars: []string{"ORCHESTRATOR_API_KEY"},
+		},
+		outputFlag,
+	}, flags...)
+}
+
+// serverFlags address entity of running server, shared by commands operating rollouts
+func serverFlags(flags ...cli.Flag) []cli.Flag {
+	return connectionFlags(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "namespace",
+			Aliases:  []string{"n"},
+			Usage:    "namespace of entity",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "entity",
+			Aliases:  []string{"e"},
+			Usage:    "entity rolled out",
+			Required: true,
+		},
+	}, flags...)...)
+}
+
+// newClient creates client of --server, validating --output first
+func newClient(c *cli.Context) (*client.Client, error) {
+	if err := validateOutput(c); err != nil {
+		return nil, err
+	}
+	var opts []client.Option
+	if apiKey := c.String("api-key"); apiKey != "" {
+		opts = append(opts, client.WithAPIKey(apiKey))
+	}
+	return client.New(c.String("server"), opts...)
+}
+
+// writeRollout writes rollout state of entity
+func writeRollout(c *cli.Context, rollout *engine.RolloutState) error {
+	return writeOutput(c.App.Writer, c.String("output"), rollout, func(w *tabwriter.Writer) {
+		writeRolloutRows(w, rollout)
+	})
+}
+
+func writeRolloutRows(w io.Writer, rollout *engine.RolloutState) {
+	paused := fmt.Sprint(rollout.Paused)
+	if len(rollout.PausedGroups) > 0 {
+		paused = fmt.Sprint(rollout.PausedGroups)
+	}
+	row(w, "TARGET", "ROLLING", "LAST KNOWN GOOD", "LAST KNOWN BAD", "PAUSED")
+	row(w, rollout.TargetVersion, rollout.RollingVersion, rollout.LastKnownGoodVersion, rollout.LastKnownBadVersion, paused)
+}
+
+// entityStatus is output of status command
+type entityStatus struct {
+	Rollout *engine.RolloutState  `json:"rollout"`
+	Targets []*engine.ClientState `json:"targets"`
+}
+
+var statusCommand = &cli.Command{
+	Name:  "status",
+	Usage: "shows rollout and targets of entity",
+	Flags: serverFlags(
+		&cli.StringFlag{
+			Name:  "group",
+			Usage: "show targets of group only",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace, entity := c.String("namespace"), c.String("entity")
+
+		status := &entityStatus{}
+		if status.Rollout, err = orchestrator.RolloutInfo(c.Context, namespace, entity); err != nil {
+			return err
+		}
+		if group := c.String("group"); group != "" {
+			status.Targets, err = orchestrator.GroupStatus(c.Context, namespace, entity, group)
+		} else {
+			status.Targets, err = orchestrator.Status(c.Context, namespace, entity)
+		}
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(c.App.Writer, c.String("output"), status, func(w *tabwriter.Writer) {
+			writeRolloutRows(w, status.Rollout)
+			row(w)
+			row(w, "NAME", "GROUP", "VERSION", "STATE", "LAST SEEN", "MESSAGE")
+			for _, target := range status.Targets {
+				state, lastSeen := "ok", ""
+				if target.IsError {
+					state = "error"
+				}
+				if !target.LastSeen.IsZero() {
+					lastSeen = target.LastSeen.Format(time.RFC3339)
+				}
+				row(w, target.Name, target.Group, target.Version, state, lastSeen, target.Message)
+			}
+		})
+	},
+}
+
+var progressCommand = &cli.Command{
+	Name:  "progress",
+	Usage: "shows rollout progress of entity and reason if rollout is stalled",
+	Flags: serverFlags(),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		progress, err := orchestrator.RolloutProgress(c.Context, c.String("namespace"), c.String("entity"))
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(c.App.Writer, c.String("output"), progress, func(w *tabwriter.Writer) {
+			lastProgress := ""
+			if !progress.ProgressTimestamp.IsZero() {
+				lastProgress = progress.ProgressTimestamp.Format(time.RFC3339)
+			}
+			row(w, "VERSION", "IN PROGRESS", "PAUSED", "UPDATED", "PENDING", "FAILED", "TOTAL", "PERCENT", "LAST PROGRESS")
+			row(w, progress.Version, fmt.Sprint(progress.InProgress), fmt.Sprint(progress.Paused),
+				fmt.Sprint(progress.UpdatedTargets), fmt.Sprint(progress.PendingTargets), fmt.Sprint(progress.FailedTargets),
+				fmt.Sprint(progress.TotalTargets), fmt.Sprint(progress.Percent), lastProgress)
+			if progress.Stall != nil {
+				row(w)
+				row(w, "STALLED SINCE", "REASON", "MESSAGE")
+				row(w, progress.Stall.Since.Format(time.RFC3339), string(progress.Stall.Reason), progress.Stall.Message)
+			}
+		})
+	},
+}
+
+// changeFlags describe version changes in audit records and notifications
+var changeFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "ticket",
+		Usage: "change ticket approving the change",
+	},
+	&cli.StringFlag{
+		Name:  "note",
+		Usage: "note describing the change",
+	},
+	&cli.BoolFlag{
+		Name:  "expedited",
+		Usage: "roll out with emergency profile of rollout options",
+	},
+}
+
+// forceFlag skips concurrency policy and downgrade protection of entities with semver version scheme
+var forceFlag = &cli.BoolFlag{
+	Name:  "force",
+	Usage: "sets version even if it is lower than last known good version or rejected by concurrency policy, marks rolling version bad",
+}
+
+// setVersion sets target version of entity and writes resulting rollout state
+func setVersion(c *cli.Context, orchestrator *client.Client, version *engine.EntityTargetVersion) error {
+	namespace, entity := c.String("namespace"), c.String("entity")
+	setTargetVersion := orchestrator.SetTargetVersion
+	if c.Bool("force") {
+		setTargetVersion = orchestrator.ForceTargetVersion
+	}
+	if err := setTargetVersion(c.Context, namespace, entity, version); err != nil {
+		return err
+	}
+	rollout, err := orchestrator.RolloutInfo(c.Context, namespace, entity)
+	if err != nil {
+		return err
+	}
+	return writeRollout(c, rollout)
+}
+
+func changeInfo(c *cli.Context) engine.ChangeInfo {
+	return engine.ChangeInfo{Ticket: c.String("ticket"), Note: c.String("note"), Expedited: c.Bool("expedited")}
+}
+
+var setVersionCommand = &cli.Command{
+	Name:      "set-version",
+	Usage:     "sets version rolled out to targets of entity",
+	ArgsUsage: "VERSION",
+	Flags:     serverFlags(append([]cli.Flag{forceFlag}, changeFlags...)...),
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return errors.New("set-version expects VERSION argument")
+		}
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		return setVersion(c, orchestrator, &engine.EntityTargetVersion{Version: c.Args().First(), ChangeInfo: changeInfo(c)})
+	},
+}
+
+var rollbackCommand = &cli.Command{
+	Name:  "rollback",
+	Usage: "rolls entity back to last known good version, or --version",
+	Flags: serverFlags(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "version",
+			Usage: "version to roll back to instead of last known good version",
+		},
+		forceFlag,
+	}, changeFlags...)...),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		version := c.String("version")
+		if version == "" {
+			rollout, err := orchestrator.RolloutInfo(c.Context, c.String("namespace"), c.String("entity"))
+			if err != nil {
+				return err
+			}
+			if rollout.LastKnownGoodVersion == "" {
+				return errors.New("entity has no last known good version, use --version")
+			}
+			version = rollout.LastKnownGoodVersion
+		}
+		change := changeInfo(c)
+		if change.Note == "" {
+			change.Note = "rollback to " + version
+		}
+		return setVersion(c, orchestrator, &engine.EntityTargetVersion{Version: version, ChangeInfo: change})
+	},
+}
+
+var setOptionsCommand = &cli.Command{
+	Name:  "set-options",
+	Usage: "updates rollout options of entity, flags override --file which overrides current options",
+	Flags: serverFlags(
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "yaml or json rollout options, - reads stdin",
+		},
+		&cli.IntFlag{
+			Name:  "batch-percent",
+			Usage: "percent of targets rolled out in each batch",
+		},
+		&cli.IntFlag{
+			Name:  "success-percent",
+			Usage: "percent of targets that must succeed",
+		},
+		&cli.IntFlag{
+			Name:  "success-timeout",
+			Usage: "seconds targets must stay healthy",
+		},
+		&cli.IntFlag{
+			Name:  "duration-timeout",
+			Usage: "seconds batch may take before failing",
+		},
+		&cli.BoolFlag{
+			Name:  "validate",
+			Usage: "only checks options on server without applying them",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace, entity := c.String("namespace"), c.String("entity")
+
+		rollout, err := orchestrator.RolloutInfo(c.Context, namespace, entity)
+		if err != nil && !errors.Is(err, httpclient.ErrNotFound) {
+			return err
+		}
+		options := engine.DefaultRolloutOptions()
+		if rollout != nil && rollout.Options != nil {
+			options = rollout.Options
+		}
+
+		if file := c.String("file"); file != "" {
+			if err := readOptions(file, options); err != nil {
+				return err
+			}
+		}
+		if c.IsSet("batch-percent") {
+			options.BatchPercent = c.Int("batch-percent")
+		}
+		if c.IsSet("success-percent") {
+			options.SuccessPercent = c.Int("success-percent")
+		}
+		if c.IsSet("success-timeout") {
+			options.SuccessTimeoutSecs = c.Int("success-timeout")
+		}
+		if c.IsSet("duration-timeout") {
+			options.DurationTimeoutSecs = c.Int("duration-timeout")
+		}
+
+		if c.Bool("validate") {
+			if err := orchestrator.ValidateRolloutOptions(c.Context, namespace, entity, options); err != nil {
+				return err
+			}
+		} else if err := orchestrator.SetRolloutOptions(c.Context, namespace, entity, options); err != nil {
+			return err
+		}
+		return writeOutput(c.App.Writer, c.String("output"), options, func(w *tabwriter.Writer) {
+			row(w, "BATCH PERCENT", "SUCCESS PERCENT", "SUCCESS TIMEOUT", "DURATION TIMEOUT")
+			row(w, fmt.Sprint(options.BatchPercent), fmt.Sprint(options.SuccessPercent),
+				fmt.Sprintf("%ds", options.SuccessTimeoutSecs), fmt.Sprintf("%ds", options.DurationTimeoutSecs))
+		})
+	},
+}
+
+// readOptions overlays yaml or json options of file onto options, keys are json names of fields
+func readOptions(file string, options *engine.RolloutOptions) error {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	// yaml is a superset of json, decoded document is re-encoded so json tags of options apply
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("invalid rollout options %s: %w", file, err)
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, options)
+}
+
+// pauseCommand creates pause or resume command
+func pauseCommand(name, usage string, paused bool) *cli.Command {
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: serverFlags(
+			&cli.StringFlag{
+				Name:  "group",
+				Usage: "group to " + name + ", whole entity if empty",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			orchestrator, err := newClient(c)
+			if err != nil {
+				return err
+			}
+			namespace, entity, group := c.String("namespace"), c.String("entity"), c.String("group")
+
+			var rollout *engine.RolloutState
+			if paused {
+				rollout, err = orchestrator.Pause(c.Context, namespace, entity, group)
+			} else {
+				rollout, err = orchestrator.Resume(c.Context, namespace, entity, group)
+			}
+			if err != nil {
+				return err
+			}
+			return writeRollout(c, rollout)
+		},
+	}
+}
+
+var (
+	pauseRolloutCommand  = pauseCommand("pause", "holds rollout of entity or group", true)
+	resumeRolloutCommand = pauseCommand("resume", "continues paused rollout of entity or group", false)
+)
+
+// versionCount is number of targets on version and how many of them are in error
+type versionCount struct {
+	Version string `json:"version"`
+	Targets int    `json:"targets"`
+	Errors  int    `json:"errors"`
+}
+
+// rolloutSummary is written by watch whenever rollout or targets change
+type rolloutSummary struct {
+	Time     time.Time                 `json:"time"`
+	Rollout  engine.RolloutVersionInfo `json:"rollout"`
+	Paused   bool                      `json:"paused,omitempty"`
+	Versions []versionCount            `json:"versions"`
+}
+
+func countVersions(targets []*engine.ClientState) []versionCount {
+	counts := make(map[string]*versionCount)
+	for _, target := range targets {
+		count, ok := counts[target.Version]
+		if !ok {
+			count = &versionCount{Version: target.Version}
+			counts[target.Version] = count
+		}
+		count.Targets++
+		if target.IsError {
+			count.Errors++
+		}
+	}
+	versions := make([]versionCount, 0, len(counts))
+	for _, count := range counts {
+		versions = append(versions, *count)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions
+}
+
+var watchCommand = &cli.Command{
+	Name: "watch",
+	Usage: "follows rollout of entity until interrupted, live view of group progress and failed targets on terminals, " +
+		"targets on each version whenever rollout progresses otherwise",
+	Flags: serverFlags(
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "interval between polls, unchanged targets are not transferred",
+			Value: 5 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name:  "plain",
+			Usage: "print changes instead of live view on terminals",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace, entity := c.String("namespace"), c.String("entity")
+
+		ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if c.String("output") == outputTable && !c.Bool("plain") && c.App.Writer == os.Stdout && isTerminal(os.Stdout) {
+			return ignoreCanceled(ctx, runTUI(ctx, c.App.Writer, orchestrator, namespace, entity, c.Duration("interval")))
+		}
+
+		ticker := time.NewTicker(c.Duration("interval"))
+		defer ticker.Stop()
+
+		var etag string
+		var targets []*engine.ClientState
+		var last *rolloutSummary
+		for {
+			rollout, err := orchestrator.RolloutInfo(ctx, namespace, entity)
+			if err != nil {
+				return ignoreCanceled(ctx, err)
+			}
+			changed, nextETag, err := orchestrator.StatusIfChanged(ctx, namespace, entity, etag)
+			switch {
+			case err == nil:
+				targets, etag = changed, nextETag
+			case !errors.Is(err, httpclient.ErrNotModified):
+				return ignoreCanceled(ctx, err)
+			}
+
+			summary := &rolloutSummary{
+				Time:     time.Now(),
+				Rollout:  rollout.RolloutVersionInfo,
+				Paused:   rollout.Paused,
+				Versions: countVersions(targets),
+			}
+			if last == nil || summaryChanged(last, summary) {
+				if err := writeSummary(c, summary); err != nil {
+					return err
+				}
+				last = summary
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+// ignoreCanceled hides errors of requests interrupted by signal
+func ignoreCanceled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func summaryChanged(last, summary *rolloutSummary) bool {
+	if last.Rollout != summary.Rollout || last.Paused != summary.Paused || len(last.Versions) != len(summary.Versions) {
+		return true
+	}
+	for i := range last.Versions {
+		if last.Versions[i] != summary.Versions[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func writeSummary(c *cli.Context, summary *rolloutSummary) error {
+	if c.String("output") == outputJSON {
+		// one line per change so output can be piped
+		return json.NewEncoder(c.App.Writer).Encode(summary)
+	}
+	return writeOutput(c.App.Writer, c.String("output"), summary, func(w *tabwriter.Writer) {
+		row(w, fmt.Sprintf("%s target %s rolling %s last known good %s paused %t", summary.Time.Format(time.RFC3339),
+			orNone(summary.Rollout.TargetVersion), orNone(summary.Rollout.RollingVersion), orNone(summary.Rollout.LastKnownGoodVersion), summary.Paused))
+		row(w, "VERSION", "TARGETS", "ERRORS")
+		for _, count := range summary.Versions {
+			row(w, count.Version, fmt.Sprint(count.Targets), fmt.Sprint(count.Errors))
+		}
+		row(w)
+	})
+}
+
+func orNone(version string) string {
+	if version == "" {
+		return "-"
+	}
+	return version
+}
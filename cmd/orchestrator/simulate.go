@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/urfave/cli/v2"
+)
+
+var simulateCommand = &cli.Command{
+	Name:  "simulate",
+	Usage: "prints batches a rollout would select with proposed options, nothing is changed",
+	Flags: serverFlags(
+		&cli.StringFlag{
+			Name:  "version",
+			Usage: "version rolled out, defaults to target version of entity",
+		},
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "yaml or json proposed rollout options overriding current options, - reads stdin",
+		},
+		&cli.IntFlag{
+			Name:  "batch-percent",
+			Usage: "proposed percent of targets rolled out in each batch",
+		},
+		&cli.StringFlag{
+			Name:  "selector",
+			Usage: "proposed label selector of targets, e.g. region=us-east",
+		},
+		&cli.BoolFlag{
+			Name:  "expedited",
+			Usage: "simulate rollout with emergency profile",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace, entity := c.String("namespace"), c.String("entity")
+
+		request := &engine.RolloutSimulationRequest{Version: c.String("version"), Expedited: c.Bool("expedited")}
+		if c.IsSet("file") || c.IsSet("batch-percent") || c.IsSet("selector") {
+			rollout, err := orchestrator.RolloutInfo(c.Context, namespace, entity)
+			if err != nil && !errors.Is(err, httpclient.ErrNotFound) {
+				return err
+			}
+			request.Options = engine.DefaultRolloutOptions()
+			if rollout != nil && rollout.Options != nil {
+				request.Options = rollout.Options
+			}
+			if file := c.String("file"); file != "" {
+				if err := readOptions(file, request.Options); err != nil {
+					return err
+				}
+			}
+			if c.IsSet("batch-percent") {
+				request.Options.BatchPercent = c.Int("batch-percent")
+			}
+			if c.IsSet("selector") {
+				request.Options.TargetSelector = c.String("selector")
+			}
+		}
+
+		simulation, err := orchestrator.SimulateRollout(c.Context, namespace, entity, request)
+		if err != nil {
+			return err
+		}
+		return writeOutput(c.App.Writer, c.String("output"), simulation, func(w *tabwriter.Writer) {
+			row(w, "BATCH", "RING", "TARGETS", "PERCENT", "NAMES")
+			for _, batch := range simulation.Batches {
+				names := make([]string, 0, len(batch.Targets))
+				for _, target := range batch.Targets {
+					names = append(names, target.Group+"/"+target.Name)
+				}
+				row(w, fmt.Sprint(batch.Batch), batch.Ring, fmt.Sprint(len(batch.Targets)), fmt.Sprintf("%d%%", batch.Percent), strings.Join(names, ","))
+			}
+			row(w, fmt.Sprintf("%d of %d targets in rollout of %s, %d already assigned", simulation.RolloutTargets,
+				simulation.TotalTargets, simulation.Version, simulation.AssignedTargets))
+			if simulation.ExternalSelection {
+				row(w, "target controller selects targets externally, batches list targets in store order")
+			}
+		})
+	},
+}
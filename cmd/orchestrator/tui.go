@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+)
+
+const (
+	// tuiRefreshInterval limits redraws of watch view, updates in between are drawn together
+	tuiRefreshInterval = 250 * time.Millisecond
+	// tuiFailedTargets is number of failed targets listed, the rest are counted
+	tuiFailedTargets = 10
+	tuiProgressWidth = 30
+
+	ansiEnterScreen = "\033[?1049h\033[?25l"
+	ansiLeaveScreen = "\033[?25h\033[?1049l"
+	ansiClear       = "\033[H\033[2J"
+	ansiRed         = "\033[31m"
+	ansiGreen       = "\033[32m"
+	ansiBold        = "\033[1m"
+	ansiReset       = "\033[0m"
+)
+
+// isTerminal is true if f is a character device, watch draws live view only on terminals
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// watchView is state of entity drawn by live watch, targets are updated from status stream
+// and rollout is polled since it is not streamed
+type watchView struct {
+	namespace string
+	entity    string
+	interval  time.Duration
+
+	lock       sync.Mutex
+	rollout    *engine.RolloutState
+	targets    map[string]*engine.ClientState
+	streamErr  error
+	rolloutAt  time.Time
+	dirty      bool
+	renderedAt time.Time
+}
+
+func newWatchView(namespace, entity string, interval time.Duration) *watchView {
+	return &watchView{namespace: namespace, entity: entity, interval: interval, targets: make(map[string]*engine.ClientState), dirty: true}
+}
+
+func (v *watchView) apply(event *client.StatusEvent) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if event.Snapshot != nil {
+		v.targets = make(map[string]*engine.ClientState, len(event.Snapshot))
+		for _, target := range event.Snapshot {
+			v.targets[target.Group+"/"+target.Name] = target
+		}
+	}
+	if event.Target != nil {
+		v.targets[event.Target.Group+"/"+event.Target.Name] = event.Target
+	}
+	v.streamErr = nil
+	v.dirty = true
+	return nil
+}
+
+func (v *watchView) setRollout(rollout *engine.RolloutState, now time.Time) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.rollout = rollout
+	v.rolloutAt = now
+	v.dirty = true
+}
+
+func (v *watchView) setStreamErr(err error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.streamErr = err
+	v.dirty = true
+}
+
+// groupProgress is number of targets of group on version being rolled out
+type groupProgress struct {
+	group  string
+	total  int
+	done   int
+	errors int
+}
+
+// render draws view if it changed since last render, unchanged view is redrawn every second for its clock
+func (v *watchView) render(w io.Writer, now time.Time) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if !v.dirty && now.Sub(v.renderedAt) < time.Second {
+		return nil
+	}
+	v.dirty = false
+	v.renderedAt = now
+
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	fmt.Fprintf(&b, "%s%s/%s%s  %s\n\n", ansiBold, v.namespace, v.entity, ansiReset, now.Format(time.RFC3339))
+
+	rollout := v.rollout
+	if rollout == nil {
+		rollout = &engine.RolloutState{}
+	}
+	version := rollout.RollingVersion
+	if version == "" {
+		version = rollout.TargetVersion
+	}
+	fmt.Fprintf(&b, "target %s  rolling %s  last known good %s  last known bad %s\n",
+		orNone(rollout.TargetVersion), orNone(rollout.RollingVersion), orNone(rollout.LastKnownGoodVersion), orNone(rollout.LastKnownBadVersion))
+	phase := fmt.Sprintf("batch stage %d", rollout.BatchStage)
+	if !rollout.BatchTimestamp.IsZero() {
+		phase += fmt.Sprintf(" started %s ago", now.Sub(rollout.BatchTimestamp).Truncate(time.Second))
+	}
+	if rollout.AnalysisOutcome != "" {
+		phase += ", analysis " + rollout.AnalysisOutcome
+	}
+	switch {
+	case rollout.Paused:
+		phase += ", " + ansiRed + "paused" + ansiReset
+	case len(rollout.PausedGroups) > 0:
+		phase += ", " + ansiRed + "paused groups " + strings.Join(rollout.PausedGroups, " ") + ansiReset
+	}
+	fmt.Fprintf(&b, "%s\n\n", phase)
+
+	groups := make(map[string]*groupProgress)
+	var failed []*engine.ClientState
+	for _, target := range v.targets {
+		progress, ok := groups[target.Group]
+		if !ok {
+			progress = &groupProgress{group: target.Group}
+			groups[target.Group] = progress
+		}
+		progress.total++
+		switch {
+		case target.IsError:
+			progress.errors++
+			failed = append(failed, target)
+		case version != "" && target.Version == version:
+			progress.done++
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(&b, "%-20s %-*s %9s %7s\n", "GROUP", tuiProgressWidth+2, "PROGRESS "+orNone(version), "TARGETS", "ERRORS")
+	for _, name := range names {
+		progress := groups[name]
+		filled := progress.done * tuiProgressWidth / progress.total
+		bar := ansiGreen + strings.Repeat("#", filled) + ansiReset + strings.Repeat(".", tuiProgressWidth-filled)
+		errorsColumn := fmt.Sprintf("%7d", progress.errors)
+		if progress.errors > 0 {
+			errorsColumn = ansiRed + errorsColumn + ansiReset
+		}
+		fmt.Fprintf(&b, "%-20s [%s] %4d/%-4d %s\n", orNone(name), bar, progress.done, progress.total, errorsColumn)
+	}
+
+	sort.Slice(failed, func(i, j int) bool {
+		if failed[i].Group != failed[j].Group {
+			return failed[i].Group < failed[j].Group
+		}
+		return failed[i].Name < failed[j].Name
+	})
+	fmt.Fprintf(&b, "\n%sfailed targets %d%s\n", ansiBold, len(failed), ansiReset)
+	for i, target := range failed {
+		if i == tuiFailedTargets {
+			fmt.Fprintf(&b, "  ... %d more\n", len(failed)-tuiFailedTargets)
+			break
+		}
+		fmt.Fprintf(&b, "  %s/%s %s: %s\n", orNone(target.Group), target.Name, orNone(target.Version), target.Message)
+	}
+
+	if v.streamErr != nil {
+		fmt.Fprintf(&b, "\n%sstream: %s, reconnecting%s\n", ansiRed, v.streamErr, ansiReset)
+	}
+	if now.Sub(v.rolloutAt) > 3*v.interval {
+		fmt.Fprintf(&b, "\n%srollout last updated %s ago%s\n", ansiRed, now.Sub(v.rolloutAt).Truncate(time.Second), ansiReset)
+	}
+	b.WriteString("\nctrl-c to quit\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runTUI draws live view of entity until ctx is done, targets are updated from status stream,
+// which is reconnected after interval if it fails, and rollout is polled every interval
+func runTUI(ctx context.Context, w io.Writer, orchestrator *client.Client, namespace, entity string, interval time.Duration) error {
+	view := newWatchView(namespace, entity, interval)
+
+	// fail before taking over terminal if entity is unknown or server unreachable
+	rollout, err := orchestrator.RolloutInfo(ctx, namespace, entity)
+	if err != nil {
+		return err
+	}
+	view.setRollout(rollout, time.Now())
+
+	if _, err := io.WriteString(w, ansiEnterScreen); err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.WriteString(w, ansiLeaveScreen)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			err := orchestrator.WatchStatus(ctx, namespace, entity, view.apply)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = errors.New("closed by server")
+			}
+			view.setStreamErr(err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// stale rollout is flagged by view once polls keep failing
+			if rollout, err := orchestrator.RolloutInfo(ctx, namespace, entity); err == nil {
+				view.setRollout(rollout, time.Now())
+			}
+		}
+	}()
+
+	refresh := time.NewTicker(tuiRefreshInterval)
+	defer refresh.Stop()
+	for {
+		if err := view.render(w, time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-refresh.C:
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/urfave/cli/v2"
+)
+
+var noteFlag = &cli.StringFlag{
+	Name:  "note",
+	Usage: "note describing why version is overridden",
+}
+
+var markBadCommand = &cli.Command{
+	Name:      "mark-bad",
+	Usage:     "marks version of entity last known bad, or clears last known bad version with --clear",
+	ArgsUsage: "[VERSION]",
+	Flags: serverFlags(noteFlag, &cli.BoolFlag{
+		Name:  "clear",
+		Usage: "clears last known bad version, e.g. one wrongly marked bad by a flaky monitor",
+	}),
+	Action: func(c *cli.Context) error {
+		if c.Bool("clear") == (c.NArg() == 1) {
+			return errors.New("mark-bad expects VERSION argument or --clear")
+		}
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		namespace, entity := c.String("namespace"), c.String("entity")
+
+		var rollout *engine.RolloutState
+		if c.Bool("clear") {
+			rollout, err = orchestrator.ClearLastKnownBad(c.Context, namespace, entity)
+		} else {
+			rollout, err = orchestrator.SetLastKnownBad(c.Context, namespace, entity, &engine.VersionOverride{Version: c.Args().First(), Note: c.String("note")})
+		}
+		if err != nil {
+			return err
+		}
+		return writeRollout(c, rollout)
+	},
+}
+
+var setLastKnownGoodCommand = &cli.Command{
+	Name:      "set-lkg",
+	Usage:     "pins last known good version of entity to a version of its history, rollback targets it",
+	ArgsUsage: "VERSION",
+	Flags:     serverFlags(noteFlag),
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return errors.New("set-lkg expects VERSION argument")
+		}
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		rollout, err := orchestrator.SetLastKnownGood(c.Context, c.String("namespace"), c.String("entity"),
+			&engine.VersionOverride{Version: c.Args().First(), Note: c.String("note")})
+		if err != nil {
+			return err
+		}
+		return writeRollout(c, rollout)
+	},
+}
+
+// versionHistory is output of version-history command
+type versionHistory struct {
+	LastKnownGood []engine.VersionMark `json:"lastknowngood"`
+	LastKnownBad  []engine.VersionMark `json:"lastknownbad"`
+}
+
+var versionHistoryCommand = &cli.Command{
+	Name:  "version-history",
+	Usage: "lists versions marked last known good or bad of entity",
+	Flags: serverFlags(),
+	Action: func(c *cli.Context) error {
+		orchestrator, err := newClient(c)
+		if err != nil {
+			return err
+		}
+		rollout, err := orchestrator.RolloutInfo(c.Context, c.String("namespace"), c.String("entity"))
+		if err != nil {
+			return err
+		}
+
+		history := &versionHistory{LastKnownGood: rollout.LastKnownGoodHistory, LastKnownBad: rollout.LastKnownBadHistory}
+		return writeOutput(c.App.Writer, c.String("output"), history, func(w *tabwriter.Writer) {
+			row(w, "MARK", "VERSION", "TIMESTAMP", "REASON", "NOTE")
+			for _, mark := range history.LastKnownGood {
+				row(w, "good", mark.Version, mark.Timestamp.Format(time.RFC3339), mark.Reason, mark.Note)
+			}
+			for _, mark := range history.LastKnownBad {
+				row(w, "bad", mark.Version, mark.Timestamp.Format(time.RFC3339), mark.Reason, mark.Note)
+			}
+		})
+	},
+}
@@ -0,0 +1,492 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nixmade/orchestrator/store"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// BadgerBackend stores state in local badger db directory
+	BadgerBackend = store.BadgerDriver
+	// PostgresBackend stores state in postgres database
+	PostgresBackend = store.PostgresDriver
+)
+
+var (
+	ErrInvalidPort         = errors.New("port must be between 1 and 65535")
+	ErrInvalidStoreBackend = errors.New("store backend is not a registered store driver")
+	ErrDatabaseURLRequired = errors.New("database url is required for store backend")
+	ErrInvalidTLS          = errors.New("tls requires both cert file and key file")
+	ErrUnknownFormat       = errors.New("config file must be .yaml, .yml, .toml or .json")
+	ErrInvalidKeyProvider  = errors.New("key provider must be vault or awskms with a key")
+	ErrInvalidRateLimit    = errors.New("rate limits and bursts must not be negative")
+	ErrInvalidLogFormat    = errors.New("log format must be console or json")
+	ErrInvalidWasmLimits   = errors.New("wasm limits must not be negative")
+)
+
+const (
+	// VaultKeyProvider wraps badger data key with Vault transit key
+	VaultKeyProvider = "vault"
+	// AWSKMSKeyProvider wraps badger data key with AWS KMS key
+	AWSKMSKeyProvider = "awskms"
+)
+
+// TLSConfig serves HTTPS when both cert and key files are set
+type TLSConfig struct {
+	CertFile string `json:"certFile,omitempty" yaml:"certFile,omitempty" toml:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty" yaml:"keyFile,omitempty" toml:"keyFile,omitempty"`
+}
+
+// Enabled returns true if server should serve HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// RateLimitConfig limits requests per second of each caller, zero rate does not limit the class of routes
+type RateLimitConfig struct {
+	// ReadsPerSecond limits GET requests such as status reads
+	ReadsPerSecond float64 `json:"readsPerSecond,omitempty" yaml:"readsPerSecond,omitempty" toml:"readsPerSecond,omitempty"`
+	// ReadBurst is number of reads allowed at once, defaults to ReadsPerSecond
+	ReadBurst int `json:"readBurst,omitempty" yaml:"readBurst,omitempty" toml:"readBurst,omitempty"`
+	// WritesPerSecond limits mutations and target reports
+	WritesPerSecond float64 `json:"writesPerSecond,omitempty" yaml:"writesPerSecond,omitempty" toml:"writesPerSecond,omitempty"`
+	// WriteBurst is number of mutations allowed at once, defaults to WritesPerSecond
+	WriteBurst int `json:"writeBurst,omitempty" yaml:"writeBurst,omitempty" toml:"writeBurst,omitempty"`
+}
+
+// Enabled returns true if reads or writes are limited
+func (r RateLimitConfig) Enabled() bool {
+	return r.ReadsPerSecond > 0 || r.WritesPerSecond > 0
+}
+
+// ServerConfig controls HTTP listener
+type ServerConfig struct {
+	Address   string          `json:"address,omitempty" yaml:"address,omitempty" toml:"address,omitempty"`
+	Port      int             `json:"port,omitempty" yaml:"port,omitempty" toml:"port,omitempty"`
+	TLS       TLSConfig       `json:"tls,omitempty" yaml:"tls,omitempty" toml:"tls,omitempty"`
+	RateLimit RateLimitConfig `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty" toml:"rateLimit,omitempty"`
+}
+
+// StoreConfig controls store backend, backend is any driver registered with store.Register
+type StoreConfig struct {
+	Backend       string `json:"backend,omitempty" yaml:"backend,omitempty" toml:"backend,omitempty"`
+	Directory     string `json:"directory,omitempty" yaml:"directory,omitempty" toml:"directory,omitempty"`
+	DatabaseURL   string `json:"databaseUrl,omitempty" yaml:"databaseUrl,omitempty" toml:"databaseUrl,omitempty"`
+	Schema        string `json:"schema,omitempty" yaml:"schema,omitempty" toml:"schema,omitempty"`
+	Table         string `json:"table,omitempty" yaml:"table,omitempty" toml:"table,omitempty"`
+	EncryptionKey string `json:"encryptionKey,omitempty" yaml:"encryptionKey,omitempty" toml:"encryptionKey,omitempty"`
+	// Params are driver specific options
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty" toml:"params,omitempty"`
+	// KeyProvider fetches badger data key from Vault or KMS instead of EncryptionKey
+	KeyProvider KeyProviderConfig `json:"keyProvider,omitempty" yaml:"keyProvider,omitempty" toml:"keyProvider,omitempty"`
+	// DisableCache reads rollout and target state from store on every orchestration, for replicas sharing
+	// a store without watch support
+	DisableCache bool `json:"disableCache,omitempty" yaml:"disableCache,omitempty" toml:"disableCache,omitempty"`
+}
+
+// KeyProviderConfig wraps store data key with Vault transit or AWS KMS key, Vault token is read from
+// VAULT_TOKEN and AWS credentials are resolved by the default AWS credential chain
+type KeyProviderConfig struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty" toml:"type,omitempty"`
+	// Address is Vault url or KMS endpoint override
+	Address string `json:"address,omitempty" yaml:"address,omitempty" toml:"address,omitempty"`
+	// Mount is Vault transit mount, defaults to transit
+	Mount string `json:"mount,omitempty" yaml:"mount,omitempty" toml:"mount,omitempty"`
+	// Key is Vault transit key name or KMS key id, arn or alias
+	Key    string `json:"key,omitempty" yaml:"key,omitempty" toml:"key,omitempty"`
+	Region string `json:"region,omitempty" yaml:"region,omitempty" toml:"region,omitempty"`
+}
+
+// Enabled returns true if data key is fetched from key provider
+func (k KeyProviderConfig) Enabled() bool {
+	return k.Type != ""
+}
+
+// Provider creates configured key provider, data key is unwrapped once when store is opened
+func (k KeyProviderConfig) Provider() (store.KeyProvider, error) {
+	switch k.Type {
+	case VaultKeyProvider:
+		return store.NewVaultKeyProvider(k.Address, os.Getenv("VAULT_TOKEN"), k.Mount, k.Key), nil
+	case AWSKMSKeyProvider:
+		return store.NewAWSKMSKeyProvider(context.Background(), k.Region, k.Key, k.Address)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeyProvider, k.Type)
+	}
+}
+
+const (
+	// LogFormatConsole writes human readable log lines, default
+	LogFormatConsole = "console"
+	// LogFormatJSON writes a json object per log line for log collectors
+	LogFormatJSON = "json"
+)
+
+// LogConfig controls logger
+type LogConfig struct {
+	Level string `json:"level,omitempty" yaml:"level,omitempty" toml:"level,omitempty"`
+	// Format of log lines, console or json
+	Format string `json:"format,omitempty" yaml:"format,omitempty" toml:"format,omitempty"`
+}
+
+// NATSConfig enables NATS agent transport when URL is set
+type NATSConfig struct {
+	URL           string `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	SubjectPrefix string `json:"subjectPrefix,omitempty" yaml:"subjectPrefix,omitempty" toml:"subjectPrefix,omitempty"`
+}
+
+// SlackConfig holds Slack app settings for interactive approvals
+type SlackConfig struct {
+	// SigningSecret verifies interactivity callbacks, callbacks are rejected if empty
+	SigningSecret string `json:"signingSecret,omitempty" yaml:"signingSecret,omitempty" toml:"signingSecret,omitempty"`
+}
+
+// WasmConfig caps limits of wasm controller plugins, limits set on controllers are clamped to maximums,
+// zero maximums use built in maximums
+type WasmConfig struct {
+	// MaxFuel is maximum number of function calls each call may make, defaults to 1B
+	MaxFuel uint64 `json:"maxFuel,omitempty" yaml:"maxFuel,omitempty" toml:"maxFuel,omitempty"`
+	// MaxMemoryPages is maximum of 64KiB pages plugin memory may grow to, defaults to 1024
+	MaxMemoryPages uint32 `json:"maxMemoryPages,omitempty" yaml:"maxMemoryPages,omitempty" toml:"maxMemoryPages,omitempty"`
+	// MaxTimeoutSecs is maximum timeout of each call, defaults to 30
+	MaxTimeoutSecs int `json:"maxTimeoutSecs,omitempty" yaml:"maxTimeoutSecs,omitempty" toml:"maxTimeoutSecs,omitempty"`
+}
+
+// EngineConfig controls orchestration engine
+type EngineConfig struct {
+	// Workers run async orchestrations concurrently, defaults to 8
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty" toml:"workers,omitempty"`
+	// DistributedLocks locks entities in store while orchestrating, for replicas sharing a store
+	DistributedLocks bool `json:"distributedLocks,omitempty" yaml:"distributedLocks,omitempty" toml:"distributedLocks,omitempty"`
+	// LeaderElection elects a leader among replicas sharing a store, only leader runs background jobs
+	LeaderElection bool `json:"leaderElection,omitempty" yaml:"leaderElection,omitempty" toml:"leaderElection,omitempty"`
+	// PluginDirectory holds wasm controller plugins loaded from disk, plugins can only be loaded by path if set
+	PluginDirectory string `json:"pluginDirectory,omitempty" yaml:"pluginDirectory,omitempty" toml:"pluginDirectory,omitempty"`
+	// Wasm caps limits of wasm controller plugins
+	Wasm WasmConfig `json:"wasm,omitempty" yaml:"wasm,omitempty" toml:"wasm,omitempty"`
+}
+
+// Config holds server configuration loaded from file and environment
+type Config struct {
+	Server ServerConfig `json:"server,omitempty" yaml:"server,omitempty" toml:"server,omitempty"`
+	Store  StoreConfig  `json:"store,omitempty" yaml:"store,omitempty" toml:"store,omitempty"`
+	Log    LogConfig    `json:"log,omitempty" yaml:"log,omitempty" toml:"log,omitempty"`
+	NATS   NATSConfig   `json:"nats,omitempty" yaml:"nats,omitempty" toml:"nats,omitempty"`
+	Engine EngineConfig `json:"engine,omitempty" yaml:"engine,omitempty" toml:"engine,omitempty"`
+	Slack  SlackConfig  `json:"slack,omitempty" yaml:"slack,omitempty" toml:"slack,omitempty"`
+}
+
+// Default returns configuration matching previous hard coded behavior
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address: "127.0.0.1",
+			Port:    8080,
+		},
+		Store: StoreConfig{
+			Backend: BadgerBackend,
+			Schema:  store.PUBLIC_SCHEMA,
+			Table:   store.TABLE_NAME,
+		},
+	}
+}
+
+// Load reads config file if path is not empty, then applies environment overrides
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// FromEnv returns default configuration with environment overrides
+func FromEnv() (*Config, error) {
+	return Load("")
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return ErrUnknownFormat
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides values with environment variables, unset variables are ignored
+func (c *Config) applyEnv() error {
+	setString(&c.Server.Address, "APP_LISTEN_ADDRESS")
+	if port := os.Getenv("APP_PORT"); port != "" {
+		value, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid APP_PORT %q: %w", port, err)
+		}
+		c.Server.Port = value
+	}
+	setString(&c.Server.TLS.CertFile, "APP_TLS_CERT_FILE")
+	setString(&c.Server.TLS.KeyFile, "APP_TLS_KEY_FILE")
+	for key, value := range map[string]*float64{
+		"APP_RATE_LIMIT_READS":  &c.Server.RateLimit.ReadsPerSecond,
+		"APP_RATE_LIMIT_WRITES": &c.Server.RateLimit.WritesPerSecond,
+	} {
+		if env := os.Getenv(key); env != "" {
+			rate, err := strconv.ParseFloat(env, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", key, env, err)
+			}
+			*value = rate
+		}
+	}
+	for key, value := range map[string]*int{
+		"APP_RATE_LIMIT_READ_BURST":  &c.Server.RateLimit.ReadBurst,
+		"APP_RATE_LIMIT_WRITE_BURST": &c.Server.RateLimit.WriteBurst,
+	} {
+		if env := os.Getenv(key); env != "" {
+			burst, err := strconv.Atoi(env)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", key, env, err)
+			}
+			*value = burst
+		}
+	}
+
+	setString(&c.Store.Backend, "APP_STORE_BACKEND")
+	setString(&c.Store.Directory, "APP_CONFIG_DIR")
+	setString(&c.Store.DatabaseURL, "APP_DATABASE_URL")
+	setString(&c.Store.Schema, "APP_DATABASE_SCHEMA")
+	setString(&c.Store.Table, "APP_DATABASE_TABLE")
+	setString(&c.Store.EncryptionKey, "MASTER_KEY")
+	setString(&c.Store.KeyProvider.Type, "APP_KEY_PROVIDER")
+	setString(&c.Store.KeyProvider.Address, "APP_KEY_PROVIDER_ADDRESS")
+	setString(&c.Store.KeyProvider.Key, "APP_KEY_PROVIDER_KEY")
+	setString(&c.Store.KeyProvider.Region, "AWS_REGION")
+	if disable := os.Getenv("APP_STORE_DISABLE_CACHE"); disable != "" {
+		value, err := strconv.ParseBool(disable)
+		if err != nil {
+			return fmt.Errorf("invalid APP_STORE_DISABLE_CACHE %q: %w", disable, err)
+		}
+		c.Store.DisableCache = value
+	}
+
+	setString(&c.Log.Level, "APP_LOG_LEVEL")
+	setString(&c.Log.Format, "APP_LOG_FORMAT")
+
+	if workers := os.Getenv("APP_WORKERS"); workers != "" {
+		value, err := strconv.Atoi(workers)
+		if err != nil {
+			return fmt.Errorf("invalid APP_WORKERS %q: %w", workers, err)
+		}
+		c.Engine.Workers = value
+	}
+	if locks := os.Getenv("APP_DISTRIBUTED_LOCKS"); locks != "" {
+		value, err := strconv.ParseBool(locks)
+		if err != nil {
+			return fmt.Errorf("invalid APP_DISTRIBUTED_LOCKS %q: %w", locks, err)
+		}
+		c.Engine.DistributedLocks = value
+	}
+	if election := os.Getenv("APP_LEADER_ELECTION"); election != "" {
+		value, err := strconv.ParseBool(election)
+		if err != nil {
+			return fmt.Errorf("invalid APP_LEADER_ELECTION %q: %w", election, err)
+		}
+		c.Engine.LeaderElection = value
+	}
+
+	setString(&c.Engine.PluginDirectory, "APP_PLUGIN_DIR")
+	if fuel := os.Getenv("APP_WASM_MAX_FUEL"); fuel != "" {
+		value, err := strconv.ParseUint(fuel, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid APP_WASM_MAX_FUEL %q: %w", fuel, err)
+		}
+		c.Engine.Wasm.MaxFuel = value
+	}
+	if pages := os.Getenv("APP_WASM_MAX_MEMORY_PAGES"); pages != "" {
+		value, err := strconv.ParseUint(pages, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid APP_WASM_MAX_MEMORY_PAGES %q: %w", pages, err)
+		}
+		c.Engine.Wasm.MaxMemoryPages = uint32(value)
+	}
+	if timeout := os.Getenv("APP_WASM_MAX_TIMEOUT_SECS"); timeout != "" {
+		value, err := strconv.Atoi(timeout)
+		if err != nil {
+			return fmt.Errorf("invalid APP_WASM_MAX_TIMEOUT_SECS %q: %w", timeout, err)
+		}
+		c.Engine.Wasm.MaxTimeoutSecs = value
+	}
+
+	setString(&c.Slack.SigningSecret, "APP_SLACK_SIGNING_SECRET")
+
+	setString(&c.NATS.URL, "APP_NATS_URL")
+	setString(&c.NATS.SubjectPrefix, "APP_NATS_SUBJECT_PREFIX")
+
+	return nil
+}
+
+func setString(value *string, key string) {
+	if env := os.Getenv(key); env != "" {
+		*value = env
+	}
+}
+
+// Validate checks configuration is usable
+func (c *Config) Validate() error {
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		return ErrInvalidPort
+	}
+
+	if c.Server.TLS.CertFile != "" || c.Server.TLS.KeyFile != "" {
+		if !c.Server.TLS.Enabled() {
+			return ErrInvalidTLS
+		}
+	}
+
+	rateLimit := c.Server.RateLimit
+	if rateLimit.ReadsPerSecond < 0 || rateLimit.WritesPerSecond < 0 || rateLimit.ReadBurst < 0 || rateLimit.WriteBurst < 0 {
+		return ErrInvalidRateLimit
+	}
+
+	if c.Engine.Wasm.MaxTimeoutSecs < 0 {
+		return ErrInvalidWasmLimits
+	}
+
+	c.Log.Format = strings.ToLower(c.Log.Format)
+	switch c.Log.Format {
+	case "", LogFormatConsole, LogFormatJSON:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidLogFormat, c.Log.Format)
+	}
+
+	c.Store.Backend = strings.ToLower(c.Store.Backend)
+	if !slices.Contains(store.Drivers(), c.Store.Backend) {
+		return fmt.Errorf("%w: %s", ErrInvalidStoreBackend, c.Store.Backend)
+	}
+	// badger directory is optional, every other driver needs a database url
+	if c.Store.Backend != BadgerBackend && c.Store.DatabaseURL == "" {
+		return ErrDatabaseURLRequired
+	}
+
+	if c.Store.KeyProvider.Enabled() {
+		c.Store.KeyProvider.Type = strings.ToLower(c.Store.KeyProvider.Type)
+		switch c.Store.KeyProvider.Type {
+		case VaultKeyProvider:
+			if c.Store.KeyProvider.Address == "" {
+				return fmt.Errorf("%w: vault address is required", ErrInvalidKeyProvider)
+			}
+		case AWSKMSKeyProvider:
+			if c.Store.KeyProvider.Region == "" {
+				return fmt.Errorf("%w: aws region is required", ErrInvalidKeyProvider)
+			}
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidKeyProvider, c.Store.KeyProvider.Type)
+		}
+		if c.Store.KeyProvider.Key == "" {
+			return ErrInvalidKeyProvider
+		}
+	}
+
+	return nil
+}
+
+// DSN returns data source name passed to store driver
+func (c *StoreConfig) DSN() string {
+	if c.Backend == BadgerBackend {
+		return c.Directory
+	}
+	return c.DatabaseURL
+}
+
+// Options returns options passed to store driver
+func (c *StoreConfig) Options() store.Options {
+	return store.Options{
+		EncryptionKey: c.EncryptionKey,
+		Schema:        c.Schema,
+		Table:         c.Table,
+		Params:        c.Params,
+	}
+}
+
+// ListenAddress returns host:port HTTP server listens on
+func (c *Config) ListenAddress() string {
+	return net.JoinHostPort(c.Server.Address, strconv.Itoa(c.Server.Port))
+}
+
+// redactedValue replaces secrets in Redacted configuration
+const redactedValue = "REDACTED"
+
+// redactURL hides password of url, values that are not urls are hidden entirely
+func redactURL(value string) string {
+	if value == "" {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return redactedValue
+	}
+	return u.Redacted()
+}
+
+// Redacted returns copy of configuration safe to print, secrets are replaced and passwords of urls hidden.
+// Params with key mentioning a password, secret or token are replaced as well
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Store.DatabaseURL = redactURL(c.Store.DatabaseURL)
+	redacted.NATS.URL = redactURL(c.NATS.URL)
+	if c.Store.EncryptionKey != "" {
+		redacted.Store.EncryptionKey = redactedValue
+	}
+	if c.Slack.SigningSecret != "" {
+		redacted.Slack.SigningSecret = redactedValue
+	}
+	if c.Store.Params != nil {
+		redacted.Store.Params = make(map[string]string, len(c.Store.Params))
+		for key, value := range c.Store.Params {
+			lower := strings.ToLower(key)
+			if strings.Contains(lower, "password") || strings.Contains(lower, "secret") || strings.Contains(lower, "token") {
+				value = redactedValue
+			}
+			redacted.Store.Params[key] = value
+		}
+	}
+	return &redacted
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDefaultConfig(t *testing.T) {
+	cfg, err := Load("")
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1:8080", cfg.ListenAddress())
+	assert.Equal(t, BadgerBackend, cfg.Store.Backend)
+	assert.False(t, cfg.Server.TLS.Enabled())
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeConfig(t, "orchestrator.yaml", `
+server:
+  address: 0.0.0.0
+  port: 9090
+  tls:
+    certFile: /etc/orchestrator/tls.crt
+    keyFile: /etc/orchestrator/tls.key
+store:
+  backend: postgres
+  databaseUrl: postgres://localhost/orchestrator
+log:
+  level: debug
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "0.0.0.0:9090", cfg.ListenAddress())
+	assert.True(t, cfg.Server.TLS.Enabled())
+	assert.Equal(t, PostgresBackend, cfg.Store.Backend)
+	assert.Equal(t, "postgres://localhost/orchestrator", cfg.Store.DatabaseURL)
+	assert.Equal(t, Default().Store.Table, cfg.Store.Table)
+	assert.Equal(t, "debug", cfg.Log.Level)
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeConfig(t, "orchestrator.toml", `
+[server]
+port = 9091
+
+[store]
+backend = "badger"
+directory = "/var/lib/orchestrator"
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1:9091", cfg.ListenAddress())
+	assert.Equal(t, "/var/lib/orchestrator", cfg.Store.Directory)
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "orchestrator.json", `{"server": {"port": 9092}, "log": {"level": "info"}}`)
+
+	t.Setenv("APP_PORT", "9093")
+	t.Setenv("APP_LOG_LEVEL", "warn")
+	t.Setenv("MASTER_KEY", "0123456789abcdef")
+	t.Setenv("APP_STORE_DISABLE_CACHE", "true")
+	t.Setenv("APP_RATE_LIMIT_READS", "50")
+	t.Setenv("APP_RATE_LIMIT_WRITE_BURST", "20")
+	t.Setenv("APP_WASM_MAX_FUEL", "1000")
+	t.Setenv("APP_WASM_MAX_TIMEOUT_SECS", "10")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitConfig{ReadsPerSecond: 50, WriteBurst: 20}, cfg.Server.RateLimit)
+	assert.Equal(t, WasmConfig{MaxFuel: 1000, MaxTimeoutSecs: 10}, cfg.Engine.Wasm)
+
+	assert.Equal(t, 9093, cfg.Server.Port)
+	assert.Equal(t, "warn", cfg.Log.Level)
+	assert.Equal(t, "0123456789abcdef", cfg.Store.EncryptionKey)
+	assert.True(t, cfg.Store.DisableCache)
+}
+
+func TestLoadKeyProvider(t *testing.T) {
+	path := writeConfig(t, "orchestrator.yaml", `
+store:
+  keyProvider:
+    type: Vault
+    address: https://vault:8200
+    key: orchestrator
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.True(t, cfg.Store.KeyProvider.Enabled())
+	assert.Equal(t, VaultKeyProvider, cfg.Store.KeyProvider.Type)
+	provider, err := cfg.Store.KeyProvider.Provider()
+	require.NoError(t, err)
+	assert.NotNil(t, provider)
+
+	t.Setenv("APP_KEY_PROVIDER", "awskms")
+	t.Setenv("APP_KEY_PROVIDER_KEY", "alias/orchestrator")
+	t.Setenv("AWS_REGION", "us-west-2")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, AWSKMSKeyProvider, cfg.Store.KeyProvider.Type)
+	assert.Equal(t, "us-west-2", cfg.Store.KeyProvider.Region)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := Load(writeConfig(t, "orchestrator.ini", "port=1"))
+	require.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "store:\n  backend: postgres\n"))
+	require.ErrorIs(t, err, ErrDatabaseURLRequired)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "store:\n  backend: consul\n"))
+	require.ErrorIs(t, err, ErrInvalidStoreBackend)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "server:\n  tls:\n    certFile: tls.crt\n"))
+	require.ErrorIs(t, err, ErrInvalidTLS)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "server:\n  rateLimit:\n    writesPerSecond: -1\n"))
+	require.ErrorIs(t, err, ErrInvalidRateLimit)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "store:\n  keyProvider:\n    type: hsm\n    key: orchestrator\n"))
+	require.ErrorIs(t, err, ErrInvalidKeyProvider)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "store:\n  keyProvider:\n    type: vault\n    key: orchestrator\n"))
+	require.ErrorIs(t, err, ErrInvalidKeyProvider)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "log:\n  format: logfmt\n"))
+	require.ErrorIs(t, err, ErrInvalidLogFormat)
+
+	_, err = Load(writeConfig(t, "orchestrator.yaml", "engine:\n  wasm:\n    maxTimeoutSecs: -1\n"))
+	require.ErrorIs(t, err, ErrInvalidWasmLimits)
+
+	t.Setenv("APP_PORT", "http")
+	_, err = Load("")
+	require.Error(t, err)
+
+	t.Setenv("APP_PORT", "70000")
+	_, err = Load("")
+	require.ErrorIs(t, err, ErrInvalidPort)
+}
+
+func TestLoadLogFormat(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "orchestrator.yaml", "log:\n  format: JSON\n"))
+	require.NoError(t, err)
+	assert.Equal(t, LogFormatJSON, cfg.Log.Format)
+
+	t.Setenv("APP_LOG_FORMAT", "console")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, LogFormatConsole, cfg.Log.Format)
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Store.DatabaseURL = "postgres://orchestrator:hunter2@db:5432/orchestrator"
+	cfg.Store.EncryptionKey = "0123456789abcdef"
+	cfg.Store.Params = map[string]string{"keyprefix": "orchestrator:", "password": "hunter2"}
+	cfg.Slack.SigningSecret = "slack"
+
+	redacted := cfg.Redacted()
+	assert.NotContains(t, redacted.Store.DatabaseURL, "hunter2")
+	assert.Contains(t, redacted.Store.DatabaseURL, "db:5432")
+	assert.Equal(t, "REDACTED", redacted.Store.EncryptionKey)
+	assert.Equal(t, "REDACTED", redacted.Store.Params["password"])
+	assert.Equal(t, "orchestrator:", redacted.Store.Params["keyprefix"])
+	assert.Equal(t, "REDACTED", redacted.Slack.SigningSecret)
+
+	// original is unchanged
+	assert.Equal(t, "hunter2", cfg.Store.Params["password"])
+	assert.Equal(t, "0123456789abcdef", cfg.Store.EncryptionKey)
+}
@@ -0,0 +1,596 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: controller.proto
+
+package controllerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Target struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Group         string                 `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	IsError       bool                   `protobuf:"varint,5,opt,name=is_error,json=isError,proto3" json:"is_error,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Target) Reset() {
+	*x = Target{}
+	mi := &file_controller_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Target) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Target) ProtoMessage() {}
+
+func (x *Target) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Target.ProtoReflect.Descriptor instead.
+func (*Target) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Target) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Target) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *Target) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Target) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Target) GetIsError() bool {
+	if x != nil {
+		return x.IsError
+	}
+	return false
+}
+
+func (x *Target) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type SelectionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SelectionRequest) Reset() {
+	*x = SelectionRequest{}
+	mi := &file_controller_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SelectionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SelectionRequest) ProtoMessage() {}
+
+func (x *SelectionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SelectionRequest.ProtoReflect.Descriptor instead.
+func (*SelectionRequest) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SelectionRequest) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+func (x *SelectionRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type SelectionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SelectionResponse) Reset() {
+	*x = SelectionResponse{}
+	mi := &file_controller_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SelectionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SelectionResponse) ProtoMessage() {}
+
+func (x *SelectionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SelectionResponse.ProtoReflect.Descriptor instead.
+func (*SelectionResponse) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SelectionResponse) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+type ApprovalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApprovalRequest) Reset() {
+	*x = ApprovalRequest{}
+	mi := &file_controller_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApprovalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApprovalRequest) ProtoMessage() {}
+
+func (x *ApprovalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApprovalRequest.ProtoReflect.Descriptor instead.
+func (*ApprovalRequest) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ApprovalRequest) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+type ApprovalResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApprovalResponse) Reset() {
+	*x = ApprovalResponse{}
+	mi := &file_controller_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApprovalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApprovalResponse) ProtoMessage() {}
+
+func (x *ApprovalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApprovalResponse.ProtoReflect.Descriptor instead.
+func (*ApprovalResponse) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ApprovalResponse) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+type MonitoringRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        *Target                `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MonitoringRequest) Reset() {
+	*x = MonitoringRequest{}
+	mi := &file_controller_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MonitoringRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MonitoringRequest) ProtoMessage() {}
+
+func (x *MonitoringRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MonitoringRequest.ProtoReflect.Descriptor instead.
+func (*MonitoringRequest) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MonitoringRequest) GetTarget() *Target {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+type MonitoringResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MonitoringResponse) Reset() {
+	*x = MonitoringResponse{}
+	mi := &file_controller_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MonitoringResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MonitoringResponse) ProtoMessage() {}
+
+func (x *MonitoringResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MonitoringResponse.ProtoReflect.Descriptor instead.
+func (*MonitoringResponse) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MonitoringResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *MonitoringResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type RemovalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemovalRequest) Reset() {
+	*x = RemovalRequest{}
+	mi := &file_controller_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemovalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemovalRequest) ProtoMessage() {}
+
+func (x *RemovalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemovalRequest.ProtoReflect.Descriptor instead.
+func (*RemovalRequest) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RemovalRequest) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+func (x *RemovalRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type RemovalResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemovalResponse) Reset() {
+	*x = RemovalResponse{}
+	mi := &file_controller_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemovalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemovalResponse) ProtoMessage() {}
+
+func (x *RemovalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_controller_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemovalResponse.ProtoReflect.Descriptor instead.
+func (*RemovalResponse) Descriptor() ([]byte, []int) {
+	return file_controller_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RemovalResponse) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+var File_controller_proto protoreflect.FileDescriptor
+
+const file_controller_proto_rawDesc = "" +
+	"\n" +
+	"\x10controller.proto\x12\x1aorchestrator.controller.v1\"\x84\x02\n" +
+	"\x06Target\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05group\x18\x02 \x01(\tR\x05group\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x19\n" +
+	"\bis_error\x18\x05 \x01(\bR\aisError\x12F\n" +
+	"\x06labels\x18\x06 \x03(\v2..orchestrator.controller.v1.Target.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"f\n" +
+	"\x10SelectionRequest\x12<\n" +
+	"\atargets\x18\x01 \x03(\v2\".orchestrator.controller.v1.TargetR\atargets\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\"Q\n" +
+	"\x11SelectionResponse\x12<\n" +
+	"\atargets\x18\x01 \x03(\v2\".orchestrator.controller.v1.TargetR\atargets\"O\n" +
+	"\x0fApprovalRequest\x12<\n" +
+	"\atargets\x18\x01 \x03(\v2\".orchestrator.controller.v1.TargetR\atargets\"P\n" +
+	"\x10ApprovalResponse\x12<\n" +
+	"\atargets\x18\x01 \x03(\v2\".orchestrator.controller.v1.TargetR\atargets\"O\n" +
+	"\x11MonitoringRequest\x12:\n" +
+	"\x06target\x18\x01 \x01(\v2\".orchestrator.controller.v1.TargetR\x06target\"F\n" +
+	"\x12MonitoringResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"d\n" +
+	"\x0eRemovalRequest\x12<\n" +
+	"\atargets\x18\x01 \x03(\v2\".orchestrator.controller.v1.TargetR\atargets\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\"O\n" +
+	"\x0fRemovalResponse\x12<\n" +
+	"\atargets\x18\x01 \x03(\v2\".orchestrator.controller.v1.TargetR\atargets2\xb4\x03\n" +
+	"\x10TargetController\x12h\n" +
+	"\tSelection\x12,.orchestrator.controller.v1.SelectionRequest\x1a-.orchestrator.controller.v1.SelectionResponse\x12e\n" +
+	"\bApproval\x12+.orchestrator.controller.v1.ApprovalRequest\x1a,.orchestrator.controller.v1.ApprovalResponse\x12k\n" +
+	"\n" +
+	"Monitoring\x12-.orchestrator.controller.v1.MonitoringRequest\x1a..orchestrator.controller.v1.MonitoringResponse\x12b\n" +
+	"\aRemoval\x12*.orchestrator.controller.v1.RemovalRequest\x1a+.orchestrator.controller.v1.RemovalResponseB.Z,github.com/nixmade/orchestrator/controllerpbb\x06proto3"
+
+var (
+	file_controller_proto_rawDescOnce sync.Once
+	file_controller_proto_rawDescData []byte
+)
+
+func file_controller_proto_rawDescGZIP() []byte {
+	file_controller_proto_rawDescOnce.Do(func() {
+		file_controller_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_controller_proto_rawDesc), len(file_controller_proto_rawDesc)))
+	})
+	return file_controller_proto_rawDescData
+}
+
+var file_controller_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_controller_proto_goTypes = []any{
+	(*Target)(nil),             // 0: orchestrator.controller.v1.Target
+	(*SelectionRequest)(nil),   // 1: orchestrator.controller.v1.SelectionRequest
+	(*SelectionResponse)(nil),  // 2: orchestrator.controller.v1.SelectionResponse
+	(*ApprovalRequest)(nil),    // 3: orchestrator.controller.v1.ApprovalRequest
+	(*ApprovalResponse)(nil),   // 4: orchestrator.controller.v1.ApprovalResponse
+	(*MonitoringRequest)(nil),  // 5: orchestrator.controller.v1.MonitoringRequest
+	(*MonitoringResponse)(nil), // 6: orchestrator.controller.v1.MonitoringResponse
+	(*RemovalRequest)(nil),     // 7: orchestrator.controller.v1.RemovalRequest
+	(*RemovalResponse)(nil),    // 8: orchestrator.controller.v1.RemovalResponse
+	nil,                        // 9: orchestrator.controller.v1.Target.LabelsEntry
+}
+var file_controller_proto_depIdxs = []int32{
+	9,  // 0: orchestrator.controller.v1.Target.labels:type_name -> orchestrator.controller.v1.Target.LabelsEntry
+	0,  // 1: orchestrator.controller.v1.SelectionRequest.targets:type_name -> orchestrator.controller.v1.Target
+	0,  // 2: orchestrator.controller.v1.SelectionResponse.targets:type_name -> orchestrator.controller.v1.Target
+	0,  // 3: orchestrator.controller.v1.ApprovalRequest.targets:type_name -> orchestrator.controller.v1.Target
+	0,  // 4: orchestrator.controller.v1.ApprovalResponse.targets:type_name -> orchestrator.controller.v1.Target
+	0,  // 5: orchestrator.controller.v1.MonitoringRequest.target:type_name -> orchestrator.controller.v1.Target
+	0,  // 6: orchestrator.controller.v1.RemovalRequest.targets:type_name -> orchestrator.controller.v1.Target
+	0,  // 7: orchestrator.controller.v1.RemovalResponse.targets:type_name -> orchestrator.controller.v1.Target
+	1,  // 8: orchestrator.controller.v1.TargetController.Selection:input_type -> orchestrator.controller.v1.SelectionRequest
+	3,  // 9: orchestrator.controller.v1.TargetController.Approval:input_type -> orchestrator.controller.v1.ApprovalRequest
+	5,  // 10: orchestrator.controller.v1.TargetController.Monitoring:input_type -> orchestrator.controller.v1.MonitoringRequest
+	7,  // 11: orchestrator.controller.v1.TargetController.Removal:input_type -> orchestrator.controller.v1.RemovalRequest
+	2,  // 12: orchestrator.controller.v1.TargetController.Selection:output_type -> orchestrator.controller.v1.SelectionResponse
+	4,  // 13: orchestrator.controller.v1.TargetController.Approval:output_type -> orchestrator.controller.v1.ApprovalResponse
+	6,  // 14: orchestrator.controller.v1.TargetController.Monitoring:output_type -> orchestrator.controller.v1.MonitoringResponse
+	8,  // 15: orchestrator.controller.v1.TargetController.Removal:output_type -> orchestrator.controller.v1.RemovalResponse
+	12, // [12:16] is the sub-list for method output_type
+	8,  // [8:12] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_controller_proto_init() }
+func file_controller_proto_init() {
+	if File_controller_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_controller_proto_rawDesc), len(file_controller_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_controller_proto_goTypes,
+		DependencyIndexes: file_controller_proto_depIdxs,
+		MessageInfos:      file_controller_proto_msgTypes,
+	}.Build()
+	File_controller_proto = out.File
+	file_controller_proto_goTypes = nil
+	file_controller_proto_depIdxs = nil
+}
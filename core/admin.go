@@ -0,0 +1,140 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+	"github.com/rs/zerolog"
+)
+
+// ReadOnlyState is request and response for read-only admin toggle
+type ReadOnlyState struct {
+	ReadOnly bool `json:"readonly"`
+}
+
+// LeaderState is response of leader admin request
+type LeaderState struct {
+	Leader bool `json:"leader"`
+}
+
+// Admin creates router for admin operations, these are not affected by read-only mode
+func (app *App) Admin() http.Handler {
+	r := chi.NewRouter()
+	r.Use(app.auth.Required)
+	r.Use(app.rateLimiter().Limit)
+
+	r.Get("/readonly", app.getReadOnly)
+	r.Post("/readonly", app.setReadOnly)
+	r.Get("/scans", app.getStoreScans)
+	r.Post("/scans/{id}/cancel", app.cancelStoreScan)
+	r.Get("/leader", app.getLeader)
+	r.Get("/freeze", app.getGlobalFreeze)
+	r.Post("/freeze", app.setGlobalFreeze)
+	r.Get("/loglevel", app.getLogLevel)
+	r.Put("/loglevel", app.setLogLevel)
+	return r
+}
+
+// rejectReadOnly fails mutating requests with 503 while in read-only mode
+func (app *App) rejectReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.ReadOnly() {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				response.Error(w, http.StatusServiceUnavailable, engine.ErrReadOnly.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *App) getReadOnly(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, &ReadOnlyState{ReadOnly: app.ReadOnly()})
+}
+
+func (app *App) setReadOnly(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	var state ReadOnlyState
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	app.SetReadOnly(state.ReadOnly)
+	response.JSON(w, http.StatusOK, &state)
+}
+
+func (app *App) getLeader(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, &LeaderState{Leader: app.e.IsLeader()})
+}
+
+func (app *App) getStoreScans(w http.ResponseWriter, r *http.Request) {
+	minAgeSecs, err := queryInt(r, "minagesecs", 0)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, app.e.GetStoreScans(time.Duration(minAgeSecs)*time.Second))
+}
+
+func (app *App) cancelStoreScan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.CancelStoreScan(id); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, app.LogLevel())
+}
+
+func (app *App) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	var state LogLevelState
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	level, err := zerolog.ParseLevel(strings.ToLower(state.Level))
+	if err != nil || state.Level == "" || state.DurationSecs < 0 {
+		err = fmt.Errorf("%w: %q for %d seconds", engine.ErrInvalidLogLevel, state.Level, state.DurationSecs)
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, app.SetLogLevel(level, time.Duration(state.DurationSecs)*time.Second))
+}
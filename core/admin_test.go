@@ -0,0 +1,117 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	app := NewApp(WithReadOnly(true))
+	router := NewRouter(app)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/orchestrate/ns/entity/version", strings.NewReader(`{"version":"v2"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "read-only")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/readonly", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"readonly":true}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/readonly", strings.NewReader(`{"readonly":false}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, app.ReadOnly())
+}
+
+func TestLogLevelAdmin(t *testing.T) {
+	original := zerolog.GlobalLevel()
+	defer zerolog.SetGlobalLevel(original)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	app := NewApp()
+	router := NewRouter(app)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/loglevel", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"info"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/v1/admin/loglevel", strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, zerolog.InfoLevel, zerolog.GlobalLevel())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/v1/admin/loglevel", strings.NewReader(`{"level":"debug","durationsecs":3600}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, zerolog.DebugLevel, zerolog.GlobalLevel())
+	assert.Contains(t, w.Body.String(), `"revertlevel":"info"`)
+
+	// consecutive temporary changes revert to level before the first
+	state := app.SetLogLevel(zerolog.TraceLevel, 50*time.Millisecond)
+	assert.Equal(t, "trace", state.Level)
+	assert.Equal(t, "info", state.RevertLevel)
+	assert.Eventually(t, func() bool {
+		return zerolog.GlobalLevel() == zerolog.InfoLevel
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, app.LogLevel().RevertAt)
+
+	// permanent change cancels pending revert
+	app.SetLogLevel(zerolog.DebugLevel, time.Hour)
+	state = app.SetLogLevel(zerolog.WarnLevel, 0)
+	assert.Nil(t, state.RevertAt)
+	assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+}
+
+func TestStoreScansAdmin(t *testing.T) {
+	router := NewRouter(NewApp(WithEngine(&engine.Engine{})))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/scans?minagesecs=3600", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/scans/0/cancel", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "not found")
+}
+
+func TestAuthenticatedRoutes(t *testing.T) {
+	auth, err := server.NewAuthenticator(&server.AuthConfig{HMACSecret: "secret"})
+	require.NoError(t, err)
+
+	router := NewRouter(NewApp(WithAuth(auth)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/readonly", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "admin",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/admin/readonly", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/orchestrate/ns/entity/version", strings.NewReader(`{"version":"v2"}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
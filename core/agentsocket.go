@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+)
+
+const (
+	agentSocketReadLimit = 4 << 20
+	agentSocketBuffer    = 256
+)
+
+// AgentMessage is sent by long lived agents over websocket with current state of its targets
+type AgentMessage struct {
+	Targets []*engine.ClientState `json:"targets,omitempty"`
+}
+
+// AgentAssignment is pushed to agents with assigned versions of its targets
+type AgentAssignment struct {
+	Targets []*engine.ClientState `json:"targets,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+func agentTargetKey(group, name string) string {
+	return group + "/" + name
+}
+
+// agentSocket holds state of a connected agent
+type agentSocket struct {
+	app       *App
+	conn      *websocket.Conn
+	namespace string
+	entity    string
+	// last version sent to agent by target
+	assigned map[string]string
+}
+
+func (s *agentSocket) send(ctx context.Context, assignment *AgentAssignment) error {
+	for _, clientTarget := range assignment.Targets {
+		s.assigned[agentTargetKey(clientTarget.Group, clientTarget.Name)] = clientTarget.Version
+	}
+	return wsjson.Write(ctx, s.conn, assignment)
+}
+
+// orchestrate reported targets and reply with assignments of agent targets only
+func (s *agentSocket) orchestrate(ctx context.Context, message *AgentMessage) error {
+	if s.app.ReadOnly() {
+		return wsjson.Write(ctx, s.conn, &AgentAssignment{Error: engine.ErrReadOnly.Error()})
+	}
+
+	reported := make(map[string]bool, len(message.Targets))
+	for _, clientTarget := range message.Targets {
+		reported[agentTargetKey(clientTarget.Group, clientTarget.Name)] = true
+		if _, ok := s.assigned[agentTargetKey(clientTarget.Group, clientTarget.Name)]; !ok {
+			s.assigned[agentTargetKey(clientTarget.Group, clientTarget.Name)] = ""
+		}
+	}
+
+	clientTargets, err := s.app.e.Orchestrate(s.namespace, s.entity, message.Targets)
+	if err != nil {
+		return wsjson.Write(ctx, s.conn, &AgentAssignment{Error: err.Error()})
+	}
+
+	assignment := &AgentAssignment{}
+	for _, clientTarget := range clientTargets {
+		if reported[agentTargetKey(clientTarget.Group, clientTarget.Name)] {
+			assignment.Targets = append(assignment.Targets, clientTarget)
+		}
+	}
+
+	return s.send(ctx, assignment)
+}
+
+// push target assigned a new version outside of this connection, like another orchestrate call
+func (s *agentSocket) push(ctx context.Context, clientTarget *engine.ClientState) error {
+	version, ok := s.assigned[agentTargetKey(clientTarget.Group, clientTarget.Name)]
+	if !ok || version == clientTarget.Version {
+		return nil
+	}
+
+	return s.send(ctx, &AgentAssignment{Targets: []*engine.ClientState{clientTarget}})
+}
+
+// agentSocket accepts long lived agent connections, agents push ClientState periodically
+// and receive target version assignments as push messages instead of polling
+func (app *App) agentSocket(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	// connection outlives server read and write timeouts
+	controller := http.NewResponseController(w)
+	if err := controller.SetReadDeadline(time.Time{}); err != nil {
+		app.requestLogger(r).Debug().Err(err).Msg("failed to clear read deadline for agent socket")
+	}
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		app.requestLogger(r).Debug().Err(err).Msg("failed to clear write deadline for agent socket")
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		app.requestLogger(r).Error().Err(err).Msg("failed to accept agent socket")
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(agentSocketReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	updates := make(chan *engine.ClientState, agentSocketBuffer)
+	unsubscribe := app.e.Events().Subscribe(func(event engine.Event) {
+		if event.Type != engine.EventTargetUpdated || event.Namespace != namespace || event.Entity != entity {
+			return
+		}
+		select {
+		case updates <- event.State:
+		default:
+			// slow agent, it still gets assignments on its next report
+		}
+	})
+	defer unsubscribe()
+
+	messages := make(chan *AgentMessage)
+	go func() {
+		defer cancel()
+		for {
+			message := &AgentMessage{}
+			if err := wsjson.Read(ctx, conn, message); err != nil {
+				return
+			}
+			select {
+			case messages <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	socket := &agentSocket{
+		app:       app,
+		conn:      conn,
+		namespace: namespace,
+		entity:    entity,
+		assigned:  make(map[string]string),
+	}
+
+	logger := app.requestLogger(r).With().Str("Namespace", namespace).Str("Entity", entity).Logger()
+	logger.Info().Msg("Agent connected")
+	defer logger.Info().Msg("Agent disconnected")
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return
+		case message := <-messages:
+			err = socket.orchestrate(ctx, message)
+		case clientTarget := <-updates:
+			err = socket.push(ctx, clientTarget)
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to write to agent socket")
+			return
+		}
+	}
+}
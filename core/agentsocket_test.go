@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentSocket(t *testing.T) {
+	const testName = "TestAgentSocket"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+
+	var clientTargets []*engine.ClientState
+	for i := 0; i < 4; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewApp(WithEngine(e))))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, httpclient.NewOrchestratorAPI(srv.URL).AgentSocket(testName, testName), nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	// agent owns first two targets
+	require.NoError(t, wsjson.Write(ctx, conn, &AgentMessage{Targets: clientTargets[:2]}))
+
+	var assignment AgentAssignment
+	require.NoError(t, wsjson.Read(ctx, conn, &assignment))
+	assert.Empty(t, assignment.Error)
+	require.Len(t, assignment.Targets, 2)
+	assert.Equal(t, "v1", assignment.Targets[0].Version)
+
+	// new version assigned through other orchestrate calls gets pushed
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	for i := 0; i < 2; i++ {
+		_, err = e.Orchestrate(testName, testName, clientTargets[2:])
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, wsjson.Read(ctx, conn, &assignment))
+	require.Len(t, assignment.Targets, 1)
+	assert.Equal(t, "v2", assignment.Targets[0].Version)
+	assert.Contains(t, []string{clientTargets[0].Name, clientTargets[1].Name}, assignment.Targets[0].Name)
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisAPI(t *testing.T) {
+	const testName = "TestAnalysisAPI"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	config := &engine.AnalysisConfig{
+		Provider: engine.AnalysisProviderConfig{Type: engine.MetricProviderDatadog, APIKey: "api", ApplicationKey: "app"},
+		Metrics:  []engine.AnalysisMetric{{Name: "errors", Query: "avg:errors{version:{{version}}}"}},
+	}
+	saved := &engine.AnalysisConfig{}
+	require.NoError(t, httpclient.PostJSON(api.Analysis(testName, testName), "", config, saved))
+	assert.Equal(t, engine.RedactedSecret, saved.Provider.APIKey)
+
+	fetched := &engine.AnalysisConfig{}
+	require.NoError(t, httpclient.GetJSON(api.Analysis(testName, testName), "", fetched))
+	assert.Equal(t, engine.RedactedSecret, fetched.Provider.ApplicationKey)
+	assert.Equal(t, config.Metrics, fetched.Metrics)
+
+	// secrets are stored
+	stored, err := e.GetAnalysisConfig(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "api", stored.Provider.APIKey)
+
+	var runs []*engine.AnalysisRun
+	require.NoError(t, httpclient.GetJSON(api.AnalysisRuns(testName, testName), "", &runs))
+	assert.Empty(t, runs)
+
+	assert.Error(t, httpclient.PostJSON(api.Analysis(testName, testName), "", &engine.AnalysisConfig{}, saved))
+}
@@ -2,21 +2,118 @@ package core
 
 import (
 	"net/http"
-	"os"
+	"sync"
+	"sync/atomic"
 
+	"github.com/nats-io/nats.go"
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/metrics"
+	"github.com/nixmade/orchestrator/server"
 	"github.com/nixmade/orchestrator/store"
+	"github.com/nixmade/orchestrator/tracing"
 	"github.com/rs/zerolog"
 )
 
 // Context stores local and aggregate stores
 type App struct {
-	dbStore store.Store
-	e       *Engine
-	logger  zerolog.Logger
+	// store is passed to engine created by Create, engine opens configured store if nil
+	store  store.Store
+	e      *engine.Engine
+	logger zerolog.Logger
+	// hasLogger keeps logger set with WithLogger instead of logger passed to Create
+	hasLogger bool
+	readOnly  atomic.Bool
+	auth      *server.Authenticator
+	config    *config.Config
+	// configErr is returned from Create when environment configuration is invalid
+	configErr error
+	nats      *nats.Conn
+	bridge    *NATSBridge
+	// keyProvider supplies badger data key to engine created by Create, created from store config if not set
+	keyProvider store.KeyProvider
+	// metrics served on /metrics, default registry if nil
+	metrics *metrics.Registry
+	// limiter rate limits orchestrate and admin routes, created from config on first use
+	limiter     *server.RateLimiter
+	limiterOnce sync.Once
+	// logLevel reverts temporary global log level changes
+	logLevel logLevelRevert
 }
 
-func NewApp() *App {
-	return &App{}
+// NewApp creates app configured using environment variables and opts
+func NewApp(opts ...Option) *App {
+	cfg, err := config.FromEnv()
+	app := &App{config: cfg, configErr: err}
+	for _, opt := range opts {
+		opt(app)
+	}
+	return app
+}
+
+// NewAppWithConfig creates app using provided configuration
+func NewAppWithConfig(cfg *config.Config) *App {
+	return &App{config: cfg}
+}
+
+// NewAppWithEngine creates app serving an existing engine, used when embedding the router
+func NewAppWithEngine(e *engine.Engine) *App {
+	app := &App{}
+	WithEngine(e)(app)
+	return app
+}
+
+// SetReadOnly toggles read-only mode, mutating requests return 503 while status reads keep working
+func (app *App) SetReadOnly(readOnly bool) {
+	app.logger.Warn().Bool("ReadOnly", readOnly).Msg("Setting read-only mode")
+	app.readOnly.Store(readOnly)
+}
+
+// ReadOnly returns true if app is in read-only mode
+func (app *App) ReadOnly() bool {
+	return app.readOnly.Load()
+}
+
+// requestLogger returns logger of request with its request id, app logger outside of routers logging requests
+func (app *App) requestLogger(r *http.Request) *zerolog.Logger {
+	if logger := zerolog.Ctx(r.Context()); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &app.logger
+}
+
+// SetAuthenticator requires valid JWT bearer token for orchestrate and admin routes, nil disables authentication
+func (app *App) SetAuthenticator(auth *server.Authenticator) {
+	app.auth = auth
+}
+
+// SetKeyProvider fetches store data key from provider instead of configured encryption key, must be called before Create
+func (app *App) SetKeyProvider(provider store.KeyProvider) {
+	app.keyProvider = provider
+}
+
+// rateLimiter returns rate limiter of configured limits, nil if requests are not limited
+func (app *App) rateLimiter() *server.RateLimiter {
+	app.limiterOnce.Do(func() {
+		app.limiter = server.NewRateLimiter(app.Config().Server.RateLimit)
+	})
+	return app.limiter
+}
+
+// Config returns configuration app was created with
+func (app *App) Config() *config.Config {
+	if app.config == nil {
+		return config.Default()
+	}
+	return app.config
+}
+
+// metricsHandler serves registry set with WithMetrics, default registry otherwise
+func (app *App) metricsHandler() http.Handler {
+	if app.metrics == nil {
+		return metrics.Handler()
+	}
+	return app.metrics.Handler()
 }
 
 func (app *App) Name() string {
@@ -27,31 +124,70 @@ func (app *App) Name() string {
 func (app *App) Create(logger zerolog.Logger) error {
 	var err error
 
-	app.logger = logger
-	app.dbStore, err = store.NewBadgerDBStore(os.Getenv("APP_CONFIG_DIR"), os.Getenv("MASTER_KEY"))
-	if err != nil {
-		logger.Error().Err(err).Msg("failed to create store")
+	if !app.hasLogger {
+		app.logger = logger
+	}
+	logger = app.logger
+	if app.configErr != nil {
+		logger.Error().Err(app.configErr).Msg("invalid configuration")
+		return app.configErr
+	}
+	cfg := app.Config()
+	if err := cfg.Validate(); err != nil {
+		logger.Error().Err(err).Msg("invalid configuration")
 		return err
 	}
 
-	logger.Info().Msg("Starting the engine")
-	app.e, err = NewOrchestratorEngineWithApp(app)
+	if tracing.ConfigureFromEnv(app.Name(), logger) {
+		logger.Info().Msg("Tracing enabled")
+	}
+
+	if app.e == nil {
+		logger.Info().Msg("Starting the engine")
+		opts := []engine.Option{engine.WithConfig(cfg), engine.WithLogger(logger), engine.WithKeyProvider(app.keyProvider)}
+		if app.store != nil {
+			opts = append(opts, engine.WithStore(app.store))
+		}
+		app.e, err = engine.New(opts...)
+		if err != nil {
+			app.logger.Error().Err(err).Msg("failed to create orchestrator engine")
+			return err
+		}
+		app.e.Start()
+	}
+
+	if cfg.NATS.URL != "" {
+		return app.startNATSBridge(cfg.NATS)
+	}
+	return nil
+}
+
+// startNATSBridge connects to NATS and bridges agent reports and assignments
+func (app *App) startNATSBridge(cfg config.NATSConfig) error {
+	var err error
+
+	app.logger.Info().Str("Subject", cfg.SubjectPrefix).Msg("Connecting to NATS")
+	app.nats, err = nats.Connect(cfg.URL, nats.Name(app.Name()))
 	if err != nil {
-		app.logger.Error().Err(err).Msg("failed to create orchestrator engine")
+		app.logger.Error().Err(err).Msg("failed to connect to NATS")
 		return err
 	}
-	return nil
+
+	app.bridge = NewNATSBridge(app, app.nats, cfg.SubjectPrefix)
+	return app.bridge.Start()
 }
 
 // Delete app context and HTTP Server
 func (app *App) Delete() error {
-	if err := app.e.Shutdown(); err != nil {
-		return err
+	if app.bridge != nil {
+		if err := app.bridge.Close(); err != nil {
+			app.logger.Error().Err(err).Msg("failed to close NATS bridge")
+		}
 	}
-	if err := app.dbStore.Close(); err != nil {
-		return err
+	if app.nats != nil {
+		app.nats.Close()
 	}
-	return nil
+	return app.e.Stop()
 }
 
 func (app *App) Handler() http.Handler {
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func getLogger() zerolog.Logger {
+	return zerolog.New(os.Stderr).With().Caller().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
+}
+
+func setupTestEngine(testName string) (*engine.Engine, error) {
+	if testing.Verbose() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.FatalLevel)
+	}
+	logger := getLogger().With().Str("Test", testName).Logger()
+
+	var dbstore store.Store
+	if os.Getenv("DATABASE_URL") != "" {
+		pgStore, err := store.NewPgxStoreWithTable(os.Getenv("DATABASE_URL"), testName)
+		if err != nil {
+			return nil, err
+		}
+		dbstore = pgStore
+	} else {
+		aesKey := make([]byte, 32)
+		if _, err := rand.Read(aesKey); err != nil {
+			return nil, err
+		}
+		badgerStore, err := store.NewBadgerDBStore("", string(aesKey))
+		if err != nil {
+			return nil, err
+		}
+		dbstore = badgerStore
+	}
+
+	e, err := engine.New(engine.WithStore(dbstore), engine.WithLogger(logger))
+	if err != nil {
+		return nil, err
+	}
+	e.Start()
+	return e, nil
+}
+
+func cleanupTestEngine(t *testing.T, e *engine.Engine, testName string) {
+	if err := e.Stop(); err != nil {
+		fmt.Println(err.Error())
+	}
+	assert.NoError(t, os.RemoveAll("./"+testName))
+}
+
+// setupNamespace rolls out v1 to numTargets targets, then starts rolling out v2
+func setupNamespace(e *engine.Engine, namespaceName, entityName string, numTargets int) ([]*engine.ClientState, error) {
+	if err := e.SetRolloutOptions(namespaceName, entityName, &engine.RolloutOptions{BatchPercent: 100}); err != nil {
+		return nil, err
+	}
+	if err := e.SetTargetVersion(namespaceName, entityName, engine.EntityTargetVersion{Version: "v1"}); err != nil {
+		return nil, err
+	}
+
+	var clientTargets []*engine.ClientState
+	for i := 0; i < numTargets; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{
+			Name:    fmt.Sprintf("clientTarget%d", i),
+			Version: "v1",
+			Message: "running successfully",
+		})
+	}
+
+	// Set LKG to v1
+	if _, err := e.Orchestrate(namespaceName, entityName, clientTargets); err != nil {
+		return nil, err
+	}
+
+	time.Sleep(1 * time.Second)
+
+	options := engine.DefaultRolloutOptions()
+	options.SuccessTimeoutSecs = 0
+	if err := e.SetRolloutOptions(namespaceName, entityName, options); err != nil {
+		return nil, err
+	}
+	if err := e.SetTargetVersion(namespaceName, entityName, engine.EntityTargetVersion{Version: "v2"}); err != nil {
+		return nil, err
+	}
+
+	// Following updates rolling version to v2
+	if _, err := e.Orchestrate(namespaceName, entityName, clientTargets); err != nil {
+		return nil, err
+	}
+	return clientTargets, nil
+}
+
+func countVersion(clientTargets []*engine.ClientState, version string) int {
+	count := 0
+	for _, clientTarget := range clientTargets {
+		if clientTarget.Version == version {
+			count++
+		}
+	}
+	return count
+}
+
+// offsetClock runs ahead of system clock, tests advance it instead of sleeping
+type offsetClock struct {
+	offset time.Duration
+}
+
+func (c *offsetClock) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
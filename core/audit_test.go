@@ -0,0 +1,67 @@
+package core
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRecordsAPI(t *testing.T) {
+	const testName = "TestAuditRecordsAPI"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	start := engine.DefaultClock.Now().UTC()
+	require.NoError(t, httpclient.PostJSON(api.TargetVersion(testName, testName), "", &engine.EntityTargetVersion{Version: "v1"}, nil))
+	require.NoError(t, httpclient.PostJSON(api.TargetVersion(testName, testName), "", &engine.EntityTargetVersion{Version: "v2", ChangeInfo: engine.ChangeInfo{Ticket: "CHG-1"}}, nil))
+	require.NoError(t, httpclient.PostJSON(api.RolloutOptions(testName, testName), "", &engine.RolloutOptions{BatchPercent: 50, SuccessPercent: 100}, nil))
+	require.NoError(t, httpclient.PostJSON(api.Notifications(testName, testName), "", &engine.NotificationConfig{URL: "http://hooks", Secret: "hmac"}, nil))
+	// failed calls are not recorded
+	assert.Error(t, httpclient.PostJSON(api.RolloutOptions(testName, testName), "", &engine.RolloutOptions{BatchPercent: 50, ConcurrencyPolicy: "unknown"}, nil))
+	// validation only calls are not recorded and change nothing
+	require.NoError(t, httpclient.PostJSON(api.ValidateRolloutOptions(testName, testName), "", &engine.RolloutOptions{BatchPercent: 20}, nil))
+	assert.Error(t, httpclient.PostJSON(api.ValidateRolloutOptions(testName, testName), "", &engine.RolloutOptions{BatchPercent: 20, SuccessPercent: 101}, nil))
+	// request bodies are bounded before being read for audit
+	assert.Error(t, httpclient.PostJSON(api.TargetVersion(testName, testName), "", &engine.EntityTargetVersion{Version: strings.Repeat("v", maxAuditedRequest)}, nil))
+
+	var records []*engine.AuditRecord
+	require.NoError(t, httpclient.GetJSON(api.Audit(testName, testName), "", &records))
+	require.Len(t, records, 4)
+	assert.Equal(t, engine.AuditNotifications, records[0].Action)
+	assert.Contains(t, string(records[0].New), engine.RedactedSecret)
+	assert.NotContains(t, string(records[0].New), "hmac")
+	assert.Equal(t, engine.AuditRolloutOptions, records[1].Action)
+	assert.Contains(t, string(records[1].New), `"batchpercent":50`)
+	assert.NotEmpty(t, records[1].Old)
+
+	version := records[2]
+	assert.Equal(t, engine.AuditTargetVersion, version.Action)
+	assert.Equal(t, "anonymous", version.Caller)
+	assert.Equal(t, testName, version.Namespace)
+	assert.Equal(t, testName, version.Entity)
+	assert.JSONEq(t, `{"version": "v1"}`, string(version.Old))
+	assert.JSONEq(t, `{"version": "v2", "ticket": "CHG-1"}`, string(version.New))
+	assert.False(t, version.Timestamp.Before(start))
+
+	query := url.Values{"action": {engine.AuditTargetVersion}, "limit": {"1"}}
+	require.NoError(t, httpclient.GetJSON(api.Audit(testName, testName)+"?"+query.Encode(), "", &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, version.ID, records[0].ID)
+
+	query = url.Values{"since": {engine.DefaultClock.Now().UTC().Add(time.Hour).Format(time.RFC3339)}}
+	require.NoError(t, httpclient.GetJSON(api.Audit(testName, testName)+"?"+query.Encode(), "", &records))
+	assert.Empty(t, records)
+	assert.Error(t, httpclient.GetJSON(api.Audit(testName, testName)+"?since=yesterday", "", &records))
+}
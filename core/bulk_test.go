@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrchestrateBulk(t *testing.T) {
+	const testName = "TestOrchestrateBulk"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetTargetVersion(testName, "entity0", engine.EntityTargetVersion{Version: "v1"}))
+	require.NoError(t, e.SetNamespaceQuota(testName, &engine.NamespaceQuota{MaxEntities: 1}))
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	var requests []*engine.BulkOrchestrateRequest
+	for i := 0; i < 2; i++ {
+		requests = append(requests, &engine.BulkOrchestrateRequest{
+			Namespace:    testName,
+			Entity:       fmt.Sprintf("entity%d", i),
+			ClientStates: []*engine.ClientState{{Name: "clientTarget0", Version: "v1"}},
+		})
+	}
+	var results []*engine.BulkOrchestrateResult
+	require.NoError(t, httpclient.PostJSON(api.OrchestrateBatch(), "", requests, &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "entity0", results[0].Entity)
+	assert.Empty(t, results[0].Error)
+	require.Len(t, results[0].ClientStates, 1)
+	assert.Equal(t, "v1", results[0].ClientStates[0].Version)
+
+	// entity1 exceeds quota of namespace without failing entity0
+	assert.Equal(t, "entity1", results[1].Entity)
+	assert.Contains(t, results[1].Error, engine.ErrQuotaExceeded.Error())
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+	assert.Empty(t, results[1].ClientStates)
+
+	err = httpclient.PostJSON(api.OrchestrateBatch(), "", []*engine.BulkOrchestrateRequest{{Namespace: testName}}, &results)
+	var statusErr *httpclient.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+}
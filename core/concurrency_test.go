@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueuedVersionsAPI(t *testing.T) {
+	const numTargets = 10
+	const namespaceName = "TestQueuedVersionsAPI"
+	const entityName = "NewEntity"
+
+	e, err := setupTestEngine(namespaceName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, namespaceName)
+
+	_, err = setupNamespace(e, namespaceName, entityName, numTargets)
+	require.NoError(t, err)
+
+	rolloutState, err := e.GetRolloutInfo(namespaceName, entityName)
+	require.NoError(t, err)
+	require.Equal(t, "v2", rolloutState.RollingVersion)
+
+	options := *rolloutState.Options
+	options.ConcurrencyPolicy = engine.ConcurrencyQueue
+	require.NoError(t, e.SetRolloutOptions(namespaceName, entityName, &options))
+	require.NoError(t, e.SetTargetVersion(namespaceName, entityName, engine.EntityTargetVersion{Version: "v3"}))
+
+	router := NewRouter(NewApp(WithEngine(e)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/orchestrate/"+namespaceName+"/"+entityName+"/version/queue", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"version":"v3"}]`, w.Body.String())
+
+	options.ConcurrencyPolicy = engine.ConcurrencyReject
+	require.NoError(t, e.SetRolloutOptions(namespaceName, entityName, &options))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/orchestrate/"+namespaceName+"/"+entityName+"/version", strings.NewReader(`{"version":"v4"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type registryTestController struct {
+	engine.NoOpEntityTargetController
+	Endpoint string   `json:"endpoint"`
+	Retries  int      `json:"retries,omitempty"`
+	Labels   []string `json:"labels"`
+	Ignored  string   `json:"-"`
+}
+
+func TestControllersRoute(t *testing.T) {
+	const testName = "TestControllersRoute"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	engine.RegisterTargetController("registry-test", func() engine.EntityTargetController { return &registryTestController{} })
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	var types []engine.ControllerType
+	require.NoError(t, httpclient.GetJSON(api.Controllers(), "", &types))
+
+	byName := make(map[string]engine.ControllerType)
+	for _, controllerType := range types {
+		byName[controllerType.Kind+"/"+controllerType.Name] = controllerType
+	}
+	for _, name := range []string{"target/noop", "target/web", "target/cohort", "target/grpc", "target/wasm", "target/slack", "target/consul", "monitoring/noop", "monitoring/web", "monitoring/prometheus"} {
+		assert.Contains(t, byName, name)
+	}
+
+	controllerType := byName["target/registry-test"]
+	assert.Equal(t, "*core.registryTestController", controllerType.GoType)
+	properties := controllerType.Schema["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"}, properties["endpoint"])
+	assert.Equal(t, map[string]any{"type": "integer"}, properties["retries"])
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, properties["labels"])
+	assert.NotContains(t, properties, "Ignored")
+
+	// embedded policy of web controller is flattened
+	webProperties := byName["target/web"].Schema["properties"].(map[string]any)
+	assert.Contains(t, webProperties, "selection")
+	assert.Contains(t, webProperties, "timeoutsecs")
+}
@@ -0,0 +1,5 @@
+// Package core serves the orchestrator engine with the REST API.
+//
+// App wraps engine.Engine with the routes served by package server, services embedding the
+// orchestrator without HTTP server use package engine instead.
+package core
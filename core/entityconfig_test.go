@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntityConfigAPI(t *testing.T) {
+	const testName = "TestEntityConfigAPI"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	assert.ErrorContains(t, httpclient.GetJSON(api.EntityConfig(testName, testName), "", &engine.EntityConfig{}), "404")
+
+	config := &engine.EntityConfig{Notifications: &engine.NotificationConfig{URL: "http://hooks/orchestrator", Secret: "secret"}}
+	stored := &engine.EntityConfig{}
+	require.NoError(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", "", config, stored))
+	assert.Equal(t, engine.RedactedSecret, stored.Notifications.Secret)
+
+	// redacted secret read back is kept
+	fetched := &engine.EntityConfig{}
+	require.NoError(t, httpclient.GetJSON(api.EntityConfig(testName, testName), "", fetched))
+	require.NoError(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", fetched.Version, fetched, stored))
+	assert.Equal(t, fetched.Version, stored.Version)
+	saved, err := e.GetEntityConfig(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", saved.Notifications.Secret)
+
+	assert.ErrorContains(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", "stale", fetched, nil), "412")
+	assert.ErrorContains(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", "", &engine.EntityConfig{Version: "stale"}, nil), "412")
+}
+
+func TestEntityConfigAPIRedactsControllerSecrets(t *testing.T) {
+	const testName = "TestEntityConfigAPIRedactsControllerSecrets"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	secrets := []string{"basic-password", "hmac-secret", "prometheus-token"}
+	config := &engine.EntityConfig{
+		TargetController: &engine.ControllerConfig{Type: "web", Settings: json.RawMessage(
+			`{"selection": "http://controller/select", "auth": {"username": "user", "password": "basic-password", "hmacsecret": "hmac-secret"}}`)},
+		MonitoringController: &engine.ControllerConfig{Type: "prometheus", Settings: json.RawMessage(
+			`{"address": "http://prometheus:9090", "query": "up", "threshold": 1, "bearertoken": "prometheus-token"}`)},
+	}
+	stored := &engine.EntityConfig{}
+	require.NoError(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", "", config, stored))
+
+	resp, err := http.Get(api.EntityConfig(testName, testName))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, err)
+	for _, secret := range secrets {
+		assert.NotContains(t, string(body), secret)
+	}
+	assert.Contains(t, string(body), engine.RedactedSecret)
+
+	// redacted secrets read back are kept
+	fetched := &engine.EntityConfig{}
+	require.NoError(t, json.Unmarshal(body, fetched))
+	require.NoError(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", fetched.Version, fetched, stored))
+	assert.Equal(t, fetched.Version, stored.Version)
+	saved, err := e.GetEntityConfig(testName, testName)
+	require.NoError(t, err)
+	for _, secret := range secrets {
+		assert.Contains(t, string(saved.TargetController.Settings)+string(saved.MonitoringController.Settings), secret)
+	}
+
+	// changed secrets replace stored
+	fetched.MonitoringController.Settings = json.RawMessage(`{"address": "http://prometheus:9090", "query": "up", "threshold": 1, "bearertoken": "rotated"}`)
+	require.NoError(t, httpclient.PutJSON(api.EntityConfig(testName, testName), "", "", fetched, stored))
+	saved, err = e.GetEntityConfig(testName, testName)
+	require.NoError(t, err)
+	assert.Contains(t, string(saved.MonitoringController.Settings), "rotated")
+	assert.Contains(t, string(saved.TargetController.Settings), "hmac-secret")
+}
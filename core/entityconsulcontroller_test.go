@@ -0,0 +1,76 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consulServer serves instances of web service, node2 fails its health check
+func consulServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/web", r.URL.Path)
+		assert.Equal(t, "consultoken", r.Header.Get("X-Consul-Token"))
+		assert.Equal(t, "dc1", r.URL.Query().Get("dc"))
+		_, _ = w.Write([]byte(`[
+			{"Node": {"Node": "node1", "Datacenter": "dc1"},
+			 "Service": {"ID": "web", "Service": "web", "Tags": ["primary"], "Meta": {"version": "v1", "rack": "r1"}},
+			 "Checks": [{"Name": "Serf Health Status", "Status": "passing"}, {"Name": "http", "Status": "passing"}]},
+			{"Node": {"Node": "node2", "Datacenter": "dc1"},
+			 "Service": {"ID": "web", "Service": "web", "Meta": {"version": "v1"}},
+			 "Checks": [{"Name": "http", "Status": "critical"}]},
+			{"Node": {"Node": "node3", "Datacenter": "dc1"},
+			 "Service": {"ID": "web-2", "Service": "web", "Meta": {"version": "v1", "zone": "b"}},
+			 "Checks": [{"Name": "http", "Status": "warning"}]}
+		]`))
+	}))
+}
+
+func TestConsulTargetControllerRollout(t *testing.T) {
+	const testName = "TestConsulTargetControllerRollout"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	consul := consulServer(t)
+	defer consul.Close()
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	controller := &engine.EntityConsulTargetController{Address: consul.URL, Token: "consultoken", Service: "web", Datacenter: "dc1"}
+	require.NoError(t, httpclient.PostJSON(api.ConsulTargetController(testName, testName), "", controller, nil))
+	assert.Error(t, httpclient.PostJSON(api.ConsulTargetController(testName, testName), "", &engine.EntityConsulTargetController{}, nil))
+
+	// targets are known without any of them reporting
+	_, err = e.Orchestrate(testName, testName, nil)
+	require.NoError(t, err)
+	state, err := e.GetClientState(testName, testName)
+	require.NoError(t, err)
+	require.Len(t, state, 3)
+
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	for i := 0; i < 2; i++ {
+		_, err = e.Orchestrate(testName, testName, nil)
+		require.NoError(t, err)
+	}
+
+	state, err = e.GetClientState(testName, testName)
+	require.NoError(t, err)
+	versions := make(map[string]string)
+	for _, clientTarget := range state {
+		versions[clientTarget.Name] = clientTarget.Version
+	}
+	assert.Equal(t, "v2", versions["node1"], versions)
+	assert.Equal(t, "v2", versions["node3:web-2"], versions)
+	assert.NotEqual(t, "v2", versions["node2"], versions)
+}
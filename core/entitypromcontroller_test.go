@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promServer returns value of query, no samples for unknown targets
+func promServer(values map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := values[r.URL.Query().Get("query")]
+		if !ok {
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"%s"]}]}}`, value)
+	}))
+}
+
+func TestPromMonitoringControllerRollout(t *testing.T) {
+	const testName = "TestPromMonitoringControllerRollout"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	prom := promServer(map[string]string{`errors{instance="clientTarget0"}`: "10"})
+	defer prom.Close()
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*engine.ClientState
+	for i := 0; i < 4; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	controller := &engine.EntityPromMonitoringController{Address: prom.URL, Query: `errors{instance="{{.Name}}"}`, Threshold: 1}
+	require.NoError(t, httpclient.PostJSON(api.PromMonitoringController(testName, testName), "", controller, nil))
+	assert.Error(t, httpclient.PostJSON(api.PromMonitoringController(testName, testName), "", &engine.EntityPromMonitoringController{}, nil))
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{
+		BatchPercent:        100,
+		SuccessPercent:      50,
+		SuccessTimeoutSecs:  3600,
+		DurationTimeoutSecs: 3600,
+	}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+
+	// first orchestrate promotes v2 to rolling version, second assigns it
+	for i := 0; i < 2; i++ {
+		clientTargets, err = e.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 4, countVersion(clientTargets, "v2"))
+
+	// clientTarget0 crosses threshold once it runs v2
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	state, err := e.GetClientState(testName, testName)
+	require.NoError(t, err)
+	for _, clientTarget := range state {
+		if clientTarget.Name == "clientTarget0" {
+			assert.True(t, clientTarget.IsError)
+			assert.Contains(t, clientTarget.Message, "crossing threshold")
+		} else {
+			assert.False(t, clientTarget.IsError, clientTarget.Name)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorKinds(t *testing.T) {
+	const testName = "TestErrorKinds"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+
+	_, err = e.GetRolloutInfo("unknown", testName)
+	assert.ErrorIs(t, err, engine.ErrNamespaceNotFound)
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+	var notFound *engine.NotFoundError
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "unknown", notFound.Name)
+
+	_, err = e.GetRolloutInfo(testName, "unknown")
+	assert.ErrorIs(t, err, engine.ErrEntityNotFound)
+	assert.NotErrorIs(t, err, engine.ErrNamespaceNotFound)
+	assert.ErrorIs(t, engine.ErrRolloutInProgress, engine.ErrVersionConflict)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	rolloutState := &engine.RolloutState{}
+	err = httpclient.GetJSON(api.RolloutInfo(testName, "unknown"), "", rolloutState)
+	assert.ErrorIs(t, err, httpclient.ErrNotFound)
+	var statusErr *httpclient.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Contains(t, statusErr.Message, "entity not found")
+
+	require.NoError(t, httpclient.PostJSON(api.Pause(testName, testName, ""), "", nil, nil))
+	err = httpclient.PostJSON(api.Pause(testName, testName, ""), "", nil, nil)
+	assert.ErrorIs(t, err, httpclient.ErrConflict)
+	assert.NotErrorIs(t, err, httpclient.ErrNotFound)
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceFreezeRoute(t *testing.T) {
+	const testName = "TestNamespaceFreezeRoute"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	// first rollout of entity is held too
+	require.NoError(t, httpclient.PostJSON(api.Freeze(testName), "", &engine.FreezeState{Frozen: true}, nil))
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*engine.ClientState{{Name: "clientTarget0", Version: "v0"}, {Name: "clientTarget1", Version: "v0"}}
+	assigned, err := e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 0, countVersion(assigned, "v1"))
+
+	freeze := &engine.FreezeState{}
+	require.NoError(t, httpclient.GetJSON(api.Freeze(testName), "", freeze))
+	assert.True(t, freeze.Frozen)
+
+	// other namespaces are not frozen
+	freeze, err = e.GetGlobalFreeze()
+	require.NoError(t, err)
+	assert.False(t, freeze.Frozen)
+
+	require.NoError(t, httpclient.PostJSON(api.Freeze(testName), "", &engine.FreezeState{}, nil))
+	assigned, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countVersion(assigned, "v1"))
+}
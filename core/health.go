@@ -0,0 +1,28 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+// healthz reports process is alive
+func (app *App) healthz(w http.ResponseWriter, r *http.Request) {
+	response.OK(w, "ok")
+}
+
+// readyz reports engine is ready to serve, store must be reachable
+func (app *App) readyz(w http.ResponseWriter, r *http.Request) {
+	if app.e == nil {
+		response.Error(w, http.StatusServiceUnavailable, engine.ErrEngineNotReady.Error())
+		return
+	}
+
+	if err := app.e.Ready(); err != nil {
+		app.requestLogger(r).Error().Err(err).Msg("Readiness check failed")
+		response.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	const testName = "TestHealthEndpoints"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	router := NewRouter(NewAppWithEngine(e))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	NewRouter(NewApp()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestShutdownDrainsAsyncOrchestrate(t *testing.T) {
+	const testName = "TestShutdownDrainsAsyncOrchestrate"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+
+	clientTargets, err := setupNamespace(e, testName, testName, 3)
+	require.NoError(t, err)
+
+	require.NoError(t, e.OrchestrateAsync(testName, testName, clientTargets))
+	require.NoError(t, e.Stop())
+
+	router := NewRouter(NewAppWithEngine(e))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetHeartbeat(t *testing.T) {
+	const numTargets = 3
+	const namespaceName = "TestTargetHeartbeat"
+	const entityName = "NewEntity"
+
+	e, err := setupTestEngine(namespaceName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, namespaceName)
+
+	clientTargets, err := setupNamespace(e, namespaceName, entityName, numTargets)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewRouter(NewApp(WithEngine(e))))
+	defer server.Close()
+
+	url := fmt.Sprintf("%s/v1/orchestrate/%s/%s/target/%s/heartbeat", server.URL, namespaceName, entityName, clientTargets[0].Name)
+	before := time.Now().UTC()
+	resp, err := http.Post(url, "application/json", nil)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	clientTarget := &engine.ClientState{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(clientTarget))
+	assert.Equal(t, clientTargets[0].Name, clientTarget.Name)
+	assert.False(t, clientTarget.LastSeen.Before(before))
+
+	_, err = e.Heartbeat(namespaceName, entityName, "", "unknownTarget")
+	assert.Error(t, err)
+}
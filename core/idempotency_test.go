@@ -0,0 +1,66 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	const testName = "TestIdempotencyKey"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	clock := &offsetClock{}
+	previous := engine.DefaultClock
+	engine.DefaultClock = clock
+	defer func() { engine.DefaultClock = previous }()
+
+	router := NewRouter(NewAppWithEngine(e))
+	post := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/orchestrate/"+testName+"/"+testName+"/version", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("retry-1", `{"version":"v1"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+
+	// version changed since, retry replays response without setting v1 again
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	replayed := post("retry-1", `{"version":"v1"}`)
+	assert.Equal(t, http.StatusOK, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, w.Body.String(), replayed.Body.String())
+	rolloutState, err := e.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rolloutState.TargetVersion)
+
+	records, err := e.GetAuditRecords(testName, testName, engine.AuditFilter{Action: engine.AuditTargetVersion}, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	w = post("retry-1", `{"version":"v3"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = post(strings.Repeat("k", engine.MaxIdempotencyKey+1), `{"version":"v3"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// expired responses are no longer replayed
+	clock.offset = 24 * time.Hour
+	w = post("retry-1", `{"version":"v3"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+}
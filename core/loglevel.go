@@ -0,0 +1,82 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+)
+
+// LogLevelState is request and response of runtime log level changes
+type LogLevelState struct {
+	// Level is zerolog level, e.g. debug, info, warn or error
+	Level string `json:"level"`
+	// DurationSecs reverts level after duration, 0 keeps level until it is changed again
+	DurationSecs int `json:"durationsecs,omitempty"`
+	// RevertAt is when level reverts to RevertLevel, absent if level is not temporary
+	RevertAt    *time.Time `json:"revertat,omitempty"`
+	RevertLevel string     `json:"revertlevel,omitempty"`
+}
+
+// logLevelRevert holds timer reverting a temporary log level, level reverts to level before the first of
+// consecutive temporary changes
+type logLevelRevert struct {
+	lock     sync.Mutex
+	timer    *time.Timer
+	revertAt time.Time
+	level    zerolog.Level
+}
+
+// LogLevel returns global log level and when it reverts if it is temporary
+func (app *App) LogLevel() *LogLevelState {
+	app.logLevel.lock.Lock()
+	defer app.logLevel.lock.Unlock()
+	return app.logLevelState()
+}
+
+// SetLogLevel changes global log level of all loggers at runtime without restarting,
+// level reverts after duration if it is positive
+func (app *App) SetLogLevel(level zerolog.Level, duration time.Duration) *LogLevelState {
+	app.logLevel.lock.Lock()
+	defer app.logLevel.lock.Unlock()
+
+	previous := zerolog.GlobalLevel()
+	if app.logLevel.timer != nil {
+		app.logLevel.timer.Stop()
+		app.logLevel.timer = nil
+		previous = app.logLevel.level
+	}
+
+	app.logger.WithLevel(zerolog.NoLevel).Str("Level", level.String()).Dur("Duration", duration).Msg("Setting log level")
+	zerolog.SetGlobalLevel(level)
+
+	if duration > 0 {
+		app.logLevel.level = previous
+		app.logLevel.revertAt = engine.DefaultClock.Now().UTC().Add(duration)
+		var timer *time.Timer
+		timer = time.AfterFunc(duration, func() {
+			app.logLevel.lock.Lock()
+			defer app.logLevel.lock.Unlock()
+			// timer was replaced by a later change
+			if app.logLevel.timer != timer {
+				return
+			}
+			app.logLevel.timer = nil
+			zerolog.SetGlobalLevel(previous)
+			app.logger.WithLevel(zerolog.NoLevel).Str("Level", previous.String()).Msg("Reverted log level")
+		})
+		app.logLevel.timer = timer
+	}
+	return app.logLevelState()
+}
+
+func (app *App) logLevelState() *LogLevelState {
+	state := &LogLevelState{Level: zerolog.GlobalLevel().String()}
+	if app.logLevel.timer != nil {
+		revertAt := app.logLevel.revertAt
+		state.RevertAt = &revertAt
+		state.RevertLevel = app.logLevel.level.String()
+	}
+	return state
+}
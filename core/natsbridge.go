@@ -0,0 +1,193 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultNATSSubjectPrefix of subjects bridged by NATSBridge
+	DefaultNATSSubjectPrefix = "orchestrator"
+	natsReportSubject        = "report"
+	natsDesiredSubject       = "desired"
+)
+
+// natsConn is subset of nats.Conn used by bridge
+type natsConn interface {
+	Subscribe(subject string, handler nats.MsgHandler) (*nats.Subscription, error)
+	Publish(subject string, data []byte) error
+}
+
+// NATSBridge lets agents without inbound HTTP connectivity orchestrate over NATS,
+// agents publish AgentMessage to <prefix>.<namespace>.<entity>.report and subscribe to
+// <prefix>.<namespace>.<entity>.desired for AgentAssignment of reported targets and versions assigned later,
+// reports sent as requests also receive assignment as reply
+type NATSBridge struct {
+	app         *App
+	conn        natsConn
+	prefix      string
+	logger      zerolog.Logger
+	sub         *nats.Subscription
+	unsubscribe func()
+	lock        sync.Mutex
+	// last version published by target
+	assigned map[string]string
+}
+
+// NewNATSBridge creates bridge between app engine and agents connected to NATS, empty prefix uses DefaultNATSSubjectPrefix
+func NewNATSBridge(app *App, conn *nats.Conn, prefix string) *NATSBridge {
+	return newNATSBridge(app, conn, prefix)
+}
+
+func newNATSBridge(app *App, conn natsConn, prefix string) *NATSBridge {
+	if prefix == "" {
+		prefix = DefaultNATSSubjectPrefix
+	}
+	return &NATSBridge{
+		app:      app,
+		conn:     conn,
+		prefix:   prefix,
+		logger:   app.logger.With().Str("Transport", "nats").Logger(),
+		assigned: make(map[string]string),
+	}
+}
+
+// ReportSubject returns subject agents publish AgentMessage to
+func (b *NATSBridge) ReportSubject(namespace, entity string) string {
+	return fmt.Sprintf("%s.%s.%s.%s", b.prefix, namespace, entity, natsReportSubject)
+}
+
+// DesiredSubject returns subject agents subscribe to for AgentAssignment
+func (b *NATSBridge) DesiredSubject(namespace, entity string) string {
+	return fmt.Sprintf("%s.%s.%s.%s", b.prefix, namespace, entity, natsDesiredSubject)
+}
+
+// parseReportSubject returns namespace and entity of report subject
+func (b *NATSBridge) parseReportSubject(subject string) (string, string, bool) {
+	tokens := strings.Split(strings.TrimPrefix(subject, b.prefix+"."), ".")
+	if len(tokens) != 3 || tokens[2] != natsReportSubject || tokens[0] == "" || tokens[1] == "" {
+		return "", "", false
+	}
+	return tokens[0], tokens[1], true
+}
+
+// Start subscribes to agent reports and publishes assignments, namespace and entity names must be valid subject tokens
+func (b *NATSBridge) Start() error {
+	sub, err := b.conn.Subscribe(b.ReportSubject("*", "*"), b.handleReport)
+	if err != nil {
+		return err
+	}
+	b.sub = sub
+	b.unsubscribe = b.app.e.Events().Subscribe(b.handleEvent)
+
+	b.logger.Info().Str("Subject", b.ReportSubject("*", "*")).Msg("NATS bridge started")
+	return nil
+}
+
+// Close stops bridging, connection is owned by caller
+func (b *NATSBridge) Close() error {
+	if b.unsubscribe != nil {
+		b.unsubscribe()
+	}
+	if b.sub != nil {
+		return b.sub.Unsubscribe()
+	}
+	return nil
+}
+
+func (b *NATSBridge) targetKey(namespace, entity, group, name string) string {
+	return namespace + "/" + entity + "/" + agentTargetKey(group, name)
+}
+
+// publish assignment to desired subject and reply subject if agent sent a request
+func (b *NATSBridge) publish(namespace, entity, reply string, assignment *AgentAssignment) error {
+	data, err := json.Marshal(assignment)
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	for _, clientTarget := range assignment.Targets {
+		b.assigned[b.targetKey(namespace, entity, clientTarget.Group, clientTarget.Name)] = clientTarget.Version
+	}
+	b.lock.Unlock()
+
+	if reply != "" {
+		if err := b.conn.Publish(reply, data); err != nil {
+			return err
+		}
+	}
+	if len(assignment.Targets) <= 0 {
+		return nil
+	}
+	return b.conn.Publish(b.DesiredSubject(namespace, entity), data)
+}
+
+// handleReport orchestrates reported targets, replies with assignments of reported targets only
+func (b *NATSBridge) handleReport(msg *nats.Msg) {
+	namespace, entity, ok := b.parseReportSubject(msg.Subject)
+	if !ok {
+		b.logger.Warn().Str("Subject", msg.Subject).Msg("Ignoring report on unexpected subject")
+		return
+	}
+	logger := b.logger.With().Str("Namespace", namespace).Str("Entity", entity).Logger()
+
+	assignment := b.orchestrate(namespace, entity, msg.Data)
+	if err := b.publish(namespace, entity, msg.Reply, assignment); err != nil {
+		logger.Error().Err(err).Msg("failed to publish assignment")
+	}
+}
+
+func (b *NATSBridge) orchestrate(namespace, entity string, data []byte) *AgentAssignment {
+	if b.app.ReadOnly() {
+		return &AgentAssignment{Error: engine.ErrReadOnly.Error()}
+	}
+
+	message := &AgentMessage{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return &AgentAssignment{Error: err.Error()}
+	}
+
+	reported := make(map[string]bool, len(message.Targets))
+	for _, clientTarget := range message.Targets {
+		reported[agentTargetKey(clientTarget.Group, clientTarget.Name)] = true
+	}
+
+	clientTargets, err := b.app.e.Orchestrate(namespace, entity, message.Targets)
+	if err != nil {
+		return &AgentAssignment{Error: err.Error()}
+	}
+
+	assignment := &AgentAssignment{}
+	for _, clientTarget := range clientTargets {
+		if reported[agentTargetKey(clientTarget.Group, clientTarget.Name)] {
+			assignment.Targets = append(assignment.Targets, clientTarget)
+		}
+	}
+	return assignment
+}
+
+// handleEvent pushes versions assigned outside of agent reports, like orchestrate over HTTP or rollback
+func (b *NATSBridge) handleEvent(event engine.Event) {
+	if event.Type != engine.EventTargetUpdated || event.State == nil {
+		return
+	}
+
+	b.lock.Lock()
+	version, ok := b.assigned[b.targetKey(event.Namespace, event.Entity, event.State.Group, event.State.Name)]
+	b.lock.Unlock()
+	// only targets reported over NATS are pushed, and only when assigned version changed
+	if !ok || version == event.State.Version {
+		return
+	}
+
+	if err := b.publish(event.Namespace, event.Entity, "", &AgentAssignment{Targets: []*engine.ClientState{event.State}}); err != nil {
+		b.logger.Error().Err(err).Str("Namespace", event.Namespace).Str("Entity", event.Entity).Msg("failed to push assignment")
+	}
+}
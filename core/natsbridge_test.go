@@ -0,0 +1,116 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type natsPublication struct {
+	subject    string
+	assignment AgentAssignment
+}
+
+// fakeNATSConn records subscriptions and publications without a NATS server
+type fakeNATSConn struct {
+	lock         sync.Mutex
+	subject      string
+	handler      nats.MsgHandler
+	publications []natsPublication
+}
+
+func (c *fakeNATSConn) Subscribe(subject string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	c.subject = subject
+	c.handler = handler
+	return nil, nil
+}
+
+func (c *fakeNATSConn) Publish(subject string, data []byte) error {
+	publication := natsPublication{subject: subject}
+	if err := json.Unmarshal(data, &publication.assignment); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.publications = append(c.publications, publication)
+	return nil
+}
+
+func (c *fakeNATSConn) published() []natsPublication {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]natsPublication{}, c.publications...)
+}
+
+func (c *fakeNATSConn) report(t *testing.T, subject, reply string, message *AgentMessage) {
+	data, err := json.Marshal(message)
+	require.NoError(t, err)
+	c.handler(&nats.Msg{Subject: subject, Reply: reply, Data: data})
+}
+
+func TestNATSBridge(t *testing.T) {
+	const testName = "TestNATSBridge"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+
+	var clientTargets []*engine.ClientState
+	for i := 0; i < 4; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	conn := &fakeNATSConn{}
+	bridge := newNATSBridge(NewAppWithEngine(e), conn, "")
+	require.NoError(t, bridge.Start())
+	defer func() {
+		assert.NoError(t, bridge.Close())
+	}()
+	assert.Equal(t, "orchestrator.*.*.report", conn.subject)
+
+	// agent owns first two targets, report sent as request gets reply and desired state
+	conn.report(t, bridge.ReportSubject(testName, testName), "_INBOX.agent", &AgentMessage{Targets: clientTargets[:2]})
+
+	published := conn.published()
+	require.Len(t, published, 2)
+	assert.Equal(t, "_INBOX.agent", published[0].subject)
+	assert.Equal(t, bridge.DesiredSubject(testName, testName), published[1].subject)
+	assert.Empty(t, published[0].assignment.Error)
+	require.Len(t, published[0].assignment.Targets, 2)
+	assert.Equal(t, "v1", published[0].assignment.Targets[0].Version)
+
+	// new version assigned through other orchestrate calls gets pushed to desired subject
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	for i := 0; i < 2; i++ {
+		_, err = e.Orchestrate(testName, testName, clientTargets[2:])
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		for _, publication := range conn.published()[2:] {
+			if publication.subject == bridge.DesiredSubject(testName, testName) &&
+				len(publication.assignment.Targets) == 1 && publication.assignment.Targets[0].Version == "v2" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// unexpected subjects and invalid reports
+	conn.report(t, "orchestrator.status", "", &AgentMessage{})
+	conn.handler(&nats.Msg{Subject: bridge.ReportSubject(testName, testName), Reply: "_INBOX.bad", Data: []byte("{")})
+	published = conn.published()
+	assert.Equal(t, "_INBOX.bad", published[len(published)-1].subject)
+	assert.NotEmpty(t, published[len(published)-1].assignment.Error)
+}
@@ -0,0 +1,332 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/redact"
+	"github.com/nixmade/orchestrator/response"
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	// swaggerUIVersion of swagger-ui-dist loaded by docs page
+	swaggerUIVersion = "5.17.14"
+)
+
+// apiOperation documents a route, request and response are values of the types encoded in bodies
+type apiOperation struct {
+	id       string
+	summary  string
+	request  any
+	response any
+	query    []string
+	// headers are request header parameters
+	headers []string
+	// contentType of response if not json
+	contentType string
+}
+
+// statusMessage is body of responses without data and of errors
+type statusMessage struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+var (
+	clientStates []*engine.ClientState
+	bulkRequests []*engine.BulkOrchestrateRequest
+	bulkResults  []*engine.BulkOrchestrateResult
+	groupQuery   = []string{"group"}
+	pageQuery    = []string{"offset", "limit"}
+	statusQuery  = []string{"cursor", "limit", "version", "error", "state"}
+	auditQuery   = []string{"since", "until", "action", "offset", "limit"}
+)
+
+// apiOperations documents routes by method and pattern, routes missing here are documented
+// with path parameters only
+var apiOperations = map[string]apiOperation{
+	"GET /healthz": {summary: "Liveness of server"},
+	"GET /readyz":  {summary: "Readiness of server and its store"},
+
+	"POST /v1/orchestrate/{namespace}/{entity}":                         {summary: "Report state of targets and return their assigned versions", request: clientStates, response: clientStates},
+	"POST /v1/orchestrate/batch":                                        {summary: "Report state of targets of many entities and return their assigned versions", request: bulkRequests, response: bulkResults},
+	"POST /v1/orchestrate/{namespace}/{entity}/version":                 {summary: "Set target version, force=true skips concurrency policy and downgrade protection", request: engine.EntityTargetVersion{}, headers: idempotencyHeaders, query: []string{"force"}},
+	"POST /v1/orchestrate/{namespace}/{entity}/options":                 {summary: "Set rollout options, validate=true only checks them", request: engine.RolloutOptions{}, headers: idempotencyHeaders, query: []string{"validate"}},
+	"POST /v1/orchestrate/{namespace}/{entity}/rollout/simulate":        {summary: "Simulate batches of rollout with proposed options, nothing is persisted", request: engine.RolloutSimulationRequest{}, response: engine.RolloutSimulation{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/controller":       {summary: "Set web target controller", request: engine.EntityWebTargetController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/cohort":           {summary: "Set hash cohort target controller", request: engine.HashCohortTargetController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/grpc":             {summary: "Set grpc target controller", request: engine.EntityGrpcTargetController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/wasm":             {summary: "Set wasm target controller", request: engine.EntityWasmTargetController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/slack":            {summary: "Set slack approval controller", request: engine.EntitySlackApprovalController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/consul":           {summary: "Set consul target controller", request: engine.EntityConsulTargetController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/monitoring/controller":   {summary: "Set web monitoring controller", request: engine.EntityWebMonitoringController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/monitoring/prometheus":   {summary: "Set prometheus monitoring controller", request: engine.EntityPromMonitoringController{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/status":                  {id: "reportStatus", summary: "Report state of targets without assigning versions", request: clientStates, headers: idempotencyHeaders},
+	"POST /v1/orchestrate/{namespace}/{entity}/quarantine/release":      {summary: "Release quarantined target", request: engine.ClientState{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/{name}/heartbeat": {summary: "Record heartbeat of target", response: engine.ClientState{}, query: groupQuery},
+	"POST /v1/orchestrate/{namespace}/{entity}/target/{name}/pin":       {summary: "Pin target to a version", request: engine.TargetPin{}, response: engine.ClientState{}, query: groupQuery},
+	"POST /v1/orchestrate/{namespace}/{entity}/pause":                   {summary: "Pause rollout of entity or group", query: groupQuery},
+	"POST /v1/orchestrate/{namespace}/{entity}/resume":                  {summary: "Resume rollout of entity or group", query: groupQuery},
+	"POST /v1/orchestrate/{namespace}/{entity}/lastknowngood":           {summary: "Pin last known good version to a version of its history", request: engine.VersionOverride{}, response: engine.RolloutState{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/lastknownbad":            {summary: "Mark version last known bad", request: engine.VersionOverride{}, response: engine.RolloutState{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/notifications":           {summary: "Set webhook notifications", request: engine.NotificationConfig{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/schedule":                {summary: "Set rollout schedule", request: engine.RolloutSchedule{}, response: engine.ScheduleStatus{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/split":                   {summary: "Set version split", request: VersionSplitRequest{}, response: engine.VersionSplit{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/analysis":                {summary: "Set canary analysis", request: engine.AnalysisConfig{}, response: engine.AnalysisConfig{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/registrywatch":           {summary: "Set registry watch", request: engine.RegistryWatch{}, response: engine.RegistryWatch{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/registrywatch/approve":   {summary: "Approve tag pending in registry watch", request: engine.RegistryTagApproval{}},
+	"POST /v1/orchestrate/namespace/{namespace}/slack":                  {summary: "Set slack notifications of namespace", request: engine.SlackConfig{}},
+	"POST /v1/orchestrate/namespace/{namespace}/quota":                  {summary: "Set quota of namespace", request: engine.NamespaceQuota{}},
+	"POST /v1/orchestrate/namespace/{namespace}/redaction":              {summary: "Set log redaction rules of namespace", request: redact.Rules{}},
+	"POST /v1/orchestrate/namespace/{namespace}/grouprules":             {summary: "Set group assignment rules", request: engine.GroupRules{}},
+	"POST /v1/orchestrate/namespace/{namespace}/dependencies":           {summary: "Set entity dependencies", request: engine.EntityDependencies{}, response: engine.DependencyGraph{}},
+	"POST /v1/orchestrate/namespace/{namespace}/freeze":                 {summary: "Set change freeze of namespace", request: engine.FreezeState{}, response: engine.FreezeState{}},
+	"POST /v1/orchestrate/{namespace}/{entity}/slack":                   {summary: "Set slack notifications", request: engine.SlackConfig{}},
+	"PUT /v1/orchestrate/{namespace}/{entity}/config":                   {summary: "Replace declarative entity config", request: engine.EntityConfig{}, response: engine.EntityConfig{}},
+	"DELETE /v1/orchestrate/{namespace}":                                {summary: "Delete namespace and its entities"},
+	"DELETE /v1/orchestrate/{namespace}/{entity}":                       {summary: "Delete entity"},
+	"DELETE /v1/orchestrate/{namespace}/{entity}/target/{name}":         {summary: "Delete target", query: groupQuery},
+	"DELETE /v1/orchestrate/{namespace}/{entity}/target/{name}/pin":     {summary: "Unpin target", response: engine.ClientState{}, query: groupQuery},
+	"DELETE /v1/orchestrate/{namespace}/{entity}/lastknownbad":          {summary: "Clear last known bad version", response: engine.RolloutState{}},
+	"DELETE /v1/orchestrate/{namespace}/{entity}/analysis":              {summary: "Delete canary analysis"},
+	"DELETE /v1/orchestrate/{namespace}/{entity}/registrywatch":         {summary: "Delete registry watch"},
+	"GET /v1/orchestrate/namespaces":                                    {summary: "List namespaces", response: []string{}},
+	"GET /v1/orchestrate/controllers":                                   {summary: "List registered controllers", response: []engine.ControllerType{}},
+	"GET /v1/orchestrate/{namespace}/entities":                          {summary: "List entities of namespace", response: []string{}},
+	"GET /v1/orchestrate/namespace/{namespace}/quota":                   {summary: "Get quota usage of namespace", response: engine.QuotaUsage{}},
+	"GET /v1/orchestrate/namespace/{namespace}/grouprules":              {summary: "Get group assignment rules", response: engine.GroupRules{}},
+	"GET /v1/orchestrate/namespace/{namespace}/dependencies":            {summary: "Get entity dependency graph and rollout order", response: engine.DependencyGraph{}},
+	"GET /v1/orchestrate/namespace/{namespace}/freeze":                  {summary: "Get change freeze of namespace", response: engine.FreezeState{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/config":                   {summary: "Get declarative entity config", response: engine.EntityConfig{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/rollout":                  {summary: "Get rollout state", response: engine.RolloutState{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/progress":                 {summary: "Get rollout progress and stall reason", response: engine.RolloutProgress{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/version/queue":            {summary: "List queued target versions", response: []engine.EntityTargetVersion{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/schedule":                 {summary: "Get rollout schedule", response: engine.ScheduleStatus{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/split":                    {summary: "Get version split", response: engine.VersionSplit{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/analysis":                 {summary: "Get canary analysis", response: engine.AnalysisConfig{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/analysis/runs":            {summary: "List canary analysis runs", response: []*engine.AnalysisRun{}, query: pageQuery},
+	"GET /v1/orchestrate/{namespace}/{entity}/registrywatch":            {summary: "Get registry watch", response: engine.RegistryWatch{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/audit":                    {summary: "List audit records of entity", response: []*engine.AuditRecord{}, query: auditQuery},
+	"GET /v1/orchestrate/{namespace}/audit":                             {summary: "List audit records of namespace level calls", response: []*engine.AuditRecord{}, query: auditQuery},
+	"GET /v1/orchestrate/{namespace}/{entity}/rollouts":                 {summary: "List rollout history", response: []*engine.RolloutHistory{}, query: pageQuery},
+	"GET /v1/orchestrate/{namespace}/{entity}/snapshots":                {summary: "List fleet snapshots", response: []*engine.FleetSnapshot{}, query: []string{"sincesecs"}},
+	"GET /v1/orchestrate/{namespace}/{entity}/quarantine":               {summary: "List quarantined targets", response: []*engine.EntityTarget{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/target/{name}/events":     {summary: "List events of target", response: []*engine.TargetEvent{}, query: []string{"group", "offset", "limit"}},
+	"GET /v1/orchestrate/{namespace}/{entity}/targets":                  {id: "getTargets", summary: "List targets", response: clientStates, query: statusQuery},
+	"GET /v1/orchestrate/{namespace}/{entity}/status":                   {summary: "List targets, one per line with Accept: application/x-ndjson", response: clientStates, query: statusQuery},
+	"GET /v1/orchestrate/{namespace}/{entity}/status/stream":            {summary: "Stream target updates as server sent events", contentType: "text/event-stream"},
+	"GET /v1/orchestrate/{namespace}/{entity}/agent":                    {summary: "Websocket of long lived agents", request: AgentMessage{}, response: AgentAssignment{}},
+	"GET /v1/orchestrate/{namespace}/{entity}/{group}/status":           {summary: "List targets of group, one per line with Accept: application/x-ndjson", response: clientStates, query: statusQuery},
+	"POST /v1/slack/interactions":                                       {summary: "Slack interactivity callback"},
+	"GET /v1/admin/readonly":                                            {summary: "Get read-only mode", response: ReadOnlyState{}},
+	"POST /v1/admin/readonly":                                           {summary: "Set read-only mode", request: ReadOnlyState{}, response: ReadOnlyState{}},
+	"GET /v1/admin/scans":                                               {summary: "List store scans in progress", response: []store.ScanInfo{}, query: []string{"minagesecs"}},
+	"POST /v1/admin/scans/{id}/cancel":                                  {summary: "Cancel store scan"},
+	"GET /v1/admin/leader":                                              {summary: "Get leadership of replica", response: LeaderState{}},
+	"GET /v1/admin/freeze":                                              {summary: "Get global change freeze", response: engine.FreezeState{}},
+	"POST /v1/admin/freeze":                                             {summary: "Set global change freeze", request: engine.FreezeState{}, response: engine.FreezeState{}},
+	"GET /v1/admin/loglevel":                                            {summary: "Get log level", response: LogLevelState{}},
+	"PUT /v1/admin/loglevel":                                            {summary: "Set log level, temporarily if durationsecs is set", request: LogLevelState{}, response: LogLevelState{}},
+	"GET /openapi":                                                      {summary: "OpenAPI specification of server"},
+	"GET /docs":                                                         {summary: "Swagger UI of OpenAPI specification", contentType: "text/html"},
+}
+
+// idempotencyHeaders are headers of routes replaying responses of retries
+var idempotencyHeaders = []string{IdempotencyKeyHeader}
+
+// integerQuery are query parameters parsed as integers, others are strings
+var integerQuery = map[string]bool{"offset": true, "limit": true, "sincesecs": true, "minagesecs": true}
+
+// booleanQuery are query parameters parsed as booleans
+var booleanQuery = map[string]bool{"error": true, "validate": true, "force": true}
+
+// undocumentedRoutes are not part of the api
+var undocumentedRoutes = []string{"/metrics", "/orchestrator/profiler"}
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// handlerName returns name of method handling route, e.g. getClientState
+func handlerName(handler http.Handler) string {
+	if chain, ok := handler.(*chi.ChainHandler); ok {
+		handler = chain.Endpoint
+	}
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+	return name
+}
+
+func jsonContent(value any, components map[string]any) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": engine.TypeSchema(reflect.TypeOf(value), components)},
+	}
+}
+
+// apiOperationSpec returns OpenAPI operation of route
+func apiOperationSpec(method, pattern, handler string, components map[string]any) map[string]any {
+	documented := apiOperations[method+" "+pattern]
+	id := documented.id
+	if id == "" {
+		id = handler
+	}
+	operation := map[string]any{"operationId": id}
+	if documented.summary != "" {
+		operation["summary"] = documented.summary
+	}
+	switch {
+	case strings.HasPrefix(pattern, "/v1/orchestrate/"):
+		operation["tags"] = []string{"orchestrate"}
+	case strings.HasPrefix(pattern, "/v1/admin/"):
+		operation["tags"] = []string{"admin"}
+	}
+
+	var parameters []map[string]any
+	for _, match := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		parameters = append(parameters, map[string]any{"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	for _, name := range documented.query {
+		schemaType := "string"
+		if integerQuery[name] {
+			schemaType = "integer"
+		} else if booleanQuery[name] {
+			schemaType = "boolean"
+		}
+		parameters = append(parameters, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": schemaType}})
+	}
+	for _, name := range documented.headers {
+		parameters = append(parameters, map[string]any{"name": name, "in": "header", "schema": map[string]any{"type": "string"}})
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if documented.request != nil && method != http.MethodGet {
+		operation["requestBody"] = map[string]any{"required": true, "content": jsonContent(documented.request, components)}
+	}
+
+	ok := map[string]any{"description": "OK"}
+	switch {
+	case documented.contentType != "":
+		ok["content"] = map[string]any{documented.contentType: map[string]any{}}
+	case documented.response != nil:
+		ok["content"] = jsonContent(documented.response, components)
+	default:
+		ok["content"] = jsonContent(statusMessage{}, components)
+	}
+	operation["responses"] = map[string]any{
+		"200":     ok,
+		"default": map[string]any{"description": "Error", "content": jsonContent(statusMessage{}, components)},
+	}
+	if strings.HasPrefix(pattern, "/v1/orchestrate/") || strings.HasPrefix(pattern, "/v1/admin/") {
+		operation["security"] = []map[string][]string{{"bearerAuth": {}}}
+	}
+	return operation
+}
+
+// OpenAPISpec generates OpenAPI specification of all routes of router
+func OpenAPISpec(router chi.Routes) (map[string]any, error) {
+	components := make(map[string]any)
+	paths := make(map[string]map[string]any)
+	operationIds := make(map[string]int)
+
+	err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/*")
+		for _, undocumented := range undocumentedRoutes {
+			if strings.HasPrefix(route, undocumented) {
+				return nil
+			}
+		}
+		if strings.Contains(route, "*") || route == "" {
+			return nil
+		}
+
+		operation := apiOperationSpec(method, route, handlerName(handler), components)
+		id := operation["operationId"].(string)
+		if operationIds[id]++; operationIds[id] > 1 {
+			operation["operationId"] = fmt.Sprintf("%s%d", id, operationIds[id])
+		}
+
+		specPath := pathParam.ReplaceAllString(route, "{$1}")
+		if paths[specPath] == nil {
+			paths[specPath] = make(map[string]any)
+		}
+		paths[specPath][strings.ToLower(method)] = operation
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []map[string]string{
+		{"name": "admin", "description": "Server administration"},
+		{"name": "orchestrate", "description": "Rollout orchestration of namespaces and entities"},
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "Orchestrator API",
+			"description": "Rollout orchestration of versions across targets",
+			"version":     "v1",
+		},
+		"tags":  tags,
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}, nil
+}
+
+// openAPI serves OpenAPI specification of router, generated once on first request
+// since routes do not change after router was created
+func (app *App) openAPI(router chi.Routes) http.HandlerFunc {
+	var (
+		once sync.Once
+		spec map[string]any
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			spec, err = OpenAPISpec(router)
+		})
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response.JSON(w, http.StatusOK, spec)
+	}
+}
+
+// swaggerUI serves Swagger UI of OpenAPI specification, assets are loaded from unpkg
+func (app *App) swaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = fmt.Fprintf(w, swaggerUIPage, swaggerUIVersion)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Orchestrator API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
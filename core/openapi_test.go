@@ -0,0 +1,75 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	router := NewRouter(NewApp())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, openAPIVersion, spec.OpenAPI)
+
+	orchestrate := spec.Paths["/v1/orchestrate/{namespace}/{entity}"]["post"]
+	require.NotNil(t, orchestrate)
+	assert.Equal(t, "orchestrate", orchestrate["operationId"])
+	assert.Contains(t, orchestrate, "requestBody")
+	assert.Contains(t, orchestrate, "security")
+	assert.Contains(t, spec.Paths["/v1/orchestrate/{namespace}/{entity}/version"], "post")
+	assert.Contains(t, spec.Paths["/v1/orchestrate/{namespace}/{entity}/config"], "put")
+	assert.Contains(t, spec.Paths["/v1/orchestrate/{namespace}/{entity}/status"], "get")
+	assert.Contains(t, spec.Paths["/v1/admin/readonly"], "post")
+	assert.NotContains(t, spec.Paths, "/metrics")
+	assert.Contains(t, spec.Components.Schemas, "ClientState")
+	assert.Contains(t, spec.Components.Schemas, "RolloutOptions")
+	assert.Contains(t, spec.Components.Schemas, "store.ScanInfo")
+
+	// operation ids are unique
+	ids := make(map[string]string)
+	for specPath, operations := range spec.Paths {
+		for method, operation := range operations {
+			id := operation["operationId"].(string)
+			assert.NotContains(t, ids, id, "%s %s", method, specPath)
+			ids[id] = specPath
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SwaggerUIBundle")
+}
+
+// every route is documented so spec stays in sync as routes are added
+func TestOpenAPIRoutesDocumented(t *testing.T) {
+	router := NewRouter(NewApp()).(chi.Routes)
+	err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		for _, undocumented := range undocumentedRoutes {
+			if strings.HasPrefix(route, undocumented) {
+				return nil
+			}
+		}
+		assert.Contains(t, apiOperations, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+}
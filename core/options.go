@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/metrics"
+	"github.com/nixmade/orchestrator/server"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+)
+
+// Option configures App created with NewApp, options are applied in order
+type Option func(*App)
+
+// WithConfig replaces configuration read from environment variables
+func WithConfig(cfg *config.Config) Option {
+	return func(app *App) {
+		app.config = cfg
+		app.configErr = nil
+	}
+}
+
+// WithStore uses store instead of opening configured store backend, app closes it on Delete
+func WithStore(s store.Store) Option {
+	return func(app *App) {
+		app.store = s
+	}
+}
+
+// WithLogger uses logger instead of logger of server creating the app
+func WithLogger(logger zerolog.Logger) Option {
+	return func(app *App) {
+		app.logger = logger
+		app.hasLogger = true
+	}
+}
+
+// WithEngine serves an existing engine using its logger, Create is not needed
+func WithEngine(e *engine.Engine) Option {
+	return func(app *App) {
+		app.e = e
+		app.logger = e.Logger()
+		app.hasLogger = true
+	}
+}
+
+// WithListenAddr sets host:port HTTP server listens on, overriding configured address and port
+func WithListenAddr(addr string) Option {
+	return func(app *App) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			app.configErr = fmt.Errorf("invalid listen address %q: %w", addr, err)
+			return
+		}
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			app.configErr = fmt.Errorf("invalid listen port %q: %w", port, err)
+			return
+		}
+		cfg := *app.Config()
+		cfg.Server.Address = host
+		cfg.Server.Port = portNum
+		app.config = &cfg
+	}
+}
+
+// WithMetrics serves registry on /metrics instead of default registry
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(app *App) {
+		app.metrics = registry
+	}
+}
+
+// WithAuth requires valid JWT bearer token for orchestrate and admin routes, nil disables authentication
+func WithAuth(auth *server.Authenticator) Option {
+	return func(app *App) {
+		app.auth = auth
+	}
+}
+
+// WithKeyProvider fetches store data key from provider instead of configured encryption key
+func WithKeyProvider(provider store.KeyProvider) Option {
+	return func(app *App) {
+		app.keyProvider = provider
+	}
+}
+
+// WithReadOnly starts app in read-only mode
+func WithReadOnly(readOnly bool) Option {
+	return func(app *App) {
+		app.readOnly.Store(readOnly)
+	}
+}
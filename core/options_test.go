@@ -0,0 +1,50 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/metrics"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppOptions(t *testing.T) {
+	app := NewApp(WithConfig(config.Default()), WithListenAddr("0.0.0.0:9095"), WithReadOnly(true))
+	assert.Equal(t, "0.0.0.0:9095", app.Config().ListenAddress())
+	assert.True(t, app.ReadOnly())
+
+	app = NewApp(WithConfig(config.Default()), WithListenAddr("9095"))
+	assert.Error(t, app.Create(zerolog.Nop()))
+
+	registry := metrics.NewRegistry()
+	registry.NewCounterVec("test_app_options_total", "counter of app options test").WithLabelValues().Inc()
+	w := httptest.NewRecorder()
+	NewRouter(NewApp(WithMetrics(registry))).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "test_app_options_total 1")
+	assert.NotContains(t, w.Body.String(), "orchestrator_store")
+}
+
+func TestAppWithStore(t *testing.T) {
+	const testName = "TestAppWithStore"
+	dbStore, err := store.NewBadgerDBStore("", "")
+	require.NoError(t, err)
+
+	app := NewApp(WithConfig(config.Default()), WithStore(dbStore), WithLogger(getLogger()))
+	require.NoError(t, app.Create(zerolog.Nop()))
+	defer func() {
+		assert.NoError(t, app.e.Stop())
+	}()
+	assert.Same(t, dbStore, app.store)
+
+	require.NoError(t, app.e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	rolloutState, err := app.e.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", rolloutState.TargetVersion)
+}
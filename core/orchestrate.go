@@ -1,11 +1,18 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
 	"github.com/nixmade/orchestrator/response"
+	"github.com/nixmade/orchestrator/server"
 )
 
 func (app *App) orchestrate(w http.ResponseWriter, r *http.Request) {
@@ -20,23 +27,50 @@ func (app *App) orchestrate(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 
 	if err := json.NewDecoder(r.Body).Decode(&clientTargets); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
-	clientTargets, err = app.e.Orchestrate(namespace, entity, clientTargets)
+	ctx, counts := engine.WithQuotaCounts(r.Context())
+	clientTargets, err = app.e.OrchestrateContext(ctx, namespace, entity, clientTargets)
 
+	app.setQuotaWarningHeader(w, namespace, counts)
 	if err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
 	response.JSON(w, http.StatusOK, clientTargets)
 }
 
+func (app *App) orchestrateBulk(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	var requests []*engine.BulkOrchestrateRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	results, err := app.e.OrchestrateBulk(r.Context(), requests)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
+
 func (app *App) reportCurrentStatus(w http.ResponseWriter, r *http.Request) {
 	var err error
 	defer func() {
@@ -49,33 +83,194 @@ func (app *App) reportCurrentStatus(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 
 	if err := json.NewDecoder(r.Body).Decode(&clientTargets); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
-	if err := app.e.OrchestrateAsync(namespace, entity, clientTargets); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+	err = app.e.OrchestrateAsync(namespace, entity, clientTargets)
+	app.setQuotaWarningHeader(w, namespace, nil)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
 	response.OK(w, "ok")
 }
 
+// NextCursorHeader is set on paged status responses with cursor of the next page, absent on last page
+const NextCursorHeader = "X-Next-Cursor"
+
+// writeClientStatePage writes page of client state when cursor or limit query parameters are set,
+// returns false to write full client state otherwise
+func (app *App) writeClientStatePage(w http.ResponseWriter, r *http.Request, group string) bool {
+	query := r.URL.Query()
+	if !query.Has("cursor") && !query.Has("limit") {
+		return false
+	}
+
+	limit, err := queryInt(r, "limit", 100)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return true
+	}
+
+	clientTargets, next, err := app.e.GetClientStatePageContext(r.Context(), chi.URLParam(r, "namespace"), chi.URLParam(r, "entity"), group, query.Get("cursor"), limit)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return true
+	}
+
+	if next != "" {
+		w.Header().Set(NextCursorHeader, next)
+	}
+	writeClientStates(w, r, clientTargets)
+	return true
+}
+
+// acceptsNDJSON is true if request prefers targets streamed one per line
+func acceptsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), server.NDJSONContentType)
+}
+
+// writeClientStateNDJSON streams targets of pages returned by stream one json value per line, flushing each page
+// so the whole array is never buffered. Errors before the first page are returned as json error response
+func (app *App) writeClientStateNDJSON(w http.ResponseWriter, r *http.Request, stream func(func([]*engine.ClientState) error) error) {
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	started := false
+
+	err := stream(func(clientTargets []*engine.ClientState) error {
+		if !started {
+			started = true
+			// large entities take longer than server write timeout
+			if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+				app.requestLogger(r).Debug().Err(err).Msg("failed to clear write deadline for ndjson status")
+			}
+			w.Header().Set("Content-Type", server.NDJSONContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+		for _, clientTarget := range clientTargets {
+			if err := encoder.Encode(clientTarget); err != nil {
+				return err
+			}
+		}
+		return controller.Flush()
+	})
+	if err == nil {
+		return
+	}
+	if !started {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	// status is already sent, truncated stream is all client sees
+	app.requestLogger(r).Debug().Err(err).Msg("failed to stream ndjson status")
+}
+
+// etagMatches is true if If-None-Match header lists etag, weak etags compare equal
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeClientStates responds with targets and ETag of their json, 304 without body if If-None-Match of
+// request has it so pollers skip transferring and decoding unchanged targets. ETag is weak since
+// response may be compressed
+func writeClientStates(w http.ResponseWriter, r *http.Request, clientTargets []*engine.ClientState) {
+	body, err := json.Marshal(clientTargets)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	hash := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(hash[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		return
+	}
+}
+
+// queryTargetFilter returns filter of version and error query parameters, nil if neither is set
+func queryTargetFilter(r *http.Request) (*engine.TargetFilter, error) {
+	query := r.URL.Query()
+	if !query.Has("version") && !query.Has("error") && !query.Has("state") {
+		return nil, nil
+	}
+
+	filter := &engine.TargetFilter{Version: query.Get("version"), State: engine.TargetState(query.Get("state"))}
+	if err := filter.State.Validate(); err != nil {
+		return nil, err
+	}
+	if query.Has("error") {
+		isError, err := strconv.ParseBool(query.Get("error"))
+		if err != nil {
+			return nil, err
+		}
+		filter.IsError = &isError
+	}
+	return filter, nil
+}
+
 func (app *App) getClientState(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	clientTargets, err := app.e.GetClientState(namespace, entity)
+	if app.writeClientStatePage(w, r, "") {
+		return
+	}
 
+	filter, err := queryTargetFilter(r)
 	if err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
-	response.JSON(w, http.StatusOK, clientTargets)
+	if acceptsNDJSON(r) {
+		app.writeClientStateNDJSON(w, r, func(fn func([]*engine.ClientState) error) error {
+			if filter == nil {
+				return app.e.StreamClientStateContext(r.Context(), namespace, entity, "", fn)
+			}
+			// filtered targets are looked up by index, they are not paged
+			clientTargets, err := app.e.GetFilteredClientStateContext(r.Context(), namespace, entity, *filter)
+			if err != nil {
+				return err
+			}
+			return fn(clientTargets)
+		})
+		return
+	}
+
+	var clientTargets []*engine.ClientState
+	if filter != nil {
+		clientTargets, err = app.e.GetFilteredClientStateContext(r.Context(), namespace, entity, *filter)
+	} else {
+		clientTargets, err = app.e.GetClientStateContext(r.Context(), namespace, entity)
+	}
+
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	writeClientStates(w, r, clientTargets)
 }
 
 func (app *App) getClientGroupState(w http.ResponseWriter, r *http.Request) {
@@ -83,21 +278,32 @@ func (app *App) getClientGroupState(w http.ResponseWriter, r *http.Request) {
 	entity := chi.URLParam(r, "entity")
 	group := chi.URLParam(r, "group")
 
-	clientTargets, err := app.e.GetClientGroupState(namespace, entity, group)
+	if app.writeClientStatePage(w, r, group) {
+		return
+	}
+
+	if acceptsNDJSON(r) {
+		app.writeClientStateNDJSON(w, r, func(fn func([]*engine.ClientState) error) error {
+			return app.e.StreamClientStateContext(r.Context(), namespace, entity, group, fn)
+		})
+		return
+	}
+
+	clientTargets, err := app.e.GetClientGroupStateContext(r.Context(), namespace, entity, group)
 
 	if err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
-	response.JSON(w, http.StatusOK, clientTargets)
+	writeClientStates(w, r, clientTargets)
 }
 
 func (app *App) getNamespaces(w http.ResponseWriter, r *http.Request) {
-	namespaces, err := app.e.GetNamespaces()
+	namespaces, err := app.e.GetNamespacesContext(r.Context())
 
 	if err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
@@ -107,10 +313,10 @@ func (app *App) getNamespaces(w http.ResponseWriter, r *http.Request) {
 func (app *App) getEntities(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 
-	entities, err := app.e.GetEntites(namespace)
+	entities, err := app.e.GetEntitesContext(r.Context(), namespace)
 
 	if err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
@@ -121,12 +327,74 @@ func (app *App) getRolloutInfo(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	rollout, err := app.e.GetRolloutInfo(namespace, entity)
+	rollout, err := app.e.GetRolloutInfoContext(r.Context(), namespace, entity)
 
 	if err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
 	response.JSON(w, http.StatusOK, rollout)
 }
+
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func (app *App) getRolloutHistory(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	limit, err := queryInt(r, "limit", 20)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	history, err := app.e.GetRolloutHistoryContext(r.Context(), namespace, entity, offset, limit)
+
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, history)
+}
+
+func (app *App) getSnapshots(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	sinceSecs, err := queryInt(r, "sincesecs", 24*60*60)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	snapshots, err := app.e.GetSnapshotsContext(r.Context(), namespace, entity, engine.DefaultClock.Now().UTC().Add(-time.Duration(sinceSecs)*time.Second))
+
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, snapshots)
+}
@@ -2,14 +2,20 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/nixmade/orchestrator/engine"
 	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/nixmade/orchestrator/response"
 	"github.com/nixmade/orchestrator/server"
 	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -49,11 +55,11 @@ func cleanupTestContext(t *testContext) {
 	}
 }
 
-func (t *testContext) setRolloutOptions(options *RolloutOptions) error {
+func (t *testContext) setRolloutOptions(options *engine.RolloutOptions) error {
 	return httpclient.PostJSON(t.RolloutOptions("namespace", "entity"), t.bearerToken, options, nil)
 }
 
-func (t *testContext) setTargetController(controller *EntityWebTargetController) error {
+func (t *testContext) setTargetController(controller *engine.EntityWebTargetController) error {
 	return httpclient.PostJSON(t.EntityTargetController("namespace", "entity"), t.bearerToken, controller, nil)
 }
 
@@ -62,17 +68,17 @@ func (t *testContext) setTargetController(controller *EntityWebTargetController)
 // }
 
 func (t *testContext) setTargetVersion(targetVersion string) error {
-	return httpclient.PostJSON(t.TargetVersion("namespace", "entity"), t.bearerToken, &EntityTargetVersion{Version: targetVersion}, nil)
+	return httpclient.PostJSON(t.TargetVersion("namespace", "entity"), t.bearerToken, &engine.EntityTargetVersion{Version: targetVersion}, nil)
 }
 
-func (t *testContext) postClientTargets(clientTargets []*ClientState) ([]*ClientState, error) {
+func (t *testContext) postClientTargets(clientTargets []*engine.ClientState) ([]*engine.ClientState, error) {
 	if err := httpclient.PostJSON(t.Orchestrate("namespace", "entity"), t.bearerToken, clientTargets, &clientTargets); err != nil {
 		return nil, err
 	}
 	return clientTargets, nil
 }
 
-func (t *testContext) postClientTargetsAsync(clientTargets []*ClientState) ([]*ClientState, error) {
+func (t *testContext) postClientTargetsAsync(clientTargets []*engine.ClientState) ([]*engine.ClientState, error) {
 	if err := httpclient.PostJSON(t.Status("namespace", "entity"), t.bearerToken, clientTargets, nil); err != nil {
 		return nil, err
 	}
@@ -84,14 +90,14 @@ func (t *testContext) postClientTargetsAsync(clientTargets []*ClientState) ([]*C
 	return clientTargets, nil
 }
 
-func (t *testContext) getClientTargetsAsync(groupName string) ([]*ClientState, error) {
+func (t *testContext) getClientTargetsAsync(groupName string) ([]*engine.ClientState, error) {
 	url := t.Status("namespace", "entity")
 
 	if groupName != "" {
 		url = t.GroupStatus("namespace", "entity", groupName)
 	}
 
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	if err := httpclient.GetJSON(url, t.bearerToken, &clientTargets); err != nil {
 		return nil, err
 	}
@@ -99,12 +105,12 @@ func (t *testContext) getClientTargetsAsync(groupName string) ([]*ClientState, e
 	return clientTargets, nil
 }
 
-func (t *testContext) establishLKG(numTargets int, version string) ([]*ClientState, error) {
+func (t *testContext) establishLKG(numTargets int, version string) ([]*engine.ClientState, error) {
 	return t.establishGroupLKG(numTargets, version, "")
 }
 
-func (t *testContext) establishGroupLKG(numTargets int, version, groupName string) ([]*ClientState, error) {
-	if err := t.setRolloutOptions(&RolloutOptions{
+func (t *testContext) establishGroupLKG(numTargets int, version, groupName string) ([]*engine.ClientState, error) {
+	if err := t.setRolloutOptions(&engine.RolloutOptions{
 		BatchPercent:        100,
 		SuccessPercent:      0,
 		SuccessTimeoutSecs:  0,
@@ -117,11 +123,11 @@ func (t *testContext) establishGroupLKG(numTargets int, version, groupName strin
 		return nil, err
 	}
 
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	var err error
 
 	for i := 0; i < numTargets; i++ {
-		clientTarget := &ClientState{
+		clientTarget := &engine.ClientState{
 			Name:    fmt.Sprintf("clientTarget%d", i),
 			Group:   groupName,
 			Version: version,
@@ -143,7 +149,7 @@ func (t *testContext) establishGroupLKG(numTargets int, version, groupName strin
 		}
 	}
 
-	options := DefaultRolloutOptions()
+	options := engine.DefaultRolloutOptions()
 	options.SuccessTimeoutSecs = 0
 
 	if err := t.setRolloutOptions(options); err != nil {
@@ -162,7 +168,7 @@ func TestRolloutOrchestrate(t *testing.T) {
 		return
 	}
 	// Establish LKG
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	clientTargets, err = tctx.establishLKG(10, "v1")
 
 	if err != nil {
@@ -223,7 +229,7 @@ func TestRollbackOrchestrate(t *testing.T) {
 	}
 
 	// Establish LKG
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	clientTargets, err = tctx.establishLKG(10, "v1")
 
 	if err != nil {
@@ -245,7 +251,7 @@ func TestRollbackOrchestrate(t *testing.T) {
 		return
 	}
 
-	options := DefaultRolloutOptions()
+	options := engine.DefaultRolloutOptions()
 	options.DurationTimeoutSecs = 3
 	options.SuccessTimeoutSecs = 2
 	if err := tctx.setRolloutOptions(options); err != nil {
@@ -297,7 +303,7 @@ func TestRolloutOrchestrateAsync(t *testing.T) {
 		return
 	}
 	// Establish LKG
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	clientTargets, err = tctx.establishLKG(10, "v1")
 
 	if err != nil {
@@ -357,7 +363,7 @@ func TestRolloutGroupOrchestrateAsync(t *testing.T) {
 		return
 	}
 	// Establish LKG
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	clientTargets, err = tctx.establishGroupLKG(10, "v1", "Group1")
 
 	if err != nil {
@@ -410,7 +416,7 @@ func TestRolloutGroupOrchestrateAsync(t *testing.T) {
 	clientTargets = nil
 
 	for i := 0; i < 10; i++ {
-		clientTarget := &ClientState{
+		clientTarget := &engine.ClientState{
 			Name:    fmt.Sprintf("clientTarget%d", i),
 			Group:   "Group2",
 			Version: "v1",
@@ -477,7 +483,7 @@ func TestRolloutOrchestrateSelection(t *testing.T) {
 		return
 	}
 	// Establish LKG
-	var clientTargets []*ClientState
+	var clientTargets []*engine.ClientState
 	clientTargets, err = tctx.establishLKG(10, "v1")
 
 	if err != nil {
@@ -520,7 +526,7 @@ func TestRolloutOrchestrateSelection(t *testing.T) {
 		}
 	}()
 
-	controller := &EntityWebTargetController{SelectionEndpoint: "http://127.0.0.1:8081/selection"}
+	controller := &engine.EntityWebTargetController{SelectionEndpoint: "http://127.0.0.1:8081/selection"}
 
 	if err := tctx.setTargetController(controller); err != nil {
 		t.Fatal(err)
@@ -555,3 +561,124 @@ func TestRolloutOrchestrateSelection(t *testing.T) {
 		return
 	}
 }
+
+func TestClientStatePages(t *testing.T) {
+	tctx, err := createTestContext("TestClientStatePages")
+	defer cleanupTestContext(tctx)
+	require.NoError(t, err)
+
+	_, err = tctx.establishLKG(10, "v1")
+	require.NoError(t, err)
+
+	var names []string
+	cursor := ""
+	for pages := 1; ; pages++ {
+		var clientTargets []*engine.ClientState
+		cursor, err = httpclient.GetJSONPage(tctx.StatusPage("namespace", "entity", cursor, 4), tctx.bearerToken, &clientTargets)
+		require.NoError(t, err)
+		for _, clientTarget := range clientTargets {
+			assert.Equal(t, "v1", clientTarget.Version)
+			names = append(names, clientTarget.Name)
+		}
+		if cursor == "" {
+			assert.Equal(t, 3, pages)
+			break
+		}
+		require.Len(t, clientTargets, 4)
+	}
+
+	var clientTargets []*engine.ClientState
+	require.NoError(t, httpclient.GetJSON(tctx.Status("namespace", "entity"), tctx.bearerToken, &clientTargets))
+	require.Len(t, names, len(clientTargets))
+	for i, clientTarget := range clientTargets {
+		assert.Equal(t, clientTarget.Name, names[i])
+	}
+}
+
+func TestClientStateNDJSON(t *testing.T) {
+	tctx, err := createTestContext("TestClientStateNDJSON")
+	defer cleanupTestContext(tctx)
+	require.NoError(t, err)
+
+	_, err = tctx.establishLKG(10, "v1")
+	require.NoError(t, err)
+
+	var clientTargets []*engine.ClientState
+	require.NoError(t, httpclient.GetJSON(tctx.Status("namespace", "entity"), tctx.bearerToken, &clientTargets))
+
+	var streamed []*engine.ClientState
+	require.NoError(t, httpclient.GetNDJSON(tctx.Status("namespace", "entity"), tctx.bearerToken, func(clientTarget *engine.ClientState) error {
+		streamed = append(streamed, clientTarget)
+		return nil
+	}))
+	require.Len(t, streamed, len(clientTargets))
+	for i, clientTarget := range clientTargets {
+		assert.Equal(t, clientTarget.Name, streamed[i].Name)
+		assert.Equal(t, "v1", streamed[i].Version)
+	}
+
+	err = httpclient.GetNDJSON(tctx.Status("namespace", "missing"), tctx.bearerToken, func(*engine.ClientState) error { return nil })
+	assert.ErrorIs(t, err, httpclient.ErrNotFound)
+}
+
+func TestClientStateETag(t *testing.T) {
+	tctx, err := createTestContext("TestClientStateETag")
+	defer cleanupTestContext(tctx)
+	require.NoError(t, err)
+
+	_, err = tctx.establishLKG(10, "v1")
+	require.NoError(t, err)
+
+	var clientTargets []*engine.ClientState
+	etag, err := httpclient.GetJSONIfNoneMatch(context.Background(), tctx.Status("namespace", "entity"), tctx.bearerToken, "", &clientTargets)
+	require.NoError(t, err)
+	require.NotEmpty(t, etag)
+	require.Len(t, clientTargets, 10)
+
+	var unchanged []*engine.ClientState
+	notModifiedETag, err := httpclient.GetJSONIfNoneMatch(context.Background(), tctx.Status("namespace", "entity"), tctx.bearerToken, etag, &unchanged)
+	require.ErrorIs(t, err, httpclient.ErrNotModified)
+	assert.Equal(t, etag, notModifiedETag)
+	assert.Empty(t, unchanged)
+
+	// reports change targets and their etag
+	clientTargets[0].Message = "restarted"
+	require.NoError(t, httpclient.PostJSON(tctx.Orchestrate("namespace", "entity"), tctx.bearerToken, clientTargets[:1], nil))
+	changedETag, err := httpclient.GetJSONIfNoneMatch(context.Background(), tctx.Status("namespace", "entity"), tctx.bearerToken, etag, &unchanged)
+	require.NoError(t, err)
+	assert.NotEqual(t, etag, changedETag)
+	assert.Len(t, unchanged, 10)
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`W/"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`"xyz", W/"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`*`, `W/"abc"`))
+	assert.False(t, etagMatches(``, `W/"abc"`))
+	assert.False(t, etagMatches(`"xyz"`, `W/"abc"`))
+}
+
+func selection(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			fmt.Printf("Failed to close body %v", err)
+		}
+	}()
+	var tsRequest engine.TargetSelectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&tsRequest); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var tsResponse engine.TargetSelectionResponse
+
+	//approve only 1 target if it exists
+	for _, targetName := range tsRequest.Targets {
+		if strings.ToLower(targetName.Name) == "clienttarget0" {
+			tsResponse.Targets = append(tsResponse.Targets, targetName)
+		}
+	}
+
+	response.JSON(w, http.StatusOK, tsResponse)
+}
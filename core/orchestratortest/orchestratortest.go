@@ -0,0 +1,235 @@
+// Package orchestratortest provides helpers to integration test agents and controllers
+// against an in-memory orchestrator engine and server
+package orchestratortest
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/core"
+	"github.com/nixmade/orchestrator/engine"
+)
+
+// FakeClock is a manually advanced clock
+type FakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewFakeClock creates fake clock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Advance moves clock forward by duration
+func (c *FakeClock) Advance(duration time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(duration)
+}
+
+// Harness runs in-memory engine served on a random local port, agents under test report to it with Client,
+// orchestrator time is controlled by Clock while harness is alive
+type Harness struct {
+	t      testing.TB
+	Engine *engine.Engine
+	Server *httptest.Server
+	Client *client.Client
+	Clock  *FakeClock
+}
+
+// New creates harness, engine, server and clock are cleaned up when test completes
+func New(t testing.TB) *Harness {
+	t.Helper()
+
+	config := engine.NewDefaultConfig()
+	config.ConsoleLogging = false
+	e, err := engine.NewOrchestratorEngine(config)
+	if err != nil {
+		t.Fatalf("failed to create orchestrator engine: %s", err)
+	}
+
+	server := httptest.NewServer(core.NewRouter(core.NewAppWithEngine(e)))
+
+	clock := NewFakeClock(time.Now())
+	previousClock := engine.DefaultClock
+	engine.DefaultClock = clock
+
+	t.Cleanup(func() {
+		engine.DefaultClock = previousClock
+		server.Close()
+		if err := e.ShutdownAndClose(); err != nil {
+			t.Errorf("failed to close orchestrator engine: %s", err)
+		}
+	})
+
+	orchestratorClient, err := client.New(server.URL)
+	if err != nil {
+		t.Fatalf("failed to create orchestrator client: %s", err)
+	}
+
+	return &Harness{
+		t:      t,
+		Engine: e,
+		Server: server,
+		Client: orchestratorClient,
+		Clock:  clock,
+	}
+}
+
+// Advance moves orchestrator time forward by duration
+func (h *Harness) Advance(duration time.Duration) {
+	h.Clock.Advance(duration)
+}
+
+// SeedFleet sets options, registers numTargets targets in group running version and marks version as last known good
+func (h *Harness) SeedFleet(namespace, entity, group, version string, numTargets int, options *engine.RolloutOptions) []*engine.ClientState {
+	h.t.Helper()
+
+	// seed without monitoring window, so version is immediately last known good
+	if err := h.Engine.SetRolloutOptions(namespace, entity, &engine.RolloutOptions{BatchPercent: 100}); err != nil {
+		h.t.Fatalf("failed to set rollout options: %s", err)
+	}
+	h.SetTargetVersion(namespace, entity, version)
+
+	var clientTargets []*engine.ClientState
+	for i := 0; i < numTargets; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{
+			Name:    fmt.Sprintf("%s-target%d", group, i),
+			Group:   group,
+			Version: version,
+			Message: "running successfully",
+		})
+	}
+
+	h.Orchestrate(namespace, entity, clientTargets)
+
+	if err := h.Engine.SetRolloutOptions(namespace, entity, options); err != nil {
+		h.t.Fatalf("failed to set rollout options: %s", err)
+	}
+	return clientTargets
+}
+
+// SetTargetVersion sets version to roll out
+func (h *Harness) SetTargetVersion(namespace, entity, version string) {
+	h.t.Helper()
+
+	if err := h.Engine.SetTargetVersion(namespace, entity, engine.EntityTargetVersion{Version: version}); err != nil {
+		h.t.Fatalf("failed to set target version: %s", err)
+	}
+}
+
+// Orchestrate reports targets and returns assigned state
+func (h *Harness) Orchestrate(namespace, entity string, clientTargets []*engine.ClientState) []*engine.ClientState {
+	h.t.Helper()
+
+	assigned, err := h.Engine.Orchestrate(namespace, entity, clientTargets)
+	if err != nil {
+		h.t.Fatalf("failed to orchestrate: %s", err)
+	}
+	return assigned
+}
+
+// ApplyAssignments simulates agents switching to assigned versions,
+// failing marks targets running that version as failed
+func ApplyAssignments(clientTargets, assigned []*engine.ClientState, failing string) {
+	versions := make(map[string]string, len(assigned))
+	for _, assignedTarget := range assigned {
+		versions[assignedTarget.Group+"/"+assignedTarget.Name] = assignedTarget.Version
+	}
+
+	for _, clientTarget := range clientTargets {
+		if version, ok := versions[clientTarget.Group+"/"+clientTarget.Name]; ok && version != "" {
+			clientTarget.Version = version
+		}
+		clientTarget.IsError = failing != "" && clientTarget.Version == failing
+		clientTarget.Message = "running successfully"
+		if clientTarget.IsError {
+			clientTarget.Message = "simulating failure"
+		}
+	}
+}
+
+// Run orchestrates up to steps times, applying assignments and advancing clock by interval after each step,
+// stops early once rolling version settles as last known good or bad and all targets run last known good
+func (h *Harness) Run(namespace, entity string, clientTargets []*engine.ClientState, failing string, steps int, interval time.Duration) *engine.RolloutState {
+	h.t.Helper()
+
+	for i := 0; i < steps; i++ {
+		assigned := h.Orchestrate(namespace, entity, clientTargets)
+		ApplyAssignments(clientTargets, assigned, failing)
+
+		state := h.RolloutState(namespace, entity)
+		if state.TargetVersion == state.RollingVersion &&
+			(state.RollingVersion == state.LastKnownGoodVersion || state.RollingVersion == state.LastKnownBadVersion) &&
+			allOnVersion(clientTargets, state.LastKnownGoodVersion) {
+			return state
+		}
+		h.Advance(interval)
+	}
+
+	return h.RolloutState(namespace, entity)
+}
+
+func allOnVersion(clientTargets []*engine.ClientState, version string) bool {
+	for _, clientTarget := range clientTargets {
+		if clientTarget.Version != version {
+			return false
+		}
+	}
+	return true
+}
+
+// RolloutState returns current rollout state
+func (h *Harness) RolloutState(namespace, entity string) *engine.RolloutState {
+	h.t.Helper()
+
+	state, err := h.Engine.GetRolloutInfo(namespace, entity)
+	if err != nil {
+		h.t.Fatalf("failed to get rollout info: %s", err)
+	}
+	return state
+}
+
+// AssertSucceeded fails test unless version is last known good
+func (h *Harness) AssertSucceeded(namespace, entity, version string) {
+	h.t.Helper()
+
+	if state := h.RolloutState(namespace, entity); state.LastKnownGoodVersion != version {
+		h.t.Errorf("expected %s to succeed, last known good %q, last known bad %q", version, state.LastKnownGoodVersion, state.LastKnownBadVersion)
+	}
+}
+
+// AssertRolledBack fails test unless version is last known bad
+func (h *Harness) AssertRolledBack(namespace, entity, version string) {
+	h.t.Helper()
+
+	if state := h.RolloutState(namespace, entity); state.LastKnownBadVersion != version {
+		h.t.Errorf("expected %s to roll back, last known good %q, last known bad %q", version, state.LastKnownGoodVersion, state.LastKnownBadVersion)
+	}
+}
+
+// AssertTargetVersions fails test unless all targets are assigned version
+func (h *Harness) AssertTargetVersions(namespace, entity, version string) {
+	h.t.Helper()
+
+	clientTargets, err := h.Engine.GetClientState(namespace, entity)
+	if err != nil {
+		h.t.Fatalf("failed to get client state: %s", err)
+	}
+	for _, clientTarget := range clientTargets {
+		if clientTarget.Version != version {
+			h.t.Errorf("expected target %s/%s on %s, assigned %s", clientTarget.Group, clientTarget.Name, version, clientTarget.Version)
+		}
+	}
+}
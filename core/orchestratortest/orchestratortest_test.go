@@ -0,0 +1,59 @@
+package orchestratortest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOptions() *engine.RolloutOptions {
+	return &engine.RolloutOptions{
+		BatchPercent:        50,
+		SuccessPercent:      100,
+		SuccessTimeoutSecs:  60,
+		DurationTimeoutSecs: 300,
+	}
+}
+
+func TestHarnessRolloutSucceeds(t *testing.T) {
+	h := New(t)
+
+	clientTargets := h.SeedFleet("ns", "app", "web", "v1", 4, testOptions())
+	h.AssertSucceeded("ns", "app", "v1")
+
+	h.SetTargetVersion("ns", "app", "v2")
+	state := h.Run("ns", "app", clientTargets, "", 20, 61*time.Second)
+	assert.Equal(t, "v2", state.RollingVersion)
+
+	h.AssertSucceeded("ns", "app", "v2")
+	h.AssertTargetVersions("ns", "app", "v2")
+
+	// server is reachable for agents under test
+	rolloutState, err := h.Client.RolloutInfo(context.Background(), "ns", "app")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rolloutState.LastKnownGoodVersion)
+}
+
+func TestHarnessRolloutFails(t *testing.T) {
+	h := New(t)
+
+	clientTargets := h.SeedFleet("ns", "app", "web", "v1", 4, testOptions())
+
+	h.SetTargetVersion("ns", "app", "v2")
+	h.Run("ns", "app", clientTargets, "v2", 20, 61*time.Second)
+
+	h.AssertRolledBack("ns", "app", "v2")
+	h.AssertSucceeded("ns", "app", "v1")
+	h.AssertTargetVersions("ns", "app", "v1")
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Now()
+	clock := NewFakeClock(start)
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+}
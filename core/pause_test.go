@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseEntityRoute(t *testing.T) {
+	const testName = "TestPauseEntityRoute"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*engine.ClientState{{Name: "clientTarget0", Version: "v1"}, {Name: "clientTarget1", Version: "v1"}}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100, SuccessPercent: 100, SuccessTimeoutSecs: 60, DurationTimeoutSecs: 120}))
+
+	resp, err := http.Post(api.Pause(testName, testName, ""), "application/json", nil)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	rolloutState := &engine.RolloutState{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(rolloutState))
+	assert.True(t, rolloutState.Paused)
+
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	var assigned []*engine.ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = e.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, countVersion(assigned, "v2"))
+
+	require.NoError(t, httpclient.PostJSON(api.Resume(testName, testName, ""), "", nil, nil))
+	assigned, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countVersion(assigned, "v2"))
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinTarget(t *testing.T) {
+	const testName = "TestPinTarget"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*engine.ClientState
+	for i := 0; i < 4; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	pinned := &engine.ClientState{}
+	require.NoError(t, httpclient.PostJSON(api.TargetPin(testName, testName, "", "clientTarget0"), "", &engine.TargetPin{Version: "v0", Reason: "customer hold"}, pinned))
+	assert.Equal(t, "v0", pinned.Version)
+	require.NotNil(t, pinned.Pin)
+	assert.Equal(t, "customer hold", pinned.Pin.Reason)
+	assert.Contains(t, pinned.Message, "customer hold")
+
+	_, err = e.PinTarget(testName, testName, "", "clientTarget1", &engine.TargetPin{Reason: "debugging"})
+	require.NoError(t, err)
+
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	var assigned []*engine.ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = e.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, countVersion(assigned, "v2"))
+	assert.Equal(t, "v0", findClientTarget(assigned, "clientTarget0").Version)
+	excluded := findClientTarget(assigned, "clientTarget1")
+	assert.Equal(t, "v1", excluded.Version)
+	require.NotNil(t, excluded.Pin)
+	assert.Equal(t, "debugging", excluded.Pin.Reason)
+
+	req, err := http.NewRequest(http.MethodDelete, api.TargetPin(testName, testName, "", "clientTarget1"), nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assigned, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 3, countVersion(assigned, "v2"))
+	assert.Nil(t, findClientTarget(assigned, "clientTarget1").Pin)
+
+	_, err = e.UnpinTarget(testName, testName, "", "clientTarget1")
+	assert.ErrorIs(t, err, engine.ErrTargetNotPinned)
+}
+
+func findClientTarget(clientTargets []*engine.ClientState, name string) *engine.ClientState {
+	for _, clientTarget := range clientTargets {
+		if clientTarget.Name == name {
+			return clientTarget
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWatchRoute(t *testing.T) {
+	const testName = "TestRegistryWatchRoute"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1.0.0"}))
+	watch := &engine.RegistryWatch{Registry: "http://registry", Repository: "myapp", Username: "user", Password: "pass", RequireApproval: true}
+	saved := &engine.RegistryWatch{}
+	require.NoError(t, httpclient.PostJSON(api.RegistryWatch(testName, testName), "", watch, saved))
+	assert.Equal(t, engine.RedactedSecret, saved.Password)
+	assert.Error(t, httpclient.PostJSON(api.RegistryWatch(testName, testName), "", &engine.RegistryWatch{}, nil))
+
+	fetched := &engine.RegistryWatch{}
+	require.NoError(t, httpclient.GetJSON(api.RegistryWatch(testName, testName), "", fetched))
+	assert.Equal(t, "myapp", fetched.Repository)
+	assert.Equal(t, engine.RedactedSecret, fetched.Password)
+
+	stored, err := e.GetRegistryWatch(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "pass", stored.Password)
+
+	// nothing is pending until registry is checked
+	err = httpclient.PostJSON(api.RegistryWatchApprove(testName, testName), "", &engine.RegistryTagApproval{Tag: "v1.1.0"}, nil)
+	var statusErr *httpclient.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.NotEqual(t, http.StatusOK, statusErr.StatusCode)
+
+	require.NoError(t, httpclient.Delete(api.RegistryWatch(testName, testName), ""))
+	assert.Error(t, httpclient.GetJSON(api.RegistryWatch(testName, testName), "", fetched))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/nixmade/orchestrator/server"
+	"github.com/nixmade/orchestrator/tracing"
 )
 
 // Route defines registration of different routes supported by app
@@ -16,8 +17,19 @@ type Route struct {
 // NewRouter registers multiple logged routes
 func NewRouter(app *App) http.Handler {
 	router := server.DefaultRouter()
+	router.Use(server.LogRequests(app.logger))
+	router.Use(tracing.Middleware)
+	router.Get("/healthz", app.healthz)
+	router.Get("/readyz", app.readyz)
 	router.Mount("/v1/orchestrate", app.Orchestrator())
+	// slack interactivity callbacks are verified with slack signing secret instead of bearer tokens
+	router.With(app.rejectReadOnly).Post("/v1/slack/interactions", app.slackInteractions)
+	router.Mount("/v1/admin", app.Admin())
 	router.Mount("/orchestrator/profiler", middleware.Profiler())
+	router.Handle("/metrics", app.metricsHandler())
+	// URLFormat middleware routes /openapi.json as /openapi with json format
+	router.Get("/openapi", app.openAPI(router))
+	router.Get("/docs", app.swaggerUI)
 
 	return http.Handler(router)
 }
@@ -4,23 +4,84 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
 )
 
 // Orchestrator Creates a new orchestrator router
 func (app *App) Orchestrator() http.Handler {
 	r := chi.NewRouter()
+	r.Use(app.auth.Required)
+	r.Use(app.rateLimiter().Limit)
+	r.Use(app.rejectReadOnly)
 
 	r.Post("/{namespace}/{entity}", app.orchestrate)
-	r.Post("/{namespace}/{entity}/version", app.setTargetVersion)
-	r.Post("/{namespace}/{entity}/options", app.setRolloutOptions)
-	r.Post("/{namespace}/{entity}/target/controller", app.setEntityTargetController)
-	r.Post("/{namespace}/{entity}/monitoring/controller", app.setEntityMonitoringController)
-	r.Post("/{namespace}/{entity}/status", app.reportCurrentStatus)
+	r.Post("/batch", app.orchestrateBulk)
+	r.With(app.idempotent, app.audited(engine.AuditTargetVersion)).Post("/{namespace}/{entity}/version", app.setTargetVersion)
+	r.With(app.idempotent, app.audited(engine.AuditRolloutOptions)).Post("/{namespace}/{entity}/options", app.setRolloutOptions)
+	r.Post("/{namespace}/{entity}/rollout/simulate", app.simulateRollout)
+	r.With(app.audited(engine.AuditTargetController)).Post("/{namespace}/{entity}/target/controller", app.setEntityTargetController)
+	r.With(app.audited(engine.AuditTargetController)).Post("/{namespace}/{entity}/target/cohort", app.setHashCohortTargetController)
+	r.With(app.audited(engine.AuditTargetController)).Post("/{namespace}/{entity}/target/grpc", app.setGrpcTargetController)
+	r.With(app.audited(engine.AuditTargetController)).Post("/{namespace}/{entity}/target/wasm", app.setWasmTargetController)
+	r.With(app.audited(engine.AuditTargetController)).Post("/{namespace}/{entity}/target/slack", app.setSlackApprovalController)
+	r.With(app.audited(engine.AuditTargetController)).Post("/{namespace}/{entity}/target/consul", app.setConsulTargetController)
+	r.With(app.audited(engine.AuditMonitoringController)).Post("/{namespace}/{entity}/monitoring/controller", app.setEntityMonitoringController)
+	r.With(app.audited(engine.AuditMonitoringController)).Post("/{namespace}/{entity}/monitoring/prometheus", app.setPromMonitoringController)
+	r.With(app.idempotent).Post("/{namespace}/{entity}/status", app.reportCurrentStatus)
+	r.With(app.audited(engine.AuditQuarantineRelease)).Post("/{namespace}/{entity}/quarantine/release", app.releaseQuarantinedTarget)
+	r.Post("/{namespace}/{entity}/target/{name}/heartbeat", app.targetHeartbeat)
+	r.With(app.audited(engine.AuditPin)).Post("/{namespace}/{entity}/target/{name}/pin", app.pinTarget)
+	r.With(app.audited(engine.AuditPause)).Post("/{namespace}/{entity}/pause", app.pauseRollout)
+	r.With(app.audited(engine.AuditResume)).Post("/{namespace}/{entity}/resume", app.resumeRollout)
+	r.With(app.audited(engine.AuditLastKnownVersion)).Post("/{namespace}/{entity}/lastknowngood", app.setLastKnownGood)
+	r.With(app.audited(engine.AuditLastKnownVersion)).Post("/{namespace}/{entity}/lastknownbad", app.setLastKnownBad)
+	r.With(app.audited(engine.AuditLastKnownVersion)).Delete("/{namespace}/{entity}/lastknownbad", app.clearLastKnownBad)
+	r.With(app.audited(engine.AuditNotifications)).Post("/{namespace}/{entity}/notifications", app.setNotificationConfig)
+	r.With(app.audited(engine.AuditSchedule)).Post("/{namespace}/{entity}/schedule", app.setSchedule)
+	r.With(app.audited(engine.AuditSplit)).Post("/{namespace}/{entity}/split", app.setVersionSplit)
+	r.With(app.audited(engine.AuditAnalysis)).Post("/{namespace}/{entity}/analysis", app.setAnalysisConfig)
+	r.With(app.audited(engine.AuditRegistryWatch)).Post("/{namespace}/{entity}/registrywatch", app.setRegistryWatch)
+	r.With(app.audited(engine.AuditApproval)).Post("/{namespace}/{entity}/registrywatch/approve", app.approveRegistryTag)
+	r.With(app.audited(engine.AuditSlack)).Post("/namespace/{namespace}/slack", app.setNamespaceSlackConfig)
+	r.With(app.audited(engine.AuditQuota)).Post("/namespace/{namespace}/quota", app.setNamespaceQuota)
+	r.With(app.audited(engine.AuditRedaction)).Post("/namespace/{namespace}/redaction", app.setNamespaceRedaction)
+	r.With(app.audited(engine.AuditGroupRules)).Post("/namespace/{namespace}/grouprules", app.setGroupRules)
+	r.With(app.audited(engine.AuditDependencies)).Post("/namespace/{namespace}/dependencies", app.setDependencies)
+	r.With(app.audited(engine.AuditFreeze)).Post("/namespace/{namespace}/freeze", app.setNamespaceFreeze)
+	r.With(app.audited(engine.AuditSlack)).Post("/{namespace}/{entity}/slack", app.setSlackConfig)
+	r.With(app.audited(engine.AuditEntityConfig)).Put("/{namespace}/{entity}/config", app.putEntityConfig)
+	r.With(app.audited(engine.AuditDelete)).Delete("/{namespace}", app.deleteNamespace)
+	r.With(app.audited(engine.AuditDelete)).Delete("/{namespace}/{entity}", app.deleteEntity)
+	r.With(app.audited(engine.AuditDeleteTarget)).Delete("/{namespace}/{entity}/target/{name}", app.deleteEntityTarget)
+	r.With(app.audited(engine.AuditUnpin)).Delete("/{namespace}/{entity}/target/{name}/pin", app.unpinTarget)
+	r.With(app.audited(engine.AuditAnalysis)).Delete("/{namespace}/{entity}/analysis", app.deleteAnalysisConfig)
+	r.With(app.audited(engine.AuditRegistryWatch)).Delete("/{namespace}/{entity}/registrywatch", app.deleteRegistryWatch)
 	r.Get("/namespaces", app.getNamespaces)
+	r.Get("/controllers", app.getControllerTypes)
 	r.Get("/{namespace}/entities", app.getEntities)
+	r.Get("/namespace/{namespace}/quota", app.getQuotaUsage)
+	r.Get("/namespace/{namespace}/grouprules", app.getGroupRules)
+	r.Get("/namespace/{namespace}/dependencies", app.getDependencyGraph)
+	r.Get("/namespace/{namespace}/freeze", app.getNamespaceFreeze)
+	r.Get("/{namespace}/{entity}/config", app.getEntityConfig)
 	r.Get("/{namespace}/{entity}/rollout", app.getRolloutInfo)
+	r.Get("/{namespace}/{entity}/progress", app.getProgress)
+	r.Get("/{namespace}/{entity}/version/queue", app.getQueuedVersions)
+	r.Get("/{namespace}/{entity}/schedule", app.getSchedule)
+	r.Get("/{namespace}/{entity}/split", app.getVersionSplit)
+	r.Get("/{namespace}/{entity}/analysis", app.getAnalysisConfig)
+	r.Get("/{namespace}/{entity}/analysis/runs", app.getAnalysisRuns)
+	r.Get("/{namespace}/{entity}/registrywatch", app.getRegistryWatch)
+	r.Get("/{namespace}/{entity}/rollouts", app.getRolloutHistory)
+	r.Get("/{namespace}/{entity}/audit", app.getAuditRecords)
+	r.Get("/{namespace}/audit", app.getAuditRecords)
+	r.Get("/{namespace}/{entity}/snapshots", app.getSnapshots)
+	r.Get("/{namespace}/{entity}/quarantine", app.getQuarantinedTargets)
+	r.Get("/{namespace}/{entity}/target/{name}/events", app.getTargetEvents)
 	r.Get("/{namespace}/{entity}/targets", app.getClientState)
 	r.Get("/{namespace}/{entity}/status", app.getClientState)
+	r.Get("/{namespace}/{entity}/status/stream", app.streamClientState)
+	r.Get("/{namespace}/{entity}/agent", app.agentSocket)
 	r.Get("/{namespace}/{entity}/{group}/status", app.getClientGroupState)
 	return r
 }
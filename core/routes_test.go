@@ -0,0 +1,39 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceRoutesDoNotShadowEntities(t *testing.T) {
+	const testName = "TestNamespaceRoutesDoNotShadowEntities"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	// entities named like namespace resources are orchestrated
+	entities := []string{"slack", "quota", "redaction", "grouprules", "freeze", "dependencies"}
+	for _, entity := range entities {
+		require.NoError(t, e.SetTargetVersion(testName, entity, engine.EntityTargetVersion{Version: "v1"}))
+		var clientStates []*engine.ClientState
+		require.NoError(t, httpclient.PostJSON(api.Orchestrate(testName, entity), "", []*engine.ClientState{{Name: "target", Version: "v1"}}, &clientStates), entity)
+		assert.Len(t, clientStates, 1, entity)
+	}
+
+	require.NoError(t, httpclient.PostJSON(api.NamespaceSlack(testName), "", &engine.SlackConfig{Channel: "#deploys"}, nil))
+
+	require.NoError(t, httpclient.PostJSON(api.Quota(testName), "", &engine.NamespaceQuota{MaxTargets: 10}, nil))
+	usage := &engine.QuotaUsage{}
+	require.NoError(t, httpclient.GetJSON(api.Quota(testName), "", usage))
+	assert.Equal(t, len(entities), usage.Entities)
+	assert.Equal(t, 10, usage.MaxTargets)
+}
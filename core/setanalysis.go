@@ -0,0 +1,86 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) setAnalysisConfig(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	var config engine.AnalysisConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetAnalysisConfig(namespace, entity, &config); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, config.Redacted())
+}
+
+func (app *App) getAnalysisConfig(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	config, err := app.e.GetAnalysisConfig(namespace, entity)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	if config == nil {
+		response.Error(w, http.StatusNotFound, "analysis not configured")
+		return
+	}
+	response.JSON(w, http.StatusOK, config.Redacted())
+}
+
+func (app *App) deleteAnalysisConfig(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	if err := app.e.SetAnalysisConfig(namespace, entity, nil); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) getAnalysisRuns(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	limit, err := queryInt(r, "limit", 20)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	runs, err := app.e.GetAnalysisRuns(namespace, entity, offset, limit)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, runs)
+}
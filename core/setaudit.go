@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+	"github.com/nixmade/orchestrator/server"
+)
+
+// maxAuditedRequest is size of request bodies read by audited routes, large enough for base64
+// encoded wasm modules
+const maxAuditedRequest = 2 * engine.MaxWasmModuleSize
+
+// auditCaller returns identity of authenticated caller from token claims
+func auditCaller(r *http.Request) string {
+	claims, ok := server.ClaimsFromContext(r.Context())
+	if !ok {
+		return "anonymous"
+	}
+	for _, claim := range []string{"sub", "email", "client_id", "name"} {
+		if value, ok := claims[claim].(string); ok && value != "" {
+			return value
+		}
+	}
+	return "anonymous"
+}
+
+// auditedValue returns value of entity changed by action, nil if action has no value tracked
+func (app *App) auditedValue(action, namespace, entity string) any {
+	if entity == "" {
+		return nil
+	}
+	switch action {
+	case engine.AuditTargetVersion, engine.AuditRolloutOptions, engine.AuditLastKnownVersion:
+		rollout, err := app.e.GetRolloutInfo(namespace, entity)
+		if err != nil {
+			return nil
+		}
+		switch action {
+		case engine.AuditRolloutOptions:
+			return rollout.Options
+		case engine.AuditLastKnownVersion:
+			return &engine.RolloutVersionInfo{LastKnownGoodVersion: rollout.LastKnownGoodVersion, LastKnownBadVersion: rollout.LastKnownBadVersion}
+		}
+		return &engine.EntityTargetVersion{Version: rollout.TargetVersion, ChangeInfo: rollout.TargetChange}
+	case engine.AuditTargetController, engine.AuditMonitoringController, engine.AuditEntityConfig:
+		config, err := app.e.GetEntityConfig(namespace, entity)
+		if err != nil {
+			return nil
+		}
+		switch action {
+		case engine.AuditTargetController:
+			return config.TargetController
+		case engine.AuditMonitoringController:
+			return config.MonitoringController
+		}
+		return config
+	}
+	return nil
+}
+
+// audited records successful calls in audit log of namespace and entity of route, with value changed by
+// action before and after the call, or request body if action has no value tracked
+func (app *App) audited(action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// validation only requests change nothing
+			if validate, _ := queryBool(r, "validate"); validate {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			namespace := chi.URLParam(r, "namespace")
+			entity := chi.URLParam(r, "entity")
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxAuditedRequest)); err != nil {
+					response.Error(w, engine.ErrorStatus(err), err.Error())
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			old := app.auditedValue(action, namespace, entity)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if ww.Status() != http.StatusOK {
+				return
+			}
+
+			record := &engine.AuditRecord{
+				Timestamp:  engine.DefaultClock.Now().UTC(),
+				Namespace:  namespace,
+				Entity:     entity,
+				Action:     action,
+				Caller:     auditCaller(r),
+				RemoteAddr: r.RemoteAddr,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Old:        engine.AuditValue(old),
+				New:        engine.AuditValue(body),
+			}
+			if current := app.auditedValue(action, namespace, entity); current != nil {
+				record.New = engine.AuditValue(current)
+			}
+			if err := app.e.RecordAudit(record); err != nil {
+				app.requestLogger(r).Error().Err(err).Str("Action", action).Msg("Failed to record audit")
+			}
+		})
+	}
+}
+
+func (app *App) getAuditRecords(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	limit, err := queryInt(r, "limit", 100)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	filter := engine.AuditFilter{Action: r.URL.Query().Get("action")}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		if *t, err = time.Parse(time.RFC3339, value); err != nil {
+			response.Error(w, engine.ErrorStatus(err), err.Error())
+			return
+		}
+	}
+
+	records, err := app.e.GetAuditRecords(namespace, entity, filter, offset, limit)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, records)
+}
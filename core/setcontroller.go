@@ -3,8 +3,10 @@ package core
 import (
 	"encoding/json"
 	"net/http"
+	"path/filepath"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
 	"github.com/nixmade/orchestrator/response"
 )
 
@@ -20,14 +22,19 @@ func (app *App) setEntityTargetController(w http.ResponseWriter, r *http.Request
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	entityController := &EntityWebTargetController{}
+	entityController := &engine.EntityWebTargetController{}
 	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
 	if err := app.e.SetEntityTargetController(namespace, entity, entityController); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 	response.OK(w, "ok")
@@ -45,15 +52,180 @@ func (app *App) setEntityMonitoringController(w http.ResponseWriter, r *http.Req
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	entityController := &EntityWebMonitoringController{}
+	entityController := &engine.EntityWebMonitoringController{}
+	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetEntityMonitoringController(namespace, entity, entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) setHashCohortTargetController(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	entityController := &engine.HashCohortTargetController{}
+	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetEntityTargetController(namespace, entity, entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) setGrpcTargetController(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	entityController := &engine.EntityGrpcTargetController{}
+	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetEntityTargetController(namespace, entity, entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) setWasmTargetController(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	// base64 encoded module in json
+	r.Body = http.MaxBytesReader(w, r.Body, 2*engine.MaxWasmModuleSize)
+	entityController := &engine.EntityWasmTargetController{}
+	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if entityController.Path != "" {
+		pluginDirectory := app.Config().Engine.PluginDirectory
+		if pluginDirectory == "" || !filepath.IsLocal(entityController.Path) {
+			response.Error(w, http.StatusBadRequest, "plugin path must be relative to configured plugin directory")
+			return
+		}
+		entityController.Path = filepath.Join(pluginDirectory, entityController.Path)
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetEntityTargetController(namespace, entity, entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) setConsulTargetController(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	entityController := &engine.EntityConsulTargetController{}
+	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetEntityTargetController(namespace, entity, entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) setPromMonitoringController(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	entityController := &engine.EntityPromMonitoringController{}
 	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
 	if err := app.e.SetEntityMonitoringController(namespace, entity, entityController); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 	response.OK(w, "ok")
 }
+
+func (app *App) getControllerTypes(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, engine.ControllerTypes())
+}
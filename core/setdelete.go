@@ -0,0 +1,43 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) deleteNamespace(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	if err := app.e.DeleteNamespace(namespace); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) deleteEntity(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	if err := app.e.DeleteEntity(namespace, entity); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (app *App) deleteEntityTarget(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+	name := chi.URLParam(r, "name")
+	group := r.URL.Query().Get("group")
+
+	if err := app.e.DeleteEntityTarget(namespace, entity, group, name); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
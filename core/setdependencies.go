@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) getDependencyGraph(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	graph, err := app.e.GetDependencyGraph(namespace)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, graph)
+}
+
+func (app *App) setDependencies(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+
+	var dependencies engine.EntityDependencies
+	if err := json.NewDecoder(r.Body).Decode(&dependencies); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetDependencies(namespace, &dependencies); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	graph, err := app.e.GetDependencyGraph(namespace)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, graph)
+}
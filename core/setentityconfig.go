@@ -0,0 +1,62 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+// writeEntityConfig responds with config redacted and its version as ETag
+func writeEntityConfig(w http.ResponseWriter, config *engine.EntityConfig) {
+	w.Header().Set("ETag", fmt.Sprintf("%q", config.Version))
+	response.JSON(w, http.StatusOK, config.Redacted())
+}
+
+func (app *App) getEntityConfig(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	config, err := app.e.GetEntityConfig(namespace, entity)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	writeEntityConfig(w, config)
+}
+
+func (app *App) putEntityConfig(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	var config engine.EntityConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	// If-Match takes precedence over version of document, weak etags compare equal
+	version := config.Version
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != "*" {
+		version = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	}
+
+	stored, err := app.e.PutEntityConfig(namespace, entity, &config, version)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	writeEntityConfig(w, stored)
+}
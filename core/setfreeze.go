@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func decodeFreeze(r *http.Request) (*engine.FreezeState, error) {
+	defer r.Body.Close()
+
+	var freeze engine.FreezeState
+	if err := json.NewDecoder(r.Body).Decode(&freeze); err != nil {
+		return nil, err
+	}
+	return &freeze, nil
+}
+
+func (app *App) setNamespaceFreeze(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	freeze, err := decodeFreeze(r)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetNamespaceFreeze(namespace, freeze); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, freeze)
+}
+
+func (app *App) getNamespaceFreeze(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	freeze, err := app.e.GetNamespaceFreeze(namespace)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, freeze)
+}
+
+func (app *App) setGlobalFreeze(w http.ResponseWriter, r *http.Request) {
+	freeze, err := decodeFreeze(r)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetGlobalFreeze(freeze); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, freeze)
+}
+
+func (app *App) getGlobalFreeze(w http.ResponseWriter, r *http.Request) {
+	freeze, err := app.e.GetGlobalFreeze()
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, freeze)
+}
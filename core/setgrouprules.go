@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) getGroupRules(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	rules, err := app.e.GetGroupRules(namespace)
+
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, rules)
+}
+
+func (app *App) setGroupRules(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+
+	var rules engine.GroupRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetGroupRules(namespace, &rules); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) targetHeartbeat(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+	name := chi.URLParam(r, "name")
+	group := r.URL.Query().Get("group")
+
+	clientTarget, err := app.e.Heartbeat(namespace, entity, group, name)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, clientTarget)
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+const (
+	// IdempotencyKeyHeader identifies retries of a request, retries replay response of the first request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a retry
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// requestHash identifies method, path and body of request, retries must match request they retry
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotent replays response of the first request with the same Idempotency-Key header instead of
+// running retries again, responses are kept for idempotencyTTL, server errors are not kept so they can be retried
+func (app *App) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > engine.MaxIdempotencyKey {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("%s longer than %d characters", IdempotencyKeyHeader, engine.MaxIdempotencyKey))
+			return
+		}
+		namespace := chi.URLParam(r, "namespace")
+		entity := chi.URLParam(r, "entity")
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				response.Error(w, engine.ErrorStatus(err), err.Error())
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		hash := requestHash(r, body)
+
+		unlock := app.e.LockIdempotencyKey(namespace, entity, key)
+		defer unlock()
+
+		recorded, err := app.e.FindIdempotentResponse(namespace, entity, key)
+		if err != nil {
+			response.Error(w, engine.ErrorStatus(err), err.Error())
+			return
+		}
+		if recorded != nil {
+			if recorded.RequestHash != hash {
+				response.Error(w, engine.ErrorStatus(engine.ErrIdempotencyKeyReused), engine.ErrIdempotencyKeyReused.Error())
+				return
+			}
+			if recorded.ContentType != "" {
+				w.Header().Set("Content-Type", recorded.ContentType)
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(recorded.StatusCode)
+			if _, err := w.Write(recorded.Body); err != nil {
+				app.requestLogger(r).Debug().Err(err).Msg("failed to replay idempotent response")
+			}
+			return
+		}
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+		next.ServeHTTP(ww, r)
+		if ww.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		resp := &engine.IdempotentResponse{
+			RequestHash: hash,
+			StatusCode:  ww.Status(),
+			ContentType: ww.Header().Get("Content-Type"),
+			Body:        buf.Bytes(),
+		}
+		if err := app.e.SaveIdempotentResponse(namespace, entity, key, resp); err != nil {
+			app.requestLogger(r).Error().Err(err).Str("Key", key).Msg("failed to record idempotent response")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) pauseRollout(w http.ResponseWriter, r *http.Request) {
+	app.setPaused(w, r, true)
+}
+
+func (app *App) resumeRollout(w http.ResponseWriter, r *http.Request) {
+	app.setPaused(w, r, false)
+}
+
+func (app *App) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+	group := r.URL.Query().Get("group")
+
+	var err error
+	if paused {
+		err = app.e.Pause(namespace, entity, group)
+	} else {
+		err = app.e.Resume(namespace, entity, group)
+	}
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	rolloutState, err := app.e.GetRolloutInfo(namespace, entity)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, rolloutState)
+}
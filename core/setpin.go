@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) pinTarget(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+	name := chi.URLParam(r, "name")
+	group := r.URL.Query().Get("group")
+
+	var pin engine.TargetPin
+	if err := json.NewDecoder(r.Body).Decode(&pin); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	clientTarget, err := app.e.PinTarget(namespace, entity, group, name, &pin)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, clientTarget)
+}
+
+func (app *App) unpinTarget(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+	name := chi.URLParam(r, "name")
+	group := r.URL.Query().Get("group")
+
+	clientTarget, err := app.e.UnpinTarget(namespace, entity, group, name)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, clientTarget)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+// setQuotaWarningHeader warns callers once namespace crosses QuotaWarningPercent of its quota,
+// counts are those of quota checks made by the request
+func (app *App) setQuotaWarningHeader(w http.ResponseWriter, namespace string, counts *engine.QuotaCounts) {
+	if warning := app.e.QuotaWarning(namespace, counts); warning != "" {
+		w.Header().Set(engine.QuotaWarningHeader, warning)
+	}
+}
+
+func (app *App) getQuotaUsage(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	usage, err := app.e.GetQuotaUsageContext(r.Context(), namespace)
+
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	app.setQuotaWarningHeader(w, namespace, nil)
+	response.JSON(w, http.StatusOK, usage)
+}
+
+func (app *App) setNamespaceQuota(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+
+	var quota engine.NamespaceQuota
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetNamespaceQuota(namespace, &quota); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
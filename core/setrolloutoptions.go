@@ -2,9 +2,11 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
 	"github.com/nixmade/orchestrator/response"
 )
 
@@ -20,15 +22,57 @@ func (app *App) setRolloutOptions(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	var rolloutOptions RolloutOptions
+	validate, err := queryBool(r, "validate")
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("invalid validate query: %s", err))
+		return
+	}
+
+	var rolloutOptions engine.RolloutOptions
 	if err := json.NewDecoder(r.Body).Decode(&rolloutOptions); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	// validate=true checks options without applying them
+	if validate {
+		if err := rolloutOptions.Validate(); err != nil {
+			response.Error(w, engine.ErrorStatus(err), err.Error())
+			return
+		}
+		response.OK(w, "valid")
 		return
 	}
 
-	if err := app.e.SetRolloutOptions(namespace, entity, &rolloutOptions); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+	if err := app.e.SetRolloutOptionsContext(r.Context(), namespace, entity, &rolloutOptions); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 	response.OK(w, "ok")
 }
+
+func (app *App) simulateRollout(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	var request engine.RolloutSimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	simulation, err := app.e.SimulateRolloutContext(r.Context(), namespace, entity, &request)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, simulation)
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+	"github.com/nixmade/orchestrator/signature"
+)
+
+const maxSlackInteractionSize = 1 << 20
+
+// slackInteraction is block_actions payload slack sends when a button is clicked
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+func (app *App) setSlackApprovalController(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	entityController := &engine.EntitySlackApprovalController{}
+	if err := json.NewDecoder(r.Body).Decode(entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := entityController.Validate(); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := app.e.SetEntityTargetController(namespace, entity, entityController); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.OK(w, "ok")
+}
+
+// slackInteractions handles approve and deny buttons of approval messages, requests must be signed
+// with slack app signing secret since slack does not send bearer tokens
+func (app *App) slackInteractions(w http.ResponseWriter, r *http.Request) {
+	secret := app.Config().Slack.SigningSecret
+	if secret == "" {
+		response.Error(w, http.StatusNotFound, "slack signing secret is not configured")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSlackInteractionSize))
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	if err := signature.VerifySlack(r.Header, body, secret, signature.DefaultTolerance, time.Now()); err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	var interaction slackInteraction
+	if err := json.Unmarshal([]byte(values.Get("payload")), &interaction); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	user := fmt.Sprintf("@%s (%s)", interaction.User.Username, interaction.User.ID)
+	for _, action := range interaction.Actions {
+		decided, err := app.e.DecideSlackAction(action.ActionID, action.Value, user, interaction.ResponseURL)
+		if err != nil {
+			response.Error(w, engine.ErrorStatus(err), err.Error())
+			return
+		}
+		if decided {
+			break
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) getTargetEvents(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+	name := chi.URLParam(r, "name")
+	group := r.URL.Query().Get("group")
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	limit, err := queryInt(r, "limit", engine.MaxTargetEvents)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	events, err := app.e.GetTargetEvents(namespace, entity, group, name, offset, limit)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, events)
+}
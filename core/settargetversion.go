@@ -2,9 +2,11 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
 	"github.com/nixmade/orchestrator/response"
 )
 
@@ -20,15 +22,40 @@ func (app *App) setTargetVersion(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
 	entity := chi.URLParam(r, "entity")
 
-	var targetVersion EntityTargetVersion
+	force, err := queryBool(r, "force")
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("invalid force query: %s", err))
+		return
+	}
+
+	var targetVersion engine.EntityTargetVersion
 	if err := json.NewDecoder(r.Body).Decode(&targetVersion); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 
-	if err := app.e.SetTargetVersion(namespace, entity, targetVersion); err != nil {
-		response.Error(w, http.StatusBadRequest, err.Error())
+	// force=true skips concurrency policy and downgrade protection, rolling version is marked bad
+	setTargetVersion := app.e.SetTargetVersionContext
+	if force {
+		setTargetVersion = app.e.ForceTargetVersionContext
+	}
+	if err := setTargetVersion(r.Context(), namespace, entity, targetVersion); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
 		return
 	}
 	response.OK(w, "ok")
 }
+
+func (app *App) getQueuedVersions(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	queuedVersions, err := app.e.GetQueuedVersions(namespace, entity)
+
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, queuedVersions)
+}
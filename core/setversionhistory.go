@@ -0,0 +1,69 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+func (app *App) setLastKnownGood(w http.ResponseWriter, r *http.Request) {
+	app.overrideLastKnownVersion(w, r, true)
+}
+
+func (app *App) setLastKnownBad(w http.ResponseWriter, r *http.Request) {
+	app.overrideLastKnownVersion(w, r, false)
+}
+
+func (app *App) overrideLastKnownVersion(w http.ResponseWriter, r *http.Request, good bool) {
+	var err error
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	var override engine.VersionOverride
+	if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	if good {
+		err = app.e.SetLastKnownGood(namespace, entity, &override)
+	} else {
+		err = app.e.SetLastKnownBad(namespace, entity, &override)
+	}
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	app.writeRolloutInfo(w, namespace, entity)
+}
+
+func (app *App) clearLastKnownBad(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	if err := app.e.ClearLastKnownBad(namespace, entity); err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	app.writeRolloutInfo(w, namespace, entity)
+}
+
+// writeRolloutInfo responds with rollout state of entity
+func (app *App) writeRolloutInfo(w http.ResponseWriter, namespace, entity string) {
+	rolloutState, err := app.e.GetRolloutInfo(namespace, entity)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+	response.JSON(w, http.StatusOK, rolloutState)
+}
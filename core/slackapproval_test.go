@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slackMessage is message posted to slack stub, only fields asserted on are decoded
+type slackMessage struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+	Blocks  []struct {
+		Text *struct {
+			Text string `json:"text"`
+		} `json:"text"`
+		Elements []struct {
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"elements"`
+	} `json:"blocks"`
+	ReplaceOriginal bool `json:"replace_original"`
+}
+
+// slackStub records messages posted to webhook and response urls
+type slackStub struct {
+	lock     sync.Mutex
+	messages []slackMessage
+}
+
+func (s *slackStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var message slackMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func (s *slackStub) last() slackMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.messages) <= 0 {
+		return slackMessage{}
+	}
+	return s.messages[len(s.messages)-1]
+}
+
+func (s *slackStub) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.messages)
+}
+
+// approvals counts approval messages, rollout notifications are posted to same webhook
+func (s *slackStub) approvals() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var count int
+	for _, message := range s.messages {
+		if strings.HasPrefix(message.Text, ":raised_hand:") {
+			count++
+		}
+	}
+	return count
+}
+
+// clickSlackButton posts signed block_actions payload for button of approval message
+func clickSlackButton(t *testing.T, serverURL, secret, responseURL string, message slackMessage, actionID string) *http.Response {
+	require.Len(t, message.Blocks, 2)
+	var value string
+	for _, element := range message.Blocks[1].Elements {
+		if element.ActionID == actionID {
+			value = element.Value
+		}
+	}
+	require.NotEmpty(t, value)
+
+	payload, err := json.Marshal(map[string]any{
+		"type":         "block_actions",
+		"user":         map[string]string{"id": "U123", "username": "oncall"},
+		"actions":      []map[string]string{{"action_id": actionID, "value": value}},
+		"response_url": responseURL,
+	})
+	require.NoError(t, err)
+	body := []byte(url.Values{"payload": {string(payload)}}.Encode())
+
+	req, err := http.NewRequest(http.MethodPost, serverURL+"/v1/slack/interactions", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	now := time.Now()
+	req.Header.Set(signature.SlackTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(signature.SlackHeader, signature.SignSlack(secret, now, body))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp
+}
+
+func TestSlackApprovalController(t *testing.T) {
+	const testName = "TestSlackApprovalController"
+	const secret = "signingsecret"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	stub := &slackStub{}
+	slack := httptest.NewServer(stub)
+	defer slack.Close()
+
+	app := NewAppWithEngine(e)
+	app.config = config.Default()
+	app.config.Slack.SigningSecret = secret
+	srv := httptest.NewServer(NewRouter(app))
+	defer srv.Close()
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*engine.ClientState{{Name: "clientTarget0", Version: "v1"}, {Name: "clientTarget1", Version: "v1"}}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	controller := &engine.EntitySlackApprovalController{WebhookURL: slack.URL, Channel: "#deploys"}
+	require.NoError(t, e.SetEntityTargetController(testName, testName, controller))
+
+	var events []engine.Event
+	var eventsLock sync.Mutex
+	unsubscribe := e.Events().Subscribe(func(event engine.Event) {
+		if event.Entity != testName {
+			return
+		}
+		eventsLock.Lock()
+		defer eventsLock.Unlock()
+		events = append(events, event)
+	})
+	defer unsubscribe()
+
+	// batch is held while approval is pending, message is posted once
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	var assigned []*engine.ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = e.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, countVersion(assigned, "v2"))
+	require.Equal(t, 1, stub.count())
+	request := stub.last()
+	assert.Equal(t, "#deploys", request.Channel)
+	assert.Contains(t, request.Blocks[0].Text.Text, "*v2* to 2 targets")
+	assert.Contains(t, request.Blocks[0].Text.Text, "`clientTarget0`")
+
+	// unsigned callbacks are rejected
+	resp, err := http.Post(srv.URL+"/v1/slack/interactions", "application/x-www-form-urlencoded", bytes.NewReader([]byte("payload={}")))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = clickSlackButton(t, srv.URL, secret, slack.URL, request, "approve")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Eventually(t, func() bool { return stub.count() == 2 }, time.Second, 10*time.Millisecond)
+	assert.True(t, stub.last().ReplaceOriginal)
+	assert.Contains(t, stub.last().Text, "Approved by @oncall (U123)")
+
+	// approving again fails, approval was already decided
+	_, err = e.DecideSlackApproval(testName, testName, "unknown", true, "someone")
+	assert.ErrorIs(t, err, engine.ErrApprovalNotPending)
+
+	assigned, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countVersion(assigned, "v2"))
+
+	histories, err := e.GetRolloutHistory(testName, testName, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, histories, 1)
+	require.Len(t, histories[0].Approvals, 1)
+	assert.Equal(t, engine.ApprovalApproved, histories[0].Approvals[0].Status)
+	assert.Equal(t, "@oncall (U123)", histories[0].Approvals[0].User)
+	assert.ElementsMatch(t, []string{"clientTarget0", "clientTarget1"}, histories[0].Approvals[0].Targets)
+
+	eventsLock.Lock()
+	var types []engine.EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	eventsLock.Unlock()
+	assert.Contains(t, types, engine.EventApprovalRequested)
+	assert.Contains(t, types, engine.EventApprovalGranted)
+}
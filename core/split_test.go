@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionSplit(t *testing.T) {
+	const testName = "TestVersionSplit"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*engine.ClientState
+	for i := 0; i < 10; i++ {
+		clientTargets = append(clientTargets, &engine.ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	split := &engine.VersionSplit{}
+	require.NoError(t, httpclient.PostJSON(api.Split(testName, testName), "", &VersionSplitRequest{Versions: []engine.VersionWeight{{Version: "v1", Percent: 90}, {Version: "v2", Percent: 10}}}, split))
+	assert.False(t, split.Converged)
+
+	assigned, err := e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 9, countVersion(assigned, "v1"))
+	assert.Equal(t, 1, countVersion(assigned, "v2"))
+
+	split, err = e.GetVersionSplit(testName, testName)
+	require.NoError(t, err)
+	assert.True(t, split.Converged)
+	assert.Equal(t, engine.VersionSplitStatus{VersionWeight: engine.VersionWeight{Version: "v2", Percent: 10}, Desired: 1, Assigned: 1}, split.Versions[1])
+
+	// canary target stays on v2 as split grows, converging at most batch percent per orchestrate
+	canary := ""
+	for _, clientTarget := range assigned {
+		if clientTarget.Version == "v2" {
+			canary = clientTarget.Name
+		}
+	}
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 20}))
+	require.NoError(t, e.SetVersionSplit(testName, testName, []engine.VersionWeight{{Version: "v1", Percent: 50}, {Version: "v2", Percent: 50}}))
+	assigned, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 3, countVersion(assigned, "v2"))
+	assigned, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 5, countVersion(assigned, "v2"))
+	assert.Equal(t, "v2", findClientTarget(assigned, canary).Version)
+
+	assert.ErrorIs(t, e.SetVersionSplit(testName, testName, []engine.VersionWeight{{Version: "v1", Percent: 50}}), engine.ErrInvalidVersionSplit)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/response"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamBufferSize        = 256
+)
+
+// writeServerSentEvent writes a single SSE event with json data
+func writeServerSentEvent(w http.ResponseWriter, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// streamClientState pushes client state as server sent events,
+// current state is sent as snapshot followed by target events whenever target state is persisted
+func (app *App) streamClientState(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	entity := chi.URLParam(r, "entity")
+
+	controller := http.NewResponseController(w)
+
+	updates := make(chan *engine.ClientState, streamBufferSize)
+	unsubscribe := app.e.Events().Subscribe(func(event engine.Event) {
+		if event.Type != engine.EventTargetUpdated || event.Namespace != namespace || event.Entity != entity {
+			return
+		}
+		select {
+		case updates <- event.State:
+		default:
+			// slow consumer, dropping update rather than blocking orchestration
+		}
+	})
+	defer unsubscribe()
+
+	clientTargets, err := app.e.GetClientState(namespace, entity)
+	if err != nil {
+		response.Error(w, engine.ErrorStatus(err), err.Error())
+		return
+	}
+
+	// stream outlives server write timeout
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		app.requestLogger(r).Debug().Err(err).Msg("failed to clear write deadline for stream")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeServerSentEvent(w, "snapshot", clientTargets); err != nil {
+		return
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case clientTarget := <-updates:
+			if err := writeServerSentEvent(w, "target", clientTarget); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamClientState(t *testing.T) {
+	const testName = "TestStreamClientState"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	clientTargets, err := setupNamespace(e, testName, testName, 2)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewApp(WithEngine(e))))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/orchestrate/" + testName + "/" + testName + "/status/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (string, string) {
+		var event, data string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSpace(line)
+			if line == "" && event != "" {
+				return event, data
+			}
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				event = value
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data = value
+			}
+		}
+	}
+
+	event, data := readEvent()
+	assert.Equal(t, "snapshot", event)
+	var snapshot []*engine.ClientState
+	require.NoError(t, json.Unmarshal([]byte(data), &snapshot))
+	assert.Len(t, snapshot, 2)
+
+	clientTargets[0].Message = "streamed"
+	_, err = e.Orchestrate(testName, testName, clientTargets[:1])
+	require.NoError(t, err)
+
+	event, data = readEvent()
+	assert.Equal(t, "target", event)
+	var clientTarget engine.ClientState
+	require.NoError(t, json.Unmarshal([]byte(data), &clientTarget))
+	assert.Equal(t, clientTargets[0].Name, clientTarget.Name)
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetEvents(t *testing.T) {
+	const testName = "TestTargetEvents"
+	e, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, e, testName)
+
+	require.NoError(t, e.SetRolloutOptions(testName, testName, &engine.RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*engine.ClientState{{Name: "clientTarget0", Version: "v1"}, {Name: "clientTarget1", Version: "v1"}}
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	// rolling version is promoted at the end of the first cycle, assigned on the next
+	require.NoError(t, e.SetTargetVersion(testName, testName, engine.EntityTargetVersion{Version: "v2"}))
+	var assigned []*engine.ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = e.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	require.Equal(t, "v2", findClientTarget(assigned, "clientTarget0").Version)
+
+	clientTargets[0].Version = "v2"
+	clientTargets[0].IsError = true
+	clientTargets[0].Message = "crash loop"
+	_, err = e.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	_, err = e.PinTarget(testName, testName, "", "clientTarget0", &engine.TargetPin{Version: "v1", Reason: "investigating"})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewRouter(NewAppWithEngine(e)))
+	defer srv.Close()
+	api := httpclient.NewOrchestratorAPI(srv.URL)
+
+	var events []*engine.TargetEvent
+	require.NoError(t, httpclient.GetJSON(api.TargetEvents(testName, testName, "", "clientTarget0"), "", &events))
+	types := targetEventTypes(events)
+	require.NotEmpty(t, types)
+	assert.Equal(t, engine.TargetEventCreated, types[len(types)-1])
+	assert.Equal(t, engine.TargetEventPinned, types[0])
+	assert.Contains(t, types, engine.TargetEventSelected)
+	assert.Contains(t, types, engine.TargetEventVersionReported)
+	assert.Contains(t, types, engine.TargetEventErrorReported)
+	for i := 1; i < len(events); i++ {
+		assert.False(t, events[i].Timestamp.After(events[i-1].Timestamp), "events are newest first")
+	}
+	for _, event := range events {
+		if event.Type == engine.TargetEventErrorReported {
+			assert.Equal(t, "v2", event.Version)
+			assert.Equal(t, "crash loop", event.Message)
+		}
+	}
+
+	paged, err := e.GetTargetEvents(testName, testName, "", "clientTarget0", 1, 1)
+	require.NoError(t, err)
+	require.Len(t, paged, 1)
+	assert.Equal(t, events[1], paged[0])
+
+	// healthy target has no error transitions
+	healthy, err := e.GetTargetEvents(testName, testName, "", "clientTarget1", 0, 0)
+	require.NoError(t, err)
+	assert.NotContains(t, targetEventTypes(healthy), engine.TargetEventErrorReported)
+
+	assert.ErrorContains(t, httpclient.GetJSON(api.TargetEvents(testName, testName, "", "unknown"), "", &events), "404")
+
+	require.NoError(t, e.DeleteEntityTarget(testName, testName, "", "clientTarget0"))
+	_, err = e.GetTargetEvents(testName, testName, "", "clientTarget0", 0, 0)
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+func targetEventTypes(events []*engine.TargetEvent) []engine.TargetEventType {
+	var types []engine.TargetEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
@@ -0,0 +1,376 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	analysisPrefix    = "analysis:"
+	analysisRunPrefix = "analysisrun:"
+	// analysisVersionPlaceholder in metric queries is replaced with canary or baseline version
+	analysisVersionPlaceholder = "{{version}}"
+	defaultAnalysisInterval    = time.Minute
+	analysisQueryTimeout       = 30 * time.Second
+	// RedactedSecret replaces secrets returned by engine
+	RedactedSecret = "********"
+)
+
+const (
+	// AnalysisPassed canary was not worse than baseline for any metric
+	AnalysisPassed = "Passed"
+	// AnalysisFailed canary was worse than baseline for a metric, rolling version is now lkb
+	AnalysisFailed = "Failed"
+	// AnalysisInconclusive a metric could not be queried, analysis is retried after interval
+	AnalysisInconclusive = "Inconclusive"
+)
+
+// AnalysisProviderConfig selects metric provider and its credentials
+type AnalysisProviderConfig struct {
+	// Type is prometheus, datadog, cloudwatch or a type added with RegisterMetricProvider
+	Type string `json:"type,omitempty"`
+	// Address of provider api, defaults to public datadog and regional cloudwatch endpoints
+	Address string `json:"address,omitempty"`
+	// APIKey is datadog api key or prometheus bearer token
+	APIKey string `json:"apikey,omitempty"`
+	// ApplicationKey is datadog application key
+	ApplicationKey string `json:"applicationkey,omitempty"`
+	// Region and credentials of cloudwatch, credentials default to AWS_* environment variables
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"accesskeyid,omitempty"`
+	SecretAccessKey string `json:"secretaccesskey,omitempty"`
+	SessionToken    string `json:"sessiontoken,omitempty"`
+}
+
+// AnalysisMetric is a provider query evaluated once for canary and once for baseline version
+type AnalysisMetric struct {
+	Name string `json:"name,omitempty"`
+	// Query with {{version}} placeholder, e.g. sum(rate(errors{version="{{version}}"}[5m]))
+	Query string `json:"query,omitempty"`
+	// TolerancePercent canary is allowed to be worse than baseline
+	TolerancePercent float64 `json:"tolerancepercent,omitempty"`
+	// HigherIsBetter for metrics like throughput, by default lower is better like latency or error rate
+	HigherIsBetter bool `json:"higherisbetter,omitempty"`
+}
+
+// AnalysisConfig is per entity canary analysis, each batch waits for a passing analysis before next batch starts
+type AnalysisConfig struct {
+	Provider AnalysisProviderConfig `json:"provider"`
+	Metrics  []AnalysisMetric       `json:"metrics,omitempty"`
+	// IntervalSecs after batch start and between analysis runs, defaults to 60
+	IntervalSecs int `json:"intervalsecs,omitempty"`
+	// WindowSecs is lookback of provider queries, defaults to IntervalSecs
+	WindowSecs int `json:"windowsecs,omitempty"`
+}
+
+// AnalysisResult is outcome of a single metric in analysis run
+type AnalysisResult struct {
+	Name     string  `json:"name,omitempty"`
+	Canary   float64 `json:"canary,omitempty"`
+	Baseline float64 `json:"baseline,omitempty"`
+	Failed   bool    `json:"failed,omitempty"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// AnalysisRun is persisted record of a canary analysis
+type AnalysisRun struct {
+	ID              string           `json:"id"`
+	Version         string           `json:"version,omitempty"`
+	BaselineVersion string           `json:"baselineversion,omitempty"`
+	Timestamp       time.Time        `json:"timestamp"`
+	Outcome         string           `json:"outcome,omitempty"`
+	Results         []AnalysisResult `json:"results,omitempty"`
+}
+
+func (c *AnalysisConfig) Validate() error {
+	if c.Provider.Type == "" {
+		return fmt.Errorf("%w: provider type is required", ErrInvalidAnalysisConfig)
+	}
+	if _, err := newMetricProvider(&c.Provider); err != nil {
+		return err
+	}
+	if c.Provider.Type == MetricProviderPrometheus && c.Provider.Address == "" {
+		return fmt.Errorf("%w: prometheus requires address", ErrInvalidAnalysisConfig)
+	}
+	if c.Provider.Type == MetricProviderCloudWatch && c.Provider.Region == "" {
+		return fmt.Errorf("%w: cloudwatch requires region", ErrInvalidAnalysisConfig)
+	}
+	if len(c.Metrics) <= 0 {
+		return fmt.Errorf("%w: at least one metric is required", ErrInvalidAnalysisConfig)
+	}
+	for i, metric := range c.Metrics {
+		if metric.Name == "" || metric.Query == "" {
+			return fmt.Errorf("%w: metric %d requires name and query", ErrInvalidAnalysisConfig, i)
+		}
+		if metric.TolerancePercent < 0 {
+			return fmt.Errorf("%w: metric %s has negative tolerancepercent", ErrInvalidAnalysisConfig, metric.Name)
+		}
+	}
+	if c.IntervalSecs < 0 || c.WindowSecs < 0 {
+		return fmt.Errorf("%w: intervalsecs and windowsecs must not be negative", ErrInvalidAnalysisConfig)
+	}
+	return nil
+}
+
+func (c *AnalysisConfig) interval() time.Duration {
+	if c.IntervalSecs <= 0 {
+		return defaultAnalysisInterval
+	}
+	return time.Duration(c.IntervalSecs) * time.Second
+}
+
+func (c *AnalysisConfig) window() time.Duration {
+	if c.WindowSecs <= 0 {
+		return c.interval()
+	}
+	return time.Duration(c.WindowSecs) * time.Second
+}
+
+// Redacted returns copy of config without provider secrets
+func (c *AnalysisConfig) Redacted() *AnalysisConfig {
+	config := *c
+	for _, secret := range []*string{&config.Provider.APIKey, &config.Provider.ApplicationKey, &config.Provider.SecretAccessKey, &config.Provider.SessionToken} {
+		if *secret != "" {
+			*secret = RedactedSecret
+		}
+	}
+	return &config
+}
+
+func (e *Entity) analysisKey() string {
+	return fmt.Sprintf("%s%s/%s", analysisPrefix, e.Namespace, e.Name)
+}
+
+func (e *Entity) analysisRunPrefix() string {
+	return fmt.Sprintf("%s%s/%s/", analysisRunPrefix, e.Namespace, e.Name)
+}
+
+// setAnalysisConfig saves canary analysis config, nil config disables analysis
+func (e *Entity) setAnalysisConfig(config *AnalysisConfig) error {
+	if config == nil {
+		e.logger.Info().Msg("Removing AnalysisConfig")
+		return e.store.Delete(e.analysisKey())
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	e.logger.Info().Str("Provider", config.Provider.Type).Int("Metrics", len(config.Metrics)).Msg("Set AnalysisConfig")
+	return e.store.SaveJSON(e.analysisKey(), config)
+}
+
+// findAnalysisConfig returns nil config if analysis is not configured
+func (e *Entity) findAnalysisConfig() (*AnalysisConfig, error) {
+	config := &AnalysisConfig{}
+	err := e.store.LoadJSON(e.analysisKey(), config)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (e *Entity) saveAnalysisRun(run *AnalysisRun) error {
+	return e.store.SaveJSON(e.analysisRunPrefix()+run.ID, run)
+}
+
+// getAnalysisRuns returns analysis runs newest first, skipping offset runs and returning at most limit runs
+func (e *Entity) getAnalysisRuns(offset, limit int) ([]*AnalysisRun, error) {
+	var runs []*AnalysisRun
+	runItr := func(key any, value any) error {
+		run := &AnalysisRun{}
+		if err := json.Unmarshal([]byte(value.(string)), run); err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		return nil
+	}
+	if err := e.store.LoadValues(e.analysisRunPrefix(), runItr); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Timestamp.After(runs[j].Timestamp)
+	})
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(runs) {
+		return []*AnalysisRun{}, nil
+	}
+	runs = runs[offset:]
+	if limit > 0 && limit < len(runs) {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+func (n *Namespace) setAnalysisConfig(entityName string, config *AnalysisConfig) error {
+	entity, err := n.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.setAnalysisConfig(config)
+}
+
+func (n *Namespace) getAnalysisConfig(entityName string) (*AnalysisConfig, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.findAnalysisConfig()
+}
+
+func (n *Namespace) getAnalysisRuns(entityName string, offset, limit int) ([]*AnalysisRun, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getAnalysisRuns(offset, limit)
+}
+
+// analyze queries every metric for canary and baseline version
+func analyze(ctx context.Context, config *AnalysisConfig, canaryVersion, baselineVersion string) (*AnalysisRun, error) {
+	provider, err := newMetricProvider(&config.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	nowTime := nowUTC()
+	run := &AnalysisRun{
+		ID:              fmt.Sprintf("%020d", nowTime.UnixNano()),
+		Version:         canaryVersion,
+		BaselineVersion: baselineVersion,
+		Timestamp:       nowTime,
+		Outcome:         AnalysisPassed,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analysisQueryTimeout)
+	defer cancel()
+
+	for _, metric := range config.Metrics {
+		result := AnalysisResult{Name: metric.Name}
+		canary, err := provider.Query(ctx, strings.ReplaceAll(metric.Query, analysisVersionPlaceholder, canaryVersion), config.window())
+		if err == nil {
+			result.Canary = canary
+			result.Baseline, err = provider.Query(ctx, strings.ReplaceAll(metric.Query, analysisVersionPlaceholder, baselineVersion), config.window())
+		}
+
+		canaryMetric := CanaryMetric{TolerancePercent: metric.TolerancePercent, HigherIsBetter: metric.HigherIsBetter}
+		switch {
+		case err != nil:
+			result.Message = err.Error()
+			if run.Outcome == AnalysisPassed {
+				run.Outcome = AnalysisInconclusive
+			}
+		case canaryMetric.isWorse(result.Canary, result.Baseline):
+			result.Failed = true
+			result.Message = fmt.Sprintf("canary %s %g is worse than baseline %g beyond tolerance %g%%", metric.Name, result.Canary, result.Baseline, metric.TolerancePercent)
+			run.Outcome = AnalysisFailed
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return run, nil
+}
+
+// runAnalysis analyzes canary targets of current batch with configured metric provider once interval passed,
+// marks rolling version as bad if analysis failed, holds next batch in state until analysis passed
+func (r *Rollout) runAnalysis(ctx context.Context, state *rolloutInfo) (bool, error) {
+	if r.entity == nil || !r.rolloutInProgress() || r.State.LastKnownGoodVersion == "" || r.State.BatchTimestamp.IsZero() {
+		// nothing to compare without baseline, or before first batch started
+		return false, nil
+	}
+
+	config, err := r.entity.findAnalysisConfig()
+	if err != nil || config == nil {
+		return false, err
+	}
+
+	// hold next batch until a run after current batch started passed
+	state.analysisPending = r.State.AnalysisOutcome != AnalysisPassed || !r.State.AnalysisTimestamp.After(r.State.BatchTimestamp)
+	if !state.analysisPending {
+		return false, nil
+	}
+
+	last := r.State.BatchTimestamp
+	if r.State.AnalysisTimestamp.After(last) {
+		last = r.State.AnalysisTimestamp
+	}
+	if timeSince(last) < config.interval() {
+		return false, nil
+	}
+
+	run, err := analyze(ctx, config, r.State.RollingVersion, r.State.LastKnownGoodVersion)
+	if err != nil {
+		return false, err
+	}
+	if err := r.entity.saveAnalysisRun(run); err != nil {
+		return false, err
+	}
+
+	r.State.AnalysisTimestamp = run.Timestamp
+	r.State.AnalysisOutcome = run.Outcome
+	r.logger.Info().Str("AnalysisRun", run.ID).Str("Outcome", run.Outcome).Msg("Canary analysis completed")
+
+	switch run.Outcome {
+	case AnalysisPassed:
+		state.analysisPending = false
+	case AnalysisFailed:
+		r.logger.Error().Str("RollingVersion", r.State.RollingVersion).Msg("Canary analysis failed, marking rolling version as bad")
+		r.setLastKnownBad(r.State.RollingVersion, VersionMarkAnalysisFailed, "")
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// filterAnalysisTargets holds next batch until canary analysis of current batch passed
+func (r *Rollout) filterAnalysisTargets(state *rolloutInfo) {
+	if !state.analysisPending {
+		return
+	}
+
+	r.logger.Info().Int("HeldTargets", len(state.availableTargets)).Msg("Holding targets until canary analysis passed")
+	state.availableTargets = nil
+}
+
+// SetAnalysisConfig sets canary analysis of entity, nil config disables analysis
+func (e *Engine) SetAnalysisConfig(namespaceName, entityName string, config *AnalysisConfig) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setAnalysisConfig(entityName, config)
+}
+
+// GetAnalysisConfig returns canary analysis of entity, nil if not configured
+func (e *Engine) GetAnalysisConfig(namespaceName, entityName string) (*AnalysisConfig, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getAnalysisConfig(entityName)
+}
+
+// GetAnalysisRuns returns canary analysis runs newest first
+// offset skips number of runs, limit <= 0 returns all remaining runs
+func (e *Engine) GetAnalysisRuns(namespaceName, entityName string, offset, limit int) ([]*AnalysisRun, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getAnalysisRuns(entityName, offset, limit)
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offsetClock runs ahead of system clock, tests advance it instead of sleeping
+type offsetClock struct {
+	offset time.Duration
+}
+
+func (c *offsetClock) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// fakeMetricProvider returns values by query
+type fakeMetricProvider struct {
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func (p *fakeMetricProvider) set(query string, value float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.values[query] = value
+}
+
+func (p *fakeMetricProvider) remove(query string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.values, query)
+}
+
+func (p *fakeMetricProvider) Query(ctx context.Context, query string, window time.Duration) (float64, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	value, ok := p.values[query]
+	if !ok {
+		return 0, fmt.Errorf("unknown query %s", query)
+	}
+	return value, nil
+}
+
+func TestPrometheusProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if strings.Contains(r.URL.Query().Get("query"), "scalar") {
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"0.5"]}}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"12.5"]}]}}`)
+	}))
+	defer srv.Close()
+
+	provider, err := newMetricProvider(&AnalysisProviderConfig{Type: MetricProviderPrometheus, Address: srv.URL, APIKey: "token"})
+	require.NoError(t, err)
+
+	value, err := provider.Query(context.Background(), `sum(errors{version="v2"})`, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, value)
+
+	value, err = provider.Query(context.Background(), "scalar(errors)", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, value)
+}
+
+func TestDatadogProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "api", r.Header.Get("DD-API-KEY"))
+		assert.Equal(t, "app", r.Header.Get("DD-APPLICATION-KEY"))
+		assert.Equal(t, "avg:errors{version:v2}", r.URL.Query().Get("query"))
+		fmt.Fprint(w, `{"status":"ok","series":[{"pointlist":[[1700000000000,1],[1700000060000,null],[1700000120000,3]]}]}`)
+	}))
+	defer srv.Close()
+
+	provider, err := newMetricProvider(&AnalysisProviderConfig{Type: MetricProviderDatadog, Address: srv.URL, APIKey: "api", ApplicationKey: "app"})
+	require.NoError(t, err)
+
+	value, err := provider.Query(context.Background(), "avg:errors{version:v2}", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, value)
+}
+
+func TestCloudWatchProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GraniteServiceVersion20100801.GetMetricData", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/monitoring/aws4_request")
+
+		var request struct {
+			MetricDataQueries []struct {
+				Expression string
+				Period     int
+			}
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		require.Len(t, request.MetricDataQueries, 1)
+		assert.Equal(t, "errors", request.MetricDataQueries[0].Expression)
+		assert.Equal(t, 300, request.MetricDataQueries[0].Period)
+		fmt.Fprint(w, `{"MetricDataResults":[{"Id":"q","Values":[4,6]}]}`)
+	}))
+	defer srv.Close()
+
+	provider, err := newMetricProvider(&AnalysisProviderConfig{
+		Type:            MetricProviderCloudWatch,
+		Address:         srv.URL,
+		Region:          "us-east-1",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+
+	value, err := provider.Query(context.Background(), "errors", 5*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, value)
+}
+
+func TestAnalysisConfigValidate(t *testing.T) {
+	metrics := []AnalysisMetric{{Name: "errors", Query: "errors"}}
+	invalid := []*AnalysisConfig{
+		{Metrics: metrics},
+		{Provider: AnalysisProviderConfig{Type: "unknown"}, Metrics: metrics},
+		{Provider: AnalysisProviderConfig{Type: MetricProviderPrometheus}, Metrics: metrics},
+		{Provider: AnalysisProviderConfig{Type: MetricProviderCloudWatch}, Metrics: metrics},
+		{Provider: AnalysisProviderConfig{Type: MetricProviderDatadog}},
+		{Provider: AnalysisProviderConfig{Type: MetricProviderDatadog}, Metrics: []AnalysisMetric{{Name: "errors"}}},
+		{Provider: AnalysisProviderConfig{Type: MetricProviderDatadog}, Metrics: metrics, IntervalSecs: -1},
+	}
+	for _, config := range invalid {
+		assert.ErrorIs(t, config.Validate(), ErrInvalidAnalysisConfig)
+	}
+	assert.NoError(t, (&AnalysisConfig{Provider: AnalysisProviderConfig{Type: MetricProviderDatadog}, Metrics: metrics}).Validate())
+}
+
+func TestAnalysisGatesBatches(t *testing.T) {
+	const testName = "TestAnalysisGatesBatches"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	clock := &offsetClock{}
+	DefaultClock = clock
+	defer func() { DefaultClock = systemClock{} }()
+
+	provider := &fakeMetricProvider{values: map[string]float64{"errors:v1": 10, "errors:v2": 10.5}}
+	RegisterMetricProvider(testName, func(config *AnalysisProviderConfig) (MetricProvider, error) {
+		return provider, nil
+	})
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*ClientState
+	for i := 0; i < 10; i++ {
+		clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.SetAnalysisConfig(testName, testName, &AnalysisConfig{
+		Provider:     AnalysisProviderConfig{Type: testName},
+		Metrics:      []AnalysisMetric{{Name: "errors", Query: "errors:{{version}}", TolerancePercent: 10}},
+		IntervalSecs: 60,
+	}))
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{
+		BatchPercent:        20,
+		SuccessPercent:      100,
+		DurationTimeoutSecs: 3600,
+	}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+
+	// first orchestrate promotes v2 to rolling version, second assigns first batch
+	for i := 0; i < 2; i++ {
+		clientTargets, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, countVersion(clientTargets, "v2"))
+
+	// next batch waits for analysis interval
+	clientTargets, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countVersion(clientTargets, "v2"))
+
+	// canary within tolerance of baseline passes and releases next batch
+	clock.offset += 2 * time.Minute
+	clientTargets, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 4, countVersion(clientTargets, "v2"))
+
+	runs, err := engine.GetAnalysisRuns(testName, testName, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, AnalysisPassed, runs[0].Outcome)
+	assert.Equal(t, "v2", runs[0].Version)
+	assert.Equal(t, "v1", runs[0].BaselineVersion)
+	require.Len(t, runs[0].Results, 1)
+	assert.Equal(t, 10.5, runs[0].Results[0].Canary)
+	assert.Equal(t, 10.0, runs[0].Results[0].Baseline)
+
+	// unreachable query is inconclusive and keeps holding next batch
+	provider.remove("errors:v2")
+	clock.offset += 2 * time.Minute
+	clientTargets, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 4, countVersion(clientTargets, "v2"))
+
+	// canary worse than baseline fails rolling version
+	provider.set("errors:v2", 20)
+	clock.offset += 2 * time.Minute
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	rolloutState, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rolloutState.LastKnownBadVersion)
+
+	runs, err = engine.GetAnalysisRuns(testName, testName, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, AnalysisFailed, runs[0].Outcome)
+	assert.True(t, runs[0].Results[0].Failed)
+	assert.Equal(t, AnalysisInconclusive, runs[1].Outcome)
+}
@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	auditPrefix = "audit:"
+	// maxAuditValue is size of request bodies kept in audit records
+	maxAuditValue = 64 << 10
+)
+
+// Audit actions of mutating api calls, old and new values are tracked for target version, rollout options,
+// last known versions, controllers and entity config, other actions record request body
+const (
+	AuditTargetVersion        = "targetversion"
+	AuditRolloutOptions       = "rolloutoptions"
+	AuditTargetController     = "targetcontroller"
+	AuditMonitoringController = "monitoringcontroller"
+	AuditEntityConfig         = "entityconfig"
+	AuditApproval             = "approval"
+	AuditQuarantineRelease    = "quarantinerelease"
+	AuditPin                  = "pin"
+	AuditUnpin                = "unpin"
+	AuditPause                = "pause"
+	AuditResume               = "resume"
+	AuditLastKnownVersion     = "lastknownversion"
+	AuditNotifications        = "notifications"
+	AuditSchedule             = "schedule"
+	AuditSplit                = "split"
+	AuditAnalysis             = "analysis"
+	AuditRegistryWatch        = "registrywatch"
+	AuditSlack                = "slack"
+	AuditQuota                = "quota"
+	AuditRedaction            = "redaction"
+	AuditGroupRules           = "grouprules"
+	AuditDependencies         = "dependencies"
+	AuditFreeze               = "freeze"
+	AuditDelete               = "delete"
+	AuditDeleteTarget         = "deletetarget"
+)
+
+// AuditRecord is an append-only record of a mutating api call or approval
+type AuditRecord struct {
+	ID        string    `json:"id,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Entity    string    `json:"entity,omitempty"`
+	Action    string    `json:"action,omitempty"`
+	// Caller is subject of bearer token, slack user of approvals, anonymous if auth is disabled
+	Caller     string `json:"caller,omitempty"`
+	RemoteAddr string `json:"remoteaddr,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Path       string `json:"path,omitempty"`
+	// Old and New are values changed by action with secrets redacted, New is request body if action
+	// has no value tracked
+	Old json.RawMessage `json:"old,omitempty"`
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// AuditFilter limits audit records to time range and action, zero values match all
+type AuditFilter struct {
+	Since  time.Time
+	Until  time.Time
+	Action string
+}
+
+func (f *AuditFilter) matches(record *AuditRecord) bool {
+	if !f.Since.IsZero() && record.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.Timestamp.After(f.Until) {
+		return false
+	}
+	return f.Action == "" || f.Action == record.Action
+}
+
+// auditSequence makes ids of records within the same nanosecond unique
+var auditSequence atomic.Uint32
+
+func auditPrefixKey(namespace, entity string) string {
+	return fmt.Sprintf("%s%s/%s/", auditPrefix, namespace, entity)
+}
+
+// secretKeys are parts of json keys whose values are redacted in audit records
+var secretKeys = []string{"secret", "token", "password", "apikey", "applicationkey", "accesskey"}
+
+// isSecretKey reports whether values of json key are secrets
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, secretKey := range secretKeys {
+		if strings.Contains(lower, secretKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets replaces string values of secret keys in decoded json
+func redactSecrets(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if _, ok := field.(string); ok && isSecretKey(key) && field != "" {
+				value[key] = RedactedSecret
+				continue
+			}
+			value[key] = redactSecrets(field)
+		}
+	case []any:
+		for i, field := range value {
+			value[i] = redactSecrets(field)
+		}
+	}
+	return value
+}
+
+// AuditValue encodes value with secrets redacted, nil if value is nil or not json
+func AuditValue(value any) json.RawMessage {
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = value
+	default:
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return nil
+		}
+	}
+	if len(data) <= 0 || len(data) > maxAuditValue {
+		return nil
+	}
+
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded == nil {
+		return nil
+	}
+	redacted, err := json.Marshal(redactSecrets(decoded))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// RecordAudit appends record to audit log of its namespace and entity
+func (e *Engine) RecordAudit(record *AuditRecord) error {
+	return recordAudit(e.store, record)
+}
+
+func recordAudit(s store.Store, record *AuditRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = nowUTC()
+	}
+	record.ID = fmt.Sprintf("%020d-%05d", record.Timestamp.UnixNano(), auditSequence.Add(1)%100000)
+	return s.SaveJSON(auditPrefixKey(record.Namespace, record.Entity)+record.ID, record)
+}
+
+// recordAudit appends record of entity to audit log
+func (e *Entity) recordAudit(record *AuditRecord) error {
+	record.Namespace = e.Namespace
+	record.Entity = e.Name
+	return recordAudit(e.store, record)
+}
+
+// GetAuditRecords returns audit records of entity newest first matching filter, skipping offset records
+// and returning at most limit records, empty entity returns records of namespace level calls
+func (e *Engine) GetAuditRecords(namespaceName, entityName string, filter AuditFilter, offset, limit int) ([]*AuditRecord, error) {
+	var records []*AuditRecord
+	err := e.store.LoadValues(auditPrefixKey(namespaceName, entityName), func(key, value any) error {
+		record := &AuditRecord{}
+		if err := json.Unmarshal([]byte(value.(string)), record); err != nil {
+			return err
+		}
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID > records[j].ID
+	})
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(records) {
+		return []*AuditRecord{}, nil
+	}
+	records = records[offset:]
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+	return records, nil
+}
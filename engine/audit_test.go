@@ -0,0 +1,27 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditValueRedactsSecrets(t *testing.T) {
+	value := AuditValue(map[string]any{
+		"url":    "http://hooks",
+		"secret": "hmac",
+		"auth":   map[string]any{"bearertoken": "token", "headers": []any{map[string]any{"password": "pass"}}},
+	})
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(value, &decoded))
+	assert.Equal(t, "http://hooks", decoded["url"])
+	assert.Equal(t, RedactedSecret, decoded["secret"])
+	auth := decoded["auth"].(map[string]any)
+	assert.Equal(t, RedactedSecret, auth["bearertoken"])
+	assert.Equal(t, RedactedSecret, auth["headers"].([]any)[0].(map[string]any)["password"])
+
+	assert.Nil(t, AuditValue(nil))
+	assert.Nil(t, AuditValue([]byte("not json")))
+}
@@ -0,0 +1,52 @@
+package engine
+
+import "fmt"
+
+// validateBatchPlan checks plan percentages are between 1 and 100 and do not decrease
+func (o *RolloutOptions) validateBatchPlan() error {
+	previous := 0
+	for _, percent := range o.BatchPlan {
+		if percent <= 0 || percent > 100 {
+			return fmt.Errorf("%w: percent %d must be between 1 and 100", ErrInvalidBatchPlan, percent)
+		}
+		if percent < previous {
+			return fmt.Errorf("%w: percent %d is less than previous stage %d", ErrInvalidBatchPlan, percent, previous)
+		}
+		previous = percent
+	}
+	return nil
+}
+
+// stageThreshold returns targets on rolling version once stage is complete, at least one target
+func stageThreshold(percent, totalTargets int) int {
+	threshold := percent * totalTargets / 100
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return threshold
+}
+
+// batchSize returns targets allowed in rollout at once, with a BatchPlan stage advances once
+// successful targets reach its percent and targets in rollout are limited to what completes the stage
+func (r *Rollout) batchSize(state *rolloutInfo) int {
+	options := r.options()
+	plan := options.BatchPlan
+	totalTargets := len(state.totalTargets)
+	if len(plan) <= 0 {
+		return options.BatchPercent * totalTargets / 100
+	}
+
+	if !r.rolloutInProgress() {
+		// setting lkg or rolling back uses last stage
+		return plan[len(plan)-1] * totalTargets / 100
+	}
+
+	stage := min(r.State.BatchStage, len(plan)-1)
+	for stage < len(plan)-1 && len(state.successTargets) >= stageThreshold(plan[stage], totalTargets) {
+		stage++
+		r.logger.Info().Int("BatchStage", stage).Int("BatchPercent", plan[stage]).Msg("Advancing to next batch plan stage")
+	}
+	r.State.BatchStage = stage
+
+	return max(stageThreshold(plan[stage], totalTargets)-len(state.successTargets), 0)
+}
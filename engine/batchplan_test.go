@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchPlanValidate(t *testing.T) {
+	assert.NoError(t, (&RolloutOptions{BatchPlan: []int{1, 5, 25, 100}}).validateBatchPlan())
+	assert.ErrorIs(t, (&RolloutOptions{BatchPlan: []int{0, 100}}).validateBatchPlan(), ErrInvalidBatchPlan)
+	assert.ErrorIs(t, (&RolloutOptions{BatchPlan: []int{50, 101}}).validateBatchPlan(), ErrInvalidBatchPlan)
+	assert.ErrorIs(t, (&RolloutOptions{BatchPlan: []int{25, 5}}).validateBatchPlan(), ErrInvalidBatchPlan)
+}
+
+func TestBatchPlanSize(t *testing.T) {
+	e, err := createEntity("TestBatchPlanSize", getLogger())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	var clientTargets []*ClientState
+	for i := 0; i < 100; i++ {
+		clientTargets = append(clientTargets, &ClientState{
+			Name:    fmt.Sprintf("clientTarget%d", i),
+			Version: "v1",
+			Message: "running successfully",
+		})
+	}
+	require.NoError(t, e.updateEntityTargets(clientTargets))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	rollout.State.RollingVersion = "v2"
+	rollout.State.LastKnownGoodVersion = "v1"
+	rollout.State.Options.BatchPlan = []int{1, 5, 25, 100}
+
+	// canary stage
+	state := createRolloutInfo(targets)
+	assert.Equal(t, 1, rollout.batchSize(state))
+	assert.Equal(t, 0, rollout.State.BatchStage)
+
+	// canary successful, up to 5% of targets
+	state.successTargets = targets[:1]
+	assert.Equal(t, 4, rollout.batchSize(state))
+	assert.Equal(t, 1, rollout.State.BatchStage)
+
+	// stages do not go back
+	state.successTargets = nil
+	assert.Equal(t, 5, rollout.batchSize(state))
+	assert.Equal(t, 1, rollout.State.BatchStage)
+
+	state.successTargets = targets[:25]
+	assert.Equal(t, 75, rollout.batchSize(state))
+	assert.Equal(t, 3, rollout.State.BatchStage)
+
+	// rolling back uses last stage
+	rollout.State.LastKnownBadVersion = "v2"
+	assert.Equal(t, 100, rollout.batchSize(createRolloutInfo(targets)))
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const (
+	// maxBulkEntities is number of entities accepted in one bulk orchestrate call
+	maxBulkEntities = 100
+	// bulkConcurrency is number of entities of a bulk call orchestrated at the same time
+	bulkConcurrency = 8
+)
+
+// BulkOrchestrateRequest reports targets of one entity in a bulk orchestrate call
+type BulkOrchestrateRequest struct {
+	Namespace    string         `json:"namespace,omitempty"`
+	Entity       string         `json:"entity,omitempty"`
+	ClientStates []*ClientState `json:"clientstates,omitempty"`
+}
+
+// BulkOrchestrateResult is outcome of one entity in a bulk orchestrate call,
+// ClientStates has assigned versions on success, Error is set on failure
+type BulkOrchestrateResult struct {
+	Namespace    string         `json:"namespace,omitempty"`
+	Entity       string         `json:"entity,omitempty"`
+	ClientStates []*ClientState `json:"clientstates,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	// StatusCode is http status of error, same as orchestrate call of entity would return
+	StatusCode int `json:"statuscode,omitempty"`
+	// QuotaWarning is set once namespace crosses warning percent of its quota
+	QuotaWarning string `json:"quotawarning,omitempty"`
+}
+
+// OrchestrateBulk orchestrates entities of requests concurrently, failure of an entity does not fail others,
+// results are in order of requests
+func (e *Engine) OrchestrateBulk(ctx context.Context, requests []*BulkOrchestrateRequest) ([]*BulkOrchestrateResult, error) {
+	if len(requests) > maxBulkEntities {
+		return nil, fmt.Errorf("%w: %d entities, at most %d", ErrInvalidBulkRequest, len(requests), maxBulkEntities)
+	}
+	for _, request := range requests {
+		if request == nil || request.Namespace == "" || request.Entity == "" {
+			return nil, fmt.Errorf("%w: namespace and entity are required", ErrInvalidBulkRequest)
+		}
+	}
+
+	results := make([]*BulkOrchestrateResult, len(requests))
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := &BulkOrchestrateResult{Namespace: request.Namespace, Entity: request.Entity}
+			requestCtx, counts := WithQuotaCounts(ctx)
+			clientStates, err := e.OrchestrateContext(requestCtx, request.Namespace, request.Entity, request.ClientStates)
+			if err != nil {
+				result.Error = err.Error()
+				result.StatusCode = ErrorStatus(err)
+			} else {
+				result.ClientStates = clientStates
+			}
+			result.QuotaWarning = e.QuotaWarning(request.Namespace, counts)
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
@@ -0,0 +1,18 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrchestrateBulkLimit(t *testing.T) {
+	const testName = "TestOrchestrateBulkLimit"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = engine.OrchestrateBulk(engine.ctx, make([]*BulkOrchestrateRequest, maxBulkEntities+1))
+	assert.ErrorIs(t, err, ErrInvalidBulkRequest)
+}
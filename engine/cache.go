@@ -0,0 +1,424 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+// cachedPrefixes are keys cached by stateCache, watched so changes of other replicas invalidate cached state
+var cachedPrefixes = []string{rolloutPrefix, entityTargetPrefix}
+
+// stateCache caches rollout and entity target state keyed by namespace/entity, writes go through to store
+// and update cached state, so orchestration does not reload rollout and every target from store
+type stateCache struct {
+	store.Store
+
+	lock     sync.Mutex
+	entities map[string]*entityCache
+}
+
+// entityCache is cached state of one entity
+type entityCache struct {
+	// generation is bumped on every write and invalidation, loads started before are not cached
+	generation    uint64
+	rolloutLoaded bool
+	// rollout is nil if rollout does not exist
+	rollout *string
+	// targets are all targets of entity by key, nil until loaded
+	targets map[string]string
+}
+
+type cachedValue struct {
+	key   string
+	value string
+}
+
+func newStateCache(s store.Store) *stateCache {
+	return &stateCache{Store: s, entities: make(map[string]*entityCache)}
+}
+
+// useStore sets store of engine, fronted by stateCache unless disableCache is set
+func (e *Engine) useStore(s store.Store, disableCache bool) {
+	e.store = s
+	if !disableCache {
+		e.cache = newStateCache(s)
+		e.store = e.cache
+	}
+}
+
+// cacheEntity returns namespace/entity owning key, false if key is not cached
+func cacheEntity(key string) (string, bool) {
+	if strings.HasPrefix(key, rolloutPrefix) {
+		return strings.TrimPrefix(key, rolloutPrefix), true
+	}
+	if strings.HasPrefix(key, entityTargetPrefix) {
+		parts := strings.SplitN(strings.TrimPrefix(key, entityTargetPrefix), "/", 3)
+		if len(parts) < 3 {
+			return "", false
+		}
+		return parts[0] + "/" + parts[1], true
+	}
+	return "", false
+}
+
+// entity returns cached state of entity, caller holds lock
+func (c *stateCache) entity(entity string) *entityCache {
+	cached, ok := c.entities[entity]
+	if !ok {
+		cached = &entityCache{}
+		c.entities[entity] = cached
+	}
+	return cached
+}
+
+func (c *stateCache) generation(entity string) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.entity(entity).generation
+}
+
+// update applies written value of key to cached state, nil value is a delete
+func (c *stateCache) update(key string, value *string) {
+	entity, ok := cacheEntity(key)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached := c.entity(entity)
+	cached.generation++
+	if strings.HasPrefix(key, rolloutPrefix) {
+		cached.rolloutLoaded = true
+		cached.rollout = value
+		return
+	}
+	if cached.targets == nil {
+		return
+	}
+	if value == nil {
+		delete(cached.targets, key)
+	} else {
+		cached.targets[key] = *value
+	}
+}
+
+// drop discards cached state of entity owning key
+func (c *stateCache) drop(key string) {
+	entity, ok := cacheEntity(key)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached := c.entity(entity)
+	cached.generation++
+	cached.rolloutLoaded = false
+	cached.rollout = nil
+	cached.targets = nil
+}
+
+// reset discards all cached state
+func (c *stateCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, cached := range c.entities {
+		cached.generation++
+		cached.rolloutLoaded = false
+		cached.rollout = nil
+		cached.targets = nil
+	}
+}
+
+// invalidate discards cached state changed in store, events of writes made through cache match cached
+// state and keep it
+func (c *stateCache) invalidate(event store.KeyEvent) {
+	entity, ok := cacheEntity(event.Key)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached := c.entity(entity)
+	if strings.HasPrefix(event.Key, rolloutPrefix) {
+		if cached.rolloutLoaded && matchesEvent(cached.rollout, event) {
+			return
+		}
+		cached.generation++
+		cached.rolloutLoaded = false
+		cached.rollout = nil
+		return
+	}
+
+	if cached.targets != nil {
+		var current *string
+		if value, ok := cached.targets[event.Key]; ok {
+			current = &value
+		}
+		if matchesEvent(current, event) {
+			return
+		}
+	}
+	cached.generation++
+	cached.targets = nil
+}
+
+func matchesEvent(current *string, event store.KeyEvent) bool {
+	if event.Deleted {
+		return current == nil
+	}
+	return current != nil && *current == event.Value
+}
+
+// cachedTargets returns cached targets of entity with prefix in key order, false if targets are not cached
+func (c *stateCache) cachedTargets(entity, prefix string) ([]cachedValue, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached := c.entity(entity)
+	if cached.targets == nil {
+		return nil, false
+	}
+
+	values := make([]cachedValue, 0, len(cached.targets))
+	for key, value := range cached.targets {
+		if strings.HasPrefix(key, prefix) {
+			values = append(values, cachedValue{key: key, value: value})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].key < values[j].key
+	})
+	return values, true
+}
+
+// loadTargets loads all targets of entity into cache, unless entity is written meanwhile
+func (c *stateCache) loadTargets(entity string) error {
+	generation := c.generation(entity)
+
+	targets := make(map[string]string)
+	err := c.Store.LoadValues(entityTargetPrefix+entity+"/", func(key, value any) error {
+		targets[key.(string)] = value.(string)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached := c.entity(entity)
+	if cached.generation == generation {
+		cached.targets = targets
+	}
+	return nil
+}
+
+// LoadValues serves targets of a single entity from cache
+func (c *stateCache) LoadValues(prefix string, iter store.ValueIterator) error {
+	entity, ok := cacheEntity(prefix)
+	if !ok || !strings.HasPrefix(prefix, entityTargetPrefix) {
+		return c.Store.LoadValues(prefix, iter)
+	}
+
+	values, ok := c.cachedTargets(entity, prefix)
+	if !ok {
+		if err := c.loadTargets(entity); err != nil {
+			return err
+		}
+		if values, ok = c.cachedTargets(entity, prefix); !ok {
+			// written while loading
+			return c.Store.LoadValues(prefix, iter)
+		}
+	}
+
+	for _, value := range values {
+		if err := iter(value.key, value.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryJsonPaths projects targets of a single entity from cache
+func (c *stateCache) QueryJsonPaths(prefix string, projection map[string]string, iter store.ValueIterator) error {
+	if _, ok := cacheEntity(prefix); !ok || !strings.HasPrefix(prefix, entityTargetPrefix) {
+		return c.Store.QueryJsonPaths(prefix, projection, iter)
+	}
+	return store.ProjectValues(c.LoadValues, prefix, projection, iter)
+}
+
+// LoadJSON serves rollouts and targets of loaded entities from cache
+func (c *stateCache) LoadJSON(key string, value interface{}) error {
+	entity, ok := cacheEntity(key)
+	if !ok {
+		return c.Store.LoadJSON(key, value)
+	}
+
+	c.lock.Lock()
+	cached := c.entity(entity)
+	generation := cached.generation
+	isRollout := strings.HasPrefix(key, rolloutPrefix)
+	var jsonValue *string
+	found := false
+	if isRollout && cached.rolloutLoaded {
+		jsonValue, found = cached.rollout, true
+	} else if !isRollout && cached.targets != nil {
+		if target, ok := cached.targets[key]; ok {
+			jsonValue = &target
+		}
+		found = true
+	}
+	c.lock.Unlock()
+
+	if found {
+		if jsonValue == nil {
+			return store.ErrKeyNotFound
+		}
+		return json.Unmarshal([]byte(*jsonValue), value)
+	}
+
+	if !isRollout {
+		return c.Store.LoadJSON(key, value)
+	}
+
+	var raw json.RawMessage
+	err := c.Store.LoadJSON(key, &raw)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return err
+	}
+
+	c.lock.Lock()
+	cached = c.entity(entity)
+	if cached.generation == generation {
+		cached.rolloutLoaded = true
+		cached.rollout = nil
+		if err == nil {
+			rollout := string(raw)
+			cached.rollout = &rollout
+		}
+	}
+	c.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, value)
+}
+
+// SaveJSON saves key and updates cached state
+func (c *stateCache) SaveJSON(key string, jsonValue interface{}) error {
+	if _, ok := cacheEntity(key); !ok {
+		return c.Store.SaveJSON(key, jsonValue)
+	}
+
+	value, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	if err := c.Store.SaveJSON(key, json.RawMessage(value)); err != nil {
+		c.drop(key)
+		return err
+	}
+	saved := string(value)
+	c.update(key, &saved)
+	return nil
+}
+
+// SaveJSONBatch saves keys and updates cached state
+func (c *stateCache) SaveJSONBatch(values map[string]any) error {
+	marshalled := make(map[string]any, len(values))
+	for key, jsonValue := range values {
+		value, err := json.Marshal(jsonValue)
+		if err != nil {
+			return err
+		}
+		marshalled[key] = json.RawMessage(value)
+	}
+
+	err := c.Store.SaveJSONBatch(marshalled)
+	for key, value := range marshalled {
+		if err != nil {
+			c.drop(key)
+			continue
+		}
+		saved := string(value.(json.RawMessage))
+		c.update(key, &saved)
+	}
+	return err
+}
+
+// Delete deletes key and removes it from cached state
+func (c *stateCache) Delete(key string) error {
+	if err := c.Store.Delete(key); err != nil {
+		c.drop(key)
+		return err
+	}
+	c.update(key, nil)
+	return nil
+}
+
+// DeletePrefix deletes keys with prefix and discards all cached state
+func (c *stateCache) DeletePrefix(prefix string) error {
+	defer c.reset()
+	return c.Store.DeletePrefix(prefix)
+}
+
+// cachingTxn records writes of transaction, applied to cached state once transaction commits
+type cachingTxn struct {
+	store.StoreTxn
+	writes []cachedWrite
+}
+
+type cachedWrite struct {
+	key   string
+	value *string
+}
+
+func (t *cachingTxn) SaveJSON(key string, jsonValue interface{}) error {
+	value, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	if err := t.StoreTxn.SaveJSON(key, json.RawMessage(value)); err != nil {
+		return err
+	}
+	saved := string(value)
+	t.writes = append(t.writes, cachedWrite{key: key, value: &saved})
+	return nil
+}
+
+func (t *cachingTxn) Delete(key string) error {
+	if err := t.StoreTxn.Delete(key); err != nil {
+		return err
+	}
+	t.writes = append(t.writes, cachedWrite{key: key})
+	return nil
+}
+
+// Txn runs fn in store transaction and updates cached state once it commits
+func (c *stateCache) Txn(fn func(txn store.StoreTxn) error) error {
+	var txn *cachingTxn
+	err := c.Store.Txn(func(storeTxn store.StoreTxn) error {
+		txn = &cachingTxn{StoreTxn: storeTxn}
+		return fn(txn)
+	})
+	if txn == nil {
+		return err
+	}
+
+	for _, write := range txn.writes {
+		if err != nil {
+			c.drop(write.key)
+			continue
+		}
+		c.update(write.key, write.value)
+	}
+	return err
+}
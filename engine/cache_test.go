@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cachedTarget struct {
+	Version string `json:"version"`
+}
+
+func loadCachedTargets(t *testing.T, s store.Store, prefix string) map[string]string {
+	targets := make(map[string]string)
+	require.NoError(t, s.LoadValues(prefix, func(key, value any) error {
+		targets[key.(string)] = value.(string)
+		return nil
+	}))
+	return targets
+}
+
+func TestStateCacheWriteThrough(t *testing.T) {
+	dbStore, err := store.NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer dbStore.Close()
+	cache := newStateCache(dbStore)
+
+	prefix := entityTargetPrefix + "ns/entity/"
+	require.NoError(t, cache.SaveJSON(prefix+"group/target1", &cachedTarget{Version: "v1"}))
+	assert.Equal(t, map[string]string{prefix + "group/target1": `{"version":"v1"}`}, loadCachedTargets(t, cache, prefix))
+
+	// written by another replica, not visible until invalidated
+	require.NoError(t, dbStore.SaveJSON(prefix+"group/target2", &cachedTarget{Version: "v2"}))
+	assert.Len(t, loadCachedTargets(t, cache, prefix), 1)
+
+	cache.invalidate(store.KeyEvent{Key: prefix + "group/target2", Value: `{"version":"v2"}`})
+	assert.Len(t, loadCachedTargets(t, cache, prefix), 2)
+
+	// own writes keep cached state
+	require.NoError(t, cache.SaveJSON(prefix+"group/target1", &cachedTarget{Version: "v3"}))
+	cache.invalidate(store.KeyEvent{Key: prefix + "group/target1", Value: `{"version":"v3"}`})
+	require.NoError(t, dbStore.Delete(prefix+"group/target2"))
+	assert.Equal(t, map[string]string{
+		prefix + "group/target1": `{"version":"v3"}`,
+		prefix + "group/target2": `{"version":"v2"}`,
+	}, loadCachedTargets(t, cache, prefix))
+
+	require.NoError(t, cache.Delete(prefix+"group/target1"))
+	target := &cachedTarget{}
+	assert.ErrorIs(t, cache.LoadJSON(prefix+"group/target1", target), store.ErrKeyNotFound)
+
+	rollout := rolloutPrefix + "ns/entity"
+	assert.ErrorIs(t, cache.LoadJSON(rollout, target), store.ErrKeyNotFound)
+	require.NoError(t, dbStore.SaveJSON(rollout, &cachedTarget{Version: "v1"}))
+	assert.ErrorIs(t, cache.LoadJSON(rollout, target), store.ErrKeyNotFound)
+	cache.invalidate(store.KeyEvent{Key: rollout, Value: `{"version":"v1"}`})
+	require.NoError(t, cache.LoadJSON(rollout, target))
+	assert.Equal(t, "v1", target.Version)
+}
+
+func TestStateCacheTxn(t *testing.T) {
+	dbStore, err := store.NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer dbStore.Close()
+	cache := newStateCache(dbStore)
+
+	rollout := rolloutPrefix + "ns/entity"
+	target := &cachedTarget{}
+	assert.ErrorIs(t, cache.LoadJSON(rollout, target), store.ErrKeyNotFound)
+
+	errDiscard := errors.New("discard")
+	assert.ErrorIs(t, cache.Txn(func(txn store.StoreTxn) error {
+		require.NoError(t, txn.SaveJSON(rollout, &cachedTarget{Version: "v1"}))
+		return errDiscard
+	}), errDiscard)
+	assert.ErrorIs(t, cache.LoadJSON(rollout, target), store.ErrKeyNotFound)
+
+	require.NoError(t, cache.Txn(func(txn store.StoreTxn) error {
+		return txn.SaveJSON(rollout, &cachedTarget{Version: "v2"})
+	}))
+	require.NoError(t, cache.LoadJSON(rollout, target))
+	assert.Equal(t, "v2", target.Version)
+	require.NoError(t, dbStore.LoadJSON(rollout, target))
+	assert.Equal(t, "v2", target.Version)
+}
+
+func TestWatchInvalidatesCachedState(t *testing.T) {
+	const testName = "TestWatchInvalidatesCachedState"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+	require.NotNil(t, engine.cache)
+
+	rollout := rolloutPrefix + testName + "/entity"
+	target := &cachedTarget{}
+	assert.ErrorIs(t, engine.store.LoadJSON(rollout, target), store.ErrKeyNotFound)
+
+	// rollout written by another replica sharing the store, written again until badger subscription is registered
+	require.Eventually(t, func() bool {
+		assert.NoError(t, engine.cache.Store.SaveJSON(rollout, &cachedTarget{Version: "v1"}))
+		return engine.store.LoadJSON(rollout, target) == nil && target.Version == "v1"
+	}, 5*time.Second, 50*time.Millisecond)
+}
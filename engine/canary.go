@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CanaryStatisticMean compares mean of metric values
+	CanaryStatisticMean = "mean"
+)
+
+// CanaryMetric compares a reported metric between canary targets on rolling version and baseline targets on lkg
+type CanaryMetric struct {
+	// Name of the metric as reported in ClientState Metrics
+	Name string `json:"name,omitempty"`
+	// Statistic is either mean or a percentile such as p50, p90, p99, defaults to mean
+	Statistic string `json:"statistic,omitempty"`
+	// TolerancePercent canary is allowed to be worse than baseline
+	TolerancePercent float64 `json:"tolerancepercent,omitempty"`
+	// HigherIsBetter for metrics like throughput, by default lower is better like latency or error rate
+	HigherIsBetter bool `json:"higherisbetter,omitempty"`
+	// MinSamples required in both canary and baseline before comparing, defaults to 1
+	MinSamples int `json:"minsamples,omitempty"`
+}
+
+// CanaryResult is the outcome of last comparison for a metric
+type CanaryResult struct {
+	Name     string  `json:"name,omitempty"`
+	Canary   float64 `json:"canary,omitempty"`
+	Baseline float64 `json:"baseline,omitempty"`
+	Failed   bool    `json:"failed,omitempty"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// computeStatistic returns mean or nearest rank percentile for values
+func computeStatistic(statistic string, values []float64) (float64, error) {
+	if len(values) <= 0 {
+		return 0, nil
+	}
+
+	statistic = strings.ToLower(statistic)
+	if statistic == "" || statistic == CanaryStatisticMean {
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		return sum / float64(len(values)), nil
+	}
+
+	if !strings.HasPrefix(statistic, "p") {
+		return 0, fmt.Errorf("unknown canary statistic %s", statistic)
+	}
+
+	percentile, err := strconv.ParseFloat(statistic[1:], 64)
+	if err != nil || percentile <= 0 || percentile > 100 {
+		return 0, fmt.Errorf("invalid canary percentile %s", statistic)
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank], nil
+}
+
+// isWorse checks if canary is worse than baseline beyond tolerance
+func (m *CanaryMetric) isWorse(canary, baseline float64) bool {
+	tolerance := math.Abs(baseline) * m.TolerancePercent / 100
+	if m.HigherIsBetter {
+		return canary < baseline-tolerance
+	}
+	return canary > baseline+tolerance
+}
+
+// analyzeCanary compares canary targets with baseline targets for configured metrics,
+// marks rolling version as bad if canary is worse for any metric
+func (r *Rollout) analyzeCanary(state *rolloutInfo) (bool, error) {
+	if len(r.State.Options.CanaryMetrics) <= 0 {
+		return false, nil
+	}
+
+	rollingVersion := r.State.RollingVersion
+	if r.State.LastKnownGoodVersion == "" ||
+		rollingVersion == r.State.LastKnownGoodVersion ||
+		rollingVersion == r.State.LastKnownBadVersion {
+		// nothing to compare without baseline or when not rolling forward
+		return false, nil
+	}
+
+	var results []CanaryResult
+	failed := false
+	for _, metric := range r.State.Options.CanaryMetrics {
+		var canaryValues, baselineValues []float64
+		for _, entityTarget := range state.totalTargets {
+			value, ok := entityTarget.State.Metrics[metric.Name]
+			if !ok {
+				continue
+			}
+			switch entityTarget.State.CurrentVersion.Version {
+			case rollingVersion:
+				canaryValues = append(canaryValues, value)
+			case r.State.LastKnownGoodVersion:
+				baselineValues = append(baselineValues, value)
+			}
+		}
+
+		minSamples := metric.MinSamples
+		if minSamples <= 0 {
+			minSamples = 1
+		}
+		if len(canaryValues) < minSamples || len(baselineValues) < minSamples {
+			r.logger.Debug().Str("Metric", metric.Name).Int("Canary", len(canaryValues)).Int("Baseline", len(baselineValues)).Msg("Not enough samples for canary analysis")
+			continue
+		}
+
+		canary, err := computeStatistic(metric.Statistic, canaryValues)
+		if err != nil {
+			return false, err
+		}
+		baseline, err := computeStatistic(metric.Statistic, baselineValues)
+		if err != nil {
+			return false, err
+		}
+
+		result := CanaryResult{Name: metric.Name, Canary: canary, Baseline: baseline}
+		if metric.isWorse(canary, baseline) {
+			result.Failed = true
+			result.Message = fmt.Sprintf("canary %s %g is worse than baseline %g beyond tolerance %g%%", metric.Name, canary, baseline, metric.TolerancePercent)
+			failed = true
+		}
+		results = append(results, result)
+	}
+
+	r.State.CanaryResults = results
+
+	if !failed {
+		return false, nil
+	}
+
+	r.logger.Error().Str("RollingVersion", rollingVersion).Msg("Canary analysis failed, marking rolling version as bad")
+	r.setLastKnownBad(rollingVersion, VersionMarkAnalysisFailed, "")
+
+	return true, nil
+}
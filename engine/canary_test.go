@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeStatistic(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 6, 7, 8, 10, 9}
+
+	mean, err := computeStatistic("", values)
+	require.NoError(t, err)
+	assert.InDelta(t, 5.5, mean, 0.001)
+
+	p50, err := computeStatistic("p50", values)
+	require.NoError(t, err)
+	assert.InDelta(t, 5.0, p50, 0.001)
+
+	p90, err := computeStatistic("P90", values)
+	require.NoError(t, err)
+	assert.InDelta(t, 9.0, p90, 0.001)
+
+	_, err = computeStatistic("p0", values)
+	require.Error(t, err)
+
+	_, err = computeStatistic("median", values)
+	require.Error(t, err)
+}
+
+func TestAnalyzeCanary(t *testing.T) {
+	e, clientTargets, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	for i, clientTarget := range clientTargets {
+		clientTarget.Metrics = map[string]float64{"latency": 100}
+		if i < 2 {
+			clientTarget.Version = "v2"
+			clientTarget.Metrics["latency"] = 105
+		}
+	}
+	require.NoError(t, e.updateEntityTargets(clientTargets))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	rollout.State.RollingVersion = "v2"
+	rollout.State.LastKnownGoodVersion = "v1"
+	rollout.State.Options.CanaryMetrics = []CanaryMetric{{Name: "latency", TolerancePercent: 10}}
+
+	failed, err := rollout.analyzeCanary(createRolloutInfo(targets))
+	require.NoError(t, err)
+	assert.False(t, failed)
+	require.Len(t, rollout.State.CanaryResults, 1)
+	assert.InDelta(t, 105.0, rollout.State.CanaryResults[0].Canary, 0.001)
+	assert.InDelta(t, 100.0, rollout.State.CanaryResults[0].Baseline, 0.001)
+
+	rollout.State.Options.CanaryMetrics = []CanaryMetric{{Name: "latency", Statistic: "p99", TolerancePercent: 1}}
+
+	failed, err = rollout.analyzeCanary(createRolloutInfo(targets))
+	require.NoError(t, err)
+	assert.True(t, failed)
+	assert.Equal(t, "v2", rollout.State.LastKnownBadVersion)
+	assert.True(t, rollout.State.CanaryResults[0].Failed)
+
+	// no more analysis once version is marked bad
+	failed, err = rollout.analyzeCanary(createRolloutInfo(targets))
+	require.NoError(t, err)
+	assert.False(t, failed)
+}
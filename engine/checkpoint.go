@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// RollbackScope decides which targets roll back to last known good version once rolling version is bad
+type RollbackScope string
+
+const (
+	// RollbackScopeAll rolls every target back to last known good version, default
+	RollbackScopeAll RollbackScope = "all"
+	// RollbackScopeBatch rolls back targets of the failing batch, healthy targets of earlier batches stay on
+	// the bad version until next target version rolls out or last known bad version is cleared
+	RollbackScopeBatch RollbackScope = "batch"
+)
+
+func (s RollbackScope) Validate() error {
+	switch s {
+	case "", RollbackScopeAll, RollbackScopeBatch:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidRollbackScope, s)
+	}
+}
+
+// rollbackScope returns configured scope, defaults to all
+func (o *RolloutOptions) rollbackScope() RollbackScope {
+	if o == nil || o.RollbackScope == "" {
+		return RollbackScopeAll
+	}
+	return o.RollbackScope
+}
+
+// RolloutCheckpoint is healthy targets of batches before the failing batch of a bad version, they keep the
+// version while targets of the failing batch roll back
+type RolloutCheckpoint struct {
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	// Targets kept on version, group/name of each target
+	Targets []string `json:"targets,omitempty"`
+}
+
+// checkpointActive returns true while rolling version is bad and a checkpoint of it holds targets
+func (r *Rollout) checkpointActive() bool {
+	checkpoint := r.State.Checkpoint
+	return checkpoint != nil && checkpoint.Version == r.State.RollingVersion &&
+		r.State.RollingVersion == r.State.LastKnownBadVersion
+}
+
+// updateCheckpoint records healthy targets of earlier batches once rolling version turns bad with
+// RollbackScopeBatch, targets assigned version since the last batch started or reporting errors roll back
+func (r *Rollout) updateCheckpoint(entityTargets EntityTargets) {
+	rollingVersion := r.State.RollingVersion
+	if r.State.Checkpoint != nil && r.State.Checkpoint.Version != rollingVersion {
+		r.State.Checkpoint = nil
+	}
+	if rollingVersion == "" || rollingVersion != r.State.LastKnownBadVersion ||
+		r.options().rollbackScope() != RollbackScopeBatch || r.State.Checkpoint != nil {
+		return
+	}
+
+	checkpoint := &RolloutCheckpoint{Version: rollingVersion, Timestamp: nowUTC()}
+	for _, entityTarget := range entityTargets {
+		state := entityTarget.State
+		if state.TargetVersion.Version != rollingVersion || state.CurrentVersion.Version != rollingVersion ||
+			!state.TargetVersion.ChangeTimestamp.Before(r.State.BatchTimestamp) ||
+			state.TargetVersion.LastMessage.IsError || state.CurrentVersion.LastMessage.IsError {
+			continue
+		}
+		checkpoint.Targets = append(checkpoint.Targets, entityTarget.Group+"/"+entityTarget.Name)
+	}
+	r.logger.Info().Int("CheckpointTargets", len(checkpoint.Targets)).Msg("Rolling back failing batch, keeping healthy targets of earlier batches")
+	r.State.Checkpoint = checkpoint
+}
+
+// withoutCheckpointTargets removes targets kept on bad rolling version by checkpoint, they are not part of rollback
+func (r *Rollout) withoutCheckpointTargets(entityTargets EntityTargets) EntityTargets {
+	if !r.checkpointActive() || len(r.State.Checkpoint.Targets) <= 0 {
+		return entityTargets
+	}
+
+	checkpointTargets := make(map[string]struct{}, len(r.State.Checkpoint.Targets))
+	for _, target := range r.State.Checkpoint.Targets {
+		checkpointTargets[target] = struct{}{}
+	}
+	var rollbackTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if _, ok := checkpointTargets[entityTarget.Group+"/"+entityTarget.Name]; ok {
+			continue
+		}
+		rollbackTargets = append(rollbackTargets, entityTarget)
+	}
+	return rollbackTargets
+}
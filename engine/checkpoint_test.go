@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkpointTarget(name, version string, changed time.Time, isError bool) *EntityTarget {
+	entityTarget := &EntityTarget{Name: name}
+	entityTarget.State.TargetVersion = EntityVersionInfo{Version: version, ChangeTimestamp: changed}
+	entityTarget.State.CurrentVersion = EntityVersionInfo{Version: version, LastMessage: Message{IsError: isError}}
+	return entityTarget
+}
+
+func TestRollbackCheckpoint(t *testing.T) {
+	options := DefaultRolloutOptions()
+	options.RollbackScope = RollbackScopeBatch
+	batchStarted := nowUTC()
+	rollout := &Rollout{State: RolloutState{
+		RolloutVersionInfo: RolloutVersionInfo{RollingVersion: "v2", LastKnownGoodVersion: "v1", LastKnownBadVersion: "v2"},
+		Options:            options,
+		BatchTimestamp:     batchStarted,
+	}}
+
+	healthy := checkpointTarget("healthy", "v2", batchStarted.Add(-time.Hour), false)
+	failingBatch := checkpointTarget("failingbatch", "v2", batchStarted.Add(time.Second), false)
+	failing := checkpointTarget("failing", "v2", batchStarted.Add(-time.Hour), true)
+	previous := checkpointTarget("previous", "v1", batchStarted.Add(-2*time.Hour), false)
+	targets := EntityTargets{healthy, failingBatch, failing, previous}
+
+	rollout.updateCheckpoint(targets)
+	require.NotNil(t, rollout.State.Checkpoint)
+	assert.Equal(t, "v2", rollout.State.Checkpoint.Version)
+	assert.Equal(t, []string{"/healthy"}, rollout.State.Checkpoint.Targets)
+	assert.Equal(t, EntityTargets{failingBatch, failing, previous}, rollout.withoutCheckpointTargets(targets))
+
+	// checkpoint is kept while version is bad, even once targets of failing batch rolled back
+	failingBatch.State.TargetVersion.Version = "v1"
+	rollout.updateCheckpoint(targets)
+	assert.Equal(t, []string{"/healthy"}, rollout.State.Checkpoint.Targets)
+
+	// clearing last known bad version resumes rollout of every target
+	rollout.State.LastKnownBadVersion = ""
+	assert.Len(t, rollout.withoutCheckpointTargets(targets), 4)
+
+	// next rolling version drops checkpoint
+	rollout.State.RollingVersion = "v3"
+	rollout.updateCheckpoint(targets)
+	assert.Nil(t, rollout.State.Checkpoint)
+
+	// default scope rolls back every target
+	rollout.State.RollingVersion, rollout.State.LastKnownBadVersion = "v2", "v2"
+	rollout.State.Options = DefaultRolloutOptions()
+	rollout.updateCheckpoint(targets)
+	assert.Nil(t, rollout.State.Checkpoint)
+	assert.Len(t, rollout.withoutCheckpointTargets(targets), 4)
+
+	assert.ErrorIs(t, (&RolloutOptions{BatchPercent: 10, RollbackScope: "group"}).Validate(), ErrInvalidRollbackScope)
+}
@@ -0,0 +1,26 @@
+package engine
+
+import "time"
+
+// Clock provides current time for rollout decisions,
+// tests replace DefaultClock to advance time without sleeping
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// DefaultClock used for all rollout timestamps and timeouts
+var DefaultClock Clock = systemClock{}
+
+func nowUTC() time.Time {
+	return DefaultClock.Now().UTC()
+}
+
+func timeSince(t time.Time) time.Duration {
+	return DefaultClock.Now().Sub(t)
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// HashCohortTargetController selects targets deterministically from a stable hash of target names,
+// same targets are always selected first as canaries across rollouts,
+// keeping performance baselines of canary targets comparable release over release
+type HashCohortTargetController struct {
+	NoOpEntityTargetController
+	// CohortPercent of targets which make up canary cohort, selected before any other target
+	CohortPercent int `json:"cohortpercent,omitempty"`
+	// Seed changes cohort membership without renaming targets
+	Seed string `json:"seed,omitempty"`
+}
+
+// cohortBuckets is resolution of hash buckets, a target is in cohort if its bucket < CohortPercent of buckets
+const cohortBuckets = 10000
+
+func (c *HashCohortTargetController) hash(target *ClientState) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(c.Seed))
+	h.Write([]byte{0})
+	h.Write([]byte(target.Group))
+	h.Write([]byte{0})
+	h.Write([]byte(target.Name))
+	return h.Sum64()
+}
+
+// InCohort checks if target belongs to canary cohort,
+// membership depends only on target name, group and seed so adding or removing targets does not change it
+func (c *HashCohortTargetController) InCohort(target *ClientState) bool {
+	return c.hash(target)%cohortBuckets < uint64(c.CohortPercent*cohortBuckets/100)
+}
+
+// TargetSelection orders cohort targets before others, both ordered by hash
+func (c *HashCohortTargetController) TargetSelection(targets []*ClientState, count int) ([]*ClientState, error) {
+	selected := append([]*ClientState(nil), targets...)
+	sort.SliceStable(selected, func(i, j int) bool {
+		iCohort, jCohort := c.InCohort(selected[i]), c.InCohort(selected[j])
+		if iCohort != jCohort {
+			return iCohort
+		}
+		return c.hash(selected[i]) < c.hash(selected[j])
+	})
+
+	if count >= 0 && len(selected) > count {
+		selected = selected[:count]
+	}
+
+	return selected, nil
+}
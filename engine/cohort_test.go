@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashCohortTargetSelection(t *testing.T) {
+	controller := &HashCohortTargetController{CohortPercent: 10}
+
+	var targets []*ClientState
+	for i := 0; i < 100; i++ {
+		targets = append(targets, &ClientState{Name: fmt.Sprintf("target%d", i)})
+	}
+
+	var cohort []string
+	for _, target := range targets {
+		if controller.InCohort(target) {
+			cohort = append(cohort, target.Name)
+		}
+	}
+	require.NotEmpty(t, cohort)
+	assert.InDelta(t, 10, len(cohort), 8)
+
+	selected, err := controller.TargetSelection(targets, len(cohort))
+	require.NoError(t, err)
+	for _, target := range selected {
+		assert.Contains(t, cohort, target.Name)
+	}
+
+	// same cohort is selected regardless of order or fleet changes
+	reversed := make([]*ClientState, 0, len(targets)+1)
+	for i := len(targets) - 1; i >= 0; i-- {
+		reversed = append(reversed, targets[i])
+	}
+	reversed = append(reversed, &ClientState{Name: "newtarget"})
+	again, err := controller.TargetSelection(reversed, 1)
+	require.NoError(t, err)
+	if !controller.InCohort(&ClientState{Name: "newtarget"}) {
+		assert.Equal(t, selected[0].Name, again[0].Name)
+	}
+
+	// cohort controller survives rollout serialization
+	bytes, err := json.Marshal(SerializedEntityTargetController{EntityTargetController: controller})
+	require.NoError(t, err)
+	var serialized SerializedEntityTargetController
+	require.NoError(t, json.Unmarshal(bytes, &serialized))
+	assert.Equal(t, controller, serialized.EntityTargetController)
+}
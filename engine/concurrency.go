@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConcurrencyPolicy decides how new target version is handled while a rollout is in progress
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyReplace retargets to latest target version once rolling version completes, default
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+	// ConcurrencyQueue queues target versions, each is rolled out in order after rolling version completes
+	ConcurrencyQueue ConcurrencyPolicy = "queue"
+	// ConcurrencyReject fails setting target version while a rollout is in progress
+	ConcurrencyReject ConcurrencyPolicy = "reject"
+)
+
+func (p ConcurrencyPolicy) Validate() error {
+	switch p {
+	case "", ConcurrencyReplace, ConcurrencyQueue, ConcurrencyReject:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidConcurrencyPolicy, p)
+	}
+}
+
+// concurrencyPolicy returns configured policy, defaults to replace
+func (o *RolloutOptions) concurrencyPolicy() ConcurrencyPolicy {
+	if o == nil || o.ConcurrencyPolicy == "" {
+		return ConcurrencyReplace
+	}
+	return o.ConcurrencyPolicy
+}
+
+// rolloutInProgress returns true if rolling version is neither last known good nor bad
+func (r *Rollout) rolloutInProgress() bool {
+	return r.State.RollingVersion != "" &&
+		!strings.EqualFold(r.State.RollingVersion, r.State.LastKnownGoodVersion) &&
+		!strings.EqualFold(r.State.RollingVersion, r.State.LastKnownBadVersion)
+}
+
+// guardTargetVersion applies concurrency policy, returns true if target version was queued
+func (r *Rollout) guardTargetVersion(entityTargetVersion EntityTargetVersion) (bool, error) {
+	switch r.State.Options.concurrencyPolicy() {
+	case ConcurrencyReject:
+		if r.rolloutInProgress() && !strings.EqualFold(entityTargetVersion.Version, r.State.RollingVersion) {
+			return false, fmt.Errorf("%w: rolling version %s", ErrRolloutInProgress, r.State.RollingVersion)
+		}
+	case ConcurrencyQueue:
+		if (r.rolloutInProgress() || len(r.State.QueuedVersions) > 0) &&
+			!strings.EqualFold(entityTargetVersion.Version, r.State.TargetVersion) {
+			r.queueTargetVersion(entityTargetVersion)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// queueTargetVersion appends version to queue, already queued version only updates its change info
+func (r *Rollout) queueTargetVersion(entityTargetVersion EntityTargetVersion) {
+	for i, queued := range r.State.QueuedVersions {
+		if strings.EqualFold(queued.Version, entityTargetVersion.Version) {
+			r.State.QueuedVersions[i] = entityTargetVersion
+			return
+		}
+	}
+
+	r.logger.Info().Str("QueuedVersion", entityTargetVersion.Version).Int("Queued", len(r.State.QueuedVersions)+1).Msg("Rollout in progress, queuing target version")
+	r.State.QueuedVersions = append(r.State.QueuedVersions, entityTargetVersion)
+}
+
+// dequeueTargetVersion promotes next queued version to target version once current target version is rolled out
+func (r *Rollout) dequeueTargetVersion() {
+	if len(r.State.QueuedVersions) <= 0 || !strings.EqualFold(r.State.TargetVersion, r.State.RollingVersion) {
+		return
+	}
+
+	next := r.State.QueuedVersions[0]
+	r.State.QueuedVersions = r.State.QueuedVersions[1:]
+	r.logger.Info().Str("TargetVersion", next.Version).Int("Queued", len(r.State.QueuedVersions)).Msg("Promoting queued target version")
+	r.State.TargetVersion = next.Version
+	r.State.TargetChange = next.ChangeInfo
+	r.State.TargetTimestamp = nowUTC()
+}
+
+// getQueuedVersions returns target versions waiting for in progress rollout
+func (e *Entity) getQueuedVersions() ([]EntityTargetVersion, error) {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+	if rollout.State.QueuedVersions == nil {
+		return []EntityTargetVersion{}, nil
+	}
+	return rollout.State.QueuedVersions, nil
+}
+
+// getQueuedVersions returns queued target versions of the entity
+func (n *Namespace) getQueuedVersions(entityName string) ([]EntityTargetVersion, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getQueuedVersions()
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyPolicyReject(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	options := DefaultRolloutOptions()
+	options.ConcurrencyPolicy = ConcurrencyReject
+	require.NoError(t, rollout.setRolloutOptions(options))
+	rollout.State.TargetVersion = "v2"
+	rollout.State.RollingVersion = "v2"
+
+	assert.ErrorIs(t, rollout.setTargetVersion(EntityTargetVersion{Version: "v3"}, false), ErrRolloutInProgress)
+	assert.Equal(t, "v2", rollout.State.TargetVersion)
+
+	// setting rolling version again is allowed
+	require.NoError(t, rollout.setTargetVersion(EntityTargetVersion{Version: "v2", ChangeInfo: ChangeInfo{Ticket: "CHG-1"}}, false))
+	assert.Equal(t, "CHG-1", rollout.State.TargetChange.Ticket)
+
+	// force bypasses concurrency policy
+	require.NoError(t, rollout.setTargetVersion(EntityTargetVersion{Version: "v3"}, true))
+	assert.Equal(t, "v3", rollout.State.TargetVersion)
+	assert.Equal(t, "v2", rollout.State.LastKnownBadVersion)
+
+	options.ConcurrencyPolicy = "bogus"
+	assert.ErrorIs(t, rollout.setRolloutOptions(options), ErrInvalidConcurrencyPolicy)
+}
+
+func TestConcurrencyPolicyQueue(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	options := DefaultRolloutOptions()
+	options.ConcurrencyPolicy = ConcurrencyQueue
+	require.NoError(t, rollout.setRolloutOptions(options))
+	rollout.State.TargetVersion = "v2"
+	rollout.State.RollingVersion = "v2"
+
+	require.NoError(t, rollout.setTargetVersion(EntityTargetVersion{Version: "v3"}, false))
+	require.NoError(t, rollout.setTargetVersion(EntityTargetVersion{Version: "v4"}, false))
+	require.NoError(t, rollout.setTargetVersion(EntityTargetVersion{Version: "v3", ChangeInfo: ChangeInfo{Ticket: "CHG-3"}}, false))
+
+	assert.Equal(t, "v2", rollout.State.TargetVersion)
+	require.Len(t, rollout.State.QueuedVersions, 2)
+	assert.Equal(t, "v3", rollout.State.QueuedVersions[0].Version)
+	assert.Equal(t, "CHG-3", rollout.State.QueuedVersions[0].Ticket)
+	assert.Equal(t, "v4", rollout.State.QueuedVersions[1].Version)
+
+	// rolling version is still in progress, nothing is promoted
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+	require.NoError(t, rollout.updateRollingVersion(createRolloutInfo(targets)))
+	assert.Equal(t, "v2", rollout.State.RollingVersion)
+
+	// rolling version completes, next queued version is promoted
+	rollout.State.LastKnownGoodVersion = "v2"
+	require.NoError(t, rollout.updateRollingVersion(createRolloutInfo(nil)))
+	assert.Equal(t, "v3", rollout.State.TargetVersion)
+	assert.Equal(t, "v3", rollout.State.RollingVersion)
+	assert.Equal(t, "CHG-3", rollout.State.RollingChange.Ticket)
+	require.Len(t, rollout.State.QueuedVersions, 1)
+
+	// rolling version fails, next queued version is promoted
+	rollout.State.LastKnownBadVersion = "v3"
+	require.NoError(t, rollout.updateRollingVersion(createRolloutInfo(nil)))
+	assert.Equal(t, "v4", rollout.State.RollingVersion)
+	assert.Empty(t, rollout.State.QueuedVersions)
+}
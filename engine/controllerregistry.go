@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ControllerKindTarget lists target controllers
+	ControllerKindTarget = "target"
+	// ControllerKindMonitoring lists monitoring controllers
+	ControllerKindMonitoring = "monitoring"
+)
+
+// TargetControllerFactory returns a new zero value target controller, settings are decoded into it
+type TargetControllerFactory func() EntityTargetController
+
+// MonitoringControllerFactory returns a new zero value monitoring controller, settings are decoded into it
+type MonitoringControllerFactory func() EntityMonitoringController
+
+// ControllerType is a registered controller with json schema of its settings
+type ControllerType struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// GoType is type name controllers are stored under
+	GoType string         `json:"gotype"`
+	Schema map[string]any `json:"schema"`
+}
+
+type registeredController struct {
+	name   string
+	goType string
+	target TargetControllerFactory
+	// monitoring is set for monitoring controllers instead of target
+	monitoring MonitoringControllerFactory
+}
+
+// controllerRegistry holds known controllers to decode stored controllers of entities,
+// stored controllers keep go type name so state written before registry was named still decodes
+var controllerRegistry = struct {
+	sync.RWMutex
+	target     map[string]registeredController
+	monitoring map[string]registeredController
+}{
+	target:     make(map[string]registeredController),
+	monitoring: make(map[string]registeredController),
+}
+
+func init() {
+	RegisterTargetController("noop", func() EntityTargetController { return &NoOpEntityTargetController{} })
+	RegisterTargetController("web", func() EntityTargetController { return &EntityWebTargetController{} })
+	RegisterTargetController("cohort", func() EntityTargetController { return &HashCohortTargetController{} })
+	RegisterTargetController("grpc", func() EntityTargetController { return &EntityGrpcTargetController{} })
+	RegisterTargetController("wasm", func() EntityTargetController { return &EntityWasmTargetController{} })
+	RegisterTargetController("slack", func() EntityTargetController { return &EntitySlackApprovalController{} })
+	RegisterTargetController("consul", func() EntityTargetController { return &EntityConsulTargetController{} })
+
+	RegisterMonitoringController("noop", func() EntityMonitoringController { return &NoOpEntityMonitoringController{} })
+	RegisterMonitoringController("web", func() EntityMonitoringController { return &EntityWebMonitoringController{} })
+	RegisterMonitoringController("prometheus", func() EntityMonitoringController { return &EntityPromMonitoringController{} })
+}
+
+// RegisterTargetController makes target controller created by factory known under name,
+// entities using it can then be loaded from store, registering a name again replaces it
+func RegisterTargetController(name string, factory TargetControllerFactory) {
+	controllerRegistry.Lock()
+	defer controllerRegistry.Unlock()
+	controllerRegistry.target[name] = registeredController{
+		name:   name,
+		goType: reflect.TypeOf(factory()).String(),
+		target: factory,
+	}
+}
+
+// RegisterMonitoringController makes monitoring controller created by factory known under name,
+// entities using it can then be loaded from store, registering a name again replaces it
+func RegisterMonitoringController(name string, factory MonitoringControllerFactory) {
+	controllerRegistry.Lock()
+	defer controllerRegistry.Unlock()
+	controllerRegistry.monitoring[name] = registeredController{
+		name:       name,
+		goType:     reflect.TypeOf(factory()).String(),
+		monitoring: factory,
+	}
+}
+
+// legacyTypePrefix is prefix of go type names built in controllers were stored under before engine
+// moved out of package core
+const legacyTypePrefix = "*core."
+
+// findController returns controller registered under name or go type name
+func findController(controllers map[string]registeredController, typeName string) (registeredController, bool) {
+	controllerRegistry.RLock()
+	defer controllerRegistry.RUnlock()
+	if controller, ok := controllers[typeName]; ok {
+		return controller, true
+	}
+	legacyTypeName := ""
+	if strings.HasPrefix(typeName, legacyTypePrefix) {
+		legacyTypeName = "*engine." + strings.TrimPrefix(typeName, legacyTypePrefix)
+	}
+	for _, controller := range controllers {
+		if controller.goType == typeName {
+			return controller, true
+		}
+	}
+	for _, controller := range controllers {
+		if legacyTypeName != "" && controller.goType == legacyTypeName {
+			return controller, true
+		}
+	}
+	return registeredController{}, false
+}
+
+// ControllerTypes lists registered target and monitoring controllers sorted by kind and name
+func ControllerTypes() []ControllerType {
+	controllerRegistry.RLock()
+	var types []ControllerType
+	for _, controller := range controllerRegistry.target {
+		types = append(types, ControllerType{Name: controller.name, Kind: ControllerKindTarget, GoType: controller.goType, Schema: jsonSchema(reflect.TypeOf(controller.target()))})
+	}
+	for _, controller := range controllerRegistry.monitoring {
+		types = append(types, ControllerType{Name: controller.name, Kind: ControllerKindMonitoring, GoType: controller.goType, Schema: jsonSchema(reflect.TypeOf(controller.monitoring()))})
+	}
+	controllerRegistry.RUnlock()
+
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Kind != types[j].Kind {
+			return types[i].Kind > types[j].Kind
+		}
+		return types[i].Name < types[j].Name
+	})
+	return types
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// jsonSchema describes json encoding of t
+func jsonSchema(t reflect.Type) map[string]any {
+	return typeSchema(t, nil)
+}
+
+// TypeSchema describes json encoding of t, named structs are added to components and referenced
+// if components is not nil, else inlined
+func TypeSchema(t reflect.Type, components map[string]any) map[string]any {
+	return typeSchema(t, components)
+}
+
+func typeSchema(t reflect.Type, components map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType, t.Implements(jsonMarshalerType), reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), components)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), components)}
+	case reflect.Struct:
+		if components != nil && t.Name() != "" {
+			return componentRef(t, components)
+		}
+		properties := make(map[string]any)
+		addProperties(t, properties, components)
+		return map[string]any{"type": "object", "properties": properties}
+	}
+	return map[string]any{}
+}
+
+// componentName returns name of type in components, types of other packages than engine and core,
+// which serves it over HTTP, are prefixed with package
+func componentName(t reflect.Type) string {
+	enginePath := reflect.TypeOf((*Engine)(nil)).Elem().PkgPath()
+	if t.PkgPath() == enginePath || t.PkgPath() == path.Join(path.Dir(enginePath), "core") {
+		return t.Name()
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// componentRef adds struct t to components and returns reference to it
+func componentRef(t reflect.Type, components map[string]any) map[string]any {
+	name := componentName(t)
+	if _, ok := components[name]; !ok {
+		// placeholder ends recursion of self referencing types
+		components[name] = map[string]any{}
+		properties := make(map[string]any)
+		addProperties(t, properties, components)
+		components[name] = map[string]any{"type": "object", "properties": properties}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// addProperties adds exported fields of struct t by json name, embedded structs without name are flattened
+func addProperties(t reflect.Type, properties map[string]any, components map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addProperties(embedded, properties, components)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type, components)
+	}
+}
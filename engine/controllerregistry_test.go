@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type registryTestController struct {
+	NoOpEntityTargetController
+	Endpoint string   `json:"endpoint"`
+	Retries  int      `json:"retries,omitempty"`
+	Labels   []string `json:"labels"`
+	Ignored  string   `json:"-"`
+}
+
+func TestRegisterTargetController(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RegisterTargetController("registry-test", func() EntityTargetController { return &registryTestController{} })
+			ControllerTypes()
+		}()
+	}
+	wg.Wait()
+
+	data, err := json.Marshal(SerializedEntityTargetController{&registryTestController{Endpoint: "http://controller"}})
+	require.NoError(t, err)
+
+	var controller SerializedEntityTargetController
+	require.NoError(t, json.Unmarshal(data, &controller))
+	require.IsType(t, &registryTestController{}, controller.EntityTargetController)
+	assert.Equal(t, "http://controller", controller.EntityTargetController.(*registryTestController).Endpoint)
+
+	// unknown controllers are left unset
+	var unknown SerializedEntityTargetController
+	require.NoError(t, json.Unmarshal([]byte(`{"Type":"*core.unknownController","Value":{}}`), &unknown))
+	assert.Nil(t, unknown.EntityTargetController)
+
+	// controllers stored before engine moved out of package core are still loaded
+	var legacy SerializedEntityTargetController
+	require.NoError(t, json.Unmarshal([]byte(`{"Type":"*core.EntityWebTargetController","Value":{"selection":"http://selection"}}`), &legacy))
+	require.IsType(t, &EntityWebTargetController{}, legacy.EntityTargetController)
+	assert.Equal(t, "http://selection", legacy.EntityTargetController.(*EntityWebTargetController).SelectionEndpoint)
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * foo *", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := parseCron(expr)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC) // friday
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 22 * * sat", time.Date(2024, time.March, 16, 22, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, time.March, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 2 29 feb *", time.Date(2028, time.February, 29, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week
+		{"0 0 20 * mon", time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		cron, err := parseCron(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.expected, cron.next(from), test.expr)
+	}
+
+	cron, err := parseCron("0 0 30 feb *")
+	require.NoError(t, err)
+	assert.True(t, cron.next(from).IsZero())
+}
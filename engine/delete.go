@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nixmade/orchestrator/redact"
+)
+
+// deleteEntityTargetByName deletes single target of the entity
+func (e *Entity) deleteEntityTargetByName(group, name string) error {
+	key := e.entityTargetKey(group, name)
+	entityTarget := &EntityTarget{}
+	if err := e.store.LoadJSON(key, entityTarget); err != nil {
+		return err
+	}
+
+	e.logger.Info().Str("Group", group).Str("EntityTarget", name).Msg("Deleting target")
+	if err := e.store.Delete(key); err != nil {
+		return err
+	}
+	return e.store.Delete(e.targetEventsKey(group, name))
+}
+
+// delete removes entity along with rollout state, targets, target events, history and notification config
+func (e *Entity) delete() error {
+	e.logger.Info().Msg("Deleting entity")
+
+	prefixes := []string{
+		fmt.Sprintf("%s%s/%s/", entityTargetPrefix, e.Namespace, e.Name),
+		e.rolloutHistoryPrefix(),
+		e.targetEventsPrefix(),
+		e.snapshotPrefix(),
+		e.analysisRunPrefix(),
+		e.wasmModulePrefix(),
+		idempotencyKey(e.Namespace, e.Name, ""),
+	}
+	for _, prefix := range prefixes {
+		if err := e.store.DeletePrefix(prefix); err != nil {
+			return err
+		}
+	}
+
+	keys := []string{
+		e.rolloutKey(),
+		e.journalKey(),
+		e.notificationKey(),
+		e.slackKey(),
+		e.slackApprovalKey(),
+		e.analysisKey(),
+		e.registryWatchKey(),
+		fmt.Sprintf("%s%s/%s", entityPrefix, e.Namespace, e.Name),
+	}
+	for _, key := range keys {
+		if err := e.store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteEntity deletes entity in namespace
+func (n *Namespace) deleteEntity(entityName string) error {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.delete()
+}
+
+// deleteEntityTarget deletes a single target of entity in namespace
+func (n *Namespace) deleteEntityTarget(entityName, group, name string) error {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.deleteEntityTargetByName(group, name)
+}
+
+// delete removes namespace along with all its entities
+func (n *Namespace) delete() error {
+	n.logger.Info().Msg("Deleting namespace")
+
+	entityKeyPrefix := fmt.Sprintf("%s%s/", entityPrefix, n.Name)
+	keys, err := n.store.LoadKeys(entityKeyPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := n.deleteEntity(strings.TrimPrefix(key, entityKeyPrefix)); err != nil {
+			return err
+		}
+	}
+
+	if err := n.setRedactionRules(&redact.Rules{}); err != nil {
+		return err
+	}
+
+	for _, key := range []string{namespaceSlackKey(n.Name), namespaceQuotaKey(n.Name), namespaceRedactionKey(n.Name), namespaceGroupRulesKey(n.Name), namespaceDependenciesKey(n.Name), namespaceFreezeKey(n.Name), namespaceKey(n.Name)} {
+		if err := n.store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteNamespaceEntityTarget(t *testing.T) {
+	const testName = "TestDeleteNamespaceEntityTarget"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	clientTargets, err := setupNamespace(engine, testName, "entity", 4)
+	require.NoError(t, err)
+	_, err = setupNamespace(engine, testName, "entity2", 2)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.DeleteEntityTarget(testName, "entity", "", clientTargets[0].Name))
+	assert.ErrorIs(t, engine.DeleteEntityTarget(testName, "entity", "", clientTargets[0].Name), store.ErrKeyNotFound)
+
+	clientStates, err := engine.GetClientState(testName, "entity")
+	require.NoError(t, err)
+	assert.Len(t, clientStates, 3)
+
+	require.NoError(t, engine.DeleteEntity(testName, "entity"))
+	_, err = engine.GetRolloutInfo(testName, "entity")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+
+	count, err := engine.store.Count(entityTargetPrefix + testName + "/entity/")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// other entities are not affected
+	clientStates, err = engine.GetClientState(testName, "entity2")
+	require.NoError(t, err)
+	assert.Len(t, clientStates, 2)
+
+	require.NoError(t, engine.DeleteNamespace(testName))
+	_, err = engine.GetEntites(testName)
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+
+	for _, prefix := range []string{entityPrefix, rolloutPrefix, entityTargetPrefix, rolloutHistoryPrefix, snapshotPrefix} {
+		count, err := engine.store.Count(prefix + testName + "/")
+		require.NoError(t, err)
+		assert.Zero(t, count, prefix)
+	}
+}
@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const dependenciesPrefix = "dependencies:"
+
+// EntityDependency holds new target versions of Entity until every entity of DependsOn reached that version
+type EntityDependency struct {
+	Entity    string   `json:"entity,omitempty"`
+	DependsOn []string `json:"dependson,omitempty"`
+}
+
+// EntityDependencies of namespace, entities without dependencies roll out independently
+type EntityDependencies struct {
+	Dependencies []EntityDependency `json:"dependencies,omitempty"`
+}
+
+// DependencyNode is an entity of dependency graph along with its rollout versions
+type DependencyNode struct {
+	Entity             string   `json:"entity,omitempty"`
+	DependsOn          []string `json:"dependson,omitempty"`
+	RolloutVersionInfo `json:",inline"`
+	// BlockedBy lists dependencies which did not reach pending target version of entity yet
+	BlockedBy []string `json:"blockedby,omitempty"`
+}
+
+// DependencyGraph of namespace, Stages orders entities so every entity only depends on entities of earlier stages
+type DependencyGraph struct {
+	Nodes  []DependencyNode `json:"nodes"`
+	Stages [][]string       `json:"stages"`
+}
+
+func namespaceDependenciesKey(namespace string) string {
+	return dependenciesPrefix + namespace
+}
+
+func (d *EntityDependencies) Validate() error {
+	seen := map[string]bool{}
+	for i, dependency := range d.Dependencies {
+		if dependency.Entity == "" {
+			return fmt.Errorf("%w: dependency %d has empty entity", ErrInvalidDependencies, i)
+		}
+		if seen[dependency.Entity] {
+			return fmt.Errorf("%w: entity %s is listed more than once", ErrInvalidDependencies, dependency.Entity)
+		}
+		seen[dependency.Entity] = true
+		for _, dependsOn := range dependency.DependsOn {
+			if dependsOn == "" || dependsOn == dependency.Entity {
+				return fmt.Errorf("%w: entity %s has empty dependency or depends on itself", ErrInvalidDependencies, dependency.Entity)
+			}
+		}
+	}
+
+	if _, err := d.stages(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// dependsOn returns dependencies of entity
+func (d *EntityDependencies) dependsOn(entity string) []string {
+	for _, dependency := range d.Dependencies {
+		if dependency.Entity == entity {
+			return dependency.DependsOn
+		}
+	}
+	return nil
+}
+
+// entities returns every entity of the graph, sorted
+func (d *EntityDependencies) entities() []string {
+	seen := map[string]bool{}
+	var entities []string
+	for _, dependency := range d.Dependencies {
+		for _, entity := range append([]string{dependency.Entity}, dependency.DependsOn...) {
+			if !seen[entity] {
+				seen[entity] = true
+				entities = append(entities, entity)
+			}
+		}
+	}
+	sort.Strings(entities)
+	return entities
+}
+
+// stages orders entities topologically, returns an error if dependencies form a cycle
+func (d *EntityDependencies) stages() ([][]string, error) {
+	remaining := d.entities()
+	done := map[string]bool{}
+	var stages [][]string
+	for len(remaining) > 0 {
+		var stage, blocked []string
+		for _, entity := range remaining {
+			ready := true
+			for _, dependsOn := range d.dependsOn(entity) {
+				if !done[dependsOn] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				stage = append(stage, entity)
+			} else {
+				blocked = append(blocked, entity)
+			}
+		}
+		if len(stage) <= 0 {
+			return nil, fmt.Errorf("%w: cycle between %s", ErrInvalidDependencies, strings.Join(blocked, ", "))
+		}
+		for _, entity := range stage {
+			done[entity] = true
+		}
+		stages = append(stages, stage)
+		remaining = blocked
+	}
+	return stages, nil
+}
+
+// findDependencies returns empty dependencies if not configured
+func findDependencies(dbStore store.Store, namespace string) (*EntityDependencies, error) {
+	dependencies := &EntityDependencies{}
+	err := dbStore.LoadJSON(namespaceDependenciesKey(namespace), dependencies)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+// findRolloutState returns rollout state of entity without loading its controllers, nil if entity has no rollout
+func findRolloutState(dbStore store.Store, namespace, entity string) (*RolloutState, error) {
+	var stored struct {
+		State RolloutState `json:"state"`
+	}
+	err := dbStore.LoadJSON(fmt.Sprintf("%s%s/%s", rolloutPrefix, namespace, entity), &stored)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored.State, nil
+}
+
+// reachedVersion returns true if version is last known good version of dependency,
+// or an older version for dependencies with semver version scheme
+func reachedVersion(state *RolloutState, version string) bool {
+	if state == nil || state.LastKnownGoodVersion == "" {
+		return false
+	}
+	if strings.EqualFold(state.LastKnownGoodVersion, version) {
+		return true
+	}
+	return state.Options.versionScheme() == VersionSchemeSemver &&
+		versionOrder(version, state.LastKnownGoodVersion) == VersionOrderOlder
+}
+
+// findBlockingDependencies returns dependencies of entity which did not reach version yet
+func findBlockingDependencies(dbStore store.Store, dependencies *EntityDependencies, namespace, entity, version string) ([]string, error) {
+	var blockedBy []string
+	for _, dependsOn := range dependencies.dependsOn(entity) {
+		state, err := findRolloutState(dbStore, namespace, dependsOn)
+		if err != nil {
+			return nil, err
+		}
+		if !reachedVersion(state, version) {
+			blockedBy = append(blockedBy, dependsOn)
+		}
+	}
+	return blockedBy, nil
+}
+
+// blockingDependencies returns dependencies which did not reach version, version is held until they do
+func (e *Entity) blockingDependencies(version string) ([]string, error) {
+	dependencies, err := findDependencies(e.store, e.Namespace)
+	if err != nil || len(dependencies.Dependencies) <= 0 {
+		return nil, err
+	}
+	return findBlockingDependencies(e.store, dependencies, e.Namespace, e.Name, version)
+}
+
+// setDependencies saves entity dependencies for the namespace
+func (n *Namespace) setDependencies(dependencies *EntityDependencies) error {
+	if err := dependencies.Validate(); err != nil {
+		return err
+	}
+
+	n.logger.Info().Int("Dependencies", len(dependencies.Dependencies)).Msg("Setting entity dependencies")
+	return n.store.SaveJSON(namespaceDependenciesKey(n.Name), dependencies)
+}
+
+// getDependencyGraph returns dependency graph of the namespace with rollout versions of its entities
+func (n *Namespace) getDependencyGraph() (*DependencyGraph, error) {
+	dependencies, err := findDependencies(n.store, n.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err := dependencies.stages()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &DependencyGraph{Nodes: []DependencyNode{}, Stages: stages}
+	if graph.Stages == nil {
+		graph.Stages = [][]string{}
+	}
+	for _, entity := range dependencies.entities() {
+		node := DependencyNode{Entity: entity, DependsOn: dependencies.dependsOn(entity)}
+		state, err := findRolloutState(n.store, n.Name, entity)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil {
+			node.RolloutVersionInfo = state.RolloutVersionInfo
+			rollout := &Rollout{State: *state}
+			if rollout.versionPending() {
+				if node.BlockedBy, err = findBlockingDependencies(n.store, dependencies, n.Name, entity, state.TargetVersion); err != nil {
+					return nil, err
+				}
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return graph, nil
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyStages(t *testing.T) {
+	dependencies := &EntityDependencies{Dependencies: []EntityDependency{
+		{Entity: "app", DependsOn: []string{"schema", "cache"}},
+		{Entity: "cache", DependsOn: []string{"schema"}},
+		{Entity: "worker", DependsOn: []string{"schema"}},
+	}}
+	require.NoError(t, dependencies.Validate())
+
+	stages, err := dependencies.stages()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"schema"}, {"cache", "worker"}, {"app"}}, stages)
+
+	dependencies.Dependencies = append(dependencies.Dependencies, EntityDependency{Entity: "schema", DependsOn: []string{"app"}})
+	assert.ErrorIs(t, dependencies.Validate(), ErrInvalidDependencies)
+
+	for _, invalid := range []EntityDependency{
+		{DependsOn: []string{"schema"}},
+		{Entity: "app", DependsOn: []string{"app"}},
+		{Entity: "app", DependsOn: []string{""}},
+	} {
+		dependencies := &EntityDependencies{Dependencies: []EntityDependency{invalid}}
+		assert.ErrorIs(t, dependencies.Validate(), ErrInvalidDependencies)
+	}
+}
+
+func TestEntityDependencies(t *testing.T) {
+	const testName = "TestEntityDependencies"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	targets := func(version string) []*ClientState {
+		var clientTargets []*ClientState
+		for i := 0; i < 4; i++ {
+			clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: version})
+		}
+		return clientTargets
+	}
+	rollOut := func(entity, version, reported string) {
+		require.NoError(t, engine.SetTargetVersion(testName, entity, EntityTargetVersion{Version: version}))
+		for i := 0; i < 2; i++ {
+			_, err := engine.Orchestrate(testName, entity, targets(reported))
+			require.NoError(t, err)
+		}
+	}
+
+	for _, entity := range []string{"schema", "app"} {
+		require.NoError(t, engine.SetRolloutOptions(testName, entity, &RolloutOptions{BatchPercent: 100}))
+		rollOut(entity, "v1", "v1")
+	}
+
+	require.NoError(t, engine.SetDependencies(testName, &EntityDependencies{Dependencies: []EntityDependency{
+		{Entity: "app", DependsOn: []string{"schema"}},
+	}}))
+
+	// app waits until schema reached v2
+	rollOut("app", "v2", "v1")
+	rollout, err := engine.GetRolloutInfo(testName, "app")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", rollout.RollingVersion)
+
+	graph, err := engine.GetDependencyGraph(testName)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"schema"}, {"app"}}, graph.Stages)
+	require.Len(t, graph.Nodes, 2)
+	assert.Equal(t, "app", graph.Nodes[0].Entity)
+	assert.Equal(t, "v2", graph.Nodes[0].TargetVersion)
+	assert.Equal(t, []string{"schema"}, graph.Nodes[0].BlockedBy)
+
+	rollOut("schema", "v2", "v2")
+	rollout, err = engine.GetRolloutInfo(testName, "schema")
+	require.NoError(t, err)
+	require.Equal(t, "v2", rollout.LastKnownGoodVersion)
+
+	_, err = engine.Orchestrate(testName, "app", targets("v1"))
+	require.NoError(t, err)
+	rollout, err = engine.GetRolloutInfo(testName, "app")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rollout.RollingVersion)
+
+	graph, err = engine.GetDependencyGraph(testName)
+	require.NoError(t, err)
+	assert.Empty(t, graph.Nodes[0].BlockedBy)
+
+	assert.ErrorIs(t, engine.SetDependencies(testName, &EntityDependencies{Dependencies: []EntityDependency{
+		{Entity: "app", DependsOn: []string{"schema"}},
+		{Entity: "schema", DependsOn: []string{"app"}},
+	}}), ErrInvalidDependencies)
+}
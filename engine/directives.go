@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"github.com/nixmade/orchestrator/store"
+)
+
+// AgentDirectives are returned to agents with assigned versions,
+// so agents and orchestrator agree on timing without separate configuration
+type AgentDirectives struct {
+	// Max duration in secs agent should spend applying a version before reporting failure
+	MaxUpdateDurationSecs int `json:"maxupdatedurationsecs,omitempty"`
+	// Grace period in secs after update before agent starts reporting health
+	HealthCheckGraceSecs int `json:"healthcheckgracesecs,omitempty"`
+	// Extra agent specific directives
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// findAgentDirectives returns directives configured in rollout options, nil if none are configured
+func (e *Entity) findAgentDirectives() (*AgentDirectives, error) {
+	rollout := &Rollout{}
+	err := e.store.LoadJSON(e.rolloutKey(), rollout)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rollout.State.Options == nil {
+		return nil, nil
+	}
+
+	return rollout.State.Options.AgentDirectives, nil
+}
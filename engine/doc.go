@@ -0,0 +1,22 @@
+// Package engine orchestrates rollouts of entity versions across targets.
+//
+// Services embedding the orchestrator use Engine directly, engine has no HTTP dependencies:
+//
+//	e, err := engine.New(engine.WithStore(s), engine.WithLogger(logger))
+//	e.Start()
+//	defer e.Stop()
+//
+// Embedders rely on the following API, which keeps backwards compatibility:
+//
+//   - New, Engine.Start and Engine.Stop create and run the engine
+//   - Engine.Orchestrate and Engine.OrchestrateContext assign versions to reported targets
+//   - Engine.SetTargetVersion and Engine.SetRolloutOptions change rollout of an entity
+//   - Engine.GetRolloutInfo, Engine.GetClientState, Engine.GetNamespaces and Engine.GetEntites query status
+//   - Engine.SetEntityTargetController and Engine.SetEntityMonitoringController set controllers of an entity,
+//     RegisterTargetController and RegisterMonitoringController make custom controllers loadable from store
+//   - Engine.RunBackgroundJob runs jobs on the leader replica
+//   - Engine.Subscribe streams rollout lifecycle and target events of the engine, every engine has its own
+//     event bus
+//
+// Package core serves Engine with the REST API.
+package engine
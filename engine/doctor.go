@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	// maxOrphanedTargetKeys is number of orphaned target keys listed in diagnostics, all of them are counted
+	maxOrphanedTargetKeys = 20
+	// diagnosePageSize is number of target keys loaded at once while looking for orphans
+	diagnosePageSize = 1000
+	// DefaultDialTimeout is how long Diagnose waits for a controller endpoint to accept a connection
+	DefaultDialTimeout = 5 * time.Second
+)
+
+// diagnosedPrefixes are key prefixes counted by Diagnose, keys of other prefixes are counted as other
+var diagnosedPrefixes = []string{
+	namespacePrefix, entityPrefix, rolloutPrefix, entityTargetPrefix, rolloutHistoryPrefix, snapshotPrefix,
+	journalPrefix, auditPrefix, idempotencyPrefix, analysisPrefix, analysisRunPrefix, notificationPrefix,
+	quotaPrefix, freezePrefix, groupRulesPrefix, redactionPrefix, registryWatchPrefix, slackPrefix,
+	slackApprovalPrefix, targetEventsPrefix, migrationPrefix,
+}
+
+// endpointController is implemented by controllers calling external services, endpoints are
+// urls or host:port addresses checked by Diagnose
+type endpointController interface {
+	endpoints() []string
+}
+
+// EndpointCheck is reachability of an endpoint of controller of an entity
+type EndpointCheck struct {
+	Namespace  string        `json:"namespace,omitempty"`
+	Entity     string        `json:"entity,omitempty"`
+	Controller string        `json:"controller,omitempty"`
+	Endpoint   string        `json:"endpoint,omitempty"`
+	Latency    time.Duration `json:"latency,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Diagnostics is report of Diagnose, failed checks are recorded instead of failing the whole report
+type Diagnostics struct {
+	// StoreError is set if store did not respond to ping, other checks are skipped
+	StoreError   string        `json:"storeerror,omitempty"`
+	StoreLatency time.Duration `json:"storelatency,omitempty"`
+	// KeyCounts is number of keys per prefix, keys of unknown prefixes are counted as other
+	KeyCounts map[string]uint64 `json:"keycounts,omitempty"`
+	// OrphanedTargets counts targets of entities that no longer exist, OrphanedTargetKeys lists the first of them
+	OrphanedTargets    int              `json:"orphanedtargets"`
+	OrphanedTargetKeys []string         `json:"orphanedtargetkeys,omitempty"`
+	Endpoints          []*EndpointCheck `json:"endpoints,omitempty"`
+	// Errors of checks that could not complete
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Healthy is true if store is reachable, there are no orphaned targets and all endpoints are reachable
+func (d *Diagnostics) Healthy() bool {
+	if d.StoreError != "" || d.OrphanedTargets > 0 || len(d.Errors) > 0 {
+		return false
+	}
+	for _, endpoint := range d.Endpoints {
+		if endpoint.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Diagnose checks store connectivity, counts keys per prefix, finds targets of deleted entities and dials
+// endpoints of controllers of every entity, waiting at most dialTimeout for each endpoint.
+// Store is only read, it is safe to diagnose store of a running server if its backend allows concurrent access
+func Diagnose(ctx context.Context, s store.Store, dialTimeout time.Duration) *Diagnostics {
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	d := &Diagnostics{}
+
+	start := time.Now()
+	if err := s.Ping(); err != nil {
+		d.StoreError = err.Error()
+		return d
+	}
+	d.StoreLatency = time.Since(start)
+
+	s = store.WithContext(ctx, s)
+	if err := d.countKeys(s); err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("failed to count keys: %v", err))
+	}
+	if err := d.findOrphanedTargets(s); err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("failed to find orphaned targets: %v", err))
+	}
+	if err := d.checkEndpoints(ctx, s, dialTimeout); err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("failed to check controller endpoints: %v", err))
+	}
+	return d
+}
+
+func (d *Diagnostics) countKeys(s store.Store) error {
+	d.KeyCounts = make(map[string]uint64)
+	var counted uint64
+	for _, prefix := range diagnosedPrefixes {
+		count, err := s.Count(prefix)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			d.KeyCounts[strings.TrimSuffix(prefix, ":")] = count
+		}
+		counted += count
+	}
+
+	total, err := s.Count("")
+	if err != nil {
+		return err
+	}
+	if total > counted {
+		d.KeyCounts["other"] = total - counted
+	}
+	return nil
+}
+
+// findOrphanedTargets pages through target keys, targets whose namespace/entity has no entity key are orphaned
+func (d *Diagnostics) findOrphanedTargets(s store.Store) error {
+	entityKeys, err := s.LoadKeys(entityPrefix)
+	if err != nil {
+		return err
+	}
+	entities := make(map[string]bool, len(entityKeys))
+	for _, key := range entityKeys {
+		entities[strings.TrimPrefix(key, entityPrefix)] = true
+	}
+
+	cursor := ""
+	for {
+		keys, next, err := s.LoadKeysN(entityTargetPrefix, cursor, diagnosePageSize)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			parts := strings.SplitN(strings.TrimPrefix(key, entityTargetPrefix), "/", 3)
+			if len(parts) == 3 && entities[parts[0]+"/"+parts[1]] {
+				continue
+			}
+			d.OrphanedTargets++
+			if len(d.OrphanedTargetKeys) < maxOrphanedTargetKeys {
+				d.OrphanedTargetKeys = append(d.OrphanedTargetKeys, key)
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// checkEndpoints dials endpoints of target and monitoring controllers of every rollout, each endpoint
+// is dialed once even if entities share it
+func (d *Diagnostics) checkEndpoints(ctx context.Context, s store.Store, dialTimeout time.Duration) error {
+	dialed := make(map[string]*EndpointCheck)
+	err := s.LoadValues(rolloutPrefix, func(key, value any) error {
+		rollout := &Rollout{}
+		if err := json.Unmarshal([]byte(value.(string)), rollout); err != nil {
+			d.Errors = append(d.Errors, fmt.Sprintf("failed to parse %s: %v", key, err))
+			return nil
+		}
+		namespace, entity, _ := strings.Cut(strings.TrimPrefix(key.(string), rolloutPrefix), "/")
+
+		for _, controller := range []any{rollout.TargetController.EntityTargetController, rollout.MonitoringController.EntityMonitoringController} {
+			withEndpoints, ok := controller.(endpointController)
+			if !ok {
+				continue
+			}
+			for _, endpoint := range withEndpoints.endpoints() {
+				check := &EndpointCheck{
+					Namespace:  namespace,
+					Entity:     entity,
+					Controller: reflect.TypeOf(controller).String(),
+					Endpoint:   endpoint,
+				}
+				if previous, ok := dialed[endpoint]; ok {
+					check.Latency, check.Error = previous.Latency, previous.Error
+				} else {
+					check.Latency, check.Error = dialEndpoint(ctx, endpoint, dialTimeout)
+					dialed[endpoint] = check
+				}
+				d.Endpoints = append(d.Endpoints, check)
+			}
+		}
+		return nil
+	})
+
+	sort.SliceStable(d.Endpoints, func(i, j int) bool {
+		if d.Endpoints[i].Namespace != d.Endpoints[j].Namespace {
+			return d.Endpoints[i].Namespace < d.Endpoints[j].Namespace
+		}
+		return d.Endpoints[i].Entity < d.Endpoints[j].Entity
+	})
+	return err
+}
+
+// endpointAddress returns host:port of url or host:port endpoint, port defaults to port of url scheme
+func endpointAddress(endpoint string) (string, error) {
+	if !strings.Contains(endpoint, "://") {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return "", err
+		}
+		return endpoint, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host in %s", endpoint)
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	case "http", "ws":
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	}
+	return "", fmt.Errorf("unknown scheme %s of %s", u.Scheme, endpoint)
+}
+
+// dialEndpoint opens and closes a tcp connection to endpoint, nothing is sent so controllers
+// see no calls, returns how long connecting took or why it failed
+func dialEndpoint(ctx context.Context, endpoint string, timeout time.Duration) (time.Duration, string) {
+	address, err := endpointAddress(endpoint)
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return 0, fmt.Sprintf("timed out after %s connecting to %s", timeout, address)
+		}
+		return 0, err.Error()
+	}
+	latency := time.Since(start)
+	if err := conn.Close(); err != nil {
+		return latency, err.Error()
+	}
+	return latency, ""
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnose(t *testing.T) {
+	const testName = "TestDiagnose"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = setupNamespace(engine, testName, "entity", 4)
+	require.NoError(t, err)
+	_, err = setupNamespace(engine, testName, "entity2", 2)
+	require.NoError(t, err)
+
+	controllerServer := httptest.NewServer(http.NotFoundHandler())
+	defer controllerServer.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	require.NoError(t, engine.SetEntityTargetController(testName, "entity", &EntityWebTargetController{
+		ApprovalEndpoint: controllerServer.URL + "/approval",
+		RemovalEndpoint:  controllerServer.URL + "/removal",
+	}))
+	require.NoError(t, engine.SetEntityMonitoringController(testName, "entity", &EntityWebMonitoringController{
+		ExternalMonitoringEndpoint: "http://" + closedAddress + "/monitoring",
+	}))
+
+	d := Diagnose(context.Background(), engine.store, time.Second)
+	assert.Empty(t, d.StoreError)
+	assert.Empty(t, d.Errors)
+	assert.Equal(t, uint64(6), d.KeyCounts["entitytarget"])
+	assert.Equal(t, uint64(2), d.KeyCounts["entity"])
+	assert.Zero(t, d.OrphanedTargets)
+
+	require.Len(t, d.Endpoints, 3)
+	for _, endpoint := range d.Endpoints {
+		assert.Equal(t, testName, endpoint.Namespace)
+		assert.Equal(t, "entity", endpoint.Entity)
+		if endpoint.Endpoint == "http://"+closedAddress+"/monitoring" {
+			assert.NotEmpty(t, endpoint.Error)
+		} else {
+			assert.Empty(t, endpoint.Error, endpoint.Endpoint)
+		}
+	}
+	assert.False(t, d.Healthy())
+
+	// targets of entity whose entity key is gone are orphaned
+	require.NoError(t, engine.store.Delete(entityPrefix+testName+"/entity2"))
+	d = Diagnose(context.Background(), engine.store, time.Second)
+	assert.Equal(t, 2, d.OrphanedTargets)
+	assert.Len(t, d.OrphanedTargetKeys, 2)
+}
+
+func TestEndpointAddress(t *testing.T) {
+	for endpoint, expected := range map[string]string{
+		"http://controller/approval":      "controller:80",
+		"https://controller/approval":     "controller:443",
+		"http://controller:8081/approval": "controller:8081",
+		"controller:50051":                "controller:50051",
+	} {
+		address, err := endpointAddress(endpoint)
+		require.NoError(t, err, endpoint)
+		assert.Equal(t, expected, address, endpoint)
+	}
+
+	for _, endpoint := range []string{"controller", "ftp://controller/", "http:///approval"} {
+		_, err := endpointAddress(endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
@@ -0,0 +1,1028 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/redact"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/nixmade/orchestrator/tracing"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	namespacePrefix = "namespace:"
+	// streamPageSize is number of targets loaded at a time while streaming client state
+	streamPageSize = 1000
+)
+
+// Namespace
+// | - Entity
+//     | - Target
+
+// Engine holds the namespaces serves
+type Engine struct {
+	ctx    context.Context
+	store  store.Store
+	logger zerolog.Logger
+	// workers run async orchestrations, drained on shutdown
+	workers *workerPool
+	// locks serialize orchestrations of the same entity
+	locks entityLocks
+	// distributedLocks also locks entity in store, serializing orchestrations across replicas
+	distributedLocks bool
+	// leadership runs background jobs while replica is leader
+	leadership leadership
+	// stopWatches cancels store watches invalidating cached state
+	stopWatches []store.CancelFunc
+	// cache serves rollout and target state, nil if disabled
+	cache *stateCache
+	// leaderElection runs background jobs only while replica holds leader lock
+	leaderElection bool
+	// started runs background jobs once
+	started sync.Once
+	// events receives rollout lifecycle and target events of this engine only
+	events *EventBus
+}
+
+// Provides an input config for new orchestrator engine
+type Config struct {
+	// Name for logs
+	ApplicationName string
+	// trace, debug, info, error, warn, fatal, panic
+	LogLevel string
+	// enable console logging
+	ConsoleLogging bool
+	// log dir to out json formatted logs
+	// these logs are auto rotated
+	LogDirectory string
+	// Store driver registered with store.Register, uses StoreDatabaseURL as dsn,
+	// defaults to postgres if StoreDatabaseURL is set otherwise badger
+	StoreDriver string
+	// Use postgres db
+	StoreDatabaseURL string
+	// postgres db schema
+	StoreDatabaseSchema string
+	// postgres db table
+	StoreDatabaseTable string
+	// Location to store badger db
+	StoreDirectory string
+	// Masterkey for encrypting badger store
+	StoreMasterKey string
+	// OTLP/HTTP endpoint to export traces, defaults to OTEL_EXPORTER_OTLP_ENDPOINT
+	TracingEndpoint string
+	// Log fields redacted from all logs, case insensitive
+	RedactFields []string
+	// Regular expressions redacted from all log values
+	RedactPatterns []string
+	// Read rollout and target state from store on every orchestration instead of caching it
+	DisableCache bool
+	// Number of workers running async orchestrations, defaults to DefaultWorkers
+	Workers int
+	// Lock entities in store while orchestrating, for replicas sharing a store
+	DistributedLocks bool
+	// Elect a leader among replicas sharing a store, only leader runs background jobs
+	LeaderElection bool
+}
+
+func namespaceKey(name string) string {
+	return namespacePrefix + name
+}
+
+func (e *Engine) getNamespace(name string) (*Namespace, error) {
+	return e.getNamespaceContext(e.ctx, name)
+}
+
+// getNamespaceContext finds or creates namespace, reads of namespace, its entities and targets stop once ctx is done
+func (e *Engine) getNamespaceContext(ctx context.Context, name string) (*Namespace, error) {
+	namespace, err := e.findNamespaceContext(ctx, name)
+	if errors.Is(err, ErrNamespaceNotFound) {
+		e.logger.Info().Msgf("Creating new namespace %s", name)
+		namespace, err = e.createNamespace(name)
+		if err != nil {
+			return nil, err
+		}
+		namespace.store = store.WithContext(ctx, e.store)
+		namespace.logger = e.logger.With().Str("Namespace", name).Logger()
+		namespace.quotaCounts = quotaCountsFrom(ctx)
+		namespace.events = e.events
+	}
+
+	return namespace, err
+}
+
+func (e *Engine) findNamespace(name string) (*Namespace, error) {
+	return e.findNamespaceContext(e.ctx, name)
+}
+
+// findNamespaceContext finds namespace, reads of namespace, its entities and targets stop once ctx is done
+func (e *Engine) findNamespaceContext(ctx context.Context, name string) (*Namespace, error) {
+	namespaceStore := store.WithContext(ctx, e.store)
+	namespace := &Namespace{}
+	if err := namespaceStore.LoadJSON(namespaceKey(name), namespace); err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, &NotFoundError{Kind: ErrNamespaceNotFound, Name: name}
+		}
+		return nil, err
+	}
+
+	namespace.store = namespaceStore
+	namespace.logger = e.logger.With().Str("Namespace", name).Logger()
+	namespace.quotaCounts = quotaCountsFrom(ctx)
+	namespace.events = e.events
+
+	return namespace, nil
+}
+
+// Save saves engine specific components only
+func (e *Engine) Save() error {
+	return nil
+}
+
+// SaveNamespaceEntity saves entityName with
+func (e *Engine) SaveNamespaceEntity(namespaceName, entityName string) error {
+	if err := e.Save(); err != nil {
+		return err
+	}
+
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.SaveEntity(entityName)
+}
+
+// Load loads list of namespaces for a userID
+func (e *Engine) Load() error {
+	e.logger.Info().Msg("Loading engine")
+
+	if err := e.migrate(migrations); err != nil {
+		return err
+	}
+
+	// watch before loading cached state, so changes made while loading are not missed
+	e.watchStore()
+	if err := e.loadRedactionRules(); err != nil {
+		return err
+	}
+
+	return e.recoverJournal()
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		ApplicationName:     "",
+		LogLevel:            "fatal",
+		ConsoleLogging:      true,
+		LogDirectory:        "",
+		StoreDatabaseURL:    "",
+		StoreDatabaseSchema: store.PUBLIC_SCHEMA,
+		StoreDatabaseTable:  store.TABLE_NAME,
+		StoreDirectory:      "",
+		StoreMasterKey:      "",
+		TracingEndpoint:     os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+	}
+}
+
+// NewOrchestratorEngine creates a new Orchestration Context
+func NewOrchestratorEngine(config *Config) (*Engine, error) {
+	level, err := zerolog.ParseLevel(strings.ToLower(config.LogLevel))
+	if err != nil {
+		level = zerolog.FatalLevel
+	}
+
+	writers := []io.Writer{}
+
+	if config.ConsoleLogging {
+		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr})
+	}
+
+	if config.LogDirectory != "" {
+		writers = append(writers, &lumberjack.Logger{
+			Filename:   path.Join(config.LogDirectory, "orchestrator.log"),
+			MaxBackups: 3,  // files
+			MaxSize:    10, // megabytes
+			MaxAge:     7,  // days
+		})
+	}
+
+	if err := redact.Default.SetRules(redact.Rules{Fields: config.RedactFields, Patterns: config.RedactPatterns}); err != nil {
+		return nil, err
+	}
+
+	logger := zerolog.New(redact.NewWriter(io.MultiWriter(writers...), redact.Default)).
+		With().
+		Str("Application", config.ApplicationName).
+		Caller().
+		Timestamp().
+		Logger().
+		Level(level)
+
+	driver, dsn := store.BadgerDriver, config.StoreDirectory
+	if config.StoreDriver != "" && config.StoreDriver != store.BadgerDriver {
+		driver, dsn = config.StoreDriver, config.StoreDatabaseURL
+	} else if config.StoreDriver == "" && config.StoreDatabaseURL != "" {
+		driver, dsn = store.PostgresDriver, config.StoreDatabaseURL
+	}
+	dbStore, err := store.Open(driver, dsn, store.Options{
+		EncryptionKey: config.StoreMasterKey,
+		Schema:        config.StoreDatabaseSchema,
+		Table:         config.StoreDatabaseTable,
+	})
+
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create store")
+		return nil, err
+	}
+
+	if tracing.Configure(config.TracingEndpoint, config.ApplicationName, logger) {
+		logger.Info().Str("Endpoint", config.TracingEndpoint).Msg("Tracing enabled")
+	}
+
+	logger.Info().Msg("Creating orchestrator engine")
+
+	e := &Engine{
+		ctx:              context.Background(),
+		logger:           logger,
+		workers:          newWorkerPool(config.Workers),
+		distributedLocks: config.DistributedLocks,
+		leaderElection:   config.LeaderElection,
+		events:           NewEventBus(),
+	}
+	e.useStore(store.NewMetricsStore(store.NewScanTrackingStore(dbStore, store.DefaultScanTracker)), config.DisableCache)
+
+	if err := e.Load(); err != nil {
+		return nil, err
+	}
+	e.Start()
+
+	//go e.saveStateAsync()
+
+	return e, nil
+}
+
+// newEngineWithConfig creates and loads engine using dbStore and logger, background jobs are not started
+func newEngineWithConfig(cfg *config.Config, dbStore store.Store, logger zerolog.Logger) (*Engine, error) {
+	logger.Info().Msg("Creating orchestrator engine")
+
+	e := &Engine{
+		ctx:              context.Background(),
+		logger:           logger,
+		workers:          newWorkerPool(cfg.Engine.Workers),
+		distributedLocks: cfg.Engine.DistributedLocks,
+		leaderElection:   cfg.Engine.LeaderElection,
+		events:           NewEventBus(),
+	}
+	e.useStore(dbStore, cfg.Store.DisableCache)
+	setWasmMaxLimits(cfg.Engine.Wasm)
+
+	if err := e.Load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Start runs scheduled rollouts, registry watches and jobs registered with RunBackgroundJob,
+// only while replica is leader if leader election is enabled, calling it again has no effect
+func (e *Engine) Start() {
+	e.started.Do(func() {
+		e.RunBackgroundJob("scheduled-rollouts", e.runScheduledRollouts)
+		e.RunBackgroundJob("stalled-rollouts", e.runStallDetection)
+		e.RunBackgroundJob("registry-watch", e.runRegistryWatches)
+		e.RunBackgroundJob("idempotency-cleanup", e.runIdempotencyCleanup)
+		e.startLeaderElection(e.leaderElection)
+	})
+}
+
+// Shutdown the engine when process is shutdown, waits for in flight async orchestrations
+func (e *Engine) Shutdown() error {
+	e.logger.Info().Msg("Shutdown orchestrator engine")
+	e.stopLeaderElection()
+	e.stopWatchingStore()
+	e.workers.close()
+	return nil
+}
+
+// Shutdown the engine when process is shutdown
+func (e *Engine) ShutdownAndClose() error {
+	if err := e.Shutdown(); err != nil {
+		return err
+	}
+	if err := tracing.Shutdown(); err != nil {
+		e.logger.Error().Err(err).Msg("failed to flush traces")
+	}
+	return e.store.Close()
+}
+
+// saveStateAsync saves all namespaces and associated entitied to storage
+// func (e *Engine) saveStateAsync() {
+// 	for {
+// 		if err := e.Save(); err != nil {
+// 			e.logger.Errorf("Failed to Save Engine")
+// 		}
+
+// 		var namespaceState []*Namespace
+// 		e.lock.Lock()
+// 		for _, namespace := range e.namespaces {
+// 			namespaceState = append(namespaceState, namespace)
+// 		}
+// 		e.lock.Unlock()
+
+// 		for _, namespace := range namespaceState {
+// 			if err := namespace.SaveNamespace(e.store, e.userID); err != nil {
+// 				e.logger.Errorf("Failed to Save Namespace '%s", namespace.name)
+// 			}
+// 		}
+// 		time.Sleep(10 * time.Second)
+// 	}
+// }
+
+// SetTargetVersion sets the target version
+func (e *Engine) SetTargetVersion(namespaceName, entityName string, targetVersion EntityTargetVersion) error {
+	return e.SetTargetVersionContext(e.ctx, namespaceName, entityName, targetVersion)
+}
+
+// SetTargetVersionContext is SetTargetVersion with ctx cancelling store reads once it is done
+func (e *Engine) SetTargetVersionContext(ctx context.Context, namespaceName, entityName string, targetVersion EntityTargetVersion) error {
+	namespace, err := e.getNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	if targetVersion.Version == "" {
+		return ErrInvalidTargetVersion
+	}
+
+	return entity.setTargetVersion(targetVersion, false)
+}
+
+// ForceTargetVersion sets the target version and marks current rolling version as bad
+// this allows target version to be promoted to rolling version
+func (e *Engine) ForceTargetVersion(namespaceName, entityName string, targetVersion EntityTargetVersion) error {
+	return e.ForceTargetVersionContext(e.ctx, namespaceName, entityName, targetVersion)
+}
+
+// ForceTargetVersionContext is ForceTargetVersion with ctx cancelling store reads once it is done
+func (e *Engine) ForceTargetVersionContext(ctx context.Context, namespaceName, entityName string, targetVersion EntityTargetVersion) error {
+	namespace, err := e.getNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	if targetVersion.Version == "" {
+		return ErrInvalidTargetVersion
+	}
+
+	return entity.setTargetVersion(targetVersion, true)
+}
+
+// SetRolloutOptions sets rollout options for the entity
+//
+//	caller should typically set this initially before calling orchestrate
+func (e *Engine) SetRolloutOptions(namespaceName string, entityName string, options *RolloutOptions) error {
+	return e.SetRolloutOptionsContext(e.ctx, namespaceName, entityName, options)
+}
+
+// SetRolloutOptionsContext is SetRolloutOptions with ctx cancelling store reads once it is done
+func (e *Engine) SetRolloutOptionsContext(ctx context.Context, namespaceName string, entityName string, options *RolloutOptions) error {
+	namespace, err := e.getNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.setRolloutOptions(options)
+}
+
+// SetEntityTargetController sets entity target controller for the entity
+//
+//	typically used for override
+func (e *Engine) SetEntityTargetController(namespaceName string, entityName string, controller EntityTargetController) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.setTargetController(controller)
+}
+
+// SetEntityTargetController sets entity target controller for the entity
+//
+//	typically used for override
+func (e *Engine) SetEntityMonitoringController(namespaceName string, entityName string, controller EntityMonitoringController) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.setMonitoringController(controller)
+}
+
+// Orchestrate list of input targets, modifies the state to record target state
+func (e *Engine) Orchestrate(namespaceName, entityName string, targets []*ClientState) ([]*ClientState, error) {
+	return e.OrchestrateContext(e.ctx, namespaceName, entityName, targets)
+}
+
+// OrchestrateContext is Orchestrate with ctx, store reads and remaining rollout phases are cancelled once it is done
+func (e *Engine) OrchestrateContext(ctx context.Context, namespaceName, entityName string, targets []*ClientState) ([]*ClientState, error) {
+	defer func(start time.Time) {
+		orchestrateDuration.WithLabelValues(namespaceName, entityName).Observe(time.Since(start).Seconds())
+	}(time.Now())
+
+	ctx, span := tracing.Start(ctx, "Orchestrate")
+	defer span.End()
+	span.SetAttributes(attribute.String("namespace", namespaceName), attribute.String("entity", entityName))
+
+	namespace, err := e.getNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	lockCtx, unlock, err := e.lockEntityContext(ctx, namespaceName, entityName)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	clientTargets, err := namespace.orchestrate(lockCtx, entityName, targets)
+	unlock()
+
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	// We should ideally just save the calling entity only
+	return clientTargets, e.SaveNamespaceEntity(namespaceName, entityName)
+}
+
+// OrchestrateAsync records the input state of targets, responds nothing
+// Clients call GetClientState to know the declarative state of targets
+func (e *Engine) OrchestrateAsync(namespaceName, entityName string, targets []*ClientState) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	// async orchestration outlives the call, its spans start a new trace
+	unlock, err := e.lockEntity(namespaceName, entityName)
+	if err != nil {
+		return err
+	}
+	err = namespace.orchestrateasync(entityName, targets, func(run func(ctx context.Context)) error {
+		return e.enqueueOrchestration(namespaceName, entityName, run)
+	})
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	// We should ideally just save the calling entity only
+	return e.SaveNamespaceEntity(namespaceName, entityName)
+}
+
+// Ready checks engine can serve requests, fails if store is unreachable
+func (e *Engine) Ready() error {
+	return e.store.Ping()
+}
+
+// GetClientState Gets Expected Client State for all the client state for the namespace, entity
+// This is an optional API where controller service reports partial status thought 1 API,
+// gets the current expected client state with another API
+func (e *Engine) GetClientState(namespaceName, entityName string) ([]*ClientState, error) {
+	return e.GetClientStateContext(e.ctx, namespaceName, entityName)
+}
+
+// GetClientStateContext is GetClientState with ctx cancelling store reads once it is done
+func (e *Engine) GetClientStateContext(ctx context.Context, namespaceName, entityName string) ([]*ClientState, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+	clientTargets, err := namespace.getClientState(entityName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return clientTargets, nil
+}
+
+// GetClientGroupState Gets Expected Client State for the client group state for the namespace, entity
+// This is an optional API where controller service reports partial status thought 1 API,
+// gets the current expected client group state with another API
+func (e *Engine) GetClientGroupState(namespaceName, entityName, groupName string) ([]*ClientState, error) {
+	return e.GetClientGroupStateContext(e.ctx, namespaceName, entityName, groupName)
+}
+
+// GetClientGroupStateContext is GetClientGroupState with ctx cancelling store reads once it is done
+func (e *Engine) GetClientGroupStateContext(ctx context.Context, namespaceName, entityName, groupName string) ([]*ClientState, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+	clientTargets, err := namespace.getClientGroupState(entityName, groupName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return clientTargets, nil
+}
+
+// GetClientStatePage Gets up to limit targets of Expected Client State for the namespace, entity and group
+// after cursor, group may be empty for all groups. Returned cursor fetches the next page and is empty on last page
+func (e *Engine) GetClientStatePage(namespaceName, entityName, groupName, cursor string, limit int) ([]*ClientState, string, error) {
+	return e.GetClientStatePageContext(e.ctx, namespaceName, entityName, groupName, cursor, limit)
+}
+
+// GetClientStatePageContext is GetClientStatePage with ctx cancelling store reads once it is done
+func (e *Engine) GetClientStatePageContext(ctx context.Context, namespaceName, entityName, groupName, cursor string, limit int) ([]*ClientState, string, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, "", err
+	}
+	return namespace.getClientStatePage(entityName, groupName, cursor, limit)
+}
+
+// StreamClientStateContext calls fn with pages of current target state of group, all groups if group is empty,
+// so targets of large entities are not held in memory at once, stops at first error of fn
+func (e *Engine) StreamClientStateContext(ctx context.Context, namespaceName, entityName, groupName string, fn func([]*ClientState) error) error {
+	cursor := ""
+	for {
+		clientTargets, next, err := e.GetClientStatePageContext(ctx, namespaceName, entityName, groupName, cursor, streamPageSize)
+		if err != nil {
+			return err
+		}
+		if err := fn(clientTargets); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// GetFilteredClientState Gets Expected Client State of targets matching filter for the namespace, entity,
+// such as targets assigned a version or targets in error
+func (e *Engine) GetFilteredClientState(namespaceName, entityName string, filter TargetFilter) ([]*ClientState, error) {
+	return e.GetFilteredClientStateContext(e.ctx, namespaceName, entityName, filter)
+}
+
+// GetFilteredClientStateContext is GetFilteredClientState with ctx cancelling store reads once it is done
+func (e *Engine) GetFilteredClientStateContext(ctx context.Context, namespaceName, entityName string, filter TargetFilter) ([]*ClientState, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+	return namespace.getFilteredClientState(entityName, filter)
+}
+
+// Below are mostly supporting cast for front end API
+
+// GetNamespaces returns a list of namespaces owned by the engine
+func (e *Engine) GetNamespaces() ([]string, error) {
+	return e.GetNamespacesContext(e.ctx)
+}
+
+// GetNamespacesContext is GetNamespaces with ctx cancelling store reads once it is done
+func (e *Engine) GetNamespacesContext(ctx context.Context) ([]string, error) {
+	namespaces, err := store.WithContext(ctx, e.store).LoadKeys(namespacePrefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return namespaces, nil
+}
+
+// GetEntites returns a list of entities owned by the namespace
+func (e *Engine) GetEntites(namespaceName string) ([]string, error) {
+	return e.GetEntitesContext(e.ctx, namespaceName)
+}
+
+// GetEntitesContext is GetEntites with ctx cancelling store reads once it is done
+func (e *Engine) GetEntitesContext(ctx context.Context, namespaceName string) ([]string, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getEntities()
+}
+
+// GetRolloutInfo returns current rollout information
+func (e *Engine) GetRolloutInfo(namespaceName, entityName string) (*RolloutState, error) {
+	return e.GetRolloutInfoContext(e.ctx, namespaceName, entityName)
+}
+
+// GetRolloutInfoContext is GetRolloutInfo with ctx cancelling store reads once it is done
+func (e *Engine) GetRolloutInfoContext(ctx context.Context, namespaceName, entityName string) (*RolloutState, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getRolloutInfo(entityName)
+}
+
+// GetQuarantinedTargets returns targets quarantined after consecutive failures
+func (e *Engine) GetQuarantinedTargets(namespaceName, entityName string) ([]*EntityTarget, error) {
+	return e.GetQuarantinedTargetsContext(e.ctx, namespaceName, entityName)
+}
+
+// GetQuarantinedTargetsContext is GetQuarantinedTargets with ctx cancelling store reads once it is done
+func (e *Engine) GetQuarantinedTargetsContext(ctx context.Context, namespaceName, entityName string) ([]*EntityTarget, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getQuarantinedTargets(entityName)
+}
+
+// ReleaseQuarantinedTarget manually releases target from quarantine, making it part of rollout again
+func (e *Engine) ReleaseQuarantinedTarget(namespaceName, entityName string, target *ClientState) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.releaseQuarantinedTarget(entityName, target.Group, target.Name)
+}
+
+// DecideSlackApproval approves or denies pending slack approval id of the entity on behalf of user,
+// batch is promoted or held on next orchestration
+func (e *Engine) DecideSlackApproval(namespaceName, entityName, id string, approved bool, user string) (*SlackApproval, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := namespace.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.decideSlackApproval(id, approved, user)
+}
+
+// DecideSlackAction decides approval of approve or deny button of an approval message clicked by user,
+// value is value of the button, message replacing the approval message is posted to responseURL in background.
+// It returns false if action is not an approval button, error only if value is invalid
+func (e *Engine) DecideSlackAction(actionID, value, user, responseURL string) (bool, error) {
+	if actionID != slackApproveAction && actionID != slackDenyAction {
+		return false, nil
+	}
+	var action slackApprovalAction
+	if err := json.Unmarshal([]byte(value), &action); err != nil {
+		return true, err
+	}
+
+	approval, err := e.DecideSlackApproval(action.Namespace, action.Entity, action.ID, actionID == slackApproveAction, user)
+
+	// slack expects a response within 3 seconds, original message is updated in background
+	message := &slackMessage{ReplaceOriginal: true}
+	if err != nil {
+		message = &slackMessage{ResponseType: "ephemeral", Text: fmt.Sprintf("Approval of `%s/%s` failed: %s", action.Namespace, action.Entity, err)}
+	} else {
+		emoji := ":white_check_mark:"
+		if approval.Status == ApprovalDenied {
+			emoji = ":no_entry:"
+		}
+		message.Text = fmt.Sprintf("%s Rollout of *%s* to %d targets of `%s/%s` %s by %s",
+			emoji, approval.Version, len(approval.Targets), action.Namespace, action.Entity, approval.Status, user)
+	}
+	if responseURL != "" {
+		go func() {
+			if err := postSlackMessage(context.Background(), responseURL, message); err != nil {
+				e.logger.Error().Err(err).Msg("Failed to update slack approval message")
+			}
+		}()
+	}
+	return true, nil
+}
+
+// GetRolloutHistory returns past rollouts newest first
+// offset skips number of records, limit <= 0 returns all remaining records
+func (e *Engine) GetRolloutHistory(namespaceName, entityName string, offset, limit int) ([]*RolloutHistory, error) {
+	return e.GetRolloutHistoryContext(e.ctx, namespaceName, entityName, offset, limit)
+}
+
+// GetRolloutHistoryContext is GetRolloutHistory with ctx cancelling store reads once it is done
+func (e *Engine) GetRolloutHistoryContext(ctx context.Context, namespaceName, entityName string, offset, limit int) ([]*RolloutHistory, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getRolloutHistory(entityName, offset, limit)
+}
+
+// SetNotificationConfig sets webhook notification config for the entity
+func (e *Engine) SetNotificationConfig(namespaceName, entityName string, config *NotificationConfig) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.setNotificationConfig(config)
+}
+
+// SetNamespaceSlackConfig sets slack notification config for all entities in the namespace
+func (e *Engine) SetNamespaceSlackConfig(namespaceName string, config *SlackConfig) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setSlackConfig(config)
+}
+
+// SetSlackConfig sets slack notification config for the entity, overrides namespace config
+func (e *Engine) SetSlackConfig(namespaceName, entityName string, config *SlackConfig) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	return entity.setSlackConfig(config)
+}
+
+// SetNamespaceQuota sets entity and target quota for the namespace
+func (e *Engine) SetNamespaceQuota(namespaceName string, quota *NamespaceQuota) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setQuota(quota)
+}
+
+// GetQueuedVersions returns target versions queued behind in progress rollout
+func (e *Engine) GetQueuedVersions(namespaceName, entityName string) ([]EntityTargetVersion, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getQueuedVersions(entityName)
+}
+
+// SetGroupRules sets rules assigning groups to targets reported without group
+func (e *Engine) SetGroupRules(namespaceName string, rules *GroupRules) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setGroupRules(rules)
+}
+
+// SetDependencies sets entities whose target versions wait until entities they depend on reached them
+func (e *Engine) SetDependencies(namespaceName string, dependencies *EntityDependencies) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setDependencies(dependencies)
+}
+
+// GetDependencyGraph returns entity dependencies of the namespace, their rollout order and blocked entities
+func (e *Engine) GetDependencyGraph(namespaceName string) (*DependencyGraph, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getDependencyGraph()
+}
+
+// GetGroupRules returns group assignment rules for the namespace
+func (e *Engine) GetGroupRules(namespaceName string) (*GroupRules, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getGroupRules()
+}
+
+// GetQuotaUsage returns quota usage for the namespace
+func (e *Engine) GetQuotaUsage(namespaceName string) (*QuotaUsage, error) {
+	return e.GetQuotaUsageContext(e.ctx, namespaceName)
+}
+
+// GetQuotaUsageContext is GetQuotaUsage with ctx cancelling store reads once it is done
+func (e *Engine) GetQuotaUsageContext(ctx context.Context, namespaceName string) (*QuotaUsage, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getQuotaUsage()
+}
+
+// GetSnapshots returns fleet snapshots of version distribution recorded since, oldest first
+func (e *Engine) GetSnapshots(namespaceName, entityName string, since time.Time) ([]*FleetSnapshot, error) {
+	return e.GetSnapshotsContext(e.ctx, namespaceName, entityName, since)
+}
+
+// GetSnapshotsContext is GetSnapshots with ctx cancelling store reads once it is done
+func (e *Engine) GetSnapshotsContext(ctx context.Context, namespaceName, entityName string, since time.Time) ([]*FleetSnapshot, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getSnapshots(entityName, since)
+}
+
+// DeleteNamespace deletes namespace along with all its entities, targets and rollout state
+func (e *Engine) DeleteNamespace(namespaceName string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.delete()
+}
+
+// DeleteEntity deletes entity along with its targets and rollout state
+func (e *Engine) DeleteEntity(namespaceName, entityName string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.deleteEntity(entityName)
+}
+
+// DeleteEntityTarget deletes a single target of the entity
+func (e *Engine) DeleteEntityTarget(namespaceName, entityName, group, name string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.deleteEntityTarget(entityName, group, name)
+}
+
+// Heartbeat records liveness of the target, returns current target state
+func (e *Engine) Heartbeat(namespaceName, entityName, group, name string) (*ClientState, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.heartbeat(entityName, group, name)
+}
+
+// Pause holds rollout progression of group while other groups continue, empty group pauses the entity
+func (e *Engine) Pause(namespaceName, entityName, group string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setPaused(entityName, group, true)
+}
+
+// SetLastKnownGood pins last known good version of entity to a version in its last known good history,
+// rollbacks target it from then on
+func (e *Engine) SetLastKnownGood(namespaceName, entityName string, override *VersionOverride) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.overrideLastKnownVersion(entityName, true, override)
+}
+
+// SetLastKnownBad marks version of entity bad, it is not rolled out until cleared
+func (e *Engine) SetLastKnownBad(namespaceName, entityName string, override *VersionOverride) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.overrideLastKnownVersion(entityName, false, override)
+}
+
+// ClearLastKnownBad clears last known bad version of entity, e.g. one wrongly marked bad by a flaky monitor
+func (e *Engine) ClearLastKnownBad(namespaceName, entityName string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.overrideLastKnownVersion(entityName, false, nil)
+}
+
+// SetSchedule sets rollout schedule of entity, nil schedule starts new target versions right away
+func (e *Engine) SetSchedule(namespaceName, entityName string, schedule *RolloutSchedule) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setSchedule(entityName, schedule)
+}
+
+// GetSchedule returns rollout schedule of entity and target version waiting for it
+func (e *Engine) GetSchedule(namespaceName, entityName string) (*ScheduleStatus, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getSchedule(entityName)
+}
+
+// GetRolloutProgress returns progress of rolling version and stall reason if rollout made no progress for stall timeout
+func (e *Engine) GetRolloutProgress(namespaceName, entityName string) (*RolloutProgress, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getProgress(entityName)
+}
+
+// Resume continues rollout progression of group, empty group resumes the entity
+func (e *Engine) Resume(namespaceName, entityName, group string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setPaused(entityName, group, false)
+}
+
+// SetNamespaceRedaction sets log redaction rules for the namespace, applied in addition to global rules
+func (e *Engine) SetNamespaceRedaction(namespaceName string, rules *redact.Rules) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setRedactionRules(rules)
+}
+
+// GetStoreScans returns in progress store scans running longer than minAge, for diagnosing pathological queries
+func (e *Engine) GetStoreScans(minAge time.Duration) []store.ScanInfo {
+	return store.DefaultScanTracker.Scans(minAge)
+}
+
+// CancelStoreScan cancels in progress store scan, scan fails with store.ErrScanCancelled before its next item
+func (e *Engine) CancelStoreScan(id uint64) error {
+	e.logger.Warn().Uint64("ScanID", id).Msg("Cancelling store scan")
+	return store.DefaultScanTracker.Cancel(id)
+}
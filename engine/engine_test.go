@@ -1,6 +1,7 @@
-package core
+package engine
 
 import (
+	"context"
 	"crypto/rand"
 	"flag"
 	"fmt"
@@ -24,11 +25,12 @@ func setupTestEngineWithStore(testName string, dbstore store.Store) (*Engine, er
 	}
 	logger := getLogger().With().Str("Test", testName).Logger()
 
-	app := NewApp()
-	app.dbStore = dbstore
-	app.logger = logger
-
-	return NewOrchestratorEngineWithApp(app)
+	e, err := New(WithStore(dbstore), WithLogger(logger))
+	if err != nil {
+		return nil, err
+	}
+	e.Start()
+	return e, nil
 }
 
 func setupTestEngine(testName string) (*Engine, error) {
@@ -400,7 +402,7 @@ func TestBlueGreenRollout(t *testing.T) {
 		return
 	}
 	defer cleanupTestEngine(t, engine, namespaceName)
-	RegisteredTargetControllers = append(RegisteredTargetControllers, &CustomEngineTestController{logger: engine.logger})
+	RegisterTargetController("custom-engine-test", func() EntityTargetController { return &CustomEngineTestController{logger: engine.logger} })
 
 	var clientTargets []*ClientState
 	clientTargets, err = setupNamespace(engine, namespaceName, entityName, numTargets)
@@ -514,7 +516,7 @@ func TestBlueGreenRollback(t *testing.T) {
 	const namespaceName = "TestBlueGreenRollback"
 	const entityName = "NewEntity"
 
-	RegisteredTargetControllers = append(RegisteredTargetControllers, &CustomEngineTestController{})
+	RegisterTargetController("custom-engine-test", func() EntityTargetController { return &CustomEngineTestController{} })
 
 	engine, err := setupTestEngine(namespaceName)
 	if err != nil {
@@ -830,3 +832,32 @@ func TestEngineSaveLoad(t *testing.T) {
 		return
 	}
 }
+
+func TestEngineContext(t *testing.T) {
+	const testName = "TestEngineContext"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.NoError(t, engine.SetTargetVersionContext(ctx, testName, testName, EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*ClientState{{Name: "clientTarget0", Version: "v1"}}
+	_, err = engine.OrchestrateContext(ctx, testName, testName, clientTargets)
+	require.NoError(t, err)
+	states, err := engine.GetClientStateContext(ctx, testName, testName)
+	require.NoError(t, err)
+	assert.Len(t, states, 1)
+
+	cancel()
+	_, err = engine.OrchestrateContext(ctx, testName, testName, clientTargets)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = engine.GetClientStateContext(ctx, testName, testName)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = engine.GetNamespacesContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// engine without context is unaffected
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,719 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+)
+
+const (
+	zombieTargetTimeout = 900 * time.Second
+	rolloutPrefix       = "rollout:"
+	entityTargetPrefix  = "entitytarget:"
+
+	// json paths of target fields queried with Store.QueryEquals
+	targetVersionPath = "$.state.targetversion.version"
+	targetErrorPath   = "$.state.targetversion.lastmessage.isError"
+	quarantinedPath   = "$.state.quarantined"
+)
+
+// targetSummaryProjection are json paths of target fields summarized by progress and split status,
+// projected with Store.QueryJsonPaths in one pass instead of loading whole targets
+var targetSummaryProjection = map[string]string{
+	"name":           "$.name",
+	"group":          "$.group",
+	"labels":         "$.labels",
+	"quarantined":    quarantinedPath,
+	"pin":            "$.state.pin",
+	"currentversion": "$.state.currentversion.version",
+	"currenterror":   "$.state.currentversion.lastmessage.isError",
+	"targetversion":  targetVersionPath,
+	"lastseen":       "$.state.lastseentimestamp",
+}
+
+// Entity has a list of Targets
+// We do need to serialize controller, which could be endpoints
+type Entity struct {
+	Name        string         `json:"name,omitempty"`
+	Namespace   string         `json:"namespace,omitempty"`
+	store       store.Store    `json:"-"`
+	logger      zerolog.Logger `json:"-"`
+	quotaCounts *QuotaCounts   `json:"-"`
+	// events of engine entity was loaded by, nil for entities outside of an engine
+	events *EventBus `json:"-"`
+}
+
+// newEntity returns entity of namespace without saving it
+func (n *Namespace) newEntity(name string) *Entity {
+	return &Entity{
+		Name:        name,
+		Namespace:   n.Name,
+		store:       n.store,
+		logger:      n.logger.With().Str("Entity", name).Logger(),
+		quotaCounts: n.quotaCounts,
+		events:      n.events,
+	}
+}
+
+// CreateEntity creates entity
+func (n *Namespace) createEntity(name string) (*Entity, error) {
+	n.logger.Info().Str("Entity", name).Msg("Creating new entity")
+
+	e := n.newEntity(name)
+
+	return e, n.store.SaveJSON(n.entityKey(name), e)
+}
+
+func (e *Entity) rolloutKey() string {
+	return fmt.Sprintf("%s%s/%s", rolloutPrefix, e.Namespace, e.Name)
+}
+
+func (e *Entity) entityTargetKey(group, name string) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s", entityTargetPrefix, e.Namespace, e.Name, group, name)
+}
+
+func (e *Entity) findOrCreateRollout() (*Rollout, error) {
+	rollout := &Rollout{}
+	err := e.store.LoadJSON(e.rolloutKey(), rollout)
+	if err == store.ErrKeyNotFound {
+		e.logger.Info().Msg("Creating new rollout")
+		rollout := &Rollout{
+			State: RolloutState{
+				RolloutVersionInfo: RolloutVersionInfo{},
+				Options:            DefaultRolloutOptions(),
+			},
+			TargetController:     SerializedEntityTargetController{EntityTargetController: &NoOpEntityTargetController{}},
+			MonitoringController: SerializedEntityMonitoringController{EntityMonitoringController: &NoOpEntityMonitoringController{}},
+			entity:               e,
+			logger:               e.logger,
+		}
+
+		return rollout, e.store.SaveJSON(e.rolloutKey(), rollout)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rollout.entity = e
+	rollout.logger = e.logger
+
+	return rollout, nil
+}
+
+func (e *Entity) getEntityTargets() ([]*EntityTarget, error) {
+	return e.getGroupEntityTargets("")
+}
+
+func (e *Entity) getGroupEntityTargets(groupName string) ([]*EntityTarget, error) {
+	prefix := fmt.Sprintf("%s%s/%s/%s", entityTargetPrefix, e.Namespace, e.Name, groupName)
+
+	var entityTargets []*EntityTarget
+	entityTargetItr := func(key any, value any) error {
+		entityTarget := &EntityTarget{}
+		if err := json.Unmarshal([]byte(value.(string)), entityTarget); err != nil {
+			return err
+		}
+		entityTargets = append(entityTargets, entityTarget)
+		return nil
+	}
+	err := e.store.LoadValues(prefix, entityTargetItr)
+	if err != nil {
+		return nil, err
+	}
+
+	return entityTargets, nil
+}
+
+// getEntityTargetSummaries returns targets of entity with only fields of targetSummaryProjection set
+func (e *Entity) getEntityTargetSummaries() (EntityTargets, error) {
+	prefix := fmt.Sprintf("%s%s/%s/", entityTargetPrefix, e.Namespace, e.Name)
+
+	var entityTargets EntityTargets
+	summaryItr := func(key any, value any) error {
+		projected, _ := value.(map[string]any)
+		summary, err := json.Marshal(map[string]any{
+			"name":   projected["name"],
+			"group":  projected["group"],
+			"labels": projected["labels"],
+			"state": map[string]any{
+				"quarantined":       projected["quarantined"],
+				"pin":               projected["pin"],
+				"currentversion":    map[string]any{"version": projected["currentversion"], "lastmessage": map[string]any{"isError": projected["currenterror"]}},
+				"targetversion":     map[string]any{"version": projected["targetversion"]},
+				"lastseentimestamp": projected["lastseen"],
+			},
+		})
+		if err != nil {
+			return err
+		}
+		entityTarget := &EntityTarget{}
+		if err := json.Unmarshal(summary, entityTarget); err != nil {
+			return err
+		}
+		entityTargets = append(entityTargets, entityTarget)
+		return nil
+	}
+	if err := e.store.QueryJsonPaths(prefix, targetSummaryProjection, summaryItr); err != nil {
+		return nil, err
+	}
+	return entityTargets, nil
+}
+
+// queryEntityTargets returns targets of entity where jsonPath of target equals value
+func (e *Entity) queryEntityTargets(jsonPath string, value any) ([]*EntityTarget, error) {
+	prefix := fmt.Sprintf("%s%s/%s/", entityTargetPrefix, e.Namespace, e.Name)
+
+	entityTargets := []*EntityTarget{}
+	entityTargetItr := func(key any, value any) error {
+		entityTarget := &EntityTarget{}
+		if err := json.Unmarshal([]byte(value.(string)), entityTarget); err != nil {
+			return err
+		}
+		entityTargets = append(entityTargets, entityTarget)
+		return nil
+	}
+	if err := e.store.QueryEquals(prefix, jsonPath, value, entityTargetItr); err != nil {
+		return nil, err
+	}
+
+	return entityTargets, nil
+}
+
+func (e *Entity) findOrCreateEntityTarget(clientTarget *ClientState) (*EntityTarget, error) {
+	entityTarget := &EntityTarget{}
+	err := e.store.LoadJSON(e.entityTargetKey(clientTarget.Group, clientTarget.Name), entityTarget)
+	if err == store.ErrKeyNotFound {
+		count, err := e.checkTargetQuota(1)
+		if err != nil {
+			return nil, err
+		}
+		e.quotaCounts.set(namespaceTargetsPrefix(e.Namespace), count)
+		rollout, err := e.findOrCreateRollout()
+		if err != nil {
+			return nil, err
+		}
+		entityTarget := e.newEntityTarget(clientTarget, rollout)
+		if err := e.store.SaveJSON(e.entityTargetKey(clientTarget.Group, clientTarget.Name), entityTarget); err != nil {
+			return nil, err
+		}
+		return entityTarget, e.saveTargetEvents(entityTarget)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entityTarget, nil
+}
+
+// newEntityTarget creates target reported by client, assigned last known good version of rollout
+func (e *Entity) newEntityTarget(clientTarget *ClientState, rollout *Rollout) *EntityTarget {
+	e.logger.Info().
+		Str("Name", clientTarget.Name).
+		Str("Group", clientTarget.Group).
+		Str("Version", clientTarget.Version).
+		Bool("IsError", clientTarget.IsError).
+		Msg("Creating new target")
+	nowTime := nowUTC()
+	entityTarget := &EntityTarget{
+		Name:   clientTarget.Name,
+		Group:  clientTarget.Group,
+		Tags:   clientTarget.Tags,
+		Labels: clientTarget.Labels,
+		State: EntityTargetState{
+			CurrentVersion: EntityVersionInfo{
+				Version:         clientTarget.Version,
+				ChangeTimestamp: nowTime.Add(-time.Second * time.Duration(rollout.options().SuccessTimeoutSecs)),
+				LastMessage: Message{
+					Message:   clientTarget.Message,
+					Timestamp: nowTime,
+					IsError:   clientTarget.IsError,
+				},
+			},
+			TargetVersion: EntityVersionInfo{
+				Version:         rollout.State.LastKnownGoodVersion,
+				ChangeTimestamp: nowTime.Add(-time.Second * time.Duration(rollout.options().SuccessTimeoutSecs)),
+				LastMessage: Message{
+					Message:   "new target, setting lkg",
+					Timestamp: nowTime,
+					IsError:   false,
+				},
+			},
+			LastUpdatedTimestamp: nowTime,
+			LastSeenTimestamp:    nowTime,
+		},
+	}
+	entityTarget.addEvent(TargetEventCreated, clientTarget.Version, clientTarget.Message)
+	return entityTarget
+}
+
+func (e *Entity) deleteEntityTarget(clientTarget *ClientState) error {
+	if err := e.store.Delete(e.entityTargetKey(clientTarget.Group, clientTarget.Name)); err != nil {
+		return err
+	}
+	return e.store.Delete(e.targetEventsKey(clientTarget.Group, clientTarget.Name))
+}
+
+func (e *Entity) setTargetController(controller EntityTargetController) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err = e.saveWasmModule(controller); err != nil {
+		return err
+	}
+
+	if err = rollout.setTargetController(controller); err != nil {
+		return err
+	}
+
+	if err = e.store.SaveJSON(e.rolloutKey(), rollout); err != nil {
+		return err
+	}
+
+	return e.deleteWasmModules(controller)
+}
+
+func (e *Entity) setMonitoringController(controller EntityMonitoringController) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err = rollout.setMonitoringController(controller); err != nil {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+func copyClientState(clientTarget *ClientState, entityTarget *EntityTarget) {
+	nowTime := nowUTC()
+	entityTarget.State.LastUpdatedTimestamp = nowTime
+	entityTarget.State.LastSeenTimestamp = nowTime
+	if entityTarget.State.CurrentVersion.Version != clientTarget.Version {
+		entityTarget.addEvent(TargetEventVersionReported, clientTarget.Version, clientTarget.Message)
+	}
+	if entityTarget.State.CurrentVersion.LastMessage.IsError != clientTarget.IsError {
+		eventType := TargetEventErrorCleared
+		if clientTarget.IsError {
+			eventType = TargetEventErrorReported
+		}
+		entityTarget.addEvent(eventType, clientTarget.Version, clientTarget.Message)
+	}
+	// record only on error switches or when version changes
+	if entityTarget.State.CurrentVersion.LastMessage.IsError != clientTarget.IsError ||
+		entityTarget.State.CurrentVersion.Version != clientTarget.Version {
+		entityTarget.State.CurrentVersion.LastMessage.Timestamp = nowTime
+	}
+	if entityTarget.State.CurrentVersion.Version != clientTarget.Version {
+		entityTarget.State.CurrentVersion.ChangeTimestamp = nowTime
+		entityTarget.State.CurrentVersion.Version = clientTarget.Version
+	}
+	entityTarget.State.CurrentVersion.LastMessage.Message = clientTarget.Message
+	entityTarget.State.CurrentVersion.LastMessage.IsError = clientTarget.IsError
+	entityTarget.State.Metrics = clientTarget.Metrics
+	// labels are kept if client does not report them
+	if clientTarget.Labels != nil {
+		entityTarget.Labels = clientTarget.Labels
+	}
+}
+
+func (e *Entity) updateEntityTarget(clientTarget *ClientState, entityTarget *EntityTarget) error {
+	e.logger.Info().
+		Str("Name", clientTarget.Name).
+		Str("Group", clientTarget.Group).
+		Str("Version", clientTarget.Version).
+		Bool("IsError", clientTarget.IsError).
+		Msg("Updating target")
+
+	copyClientState(clientTarget, entityTarget)
+
+	return e.saveEntityTarget(entityTarget)
+}
+
+// refreshes internal entity target state, existing targets are loaded and updated targets saved in a single batch
+func (e *Entity) updateEntityTargets(targets []*ClientState) error {
+	if err := e.assignGroups(targets); err != nil {
+		return err
+	}
+
+	entityTargets, err := e.getEntityTargets()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*EntityTarget, len(entityTargets))
+	for _, entityTarget := range entityTargets {
+		existing[e.entityTargetKey(entityTarget.Group, entityTarget.Name)] = entityTarget
+	}
+
+	newTargets := make(map[string]*ClientState)
+	for _, clientTarget := range targets {
+		key := e.entityTargetKey(clientTarget.Group, clientTarget.Name)
+		if _, ok := existing[key]; !ok {
+			newTargets[key] = clientTarget
+		}
+	}
+	if len(newTargets) > 0 {
+		count, err := e.checkTargetQuota(len(newTargets))
+		if err != nil {
+			return err
+		}
+		e.quotaCounts.set(namespaceTargetsPrefix(e.Namespace), count)
+		rollout, err := e.findOrCreateRollout()
+		if err != nil {
+			return err
+		}
+		for key, clientTarget := range newTargets {
+			existing[key] = e.newEntityTarget(clientTarget, rollout)
+		}
+	}
+
+	batch := make(map[string]any, len(targets))
+	var updatedTargets EntityTargets
+	for _, clientTarget := range targets {
+		key := e.entityTargetKey(clientTarget.Group, clientTarget.Name)
+		entityTarget := existing[key]
+		e.logger.Info().
+			Str("Name", clientTarget.Name).
+			Str("Group", clientTarget.Group).
+			Str("Version", clientTarget.Version).
+			Bool("IsError", clientTarget.IsError).
+			Msg("Updating target")
+		copyClientState(clientTarget, entityTarget)
+		if _, ok := batch[key]; !ok {
+			updatedTargets = append(updatedTargets, entityTarget)
+		}
+		batch[key] = entityTarget
+	}
+
+	if err := e.store.SaveJSONBatch(batch); err != nil {
+		return err
+	}
+	if err := e.saveTargetEvents(updatedTargets...); err != nil {
+		return err
+	}
+	for _, entityTarget := range updatedTargets {
+		e.publishTargetUpdated(entityTarget)
+	}
+
+	// cleanup zombie targets after specific timeout
+	return e.store.Txn(func(txn store.StoreTxn) error {
+		for _, entityTarget := range entityTargets {
+			if timeSince(entityTarget.State.LastUpdatedTimestamp) > zombieTargetTimeout {
+				if err := txn.Delete(e.entityTargetKey(entityTarget.Group, entityTarget.Name)); err != nil {
+					return err
+				}
+				if err := txn.Delete(e.targetEventsKey(entityTarget.Group, entityTarget.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (e *Entity) saveEntityTarget(entityTarget *EntityTarget) error {
+	if err := e.store.SaveJSON(e.entityTargetKey(entityTarget.Group, entityTarget.Name), entityTarget); err != nil {
+		return err
+	}
+	if err := e.saveTargetEvents(entityTarget); err != nil {
+		return err
+	}
+
+	e.publishTargetUpdated(entityTarget)
+	return nil
+}
+
+// checkpoint internal entity target state in a single batch
+func (e *Entity) saveEntityTargets(entityTargets EntityTargets) error {
+	if len(entityTargets) <= 0 {
+		return nil
+	}
+
+	batch := make(map[string]any, len(entityTargets))
+	for _, entityTarget := range entityTargets {
+		batch[e.entityTargetKey(entityTarget.Group, entityTarget.Name)] = entityTarget
+	}
+	if err := e.store.SaveJSONBatch(batch); err != nil {
+		return err
+	}
+	if err := e.saveTargetEvents(entityTargets...); err != nil {
+		return err
+	}
+
+	for _, entityTarget := range entityTargets {
+		e.publishTargetUpdated(entityTarget)
+	}
+	return nil
+}
+
+// returns updated Client state from current Entity Target state matching groupName
+func (e *Entity) returnClientGroupState(groupName string) ([]*ClientState, error) {
+	entityTargets, err := e.getGroupEntityTargets(groupName)
+	if err != nil {
+		return nil, err
+	}
+	directives, err := e.findAgentDirectives()
+	if err != nil {
+		return nil, err
+	}
+	order, err := e.findVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+	var retTargets []*ClientState
+	for _, entityTarget := range entityTargets {
+		clientTarget := returnClientTarget(entityTarget)
+		e.logger.Debug().
+			Str("Name", clientTarget.Name).
+			Str("Group", clientTarget.Group).
+			Str("Version", clientTarget.Version).
+			Str("LastMessage", clientTarget.Message).
+			Bool("IsError", clientTarget.IsError).
+			Msg("Returning Target")
+		clientTarget.Directives = directives
+		if order != nil {
+			clientTarget.VersionOrder = order(clientTarget.Version)
+		}
+		retTargets = append(retTargets, clientTarget)
+	}
+	return retTargets, nil
+}
+
+// returns page of up to limit client states matching groupName after cursor, only keys and values
+// of the page are loaded, cursor is target group/name relative to entity
+func (e *Entity) returnClientGroupStatePage(groupName, cursor string, limit int) ([]*ClientState, string, error) {
+	entityPrefix := fmt.Sprintf("%s%s/%s/", entityTargetPrefix, e.Namespace, e.Name)
+	keyCursor := ""
+	if cursor != "" {
+		keyCursor = entityPrefix + cursor
+	}
+
+	keys, next, err := e.store.LoadKeysN(entityPrefix+groupName, keyCursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	directives, err := e.findAgentDirectives()
+	if err != nil {
+		return nil, "", err
+	}
+	order, err := e.findVersionOrder()
+	if err != nil {
+		return nil, "", err
+	}
+
+	retTargets := make([]*ClientState, 0, len(keys))
+	for _, key := range keys {
+		entityTarget := &EntityTarget{}
+		if err := e.store.LoadJSON(key, entityTarget); err != nil {
+			if err == store.ErrKeyNotFound {
+				// target removed after keys were loaded
+				continue
+			}
+			return nil, "", err
+		}
+		clientTarget := returnClientTarget(entityTarget)
+		clientTarget.Directives = directives
+		if order != nil {
+			clientTarget.VersionOrder = order(clientTarget.Version)
+		}
+		retTargets = append(retTargets, clientTarget)
+	}
+
+	return retTargets, strings.TrimPrefix(next, entityPrefix), nil
+}
+
+// returnClientTarget converts entity target to client state with target version
+func returnClientTarget(entityTarget *EntityTarget) *ClientState {
+	return &ClientState{
+		Name:        entityTarget.Name,
+		Group:       entityTarget.Group,
+		Labels:      entityTarget.Labels,
+		Version:     entityTarget.State.TargetVersion.Version,
+		Message:     fmt.Sprintf("%s at %s", entityTarget.State.TargetVersion.LastMessage.Message, entityTarget.State.TargetVersion.LastMessage.Timestamp),
+		IsError:     entityTarget.State.TargetVersion.LastMessage.IsError,
+		LastSeen:    entityTarget.State.LastSeenTimestamp,
+		Pin:         entityTarget.State.Pin,
+		Quarantined: entityTarget.State.Quarantined,
+	}
+}
+
+// returns updated Client state from current Entity Target state
+func (e *Entity) returnClientState() ([]*ClientState, error) {
+	return e.returnClientGroupState("")
+}
+
+// SetTargetVersion sets the targetversion
+func (e *Entity) setTargetVersion(version EntityTargetVersion, force bool) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err = rollout.setTargetVersion(version, force); err != nil {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+// SetRolloutOptions sets the rolloutOptions
+func (e *Entity) setRolloutOptions(options *RolloutOptions) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err = rollout.setRolloutOptions(options); err != nil {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+// Orchestrate over current entity and list of targets
+// Checks if there is an ongoing rollout, takes the current state and determines target state
+// If no rollout, Checks if there is a new version/action for registered entities
+// Creates new rollout if required
+// Orchestrate rollouts in order
+func (e *Entity) orchestrate(ctx context.Context, targets []*ClientState) ([]*ClientState, error) {
+	e.logger.Info().Msg("Refreshing target state")
+
+	if err := e.updateEntityTargets(targets); err != nil {
+		return nil, err
+	}
+
+	e.logger.Info().Msg("Orchestrate rollout")
+
+	if err := e.rolloutOrchestrate(ctx); err != nil {
+		return nil, err
+	}
+
+	e.logger.Info().Msg("returning client state")
+
+	return e.returnClientState()
+}
+
+func (e *Entity) rolloutOrchestrate(ctx context.Context) error {
+	e.logger.Info().Msg("Orchestrate rollout")
+
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err := e.discoverTargets(ctx, rollout); err != nil {
+		return err
+	}
+
+	entityTargets, err := e.getEntityTargets()
+	if err != nil {
+		return err
+	}
+
+	if err := rollout.orchestrate(ctx, entityTargets); err != nil {
+		return err
+	}
+
+	if err := e.recordSnapshot(rollout, entityTargets); err != nil {
+		return err
+	}
+
+	// rollout state is consistent with assigned targets, batch is complete
+	return e.store.Txn(func(txn store.StoreTxn) error {
+		if err := txn.SaveJSON(e.rolloutKey(), rollout); err != nil {
+			return err
+		}
+		return e.endBatch(txn)
+	})
+}
+
+// orchestrateasync records input target state and enqueues rollout orchestration, run with context of
+// entity lock holder
+func (e *Entity) orchestrateasync(targets []*ClientState, enqueue func(run func(ctx context.Context)) error) error {
+	e.logger.Info().Msg("Refreshing target state")
+
+	if err := e.updateEntityTargets(targets); err != nil {
+		return err
+	}
+
+	return enqueue(func(ctx context.Context) {
+		if err := e.rolloutOrchestrate(ctx); err != nil {
+			e.logger.Error().Err(err).Msg("Async rollout orchestrate failed")
+		}
+	})
+}
+
+func (e *Entity) getClientState() ([]*ClientState, error) {
+	return e.returnClientState()
+}
+
+// returns client state of targets matching filter, targets are queried by version or error state
+// instead of loading every target
+func (e *Entity) getFilteredClientState(filter TargetFilter) ([]*ClientState, error) {
+	var entityTargets []*EntityTarget
+	var err error
+	switch {
+	case filter.Version != "":
+		entityTargets, err = e.queryEntityTargets(targetVersionPath, filter.Version)
+	case filter.IsError != nil && *filter.IsError:
+		entityTargets, err = e.queryEntityTargets(targetErrorPath, true)
+	case filter.State == TargetStateQuarantined:
+		entityTargets, err = e.getQuarantinedTargets()
+	default:
+		// isError is omitted when false, so targets without errors are not indexed
+		entityTargets, err = e.getEntityTargets()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	directives, err := e.findAgentDirectives()
+	if err != nil {
+		return nil, err
+	}
+	order, err := e.findVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	retTargets := []*ClientState{}
+	for _, entityTarget := range entityTargets {
+		if !filter.matches(entityTarget) {
+			continue
+		}
+		clientTarget := returnClientTarget(entityTarget)
+		clientTarget.Directives = directives
+		if order != nil {
+			clientTarget.VersionOrder = order(clientTarget.Version)
+		}
+		retTargets = append(retTargets, clientTarget)
+	}
+	return retTargets, nil
+}
+
+func (e *Entity) getClientStatePage(groupName, cursor string, limit int) ([]*ClientState, string, error) {
+	return e.returnClientGroupStatePage(groupName, cursor, limit)
+}
+
+func (e *Entity) getClientGroupState(groupName string) ([]*ClientState, error) {
+	return e.returnClientGroupState(groupName)
+}
+
+// We do not want to expose internal structures to app, may be in future all returns would be json serialized
+func (e *Entity) getRolloutInfo() (*RolloutState, error) {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+	return &rollout.State, nil
+}
@@ -1,4 +1,4 @@
-package core
+package engine
 
 import (
 	"context"
@@ -10,6 +10,7 @@ import (
 	"github.com/nixmade/orchestrator/store"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func createEntity(entityName string, logger zerolog.Logger) (*Entity, error) {
@@ -116,7 +117,7 @@ func TestSetTargetVersion(t *testing.T) {
 		return
 	}
 
-	if err := e.setTargetVersion("v1", false); err != nil {
+	if err := e.setTargetVersion(EntityTargetVersion{Version: "v1"}, false); err != nil {
 		t.Fatalf("Failed to set target version: %s", err)
 		return
 	}
@@ -203,7 +204,7 @@ func (c *CustomEntityTestController) TargetSelection(targets []*ClientState, sel
 }
 
 func TestSetEntityController(t *testing.T) {
-	RegisteredTargetControllers = append(RegisteredTargetControllers, &CustomEntityTestController{})
+	RegisterTargetController("custom-entity-test", func() EntityTargetController { return &CustomEntityTestController{} })
 	const entityName = "TestSetEntityController"
 	e, err := createEntity(entityName, getLogger())
 
@@ -469,3 +470,66 @@ func TestReturnClientState(t *testing.T) {
 		}
 	}
 }
+
+func TestReturnClientStateDirectives(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	clientTargets, err := e.returnClientState()
+	require.NoError(t, err)
+	require.NotEmpty(t, clientTargets)
+	assert.Nil(t, clientTargets[0].Directives)
+
+	options := DefaultRolloutOptions()
+	options.AgentDirectives = &AgentDirectives{MaxUpdateDurationSecs: 300, HealthCheckGraceSecs: 30}
+	require.NoError(t, e.setRolloutOptions(options))
+
+	clientTargets, err = e.returnClientState()
+	require.NoError(t, err)
+	for _, clientTarget := range clientTargets {
+		require.NotNil(t, clientTarget.Directives)
+		assert.Equal(t, 300, clientTarget.Directives.MaxUpdateDurationSecs)
+		assert.Equal(t, 30, clientTarget.Directives.HealthCheckGraceSecs)
+	}
+}
+
+func TestFilteredClientState(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	entityTargets, err := e.getEntityTargets()
+	require.NoError(t, err)
+	require.Len(t, entityTargets, 10)
+
+	for _, entityTarget := range entityTargets[:3] {
+		entityTarget.State.TargetVersion.Version = "v2"
+	}
+	entityTargets[0].State.TargetVersion.LastMessage.Error("failed monitoring")
+	entityTargets[5].State.TargetVersion.LastMessage.Error("failed monitoring")
+	require.NoError(t, e.saveEntityTargets(entityTargets))
+
+	clientTargets, err := e.getFilteredClientState(TargetFilter{Version: "v2"})
+	require.NoError(t, err)
+	assert.Len(t, clientTargets, 3)
+
+	isError := true
+	clientTargets, err = e.getFilteredClientState(TargetFilter{IsError: &isError})
+	require.NoError(t, err)
+	assert.Len(t, clientTargets, 2)
+
+	clientTargets, err = e.getFilteredClientState(TargetFilter{Version: "v2", IsError: &isError})
+	require.NoError(t, err)
+	require.Len(t, clientTargets, 1)
+	assert.Equal(t, entityTargets[0].Name, clientTargets[0].Name)
+
+	isError = false
+	clientTargets, err = e.getFilteredClientState(TargetFilter{Version: "v1", IsError: &isError})
+	require.NoError(t, err)
+	assert.Len(t, clientTargets, 6)
+}
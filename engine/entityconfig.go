@@ -0,0 +1,330 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// EntityConfig is declarative configuration of an entity applied as one document, sections left out are
+// reset to their defaults so applying the same document again changes nothing
+type EntityConfig struct {
+	// Options of rollout, defaults to DefaultRolloutOptions
+	Options *RolloutOptions `json:"options,omitempty"`
+	// TargetController by registered name, defaults to noop
+	TargetController *ControllerConfig `json:"targetcontroller,omitempty"`
+	// MonitoringController by registered name, defaults to noop
+	MonitoringController *ControllerConfig `json:"monitoringcontroller,omitempty"`
+	// Schedule of rollouts, new target versions start right away if not set
+	Schedule *RolloutSchedule `json:"schedule,omitempty"`
+	// Notifications webhook, disabled if not set
+	Notifications *NotificationConfig `json:"notifications,omitempty"`
+	// Version is etag of stored configuration, a put with version applies only if configuration is unchanged
+	Version string `json:"version,omitempty"`
+}
+
+// ControllerConfig is a controller registered under Type with its settings
+type ControllerConfig struct {
+	Type     string          `json:"type"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// validatingController is implemented by controllers checking their settings
+type validatingController interface {
+	Validate() error
+}
+
+// controllerConfig returns config of controller, nil for noop controllers
+func controllerConfig(controllers map[string]registeredController, controller any) (*ControllerConfig, error) {
+	if controller == nil {
+		return nil, nil
+	}
+	registered, ok := findController(controllers, reflect.TypeOf(controller).String())
+	if !ok {
+		return nil, fmt.Errorf("controller %T is not registered", controller)
+	}
+	if registered.name == "noop" {
+		return nil, nil
+	}
+	settings, err := json.Marshal(controller)
+	if err != nil {
+		return nil, err
+	}
+	return &ControllerConfig{Type: registered.name, Settings: settings}, nil
+}
+
+// decodeController decodes and validates settings of config into controller
+func decodeController(config *ControllerConfig, controller any) error {
+	if len(config.Settings) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(config.Settings))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(controller); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidEntityConfig, config.Type, err)
+		}
+	}
+	if validating, ok := controller.(validatingController); ok {
+		if err := validating.Validate(); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidEntityConfig, config.Type, err)
+		}
+	}
+	return nil
+}
+
+func targetControllerFromConfig(config *ControllerConfig) (EntityTargetController, error) {
+	if config == nil {
+		return &NoOpEntityTargetController{}, nil
+	}
+	registered, ok := findController(controllerRegistry.target, config.Type)
+	if !ok || registered.name != config.Type {
+		return nil, fmt.Errorf("%w: unknown target controller %q", ErrInvalidEntityConfig, config.Type)
+	}
+	controller := registered.target()
+	return controller, decodeController(config, controller)
+}
+
+func monitoringControllerFromConfig(config *ControllerConfig) (EntityMonitoringController, error) {
+	if config == nil {
+		return &NoOpEntityMonitoringController{}, nil
+	}
+	registered, ok := findController(controllerRegistry.monitoring, config.Type)
+	if !ok || registered.name != config.Type {
+		return nil, fmt.Errorf("%w: unknown monitoring controller %q", ErrInvalidEntityConfig, config.Type)
+	}
+	controller := registered.monitoring()
+	return controller, decodeController(config, controller)
+}
+
+// configVersion returns etag of config, hash of its json encoding without version
+func configVersion(config *EntityConfig) (string, error) {
+	unversioned := *config
+	unversioned.Version = ""
+	data, err := json.Marshal(&unversioned)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// Redacted returns copy of config without notification secret and controller credentials
+func (c *EntityConfig) Redacted() *EntityConfig {
+	config := *c
+	if config.Notifications != nil && config.Notifications.Secret != "" {
+		notifications := *config.Notifications
+		notifications.Secret = RedactedSecret
+		config.Notifications = &notifications
+	}
+	config.TargetController = config.TargetController.Redacted()
+	config.MonitoringController = config.MonitoringController.Redacted()
+	return &config
+}
+
+// Redacted returns copy of config with string values of secret settings redacted, settings are dropped
+// if they cannot be decoded
+func (c *ControllerConfig) Redacted() *ControllerConfig {
+	if c == nil || len(c.Settings) <= 0 {
+		return c
+	}
+	config := &ControllerConfig{Type: c.Type}
+	var settings any
+	if err := json.Unmarshal(c.Settings, &settings); err != nil {
+		return config
+	}
+	redacted, err := json.Marshal(redactSecrets(settings))
+	if err != nil {
+		return config
+	}
+	config.Settings = redacted
+	return config
+}
+
+// keepSecrets returns copy of config with secret settings read back redacted replaced by those of stored
+// config of the same type
+func (c *ControllerConfig) keepSecrets(stored *ControllerConfig) (*ControllerConfig, error) {
+	if c == nil || stored == nil || c.Type != stored.Type || len(c.Settings) <= 0 || len(stored.Settings) <= 0 {
+		return c, nil
+	}
+	var settings, storedSettings any
+	if err := json.Unmarshal(c.Settings, &settings); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidEntityConfig, c.Type, err)
+	}
+	if err := json.Unmarshal(stored.Settings, &storedSettings); err != nil {
+		return nil, err
+	}
+	kept, err := json.Marshal(keepSecrets(settings, storedSettings))
+	if err != nil {
+		return nil, err
+	}
+	return &ControllerConfig{Type: c.Type, Settings: kept}, nil
+}
+
+// keepSecrets replaces redacted string values of secret keys in decoded json by values at the same path
+// of stored
+func keepSecrets(value, stored any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		storedMap, _ := stored.(map[string]any)
+		for key, field := range value {
+			if field == RedactedSecret && isSecretKey(key) {
+				if secret, ok := storedMap[key].(string); ok {
+					value[key] = secret
+				}
+				continue
+			}
+			value[key] = keepSecrets(field, storedMap[key])
+		}
+	case []any:
+		storedSlice, _ := stored.([]any)
+		for i, field := range value {
+			var storedField any
+			if i < len(storedSlice) {
+				storedField = storedSlice[i]
+			}
+			value[i] = keepSecrets(field, storedField)
+		}
+	}
+	return value
+}
+
+// getConfig returns stored configuration of entity with its version
+func (e *Entity) getConfig() (*EntityConfig, error) {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := e.findNotificationConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &EntityConfig{
+		Options:       rollout.State.Options,
+		Schedule:      rollout.State.Schedule,
+		Notifications: notifications,
+	}
+	if config.TargetController, err = controllerConfig(controllerRegistry.target, rollout.TargetController.EntityTargetController); err != nil {
+		return nil, err
+	}
+	if config.MonitoringController, err = controllerConfig(controllerRegistry.monitoring, rollout.MonitoringController.EntityMonitoringController); err != nil {
+		return nil, err
+	}
+	if config.Version, err = configVersion(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// putConfig replaces configuration of entity if version is empty or matches stored configuration,
+// every section is validated before any is saved
+func (e *Entity) putConfig(config *EntityConfig, version string) (*EntityConfig, error) {
+	current, err := e.getConfig()
+	if err != nil {
+		return nil, err
+	}
+	if version != "" && version != current.Version {
+		return nil, fmt.Errorf("%w: expected %s, stored %s", ErrEntityConfigVersionMismatch, version, current.Version)
+	}
+
+	// controller secrets read back redacted are kept
+	targetConfig, err := config.TargetController.keepSecrets(current.TargetController)
+	if err != nil {
+		return nil, err
+	}
+	monitoringConfig, err := config.MonitoringController.keepSecrets(current.MonitoringController)
+	if err != nil {
+		return nil, err
+	}
+	targetController, err := targetControllerFromConfig(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	monitoringController, err := monitoringControllerFromConfig(monitoringConfig)
+	if err != nil {
+		return nil, err
+	}
+	notifications := config.Notifications
+	if notifications != nil && notifications.Secret == RedactedSecret && current.Notifications != nil {
+		// secret read back redacted is kept
+		redacted := *notifications
+		redacted.Secret = current.Notifications.Secret
+		notifications = &redacted
+	}
+
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+	if err := rollout.setRolloutOptions(config.Options); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEntityConfig, err)
+	}
+	if err := rollout.setSchedule(config.Schedule); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEntityConfig, err)
+	}
+	if err := e.saveWasmModule(targetController); err != nil {
+		return nil, err
+	}
+	if err := rollout.setTargetController(targetController); err != nil {
+		return nil, err
+	}
+	if err := rollout.setMonitoringController(monitoringController); err != nil {
+		return nil, err
+	}
+	if err := e.store.SaveJSON(e.rolloutKey(), rollout); err != nil {
+		return nil, err
+	}
+	if err := e.deleteWasmModules(targetController); err != nil {
+		return nil, err
+	}
+
+	if notifications == nil {
+		if err := e.store.Delete(e.notificationKey()); err != nil {
+			return nil, err
+		}
+	} else if err := e.setNotificationConfig(notifications); err != nil {
+		return nil, err
+	}
+
+	return e.getConfig()
+}
+
+func (n *Namespace) getEntityConfig(entityName string) (*EntityConfig, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getConfig()
+}
+
+// GetEntityConfig returns declarative configuration of entity with its version
+func (e *Engine) GetEntityConfig(namespaceName, entityName string) (*EntityConfig, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getEntityConfig(entityName)
+}
+
+// PutEntityConfig replaces configuration of entity creating it if needed and returns stored configuration,
+// non empty version must match version of stored configuration else ErrEntityConfigVersionMismatch is returned
+func (e *Engine) PutEntityConfig(namespaceName, entityName string, config *EntityConfig, version string) (*EntityConfig, error) {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := namespace.findorCreateEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := e.lockEntity(namespaceName, entityName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return entity.putConfig(config, version)
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntityConfigPut(t *testing.T) {
+	const testName = "TestEntityConfigPut"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	options := DefaultRolloutOptions()
+	options.BatchPercent = 20
+	config := &EntityConfig{
+		Options:              options,
+		TargetController:     &ControllerConfig{Type: "web", Settings: json.RawMessage(`{"selection": "http://controller/select"}`)},
+		MonitoringController: &ControllerConfig{Type: "prometheus", Settings: json.RawMessage(`{"address": "http://prometheus:9090", "query": "up", "threshold": 1}`)},
+		Schedule:             &RolloutSchedule{Cron: "0 22 * * sat"},
+		Notifications:        &NotificationConfig{URL: "http://hooks/orchestrator", Secret: "secret"},
+	}
+	stored, err := engine.PutEntityConfig(testName, testName, config, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, stored.Version)
+	assert.Equal(t, 20, stored.Options.BatchPercent)
+	assert.Equal(t, "web", stored.TargetController.Type)
+	assert.Equal(t, "prometheus", stored.MonitoringController.Type)
+	assert.Equal(t, "0 22 * * sat", stored.Schedule.Cron)
+
+	rollout, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, 20, rollout.Options.BatchPercent)
+
+	// applying same document again is a no-op
+	again, err := engine.PutEntityConfig(testName, testName, config, stored.Version)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Version, again.Version)
+
+	fetched, err := engine.GetEntityConfig(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, stored, fetched)
+
+	// version of a changed config is rejected
+	_, err = engine.PutEntityConfig(testName, testName, &EntityConfig{}, "stale")
+	assert.ErrorIs(t, err, ErrEntityConfigVersionMismatch)
+
+	// invalid section leaves config unchanged
+	_, err = engine.PutEntityConfig(testName, testName, &EntityConfig{TargetController: &ControllerConfig{Type: "unknown"}}, "")
+	assert.ErrorIs(t, err, ErrInvalidEntityConfig)
+	_, err = engine.PutEntityConfig(testName, testName, &EntityConfig{Schedule: &RolloutSchedule{Cron: "bad"}}, "")
+	assert.ErrorIs(t, err, ErrInvalidEntityConfig)
+	_, err = engine.PutEntityConfig(testName, testName, &EntityConfig{TargetController: &ControllerConfig{Type: "web", Settings: json.RawMessage(`{"unknown": 1}`)}}, "")
+	assert.ErrorIs(t, err, ErrInvalidEntityConfig)
+	fetched, err = engine.GetEntityConfig(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Version, fetched.Version)
+
+	// sections left out are reset
+	reset, err := engine.PutEntityConfig(testName, testName, &EntityConfig{}, stored.Version)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRolloutOptions().BatchPercent, reset.Options.BatchPercent)
+	assert.Nil(t, reset.TargetController)
+	assert.Nil(t, reset.MonitoringController)
+	assert.Nil(t, reset.Schedule)
+	assert.Nil(t, reset.Notifications)
+	assert.NotEqual(t, stored.Version, reset.Version)
+}
@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	consulTimeout        = 30 * time.Second
+	defaultConsulAddress = "http://127.0.0.1:8500"
+	defaultConsulVersion = "version"
+
+	// ConsulNodeLabel and ConsulDatacenterLabel are added to labels of discovered targets for target selectors
+	ConsulNodeLabel       = "orchestrator.nixmade.io/node"
+	ConsulDatacenterLabel = "orchestrator.nixmade.io/datacenter"
+)
+
+// targetDiscoveryController is implemented by target controllers enumerating targets from an external inventory,
+// discovered targets are created and refreshed before every orchestration so targets need not report before rollout
+type targetDiscoveryController interface {
+	DiscoverTargets() ([]*ClientState, error)
+}
+
+// EntityConsulTargetController enumerates instances of a consul catalog service as targets,
+// instances with failing health checks are reported as errors and never selected for rollout
+type EntityConsulTargetController struct {
+	NoOpEntityTargetController
+	// Address of consul agent, defaults to http://127.0.0.1:8500
+	Address string `json:"address,omitempty"`
+	// Token sent as X-Consul-Token if set
+	Token string `json:"token,omitempty"`
+	// Service whose instances are targets
+	Service string `json:"service,omitempty"`
+	// Datacenter of service, defaults to datacenter of agent
+	Datacenter string `json:"datacenter,omitempty"`
+	// Tag limits instances to those registered with tag
+	Tag string `json:"tag,omitempty"`
+	// VersionMeta is service meta key holding version of instance, defaults to version
+	VersionMeta string `json:"versionmeta,omitempty"`
+	// GroupMeta is service meta key holding group of instance, defaults to datacenter of node
+	GroupMeta string `json:"groupmeta,omitempty"`
+
+	ctx context.Context
+	// failing are targets with failing checks in last discovery by group and name
+	failing map[string]bool
+}
+
+// consulServiceEntry is the part of health service entry controller reads
+type consulServiceEntry struct {
+	Node struct {
+		Node       string `json:"Node"`
+		Datacenter string `json:"Datacenter"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Tags    []string          `json:"Tags"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+	Checks []struct {
+		Name   string `json:"Name"`
+		Status string `json:"Status"`
+	} `json:"Checks"`
+}
+
+func (e *EntityConsulTargetController) Validate() error {
+	if e.Service == "" {
+		return fmt.Errorf("%w: consul service is required", ErrInvalidTargetController)
+	}
+	if e.Address != "" {
+		if _, err := url.ParseRequestURI(e.Address); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTargetController, err)
+		}
+	}
+	return nil
+}
+
+func (e *EntityConsulTargetController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntityConsulTargetController) endpoints() []string {
+	if e.Address == "" {
+		return []string{defaultConsulAddress}
+	}
+	return []string{e.Address}
+}
+
+// serviceEntries lists all instances of service including those failing health checks
+func (e *EntityConsulTargetController) serviceEntries() ([]consulServiceEntry, error) {
+	address := e.Address
+	if address == "" {
+		address = defaultConsulAddress
+	}
+	query := url.Values{}
+	if e.Datacenter != "" {
+		query.Set("dc", e.Datacenter)
+	}
+	if e.Tag != "" {
+		query.Set("tag", e.Tag)
+	}
+	endpoint := fmt.Sprintf("%s/v1/health/service/%s", strings.TrimSuffix(address, "/"), url.PathEscape(e.Service))
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	ctx, cancel := context.WithTimeout(controllerContext(e.ctx), consulTimeout)
+	defer cancel()
+	ctx, span := tracing.StartWithKind(ctx, "GET "+endpoint, trace.SpanKindClient)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if e.Token != "" {
+		req.Header.Set("X-Consul-Token", e.Token)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, fmt.Errorf("consul service %s: %d %s", e.Service, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// clientState synthesizes target reported by instance, named by node and by service id too if the id
+// is not the service name since a node may run several instances
+func (e *EntityConsulTargetController) clientState(entry *consulServiceEntry) *ClientState {
+	name := entry.Node.Node
+	if entry.Service.ID != "" && entry.Service.ID != entry.Service.Service {
+		name = fmt.Sprintf("%s:%s", entry.Node.Node, entry.Service.ID)
+	}
+
+	versionMeta := e.VersionMeta
+	if versionMeta == "" {
+		versionMeta = defaultConsulVersion
+	}
+	group := entry.Node.Datacenter
+	if e.GroupMeta != "" && entry.Service.Meta[e.GroupMeta] != "" {
+		group = entry.Service.Meta[e.GroupMeta]
+	}
+
+	labels := make(map[string]string, len(entry.Service.Meta)+2)
+	for key, value := range entry.Service.Meta {
+		labels[key] = value
+	}
+	labels[ConsulNodeLabel] = entry.Node.Node
+	labels[ConsulDatacenterLabel] = entry.Node.Datacenter
+
+	var critical, warning []string
+	for _, check := range entry.Checks {
+		switch check.Status {
+		case "critical":
+			critical = append(critical, check.Name)
+		case "warning":
+			warning = append(warning, check.Name)
+		}
+	}
+	message := "consul checks passing"
+	switch {
+	case len(critical) > 0:
+		message = fmt.Sprintf("consul checks failing: %s", strings.Join(critical, ", "))
+	case len(warning) > 0:
+		message = fmt.Sprintf("consul checks warning: %s", strings.Join(warning, ", "))
+	}
+
+	return &ClientState{
+		Name:    name,
+		Group:   group,
+		Tags:    strings.Join(entry.Service.Tags, ","),
+		Version: entry.Service.Meta[versionMeta],
+		Message: message,
+		IsError: len(critical) > 0,
+		Labels:  labels,
+	}
+}
+
+// DiscoverTargets returns a target for every instance of service, version is empty if instance has no version meta
+func (e *EntityConsulTargetController) DiscoverTargets() ([]*ClientState, error) {
+	entries, err := e.serviceEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	e.failing = make(map[string]bool, len(entries))
+	clientTargets := make([]*ClientState, 0, len(entries))
+	for i := range entries {
+		clientTarget := e.clientState(&entries[i])
+		if clientTarget.Name == "" {
+			continue
+		}
+		e.failing[clientTarget.Group+"/"+clientTarget.Name] = clientTarget.IsError
+		clientTargets = append(clientTargets, clientTarget)
+	}
+	sort.Slice(clientTargets, func(i, j int) bool {
+		if clientTargets[i].Group != clientTargets[j].Group {
+			return clientTargets[i].Group < clientTargets[j].Group
+		}
+		return clientTargets[i].Name < clientTargets[j].Name
+	})
+	return clientTargets, nil
+}
+
+// TargetSelection skips targets whose consul checks failed in last discovery, targets not registered in consul
+// are selectable since they reported themselves
+func (e *EntityConsulTargetController) TargetSelection(targets []*ClientState, count int) ([]*ClientState, error) {
+	selected := make([]*ClientState, 0, len(targets))
+	for _, target := range targets {
+		if count >= 0 && len(selected) >= count {
+			break
+		}
+		if e.failing[target.Group+"/"+target.Name] {
+			continue
+		}
+		selected = append(selected, target)
+	}
+	return selected, nil
+}
+
+// discoverTargets creates and refreshes targets discovered by target controller, version of known targets
+// is kept if controller does not know it, targets still rolled out from reports if discovery fails
+func (e *Entity) discoverTargets(ctx context.Context, rollout *Rollout) error {
+	controller, ok := rollout.TargetController.EntityTargetController.(targetDiscoveryController)
+	if !ok {
+		return nil
+	}
+	if contextController, ok := controller.(contextController); ok {
+		contextController.setContext(ctx)
+	}
+
+	discovered, err := controller.DiscoverTargets()
+	if err != nil {
+		e.logger.Warn().Err(err).Msg("Failed to discover targets")
+		return nil
+	}
+	if len(discovered) <= 0 {
+		return nil
+	}
+
+	entityTargets, err := e.getEntityTargets()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*EntityTarget, len(entityTargets))
+	for _, entityTarget := range entityTargets {
+		existing[e.entityTargetKey(entityTarget.Group, entityTarget.Name)] = entityTarget
+	}
+	for _, clientTarget := range discovered {
+		if entityTarget, ok := existing[e.entityTargetKey(clientTarget.Group, clientTarget.Name)]; ok && clientTarget.Version == "" {
+			clientTarget.Version = entityTarget.State.CurrentVersion.Version
+		}
+	}
+
+	e.logger.Info().Int("DiscoveredTargets", len(discovered)).Msg("Refreshing discovered targets")
+	return e.updateEntityTargets(discovered)
+}
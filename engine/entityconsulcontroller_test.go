@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consulServer serves instances of web service, node2 fails its health check
+func consulServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/web", r.URL.Path)
+		assert.Equal(t, "consultoken", r.Header.Get("X-Consul-Token"))
+		assert.Equal(t, "dc1", r.URL.Query().Get("dc"))
+		_, _ = w.Write([]byte(`[
+			{"Node": {"Node": "node1", "Datacenter": "dc1"},
+			 "Service": {"ID": "web", "Service": "web", "Tags": ["primary"], "Meta": {"version": "v1", "rack": "r1"}},
+			 "Checks": [{"Name": "Serf Health Status", "Status": "passing"}, {"Name": "http", "Status": "passing"}]},
+			{"Node": {"Node": "node2", "Datacenter": "dc1"},
+			 "Service": {"ID": "web", "Service": "web", "Meta": {"version": "v1"}},
+			 "Checks": [{"Name": "http", "Status": "critical"}]},
+			{"Node": {"Node": "node3", "Datacenter": "dc1"},
+			 "Service": {"ID": "web-2", "Service": "web", "Meta": {"version": "v1", "zone": "b"}},
+			 "Checks": [{"Name": "http", "Status": "warning"}]}
+		]`))
+	}))
+}
+
+func TestConsulDiscoverTargets(t *testing.T) {
+	consul := consulServer(t)
+	defer consul.Close()
+
+	controller := &EntityConsulTargetController{Address: consul.URL, Token: "consultoken", Service: "web", Datacenter: "dc1", GroupMeta: "zone"}
+	require.NoError(t, controller.Validate())
+
+	discovered, err := controller.DiscoverTargets()
+	require.NoError(t, err)
+	require.Len(t, discovered, 3)
+
+	node1, node2, node3 := discovered[1], discovered[2], discovered[0]
+	assert.Equal(t, "node1", node1.Name)
+	assert.Equal(t, "dc1", node1.Group)
+	assert.Equal(t, "v1", node1.Version)
+	assert.Equal(t, "primary", node1.Tags)
+	assert.Equal(t, "r1", node1.Labels["rack"])
+	assert.Equal(t, "node1", node1.Labels[ConsulNodeLabel])
+	assert.False(t, node1.IsError)
+
+	assert.Equal(t, "node2", node2.Name)
+	assert.True(t, node2.IsError)
+	assert.Equal(t, "consul checks failing: http", node2.Message)
+
+	// instance id differs from service name, group from meta
+	assert.Equal(t, "node3:web-2", node3.Name)
+	assert.Equal(t, "b", node3.Group)
+	assert.False(t, node3.IsError)
+	assert.Equal(t, "consul checks warning: http", node3.Message)
+
+	selected, err := controller.TargetSelection(discovered, 10)
+	require.NoError(t, err)
+	assert.Len(t, selected, 2)
+	for _, target := range selected {
+		assert.NotEqual(t, "node2", target.Name)
+	}
+
+	assert.ErrorIs(t, (&EntityConsulTargetController{}).Validate(), ErrInvalidTargetController)
+	assert.ErrorIs(t, (&EntityConsulTargetController{Service: "web", Address: "::"}).Validate(), ErrInvalidTargetController)
+}
@@ -1,6 +1,7 @@
-package core
+package engine
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 )
@@ -32,15 +33,16 @@ type EntityMonitoringController interface {
 	ExternalMonitoring([]*ClientState) error
 }
 
-// We need to register all known message types here to be able to unmarshal them to the correct interface type.
-var RegisteredTargetControllers = []EntityTargetController{
-	&NoOpEntityTargetController{},
-	&EntityWebTargetController{},
+// contextController is implemented by controllers making external calls,
+// context is set before every orchestration to propagate trace context
+type contextController interface {
+	setContext(ctx context.Context)
 }
 
-var RegisteredMonitoringControllers = []EntityMonitoringController{
-	&NoOpEntityMonitoringController{},
-	&EntityWebMonitoringController{},
+// rolloutController is implemented by controllers keeping state of the rollout,
+// rollout is set before every orchestration
+type rolloutController interface {
+	setRollout(r *Rollout)
 }
 
 type SerializedEntityTargetController struct {
@@ -60,20 +62,16 @@ func (c *SerializedEntityTargetController) UnmarshalJSON(bytes []byte) error {
 		return err
 	}
 
-	for _, registeredController := range RegisteredTargetControllers {
-		registeredControllerType := reflect.TypeOf(registeredController)
-		if registeredControllerType.String() == data.Type {
-			// Create a new pointer to a value of the concrete message type
-			target := reflect.New(registeredControllerType)
-			// Unmarshal the data to an interface to the concrete value (which will act as a pointer, don't ask why)
-			if err := json.Unmarshal(data.Value, target.Interface()); err != nil {
-				return err
-			}
-			// Now we get the element value of the target and convert it to the interface type (this is to get rid of a pointer type instead of a plain struct value)
-			c.EntityTargetController = target.Elem().Interface().(EntityTargetController)
-			return nil
-		}
+	typeName, _ := data.Type.(string)
+	registered, ok := findController(controllerRegistry.target, typeName)
+	if !ok {
+		return nil
 	}
+	controller := registered.target()
+	if err := json.Unmarshal(data.Value, controller); err != nil {
+		return err
+	}
+	c.EntityTargetController = controller
 	return nil
 }
 
@@ -97,20 +95,16 @@ func (c *SerializedEntityMonitoringController) UnmarshalJSON(bytes []byte) error
 		return err
 	}
 
-	for _, registeredController := range RegisteredMonitoringControllers {
-		registeredControllerType := reflect.TypeOf(registeredController)
-		if registeredControllerType.String() == data.Type {
-			// Create a new pointer to a value of the concrete message type
-			target := reflect.New(registeredControllerType)
-			// Unmarshal the data to an interface to the concrete value (which will act as a pointer, don't ask why)
-			if err := json.Unmarshal(data.Value, target.Interface()); err != nil {
-				return err
-			}
-			// Now we get the element value of the target and convert it to the interface type (this is to get rid of a pointer type instead of a plain struct value)
-			c.EntityMonitoringController = target.Elem().Interface().(EntityMonitoringController)
-			return nil
-		}
+	typeName, _ := data.Type.(string)
+	registered, ok := findController(controllerRegistry.monitoring, typeName)
+	if !ok {
+		return nil
+	}
+	controller := registered.monitoring()
+	if err := json.Unmarshal(data.Value, controller); err != nil {
+		return err
 	}
+	c.EntityMonitoringController = controller
 	return nil
 }
 
@@ -164,6 +158,7 @@ func getClientTarget(entityTarget *EntityTarget) *ClientState {
 		Version: entityTarget.State.TargetVersion.Version,
 		Message: entityTarget.State.TargetVersion.LastMessage.Message,
 		IsError: entityTarget.State.TargetVersion.LastMessage.IsError,
+		Labels:  entityTarget.Labels,
 	}
 }
 
@@ -0,0 +1,238 @@
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/controllerpb"
+	"github.com/nixmade/orchestrator/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// EntityGrpcTargetController calls a gRPC control plane implementing TargetController service of controllerpb,
+// RPCs left unimplemented by the service behave as if the controller was not set
+type EntityGrpcTargetController struct {
+	// Endpoint of service, host:port
+	Endpoint string `json:"endpoint,omitempty"`
+	// Insecure uses plaintext connection, by default TLS is verified with system roots
+	Insecure bool `json:"insecure,omitempty"`
+	// TimeoutSecs is deadline of each call propagated to service, 0 uses deadline of orchestration if any
+	TimeoutSecs int `json:"timeoutsecs,omitempty"`
+	// BearerToken sent as authorization metadata if set
+	BearerToken string `json:"bearertoken,omitempty"`
+
+	ctx context.Context
+}
+
+// grpcConns are shared connections by endpoint, controllers are reloaded for every orchestration
+var grpcConns = struct {
+	sync.Mutex
+	conns map[string]*grpc.ClientConn
+}{conns: make(map[string]*grpc.ClientConn)}
+
+var errGrpcUnimplemented = errors.New("grpc method not implemented")
+
+func grpcConn(endpoint string, plaintext bool) (*grpc.ClientConn, error) {
+	key := fmt.Sprintf("%s/%t", endpoint, plaintext)
+
+	grpcConns.Lock()
+	defer grpcConns.Unlock()
+	if conn, ok := grpcConns.conns[key]; ok {
+		return conn, nil
+	}
+
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	grpcConns.conns[key] = conn
+	return conn, nil
+}
+
+func (e *EntityGrpcTargetController) Validate() error {
+	if e.Endpoint == "" {
+		return fmt.Errorf("%w: grpc endpoint is required", ErrInvalidTargetController)
+	}
+	if e.TimeoutSecs < 0 {
+		return fmt.Errorf("%w: timeoutsecs must not be negative", ErrInvalidTargetController)
+	}
+	return nil
+}
+
+// call invokes method of service with deadline, trace context and authorization in outgoing metadata
+func (e *EntityGrpcTargetController) call(method string, invoke func(context.Context, controllerpb.TargetControllerClient) error) error {
+	ctx, span := tracing.StartWithKind(controllerContext(e.ctx), "grpc "+method, trace.SpanKindClient)
+	defer span.End()
+
+	if e.TimeoutSecs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(e.TimeoutSecs)*time.Second)
+		defer cancel()
+	}
+
+	header := http.Header{}
+	tracing.Inject(ctx, header)
+	md := metadata.MD{}
+	for key, values := range header {
+		md.Append(strings.ToLower(key), values...)
+	}
+	if e.BearerToken != "" {
+		md.Set("authorization", "Bearer "+e.BearerToken)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	conn, err := grpcConn(e.Endpoint, e.Insecure)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("%w: %w", ErrExternalControllerFailure, err)
+	}
+
+	if err := invoke(ctx, controllerpb.NewTargetControllerClient(conn)); err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return errGrpcUnimplemented
+		}
+		tracing.RecordError(span, err)
+		return fmt.Errorf("%w: %s %s: %w", ErrExternalControllerFailure, e.Endpoint, method, err)
+	}
+	return nil
+}
+
+func toProtoTarget(clientTarget *ClientState) *controllerpb.Target {
+	return &controllerpb.Target{
+		Name:    clientTarget.Name,
+		Group:   clientTarget.Group,
+		Version: clientTarget.Version,
+		Message: clientTarget.Message,
+		IsError: clientTarget.IsError,
+		Labels:  clientTarget.Labels,
+	}
+}
+
+func toProtoTargets(clientTargets []*ClientState) []*controllerpb.Target {
+	targets := make([]*controllerpb.Target, 0, len(clientTargets))
+	for _, clientTarget := range clientTargets {
+		targets = append(targets, toProtoTarget(clientTarget))
+	}
+	return targets
+}
+
+func fromProtoTargets(targets []*controllerpb.Target) []*ClientState {
+	clientTargets := make([]*ClientState, 0, len(targets))
+	for _, target := range targets {
+		clientTargets = append(clientTargets, &ClientState{
+			Name:    target.GetName(),
+			Group:   target.GetGroup(),
+			Version: target.GetVersion(),
+			Message: target.GetMessage(),
+			IsError: target.GetIsError(),
+			Labels:  target.GetLabels(),
+		})
+	}
+	return clientTargets
+}
+
+// knownTargets returns client targets named in targets
+func knownTargets(clientTargets []*ClientState, targets []*controllerpb.Target) []*ClientState {
+	var known []*ClientState
+	for _, target := range targets {
+		for _, clientTarget := range clientTargets {
+			if clientTarget.Name == target.GetName() {
+				known = append(known, clientTarget)
+			}
+		}
+	}
+	return known
+}
+
+// TargetSelection selects list of targets
+func (e *EntityGrpcTargetController) TargetSelection(clientTargets []*ClientState, numSelection int) ([]*ClientState, error) {
+	var resp *controllerpb.SelectionResponse
+	err := e.call("Selection", func(ctx context.Context, client controllerpb.TargetControllerClient) (err error) {
+		resp, err = client.Selection(ctx, &controllerpb.SelectionRequest{Targets: toProtoTargets(clientTargets), Count: int32(numSelection)})
+		return err
+	})
+	if errors.Is(err, errGrpcUnimplemented) {
+		return clientTargets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromProtoTargets(resp.GetTargets()), nil
+}
+
+// TargetApproval gets approval for list of targets
+func (e *EntityGrpcTargetController) TargetApproval(clientTargets []*ClientState) ([]*ClientState, error) {
+	var resp *controllerpb.ApprovalResponse
+	err := e.call("Approval", func(ctx context.Context, client controllerpb.TargetControllerClient) (err error) {
+		resp, err = client.Approval(ctx, &controllerpb.ApprovalRequest{Targets: toProtoTargets(clientTargets)})
+		return err
+	})
+	if errors.Is(err, errGrpcUnimplemented) {
+		return clientTargets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// only known targets can get approved
+	return knownTargets(clientTargets, resp.GetTargets()), nil
+}
+
+// TargetMonitoring monitors the provided target
+func (e *EntityGrpcTargetController) TargetMonitoring(clientTarget *ClientState) error {
+	var resp *controllerpb.MonitoringResponse
+	err := e.call("Monitoring", func(ctx context.Context, client controllerpb.TargetControllerClient) (err error) {
+		resp, err = client.Monitoring(ctx, &controllerpb.MonitoringRequest{Target: toProtoTarget(clientTarget)})
+		return err
+	})
+	if errors.Is(err, errGrpcUnimplemented) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(resp.GetStatus()) != "ok" {
+		return fmt.Errorf("%s %s", resp.GetStatus(), resp.GetMessage())
+	}
+	return nil
+}
+
+// TargetRemoval removes optional set of additional targets
+func (e *EntityGrpcTargetController) TargetRemoval(clientTargets []*ClientState, numRemoval int) ([]*ClientState, error) {
+	var resp *controllerpb.RemovalResponse
+	err := e.call("Removal", func(ctx context.Context, client controllerpb.TargetControllerClient) (err error) {
+		resp, err = client.Removal(ctx, &controllerpb.RemovalRequest{Targets: toProtoTargets(clientTargets), Count: int32(numRemoval)})
+		return err
+	})
+	if errors.Is(err, errGrpcUnimplemented) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// only known targets can get removed
+	return knownTargets(clientTargets, resp.GetTargets()), nil
+}
+
+func (e *EntityGrpcTargetController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntityGrpcTargetController) endpoints() []string {
+	return []string{e.Endpoint}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/nixmade/orchestrator/controllerpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testTargetControllerServer struct {
+	controllerpb.UnimplementedTargetControllerServer
+	authorization []string
+	hasDeadline   bool
+}
+
+func (s *testTargetControllerServer) Approval(ctx context.Context, req *controllerpb.ApprovalRequest) (*controllerpb.ApprovalResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.authorization = md.Get("authorization")
+	_, s.hasDeadline = ctx.Deadline()
+	// approve first target and an unknown one
+	return &controllerpb.ApprovalResponse{Targets: []*controllerpb.Target{req.Targets[0], {Name: "unknown"}}}, nil
+}
+
+func (s *testTargetControllerServer) Monitoring(ctx context.Context, req *controllerpb.MonitoringRequest) (*controllerpb.MonitoringResponse, error) {
+	if req.Target.Labels["health"] == "bad" {
+		return &controllerpb.MonitoringResponse{Status: "failed", Message: "unhealthy"}, nil
+	}
+	return &controllerpb.MonitoringResponse{Status: "ok"}, nil
+}
+
+func startTestTargetControllerServer(t *testing.T, srv controllerpb.TargetControllerServer) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	controllerpb.RegisterTargetControllerServer(server, srv)
+	go func() {
+		assert.NoError(t, server.Serve(lis))
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestGrpcTargetController(t *testing.T) {
+	srv := &testTargetControllerServer{}
+	controller := &EntityGrpcTargetController{
+		Endpoint:    startTestTargetControllerServer(t, srv),
+		Insecure:    true,
+		TimeoutSecs: 5,
+		BearerToken: "token",
+	}
+	controller.setContext(context.Background())
+	targets := []*ClientState{{Name: "target0"}, {Name: "target1", Labels: map[string]string{"health": "bad"}}}
+
+	approved, err := controller.TargetApproval(targets)
+	require.NoError(t, err)
+	assert.Equal(t, []*ClientState{targets[0]}, approved)
+	assert.Equal(t, []string{"Bearer token"}, srv.authorization)
+	assert.True(t, srv.hasDeadline)
+
+	assert.NoError(t, controller.TargetMonitoring(targets[0]))
+	assert.EqualError(t, controller.TargetMonitoring(targets[1]), "failed unhealthy")
+
+	// unimplemented methods behave as if controller was not set
+	selected, err := controller.TargetSelection(targets, 1)
+	require.NoError(t, err)
+	assert.Equal(t, targets, selected)
+
+	removed, err := controller.TargetRemoval(targets, 1)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	assert.NoError(t, controller.Validate())
+	assert.ErrorIs(t, (&EntityGrpcTargetController{}).Validate(), ErrInvalidTargetController)
+	assert.ErrorIs(t, (&EntityGrpcTargetController{Endpoint: "localhost:1", TimeoutSecs: -1}).Validate(), ErrInvalidTargetController)
+}
+
+func TestGrpcTargetControllerUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	endpoint := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	controller := &EntityGrpcTargetController{Endpoint: endpoint, Insecure: true, TimeoutSecs: 1}
+	_, err = controller.TargetApproval([]*ClientState{{Name: "target0"}})
+	assert.ErrorIs(t, err, ErrExternalControllerFailure)
+}
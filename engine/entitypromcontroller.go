@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+const promMonitoringTimeout = 30 * time.Second
+
+// TargetMonitoringFailure is a target failed by monitoring controller
+type TargetMonitoringFailure struct {
+	Target  *ClientState
+	Message string
+}
+
+// targetMonitoringController is implemented by monitoring controllers judging health of individual targets,
+// returned targets fail monitoring instead of failing whole orchestration
+type targetMonitoringController interface {
+	MonitorTargets([]*ClientState) ([]TargetMonitoringFailure, error)
+}
+
+// EntityPromMonitoringController runs a PromQL query per target in rollout against prometheus,
+// targets whose result crosses threshold fail monitoring
+type EntityPromMonitoringController struct {
+	// Address of prometheus, e.g. http://prometheus:9090
+	Address string `json:"address,omitempty"`
+	// BearerToken sent with queries if set
+	BearerToken string `json:"bearertoken,omitempty"`
+	// Query is a text/template executed with target, e.g. rate(errors{instance="{{.Name}}",region="{{.Labels.region}}"}[5m])
+	Query string `json:"query,omitempty"`
+	// Threshold target fails above, or below if FailBelow is set
+	Threshold float64 `json:"threshold"`
+	FailBelow bool    `json:"failbelow,omitempty"`
+	// FailOnNoData fails targets query returned no samples for, by default they are healthy
+	FailOnNoData bool `json:"failonnodata,omitempty"`
+
+	ctx context.Context
+}
+
+func (e *EntityPromMonitoringController) Validate() error {
+	if e.Address == "" || e.Query == "" {
+		return fmt.Errorf("%w: prometheus address and query are required", ErrInvalidMonitoringController)
+	}
+	if _, err := template.New("query").Option("missingkey=zero").Parse(e.Query); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMonitoringController, err)
+	}
+	return nil
+}
+
+// ExternalMonitoring is not used, targets are monitored individually by MonitorTargets
+func (e *EntityPromMonitoringController) ExternalMonitoring([]*ClientState) error {
+	return nil
+}
+
+// crossed checks if value crosses threshold
+func (e *EntityPromMonitoringController) crossed(value float64) bool {
+	if e.FailBelow {
+		return value < e.Threshold
+	}
+	return value > e.Threshold
+}
+
+// MonitorTargets queries prometheus for every target, returns targets crossing threshold
+func (e *EntityPromMonitoringController) MonitorTargets(clientTargets []*ClientState) ([]TargetMonitoringFailure, error) {
+	if len(clientTargets) <= 0 {
+		return nil, nil
+	}
+
+	queryTemplate, err := template.New("query").Option("missingkey=zero").Parse(e.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(controllerContext(e.ctx), promMonitoringTimeout)
+	defer cancel()
+
+	provider := &prometheusProvider{config: &AnalysisProviderConfig{Type: MetricProviderPrometheus, Address: e.Address, APIKey: e.BearerToken}}
+
+	var failures []TargetMonitoringFailure
+	for _, clientTarget := range clientTargets {
+		var query bytes.Buffer
+		if err := queryTemplate.Execute(&query, clientTarget); err != nil {
+			return nil, err
+		}
+
+		value, err := provider.Query(ctx, query.String(), 0)
+		if errors.Is(err, errNoMetricData) {
+			if e.FailOnNoData {
+				failures = append(failures, TargetMonitoringFailure{Target: clientTarget, Message: fmt.Sprintf("query %s returned no data", query.String())})
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if e.crossed(value) {
+			failures = append(failures, TargetMonitoringFailure{
+				Target:  clientTarget,
+				Message: fmt.Sprintf("query %s returned %g crossing threshold %g", query.String(), value, e.Threshold),
+			})
+		}
+	}
+
+	return failures, nil
+}
+
+func (e *EntityPromMonitoringController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntityPromMonitoringController) endpoints() []string {
+	return []string{e.Address}
+}
+
+// unhealthyTargets asks monitoring controller judging individual targets about targets running target version,
+// returns failure message by group and name of target
+func (r *Rollout) unhealthyTargets(state *rolloutInfo, targetVersion string) (map[string]string, error) {
+	controller, ok := r.MonitoringController.EntityMonitoringController.(targetMonitoringController)
+	if !ok {
+		return nil, nil
+	}
+
+	var runningTargets EntityTargets
+	for _, entityTarget := range state.inRolloutTargets {
+		if entityTarget.State.CurrentVersion.Version == targetVersion {
+			runningTargets = append(runningTargets, entityTarget)
+		}
+	}
+
+	failures, err := controller.MonitorTargets(getClientTargets(runningTargets))
+	if err != nil {
+		return nil, err
+	}
+
+	unhealthy := make(map[string]string, len(failures))
+	for _, failure := range failures {
+		unhealthy[failure.Target.Group+"/"+failure.Target.Name] = failure.Message
+	}
+	return unhealthy, nil
+}
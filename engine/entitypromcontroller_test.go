@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promServer returns value of query, no samples for unknown targets
+func promServer(values map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := values[r.URL.Query().Get("query")]
+		if !ok {
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"%s"]}]}}`, value)
+	}))
+}
+
+func TestPromMonitorTargets(t *testing.T) {
+	srv := promServer(map[string]string{
+		`errors{instance="target0",region="us-east"}`: "0.5",
+		`errors{instance="target1",region="us-east"}`: "5",
+	})
+	defer srv.Close()
+
+	controller := &EntityPromMonitoringController{
+		Address:   srv.URL,
+		Query:     `errors{instance="{{.Name}}",region="{{.Labels.region}}"}`,
+		Threshold: 1,
+	}
+	require.NoError(t, controller.Validate())
+
+	var clientTargets []*ClientState
+	for i := 0; i < 3; i++ {
+		clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("target%d", i), Labels: map[string]string{"region": "us-east"}})
+	}
+
+	failures, err := controller.MonitorTargets(clientTargets)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "target1", failures[0].Target.Name)
+
+	// target without samples fails only with FailOnNoData
+	controller.FailOnNoData = true
+	failures, err = controller.MonitorTargets(clientTargets)
+	require.NoError(t, err)
+	assert.Len(t, failures, 2)
+
+	controller.FailOnNoData = false
+	controller.FailBelow = true
+	failures, err = controller.MonitorTargets(clientTargets)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "target0", failures[0].Target.Name)
+
+	assert.ErrorIs(t, (&EntityPromMonitoringController{Address: srv.URL}).Validate(), ErrInvalidMonitoringController)
+	assert.ErrorIs(t, (&EntityPromMonitoringController{Address: srv.URL, Query: "{{.Name"}).Validate(), ErrInvalidMonitoringController)
+}
@@ -0,0 +1,504 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/tracing"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+const (
+	// MaxWasmModuleSize of plugins uploaded or loaded from disk
+	MaxWasmModuleSize = 8 << 20
+
+	defaultWasmFuel           = 100_000_000
+	defaultWasmMaxMemoryPages = 256
+	defaultWasmTimeout        = 5 * time.Second
+	maxWasmFuel               = 1_000_000_000
+	maxWasmMemoryPages        = 1024
+	maxWasmTimeout            = 30 * time.Second
+	maxCachedWasmModules      = 64
+	wasmModulePrefix          = "wasmmodule:"
+)
+
+// EntityWasmTargetController runs selection, approval, monitoring and removal logic of a WebAssembly plugin
+// with wazero, every call runs in a fresh instance without imports so plugins have no access to host, network or disk.
+//
+// Plugins export memory and alloc(size i32) i32, and any of selection, approval, monitoring and removal
+// taking (ptr i32, len i32) of JSON request of web controller endpoints and returning i64 of ptr << 32 | len
+// of JSON response. Functions not exported behave as if controller was not set.
+//
+// Uploaded modules are stored once with the entity under their digest, rollout keeps only the digest.
+type EntityWasmTargetController struct {
+	// Module is plugin uploaded via API, replaced by Digest once controller is set
+	Module []byte `json:"module,omitempty"`
+	// Digest is hex sha256 of uploaded module stored with entity
+	Digest string `json:"digest,omitempty"`
+	// Path of plugin on disk, read on every call so replacing the file swaps the plugin
+	Path string `json:"path,omitempty"`
+	// Fuel is number of function calls each call may make, defaults to 100M, clamped to engine.wasm.maxFuel
+	Fuel uint64 `json:"fuel,omitempty"`
+	// MaxMemoryPages of 64KiB plugin memory may grow to, defaults to 256, clamped to engine.wasm.maxMemoryPages
+	MaxMemoryPages uint32 `json:"maxmemorypages,omitempty"`
+	// TimeoutSecs of each call, defaults to 5, clamped to engine.wasm.maxTimeoutSecs
+	TimeoutSecs int `json:"timeoutsecs,omitempty"`
+
+	ctx    context.Context
+	entity *Entity
+}
+
+func (e *Entity) wasmModulePrefix() string {
+	return fmt.Sprintf("%s%s/%s/", wasmModulePrefix, e.Namespace, e.Name)
+}
+
+func (e *Entity) wasmModuleKey(digest string) string {
+	return e.wasmModulePrefix() + digest
+}
+
+// saveWasmModule stores uploaded module of wasm controller under its digest, leaving only the digest
+// in controller, stored module of digest is checked to exist and compile
+func (e *Entity) saveWasmModule(controller EntityTargetController) error {
+	wasmController, ok := controller.(*EntityWasmTargetController)
+	if !ok || wasmController.Path != "" {
+		return nil
+	}
+	if len(wasmController.Module) > 0 {
+		digest := sha256.Sum256(wasmController.Module)
+		wasmController.Digest = hex.EncodeToString(digest[:])
+		if err := e.store.SaveJSON(e.wasmModuleKey(wasmController.Digest), wasmController.Module); err != nil {
+			return err
+		}
+		wasmController.Module = nil
+	}
+	wasmController.entity = e
+	if _, err := wasmController.load(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTargetController, err)
+	}
+	return nil
+}
+
+// deleteWasmModules removes stored modules of entity not used by controller
+func (e *Entity) deleteWasmModules(controller EntityTargetController) error {
+	keep := ""
+	if wasmController, ok := controller.(*EntityWasmTargetController); ok && wasmController.Digest != "" {
+		keep = e.wasmModuleKey(wasmController.Digest)
+	}
+	keys, err := e.store.LoadKeys(e.wasmModulePrefix())
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key == keep {
+			continue
+		}
+		if err := e.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wasmMaxLimits are configured maximums plugin limits are clamped to, set when engine is created
+var wasmMaxLimits = struct {
+	sync.RWMutex
+	config.WasmConfig
+}{}
+
+// setWasmMaxLimits sets maximums of plugin limits, zero maximums use built in maximums
+func setWasmMaxLimits(cfg config.WasmConfig) {
+	wasmMaxLimits.Lock()
+	defer wasmMaxLimits.Unlock()
+	wasmMaxLimits.WasmConfig = cfg
+}
+
+// wasmLimits of plugin calls
+type wasmLimits struct {
+	// Fuel is number of function calls
+	Fuel uint64
+	// MaxMemoryPages of 64KiB memory may grow to
+	MaxMemoryPages uint32
+}
+
+// maxWasmLimits returns maximums of plugin limits and call timeout
+func maxWasmLimits() (wasmLimits, time.Duration) {
+	wasmMaxLimits.RLock()
+	defer wasmMaxLimits.RUnlock()
+	limits := wasmLimits{Fuel: wasmMaxLimits.MaxFuel, MaxMemoryPages: wasmMaxLimits.MaxMemoryPages}
+	if limits.Fuel == 0 {
+		limits.Fuel = maxWasmFuel
+	}
+	if limits.MaxMemoryPages == 0 {
+		limits.MaxMemoryPages = maxWasmMemoryPages
+	}
+	timeout := time.Duration(wasmMaxLimits.MaxTimeoutSecs) * time.Second
+	if timeout == 0 {
+		timeout = maxWasmTimeout
+	}
+	return limits, timeout
+}
+
+var (
+	// errWasmFuelExhausted returns an error if plugin call made more function calls than its fuel
+	errWasmFuelExhausted = errors.New("wasm fuel exhausted")
+	// errInvalidWasmPlugin returns an error if module does not implement plugin interface
+	errInvalidWasmPlugin = errors.New("invalid wasm plugin")
+)
+
+type wasmFuelKey struct{}
+
+// wasmFuel is remaining fuel of a plugin call
+type wasmFuel struct {
+	remaining atomic.Int64
+}
+
+// wasmFuelListener burns fuel of call context on every function call, panics of listeners are returned by
+// wazero as error of the call
+type wasmFuelListener struct{}
+
+func (wasmFuelListener) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return experimental.FunctionListenerFunc(func(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+		if fuel, ok := ctx.Value(wasmFuelKey{}).(*wasmFuel); ok && fuel.remaining.Add(-1) < 0 {
+			panic(errWasmFuelExhausted)
+		}
+	})
+}
+
+// wasmPluginKey is digest of plugin compiled for runtime of memory limit
+type wasmPluginKey struct {
+	digest         [sha256.Size]byte
+	maxMemoryPages uint32
+}
+
+// wasmPlugins are wazero runtimes by memory limit and plugins compiled for them, controllers are reloaded
+// for every orchestration, evicted plugins are closed while running instances keep their code
+var wasmPlugins = struct {
+	sync.Mutex
+	runtimes map[uint32]wazero.Runtime
+	modules  map[wasmPluginKey]wazero.CompiledModule
+}{runtimes: make(map[uint32]wazero.Runtime), modules: make(map[wasmPluginKey]wazero.CompiledModule)}
+
+// wasmPlugin is compiled plugin and runtime it runs in
+type wasmPlugin struct {
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+}
+
+// cachedWasmPlugin returns compiled plugin of digest if it is cached
+func cachedWasmPlugin(digest [sha256.Size]byte, maxMemoryPages uint32) (*wasmPlugin, bool) {
+	wasmPlugins.Lock()
+	defer wasmPlugins.Unlock()
+	module, ok := wasmPlugins.modules[wasmPluginKey{digest: digest, maxMemoryPages: maxMemoryPages}]
+	if !ok {
+		return nil, false
+	}
+	return &wasmPlugin{runtime: wasmPlugins.runtimes[maxMemoryPages], module: module}, true
+}
+
+// compileWasmPlugin compiles binary for runtime of memory limit and checks it implements plugin interface,
+// runtime closes plugin instances once context of their call is done
+func compileWasmPlugin(binary []byte, maxMemoryPages uint32) (*wasmPlugin, error) {
+	key := wasmPluginKey{digest: sha256.Sum256(binary), maxMemoryPages: maxMemoryPages}
+
+	wasmPlugins.Lock()
+	defer wasmPlugins.Unlock()
+	runtime, ok := wasmPlugins.runtimes[maxMemoryPages]
+	if !ok {
+		runtime = wazero.NewRuntimeWithConfig(context.Background(), wazero.NewRuntimeConfig().
+			WithCloseOnContextDone(true).
+			WithMemoryLimitPages(maxMemoryPages))
+		wasmPlugins.runtimes[maxMemoryPages] = runtime
+	}
+	if module, ok := wasmPlugins.modules[key]; ok {
+		return &wasmPlugin{runtime: runtime, module: module}, nil
+	}
+
+	ctx := experimental.WithFunctionListenerFactory(context.Background(), wasmFuelListener{})
+	module, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidWasmPlugin, err)
+	}
+	if err := checkWasmPlugin(module); err != nil {
+		_ = module.Close(ctx)
+		return nil, err
+	}
+
+	if len(wasmPlugins.modules) >= maxCachedWasmModules {
+		for _, evicted := range wasmPlugins.modules {
+			_ = evicted.Close(ctx)
+		}
+		clear(wasmPlugins.modules)
+	}
+	wasmPlugins.modules[key] = module
+	return &wasmPlugin{runtime: runtime, module: module}, nil
+}
+
+// checkWasmPlugin checks module has no imports and exports memory, alloc and plugin functions of their signatures
+func checkWasmPlugin(module wazero.CompiledModule) error {
+	for _, imported := range module.ImportedFunctions() {
+		moduleName, name, _ := imported.Import()
+		return fmt.Errorf("%w: plugins must not import functions, imports %s.%s", errInvalidWasmPlugin, moduleName, name)
+	}
+	if _, ok := module.ExportedMemories()["memory"]; !ok {
+		return fmt.Errorf("%w: memory is not exported", errInvalidWasmPlugin)
+	}
+	exported := module.ExportedFunctions()
+	if alloc, ok := exported["alloc"]; !ok || !slices.Equal(alloc.ParamTypes(), []api.ValueType{api.ValueTypeI32}) ||
+		!slices.Equal(alloc.ResultTypes(), []api.ValueType{api.ValueTypeI32}) {
+		return fmt.Errorf("%w: alloc(i32) i32 is not exported", errInvalidWasmPlugin)
+	}
+	for _, name := range []string{"selection", "approval", "monitoring", "removal"} {
+		f, ok := exported[name]
+		if !ok {
+			continue
+		}
+		if !slices.Equal(f.ParamTypes(), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}) ||
+			!slices.Equal(f.ResultTypes(), []api.ValueType{api.ValueTypeI64}) {
+			return fmt.Errorf("%w: %s must be (i32, i32) i64", errInvalidWasmPlugin, name)
+		}
+	}
+	return nil
+}
+
+// load reads plugin from disk, module or store by digest and compiles it
+func (e *EntityWasmTargetController) load() (*wasmPlugin, error) {
+	binary := e.Module
+	if len(binary) == 0 && e.Path == "" {
+		return e.loadDigest()
+	}
+	if e.Path != "" {
+		info, err := os.Stat(e.Path)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > MaxWasmModuleSize {
+			return nil, fmt.Errorf("plugin %s is larger than %d bytes", e.Path, MaxWasmModuleSize)
+		}
+		if binary, err = os.ReadFile(e.Path); err != nil {
+			return nil, err
+		}
+	}
+	limits, _ := e.limits()
+	return compileWasmPlugin(binary, limits.MaxMemoryPages)
+}
+
+// loadDigest returns cached plugin of digest, loading module stored with entity if not cached
+func (e *EntityWasmTargetController) loadDigest() (*wasmPlugin, error) {
+	decoded, err := hex.DecodeString(e.Digest)
+	if err != nil || len(decoded) != sha256.Size {
+		return nil, fmt.Errorf("invalid wasm module digest %q", e.Digest)
+	}
+	digest := [sha256.Size]byte(decoded)
+	limits, _ := e.limits()
+	if plugin, ok := cachedWasmPlugin(digest, limits.MaxMemoryPages); ok {
+		return plugin, nil
+	}
+	if e.entity == nil {
+		return nil, fmt.Errorf("wasm module %s is not loaded", e.Digest)
+	}
+
+	var binary []byte
+	if err := e.entity.store.LoadJSON(e.entity.wasmModuleKey(e.Digest), &binary); err != nil {
+		return nil, fmt.Errorf("wasm module %s: %w", e.Digest, err)
+	}
+	if sha256.Sum256(binary) != digest {
+		return nil, fmt.Errorf("wasm module %s does not match its digest", e.Digest)
+	}
+	return compileWasmPlugin(binary, limits.MaxMemoryPages)
+}
+
+// Validate checks module or path is set and compiles plugin, stored modules are checked once controller is set,
+// limits are clamped to configured maximums
+func (e *EntityWasmTargetController) Validate() error {
+	if (len(e.Module) == 0 && e.Digest == "") == (e.Path == "") {
+		return fmt.Errorf("%w: either wasm module or path is required", ErrInvalidTargetController)
+	}
+	if len(e.Module) > MaxWasmModuleSize {
+		return fmt.Errorf("%w: wasm module is larger than %d bytes", ErrInvalidTargetController, MaxWasmModuleSize)
+	}
+	if e.TimeoutSecs < 0 {
+		return fmt.Errorf("%w: timeoutsecs must not be negative", ErrInvalidTargetController)
+	}
+	maxLimits, maxTimeout := maxWasmLimits()
+	e.Fuel = min(e.Fuel, maxLimits.Fuel)
+	e.MaxMemoryPages = min(e.MaxMemoryPages, maxLimits.MaxMemoryPages)
+	e.TimeoutSecs = min(e.TimeoutSecs, int(maxTimeout/time.Second))
+	if len(e.Module) == 0 && e.Path == "" {
+		return nil
+	}
+	if _, err := e.load(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTargetController, err)
+	}
+	return nil
+}
+
+// limits returns limits and timeout of each call, clamped to configured maximums
+// which may have been lowered since controller was set
+func (e *EntityWasmTargetController) limits() (wasmLimits, time.Duration) {
+	limits := wasmLimits{Fuel: e.Fuel, MaxMemoryPages: e.MaxMemoryPages}
+	if limits.Fuel == 0 {
+		limits.Fuel = defaultWasmFuel
+	}
+	if limits.MaxMemoryPages == 0 {
+		limits.MaxMemoryPages = defaultWasmMaxMemoryPages
+	}
+	timeout := defaultWasmTimeout
+	if e.TimeoutSecs > 0 {
+		timeout = time.Duration(e.TimeoutSecs) * time.Second
+	}
+
+	maxLimits, maxTimeout := maxWasmLimits()
+	limits.Fuel = min(limits.Fuel, maxLimits.Fuel)
+	limits.MaxMemoryPages = min(limits.MaxMemoryPages, maxLimits.MaxMemoryPages)
+	return limits, min(timeout, maxTimeout)
+}
+
+// invoke calls plugin function name with JSON request, decoding its JSON response,
+// returns false if plugin does not export name
+func (e *EntityWasmTargetController) invoke(name string, request, response any) (bool, error) {
+	ctx, span := tracing.Start(controllerContext(e.ctx), "wasm "+name)
+	defer span.End()
+
+	called, err := e.call(ctx, name, request, response)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return called, fmt.Errorf("%w: wasm %s: %w", ErrExternalControllerFailure, name, err)
+	}
+	return called, nil
+}
+
+func (e *EntityWasmTargetController) call(ctx context.Context, name string, request, response any) (bool, error) {
+	plugin, err := e.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := plugin.module.ExportedFunctions()[name]; !ok {
+		return false, nil
+	}
+
+	limits, timeout := e.limits()
+	fuel := &wasmFuel{}
+	fuel.remaining.Store(int64(min(limits.Fuel, math.MaxInt64)))
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, wasmFuelKey{}, fuel), timeout)
+	defer cancel()
+
+	// anonymous instances run concurrently, start functions other than start section are not called
+	instance, err := plugin.runtime.InstantiateModule(ctx, plugin.module, wazero.NewModuleConfig().WithName("").WithStartFunctions())
+	if err != nil {
+		return true, err
+	}
+	defer instance.Close(context.Background())
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return true, err
+	}
+	results, err := instance.ExportedFunction("alloc").Call(ctx, uint64(len(body)))
+	if err != nil {
+		return true, err
+	}
+	if !instance.Memory().Write(uint32(results[0]), body) {
+		return true, fmt.Errorf("alloc returned out of bounds memory")
+	}
+
+	results, err = instance.ExportedFunction(name).Call(ctx, uint64(uint32(results[0])), uint64(len(body)))
+	if err != nil {
+		return true, err
+	}
+	out, ok := instance.Memory().Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return true, fmt.Errorf("%s returned out of bounds memory", name)
+	}
+	return true, json.Unmarshal(out, response)
+}
+
+// TargetSelection selects list of targets
+func (e *EntityWasmTargetController) TargetSelection(clientTargets []*ClientState, numSelection int) ([]*ClientState, error) {
+	var response TargetSelectionResponse
+	called, err := e.invoke("selection", TargetSelectionRequest{Targets: clientTargets, Count: numSelection}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if !called {
+		return clientTargets, nil
+	}
+	return response.Targets, nil
+}
+
+// TargetApproval gets approval for list of targets
+func (e *EntityWasmTargetController) TargetApproval(clientTargets []*ClientState) ([]*ClientState, error) {
+	var response TargetApprovalResponse
+	called, err := e.invoke("approval", TargetApprovalRequest{Targets: clientTargets}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if !called {
+		return clientTargets, nil
+	}
+
+	var approvedTargets []*ClientState
+	for _, target := range response.Targets {
+		for _, clientTarget := range clientTargets {
+			if clientTarget.Name == target.Name {
+				// only known targets can get approved
+				approvedTargets = append(approvedTargets, clientTarget)
+			}
+		}
+	}
+	return approvedTargets, nil
+}
+
+// TargetMonitoring monitors the provided target
+func (e *EntityWasmTargetController) TargetMonitoring(clientTarget *ClientState) error {
+	var response TargetMonitoringResponse
+	called, err := e.invoke("monitoring", TargetMonitoringRequest{Target: clientTarget}, &response)
+	if err != nil || !called {
+		return err
+	}
+	if strings.ToLower(response.Status) != "ok" {
+		return fmt.Errorf("%s %s", response.Status, response.Message)
+	}
+	return nil
+}
+
+// TargetRemoval removes optional set of additional targets
+func (e *EntityWasmTargetController) TargetRemoval(clientTargets []*ClientState, numRemoval int) ([]*ClientState, error) {
+	var response TargetRemovalResponse
+	called, err := e.invoke("removal", TargetRemovalRequest{Targets: clientTargets, Count: numRemoval}, &response)
+	if err != nil || !called {
+		return nil, err
+	}
+
+	var removedTargets []*ClientState
+	for _, target := range response.Targets {
+		for _, clientTarget := range clientTargets {
+			if clientTarget.Name == target.Name {
+				// only known targets can get removed
+				removedTargets = append(removedTargets, clientTarget)
+			}
+		}
+	}
+	return removedTargets, nil
+}
+
+func (e *EntityWasmTargetController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntityWasmTargetController) setRollout(r *Rollout) {
+	e.entity = r.entity
+}
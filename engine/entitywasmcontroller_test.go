@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWasmPlugin is
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (global $heap (mut i32) (i32.const 1024))
+//	  (data (i32.const 16) "{\"status\":\"failed\",\"message\":\"unhealthy\"}")
+//	  (func (export "alloc") (param $size i32) (result i32)
+//	    global.get $heap global.get $heap local.get $size i32.add global.set $heap)
+//	  ;; approves every target by echoing request
+//	  (func (export "approval") (param $ptr i32) (param $len i32) (result i64)
+//	    local.get $ptr i64.extend_i32_u i64.const 32 i64.shl local.get $len i64.extend_i32_u i64.or)
+//	  ;; fails every target
+//	  (func (export "monitoring") (param i32 i32) (result i64)
+//	    i64.const 68719476777)
+//	  ;; never returns, allocating until fuel is exhausted
+//	  (func (export "selection") (param i32 i32) (result i64)
+//	    loop i32.const 0 call 0 drop br 0 end unreachable))
+var testWasmPlugin = []byte{
+	// magic and version
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// types: (i32)->i32, (i32 i32)->i64
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e,
+	// functions: alloc, approval, monitoring, selection
+	0x03, 0x05, 0x04, 0x00, 0x01, 0x01, 0x01,
+	// memory: 1 page
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// global: mutable i32 heap pointer at 1024
+	0x06, 0x07, 0x01, 0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b,
+	// exports
+	0x07, 0x36, 0x05, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x05, 0x61, 0x6c, 0x6c,
+	0x6f, 0x63, 0x00, 0x00, 0x08, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x00, 0x01, 0x0a,
+	0x6d, 0x6f, 0x6e, 0x69, 0x74, 0x6f, 0x72, 0x69, 0x6e, 0x67, 0x00, 0x02, 0x09, 0x73, 0x65, 0x6c,
+	0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x00, 0x03,
+	// code
+	0x0a, 0x32, 0x04, 0x0b, 0x00, 0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6a, 0x24, 0x00, 0x0b, 0x0c,
+	0x00, 0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b, 0x09, 0x00, 0x42, 0xa9,
+	0x80, 0x80, 0x80, 0x80, 0x02, 0x0b, 0x0d, 0x00, 0x03, 0x40, 0x41, 0x00, 0x10, 0x00, 0x1a, 0x0c,
+	0x00, 0x0b, 0x00, 0x0b,
+	// data: monitoring response at 16
+	0x0b, 0x2f, 0x01, 0x00, 0x41, 0x10, 0x0b, 0x29, 0x7b, 0x22, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x22, 0x3a, 0x22, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x22, 0x2c, 0x22, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x22, 0x3a, 0x22, 0x75, 0x6e, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x22,
+	0x7d,
+}
+
+func TestWasmTargetController(t *testing.T) {
+	controller := &EntityWasmTargetController{Module: testWasmPlugin, Fuel: 100000}
+	require.NoError(t, controller.Validate())
+	targets := []*ClientState{{Name: "target0"}, {Name: "target1"}}
+
+	approved, err := controller.TargetApproval(targets)
+	require.NoError(t, err)
+	assert.Equal(t, targets, approved)
+
+	assert.EqualError(t, controller.TargetMonitoring(targets[0]), "failed unhealthy")
+
+	_, err = controller.TargetSelection(targets, 1)
+	assert.ErrorIs(t, err, ErrExternalControllerFailure)
+	assert.ErrorIs(t, err, errWasmFuelExhausted)
+
+	// calls stop once they time out before running out of fuel
+	slow := &EntityWasmTargetController{Module: testWasmPlugin, Fuel: maxWasmFuel, TimeoutSecs: 1}
+	require.NoError(t, slow.Validate())
+	_, err = slow.TargetSelection(targets, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// removal is not exported
+	removed, err := controller.TargetRemoval(targets, 1)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	assert.ErrorIs(t, (&EntityWasmTargetController{}).Validate(), ErrInvalidTargetController)
+	assert.ErrorIs(t, (&EntityWasmTargetController{Module: []byte("not wasm")}).Validate(), ErrInvalidTargetController)
+}
+
+func TestWasmTargetControllerMaxLimits(t *testing.T) {
+	setWasmMaxLimits(config.WasmConfig{MaxFuel: 1000, MaxMemoryPages: 2, MaxTimeoutSecs: 1})
+	defer setWasmMaxLimits(config.WasmConfig{})
+
+	controller := &EntityWasmTargetController{Module: testWasmPlugin, Fuel: 1 << 40, MaxMemoryPages: 1 << 16, TimeoutSecs: 3600}
+	require.NoError(t, controller.Validate())
+	assert.Equal(t, uint64(1000), controller.Fuel)
+	assert.Equal(t, uint32(2), controller.MaxMemoryPages)
+	assert.Equal(t, 1, controller.TimeoutSecs)
+
+	// defaults are clamped as well
+	limits, timeout := (&EntityWasmTargetController{}).limits()
+	assert.Equal(t, wasmLimits{Fuel: 1000, MaxMemoryPages: 2}, limits)
+	assert.Equal(t, time.Second, timeout)
+
+	setWasmMaxLimits(config.WasmConfig{})
+	limits, timeout = (&EntityWasmTargetController{}).limits()
+	assert.Equal(t, wasmLimits{Fuel: defaultWasmFuel, MaxMemoryPages: defaultWasmMaxMemoryPages}, limits)
+	assert.Equal(t, defaultWasmTimeout, timeout)
+}
+
+func TestWasmTargetControllerPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugin.wasm")
+	controller := &EntityWasmTargetController{Path: path}
+	assert.ErrorIs(t, controller.Validate(), ErrInvalidTargetController)
+
+	require.NoError(t, os.WriteFile(path, testWasmPlugin, 0o600))
+	require.NoError(t, controller.Validate())
+	assert.Error(t, controller.TargetMonitoring(&ClientState{Name: "target0"}))
+
+	// plugin is swapped by replacing the file, module without functions passes monitoring
+	require.NoError(t, os.WriteFile(path, []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		// types, functions, memory and exports of memory and alloc
+		0x01, 0x06, 0x01, 0x60, 0x01, 0x7f, 0x01, 0x7f,
+		0x03, 0x02, 0x01, 0x00,
+		0x05, 0x03, 0x01, 0x00, 0x01,
+		0x07, 0x12, 0x02, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x00,
+		// alloc returns 0
+		0x0a, 0x06, 0x01, 0x04, 0x00, 0x41, 0x00, 0x0b,
+	}, 0o600))
+	assert.NoError(t, controller.TargetMonitoring(&ClientState{Name: "target0"}))
+}
+
+func TestWasmTargetControllerStoredModule(t *testing.T) {
+	const testName = "TestWasmTargetControllerStoredModule"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = setupNamespace(engine, testName, testName, 1)
+	require.NoError(t, err)
+	require.NoError(t, engine.SetEntityTargetController(testName, testName, &EntityWasmTargetController{Module: testWasmPlugin, Fuel: 100000}))
+
+	namespace, err := engine.findNamespace(testName)
+	require.NoError(t, err)
+	entity, err := namespace.findEntity(testName)
+	require.NoError(t, err)
+
+	// rollout keeps digest of module stored once with entity
+	digest := sha256.Sum256(testWasmPlugin)
+	keys, err := engine.store.LoadKeys(entity.wasmModulePrefix())
+	require.NoError(t, err)
+	assert.Equal(t, []string{entity.wasmModuleKey(hex.EncodeToString(digest[:]))}, keys)
+
+	rollout, err := entity.findOrCreateRollout()
+	require.NoError(t, err)
+	controller, ok := rollout.TargetController.EntityTargetController.(*EntityWasmTargetController)
+	require.True(t, ok)
+	assert.Empty(t, controller.Module)
+	assert.Equal(t, hex.EncodeToString(digest[:]), controller.Digest)
+
+	// module is loaded by digest, uncached modules are read from store
+	wasmPlugins.Lock()
+	for _, module := range wasmPlugins.modules {
+		require.NoError(t, module.Close(context.Background()))
+	}
+	clear(wasmPlugins.modules)
+	wasmPlugins.Unlock()
+	rollout.setControllerContext(context.Background())
+	assert.EqualError(t, controller.TargetMonitoring(&ClientState{Name: "target0"}), "failed unhealthy")
+
+	// config read back applies with stored module
+	entityConfig, err := engine.GetEntityConfig(testName, testName)
+	require.NoError(t, err)
+	_, err = engine.PutEntityConfig(testName, testName, entityConfig, entityConfig.Version)
+	require.NoError(t, err)
+
+	_, err = engine.PutEntityConfig(testName, testName, &EntityConfig{
+		TargetController: &ControllerConfig{Type: "wasm", Settings: []byte(`{"digest":"` + strings.Repeat("0", 64) + `"}`)},
+	}, "")
+	assert.ErrorIs(t, err, ErrInvalidTargetController)
+
+	// replaced controller removes stored module
+	require.NoError(t, engine.SetEntityTargetController(testName, testName, &NoOpEntityTargetController{}))
+	keys, err = engine.store.LoadKeys(entity.wasmModulePrefix())
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, engine.SetEntityTargetController(testName, testName, &EntityWasmTargetController{Module: testWasmPlugin}))
+	require.NoError(t, engine.DeleteEntity(testName, testName))
+	keys, err = engine.store.LoadKeys(entity.wasmModulePrefix())
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
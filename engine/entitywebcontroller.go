@@ -1,14 +1,16 @@
-package core
+package engine
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
-	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/nixmade/orchestrator/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // EntityWebController defines set of callback functions wrapper around RolloutController and could be more
@@ -31,12 +33,24 @@ type EntityWebTargetController struct {
 	// MonitoringEndpoint checks for any additional monitoring for individual target
 	//	typically health information is included in messages, but this provides another opportunity
 	MonitoringEndpoint string `json:"monitoring,omitempty"`
+
+	WebControllerPolicy `json:",inline"`
+	// Auth of calls to endpoints, none if nil
+	Auth *WebControllerAuth `json:"auth,omitempty"`
+
+	ctx context.Context
 }
 
 type EntityWebMonitoringController struct {
 	// ExternalMonitoringEndpoint communicates with external monitoring system,
 	// 	in case rollout has degraded the system as a whole
 	ExternalMonitoringEndpoint string `json:"externalmonitoring,omitempty"`
+
+	WebControllerPolicy `json:",inline"`
+	// Auth of calls to endpoints, none if nil
+	Auth *WebControllerAuth `json:"auth,omitempty"`
+
+	ctx context.Context
 }
 
 // TargetSelectionRequest request of target list with count
@@ -99,9 +113,28 @@ func (e *EntityWebTargetController) TargetSelection(clientTargets []*ClientState
 		return clientTargets, nil
 	}
 
-	var trResponse TargetSelectionResponse
+	postBuf, err := json.Marshal(TargetSelectionRequest{Targets: clientTargets, Count: numSelection})
+	if err != nil {
+		return nil, err
+	}
 
-	if err := httpclient.PostJSON(e.SelectionEndpoint, "", TargetSelectionRequest{Targets: clientTargets, Count: numSelection}, &trResponse); err != nil {
+	respBody, err := e.post(e.ctx, e.SelectionEndpoint, postBuf, e.Auth)
+	if e.failOpen(err) {
+		return clientTargets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := respBody.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	var trResponse TargetSelectionResponse
+	if err := json.NewDecoder(respBody).Decode(&trResponse); err != nil {
 		return nil, err
 	}
 
@@ -119,7 +152,10 @@ func (e *EntityWebTargetController) TargetApproval(clientTargets []*ClientState)
 		return nil, err
 	}
 
-	respBody, err := makeRequest(e.ApprovalEndpoint, postBuf)
+	respBody, err := e.post(e.ctx, e.ApprovalEndpoint, postBuf, e.Auth)
+	if e.failOpen(err) {
+		return clientTargets, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +197,10 @@ func (e *EntityWebTargetController) TargetMonitoring(clientTarget *ClientState)
 		return err
 	}
 
-	respBody, err := makeRequest(e.MonitoringEndpoint, postBuf)
+	respBody, err := e.post(e.ctx, e.MonitoringEndpoint, postBuf, e.Auth)
+	if e.failOpen(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -197,7 +236,10 @@ func (e *EntityWebTargetController) TargetRemoval(clientTargets []*ClientState,
 		return nil, err
 	}
 
-	respBody, err := makeRequest(e.RemovalEndpoint, postBuf)
+	respBody, err := e.post(e.ctx, e.RemovalEndpoint, postBuf, e.Auth)
+	if e.failOpen(err) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -240,7 +282,10 @@ func (e *EntityWebMonitoringController) ExternalMonitoring(clientTargets []*Clie
 		return err
 	}
 
-	respBody, err := makeRequest(e.ExternalMonitoringEndpoint, postBuf)
+	respBody, err := e.post(e.ctx, e.ExternalMonitoringEndpoint, postBuf, e.Auth)
+	if e.failOpen(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -264,15 +309,64 @@ func (e *EntityWebMonitoringController) ExternalMonitoring(clientTargets []*Clie
 	return nil
 }
 
-func makeRequest(req string, postBuf []byte) (io.ReadCloser, error) {
-	resp, err := http.Post(req, "application/json", bytes.NewBuffer(postBuf))
+func (e *EntityWebTargetController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntityWebMonitoringController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntityWebTargetController) endpoints() []string {
+	var endpoints []string
+	for _, endpoint := range []string{e.SelectionEndpoint, e.ApprovalEndpoint, e.RemovalEndpoint, e.MonitoringEndpoint} {
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+func (e *EntityWebMonitoringController) endpoints() []string {
+	if e.ExternalMonitoringEndpoint == "" {
+		return nil
+	}
+	return []string{e.ExternalMonitoringEndpoint}
+}
+
+func controllerContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+func makeRequest(ctx context.Context, url string, postBuf []byte, auth *WebControllerAuth) (io.ReadCloser, error) {
+	ctx, span := tracing.StartWithKind(controllerContext(ctx), "POST "+url, trace.SpanKindClient)
+	defer span.End()
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(postBuf))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	auth.apply(req, postBuf)
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ErrExternalControllerFailure
+		statusErr := &endpointStatusError{url: url, statusCode: resp.StatusCode}
+		tracing.RecordError(span, statusErr)
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			return nil, closeErr
+		}
+		return nil, statusErr
 	}
 
 	return resp.Body, err
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+var (
+	// ErrNamespaceNotCreated returns an error if namespace is not created
+	ErrNamespaceNotCreated = errors.New("namespace not created")
+	// ErrEntityNotCreated returns an error if entity is not created
+	ErrEntityNotCreated = errors.New("entity not created")
+	// ErrInvalidTargetVersion returns an error if target version is invalid
+	ErrInvalidTargetVersion = errors.New("invalid Target Version")
+	// ErrExternalControllerFailure returns an error if call to external controller failed
+	ErrExternalControllerFailure = errors.New("failure calling external controller")
+	// ErrTargetNotQuarantined returns an error if target is released but not quarantined
+	ErrTargetNotQuarantined = errors.New("target not quarantined")
+	// ErrInvalidTargetState returns an error if target state filter is unknown
+	ErrInvalidTargetState = errors.New("invalid target state")
+	// ErrTargetNotPinned returns an error if target being unpinned is not pinned
+	ErrTargetNotPinned = errors.New("target not pinned")
+	// ErrQuotaExceeded returns an error if namespace quota is exceeded
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrReadOnly returns an error if mutating request is made while server is in read-only mode
+	ErrReadOnly = errors.New("orchestrator is in read-only mode, mutating requests are disabled")
+	// ErrVersionConflict returns an error if change conflicts with version currently rolling out or stored
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrRolloutInProgress returns an error if target version is rejected while rollout is in progress
+	ErrRolloutInProgress = fmt.Errorf("%w: rollout in progress", ErrVersionConflict)
+	// ErrRolloutPaused returns an error if rollout of entity or group is already paused
+	ErrRolloutPaused = errors.New("rollout paused")
+	// ErrNamespaceNotFound returns an error if namespace does not exist
+	ErrNamespaceNotFound = errors.New("namespace not found")
+	// ErrEntityNotFound returns an error if entity does not exist in namespace
+	ErrEntityNotFound = errors.New("entity not found")
+	// ErrInvalidConcurrencyPolicy returns an error if concurrency policy is unknown
+	ErrInvalidConcurrencyPolicy = errors.New("invalid concurrency policy")
+	// ErrEngineNotReady returns an error if engine is not yet created
+	ErrEngineNotReady = errors.New("orchestrator engine not ready")
+	// ErrInvalidEmergencyProfile returns an error if emergency profile is invalid
+	ErrInvalidEmergencyProfile = errors.New("invalid emergency profile")
+	// ErrSchemaVersionUnsupported returns an error if store was written by a newer engine
+	ErrSchemaVersionUnsupported = errors.New("unsupported store schema version")
+	// ErrMigrationFailed returns an error if store migration failed and was rolled back
+	ErrMigrationFailed = errors.New("store migration failed")
+	// ErrEngineShutdown returns an error if async orchestration is requested after engine shutdown
+	ErrEngineShutdown = errors.New("orchestrator engine shut down")
+	// ErrInvalidRolloutOptions returns an error if rollout options are out of range or contradict each other
+	ErrInvalidRolloutOptions = errors.New("invalid rollout options")
+	// ErrInvalidVersionScheme returns an error if version scheme is unknown
+	ErrInvalidVersionScheme = errors.New("invalid version scheme")
+	// ErrVersionDowngrade returns an error if target version is lower than last known good version of semver entity
+	ErrVersionDowngrade = fmt.Errorf("%w: version downgrade", ErrVersionConflict)
+	// ErrInvalidRollbackScope returns an error if rollback scope is unknown
+	ErrInvalidRollbackScope = errors.New("invalid rollback scope")
+	// ErrInvalidBatchPlan returns an error if batch plan percentages are out of range or decrease
+	ErrInvalidBatchPlan = errors.New("invalid batch plan")
+	// ErrInvalidFailureBudget returns an error if MaxFailedTargets or MaxFailedPercent are out of range
+	ErrInvalidFailureBudget = errors.New("invalid failure budget")
+	// ErrInvalidTargetSelector returns an error if target selector label expression is invalid
+	ErrInvalidTargetSelector = errors.New("invalid target selector")
+	// ErrInvalidVersionSplit returns an error if split versions are not unique or percentages do not add up to 100
+	ErrInvalidVersionSplit = errors.New("invalid version split")
+	// ErrInvalidWebControllerPolicy returns an error if web controller timeout, retries or failure policy are invalid
+	ErrInvalidWebControllerPolicy = errors.New("invalid web controller policy")
+	// ErrInvalidWebControllerAuth returns an error if web controller auth combines exclusive methods
+	ErrInvalidWebControllerAuth = errors.New("invalid web controller auth")
+	// ErrInvalidTargetController returns an error if target controller is misconfigured
+	ErrInvalidTargetController = errors.New("invalid target controller")
+	// ErrInvalidMonitoringController returns an error if monitoring controller is misconfigured
+	ErrInvalidMonitoringController = errors.New("invalid monitoring controller")
+	// ErrInvalidAnalysisConfig returns an error if canary analysis provider or metrics are invalid
+	ErrInvalidAnalysisConfig = errors.New("invalid analysis config")
+	// ErrInvalidSchedule returns an error if rollout schedule or its cron expressions are invalid
+	ErrInvalidSchedule = errors.New("invalid rollout schedule")
+	// ErrInvalidGroupRule returns an error if group assignment rule is invalid
+	ErrInvalidGroupRule = errors.New("invalid group rule")
+	// ErrInvalidDependencies returns an error if entity dependencies are invalid or form a cycle
+	ErrInvalidDependencies = errors.New("invalid entity dependencies")
+	// ErrApprovalNotPending returns an error if approval does not exist or was already decided
+	ErrApprovalNotPending = errors.New("approval not pending")
+	// ErrInvalidRegistryWatch returns an error if registry watch repository, pattern or interval is invalid
+	ErrInvalidRegistryWatch = errors.New("invalid registry watch")
+	// ErrRegistryTagNotPending returns an error if approved tag is not the tag of registry watch waiting for approval
+	ErrRegistryTagNotPending = errors.New("registry tag not pending approval")
+	// ErrInvalidEntityConfig returns an error if a section of declarative entity config is invalid
+	ErrInvalidEntityConfig = errors.New("invalid entity config")
+	// ErrEntityConfigVersionMismatch returns an error if entity config changed since the version put was based on
+	ErrEntityConfigVersionMismatch = fmt.Errorf("%w: entity config version mismatch", ErrVersionConflict)
+	// ErrInvalidBulkRequest returns an error if bulk orchestrate call has too many entities or entity without name
+	ErrInvalidBulkRequest = errors.New("invalid bulk orchestrate request")
+	// ErrIdempotencyKeyReused returns an error if idempotency key is reused for a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with different request")
+	// ErrInvalidLogLevel returns an error if log level is not a zerolog level or temporary duration is negative
+	ErrInvalidLogLevel = errors.New("invalid log level")
+)
+
+// NotFoundError is returned when namespace or entity does not exist,
+// it matches its Kind and store.ErrKeyNotFound with errors.Is
+type NotFoundError struct {
+	// Kind is ErrNamespaceNotFound or ErrEntityNotFound
+	Kind error
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Name)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Kind
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == store.ErrKeyNotFound
+}
+
+// ErrorStatus returns http status code of err, errors without a specific code are bad requests
+func ErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrEntityConfigVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, store.ErrKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrVersionConflict), errors.Is(err, ErrRolloutPaused), errors.Is(err, store.ErrTxnConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is type of rollout lifecycle event
+type EventType string
+
+const (
+	// EventRolloutStarted is emitted when a new version starts rolling out
+	EventRolloutStarted EventType = "RolloutStarted"
+	// EventBatchStarted is emitted when a batch of targets is assigned rolling version
+	EventBatchStarted EventType = "BatchStarted"
+	// EventTargetFailed is emitted when a target starts failing
+	EventTargetFailed EventType = "TargetFailed"
+	// EventTargetQuarantined is emitted when a target is quarantined after consecutive failures
+	EventTargetQuarantined EventType = "TargetQuarantined"
+	// EventRollbackInitiated is emitted when rolling version is marked bad and lkg is rolled back
+	EventRollbackInitiated EventType = "RollbackInitiated"
+	// EventRolloutSucceeded is emitted when rolling version becomes lkg
+	EventRolloutSucceeded EventType = "RolloutSucceeded"
+	// EventQuotaWarning is emitted when namespace crosses QuotaWarningPercent of its quota
+	EventQuotaWarning EventType = "QuotaWarning"
+	// EventApprovalRequested is emitted when a batch waits for human approval
+	EventApprovalRequested EventType = "ApprovalRequested"
+	// EventApprovalGranted is emitted when approval of a batch is granted, Message records who approved it
+	EventApprovalGranted EventType = "ApprovalGranted"
+	// EventApprovalDenied is emitted when approval of a batch is denied, Message records who denied it
+	EventApprovalDenied EventType = "ApprovalDenied"
+	// EventRolloutStalled is emitted when in progress rollout made no progress for StallTimeoutSecs, Message records the reason
+	EventRolloutStalled EventType = "RolloutStalled"
+	// EventTargetUpdated is emitted whenever target state is persisted, it is not sent to webhooks
+	EventTargetUpdated EventType = "TargetUpdated"
+)
+
+// Event is a structured rollout lifecycle event
+type Event struct {
+	Type                 EventType `json:"type,omitempty"`
+	Namespace            string    `json:"namespace,omitempty"`
+	Entity               string    `json:"entity,omitempty"`
+	Version              string    `json:"version,omitempty"`
+	LastKnownGoodVersion string    `json:"lastknowngoodversion,omitempty"`
+	LastKnownBadVersion  string    `json:"lastknownbadversion,omitempty"`
+	Group                string    `json:"group,omitempty"`
+	Target               string    `json:"target,omitempty"`
+	Targets              int       `json:"targets,omitempty"`
+	FailedTargets        int       `json:"failedtargets,omitempty"`
+	Message              string    `json:"message,omitempty"`
+	Ticket               string    `json:"ticket,omitempty"`
+	Note                 string    `json:"note,omitempty"`
+	Expedited            bool      `json:"expedited,omitempty"`
+	Timestamp            time.Time `json:"timestamp,omitempty"`
+	// State of target for TargetUpdated
+	State *ClientState `json:"state,omitempty"`
+}
+
+// EventHandler is called for every published event, handlers are called synchronously and should not block
+type EventHandler func(event Event)
+
+// EventBus fans out events to subscribers
+type EventBus struct {
+	lock     sync.RWMutex
+	nextID   int
+	handlers map[int]EventHandler
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[int]EventHandler)}
+}
+
+// Subscribe registers handler, returned func unsubscribes it
+func (b *EventBus) Subscribe(handler EventHandler) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// Publish calls all subscribed handlers with event, events published to nil bus are dropped
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	b.lock.RLock()
+	handlers := make([]EventHandler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.lock.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// addEvent queues event, queued events are published once orchestration completes
+func (r *Rollout) addEvent(event Event) {
+	event.Namespace = r.entity.Namespace
+	event.Entity = r.entity.Name
+	event.LastKnownGoodVersion = r.State.LastKnownGoodVersion
+	event.LastKnownBadVersion = r.State.LastKnownBadVersion
+	if event.Version == "" {
+		event.Version = r.State.RollingVersion
+	}
+	event.Ticket = r.State.RollingChange.Ticket
+	event.Note = r.State.RollingChange.Note
+	event.Expedited = r.State.RollingChange.Expedited
+	event.Timestamp = nowUTC()
+	r.events = append(r.events, event)
+}
+
+// publishEvents adds lifecycle events based on state transitions and publishes all queued events
+func (r *Rollout) publishEvents(previous RolloutVersionInfo, state *rolloutInfo) {
+	rollingVersion := r.State.RollingVersion
+	if rollingVersion != previous.RollingVersion &&
+		rollingVersion != r.State.LastKnownGoodVersion &&
+		rollingVersion != r.State.LastKnownBadVersion {
+		// prepend, rollout started before any batch
+		events := r.events
+		r.events = nil
+		r.addEvent(Event{Type: EventRolloutStarted, Targets: len(state.totalTargets)})
+		r.events = append(r.events, events...)
+	}
+
+	if previous.LastKnownBadVersion != r.State.LastKnownBadVersion &&
+		r.State.LastKnownBadVersion == rollingVersion &&
+		r.State.LastKnownGoodVersion != "" {
+		r.addEvent(Event{
+			Type:          EventRollbackInitiated,
+			Targets:       len(state.totalTargets),
+			FailedTargets: len(state.failedTargets),
+		})
+	}
+
+	if previous.LastKnownGoodVersion != r.State.LastKnownGoodVersion &&
+		r.State.LastKnownGoodVersion == rollingVersion {
+		r.addEvent(Event{
+			Type:          EventRolloutSucceeded,
+			Targets:       len(state.totalTargets),
+			FailedTargets: len(state.failedTargets),
+		})
+	}
+
+	events := r.events
+	r.events = nil
+	if len(events) <= 0 {
+		return
+	}
+
+	for _, event := range events {
+		r.logger.Info().Str("Event", string(event.Type)).Str("Version", event.Version).Msg("Publishing rollout event")
+		r.entity.events.Publish(event)
+	}
+
+	r.entity.notify(events)
+	r.entity.notifySlack(events)
+}
+
+// publishTargetUpdated publishes persisted target state immediately
+func (e *Entity) publishTargetUpdated(entityTarget *EntityTarget) {
+	e.events.Publish(Event{
+		Type:      EventTargetUpdated,
+		Namespace: e.Namespace,
+		Entity:    e.Name,
+		Group:     entityTarget.Group,
+		Target:    entityTarget.Name,
+		Version:   entityTarget.State.TargetVersion.Version,
+		Timestamp: nowUTC(),
+		State:     returnClientTarget(entityTarget),
+	})
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+
+	var received []EventType
+	unsubscribe := bus.Subscribe(func(event Event) {
+		received = append(received, event.Type)
+	})
+
+	bus.Publish(Event{Type: EventRolloutStarted})
+	unsubscribe()
+	bus.Publish(Event{Type: EventRolloutSucceeded})
+
+	assert.Equal(t, []EventType{EventRolloutStarted}, received)
+}
+
+func TestPublishEvents(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+	e.events = NewEventBus()
+
+	var received []Event
+	unsubscribe := e.events.Subscribe(func(event Event) {
+		if event.Entity == e.Name {
+			received = append(received, event)
+		}
+	})
+	defer unsubscribe()
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	previous := rollout.State.RolloutVersionInfo
+	rollout.State.RollingVersion = "v2"
+	rollout.addEvent(Event{Type: EventBatchStarted, Targets: 2})
+	rollout.publishEvents(previous, createRolloutInfo(nil))
+
+	require.Len(t, received, 2)
+	assert.Equal(t, EventRolloutStarted, received[0].Type)
+	assert.Equal(t, EventBatchStarted, received[1].Type)
+	assert.Equal(t, "v2", received[1].Version)
+
+	received = nil
+	previous = rollout.State.RolloutVersionInfo
+	rollout.State.LastKnownBadVersion = "v2"
+	rollout.publishEvents(previous, createRolloutInfo(nil))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, EventRollbackInitiated, received[0].Type)
+	assert.Equal(t, "v1", received[0].LastKnownGoodVersion)
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	config := &NotificationConfig{Secret: "secret"}
+
+	var event Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, signPayload(config.Secret, payload), r.Header.Get(SignatureHeader))
+		require.NoError(t, json.Unmarshal(payload, &event))
+	}))
+	defer server.Close()
+	config.URL = server.URL
+
+	require.NoError(t, deliverWebhook(context.Background(), config, Event{Type: EventRolloutSucceeded, Version: "v2"}))
+	assert.Equal(t, EventRolloutSucceeded, event.Type)
+	assert.Equal(t, "v2", event.Version)
+
+	assert.True(t, config.matches(EventTargetFailed))
+	config.Events = []EventType{EventRollbackInitiated}
+	assert.False(t, config.matches(EventTargetFailed))
+}
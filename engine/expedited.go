@@ -0,0 +1,66 @@
+package engine
+
+import "fmt"
+
+// EmergencyProfile overrides rollout options while an expedited version is rolling out
+type EmergencyProfile struct {
+	// Percentage of targets in rollout
+	BatchPercent int `json:"batchpercent,omitempty"`
+	// Timeout in secs to have successful monitoring window
+	SuccessTimeoutSecs int `json:"successtimeoutsecs,omitempty"`
+	// Max Duration timeout in secs to wait to have a successful monitoring window
+	DurationTimeoutSecs int `json:"durationtimeoutsecs,omitempty"`
+	// Bake time in secs after a ring is successful before promoting next ring
+	GroupBakeTimeSecs int `json:"groupbaketimesecs,omitempty"`
+	// Minimum secs between starting batches
+	BatchIntervalSecs int `json:"batchintervalsecs,omitempty"`
+}
+
+// DefaultEmergencyProfile used for expedited versions when rollout options do not configure one
+func DefaultEmergencyProfile() *EmergencyProfile {
+	return &EmergencyProfile{
+		BatchPercent:        50,
+		SuccessTimeoutSecs:  15,
+		DurationTimeoutSecs: 60,
+		GroupBakeTimeSecs:   0,
+	}
+}
+
+func (p *EmergencyProfile) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.BatchPercent <= 0 || p.BatchPercent > 100 {
+		return fmt.Errorf("%w: batchpercent must be between 1 and 100", ErrInvalidEmergencyProfile)
+	}
+	if p.SuccessTimeoutSecs < 0 || p.DurationTimeoutSecs < 0 || p.GroupBakeTimeSecs < 0 || p.BatchIntervalSecs < 0 {
+		return fmt.Errorf("%w: timeouts must not be negative", ErrInvalidEmergencyProfile)
+	}
+	return nil
+}
+
+// emergencyProfile returns configured profile, defaults to DefaultEmergencyProfile
+func (o *RolloutOptions) emergencyProfile() *EmergencyProfile {
+	if o == nil || o.EmergencyProfile == nil {
+		return DefaultEmergencyProfile()
+	}
+	return o.EmergencyProfile
+}
+
+// options returns rollout options in effect for rolling version,
+// batch size and bake times come from emergency profile while rolling version is expedited
+func (r *Rollout) options() *RolloutOptions {
+	if !r.State.RollingChange.Expedited {
+		return r.State.Options
+	}
+
+	profile := r.State.Options.emergencyProfile()
+	options := *r.State.Options
+	options.BatchPercent = profile.BatchPercent
+	options.BatchPlan = nil
+	options.SuccessTimeoutSecs = profile.SuccessTimeoutSecs
+	options.DurationTimeoutSecs = profile.DurationTimeoutSecs
+	options.GroupBakeTimeSecs = profile.GroupBakeTimeSecs
+	options.BatchIntervalSecs = profile.BatchIntervalSecs
+	return &options
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countVersion(clientTargets []*ClientState, version string) int {
+	count := 0
+	for _, clientTarget := range clientTargets {
+		if clientTarget.Version == version {
+			count++
+		}
+	}
+	return count
+}
+
+func TestExpeditedRollout(t *testing.T) {
+	e, clientTargets, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	require.NoError(t, e.setRolloutOptions(&RolloutOptions{
+		BatchPercent:        10,
+		SuccessPercent:      100,
+		SuccessTimeoutSecs:  60,
+		DurationTimeoutSecs: 120,
+		EmergencyProfile:    &EmergencyProfile{BatchPercent: 50, SuccessTimeoutSecs: 5, DurationTimeoutSecs: 30},
+	}))
+
+	change := ChangeInfo{Ticket: "SEC-42", Expedited: true}
+	require.NoError(t, e.setTargetVersion(EntityTargetVersion{Version: "v2", ChangeInfo: change}, false))
+
+	assigned, err := e.orchestrate(context.Background(), clientTargets)
+	require.NoError(t, err)
+	// emergency profile batch percent overrides configured 10%
+	assert.Equal(t, 5, countVersion(assigned, "v2"))
+
+	rolloutState, err := e.getRolloutInfo()
+	require.NoError(t, err)
+	assert.True(t, rolloutState.RollingChange.Expedited)
+	// configured options are unchanged, override only applies while expedited version is rolling
+	assert.Equal(t, 10, rolloutState.Options.BatchPercent)
+
+	history, err := e.getRolloutHistory(0, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	assert.True(t, history[0].Expedited)
+	assert.Equal(t, "SEC-42", history[0].Ticket)
+}
+
+func TestExpeditedRolloutOptions(t *testing.T) {
+	rollout := &Rollout{State: RolloutState{Options: DefaultRolloutOptions()}}
+	assert.Equal(t, 5, rollout.options().BatchPercent)
+
+	rollout.State.RollingChange.Expedited = true
+	options := rollout.options()
+	assert.Equal(t, DefaultEmergencyProfile().BatchPercent, options.BatchPercent)
+	assert.Equal(t, DefaultEmergencyProfile().SuccessTimeoutSecs, options.SuccessTimeoutSecs)
+	assert.Equal(t, 100, options.SuccessPercent)
+	assert.Equal(t, 5, rollout.State.Options.BatchPercent)
+
+	invalid := DefaultRolloutOptions()
+	invalid.EmergencyProfile = &EmergencyProfile{BatchPercent: 0}
+	assert.ErrorIs(t, rollout.setRolloutOptions(invalid), ErrInvalidEmergencyProfile)
+}
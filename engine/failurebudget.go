@@ -0,0 +1,48 @@
+package engine
+
+import "fmt"
+
+// validateFailureBudget checks failure budget is not negative and percent is at most 100
+func (o *RolloutOptions) validateFailureBudget() error {
+	if o.MaxFailedTargets < 0 || o.MaxFailedPercent < 0 || o.MaxFailedPercent > 100 {
+		return fmt.Errorf("%w: maxfailedtargets must not be negative and maxfailedpercent must be between 0 and 100", ErrInvalidFailureBudget)
+	}
+	return nil
+}
+
+// hasFailureBudget returns true if MaxFailedTargets or MaxFailedPercent is set
+func (o *RolloutOptions) hasFailureBudget() bool {
+	return o.MaxFailedTargets > 0 || o.MaxFailedPercent > 0
+}
+
+// failureBudget returns failed targets tolerated before rollout aborts, smaller of configured limits
+func (o *RolloutOptions) failureBudget(totalTargets int) int {
+	budget := -1
+	if o.MaxFailedTargets > 0 {
+		budget = o.MaxFailedTargets
+	}
+	if o.MaxFailedPercent > 0 {
+		percentBudget := o.MaxFailedPercent * totalTargets / 100
+		if budget < 0 || percentBudget < budget {
+			budget = percentBudget
+		}
+	}
+	return budget
+}
+
+// failureBudgetExceeded returns true once failed targets exceed failure budget,
+// failure threshold derived from SuccessPercent still applies independently
+func (r *Rollout) failureBudgetExceeded(state *rolloutInfo) bool {
+	options := r.options()
+	if !options.hasFailureBudget() {
+		return false
+	}
+
+	budget := options.failureBudget(len(state.totalTargets))
+	if len(state.failedTargets) <= budget {
+		return false
+	}
+
+	r.logger.Error().Int("FailedTargets", len(state.failedTargets)).Int("FailureBudget", budget).Msg("Failure budget exceeded")
+	return true
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureBudget(t *testing.T) {
+	e, err := createEntity("TestFailureBudget", getLogger())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	var clientTargets []*ClientState
+	for i := 0; i < 10; i++ {
+		clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	require.NoError(t, e.updateEntityTargets(clientTargets))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		successPercent   int
+		maxFailedTargets int
+		maxFailedPercent int
+		failed           int
+		aborted          bool
+	}{
+		// SuccessPercent 50 tolerates 4 failures, budget is smaller
+		{"within budget", 50, 2, 0, 2, false},
+		{"budget exceeded", 50, 2, 0, 3, true},
+		{"percent budget exceeded", 50, 0, 10, 2, true},
+		{"smaller budget applies", 50, 5, 10, 2, true},
+		// SuccessPercent 90 aborts on first failure even with a larger budget
+		{"success percent threshold", 90, 5, 0, 1, true},
+		{"no budget", 50, 0, 0, 3, false},
+	}
+	for _, test := range tests {
+		rollout.State.RollingVersion = "v2"
+		rollout.State.LastKnownGoodVersion = "v1"
+		rollout.State.LastKnownBadVersion = ""
+		rollout.State.Options.SuccessPercent = test.successPercent
+		rollout.State.Options.MaxFailedTargets = test.maxFailedTargets
+		rollout.State.Options.MaxFailedPercent = test.maxFailedPercent
+
+		state := createRolloutInfo(targets)
+		state.failedTargets = targets[:test.failed]
+		require.NoError(t, rollout.updateLastKnownVersions(state), test.name)
+		assert.Equal(t, test.aborted, rollout.State.LastKnownBadVersion == "v2", test.name)
+	}
+
+	assert.ErrorIs(t, (&RolloutOptions{MaxFailedPercent: 101}).validateFailureBudget(), ErrInvalidFailureBudget)
+	assert.ErrorIs(t, (&RolloutOptions{MaxFailedTargets: -1}).validateFailureBudget(), ErrInvalidFailureBudget)
+}
+
+func TestFailureBudgetAbortsWithoutDurationTimeout(t *testing.T) {
+	const testName = "TestFailureBudgetAbortsWithoutDurationTimeout"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*ClientState
+	for i := 0; i < 10; i++ {
+		clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{
+		BatchPercent:        100,
+		SuccessPercent:      50,
+		SuccessTimeoutSecs:  3600,
+		DurationTimeoutSecs: 3600,
+		MaxFailedTargets:    1,
+	}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+
+	// first orchestrate promotes v2 to rolling version, second assigns it
+	for i := 0; i < 2; i++ {
+		clientTargets, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 10, countVersion(clientTargets, "v2"))
+
+	// two targets report errors, long before DurationTimeoutSecs
+	markTargetVersionBad(clientTargets[:2], "v2")
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	rolloutState, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rolloutState.LastKnownBadVersion)
+}
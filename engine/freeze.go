@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	freezePrefix = "freeze:"
+	// globalFreezeKey freezes every namespace
+	globalFreezeKey = freezePrefix + "global"
+)
+
+// FreezeState is change freeze, while frozen new target versions do not start rolling,
+// status reporting and rolling back to LKG continue
+type FreezeState struct {
+	Frozen bool   `json:"frozen"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func namespaceFreezeKey(namespace string) string {
+	return freezePrefix + "namespace/" + namespace
+}
+
+// findFreeze returns unfrozen state if freeze was never set
+func findFreeze(dbStore store.Store, key string) (*FreezeState, error) {
+	freeze := &FreezeState{}
+	err := dbStore.LoadJSON(key, freeze)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+	return freeze, nil
+}
+
+// activeFreeze returns global or namespace freeze in effect for entity, nil if none
+func (e *Entity) activeFreeze() (*FreezeState, error) {
+	for _, key := range []string{globalFreezeKey, namespaceFreezeKey(e.Namespace)} {
+		freeze, err := findFreeze(e.store, key)
+		if err != nil {
+			return nil, err
+		}
+		if freeze.Frozen {
+			return freeze, nil
+		}
+	}
+	return nil, nil
+}
+
+// promotionHeld returns true if pending target version must not start rolling yet, either its scheduled
+// start has not passed, a change freeze is in effect or entities it depends on did not reach it
+func (r *Rollout) promotionHeld() (bool, error) {
+	return r.versionHeld(r.State.TargetVersion)
+}
+
+// versionHeld returns true if version must not be rolled to more targets yet, target version or a version of split
+func (r *Rollout) versionHeld(version string) (bool, error) {
+	if !r.scheduleAllows() {
+		r.logger.Info().Str("Version", version).Msg("Holding version until scheduled start")
+		return true, nil
+	}
+
+	if r.entity == nil {
+		return false, nil
+	}
+	freeze, err := r.entity.activeFreeze()
+	if err != nil {
+		return false, err
+	}
+	if freeze != nil {
+		r.logger.Info().Str("Version", version).Str("Reason", freeze.Reason).Msg("Holding version during change freeze")
+		return true, nil
+	}
+	blockedBy, err := r.entity.blockingDependencies(version)
+	if err != nil {
+		return false, err
+	}
+	if len(blockedBy) > 0 {
+		r.logger.Info().Str("Version", version).Strs("BlockedBy", blockedBy).Msg("Holding version until dependencies reach it")
+		return true, nil
+	}
+	return false, nil
+}
+
+func (n *Namespace) setFreeze(freeze *FreezeState) error {
+	n.logger.Info().Bool("Frozen", freeze.Frozen).Str("Reason", freeze.Reason).Msg("Set namespace change freeze")
+	return n.store.SaveJSON(namespaceFreezeKey(n.Name), freeze)
+}
+
+func (n *Namespace) getFreeze() (*FreezeState, error) {
+	return findFreeze(n.store, namespaceFreezeKey(n.Name))
+}
+
+// SetGlobalFreeze sets change freeze of every namespace
+func (e *Engine) SetGlobalFreeze(freeze *FreezeState) error {
+	e.logger.Info().Bool("Frozen", freeze.Frozen).Str("Reason", freeze.Reason).Msg("Set global change freeze")
+	return e.store.SaveJSON(globalFreezeKey, freeze)
+}
+
+// GetGlobalFreeze returns change freeze of every namespace
+func (e *Engine) GetGlobalFreeze() (*FreezeState, error) {
+	return findFreeze(e.store, globalFreezeKey)
+}
+
+// SetNamespaceFreeze sets change freeze of namespace
+func (e *Engine) SetNamespaceFreeze(namespaceName string, freeze *FreezeState) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setFreeze(freeze)
+}
+
+// GetNamespaceFreeze returns change freeze of namespace, global freeze is returned by GetGlobalFreeze
+func (e *Engine) GetNamespaceFreeze(namespaceName string) (*FreezeState, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getFreeze()
+}
@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalFreeze(t *testing.T) {
+	const testName = "TestGlobalFreeze"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*ClientState{{Name: "clientTarget0", Version: "v1"}, {Name: "clientTarget1", Version: "v1"}}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.SetGlobalFreeze(&FreezeState{Frozen: true, Reason: "holidays"}))
+	freeze, err := engine.GetGlobalFreeze()
+	require.NoError(t, err)
+	assert.Equal(t, "holidays", freeze.Reason)
+
+	// target version is accepted but does not start rolling
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+	var assigned []*ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, countVersion(assigned, "v2"))
+
+	require.NoError(t, engine.SetGlobalFreeze(&FreezeState{}))
+	for i := 0; i < 2; i++ {
+		assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, countVersion(assigned, "v2"))
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const groupRulesPrefix = "grouprules:"
+
+// GroupRule assigns targets reported without group to Group,
+// all matchers that are set must match
+type GroupRule struct {
+	Group string `json:"group,omitempty"`
+	// NamePattern is regular expression matched against target name
+	NamePattern string `json:"namepattern,omitempty"`
+	// Label matched against comma separated target tags, such as region=us-west
+	Label string `json:"label,omitempty"`
+}
+
+// GroupRules evaluated in order, first matching rule assigns the group
+type GroupRules struct {
+	Rules []GroupRule `json:"rules,omitempty"`
+}
+
+func namespaceGroupRulesKey(namespace string) string {
+	return groupRulesPrefix + namespace
+}
+
+func (r *GroupRules) Validate() error {
+	for i, rule := range r.Rules {
+		if rule.Group == "" {
+			return fmt.Errorf("%w: rule %d has empty group", ErrInvalidGroupRule, i)
+		}
+		if rule.NamePattern == "" && rule.Label == "" {
+			return fmt.Errorf("%w: rule %d requires namepattern or label", ErrInvalidGroupRule, i)
+		}
+		if _, err := regexp.Compile(rule.NamePattern); err != nil {
+			return fmt.Errorf("%w: rule %d %s", ErrInvalidGroupRule, i, err)
+		}
+	}
+	return nil
+}
+
+func hasLabel(tags, label string) bool {
+	for _, tag := range strings.Split(tags, ",") {
+		if strings.TrimSpace(tag) == label {
+			return true
+		}
+	}
+	return false
+}
+
+// assign returns group of first matching rule, empty if none match
+func (r *GroupRules) assign(clientTarget *ClientState) string {
+	for _, rule := range r.Rules {
+		if rule.Label != "" && !hasLabel(clientTarget.Tags, rule.Label) {
+			continue
+		}
+		if rule.NamePattern != "" {
+			matched, err := regexp.MatchString(rule.NamePattern, clientTarget.Name)
+			if err != nil || !matched {
+				continue
+			}
+		}
+		return rule.Group
+	}
+	return ""
+}
+
+// findGroupRules returns empty rules if not configured
+func findGroupRules(dbStore store.Store, namespace string) (*GroupRules, error) {
+	rules := &GroupRules{}
+	err := dbStore.LoadJSON(namespaceGroupRulesKey(namespace), rules)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// setGroupRules saves group assignment rules for the namespace
+func (n *Namespace) setGroupRules(rules *GroupRules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+
+	n.logger.Info().Int("Rules", len(rules.Rules)).Msg("Setting group assignment rules")
+	return n.store.SaveJSON(namespaceGroupRulesKey(n.Name), rules)
+}
+
+// getGroupRules returns group assignment rules for the namespace
+func (n *Namespace) getGroupRules() (*GroupRules, error) {
+	return findGroupRules(n.store, n.Name)
+}
+
+// assignGroups sets group of targets reported without group using namespace rules
+func (e *Entity) assignGroups(targets []*ClientState) error {
+	rules, err := findGroupRules(e.store, e.Namespace)
+	if err != nil || len(rules.Rules) <= 0 {
+		return err
+	}
+
+	for _, clientTarget := range targets {
+		if clientTarget.Group != "" {
+			continue
+		}
+		if group := rules.assign(clientTarget); group != "" {
+			e.logger.Debug().Str("Name", clientTarget.Name).Str("Group", group).Msg("Assigning target group")
+			clientTarget.Group = group
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupRulesAssign(t *testing.T) {
+	rules := &GroupRules{Rules: []GroupRule{
+		{Group: "canary", NamePattern: "^canary-"},
+		{Group: "west", Label: "region=us-west"},
+		{Group: "west-web", NamePattern: "^web", Label: "region=us-west"},
+	}}
+	require.NoError(t, rules.Validate())
+
+	assert.Equal(t, "canary", rules.assign(&ClientState{Name: "canary-1", Tags: "region=us-west"}))
+	assert.Equal(t, "west", rules.assign(&ClientState{Name: "web-1", Tags: "tier=web, region=us-west"}))
+	assert.Empty(t, rules.assign(&ClientState{Name: "web-2", Tags: "region=us-east"}))
+
+	assert.ErrorIs(t, (&GroupRules{Rules: []GroupRule{{NamePattern: "web"}}}).Validate(), ErrInvalidGroupRule)
+	assert.ErrorIs(t, (&GroupRules{Rules: []GroupRule{{Group: "web"}}}).Validate(), ErrInvalidGroupRule)
+	assert.ErrorIs(t, (&GroupRules{Rules: []GroupRule{{Group: "web", NamePattern: "("}}}).Validate(), ErrInvalidGroupRule)
+}
+
+func TestGroupRulesOrchestrate(t *testing.T) {
+	const testName = "TestGroupRulesOrchestrate"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	rules := &GroupRules{Rules: []GroupRule{{Group: "canary", NamePattern: "^canary-"}}}
+	require.NoError(t, engine.SetGroupRules(testName, rules))
+
+	savedRules, err := engine.GetGroupRules(testName)
+	require.NoError(t, err)
+	assert.Equal(t, rules, savedRules)
+
+	clientTargets := []*ClientState{
+		{Name: "canary-1", Version: "v1"},
+		{Name: "web-1", Version: "v1"},
+		{Name: "web-2", Group: "explicit", Version: "v1"},
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	canaryTargets, err := engine.GetClientGroupState(testName, testName, "canary")
+	require.NoError(t, err)
+	require.Len(t, canaryTargets, 1)
+	assert.Equal(t, "canary-1", canaryTargets[0].Name)
+
+	explicitTargets, err := engine.GetClientGroupState(testName, testName, "explicit")
+	require.NoError(t, err)
+	require.Len(t, explicitTargets, 1)
+	assert.Equal(t, "web-2", explicitTargets[0].Name)
+
+	assert.ErrorIs(t, engine.SetGroupRules(testName, &GroupRules{Rules: []GroupRule{{Group: "canary"}}}), ErrInvalidGroupRule)
+}
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// heartbeat records liveness of the target without a full status report
+func (e *Entity) heartbeat(group, name string) (*ClientState, error) {
+	entityTarget := &EntityTarget{}
+	if err := e.store.LoadJSON(e.entityTargetKey(group, name), entityTarget); err != nil {
+		return nil, err
+	}
+
+	entityTarget.State.LastSeenTimestamp = nowUTC()
+
+	// heartbeats are frequent, save without publishing target updates
+	if err := e.store.SaveJSON(e.entityTargetKey(group, name), entityTarget); err != nil {
+		return nil, err
+	}
+
+	return returnClientTarget(entityTarget), nil
+}
+
+// heartbeat records liveness of the target for the entity
+func (n *Namespace) heartbeat(entityName, group, name string) (*ClientState, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.heartbeat(group, name)
+}
+
+// heartbeatMissed returns true when target has not been seen within heartbeat timeout
+func (r *Rollout) heartbeatMissed(entityTarget *EntityTarget) bool {
+	if r.State.Options.HeartbeatTimeoutSecs <= 0 || entityTarget.State.LastSeenTimestamp.IsZero() {
+		return false
+	}
+
+	return int(timeSince(entityTarget.State.LastSeenTimestamp).Seconds()) > r.State.Options.HeartbeatTimeoutSecs
+}
+
+// heartbeatMessage describes missed heartbeat failure
+func heartbeatMessage(entityTarget *EntityTarget) string {
+	return fmt.Sprintf("failed monitoring, no heartbeat since %s", entityTarget.State.LastSeenTimestamp)
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissedHeartbeatFailsMonitoring(t *testing.T) {
+	e, clientTargets, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	rollout.State.RollingVersion = "v1"
+	rollout.State.LastKnownGoodVersion = "v1"
+	rollout.State.Options.SuccessTimeoutSecs = 900
+	rollout.State.Options.DurationTimeoutSecs = 900
+	rollout.State.Options.HeartbeatTimeoutSecs = 30
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	// first target has not been seen for longer than heartbeat timeout
+	for _, target := range targets {
+		if target.Name == clientTargets[0].Name {
+			target.State.LastSeenTimestamp = time.Now().UTC().Add(-time.Minute)
+		}
+	}
+
+	state := createRolloutInfo(targets)
+	require.NoError(t, rollout.determineCurrentState(state))
+	require.NoError(t, rollout.monitorTargets(state))
+
+	require.Len(t, state.failedTargets, 1)
+	assert.Equal(t, clientTargets[0].Name, state.failedTargets[0].Name)
+	assert.True(t, state.failedTargets[0].State.TargetVersion.LastMessage.IsError)
+	assert.Equal(t, 1, state.failedTargets[0].State.ConsecutiveFailures)
+
+	_, err = e.heartbeat("", clientTargets[0].Name)
+	require.NoError(t, err)
+
+	targets, err = e.getEntityTargets()
+	require.NoError(t, err)
+	for _, target := range targets {
+		assert.False(t, rollout.heartbeatMissed(target))
+	}
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	rolloutHistoryPrefix = "rollouthistory:"
+)
+
+const (
+	// RolloutInProgress rollout attempt is still rolling out
+	RolloutInProgress = "InProgress"
+	// RolloutSucceeded rollout attempt reached success threshold, version is now lkg
+	RolloutSucceeded = "Succeeded"
+	// RolloutFailed rollout attempt crossed failure threshold, version is now lkb
+	RolloutFailed = "Failed"
+	// RolloutSuperseded rollout attempt was replaced by a newer rolling version before completing
+	RolloutSuperseded = "Superseded"
+)
+
+// RolloutHistory is a record of a single rollout attempt of a version
+type RolloutHistory struct {
+	ID             string    `json:"id,omitempty"`
+	TargetVersion  string    `json:"targetversion,omitempty"`
+	StartTimestamp time.Time `json:"starttimestamp,omitempty"`
+	EndTimestamp   time.Time `json:"endtimestamp,omitempty"`
+	Outcome        string    `json:"outcome,omitempty"`
+	SuccessTargets int       `json:"successtargets,omitempty"`
+	FailedTargets  int       `json:"failedtargets,omitempty"`
+	TotalTargets   int       `json:"totaltargets,omitempty"`
+	RolledBack     bool      `json:"rolledback,omitempty"`
+	// Change ticket and note of the version
+	Ticket string `json:"ticket,omitempty"`
+	Note   string `json:"note,omitempty"`
+	// Expedited is true if version was rolled out using emergency profile
+	Expedited bool `json:"expedited,omitempty"`
+	// Approvals granted or denied for batches of the rollout
+	Approvals []*ApprovalDecision `json:"approvals,omitempty"`
+}
+
+// ApprovalDecision records who approved or denied a batch
+type ApprovalDecision struct {
+	Version   string    `json:"version,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Targets   []string  `json:"targets,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// recordApproval adds approval decision to history of current rollout
+func (r *Rollout) recordApproval(decision *ApprovalDecision) error {
+	if r.State.HistoryID == "" {
+		return nil
+	}
+	history, err := r.entity.findRolloutHistory(r.State.HistoryID)
+	if err != nil {
+		return err
+	}
+	history.Approvals = append(history.Approvals, decision)
+	return r.entity.saveRolloutHistory(history)
+}
+
+func (e *Entity) rolloutHistoryPrefix() string {
+	return fmt.Sprintf("%s%s/%s/", rolloutHistoryPrefix, e.Namespace, e.Name)
+}
+
+func (e *Entity) rolloutHistoryKey(id string) string {
+	return e.rolloutHistoryPrefix() + id
+}
+
+func (e *Entity) saveRolloutHistory(history *RolloutHistory) error {
+	return e.store.SaveJSON(e.rolloutHistoryKey(history.ID), history)
+}
+
+func (e *Entity) findRolloutHistory(id string) (*RolloutHistory, error) {
+	history := &RolloutHistory{}
+	if err := e.store.LoadJSON(e.rolloutHistoryKey(id), history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// getRolloutHistory returns rollout history newest first, skipping offset records and returning at most limit records
+func (e *Entity) getRolloutHistory(offset, limit int) ([]*RolloutHistory, error) {
+	var histories []*RolloutHistory
+	historyItr := func(key any, value any) error {
+		history := &RolloutHistory{}
+		if err := json.Unmarshal([]byte(value.(string)), history); err != nil {
+			return err
+		}
+		histories = append(histories, history)
+		return nil
+	}
+	if err := e.store.LoadValues(e.rolloutHistoryPrefix(), historyItr); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i].StartTimestamp.After(histories[j].StartTimestamp)
+	})
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(histories) {
+		return []*RolloutHistory{}, nil
+	}
+	histories = histories[offset:]
+	if limit > 0 && limit < len(histories) {
+		histories = histories[:limit]
+	}
+
+	return histories, nil
+}
+
+// recordHistory compares version info before and after orchestration,
+// starts a new history record when rolling version changes and completes it when lkg or lkb is updated
+func (r *Rollout) recordHistory(previous RolloutVersionInfo, state *rolloutInfo) error {
+	nowTime := nowUTC()
+
+	var history *RolloutHistory
+	if r.State.HistoryID != "" {
+		var err error
+		history, err = r.entity.findRolloutHistory(r.State.HistoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.State.RollingVersion != previous.RollingVersion || history == nil {
+		if history != nil && history.Outcome == RolloutInProgress {
+			history.Outcome = RolloutSuperseded
+			history.EndTimestamp = nowTime
+			if err := r.entity.saveRolloutHistory(history); err != nil {
+				return err
+			}
+		}
+		history = &RolloutHistory{
+			ID:             fmt.Sprintf("%020d", nowTime.UnixNano()),
+			TargetVersion:  r.State.RollingVersion,
+			StartTimestamp: nowTime,
+			Outcome:        RolloutInProgress,
+			Ticket:         r.State.RollingChange.Ticket,
+			Note:           r.State.RollingChange.Note,
+			Expedited:      r.State.RollingChange.Expedited,
+		}
+		r.State.HistoryID = history.ID
+		r.logger.Info().Str("HistoryID", history.ID).Str("Version", history.TargetVersion).Bool("Expedited", history.Expedited).Msg("Recording new rollout history")
+	}
+
+	if history.Outcome != RolloutInProgress {
+		return nil
+	}
+
+	history.SuccessTargets = len(state.successTargets)
+	history.FailedTargets = len(state.failedTargets)
+	history.TotalTargets = len(state.totalTargets)
+
+	switch history.TargetVersion {
+	case r.State.LastKnownBadVersion:
+		history.Outcome = RolloutFailed
+		history.EndTimestamp = nowTime
+		history.RolledBack = r.State.LastKnownGoodVersion != ""
+	case r.State.LastKnownGoodVersion:
+		history.Outcome = RolloutSucceeded
+		history.EndTimestamp = nowTime
+	}
+
+	return r.entity.saveRolloutHistory(history)
+}
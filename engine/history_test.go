@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutHistory(t *testing.T) {
+	const numTargets = 3
+	const namespaceName = "TestRolloutHistory"
+	const entityName = "NewEntity"
+
+	engine, err := setupTestEngine(namespaceName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, namespaceName)
+
+	clientTargets, err := setupNamespace(engine, namespaceName, entityName, numTargets)
+	require.NoError(t, err)
+
+	testRolloutOrchestrate(t, engine, namespaceName, entityName, clientTargets)
+
+	history, err := engine.GetRolloutHistory(namespaceName, entityName, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	assert.Equal(t, "v2", history[0].TargetVersion)
+	assert.Equal(t, RolloutSucceeded, history[0].Outcome)
+	assert.Equal(t, numTargets, history[0].TotalTargets)
+	assert.False(t, history[0].RolledBack)
+	assert.False(t, history[0].EndTimestamp.IsZero())
+
+	assert.Equal(t, "v1", history[1].TargetVersion)
+	assert.Equal(t, RolloutSucceeded, history[1].Outcome)
+
+	history, err = engine.GetRolloutHistory(namespaceName, entityName, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "v1", history[0].TargetVersion)
+
+	history, err = engine.GetRolloutHistory(namespaceName, entityName, 5, 1)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestRollbackHistory(t *testing.T) {
+	const numTargets = 3
+	const namespaceName = "TestRollbackHistory"
+	const entityName = "NewEntity"
+
+	engine, err := setupTestEngine(namespaceName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, namespaceName)
+
+	clientTargets, err := setupNamespace(engine, namespaceName, entityName, numTargets)
+	require.NoError(t, err)
+
+	testRollbackOrchestrate(t, engine, namespaceName, entityName, clientTargets)
+
+	history, err := engine.GetRolloutHistory(namespaceName, entityName, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+
+	assert.Equal(t, "v2", history[0].TargetVersion)
+	assert.Equal(t, RolloutFailed, history[0].Outcome)
+	assert.True(t, history[0].RolledBack)
+	assert.Positive(t, history[0].FailedTargets)
+}
+
+func TestRolloutHistoryChangeTicket(t *testing.T) {
+	e, clientTargets, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+	e.events = NewEventBus()
+
+	var received []Event
+	unsubscribe := e.events.Subscribe(func(event Event) {
+		if event.Entity == e.Name && event.Type == EventRolloutStarted {
+			received = append(received, event)
+		}
+	})
+	defer unsubscribe()
+
+	change := ChangeInfo{Ticket: "CHG-1234", Note: "enable feature"}
+	require.NoError(t, e.setTargetVersion(EntityTargetVersion{Version: "v2", ChangeInfo: change}, false))
+
+	_, err = e.orchestrate(context.Background(), clientTargets)
+	require.NoError(t, err)
+
+	rolloutState, err := e.getRolloutInfo()
+	require.NoError(t, err)
+	assert.Equal(t, change, rolloutState.TargetChange)
+	assert.Equal(t, change, rolloutState.RollingChange)
+
+	history, err := e.getRolloutHistory(0, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	assert.Equal(t, "v2", history[0].TargetVersion)
+	assert.Equal(t, change.Ticket, history[0].Ticket)
+	assert.Equal(t, change.Note, history[0].Note)
+
+	require.Len(t, received, 1)
+	assert.Equal(t, change.Ticket, received[0].Ticket)
+	assert.Equal(t, change.Note, received[0].Note)
+}
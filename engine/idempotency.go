@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	idempotencyPrefix = "idempotency:"
+	// idempotencyTTL is how long responses are replayed for retries with the same idempotency key
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyCleanupInterval is how often expired responses are deleted
+	idempotencyCleanupInterval = time.Hour
+	// MaxIdempotencyKey is length of longest idempotency key accepted
+	MaxIdempotencyKey = 255
+)
+
+// IdempotentResponse is response of a request with idempotency key, replayed for retries until it expires
+type IdempotentResponse struct {
+	// RequestHash of method, path and body of original request
+	RequestHash string    `json:"requesthash,omitempty"`
+	StatusCode  int       `json:"statuscode,omitempty"`
+	ContentType string    `json:"contenttype,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	ExpiresAt   time.Time `json:"expiresat,omitempty"`
+}
+
+func idempotencyKey(namespace, entity, key string) string {
+	return fmt.Sprintf("%s%s/%s/%s", idempotencyPrefix, namespace, entity, key)
+}
+
+// LockIdempotencyKey serializes requests with the same idempotency key, so concurrent retries replay
+// response of the first request instead of running it again
+func (e *Engine) LockIdempotencyKey(namespace, entity, key string) func() {
+	return e.locks.acquire(idempotencyKey(namespace, entity, key))
+}
+
+// FindIdempotentResponse returns response recorded for idempotency key, nil if there is none or it expired
+func (e *Engine) FindIdempotentResponse(namespace, entity, key string) (*IdempotentResponse, error) {
+	resp := &IdempotentResponse{}
+	if err := e.store.LoadJSON(idempotencyKey(namespace, entity, key), resp); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !nowUTC().Before(resp.ExpiresAt) {
+		return nil, nil
+	}
+	return resp, nil
+}
+
+// SaveIdempotentResponse records response for idempotency key, replayed for retries until idempotencyTTL
+func (e *Engine) SaveIdempotentResponse(namespace, entity, key string, resp *IdempotentResponse) error {
+	resp.ExpiresAt = nowUTC().Add(idempotencyTTL)
+	return e.store.SaveJSON(idempotencyKey(namespace, entity, key), resp)
+}
+
+// deleteExpiredIdempotentResponses deletes responses no longer replayed
+func (e *Engine) deleteExpiredIdempotentResponses() error {
+	now := nowUTC()
+	var expired []string
+	err := e.store.LoadValues(idempotencyPrefix, func(key, value any) error {
+		resp := &IdempotentResponse{}
+		if err := json.Unmarshal([]byte(value.(string)), resp); err != nil {
+			return err
+		}
+		if !now.Before(resp.ExpiresAt) {
+			expired = append(expired, key.(string))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		if err := e.store.Delete(key); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// runIdempotencyCleanup periodically deletes expired idempotent responses until ctx is done
+func (e *Engine) runIdempotencyCleanup(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.deleteExpiredIdempotentResponses(); err != nil {
+				e.logger.Error().Err(err).Msg("failed to delete expired idempotent responses")
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteExpiredIdempotentResponses(t *testing.T) {
+	const testName = "TestDeleteExpiredIdempotentResponses"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	clock := &offsetClock{}
+	DefaultClock = clock
+	defer func() { DefaultClock = systemClock{} }()
+
+	require.NoError(t, engine.SaveIdempotentResponse(testName, testName, "retry-1", &IdempotentResponse{StatusCode: 200}))
+	resp, err := engine.FindIdempotentResponse(testName, testName, "retry-1")
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	// expired responses are no longer replayed and are deleted
+	clock.offset = idempotencyTTL
+	resp, err = engine.FindIdempotentResponse(testName, testName, "retry-1")
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+
+	clock.offset = 2 * idempotencyTTL
+	require.NoError(t, engine.deleteExpiredIdempotentResponses())
+	count, err := engine.store.Count(idempotencyPrefix)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	journalPrefix = "journal:"
+)
+
+// JournalTarget is a target part of an in progress batch
+type JournalTarget struct {
+	Name            string `json:"name,omitempty"`
+	Group           string `json:"group,omitempty"`
+	PreviousVersion string `json:"previousversion,omitempty"`
+}
+
+// Journal records an in progress batch, removed once rollout state is saved
+type Journal struct {
+	Namespace string          `json:"namespace,omitempty"`
+	Entity    string          `json:"entity,omitempty"`
+	Version   string          `json:"version,omitempty"`
+	Targets   []JournalTarget `json:"targets,omitempty"`
+	Timestamp time.Time       `json:"timestamp,omitempty"`
+}
+
+func (e *Entity) journalKey() string {
+	return fmt.Sprintf("%s%s/%s", journalPrefix, e.Namespace, e.Name)
+}
+
+// beginBatch journals targets which are about to be assigned version
+func (e *Entity) beginBatch(version string, entityTargets EntityTargets) error {
+	journal := &Journal{
+		Namespace: e.Namespace,
+		Entity:    e.Name,
+		Version:   version,
+		Timestamp: nowUTC(),
+	}
+	for _, entityTarget := range entityTargets {
+		journal.Targets = append(journal.Targets, JournalTarget{
+			Name:            entityTarget.Name,
+			Group:           entityTarget.Group,
+			PreviousVersion: entityTarget.State.TargetVersion.Version,
+		})
+	}
+	return e.store.SaveJSON(e.journalKey(), journal)
+}
+
+// endBatch removes journal within txn persisting rollout state, so rollout state and journal
+// are never out of sync
+func (e *Entity) endBatch(txn store.StoreTxn) error {
+	return txn.Delete(e.journalKey())
+}
+
+// recoverBatch reconciles a partially applied batch after a crash
+// if persisted rollout still expects journaled version, batch is completed
+// otherwise targets assigned as part of batch are reverted to previous version
+func (e *Entity) recoverBatch() error {
+	journal := &Journal{}
+	err := e.store.LoadJSON(e.journalKey(), journal)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	expectedVersion := rollout.State.RollingVersion
+	if expectedVersion == rollout.State.LastKnownBadVersion {
+		expectedVersion = rollout.State.LastKnownGoodVersion
+	}
+	complete := expectedVersion == journal.Version
+
+	e.logger.Warn().
+		Str("Version", journal.Version).
+		Str("ExpectedVersion", expectedVersion).
+		Int("Targets", len(journal.Targets)).
+		Bool("Complete", complete).
+		Msg("Recovering partially applied batch")
+
+	for _, journalTarget := range journal.Targets {
+		entityTarget := &EntityTarget{}
+		err := e.store.LoadJSON(e.entityTargetKey(journalTarget.Group, journalTarget.Name), entityTarget)
+		if err == store.ErrKeyNotFound {
+			// target was removed since, nothing to reconcile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		version := journalTarget.PreviousVersion
+		message := fmt.Sprintf("Recovered batch, reverting to version %s", version)
+		if complete {
+			version = journal.Version
+			message = fmt.Sprintf("Recovered batch, assigning version %s", version)
+		}
+
+		if entityTarget.State.TargetVersion.Version == version {
+			continue
+		}
+
+		entityTarget.State.TargetVersion.Version = version
+		entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+		entityTarget.State.TargetVersion.LastMessage.Success(message)
+		entityTarget.addEvent(TargetEventVersionAssigned, version, message)
+		if err := e.saveEntityTarget(entityTarget); err != nil {
+			return err
+		}
+	}
+
+	return e.store.Txn(e.endBatch)
+}
+
+// recoverJournal reconciles all partially applied batches on startup, journals of deleted namespaces
+// or entities are removed
+func (e *Engine) recoverJournal() error {
+	journals := make(map[string]*Journal)
+	journalItr := func(key any, value any) error {
+		journal := &Journal{}
+		if err := json.Unmarshal([]byte(value.(string)), journal); err != nil {
+			return err
+		}
+		journals[key.(string)] = journal
+		return nil
+	}
+	if err := e.store.LoadValues(journalPrefix, journalItr); err != nil {
+		return err
+	}
+
+	for key, journal := range journals {
+		namespace, err := e.findNamespace(journal.Namespace)
+		var entity *Entity
+		if err == nil {
+			entity, err = namespace.findEntity(journal.Entity)
+		}
+		if errors.Is(err, ErrNamespaceNotFound) || errors.Is(err, ErrEntityNotFound) {
+			e.logger.Warn().Err(err).Str("Namespace", journal.Namespace).Str("Entity", journal.Entity).Msg("Removing orphaned journal")
+			if err := e.store.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := entity.recoverBatch(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
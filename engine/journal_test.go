@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverBatch(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+	rollout.State.RollingVersion = "v2"
+	require.NoError(t, e.store.SaveJSON(e.rolloutKey(), rollout))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	// crash after assigning only first target of the batch
+	require.NoError(t, e.beginBatch("v2", targets[:4]))
+	targets[0].State.TargetVersion.Version = "v2"
+	require.NoError(t, e.saveEntityTarget(targets[0]))
+
+	require.NoError(t, e.recoverBatch())
+
+	targets, err = e.getEntityTargets()
+	require.NoError(t, err)
+	assigned := 0
+	for _, entityTarget := range targets {
+		if entityTarget.State.TargetVersion.Version == "v2" {
+			assigned++
+		}
+	}
+	assert.Equal(t, 4, assigned)
+
+	// rolling version was marked bad before crash, batch gets reverted
+	rollout.State.RollingVersion = "v3"
+	rollout.State.LastKnownBadVersion = "v3"
+	require.NoError(t, e.store.SaveJSON(e.rolloutKey(), rollout))
+
+	require.NoError(t, e.beginBatch("v3", targets[:2]))
+	targets[0].State.TargetVersion.Version = "v3"
+	require.NoError(t, e.saveEntityTarget(targets[0]))
+
+	require.NoError(t, e.recoverBatch())
+
+	targets, err = e.getEntityTargets()
+	require.NoError(t, err)
+	for _, entityTarget := range targets {
+		assert.NotEqual(t, "v3", entityTarget.State.TargetVersion.Version)
+	}
+
+	// journal is removed after recovery
+	require.NoError(t, e.recoverBatch())
+}
+
+func TestRecoverOrphanedJournal(t *testing.T) {
+	const testName = "TestRecoverOrphanedJournal"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = setupNamespace(engine, testName, testName, 4)
+	require.NoError(t, err)
+
+	// journals left behind by deleted namespace and entity
+	orphaned := map[string]*Journal{
+		journalPrefix + "missing/" + testName: {Namespace: "missing", Entity: testName, Version: "v2"},
+		journalPrefix + testName + "/missing": {Namespace: testName, Entity: "missing", Version: "v2"},
+	}
+	for key, journal := range orphaned {
+		require.NoError(t, engine.store.SaveJSON(key, journal))
+	}
+
+	require.NoError(t, engine.recoverJournal())
+	for key := range orphaned {
+		assert.ErrorIs(t, engine.store.LoadJSON(key, &Journal{}), store.ErrKeyNotFound)
+	}
+}
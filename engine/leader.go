@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/metrics"
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	// leaderLockName is store lock held by leader replica
+	leaderLockName = "leader"
+	// leaderCheckInterval is how often leader checks store is reachable, and how long replicas wait
+	// before campaigning again after a store failure
+	leaderCheckInterval = 10 * time.Second
+)
+
+var leaderGauge = metrics.DefaultRegistry.NewGauge(
+	"orchestrator_leader",
+	"1 if replica is leader running background jobs, 0 otherwise",
+)
+
+// BackgroundJob runs on leader replica only, ctx is cancelled once leadership is lost or engine shuts down
+type BackgroundJob func(ctx context.Context)
+
+type backgroundJob struct {
+	name string
+	run  BackgroundJob
+}
+
+// leadership runs background jobs while replica is leader
+type leadership struct {
+	lock   sync.Mutex
+	jobs   []backgroundJob
+	leader bool
+	// cancelJobs cancels jobs started in current leadership term
+	cancelJobs context.CancelFunc
+	jobsCtx    context.Context
+	running    sync.WaitGroup
+	// stopCampaign stops leader election, campaignDone is closed once it stopped
+	stopCampaign context.CancelFunc
+	campaignDone chan struct{}
+}
+
+// startJob runs job until jobs context of current term is cancelled, caller holds lock
+func (l *leadership) startJob(job backgroundJob) {
+	l.running.Add(1)
+	go func(ctx context.Context) {
+		defer l.running.Done()
+		job.run(ctx)
+	}(l.jobsCtx)
+}
+
+// RunBackgroundJob registers job run by leader replica, job starts immediately if replica is leader,
+// every replica is leader if leader election is disabled
+func (e *Engine) RunBackgroundJob(name string, job BackgroundJob) {
+	e.leadership.lock.Lock()
+	defer e.leadership.lock.Unlock()
+
+	registered := backgroundJob{name: name, run: job}
+	e.leadership.jobs = append(e.leadership.jobs, registered)
+	if e.leadership.leader {
+		e.logger.Info().Str("Job", name).Msg("Starting background job")
+		e.leadership.startJob(registered)
+	}
+}
+
+// IsLeader returns true if replica runs background jobs
+func (e *Engine) IsLeader() bool {
+	e.leadership.lock.Lock()
+	defer e.leadership.lock.Unlock()
+	return e.leadership.leader
+}
+
+// becomeLeader starts background jobs
+func (e *Engine) becomeLeader() {
+	e.leadership.lock.Lock()
+	defer e.leadership.lock.Unlock()
+
+	e.logger.Info().Int("Jobs", len(e.leadership.jobs)).Msg("Became leader, starting background jobs")
+	e.leadership.leader = true
+	e.leadership.jobsCtx, e.leadership.cancelJobs = context.WithCancel(e.ctx)
+	for _, job := range e.leadership.jobs {
+		e.leadership.startJob(job)
+	}
+	leaderGauge.Set(1)
+}
+
+// stepDown cancels background jobs and waits for them to return
+func (e *Engine) stepDown() {
+	e.leadership.lock.Lock()
+	if !e.leadership.leader {
+		e.leadership.lock.Unlock()
+		return
+	}
+	e.logger.Info().Msg("Stepping down as leader, stopping background jobs")
+	e.leadership.leader = false
+	e.leadership.cancelJobs()
+	e.leadership.lock.Unlock()
+
+	e.leadership.running.Wait()
+	leaderGauge.Set(0)
+}
+
+// startLeaderElection campaigns for leader lock in store if leader election is enabled,
+// otherwise replica is leader right away
+func (e *Engine) startLeaderElection(enabled bool) {
+	if !enabled {
+		e.becomeLeader()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.leadership.stopCampaign = cancel
+	e.leadership.campaignDone = make(chan struct{})
+	go e.campaign(ctx)
+}
+
+// campaign waits for leader lock, leads until leader lock is lost or store becomes unreachable and
+// campaigns again
+func (e *Engine) campaign(ctx context.Context) {
+	defer close(e.leadership.campaignDone)
+
+	for {
+		lockCtx, unlock, err := e.store.Lock(ctx, leaderLockName)
+		if err == nil {
+			e.becomeLeader()
+			e.lead(ctx, lockCtx)
+			e.stepDown()
+			if err := unlock(); err != nil && !errors.Is(err, store.ErrStoreClosed) && !errors.Is(err, store.ErrLockLost) {
+				e.logger.Error().Err(err).Msg("failed to release leader lock")
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		e.logger.Error().Err(err).Msg("failed to campaign for leader lock")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderCheckInterval):
+		}
+	}
+}
+
+// lead returns once ctx is done, leader lock is lost or store is unreachable, which may mean it was lost
+func (e *Engine) lead(ctx, lockCtx context.Context) {
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-lockCtx.Done():
+			e.logger.Error().Err(context.Cause(lockCtx)).Msg("Leader lock lost")
+			return
+		case <-ticker.C:
+			if err := e.store.Ping(); err != nil {
+				e.logger.Error().Err(err).Msg("Store unreachable, leader lock may be lost")
+				return
+			}
+		}
+	}
+}
+
+// stopLeaderElection stops campaigning and background jobs, leader lock is released
+func (e *Engine) stopLeaderElection() {
+	if e.leadership.stopCampaign != nil {
+		e.leadership.stopCampaign()
+		<-e.leadership.campaignDone
+		e.leadership.stopCampaign = nil
+	}
+	e.stepDown()
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderElectionDisabled(t *testing.T) {
+	const testName = "TestLeaderElectionDisabled"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	assert.True(t, engine.IsLeader())
+
+	started := make(chan struct{})
+	engine.RunBackgroundJob("job", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+}
+
+func TestLeaderElection(t *testing.T) {
+	dbStore, err := store.NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer dbStore.Close()
+
+	// replicas sharing the store
+	var running atomic.Int32
+	replicas := make([]*Engine, 2)
+	for i := range replicas {
+		replicas[i] = &Engine{ctx: context.Background(), logger: getLogger(), store: dbStore}
+		replicas[i].RunBackgroundJob("job", func(ctx context.Context) {
+			running.Add(1)
+			<-ctx.Done()
+			running.Add(-1)
+		})
+		replicas[i].startLeaderElection(true)
+	}
+
+	leader := func() *Engine {
+		var leaders []*Engine
+		for _, replica := range replicas {
+			if replica.IsLeader() {
+				leaders = append(leaders, replica)
+			}
+		}
+		if len(leaders) != 1 {
+			return nil
+		}
+		return leaders[0]
+	}
+	require.Eventually(t, func() bool { return leader() != nil && running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// leader shuts down, other replica takes over
+	first := leader()
+	first.stopLeaderElection()
+	assert.False(t, first.IsLeader())
+	require.Eventually(t, func() bool {
+		second := leader()
+		return second != nil && second != first && running.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, replica := range replicas {
+		replica.stopLeaderElection()
+	}
+	assert.Equal(t, int32(0), running.Load())
+}
+
+func TestLeaderLockLost(t *testing.T) {
+	dbStore, err := store.NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer dbStore.Close()
+	lostStore := &lostLockStore{Store: dbStore}
+
+	var running atomic.Int32
+	engine := &Engine{ctx: context.Background(), logger: getLogger(), store: lostStore}
+	engine.RunBackgroundJob("job", func(ctx context.Context) {
+		running.Add(1)
+		<-ctx.Done()
+		running.Add(-1)
+	})
+	engine.startLeaderElection(true)
+	defer engine.stopLeaderElection()
+	require.Eventually(t, func() bool { return engine.IsLeader() && running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// leader steps down once its lock is lost, before store is checked again, and campaigns again
+	var steppedDown atomic.Bool
+	engine.RunBackgroundJob("watch", func(ctx context.Context) {
+		<-ctx.Done()
+		steppedDown.Store(true)
+	})
+	lostStore.loseLocks()
+	require.Eventually(t, steppedDown.Load, leaderCheckInterval/2, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return engine.IsLeader() && running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+}
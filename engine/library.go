@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+)
+
+// New creates engine embedded in another service without HTTP server, configured using
+// environment variables and opts, store is opened from configuration unless set with WithStore.
+// Background jobs run once Start is called, Stop shuts engine down and closes its store
+func New(opts ...Option) (*Engine, error) {
+	o := newOptions(opts...)
+	if o.configErr != nil {
+		return nil, o.configErr
+	}
+	if err := o.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	dbStore := o.store
+	if dbStore == nil {
+		var err error
+		if dbStore, err = OpenStore(o.config, o.keyProvider, o.logger); err != nil {
+			return nil, err
+		}
+	}
+
+	e, err := newEngineWithConfig(o.config, dbStore, o.logger)
+	if err != nil {
+		if closeErr := dbStore.Close(); closeErr != nil {
+			o.logger.Error().Err(closeErr).Msg("failed to close store")
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// OpenStore opens store backend of cfg, data key is fetched from provider if set otherwise from configured
+// key provider
+func OpenStore(cfg *config.Config, provider store.KeyProvider, logger zerolog.Logger) (store.Store, error) {
+	logger.Info().Str("Backend", cfg.Store.Backend).Msg("Creating store")
+	opts := cfg.Store.Options()
+	if provider == nil && cfg.Store.KeyProvider.Enabled() {
+		logger.Info().Str("KeyProvider", cfg.Store.KeyProvider.Type).Msg("Fetching store data key")
+		var err error
+		if provider, err = cfg.Store.KeyProvider.Provider(); err != nil {
+			logger.Error().Err(err).Msg("failed to create key provider")
+			return nil, err
+		}
+	}
+	opts.KeyProvider = provider
+	dbStore, err := store.Open(cfg.Store.Backend, cfg.Store.DSN(), opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create store")
+		return nil, err
+	}
+	return store.NewMetricsStore(store.NewScanTrackingStore(dbStore, store.DefaultScanTracker)), nil
+}
+
+// Stop waits for in flight async orchestrations, stops background jobs and closes store
+func (e *Engine) Stop() error {
+	return e.ShutdownAndClose()
+}
+
+// Events returns event bus receiving rollout lifecycle and target events of this engine
+func (e *Engine) Events() *EventBus {
+	return e.events
+}
+
+// Subscribe returns channel receiving rollout lifecycle and target events of this engine,
+// events are dropped rather than blocking orchestration once buffer is full,
+// returned func unsubscribes and closes the channel
+func (e *Engine) Subscribe(buffer int) (<-chan Event, func()) {
+	var lock sync.Mutex
+	closed := false
+	events := make(chan Event, buffer)
+
+	unsubscribe := e.events.Subscribe(func(event Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+			// slow consumer, dropping event rather than blocking orchestration
+		}
+	})
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			unsubscribe()
+			lock.Lock()
+			defer lock.Unlock()
+			closed = true
+			close(events)
+		})
+	}
+}
+
+// Logger returns logger of engine
+func (e *Engine) Logger() zerolog.Logger {
+	return e.logger
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedEngine(t *testing.T) {
+	const testName = "TestEmbeddedEngine"
+	dbStore, err := store.NewBadgerDBStore("", "")
+	require.NoError(t, err)
+
+	engine, err := New(WithConfig(config.Default()), WithStore(dbStore), WithLogger(getLogger()))
+	require.NoError(t, err)
+	assert.False(t, engine.IsLeader())
+
+	engine.Start()
+	engine.Start()
+	assert.True(t, engine.IsLeader())
+
+	events, unsubscribe := engine.Subscribe(16)
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	_, err = engine.Orchestrate(testName, testName, []*ClientState{{Name: "clientTarget0", Version: "v0"}})
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, testName, event.Namespace)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected event of orchestration")
+	}
+	unsubscribe()
+	unsubscribe()
+	for range events {
+	}
+
+	require.NoError(t, engine.Stop())
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// entityLockPrefix names store locks of entities
+	entityLockPrefix = "entity/"
+	// entityLockTimeout bounds waiting for another replica orchestrating the same entity
+	entityLockTimeout = 30 * time.Second
+)
+
+func entityLockKey(namespace, entity string) string {
+	return namespace + "/" + entity
+}
+
+// lockEntity serializes orchestrations of entity, across replicas sharing store if distributed locks
+// are enabled, returned func releases it
+func (e *Engine) lockEntity(namespace, entity string) (func(), error) {
+	_, unlock, err := e.lockEntityContext(e.ctx, namespace, entity)
+	return unlock, err
+}
+
+// lockEntityContext locks entity, waiting for lock held by another replica stops once ctx is done,
+// returned context of lock holder is ctx cancelled with store.ErrLockLost as cause once store lock is lost
+func (e *Engine) lockEntityContext(ctx context.Context, namespace, entity string) (context.Context, func(), error) {
+	key := entityLockKey(namespace, entity)
+	unlock := e.locks.acquire(key)
+	if !e.distributedLocks {
+		return ctx, unlock, nil
+	}
+
+	lockCtx, cancelLock := context.WithTimeout(ctx, entityLockTimeout)
+	defer cancelLock()
+	storeCtx, unlockStore, err := e.store.Lock(lockCtx, entityLockPrefix+key)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	if e.cache != nil {
+		// another replica may have orchestrated entity since it was cached
+		e.cache.drop(rolloutPrefix + key)
+	}
+
+	holderCtx, cancel := context.WithCancelCause(ctx)
+	stopLost := context.AfterFunc(storeCtx, func() {
+		cancel(context.Cause(storeCtx))
+	})
+	return holderCtx, func() {
+		stopLost()
+		cancel(nil)
+		if err := unlockStore(); err != nil {
+			e.logger.Error().Err(err).Str("Namespace", namespace).Str("Entity", entity).Msg("failed to release entity lock")
+		}
+		unlock()
+	}, nil
+}
+
+// entityLocks serializes orchestrations of the same entity
+type entityLocks struct {
+	lock  sync.Mutex
+	locks map[string]*entityLock
+}
+
+type entityLock struct {
+	sync.Mutex
+	refs int
+}
+
+// acquire locks entity key, returned func releases it
+func (l *entityLocks) acquire(key string) func() {
+	l.lock.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*entityLock)
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &entityLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.lock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		lock.refs--
+		if lock.refs <= 0 {
+			delete(l.locks, key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lostLockStore is store whose held locks are lost once loseLocks is called
+type lostLockStore struct {
+	store.Store
+	lock sync.Mutex
+	lose []context.CancelCauseFunc
+}
+
+func (s *lostLockStore) Lock(ctx context.Context, name string) (context.Context, store.UnlockFunc, error) {
+	holderCtx, unlock, err := s.Store.Lock(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	lostCtx, lose := context.WithCancelCause(holderCtx)
+	s.lock.Lock()
+	s.lose = append(s.lose, lose)
+	s.lock.Unlock()
+	return lostCtx, func() error {
+		lose(nil)
+		return unlock()
+	}, nil
+}
+
+func (s *lostLockStore) loseLocks() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, lose := range s.lose {
+		lose(fmt.Errorf("%w: lease expired", store.ErrLockLost))
+	}
+	s.lose = nil
+}
+
+func TestEntityLocks(t *testing.T) {
+	var locks entityLocks
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer locks.acquire("ns/entity")()
+			counter++
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, counter)
+	assert.Empty(t, locks.locks)
+}
+
+func TestDistributedEntityLocks(t *testing.T) {
+	const testName = "TestDistributedEntityLocks"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+	engine.distributedLocks = true
+
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+
+	// entity locked by another replica sharing the store
+	_, unlock, err := engine.store.Lock(context.Background(), entityLockPrefix+entityLockKey(testName, testName))
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := engine.Orchestrate(testName, testName, []*ClientState{{Name: "clientTarget0", Version: "v1"}})
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("orchestrated while entity is locked by another replica")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, unlock())
+	require.NoError(t, <-done)
+}
+
+func TestDistributedEntityLockLost(t *testing.T) {
+	const testName = "TestDistributedEntityLockLost"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+	engine.distributedLocks = true
+	lostStore := &lostLockStore{Store: engine.store}
+	engine.store = lostStore
+
+	ctx, unlock, err := engine.lockEntityContext(context.Background(), testName, testName)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err())
+
+	// holder stops once store lock is lost
+	lostStore.loseLocks()
+	require.Eventually(t, func() bool { return ctx.Err() != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, context.Cause(ctx), store.ErrLockLost)
+	unlock()
+
+	// entity is locked again once released
+	ctx, unlock, err = engine.lockEntityContext(context.Background(), testName, testName)
+	require.NoError(t, err)
+	assert.NoError(t, ctx.Err())
+	unlock()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
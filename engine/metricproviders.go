@@ -0,0 +1,308 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MetricProviderPrometheus queries prometheus instant query api
+	MetricProviderPrometheus = "prometheus"
+	// MetricProviderDatadog queries datadog timeseries query api
+	MetricProviderDatadog = "datadog"
+	// MetricProviderCloudWatch queries cloudwatch GetMetricData with metric math or metrics insights expressions
+	MetricProviderCloudWatch = "cloudwatch"
+
+	defaultDatadogAddress = "https://api.datadoghq.com"
+)
+
+// errNoMetricData is returned if query matched no series
+var errNoMetricData = errors.New("query returned no data")
+
+// MetricProvider evaluates query of canary analysis to a single value, window is lookback of query
+type MetricProvider interface {
+	Query(ctx context.Context, query string, window time.Duration) (float64, error)
+}
+
+// MetricProviderFactory creates metric provider from analysis provider config
+type MetricProviderFactory func(config *AnalysisProviderConfig) (MetricProvider, error)
+
+var (
+	metricProvidersLock sync.RWMutex
+	metricProviders     = map[string]MetricProviderFactory{
+		MetricProviderPrometheus: func(config *AnalysisProviderConfig) (MetricProvider, error) {
+			return &prometheusProvider{config: config}, nil
+		},
+		MetricProviderDatadog: func(config *AnalysisProviderConfig) (MetricProvider, error) {
+			return &datadogProvider{config: config}, nil
+		},
+		MetricProviderCloudWatch: func(config *AnalysisProviderConfig) (MetricProvider, error) {
+			return &cloudWatchProvider{config: config}, nil
+		},
+	}
+)
+
+// RegisterMetricProvider makes metric provider available to canary analysis under type name
+func RegisterMetricProvider(providerType string, factory MetricProviderFactory) {
+	metricProvidersLock.Lock()
+	defer metricProvidersLock.Unlock()
+	metricProviders[providerType] = factory
+}
+
+func newMetricProvider(config *AnalysisProviderConfig) (MetricProvider, error) {
+	metricProvidersLock.RLock()
+	factory, ok := metricProviders[config.Type]
+	metricProvidersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown metric provider %q", ErrInvalidAnalysisConfig, config.Type)
+	}
+	return factory(config)
+}
+
+// doMetricRequest sends request and decodes json response, non 2xx status is an error
+func doMetricRequest(req *http.Request, value any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("metric query failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+type prometheusProvider struct {
+	config *AnalysisProviderConfig
+}
+
+// Query runs instant query, vector results use first sample
+func (p *prometheusProvider) Query(ctx context.Context, query string, window time.Duration) (float64, error) {
+	queryURL := fmt.Sprintf("%s/api/v1/query?query=%s", strings.TrimSuffix(p.config.Address, "/"), url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := doMetricRequest(req, &result); err != nil {
+		return 0, err
+	}
+	if result.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", result.Error)
+	}
+
+	var sample []any
+	switch result.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(result.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+	case "vector":
+		var vector []struct {
+			Value []any `json:"value"`
+		}
+		if err := json.Unmarshal(result.Data.Result, &vector); err != nil {
+			return 0, err
+		}
+		if len(vector) <= 0 {
+			return 0, fmt.Errorf("prometheus %w: %s", errNoMetricData, query)
+		}
+		sample = vector[0].Value
+	default:
+		return 0, fmt.Errorf("unsupported prometheus result type %s", result.Data.ResultType)
+	}
+
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("invalid prometheus sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid prometheus sample value %v", sample[1])
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+type datadogProvider struct {
+	config *AnalysisProviderConfig
+}
+
+// Query averages points of first series over window
+func (p *datadogProvider) Query(ctx context.Context, query string, window time.Duration) (float64, error) {
+	address := p.config.Address
+	if address == "" {
+		address = defaultDatadogAddress
+	}
+	to := time.Now()
+	queryURL := fmt.Sprintf("%s/api/v1/query?from=%d&to=%d&query=%s", strings.TrimSuffix(address, "/"),
+		to.Add(-window).Unix(), to.Unix(), url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("DD-API-KEY", p.config.APIKey)
+	req.Header.Set("DD-APPLICATION-KEY", p.config.ApplicationKey)
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Series []struct {
+			Pointlist [][]*float64 `json:"pointlist"`
+		} `json:"series"`
+	}
+	if err := doMetricRequest(req, &result); err != nil {
+		return 0, err
+	}
+	if result.Status == "error" {
+		return 0, fmt.Errorf("datadog query failed: %s", result.Error)
+	}
+
+	var values []float64
+	if len(result.Series) > 0 {
+		for _, point := range result.Series[0].Pointlist {
+			if len(point) == 2 && point[1] != nil {
+				values = append(values, *point[1])
+			}
+		}
+	}
+	if len(values) <= 0 {
+		return 0, fmt.Errorf("datadog %w: %s", errNoMetricData, query)
+	}
+	return computeStatistic(CanaryStatisticMean, values)
+}
+
+type cloudWatchProvider struct {
+	config *AnalysisProviderConfig
+}
+
+// Query evaluates expression with GetMetricData over window as a single period, averaging returned values
+func (p *cloudWatchProvider) Query(ctx context.Context, query string, window time.Duration) (float64, error) {
+	address := p.config.Address
+	if address == "" {
+		address = fmt.Sprintf("https://monitoring.%s.amazonaws.com", p.config.Region)
+	}
+	period := max(int(window.Seconds())/60*60, 60)
+	end := time.Now()
+	body, err := json.Marshal(map[string]any{
+		"MetricDataQueries": []map[string]any{{"Id": "q", "Expression": query, "Period": period}},
+		"StartTime":         end.Add(-window).Unix(),
+		"EndTime":           end.Unix(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(address, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "GraniteServiceVersion20100801.GetMetricData")
+
+	accessKeyID, secretAccessKey, sessionToken := p.config.AccessKeyID, p.config.SecretAccessKey, p.config.SessionToken
+	if accessKeyID == "" {
+		accessKeyID, secretAccessKey, sessionToken = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+	}
+	signAWSRequest(req, body, p.config.Region, "monitoring", accessKeyID, secretAccessKey, sessionToken, time.Now())
+
+	var result struct {
+		MetricDataResults []struct {
+			Values []float64 `json:"Values"`
+		} `json:"MetricDataResults"`
+	}
+	if err := doMetricRequest(req, &result); err != nil {
+		return 0, err
+	}
+	if len(result.MetricDataResults) <= 0 || len(result.MetricDataResults[0].Values) <= 0 {
+		return 0, fmt.Errorf("cloudwatch %w: %s", errNoMetricData, query)
+	}
+	return computeStatistic(CanaryStatisticMean, result.MetricDataResults[0].Values)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signAWSRequest signs request with AWS signature version 4, signing host, content type and x-amz headers
+func signAWSRequest(req *http.Request, body []byte, region, service, accessKeyID, secretAccessKey, sessionToken string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.Query().Encode(), canonicalHeaders.String(), signedHeaders, sha256Hex(body)}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKeyID, scope, signedHeaders, signature))
+}
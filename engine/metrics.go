@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"github.com/nixmade/orchestrator/metrics"
+)
+
+var (
+	orchestrateDuration = metrics.DefaultRegistry.NewHistogramVec(
+		"orchestrator_orchestrate_duration_seconds",
+		"Latency of orchestrate calls in seconds",
+		nil,
+		"namespace", "entity",
+	)
+	rolloutsInProgress = metrics.DefaultRegistry.NewGaugeVec(
+		"orchestrator_rollouts_in_progress",
+		"1 if entity is rolling out a new version, 0 otherwise",
+		"namespace", "entity",
+	)
+	rolloutTargets = metrics.DefaultRegistry.NewGaugeVec(
+		"orchestrator_rollout_targets",
+		"Number of targets by rollout state",
+		"namespace", "entity", "state",
+	)
+	rolloutsStalled = metrics.DefaultRegistry.NewGaugeVec(
+		"orchestrator_rollouts_stalled",
+		"1 if in progress rollout made no progress for stall timeout, 0 otherwise",
+		"namespace", "entity",
+	)
+	rollbacksTotal = metrics.DefaultRegistry.NewCounterVec(
+		"orchestrator_rollbacks_total",
+		"Number of rollbacks initiated",
+		"namespace", "entity",
+	)
+)
+
+// recordMetrics updates rollout metrics after orchestration
+func (r *Rollout) recordMetrics(previous RolloutVersionInfo, state *rolloutInfo) {
+	namespace, entity := r.entity.Namespace, r.entity.Name
+
+	inProgress := 0.0
+	if r.State.RollingVersion != r.State.LastKnownGoodVersion && r.State.RollingVersion != r.State.LastKnownBadVersion {
+		inProgress = 1.0
+	}
+	rolloutsInProgress.WithLabelValues(namespace, entity).Set(inProgress)
+
+	rolloutTargets.WithLabelValues(namespace, entity, "success").Set(float64(len(state.successTargets)))
+	rolloutTargets.WithLabelValues(namespace, entity, "failed").Set(float64(len(state.failedTargets)))
+	rolloutTargets.WithLabelValues(namespace, entity, "inRollout").Set(float64(len(state.inRolloutTargets)))
+
+	if previous.LastKnownBadVersion != r.State.LastKnownBadVersion &&
+		r.State.LastKnownBadVersion == r.State.RollingVersion &&
+		r.State.LastKnownGoodVersion != "" {
+		rollbacksTotal.WithLabelValues(namespace, entity).Inc()
+	}
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	schemaVersionKey = "schemaversion"
+	migrationPrefix  = "migration:"
+	// initialSchemaVersion is layout of stores created before schema version was recorded
+	initialSchemaVersion = 1
+)
+
+// Migration rewrites keys under Prefix from layout Version-1 to layout Version
+type Migration struct {
+	Version     int
+	Description string
+	// Prefix of keys rewritten by migration
+	Prefix string
+	// Rewrite returns new key and value for key, returning same key rewrites value in place, empty key deletes key
+	Rewrite func(key string, value json.RawMessage) (string, json.RawMessage, error)
+}
+
+// SchemaVersion records store layout version
+type SchemaVersion struct {
+	Version   int       `json:"version,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// MigratedKey records original key and value, used to roll back partially applied migration
+type MigratedKey struct {
+	Key    string          `json:"key,omitempty"`
+	NewKey string          `json:"newkey,omitempty"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// MigrationProgress is persisted while migration is applied, removed once schema version is updated
+type MigrationProgress struct {
+	Version   int           `json:"version,omitempty"`
+	Total     int           `json:"total,omitempty"`
+	Completed int           `json:"completed,omitempty"`
+	Keys      []MigratedKey `json:"keys,omitempty"`
+	Timestamp time.Time     `json:"timestamp,omitempty"`
+}
+
+// migrations registered in order, append new migration when rolloutKey, entityTargetKey or other key layouts change
+var migrations []Migration
+
+// SchemaVersionLatest returns store layout version written by this engine
+func SchemaVersionLatest() int {
+	return latestSchemaVersion(migrations)
+}
+
+func latestSchemaVersion(migrations []Migration) int {
+	latest := initialSchemaVersion
+	for _, migration := range migrations {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+	return latest
+}
+
+func migrationKey(version int) string {
+	return fmt.Sprintf("%s%010d", migrationPrefix, version)
+}
+
+// SchemaVersion returns store layout version, stores without schema version are initial layout
+func (e *Engine) SchemaVersion() (int, error) {
+	schemaVersion := &SchemaVersion{}
+	err := e.store.LoadJSON(schemaVersionKey, schemaVersion)
+	if err == store.ErrKeyNotFound {
+		return initialSchemaVersion, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return schemaVersion.Version, nil
+}
+
+// migrate rolls back interrupted migrations and applies pending migrations in version order
+func (e *Engine) migrate(migrations []Migration) error {
+	if err := e.rollbackInterruptedMigrations(); err != nil {
+		return err
+	}
+
+	current, err := e.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest := latestSchemaVersion(migrations)
+	if current > latest {
+		return fmt.Errorf("%w: store version %d, engine version %d", ErrSchemaVersionUnsupported, current, latest)
+	}
+
+	pending := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+
+	for _, migration := range pending {
+		if err := e.applyMigration(migration); err != nil {
+			return err
+		}
+	}
+
+	if len(pending) > 0 {
+		return nil
+	}
+
+	// record version for stores created before schema version was recorded
+	if err := e.store.LoadJSON(schemaVersionKey, &SchemaVersion{}); err != store.ErrKeyNotFound {
+		return err
+	}
+	return e.store.SaveJSON(schemaVersionKey, &SchemaVersion{Version: latest, Timestamp: nowUTC()})
+}
+
+// applyMigration rewrites keys of migration, partially applied migration is rolled back on failure
+func (e *Engine) applyMigration(migration Migration) error {
+	logger := e.logger.With().Int("SchemaVersion", migration.Version).Str("Prefix", migration.Prefix).Logger()
+	logger.Info().Str("Description", migration.Description).Msg("Applying store migration")
+
+	if migration.Prefix == "" || migration.Rewrite == nil {
+		return fmt.Errorf("%w: version %d requires prefix and rewrite", ErrMigrationFailed, migration.Version)
+	}
+
+	progress := &MigrationProgress{Version: migration.Version, Timestamp: nowUTC()}
+	migrationItr := func(key any, value any) error {
+		progress.Keys = append(progress.Keys, MigratedKey{Key: key.(string), Value: json.RawMessage(value.(string))})
+		return nil
+	}
+	if err := e.store.LoadValues(migration.Prefix, migrationItr); err != nil {
+		return err
+	}
+	progress.Total = len(progress.Keys)
+
+	// compute all rewrites before modifying store, failures here leave store untouched
+	values := make([]json.RawMessage, len(progress.Keys))
+	for i, migratedKey := range progress.Keys {
+		newKey, newValue, err := migration.Rewrite(migratedKey.Key, migratedKey.Value)
+		if err != nil {
+			return fmt.Errorf("%w: version %d key %s: %w", ErrMigrationFailed, migration.Version, migratedKey.Key, err)
+		}
+		progress.Keys[i].NewKey = newKey
+		values[i] = newValue
+	}
+
+	// progress records original values, persisted before any key is rewritten
+	if err := e.store.SaveJSON(migrationKey(migration.Version), progress); err != nil {
+		return err
+	}
+
+	for i, migratedKey := range progress.Keys {
+		if err := e.rewriteKey(migratedKey, values[i]); err != nil {
+			logger.Error().Err(err).Str("Key", migratedKey.Key).Msg("Store migration failed, rolling back")
+			if rollbackErr := e.rollbackMigration(progress); rollbackErr != nil {
+				return rollbackErr
+			}
+			return fmt.Errorf("%w: version %d key %s: %w", ErrMigrationFailed, migration.Version, migratedKey.Key, err)
+		}
+		progress.Completed = i + 1
+		if progress.Completed%1000 == 0 {
+			logger.Info().Int("Completed", progress.Completed).Int("Total", progress.Total).Msg("Store migration progress")
+		}
+	}
+
+	if err := e.store.SaveJSON(schemaVersionKey, &SchemaVersion{Version: migration.Version, Timestamp: nowUTC()}); err != nil {
+		return err
+	}
+
+	logger.Info().Int("Total", progress.Total).Msg("Store migration completed")
+	return e.store.Delete(migrationKey(migration.Version))
+}
+
+func (e *Engine) rewriteKey(migratedKey MigratedKey, value json.RawMessage) error {
+	if migratedKey.NewKey != "" {
+		if err := e.store.SaveJSON(migratedKey.NewKey, value); err != nil {
+			return err
+		}
+	}
+	if migratedKey.NewKey == migratedKey.Key {
+		return nil
+	}
+	return e.store.Delete(migratedKey.Key)
+}
+
+// rollbackMigration restores original keys and values, removes rewritten keys
+func (e *Engine) rollbackMigration(progress *MigrationProgress) error {
+	e.logger.Warn().Int("SchemaVersion", progress.Version).Int("Total", progress.Total).Msg("Rolling back store migration")
+
+	for _, migratedKey := range progress.Keys {
+		if migratedKey.NewKey != "" && migratedKey.NewKey != migratedKey.Key {
+			if err := e.store.Delete(migratedKey.NewKey); err != nil && err != store.ErrKeyNotFound {
+				return err
+			}
+		}
+		if err := e.store.SaveJSON(migratedKey.Key, migratedKey.Value); err != nil {
+			return err
+		}
+	}
+
+	return e.store.Delete(migrationKey(progress.Version))
+}
+
+// rollbackInterruptedMigrations rolls back migrations left behind by a crash, they are applied again afterwards,
+// progress of migrations already recorded in schema version is only removed
+func (e *Engine) rollbackInterruptedMigrations() error {
+	current, err := e.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	var interrupted []*MigrationProgress
+	progressItr := func(key any, value any) error {
+		progress := &MigrationProgress{}
+		if err := json.Unmarshal([]byte(value.(string)), progress); err != nil {
+			return err
+		}
+		interrupted = append(interrupted, progress)
+		return nil
+	}
+	if err := e.store.LoadValues(migrationPrefix, progressItr); err != nil {
+		return err
+	}
+
+	for _, progress := range interrupted {
+		if progress.Version <= current {
+			if err := e.store.Delete(migrationKey(progress.Version)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.rollbackMigration(progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
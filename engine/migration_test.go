@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renameMigration(version int, prefix, from, to string) Migration {
+	return Migration{
+		Version:     version,
+		Description: "rename " + from + " to " + to,
+		Prefix:      prefix,
+		Rewrite: func(key string, value json.RawMessage) (string, json.RawMessage, error) {
+			return strings.Replace(key, from, to, 1), value, nil
+		},
+	}
+}
+
+func TestSchemaVersionRecorded(t *testing.T) {
+	testName := "TestSchemaVersionRecorded"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	version, err := engine.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersionLatest(), version)
+
+	schemaVersion := &SchemaVersion{}
+	require.NoError(t, engine.store.LoadJSON(schemaVersionKey, schemaVersion))
+	assert.Equal(t, SchemaVersionLatest(), schemaVersion.Version)
+
+	// store written by a newer engine is not loaded
+	require.NoError(t, engine.store.SaveJSON(schemaVersionKey, &SchemaVersion{Version: SchemaVersionLatest() + 1}))
+	require.ErrorIs(t, engine.migrate(migrations), ErrSchemaVersionUnsupported)
+}
+
+func TestMigrationRewritesKeys(t *testing.T) {
+	testName := "TestMigrationRewritesKeys"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.store.SaveJSON("legacy:ns/a", map[string]string{"name": "a"}))
+	require.NoError(t, engine.store.SaveJSON("legacy:ns/b", map[string]string{"name": "b"}))
+
+	upper := Migration{
+		Version: 3,
+		Prefix:  "layout:",
+		Rewrite: func(key string, value json.RawMessage) (string, json.RawMessage, error) {
+			return key, json.RawMessage(strings.ToUpper(string(value))), nil
+		},
+	}
+	// applied in version order regardless of registration order
+	require.NoError(t, engine.migrate([]Migration{upper, renameMigration(2, "legacy:", "legacy:", "layout:")}))
+
+	version, err := engine.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	keys, err := engine.store.LoadKeys("legacy:")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	value := map[string]string{}
+	require.NoError(t, engine.store.LoadJSON("layout:ns/a", &value))
+	assert.Equal(t, "A", value["NAME"])
+
+	keys, err = engine.store.LoadKeys(migrationPrefix)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// already applied migrations are skipped
+	require.NoError(t, engine.migrate([]Migration{renameMigration(2, "legacy:", "legacy:", "broken:"), upper}))
+	keys, err = engine.store.LoadKeys("broken:")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestMigrationRollback(t *testing.T) {
+	testName := "TestMigrationRollback"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.store.SaveJSON("legacy:ns/a", "a"))
+
+	failing := Migration{
+		Version: 2,
+		Prefix:  "legacy:",
+		Rewrite: func(key string, value json.RawMessage) (string, json.RawMessage, error) {
+			return "", nil, errors.New("unexpected layout")
+		},
+	}
+	require.ErrorIs(t, engine.migrate([]Migration{failing}), ErrMigrationFailed)
+
+	version, err := engine.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	var value string
+	require.NoError(t, engine.store.LoadJSON("legacy:ns/a", &value))
+	assert.Equal(t, "a", value)
+
+	// simulate crash after key was rewritten, interrupted migration is rolled back then applied again
+	progress := &MigrationProgress{
+		Version: 2,
+		Total:   1,
+		Keys:    []MigratedKey{{Key: "legacy:ns/a", NewKey: "layout:ns/a", Value: json.RawMessage(`"a"`)}},
+	}
+	require.NoError(t, engine.store.SaveJSON(migrationKey(2), progress))
+	require.NoError(t, engine.store.SaveJSON("layout:ns/a", "a"))
+	require.NoError(t, engine.store.Delete("legacy:ns/a"))
+
+	require.NoError(t, engine.rollbackInterruptedMigrations())
+	require.NoError(t, engine.store.LoadJSON("legacy:ns/a", &value))
+	assert.Equal(t, "a", value)
+	assert.ErrorIs(t, engine.store.LoadJSON("layout:ns/a", &value), store.ErrKeyNotFound)
+
+	require.NoError(t, engine.migrate([]Migration{renameMigration(2, "legacy:", "legacy:", "layout:")}))
+	require.NoError(t, engine.store.LoadJSON("layout:ns/a", &value))
+	assert.Equal(t, "a", value)
+}
@@ -1,7 +1,10 @@
-package core
+package engine
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/nixmade/orchestrator/store"
 	"github.com/rs/zerolog"
@@ -14,9 +17,11 @@ const (
 // Namespace holds the list of entities
 type Namespace struct {
 	// list of entities
-	Name   string         `json:"name,omitempty"`
-	store  store.Store    `json:"-"`
-	logger zerolog.Logger `json:"-"`
+	Name        string         `json:"name,omitempty"`
+	store       store.Store    `json:"-"`
+	logger      zerolog.Logger `json:"-"`
+	quotaCounts *QuotaCounts   `json:"-"`
+	events      *EventBus      `json:"-"`
 }
 
 // CreateNamespace creates namespace
@@ -27,6 +32,7 @@ func (e *Engine) createNamespace(name string) (*Namespace, error) {
 		Name:   name,
 		logger: e.logger.With().Str("Namespace", name).Logger(),
 		store:  e.store,
+		events: e.events,
 	}
 
 	return n, e.store.SaveJSON(namespaceKey(name), n)
@@ -47,18 +53,28 @@ func (n *Namespace) findEntity(name string) (*Entity, error) {
 	entity := &Entity{}
 
 	if err := n.store.LoadJSON(n.entityKey(name), entity); err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, &NotFoundError{Kind: ErrEntityNotFound, Name: name}
+		}
 		return nil, err
 	}
 
 	entity.store = n.store
 	entity.logger = n.logger.With().Str("Entity", name).Logger()
+	entity.quotaCounts = n.quotaCounts
+	entity.events = n.events
 
 	return entity, nil
 }
 
 func (n *Namespace) findorCreateEntity(name string) (*Entity, error) {
 	entity, err := n.findEntity(name)
-	if err == store.ErrKeyNotFound {
+	if errors.Is(err, ErrEntityNotFound) {
+		count, err := n.checkEntityQuota(name)
+		if err != nil {
+			return nil, err
+		}
+		n.quotaCounts.set(namespaceEntitiesPrefix(n.Name), count)
 		return n.createEntity(name)
 	}
 
@@ -70,21 +86,21 @@ func (n *Namespace) findorCreateEntity(name string) (*Entity, error) {
 }
 
 // orchestrate provided entityName over list of targets, updates targetVersion
-func (n *Namespace) orchestrate(entityName string, targets []*ClientState) ([]*ClientState, error) {
+func (n *Namespace) orchestrate(ctx context.Context, entityName string, targets []*ClientState) ([]*ClientState, error) {
 	entity, err := n.findorCreateEntity(entityName)
 	if err != nil {
 		return nil, err
 	}
-	return entity.orchestrate(targets)
+	return entity.orchestrate(ctx, targets)
 }
 
 // orchestrateasync records list of input targets
-func (n *Namespace) orchestrateasync(entityName string, targets []*ClientState) error {
+func (n *Namespace) orchestrateasync(entityName string, targets []*ClientState, enqueue func(run func(ctx context.Context)) error) error {
 	entity, err := n.findorCreateEntity(entityName)
 	if err != nil {
 		return err
 	}
-	return entity.orchestrateasync(targets)
+	return entity.orchestrateasync(targets, enqueue)
 }
 
 // getClientState provided entityName, returns current target state
@@ -105,6 +121,24 @@ func (n *Namespace) getClientGroupState(entityName, groupName string) ([]*Client
 	return entity.getClientGroupState(groupName)
 }
 
+// getClientStatePage provided entityName, returns page of current target state of group after cursor
+func (n *Namespace) getClientStatePage(entityName, groupName, cursor string, limit int) ([]*ClientState, string, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, "", err
+	}
+	return entity.getClientStatePage(groupName, cursor, limit)
+}
+
+// getFilteredClientState provided entityName, returns current target state of targets matching filter
+func (n *Namespace) getFilteredClientState(entityName string, filter TargetFilter) ([]*ClientState, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getFilteredClientState(filter)
+}
+
 // Below are supporting case for front end API
 
 // getEntities gets list of entities owned by this namespace
@@ -126,3 +160,39 @@ func (n *Namespace) getRolloutInfo(entityName string) (*RolloutState, error) {
 	}
 	return entity.getRolloutInfo()
 }
+
+// getQuarantinedTargets gets quarantined targets for the entity
+func (n *Namespace) getQuarantinedTargets(entityName string) ([]*EntityTarget, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getQuarantinedTargets()
+}
+
+// releaseQuarantinedTarget releases quarantined target for the entity
+func (n *Namespace) releaseQuarantinedTarget(entityName, group, name string) error {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.releaseQuarantinedTarget(group, name)
+}
+
+// getRolloutHistory gets past rollouts for the entity
+func (n *Namespace) getRolloutHistory(entityName string, offset, limit int) ([]*RolloutHistory, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getRolloutHistory(offset, limit)
+}
+
+// getSnapshots gets fleet snapshots for the entity
+func (n *Namespace) getSnapshots(entityName string, since time.Time) ([]*FleetSnapshot, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getSnapshots(since)
+}
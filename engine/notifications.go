@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nixmade/orchestrator/signature"
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	notificationPrefix = "notification:"
+	// SignatureHeader carries hex encoded HMAC-SHA256 of webhook payload signed with notification secret
+	SignatureHeader = signature.Header
+	webhookTimeout  = 10 * time.Second
+)
+
+// NotificationConfig is per entity webhook configuration
+type NotificationConfig struct {
+	// URL is called with json Event for every matching event, empty URL disables notifications
+	URL string `json:"url,omitempty"`
+	// Secret used to sign payload, signature is sent in X-Orchestrator-Signature as sha256=<hex>
+	Secret string `json:"secret,omitempty"`
+	// Events to notify, empty notifies all events
+	Events []EventType `json:"events,omitempty"`
+}
+
+func (c *NotificationConfig) matches(eventType EventType) bool {
+	if len(c.Events) <= 0 {
+		return true
+	}
+	for _, event := range c.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entity) notificationKey() string {
+	return fmt.Sprintf("%s%s/%s", notificationPrefix, e.Namespace, e.Name)
+}
+
+// setNotificationConfig saves webhook configuration for the entity
+func (e *Entity) setNotificationConfig(config *NotificationConfig) error {
+	e.logger.Info().Str("URL", config.URL).Msg("Set NotificationConfig")
+	return e.store.SaveJSON(e.notificationKey(), config)
+}
+
+// findNotificationConfig returns nil config if notifications are not configured
+func (e *Entity) findNotificationConfig() (*NotificationConfig, error) {
+	config := &NotificationConfig{}
+	err := e.store.LoadJSON(e.notificationKey(), config)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// signPayload returns hex encoded HMAC-SHA256 of payload
+func signPayload(secret string, payload []byte) string {
+	return signature.SignPayload(secret, payload)
+}
+
+// deliverWebhook posts signed event to configured webhook
+func deliverWebhook(ctx context.Context, config *NotificationConfig, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.Secret != "" {
+		req.Header.Set(SignatureHeader, signPayload(config.Secret, payload))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", config.URL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// notify delivers events to entity webhook in background, failures are logged
+func (e *Entity) notify(events []Event) {
+	config, err := e.findNotificationConfig()
+	if err != nil {
+		e.logger.Error().Err(err).Msg("Failed to load notification config")
+		return
+	}
+	if config == nil || config.URL == "" {
+		return
+	}
+
+	go func() {
+		for _, event := range events {
+			if !config.matches(event.Type) {
+				continue
+			}
+			if err := deliverWebhook(context.Background(), config, event); err != nil {
+				e.logger.Error().Err(err).Str("Event", string(event.Type)).Msg("Failed to deliver webhook notification")
+			}
+		}
+	}()
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+)
+
+// Option configures engine created with New, options are applied in order
+type Option func(*options)
+
+type options struct {
+	config *config.Config
+	// configErr is returned from New when environment configuration is invalid
+	configErr   error
+	store       store.Store
+	logger      zerolog.Logger
+	keyProvider store.KeyProvider
+}
+
+func newOptions(opts ...Option) *options {
+	cfg, err := config.FromEnv()
+	o := &options{config: cfg, configErr: err}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithConfig replaces configuration read from environment variables
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.config = cfg
+		o.configErr = nil
+	}
+}
+
+// WithStore uses store instead of opening configured store backend, engine closes it on Stop
+func WithStore(s store.Store) Option {
+	return func(o *options) {
+		o.store = s
+	}
+}
+
+// WithLogger logs engine to logger, engine does not log otherwise
+func WithLogger(logger zerolog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithKeyProvider fetches store data key from provider instead of configured encryption key
+func WithKeyProvider(provider store.KeyProvider) Option {
+	return func(o *options) {
+		o.keyProvider = provider
+	}
+}
@@ -0,0 +1,20 @@
+package engine
+
+import "time"
+
+// filterPacedTargets holds next batch of a forward rollout until BatchIntervalSecs passed since last batch,
+// even if targets of last batch were already successful
+func (r *Rollout) filterPacedTargets(state *rolloutInfo) {
+	interval := time.Duration(r.options().BatchIntervalSecs) * time.Second
+	if interval <= 0 || r.State.BatchTimestamp.IsZero() || !r.rolloutInProgress() {
+		return
+	}
+
+	elapsed := timeSince(r.State.BatchTimestamp)
+	if elapsed >= interval {
+		return
+	}
+
+	r.logger.Info().Dur("Remaining", interval-elapsed).Int("HeldTargets", len(state.availableTargets)).Msg("Holding targets until batch interval passed")
+	state.availableTargets = nil
+}
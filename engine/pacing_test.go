@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterPacedTargets(t *testing.T) {
+	e, err := createEntity("TestFilterPacedTargets", getLogger())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	var clientTargets []*ClientState
+	for i := 0; i < 4; i++ {
+		clientTargets = append(clientTargets, &ClientState{
+			Name:    fmt.Sprintf("clientTarget%d", i),
+			Version: "v1",
+			Message: "running successfully",
+		})
+	}
+	require.NoError(t, e.updateEntityTargets(clientTargets))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	rollout.State.RollingVersion = "v2"
+	rollout.State.LastKnownGoodVersion = "v1"
+	rollout.State.Options.BatchIntervalSecs = 600
+
+	// first batch is not held
+	state := createRolloutInfo(targets)
+	state.availableTargets = targets
+	rollout.filterPacedTargets(state)
+	assert.Len(t, state.availableTargets, 4)
+
+	// last batch started recently, even if it was successful
+	rollout.State.BatchTimestamp = nowUTC().Add(-time.Minute)
+	state = createRolloutInfo(targets)
+	state.availableTargets = targets[2:]
+	state.successTargets = targets[:2]
+	rollout.filterPacedTargets(state)
+	assert.Empty(t, state.availableTargets)
+
+	// interval passed
+	rollout.State.BatchTimestamp = nowUTC().Add(-11 * time.Minute)
+	state = createRolloutInfo(targets)
+	state.availableTargets = targets[2:]
+	rollout.filterPacedTargets(state)
+	assert.Len(t, state.availableTargets, 2)
+
+	// rolling back is never paced
+	rollout.State.BatchTimestamp = nowUTC()
+	rollout.State.LastKnownBadVersion = "v2"
+	state = createRolloutInfo(targets)
+	state.availableTargets = targets
+	rollout.filterPacedTargets(state)
+	assert.Len(t, state.availableTargets, 4)
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// pause holds rollout progression of group, empty group pauses whole entity,
+// returns ErrRolloutPaused if entity or group is already paused
+func (r *Rollout) pause(group string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if group == "" {
+		if r.State.Paused {
+			return fmt.Errorf("%w: entity", ErrRolloutPaused)
+		}
+		r.logger.Info().Msg("Pausing rollout")
+		r.State.Paused = true
+		return nil
+	}
+	if slices.Contains(r.State.PausedGroups, group) {
+		return fmt.Errorf("%w: group %s", ErrRolloutPaused, group)
+	}
+	r.logger.Info().Str("Group", group).Msg("Pausing rollout")
+	r.State.PausedGroups = append(r.State.PausedGroups, group)
+	return nil
+}
+
+// resume continues rollout progression of group, empty group resumes entity, groups paused individually stay paused
+func (r *Rollout) resume(group string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.logger.Info().Str("Group", group).Msg("Resuming rollout")
+	if group == "" {
+		r.State.Paused = false
+		return
+	}
+	r.State.PausedGroups = slices.DeleteFunc(r.State.PausedGroups, func(paused string) bool {
+		return paused == group
+	})
+}
+
+// isGroupPaused returns true if entity or group is paused
+func (r *Rollout) isGroupPaused(group string) bool {
+	return r.State.Paused || slices.Contains(r.State.PausedGroups, group)
+}
+
+// filterPausedTargets removes targets of paused groups from available targets,
+// monitoring continues and rolling back or setting lkg is not held
+func (r *Rollout) filterPausedTargets(state *rolloutInfo) {
+	if !r.State.Paused && len(r.State.PausedGroups) <= 0 {
+		return
+	}
+
+	if !r.rolloutInProgress() {
+		return
+	}
+
+	var availableTargets EntityTargets
+	for _, entityTarget := range state.availableTargets {
+		if !r.isGroupPaused(entityTarget.Group) {
+			availableTargets = append(availableTargets, entityTarget)
+		}
+	}
+
+	r.logger.Info().Bool("Paused", r.State.Paused).Strs("PausedGroups", r.State.PausedGroups).
+		Int("HeldTargets", len(state.availableTargets)-len(availableTargets)).Msg("Holding targets of paused groups")
+	state.availableTargets = availableTargets
+}
+
+func (e *Entity) setPaused(group string, paused bool) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if paused {
+		if err := rollout.pause(group); err != nil {
+			return err
+		}
+	} else {
+		rollout.resume(group)
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+func (n *Namespace) setPaused(entityName, group string, paused bool) error {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.setPaused(group, paused)
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseGroup(t *testing.T) {
+	const testName = "TestPauseGroup"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+
+	var clientTargets []*ClientState
+	for _, group := range []string{"us-east", "eu-west"} {
+		for i := 0; i < 2; i++ {
+			clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Group: group, Version: "v1"})
+		}
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	// v1 is lkg, hold v2 rollout until targets report success
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100, SuccessPercent: 100, SuccessTimeoutSecs: 60, DurationTimeoutSecs: 120}))
+	require.NoError(t, engine.Pause(testName, testName, "eu-west"))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+
+	// first orchestrate promotes v2 to rolling version, second assigns it
+	var assigned []*ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	for _, clientTarget := range assigned {
+		if clientTarget.Group == "eu-west" {
+			assert.Equal(t, "v1", clientTarget.Version)
+		} else {
+			assert.Equal(t, "v2", clientTarget.Version)
+		}
+	}
+
+	rolloutState, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west"}, rolloutState.PausedGroups)
+	assert.False(t, rolloutState.Paused)
+
+	// entity pause holds every group, resuming entity keeps group pause
+	require.NoError(t, engine.Pause(testName, testName, ""))
+	assert.ErrorIs(t, engine.Pause(testName, testName, ""), ErrRolloutPaused)
+	assert.ErrorIs(t, engine.Pause(testName, testName, "eu-west"), ErrRolloutPaused)
+	require.NoError(t, engine.Resume(testName, testName, ""))
+	rolloutState, err = engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west"}, rolloutState.PausedGroups)
+
+	require.NoError(t, engine.Resume(testName, testName, "eu-west"))
+	assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 4, countVersion(assigned, "v2"))
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// TargetPin holds target on a version or excludes it from rollouts until unpinned
+type TargetPin struct {
+	// Version target is pinned to, empty excludes target keeping its current target version
+	Version string `json:"version,omitempty"`
+	// Reason shown in target status
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+func (p *TargetPin) message() string {
+	if p.Version == "" {
+		return fmt.Sprintf("Excluded from rollouts: %s", p.Reason)
+	}
+	return fmt.Sprintf("Pinned to version %s: %s", p.Version, p.Reason)
+}
+
+// pinTarget pins target to version or excludes it, pinned targets are not part of rollout
+func (e *Entity) pinTarget(group, name string, pin *TargetPin) (*ClientState, error) {
+	entityTarget := &EntityTarget{}
+	if err := e.store.LoadJSON(e.entityTargetKey(group, name), entityTarget); err != nil {
+		return nil, err
+	}
+
+	e.logger.Info().Str("Name", name).Str("Group", group).Str("Version", pin.Version).Str("Reason", pin.Reason).Msg("Pinning target")
+
+	pin.Timestamp = nowUTC()
+	entityTarget.State.Pin = pin
+	if pin.Version != "" && entityTarget.State.TargetVersion.Version != pin.Version {
+		entityTarget.State.TargetVersion.Version = pin.Version
+		entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+	}
+	entityTarget.State.TargetVersion.LastMessage.Success(pin.message())
+	entityTarget.addEvent(TargetEventPinned, pin.Version, pin.message())
+
+	if err := e.saveEntityTarget(entityTarget); err != nil {
+		return nil, err
+	}
+	return returnClientTarget(entityTarget), nil
+}
+
+// unpinTarget makes target part of rollout again
+func (e *Entity) unpinTarget(group, name string) (*ClientState, error) {
+	entityTarget := &EntityTarget{}
+	if err := e.store.LoadJSON(e.entityTargetKey(group, name), entityTarget); err != nil {
+		return nil, err
+	}
+
+	if entityTarget.State.Pin == nil {
+		return nil, ErrTargetNotPinned
+	}
+
+	e.logger.Info().Str("Name", name).Str("Group", group).Msg("Unpinning target")
+
+	entityTarget.State.Pin = nil
+	// restart monitoring window, otherwise target would immediately fail duration timeout
+	entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+	entityTarget.State.TargetVersion.LastMessage.Success("unpinned")
+	entityTarget.addEvent(TargetEventUnpinned, entityTarget.State.TargetVersion.Version, "unpinned")
+
+	if err := e.saveEntityTarget(entityTarget); err != nil {
+		return nil, err
+	}
+	return returnClientTarget(entityTarget), nil
+}
+
+func (n *Namespace) pinTarget(entityName, group, name string, pin *TargetPin) (*ClientState, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.pinTarget(group, name, pin)
+}
+
+func (n *Namespace) unpinTarget(entityName, group, name string) (*ClientState, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.unpinTarget(group, name)
+}
+
+// PinTarget pins target to a version, or excludes it from rollouts if pin has no version
+func (e *Engine) PinTarget(namespaceName, entityName, group, name string, pin *TargetPin) (*ClientState, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// rollout must not assign target while it is being pinned
+	unlock, err := e.lockEntity(namespaceName, entityName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return namespace.pinTarget(entityName, group, name, pin)
+}
+
+// UnpinTarget makes pinned or excluded target part of rollouts again
+func (e *Engine) UnpinTarget(namespaceName, entityName, group, name string) (*ClientState, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := e.lockEntity(namespaceName, entityName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return namespace.unpinTarget(entityName, group, name)
+}
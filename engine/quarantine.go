@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// recordTargetFailure increments consecutive failures of the target,
+// once it reaches QuarantineFailureCount target is quarantined and excluded from rollout
+func (r *Rollout) recordTargetFailure(entityTarget *EntityTarget) {
+	entityTarget.State.ConsecutiveFailures++
+	entityTarget.addEvent(TargetEventFailed, entityTarget.State.TargetVersion.Version, entityTarget.State.TargetVersion.LastMessage.Message)
+
+	if entityTarget.State.ConsecutiveFailures == 1 {
+		r.addEvent(Event{
+			Type:    EventTargetFailed,
+			Version: entityTarget.State.CurrentVersion.Version,
+			Group:   entityTarget.Group,
+			Target:  entityTarget.Name,
+			Message: entityTarget.State.CurrentVersion.LastMessage.Message,
+		})
+	}
+
+	if r.State.Options.QuarantineFailureCount <= 0 ||
+		entityTarget.State.ConsecutiveFailures < r.State.Options.QuarantineFailureCount {
+		return
+	}
+
+	entityTarget.State.Quarantined = true
+	entityTarget.State.QuarantineTimestamp = nowUTC()
+	entityTarget.State.TargetVersion.LastMessage.Error(fmt.Sprintf("Quarantined after %d consecutive failures", entityTarget.State.ConsecutiveFailures))
+	entityTarget.addEvent(TargetEventQuarantined, entityTarget.State.TargetVersion.Version, entityTarget.State.TargetVersion.LastMessage.Message)
+
+	r.logger.Warn().
+		Str("EntityTarget", entityTarget.Name).
+		Str("Group", entityTarget.Group).
+		Int("ConsecutiveFailures", entityTarget.State.ConsecutiveFailures).
+		Msg("Target quarantined")
+
+	r.addEvent(Event{
+		Type:    EventTargetQuarantined,
+		Version: entityTarget.State.CurrentVersion.Version,
+		Group:   entityTarget.Group,
+		Target:  entityTarget.Name,
+		Message: entityTarget.State.TargetVersion.LastMessage.Message,
+	})
+}
+
+// recordTargetSuccess resets consecutive failures of the target and records success in its timeline
+func (r *Rollout) recordTargetSuccess(entityTarget *EntityTarget) {
+	entityTarget.State.ConsecutiveFailures = 0
+	entityTarget.State.Retry = nil
+	entityTarget.addEvent(TargetEventSucceeded, entityTarget.State.TargetVersion.Version, entityTarget.State.TargetVersion.LastMessage.Message)
+}
+
+// activeEntityTargets filters out quarantined and pinned targets
+func activeEntityTargets(entityTargets EntityTargets) EntityTargets {
+	var activeTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if entityTarget.State.Quarantined || entityTarget.State.Pin != nil {
+			continue
+		}
+		activeTargets = append(activeTargets, entityTarget)
+	}
+	return activeTargets
+}
+
+// getQuarantinedTargets returns all quarantined targets for the entity
+func (e *Entity) getQuarantinedTargets() ([]*EntityTarget, error) {
+	return e.queryEntityTargets(quarantinedPath, true)
+}
+
+// releaseQuarantinedTarget releases target from quarantine, target is eligible for rollout again
+func (e *Entity) releaseQuarantinedTarget(group, name string) error {
+	entityTarget := &EntityTarget{}
+	if err := e.store.LoadJSON(e.entityTargetKey(group, name), entityTarget); err != nil {
+		return err
+	}
+
+	if !entityTarget.State.Quarantined {
+		return ErrTargetNotQuarantined
+	}
+
+	e.logger.Info().Str("Name", name).Str("Group", group).Msg("Releasing target from quarantine")
+
+	entityTarget.State.Quarantined = false
+	entityTarget.State.ConsecutiveFailures = 0
+	entityTarget.State.QuarantineTimestamp = time.Time{}
+	// restart monitoring window, otherwise target would immediately fail duration timeout
+	entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+	entityTarget.State.TargetVersion.LastMessage.Success("released from quarantine")
+	entityTarget.addEvent(TargetEventReleased, entityTarget.State.TargetVersion.Version, "released from quarantine")
+
+	return e.saveEntityTarget(entityTarget)
+}
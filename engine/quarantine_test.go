@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineTargets(t *testing.T) {
+	e, clientTargets, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	for _, clientTarget := range clientTargets {
+		clientTarget.IsError = true
+		clientTarget.Message = "Simulating Failure"
+	}
+	require.NoError(t, e.updateEntityTargets(clientTargets))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	rollout.State.RollingVersion = "v1"
+	rollout.State.LastKnownGoodVersion = "v1"
+	rollout.State.Options.SuccessTimeoutSecs = 900
+	rollout.State.Options.DurationTimeoutSecs = 0
+	rollout.State.Options.QuarantineFailureCount = 2
+
+	for i := 1; i <= 2; i++ {
+		state := createRolloutInfo(activeEntityTargets(targets))
+		require.NoError(t, rollout.determineCurrentState(state))
+		require.NoError(t, rollout.monitorTargets(state))
+		assert.Len(t, state.failedTargets, len(clientTargets))
+	}
+
+	assert.Empty(t, activeEntityTargets(targets))
+
+	quarantinedTargets, err := e.getQuarantinedTargets()
+	require.NoError(t, err)
+	require.Len(t, quarantinedTargets, len(clientTargets))
+	assert.True(t, quarantinedTargets[0].State.TargetVersion.LastMessage.IsError)
+
+	require.NoError(t, e.releaseQuarantinedTarget("", clientTargets[0].Name))
+
+	quarantinedTargets, err = e.getQuarantinedTargets()
+	require.NoError(t, err)
+	assert.Len(t, quarantinedTargets, len(clientTargets)-1)
+
+	assert.ErrorIs(t, e.releaseQuarantinedTarget("", clientTargets[0].Name), ErrTargetNotQuarantined)
+
+	quarantined, err := e.getFilteredClientState(TargetFilter{State: TargetStateQuarantined})
+	require.NoError(t, err)
+	require.Len(t, quarantined, len(clientTargets)-1)
+	assert.True(t, quarantined[0].Quarantined)
+
+	active, err := e.getFilteredClientState(TargetFilter{State: TargetStateActive})
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, clientTargets[0].Name, active[0].Name)
+	assert.False(t, active[0].Quarantined)
+
+	assert.ErrorIs(t, TargetState("broken").Validate(), ErrInvalidTargetState)
+}
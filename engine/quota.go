@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	quotaPrefix = "quota:"
+	// QuotaWarningHeader is set on responses once namespace usage crosses QuotaWarningPercent
+	QuotaWarningHeader = "X-Orchestrator-Quota-Warning"
+	// QuotaWarningPercent of quota after which warnings are emitted
+	QuotaWarningPercent = 80
+)
+
+// NamespaceQuota limits entities and targets in a namespace, 0 is unlimited
+type NamespaceQuota struct {
+	MaxEntities int `json:"maxentities,omitempty"`
+	MaxTargets  int `json:"maxtargets,omitempty"`
+}
+
+// QuotaUsage is current usage of namespace quota
+type QuotaUsage struct {
+	Entities    int      `json:"entities"`
+	MaxEntities int      `json:"maxentities,omitempty"`
+	Targets     int      `json:"targets"`
+	MaxTargets  int      `json:"maxtargets,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+func namespaceQuotaKey(namespace string) string {
+	return quotaPrefix + namespace
+}
+
+// findNamespaceQuota returns empty quota if not configured
+func findNamespaceQuota(dbStore store.Store, namespace string) (*NamespaceQuota, error) {
+	quota := &NamespaceQuota{}
+	err := dbStore.LoadJSON(namespaceQuotaKey(namespace), quota)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+	return quota, nil
+}
+
+func namespaceEntitiesPrefix(namespace string) string {
+	return fmt.Sprintf("%s%s/", entityPrefix, namespace)
+}
+
+func namespaceTargetsPrefix(namespace string) string {
+	return fmt.Sprintf("%s%s/", entityTargetPrefix, namespace)
+}
+
+// QuotaCounts are counts of namespace entities and targets returned by quota checks of one request,
+// passed to quotaWarnings of the same request so it does not scan namespace again
+type QuotaCounts struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+type quotaCountsKey struct{}
+
+// WithQuotaCounts returns ctx recording counts of quota checks of namespaces found with it in counts
+func WithQuotaCounts(ctx context.Context) (context.Context, *QuotaCounts) {
+	counts := &QuotaCounts{}
+	return context.WithValue(ctx, quotaCountsKey{}, counts), counts
+}
+
+// quotaCountsFrom returns counts of request ctx, nil if quota checks are not recorded
+func quotaCountsFrom(ctx context.Context) *QuotaCounts {
+	counts, _ := ctx.Value(quotaCountsKey{}).(*QuotaCounts)
+	return counts
+}
+
+// set records count of prefix, counts of 0 were not made
+func (c *QuotaCounts) set(prefix string, count int) {
+	if c == nil || count <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[prefix] = count
+}
+
+func (c *QuotaCounts) get(prefix string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	count, ok := c.counts[prefix]
+	return count, ok
+}
+
+func isQuotaWarning(used, max int) bool {
+	return max > 0 && used*100 >= max*QuotaWarningPercent
+}
+
+// checkQuota fails if adding count more would exceed max,
+// returns warning message when adding count more crosses warning threshold
+func checkQuota(resource string, used, count, max int) (string, error) {
+	if max <= 0 {
+		return "", nil
+	}
+
+	if used+count > max {
+		return "", fmt.Errorf("%w: %s %d/%d", ErrQuotaExceeded, resource, used, max)
+	}
+
+	if !isQuotaWarning(used, max) && isQuotaWarning(used+count, max) {
+		return fmt.Sprintf("%s quota at %d/%d", resource, used+count, max), nil
+	}
+
+	return "", nil
+}
+
+func quotaWarningEvent(namespace, entity, message string) Event {
+	return Event{
+		Type:      EventQuotaWarning,
+		Namespace: namespace,
+		Entity:    entity,
+		Message:   message,
+		Timestamp: nowUTC(),
+	}
+}
+
+// setQuota saves quota for the namespace
+func (n *Namespace) setQuota(quota *NamespaceQuota) error {
+	n.logger.Info().Int("MaxEntities", quota.MaxEntities).Int("MaxTargets", quota.MaxTargets).Msg("Set NamespaceQuota")
+	return n.store.SaveJSON(namespaceQuotaKey(n.Name), quota)
+}
+
+// countQuota counts keys of prefix, reusing count of quota check of the same request if any
+func (n *Namespace) countQuota(prefix string, counts *QuotaCounts) (int, error) {
+	if count, ok := counts.get(prefix); ok {
+		return count, nil
+	}
+	count, err := n.store.Count(prefix)
+	return int(count), err
+}
+
+// quotaUsage returns usage and warnings of quota, resources without limits are only counted if all is set
+func (n *Namespace) quotaUsage(quota *NamespaceQuota, all bool, counts *QuotaCounts) (*QuotaUsage, error) {
+	usage := &QuotaUsage{MaxEntities: quota.MaxEntities, MaxTargets: quota.MaxTargets}
+
+	var err error
+	if all || quota.MaxEntities > 0 {
+		if usage.Entities, err = n.countQuota(namespaceEntitiesPrefix(n.Name), counts); err != nil {
+			return nil, err
+		}
+	}
+	if all || quota.MaxTargets > 0 {
+		if usage.Targets, err = n.countQuota(namespaceTargetsPrefix(n.Name), counts); err != nil {
+			return nil, err
+		}
+	}
+
+	if isQuotaWarning(usage.Entities, usage.MaxEntities) {
+		usage.Warnings = append(usage.Warnings, fmt.Sprintf("entities quota at %d/%d", usage.Entities, usage.MaxEntities))
+	}
+	if isQuotaWarning(usage.Targets, usage.MaxTargets) {
+		usage.Warnings = append(usage.Warnings, fmt.Sprintf("targets quota at %d/%d", usage.Targets, usage.MaxTargets))
+	}
+
+	return usage, nil
+}
+
+// getQuotaUsage returns current usage and warnings for the namespace
+func (n *Namespace) getQuotaUsage() (*QuotaUsage, error) {
+	quota, err := findNamespaceQuota(n.store, n.Name)
+	if err != nil {
+		return nil, err
+	}
+	return n.quotaUsage(quota, true, nil)
+}
+
+// quotaWarnings returns warnings for the namespace using counts of quota checks of the same request,
+// namespaces without limits are not counted
+func (n *Namespace) quotaWarnings(counts *QuotaCounts) ([]string, error) {
+	quota, err := findNamespaceQuota(n.store, n.Name)
+	if err != nil || (quota.MaxEntities <= 0 && quota.MaxTargets <= 0) {
+		return nil, err
+	}
+
+	usage, err := n.quotaUsage(quota, false, counts)
+	if err != nil {
+		return nil, err
+	}
+	return usage.Warnings, nil
+}
+
+// checkEntityQuota is called before creating a new entity in namespace, returns count of entities
+// including the new one, 0 if entities are not limited
+func (n *Namespace) checkEntityQuota(name string) (int, error) {
+	quota, err := findNamespaceQuota(n.store, n.Name)
+	if err != nil || quota.MaxEntities <= 0 {
+		return 0, err
+	}
+
+	entities, err := n.store.Count(namespaceEntitiesPrefix(n.Name))
+	if err != nil {
+		return 0, err
+	}
+
+	warning, err := checkQuota("entities", int(entities), 1, quota.MaxEntities)
+	if err != nil {
+		return 0, err
+	}
+
+	if warning != "" {
+		n.newEntity(name).warnQuota(warning)
+	}
+
+	return int(entities) + 1, nil
+}
+
+// checkTargetQuota is called before creating count new targets in namespace, returns count of targets
+// including the new ones, 0 if targets are not limited
+func (e *Entity) checkTargetQuota(count int) (int, error) {
+	quota, err := findNamespaceQuota(e.store, e.Namespace)
+	if err != nil || quota.MaxTargets <= 0 {
+		return 0, err
+	}
+
+	targets, err := e.store.Count(namespaceTargetsPrefix(e.Namespace))
+	if err != nil {
+		return 0, err
+	}
+
+	warning, err := checkQuota("targets", int(targets), count, quota.MaxTargets)
+	if err != nil {
+		return 0, err
+	}
+
+	if warning != "" {
+		e.warnQuota(warning)
+	}
+
+	return int(targets) + count, nil
+}
+
+// warnQuota publishes and notifies quota warning of entity
+func (e *Entity) warnQuota(warning string) {
+	e.logger.Warn().Msg(warning)
+	event := quotaWarningEvent(e.Namespace, e.Name, warning)
+	e.events.Publish(event)
+	e.notify([]Event{event})
+}
+
+// QuotaWarning returns warnings to be set in QuotaWarningHeader, empty if namespace is below warning threshold,
+// counts are those of quota checks of the same request, nil if none were recorded
+func (e *Engine) QuotaWarning(namespaceName string, counts *QuotaCounts) string {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return ""
+	}
+	warnings, err := namespace.quotaWarnings(counts)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(warnings, ", ")
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckQuota(t *testing.T) {
+	warning, err := checkQuota("targets", 5, 1, 0)
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+
+	warning, err = checkQuota("targets", 6, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+
+	warning, err = checkQuota("targets", 7, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "targets quota at 8/10", warning)
+
+	// warned once when crossing threshold
+	warning, err = checkQuota("targets", 8, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+
+	// single warning when several targets cross threshold
+	warning, err = checkQuota("targets", 5, 4, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "targets quota at 9/10", warning)
+
+	_, err = checkQuota("targets", 10, 1, 10)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = checkQuota("targets", 8, 3, 10)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	const testName = "TestNamespaceQuota"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetNamespaceQuota(testName, &NamespaceQuota{MaxEntities: 1, MaxTargets: 5}))
+
+	var warnings []Event
+	unsubscribe := engine.events.Subscribe(func(event Event) {
+		if event.Type == EventQuotaWarning && event.Namespace == testName {
+			warnings = append(warnings, event)
+		}
+	})
+	defer unsubscribe()
+
+	_, err = setupNamespace(engine, testName, testName, 4)
+	require.NoError(t, err)
+
+	usage, err := engine.GetQuotaUsage(testName)
+	require.NoError(t, err)
+	assert.Equal(t, 1, usage.Entities)
+	assert.Equal(t, 4, usage.Targets)
+	assert.Len(t, usage.Warnings, 2)
+	assert.Len(t, warnings, 2)
+	assert.NotEmpty(t, engine.QuotaWarning(testName, nil))
+
+	// warnings reuse counts of quota checks of the same request only
+	ctx, counts := WithQuotaCounts(context.Background())
+	_, err = engine.OrchestrateContext(ctx, testName, testName, []*ClientState{{Name: "target4", Version: "v1"}})
+	require.NoError(t, err)
+	count, ok := counts.get(namespaceTargetsPrefix(testName))
+	assert.True(t, ok)
+	assert.Equal(t, 5, count)
+	counts.set(namespaceTargetsPrefix(testName), 1)
+	assert.Equal(t, "entities quota at 1/1", engine.QuotaWarning(testName, counts))
+	assert.Equal(t, "entities quota at 1/1, targets quota at 5/5", engine.QuotaWarning(testName, nil))
+
+	// namespaces without limits are not counted
+	require.NoError(t, engine.SetNamespaceQuota(testName, &NamespaceQuota{}))
+	assert.Empty(t, engine.QuotaWarning(testName, nil))
+	usage, err = engine.GetQuotaUsage(testName)
+	require.NoError(t, err)
+	assert.Equal(t, 5, usage.Targets)
+	require.NoError(t, engine.SetNamespaceQuota(testName, &NamespaceQuota{MaxEntities: 1, MaxTargets: 5}))
+
+	_, err = engine.Orchestrate(testName, "another", []*ClientState{{Name: "target", Version: "v1"}})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	_, err = engine.Orchestrate(testName, testName, []*ClientState{{Name: "target4", Version: "v1"}, {Name: "target5", Version: "v1"}})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+)
+
+const (
+	reconcilerNamespace = "reconciler"
+	reconcilerEntity    = "entity"
+)
+
+// Reconciler exposes batch selection and monitoring decisions for custom control loops,
+// caller owns rollout state and target state between calls, no server or persistent store is required
+type Reconciler interface {
+	// Reconcile applies reports to targets, runs a single orchestration pass
+	// and returns updated state, targets and actions to apply
+	Reconcile(ctx context.Context, state *RolloutState, targets EntityTargets, reports []*ClientState) (*Reconciliation, error)
+	// Close releases resources held by reconciler
+	Close() error
+}
+
+type ReconcileActionType string
+
+const (
+	// ActionAssignVersion target should switch to assigned version
+	ActionAssignVersion ReconcileActionType = "AssignVersion"
+	// ActionRemoveTarget target was removed by target controller
+	ActionRemoveTarget ReconcileActionType = "RemoveTarget"
+)
+
+// ReconcileAction is a decision for a single target
+type ReconcileAction struct {
+	Type    ReconcileActionType `json:"type,omitempty"`
+	Name    string              `json:"name,omitempty"`
+	Group   string              `json:"group,omitempty"`
+	Version string              `json:"version,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// Reconciliation is result of a single reconcile pass, state and targets are input to next pass
+type Reconciliation struct {
+	State   *RolloutState      `json:"state,omitempty"`
+	Targets EntityTargets      `json:"targets,omitempty"`
+	Actions []*ReconcileAction `json:"actions,omitempty"`
+}
+
+// storeReconciler runs rollout decisions over an ephemeral in-memory store
+type storeReconciler struct {
+	lock                 sync.Mutex
+	store                store.Store
+	entity               *Entity
+	targetController     EntityTargetController
+	monitoringController EntityMonitoringController
+}
+
+// NewReconciler creates reconciler, nil controllers default to no-op controllers
+func NewReconciler(logger zerolog.Logger, targetController EntityTargetController, monitoringController EntityMonitoringController) (Reconciler, error) {
+	dbStore, err := store.NewBadgerDBStore("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	if targetController == nil {
+		targetController = &NoOpEntityTargetController{}
+	}
+	if monitoringController == nil {
+		monitoringController = &NoOpEntityMonitoringController{}
+	}
+
+	return &storeReconciler{
+		store: dbStore,
+		entity: &Entity{
+			Name:      reconcilerEntity,
+			Namespace: reconcilerNamespace,
+			store:     dbStore,
+			logger:    logger.With().Str("Namespace", reconcilerNamespace).Str("Entity", reconcilerEntity).Logger(),
+		},
+		targetController:     targetController,
+		monitoringController: monitoringController,
+	}, nil
+}
+
+// reset replaces stored rollout and targets with caller state
+func (s *storeReconciler) reset(state *RolloutState, targets EntityTargets) error {
+	existing, err := s.entity.getEntityTargets()
+	if err != nil {
+		return err
+	}
+	for _, entityTarget := range existing {
+		if err := s.store.Delete(s.entity.entityTargetKey(entityTarget.Group, entityTarget.Name)); err != nil {
+			return err
+		}
+	}
+
+	rolloutState := RolloutState{}
+	if state != nil {
+		rolloutState = *state
+	}
+	// copy options, caller state is never modified
+	options := DefaultRolloutOptions()
+	if rolloutState.Options != nil {
+		*options = *rolloutState.Options
+	}
+	rolloutState.Options = options
+
+	rollout := &Rollout{
+		State:                rolloutState,
+		TargetController:     SerializedEntityTargetController{EntityTargetController: s.targetController},
+		MonitoringController: SerializedEntityMonitoringController{EntityMonitoringController: s.monitoringController},
+	}
+	if err := s.store.SaveJSON(s.entity.rolloutKey(), rollout); err != nil {
+		return err
+	}
+
+	for _, entityTarget := range targets {
+		if err := s.store.SaveJSON(s.entity.entityTargetKey(entityTarget.Group, entityTarget.Name), entityTarget); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *storeReconciler) Reconcile(ctx context.Context, state *RolloutState, targets EntityTargets, reports []*ClientState) (*Reconciliation, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.reset(state, targets); err != nil {
+		return nil, err
+	}
+
+	for _, clientTarget := range reports {
+		entityTarget, err := s.entity.findOrCreateEntityTarget(clientTarget)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.entity.updateEntityTarget(clientTarget, entityTarget); err != nil {
+			return nil, err
+		}
+	}
+
+	before, err := s.entity.getEntityTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.entity.rolloutOrchestrate(ctx); err != nil {
+		return nil, err
+	}
+
+	rolloutState, err := s.entity.getRolloutInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := s.entity.getEntityTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reconciliation{
+		State:   rolloutState,
+		Targets: after,
+		Actions: reconcileActions(before, after),
+	}, nil
+}
+
+func (s *storeReconciler) Close() error {
+	return s.store.Close()
+}
+
+// reconcileActions compares targets before and after orchestration
+func reconcileActions(before, after EntityTargets) []*ReconcileAction {
+	afterTargets := make(map[string]*EntityTarget, len(after))
+	for _, entityTarget := range after {
+		afterTargets[entityTarget.Group+"/"+entityTarget.Name] = entityTarget
+	}
+
+	actions := []*ReconcileAction{}
+	for _, previous := range before {
+		current, ok := afterTargets[previous.Group+"/"+previous.Name]
+		if !ok {
+			actions = append(actions, &ReconcileAction{
+				Type:  ActionRemoveTarget,
+				Name:  previous.Name,
+				Group: previous.Group,
+			})
+			continue
+		}
+
+		if current.State.TargetVersion.Version != previous.State.TargetVersion.Version {
+			actions = append(actions, &ReconcileAction{
+				Type:    ActionAssignVersion,
+				Name:    current.Name,
+				Group:   current.Group,
+				Version: current.State.TargetVersion.Version,
+				Message: current.State.TargetVersion.LastMessage.Message,
+			})
+		}
+	}
+	return actions
+}
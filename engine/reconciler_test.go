@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler(t *testing.T) {
+	reconciler, err := NewReconciler(getLogger(), nil, nil)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, reconciler.Close())
+	}()
+
+	var reports []*ClientState
+	for i := 0; i < 10; i++ {
+		reports = append(reports, &ClientState{
+			Name:    fmt.Sprintf("clientTarget%d", i),
+			Version: "v1",
+			Message: "running successfully",
+		})
+	}
+
+	options := DefaultRolloutOptions()
+	options.BatchPercent = 20
+	state := &RolloutState{
+		RolloutVersionInfo: RolloutVersionInfo{TargetVersion: "v2", LastKnownGoodVersion: "v1"},
+		Options:            options,
+	}
+
+	result, err := reconciler.Reconcile(context.Background(), state, nil, reports)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", result.State.RollingVersion)
+	assert.Len(t, result.Targets, 10)
+	require.Len(t, result.Actions, 2)
+	for _, action := range result.Actions {
+		assert.Equal(t, ActionAssignVersion, action.Type)
+		assert.Equal(t, "v2", action.Version)
+	}
+	// caller state is not modified
+	assert.Empty(t, state.RollingVersion)
+
+	// batch is in progress, nothing new to assign until monitoring completes
+	result, err = reconciler.Reconcile(context.Background(), result.State, result.Targets, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actions)
+	assert.Len(t, result.Targets, 10)
+
+	_, err = reconciler.Reconcile(context.Background(), nil, nil, nil)
+	assert.Error(t, err)
+}
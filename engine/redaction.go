@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nixmade/orchestrator/redact"
+)
+
+const (
+	redactionPrefix = "redaction:"
+)
+
+func namespaceRedactionKey(namespace string) string {
+	return redactionPrefix + namespace
+}
+
+// setRedactionRules saves and applies log redaction rules for records of the namespace
+func (n *Namespace) setRedactionRules(rules *redact.Rules) error {
+	if err := redact.Default.SetNamespaceRules(n.Name, *rules); err != nil {
+		return err
+	}
+
+	n.logger.Info().Int("Fields", len(rules.Fields)).Int("Patterns", len(rules.Patterns)).Msg("Set redaction rules")
+	return n.store.SaveJSON(namespaceRedactionKey(n.Name), rules)
+}
+
+// loadRedactionRules applies persisted namespace redaction rules on startup
+func (e *Engine) loadRedactionRules() error {
+	redactionItr := func(key any, value any) error {
+		rules := &redact.Rules{}
+		if err := json.Unmarshal([]byte(value.(string)), rules); err != nil {
+			return err
+		}
+		return redact.Default.SetNamespaceRules(strings.TrimPrefix(key.(string), redactionPrefix), *rules)
+	}
+
+	return e.store.LoadValues(redactionPrefix, redactionItr)
+}
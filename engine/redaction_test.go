@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/nixmade/orchestrator/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceRedaction(t *testing.T) {
+	const testName = "TestNamespaceRedaction"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.Error(t, engine.SetNamespaceRedaction(testName, &redact.Rules{Patterns: []string{"("}}))
+	require.NoError(t, engine.SetNamespaceRedaction(testName, &redact.Rules{Fields: []string{"Message"}}))
+
+	record := redact.Default.Record(map[string]any{"Namespace": testName, "Message": "sensitive"})
+	assert.Equal(t, redact.Redacted, record["Message"])
+
+	// rules are reloaded on startup
+	require.NoError(t, redact.Default.SetNamespaceRules(testName, redact.Rules{}))
+	require.NoError(t, engine.Load())
+	record = redact.Default.Record(map[string]any{"Namespace": testName, "Message": "sensitive"})
+	assert.Equal(t, redact.Redacted, record["Message"])
+
+	require.NoError(t, engine.DeleteNamespace(testName))
+	record = redact.Default.Record(map[string]any{"Namespace": testName, "Message": "sensitive"})
+	assert.Equal(t, "sensitive", record["Message"])
+}
@@ -0,0 +1,559 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	registryWatchPrefix = "registrywatch:"
+	// registryWatchCheckInterval is how often leader looks for watches due for a poll
+	registryWatchCheckInterval = 30 * time.Second
+	defaultRegistryWatchSecs   = 300
+	minRegistryWatchSecs       = 30
+	registryTimeout            = 30 * time.Second
+	// maxRegistryTagPages limits pagination of tags of repositories with many thousands of tags
+	maxRegistryTagPages = 100
+
+	defaultRegistry = "https://registry-1.docker.io"
+	// defaultRegistryTagPattern matches semver tags with optional v prefix and pre-release
+	defaultRegistryTagPattern = `^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`
+)
+
+// RegistryWatch polls a docker/OCI registry for new tags of a repository and sets the newest as target version
+type RegistryWatch struct {
+	// Registry url, defaults to docker hub https://registry-1.docker.io
+	Registry string `json:"registry,omitempty"`
+	// Repository of image, e.g. nixmade/myapp, docker hub official images may omit library/
+	Repository string `json:"repository,omitempty"`
+	// Pattern is regular expression tags must match, defaults to semver tags like v1.2.3
+	Pattern string `json:"pattern,omitempty"`
+	// Username and Password authenticate with registry or its token service, password may be a token
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// IntervalSecs between polls, defaults to 300, at least 30
+	IntervalSecs int `json:"intervalsecs,omitempty"`
+	// RequireApproval holds new tags as pending until approved instead of setting them as target version
+	RequireApproval bool `json:"requireapproval,omitempty"`
+
+	Status RegistryWatchStatus `json:"status"`
+}
+
+// RegistryWatchStatus is outcome of last poll, updated by watcher
+type RegistryWatchStatus struct {
+	// LastTag is last tag set as target version
+	LastTag string `json:"lasttag,omitempty"`
+	// PendingTag is newest tag waiting for approval
+	PendingTag string    `json:"pendingtag,omitempty"`
+	LastCheck  time.Time `json:"lastcheck,omitempty"`
+	LastError  string    `json:"lasterror,omitempty"`
+}
+
+// RegistryTagApproval approves pending tag of registry watch
+type RegistryTagApproval struct {
+	Tag string `json:"tag"`
+}
+
+func (w *RegistryWatch) Validate() error {
+	if w.Repository == "" {
+		return fmt.Errorf("%w: repository is required", ErrInvalidRegistryWatch)
+	}
+	if w.Registry != "" {
+		if _, err := url.ParseRequestURI(w.Registry); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidRegistryWatch, err)
+		}
+	}
+	if _, err := regexp.Compile(w.pattern()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRegistryWatch, err)
+	}
+	if w.IntervalSecs != 0 && w.IntervalSecs < minRegistryWatchSecs {
+		return fmt.Errorf("%w: intervalsecs must be at least %d", ErrInvalidRegistryWatch, minRegistryWatchSecs)
+	}
+	return nil
+}
+
+func (w *RegistryWatch) pattern() string {
+	if w.Pattern == "" {
+		return defaultRegistryTagPattern
+	}
+	return w.Pattern
+}
+
+func (w *RegistryWatch) interval() time.Duration {
+	if w.IntervalSecs <= 0 {
+		return defaultRegistryWatchSecs * time.Second
+	}
+	return time.Duration(w.IntervalSecs) * time.Second
+}
+
+// due returns true if watch was not polled within its interval
+func (w *RegistryWatch) due(now time.Time) bool {
+	return now.Sub(w.Status.LastCheck) >= w.interval()
+}
+
+// Redacted returns copy of watch without registry password
+func (w *RegistryWatch) Redacted() *RegistryWatch {
+	watch := *w
+	if watch.Password != "" {
+		watch.Password = RedactedSecret
+	}
+	return &watch
+}
+
+// repositoryPath returns repository in registry, docker hub keeps official images under library/
+func (w *RegistryWatch) repositoryPath() (registry, repository string) {
+	registry, repository = strings.TrimSuffix(w.Registry, "/"), w.Repository
+	if registry == "" {
+		registry = defaultRegistry
+	}
+	if registry == defaultRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository
+}
+
+// parseTagVersion parses semver like tags into numeric parts and pre-release, ok is false for other tags
+func parseTagVersion(tag string) (parts []int, prerelease string, ok bool) {
+	version, prerelease, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "-")
+	for _, field := range strings.Split(version, ".") {
+		part, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, "", false
+		}
+		parts = append(parts, part)
+	}
+	return parts, prerelease, true
+}
+
+// compareTags orders semver like tags numerically with pre-releases before releases, other tags lexically
+func compareTags(a, b string) int {
+	aParts, aPre, aOk := parseTagVersion(a)
+	bParts, bPre, bOk := parseTagVersion(b)
+	if !aOk || !bOk {
+		return strings.Compare(a, b)
+	}
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart != bPart {
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return strings.Compare(aPre, bPre)
+}
+
+// newestTag returns newest tag matching pattern, empty if none match
+func newestTag(tags []string, pattern *regexp.Regexp) string {
+	newest := ""
+	for _, tag := range tags {
+		if !pattern.MatchString(tag) {
+			continue
+		}
+		if newest == "" || compareTags(tag, newest) > 0 {
+			newest = tag
+		}
+	}
+	return newest
+}
+
+// registryChallengeParam matches parameters of WWW-Authenticate header, quoted values may contain commas
+var registryChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryChallenge parses WWW-Authenticate header, e.g. Bearer realm="...",service="...",scope="..."
+func registryChallenge(header string) (scheme string, params map[string]string) {
+	scheme, rest, _ := strings.Cut(header, " ")
+	params = make(map[string]string)
+	for _, match := range registryChallengeParam.FindAllStringSubmatch(rest, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	return strings.ToLower(scheme), params
+}
+
+// registryClient lists tags with anonymous, basic or token service authentication, whichever registry asks for
+type registryClient struct {
+	watch         *RegistryWatch
+	authorization string
+}
+
+// token exchanges credentials for bearer token at token service of challenge
+func (c *registryClient) token(ctx context.Context, params map[string]string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid registry token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.watch.Username != "" || c.watch.Password != "" {
+		req.SetBasicAuth(c.watch.Username, c.watch.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token service returned %d", resp.StatusCode)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return token.Token, nil
+}
+
+// get requests url, answering an authentication challenge once
+func (c *registryClient) get(ctx context.Context, endpoint string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+
+		scheme, params := registryChallenge(resp.Header.Get("WWW-Authenticate"))
+		resp.Body.Close()
+		switch scheme {
+		case "bearer":
+			token, err := c.token(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			c.authorization = "Bearer " + token
+		case "basic":
+			req.SetBasicAuth(c.watch.Username, c.watch.Password)
+			c.authorization = req.Header.Get("Authorization")
+		default:
+			return nil, fmt.Errorf("unsupported registry authentication %q", scheme)
+		}
+	}
+}
+
+// nextLink returns next page of Link header, e.g. </v2/app/tags/list?last=v1&n=1000>; rel="next"
+func nextLink(base *url.URL, header string) string {
+	link, _, ok := strings.Cut(header, ";")
+	if !ok || !strings.Contains(header, `rel="next"`) {
+		return ""
+	}
+	next, err := base.Parse(strings.Trim(strings.TrimSpace(link), "<>"))
+	if err != nil {
+		return ""
+	}
+	return next.String()
+}
+
+// listTags returns all tags of repository
+func (c *registryClient) listTags(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, registryTimeout)
+	defer cancel()
+
+	registry, repository := c.watch.repositoryPath()
+	endpoint := fmt.Sprintf("%s/v2/%s/tags/list?n=1000", registry, repository)
+	var tags []string
+	for page := 0; endpoint != "" && page < maxRegistryTagPages; page++ {
+		base, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.get(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry returned %d for %s: %s", resp.StatusCode, repository, strings.TrimSpace(string(data)))
+		}
+
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, list.Tags...)
+		endpoint = nextLink(base, resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+func (e *Entity) registryWatchKey() string {
+	return fmt.Sprintf("%s%s/%s", registryWatchPrefix, e.Namespace, e.Name)
+}
+
+// findRegistryWatch returns nil watch if entity has none
+func (e *Entity) findRegistryWatch() (*RegistryWatch, error) {
+	watch := &RegistryWatch{}
+	err := e.store.LoadJSON(e.registryWatchKey(), watch)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return watch, nil
+}
+
+// setRegistryWatch saves watch keeping status of previous watch of the same repository, nil watch removes it
+func (e *Entity) setRegistryWatch(watch *RegistryWatch) error {
+	if watch == nil {
+		e.logger.Info().Msg("Removing RegistryWatch")
+		return e.store.Delete(e.registryWatchKey())
+	}
+	if err := watch.Validate(); err != nil {
+		return err
+	}
+	existing, err := e.findRegistryWatch()
+	if err != nil {
+		return err
+	}
+	watch.Status = RegistryWatchStatus{}
+	if existing != nil && existing.Registry == watch.Registry && existing.Repository == watch.Repository {
+		watch.Status.LastTag = existing.Status.LastTag
+	}
+	e.logger.Info().Str("Registry", watch.Registry).Str("Repository", watch.Repository).Str("Pattern", watch.pattern()).Msg("Set RegistryWatch")
+	return e.store.SaveJSON(e.registryWatchKey(), watch)
+}
+
+// promoteTag sets tag as target version
+func (e *Entity) promoteTag(watch *RegistryWatch, tag string) error {
+	e.logger.Info().Str("Repository", watch.Repository).Str("Tag", tag).Msg("Setting new registry tag as target version")
+	if err := e.setTargetVersion(EntityTargetVersion{Version: tag, ChangeInfo: ChangeInfo{Note: fmt.Sprintf("new tag of %s", watch.Repository)}}, false); err != nil {
+		return err
+	}
+	watch.Status.LastTag = tag
+	watch.Status.PendingTag = ""
+	return nil
+}
+
+// checkRegistryWatch polls registry and sets newest tag newer than last tag or target version as target version,
+// or holds it as pending tag if watch requires approval
+func (e *Entity) checkRegistryWatch(ctx context.Context, watch *RegistryWatch) error {
+	watch.Status.LastCheck = nowUTC()
+	watch.Status.LastError = ""
+
+	tags, err := (&registryClient{watch: watch}).listTags(ctx)
+	if err != nil {
+		watch.Status.LastError = err.Error()
+		e.logger.Error().Err(err).Str("Repository", watch.Repository).Msg("Failed to list registry tags")
+		return e.store.SaveJSON(e.registryWatchKey(), watch)
+	}
+
+	newest := newestTag(tags, regexp.MustCompile(watch.pattern()))
+	current := watch.Status.LastTag
+	if current == "" {
+		rollout, err := e.findOrCreateRollout()
+		if err != nil {
+			return err
+		}
+		current = rollout.State.TargetVersion
+	}
+
+	switch {
+	case newest == "" || (current != "" && compareTags(newest, current) <= 0):
+	case watch.RequireApproval:
+		if watch.Status.PendingTag != newest {
+			e.logger.Info().Str("Repository", watch.Repository).Str("Tag", newest).Msg("New registry tag waiting for approval")
+			watch.Status.PendingTag = newest
+		}
+	default:
+		if err := e.promoteTag(watch, newest); err != nil {
+			watch.Status.LastError = err.Error()
+		}
+	}
+	return e.store.SaveJSON(e.registryWatchKey(), watch)
+}
+
+// approveRegistryTag sets pending tag as target version
+func (e *Entity) approveRegistryTag(tag string) error {
+	watch, err := e.findRegistryWatch()
+	if err != nil {
+		return err
+	}
+	if watch == nil || watch.Status.PendingTag == "" || watch.Status.PendingTag != tag {
+		return fmt.Errorf("%w: %s", ErrRegistryTagNotPending, tag)
+	}
+	if err := e.promoteTag(watch, tag); err != nil {
+		return err
+	}
+	return e.store.SaveJSON(e.registryWatchKey(), watch)
+}
+
+func (n *Namespace) setRegistryWatch(entityName string, watch *RegistryWatch) error {
+	entity, err := n.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.setRegistryWatch(watch)
+}
+
+func (n *Namespace) getRegistryWatch(entityName string) (*RegistryWatch, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.findRegistryWatch()
+}
+
+// runRegistryWatches is background job polling registries of watches due for a poll
+func (e *Engine) runRegistryWatches(ctx context.Context) {
+	ticker := time.NewTicker(registryWatchCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.checkRegistryWatches(ctx, nowUTC()); err != nil {
+				e.logger.Error().Err(err).Msg("failed to check registry watches")
+			}
+		}
+	}
+}
+
+// checkRegistryWatches polls registries of watches not polled within their interval, a failing watch
+// does not hold up others
+func (e *Engine) checkRegistryWatches(ctx context.Context, now time.Time) error {
+	var due []string
+	err := e.store.LoadValues(registryWatchPrefix, func(key, value any) error {
+		watch := &RegistryWatch{}
+		if err := json.Unmarshal([]byte(value.(string)), watch); err != nil {
+			return err
+		}
+		if watch.due(now) {
+			due = append(due, strings.TrimPrefix(key.(string), registryWatchPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range due {
+		namespaceName, entityName, _ := strings.Cut(key, "/")
+		if err := e.checkRegistryWatch(ctx, namespaceName, entityName); err != nil {
+			e.logger.Error().Err(err).Str("Namespace", namespaceName).Str("Entity", entityName).Msg("failed to check registry watch")
+		}
+	}
+	return nil
+}
+
+// checkRegistryWatch polls registry of entity holding entity lock, target version is set like through the api
+func (e *Engine) checkRegistryWatch(ctx context.Context, namespaceName, entityName string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+	entity, err := namespace.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := e.lockEntity(namespaceName, entityName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// watch may have changed or been removed since it was found due
+	watch, err := entity.findRegistryWatch()
+	if err != nil || watch == nil {
+		return err
+	}
+	return entity.checkRegistryWatch(ctx, watch)
+}
+
+// SetRegistryWatch sets registry watch of entity, nil watch removes it
+func (e *Engine) SetRegistryWatch(namespaceName, entityName string, watch *RegistryWatch) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setRegistryWatch(entityName, watch)
+}
+
+// GetRegistryWatch returns registry watch of entity with status of last poll, nil if not configured
+func (e *Engine) GetRegistryWatch(namespaceName, entityName string) (*RegistryWatch, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getRegistryWatch(entityName)
+}
+
+// ApproveRegistryTag sets tag pending approval of registry watch as target version
+func (e *Engine) ApproveRegistryTag(namespaceName, entityName, tag string) error {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+	entity, err := namespace.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := e.lockEntity(namespaceName, entityName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return entity.approveRegistryTag(tag)
+}
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry serves tags of myapp in two pages behind a token service accepting user/pass
+type fakeRegistry struct {
+	sync.Mutex
+	*httptest.Server
+	tags []string
+}
+
+func (f *fakeRegistry) setTags(tags ...string) {
+	f.Lock()
+	defer f.Unlock()
+	f.tags = tags
+}
+
+func newFakeRegistry(t *testing.T, tags ...string) *fakeRegistry {
+	registry := &fakeRegistry{tags: tags}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "registry", r.URL.Query().Get("service"))
+		assert.Equal(t, "repository:myapp:pull,push", r.URL.Query().Get("scope"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "regtoken"})
+	})
+	mux.HandleFunc("/v2/myapp/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer regtoken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="registry",scope="repository:myapp:pull,push"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		registry.Lock()
+		tags := registry.tags
+		registry.Unlock()
+		if r.URL.Query().Get("last") == "" && len(tags) > 2 {
+			w.Header().Set("Link", `</v2/myapp/tags/list?last=`+tags[1]+`&n=2>; rel="next"`)
+			tags = tags[:2]
+		} else if r.URL.Query().Get("last") != "" {
+			tags = tags[2:]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": "myapp", "tags": tags})
+	})
+	registry.Server = httptest.NewServer(mux)
+	return registry
+}
+
+func TestCompareTags(t *testing.T) {
+	assert.Equal(t, 1, compareTags("v1.10.0", "v1.9.0"))
+	assert.Equal(t, -1, compareTags("1.2.3", "v1.2.4"))
+	assert.Equal(t, 0, compareTags("v1.2.3", "1.2.3"))
+	assert.Equal(t, -1, compareTags("v1.2.3-rc1", "v1.2.3"))
+	assert.Equal(t, -1, compareTags("v1.2.3-rc1", "v1.2.3-rc2"))
+	assert.Equal(t, 1, compareTags("v2", "v1.9.9"))
+	assert.Equal(t, 1, compareTags("main-b", "main-a"))
+
+	pattern := regexp.MustCompile(defaultRegistryTagPattern)
+	assert.Equal(t, "v1.10.0", newestTag([]string{"latest", "v1.9.0", "v1.10.0", "v1.11.0-rc1", "v1.10.0-rc1"}, regexp.MustCompile(`^v\d+\.\d+\.\d+$`)))
+	assert.Equal(t, "v1.11.0-rc1", newestTag([]string{"latest", "v1.9.0", "v1.10.0", "v1.11.0-rc1"}, pattern))
+	assert.Equal(t, "", newestTag([]string{"latest", "main"}, pattern))
+}
+
+func TestRegistryWatchValidate(t *testing.T) {
+	assert.ErrorIs(t, (&RegistryWatch{}).Validate(), ErrInvalidRegistryWatch)
+	assert.ErrorIs(t, (&RegistryWatch{Repository: "myapp", Pattern: "("}).Validate(), ErrInvalidRegistryWatch)
+	assert.ErrorIs(t, (&RegistryWatch{Repository: "myapp", IntervalSecs: 5}).Validate(), ErrInvalidRegistryWatch)
+	assert.ErrorIs(t, (&RegistryWatch{Repository: "myapp", Registry: "registry"}).Validate(), ErrInvalidRegistryWatch)
+	assert.NoError(t, (&RegistryWatch{Repository: "myapp"}).Validate())
+
+	registry, repository := (&RegistryWatch{Repository: "nginx"}).repositoryPath()
+	assert.Equal(t, defaultRegistry, registry)
+	assert.Equal(t, "library/nginx", repository)
+	_, repository = (&RegistryWatch{Registry: "https://ghcr.io/", Repository: "nginx"}).repositoryPath()
+	assert.Equal(t, "nginx", repository)
+}
+
+func TestRegistryWatchSetsTargetVersion(t *testing.T) {
+	const testName = "TestRegistryWatchSetsTargetVersion"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	registry := newFakeRegistry(t, "latest", "v1.0.0", "v1.2.0", "v1.1.0")
+	defer registry.Close()
+
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1.0.0"}))
+	require.NoError(t, engine.SetRegistryWatch(testName, testName, &RegistryWatch{
+		Registry: registry.URL, Repository: "myapp", Pattern: `^v\d+\.\d+\.\d+$`, Username: "user", Password: "pass",
+	}))
+
+	ctx := context.Background()
+	require.NoError(t, engine.checkRegistryWatches(ctx, nowUTC()))
+	rollout, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.0", rollout.TargetVersion)
+
+	watch, err := engine.GetRegistryWatch(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.0", watch.Status.LastTag)
+	assert.Empty(t, watch.Status.LastError)
+
+	// not due again until interval passed
+	registry.setTags("v1.3.0")
+	require.NoError(t, engine.checkRegistryWatches(ctx, nowUTC()))
+	rollout, err = engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.0", rollout.TargetVersion)
+
+	require.NoError(t, engine.checkRegistryWatches(ctx, nowUTC().Add(defaultRegistryWatchSecs*time.Second)))
+	rollout, err = engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.3.0", rollout.TargetVersion)
+
+	// registry errors are recorded in status
+	require.NoError(t, engine.SetRegistryWatch(testName, testName, &RegistryWatch{Registry: registry.URL, Repository: "myapp", Username: "user", Password: "wrong"}))
+	require.NoError(t, engine.checkRegistryWatches(ctx, nowUTC()))
+	watch, err = engine.GetRegistryWatch(testName, testName)
+	require.NoError(t, err)
+	assert.Contains(t, watch.Status.LastError, "401")
+	assert.Equal(t, "v1.3.0", watch.Status.LastTag)
+}
+
+func TestRegistryWatchApproval(t *testing.T) {
+	const testName = "TestRegistryWatchApproval"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	registry := newFakeRegistry(t, "v1.0.0", "v1.1.0")
+	defer registry.Close()
+
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1.0.0"}))
+	watch := &RegistryWatch{Registry: registry.URL, Repository: "myapp", Username: "user", Password: "pass", RequireApproval: true}
+	require.NoError(t, engine.SetRegistryWatch(testName, testName, watch))
+
+	require.NoError(t, engine.checkRegistryWatches(context.Background(), nowUTC()))
+	rollout, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", rollout.TargetVersion)
+
+	stored, err := engine.GetRegistryWatch(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", stored.Status.PendingTag)
+
+	assert.ErrorIs(t, engine.ApproveRegistryTag(testName, testName, "v1.0.0"), ErrRegistryTagNotPending)
+	require.NoError(t, engine.ApproveRegistryTag(testName, testName, "v1.1.0"))
+	rollout, err = engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", rollout.TargetVersion)
+
+	stored, err = engine.GetRegistryWatch(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", stored.Status.LastTag)
+	assert.Empty(t, stored.Status.PendingTag)
+	assert.ErrorIs(t, engine.ApproveRegistryTag(testName, testName, "v1.1.0"), ErrRegistryTagNotPending)
+}
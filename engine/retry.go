@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// TargetRetry tracks retries of a target which failed rolling version
+type TargetRetry struct {
+	// Version of rollout retried, retries reset with next rolling version
+	Version string `json:"version,omitempty"`
+	// Count of retries of the version
+	Count int `json:"count,omitempty"`
+	// RetryAfter is when target may be reselected for rolling version
+	RetryAfter time.Time `json:"retryafter,omitempty"`
+}
+
+// retryBackoff returns wait before retry, RetryBackoffSecs doubled with every retry
+func (o *RolloutOptions) retryBackoff(count int) time.Duration {
+	if o.RetryBackoffSecs <= 0 || count <= 0 {
+		return 0
+	}
+	return time.Duration(o.RetryBackoffSecs) * time.Second << (count - 1)
+}
+
+// retryTarget moves failed target back to last known good version to be reselected in a later batch,
+// returns false once FailedTargetRetries are exhausted and target counts as failed
+func (r *Rollout) retryTarget(entityTarget *EntityTarget, message string) bool {
+	options := r.options()
+	if options.FailedTargetRetries <= 0 || !r.rolloutInProgress() || r.State.LastKnownGoodVersion == "" {
+		return false
+	}
+
+	retry := entityTarget.State.Retry
+	if retry == nil || retry.Version != r.State.RollingVersion {
+		retry = &TargetRetry{Version: r.State.RollingVersion}
+	}
+	if retry.Count >= options.FailedTargetRetries {
+		return false
+	}
+
+	retry.Count++
+	retry.RetryAfter = nowUTC().Add(options.retryBackoff(retry.Count))
+	entityTarget.State.Retry = retry
+
+	r.logger.Warn().Str("EntityTarget", entityTarget.Name).Str("Group", entityTarget.Group).
+		Int("Retry", retry.Count).Time("RetryAfter", retry.RetryAfter).Str("Reason", message).Msg("Retrying failed target")
+
+	entityTarget.State.TargetVersion.Version = r.State.LastKnownGoodVersion
+	entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+	entityTarget.State.TargetVersion.LastMessage.Error(fmt.Sprintf("retry %d of %d after %s: %s",
+		retry.Count, options.FailedTargetRetries, retry.RetryAfter.Format(time.RFC3339), message))
+	entityTarget.addEvent(TargetEventRetried, r.State.RollingVersion, entityTarget.State.TargetVersion.LastMessage.Message)
+	return true
+}
+
+// failTarget retries target failing monitoring if allowed, otherwise marks it failed
+func (r *Rollout) failTarget(state *rolloutInfo, entityTarget *EntityTarget, message string) error {
+	if !r.retryTarget(entityTarget, message) {
+		state.failedTargets = addEntityTarget(state.failedTargets, entityTarget)
+		entityTarget.State.TargetVersion.LastMessage.Error(message)
+		r.recordTargetFailure(entityTarget)
+	}
+	if err := r.entity.saveEntityTarget(entityTarget); err != nil {
+		return err
+	}
+	state.inRolloutTargets = removeEntityTarget(state.inRolloutTargets, entityTarget)
+	return nil
+}
+
+// filterRetryTargets holds retried targets of a forward rollout until their retry backoff passed
+func (r *Rollout) filterRetryTargets(state *rolloutInfo) {
+	if !r.rolloutInProgress() {
+		return
+	}
+
+	now := nowUTC()
+	var availableTargets EntityTargets
+	for _, entityTarget := range state.availableTargets {
+		retry := entityTarget.State.Retry
+		if retry != nil && retry.Version == r.State.RollingVersion && now.Before(retry.RetryAfter) {
+			r.logger.Debug().Str("EntityTarget", entityTarget.Name).Time("RetryAfter", retry.RetryAfter).Msg("Holding target until retry backoff passed")
+			continue
+		}
+		availableTargets = append(availableTargets, entityTarget)
+	}
+	state.availableTargets = availableTargets
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryFailedTarget(t *testing.T) {
+	options := DefaultRolloutOptions()
+	options.FailedTargetRetries = 2
+	options.RetryBackoffSecs = 60
+	rollout := &Rollout{State: RolloutState{
+		RolloutVersionInfo: RolloutVersionInfo{RollingVersion: "v2", LastKnownGoodVersion: "v1"},
+		Options:            options,
+	}}
+
+	entityTarget := &EntityTarget{Name: "target"}
+	entityTarget.State.TargetVersion = EntityVersionInfo{Version: "v2"}
+	entityTarget.State.CurrentVersion = EntityVersionInfo{Version: "v2", LastMessage: Message{IsError: true}}
+
+	start := nowUTC()
+	require.True(t, rollout.retryTarget(entityTarget, "agent error"))
+	require.NotNil(t, entityTarget.State.Retry)
+	assert.Equal(t, 1, entityTarget.State.Retry.Count)
+	assert.Equal(t, "v1", entityTarget.State.TargetVersion.Version)
+	assert.True(t, entityTarget.State.TargetVersion.LastMessage.IsError)
+	assert.WithinDuration(t, start.Add(time.Minute), entityTarget.State.Retry.RetryAfter, 5*time.Second)
+	assert.Zero(t, entityTarget.State.ConsecutiveFailures)
+
+	// retried target is held until backoff passed
+	state := &rolloutInfo{availableTargets: EntityTargets{entityTarget}}
+	rollout.filterRetryTargets(state)
+	assert.Empty(t, state.availableTargets)
+
+	entityTarget.State.Retry.RetryAfter = start.Add(-time.Second)
+	state.availableTargets = EntityTargets{entityTarget}
+	rollout.filterRetryTargets(state)
+	assert.Len(t, state.availableTargets, 1)
+
+	// backoff doubles with every retry, retries are bounded
+	require.True(t, rollout.retryTarget(entityTarget, "agent error"))
+	assert.Equal(t, 2, entityTarget.State.Retry.Count)
+	assert.WithinDuration(t, start.Add(2*time.Minute), entityTarget.State.Retry.RetryAfter, 5*time.Second)
+	assert.False(t, rollout.retryTarget(entityTarget, "agent error"))
+
+	// retries reset with next rolling version
+	rollout.State.RollingVersion = "v3"
+	assert.True(t, rollout.retryTarget(entityTarget, "agent error"))
+	assert.Equal(t, "v3", entityTarget.State.Retry.Version)
+	assert.Equal(t, 1, entityTarget.State.Retry.Count)
+
+	rollout.recordTargetSuccess(entityTarget)
+	assert.Nil(t, entityTarget.State.Retry)
+
+	// rollbacks are not retried
+	rollout.State.LastKnownBadVersion = "v3"
+	assert.False(t, rollout.retryTarget(entityTarget, "agent error"))
+
+	assert.ErrorIs(t, (&RolloutOptions{BatchPercent: 10, FailedTargetRetries: -1}).Validate(), ErrInvalidRolloutOptions)
+	assert.ErrorIs(t, (&RolloutOptions{BatchPercent: 10, RetryBackoffSecs: 30}).Validate(), ErrInvalidRolloutOptions)
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"time"
+)
+
+// RingState tracks progress of ring based rollout for rolling version
+type RingState struct {
+	// Version being rolled out through rings
+	Version string `json:"version,omitempty"`
+	// Index of current ring in GroupOrder
+	Index int `json:"index,omitempty"`
+	// BakeTimestamp is when current ring reached success, next ring starts after bake time
+	BakeTimestamp time.Time `json:"baketimestamp,omitempty"`
+}
+
+// ringIndex returns ring index of the group, groups not part of GroupOrder belong to last ring
+func (r *Rollout) ringIndex(group string) int {
+	for i, ring := range r.State.Options.GroupOrder {
+		if ring == group {
+			return i
+		}
+	}
+	return len(r.State.Options.GroupOrder) - 1
+}
+
+// isRingSuccessful checks if targets in ring reached SuccessPercent
+func (r *Rollout) isRingSuccessful(state *rolloutInfo, index int) bool {
+	ringTargets := 0
+	for _, entityTarget := range state.totalTargets {
+		if r.ringIndex(entityTarget.Group) == index {
+			ringTargets++
+		}
+	}
+
+	successTargets := 0
+	for _, entityTarget := range state.successTargets {
+		if r.ringIndex(entityTarget.Group) == index {
+			successTargets++
+		}
+	}
+
+	successThreshold := int((r.State.Options.SuccessPercent * ringTargets) / 100)
+
+	return successTargets >= successThreshold
+}
+
+// filterRingTargets promotes rings sequentially, available targets are restricted to rings up to current ring
+// ring N+1 is promoted only after ring N reaches SuccessPercent and bake time has passed
+func (r *Rollout) filterRingTargets(state *rolloutInfo) {
+	groupOrder := r.State.Options.GroupOrder
+	if len(groupOrder) <= 0 {
+		return
+	}
+
+	rollingVersion := r.State.RollingVersion
+	if rollingVersion == r.State.LastKnownGoodVersion || rollingVersion == r.State.LastKnownBadVersion {
+		// rings are not used for setting lkg or rolling back
+		return
+	}
+
+	if r.State.Ring.Version != rollingVersion {
+		r.logger.Info().Str("Ring", groupOrder[0]).Msg("Starting ring based rollout")
+		r.State.Ring = RingState{Version: rollingVersion}
+	}
+
+	bakeTime := time.Duration(r.options().GroupBakeTimeSecs) * time.Second
+	for r.State.Ring.Index < len(groupOrder)-1 {
+		if !r.isRingSuccessful(state, r.State.Ring.Index) {
+			break
+		}
+
+		if r.State.Ring.BakeTimestamp.IsZero() {
+			r.logger.Info().Str("Ring", groupOrder[r.State.Ring.Index]).Msg("Ring successful, baking before promoting next ring")
+			r.State.Ring.BakeTimestamp = nowUTC()
+		}
+
+		if timeSince(r.State.Ring.BakeTimestamp) < bakeTime {
+			break
+		}
+
+		r.State.Ring.Index++
+		r.State.Ring.BakeTimestamp = time.Time{}
+		r.logger.Info().Str("Ring", groupOrder[r.State.Ring.Index]).Msg("Promoting to next ring")
+	}
+
+	var ringTargets EntityTargets
+	for _, entityTarget := range state.availableTargets {
+		if r.ringIndex(entityTarget.Group) <= r.State.Ring.Index {
+			ringTargets = append(ringTargets, entityTarget)
+		}
+	}
+
+	r.logger.Info().Str("Ring", groupOrder[r.State.Ring.Index]).Int("AvailableTargets", len(ringTargets)).Msg("Restricting available targets to ring")
+
+	state.availableTargets = ringTargets
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterRingTargets(t *testing.T) {
+	e, err := createEntity("TestFilterRingTargets", getLogger())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	var clientTargets []*ClientState
+	for _, group := range []string{"ring0", "ring1", "ring2"} {
+		for i := 0; i < 2; i++ {
+			clientTargets = append(clientTargets, &ClientState{
+				Name:    fmt.Sprintf("clientTarget%d", i),
+				Group:   group,
+				Version: "v1",
+				Message: "running successfully",
+			})
+		}
+	}
+	require.NoError(t, e.updateEntityTargets(clientTargets))
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Group < targets[j].Group
+	})
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	rollout.State.RollingVersion = "v2"
+	rollout.State.LastKnownGoodVersion = "v1"
+	rollout.State.Options.GroupOrder = []string{"ring0", "ring1"}
+	rollout.State.Options.GroupBakeTimeSecs = 3600
+
+	state := createRolloutInfo(targets)
+	state.availableTargets = targets
+	rollout.filterRingTargets(state)
+
+	require.Len(t, state.availableTargets, 2)
+	for _, entityTarget := range state.availableTargets {
+		assert.Equal(t, "ring0", entityTarget.Group)
+	}
+
+	// ring0 successful, but still baking
+	state = createRolloutInfo(targets)
+	state.availableTargets = targets[2:]
+	state.successTargets = targets[:2]
+	rollout.filterRingTargets(state)
+
+	assert.Empty(t, state.availableTargets)
+	assert.Equal(t, 0, rollout.State.Ring.Index)
+	assert.False(t, rollout.State.Ring.BakeTimestamp.IsZero())
+
+	// bake time passed, ring1 gets promoted, ring2 is not listed so it is part of last ring
+	rollout.State.Options.GroupBakeTimeSecs = 0
+	state = createRolloutInfo(targets)
+	state.availableTargets = targets[2:]
+	state.successTargets = targets[:2]
+	rollout.filterRingTargets(state)
+
+	assert.Len(t, state.availableTargets, 4)
+	assert.Equal(t, 1, rollout.State.Ring.Index)
+
+	// new rolling version restarts from first ring
+	rollout.State.RollingVersion = "v3"
+	state = createRolloutInfo(targets)
+	state.availableTargets = targets
+	rollout.filterRingTargets(state)
+
+	assert.Len(t, state.availableTargets, 2)
+	assert.Equal(t, "v3", rollout.State.Ring.Version)
+}
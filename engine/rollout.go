@@ -0,0 +1,911 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/tracing"
+	"github.com/rs/zerolog"
+)
+
+// Rollout object stores current state
+type Rollout struct {
+	State                RolloutState                         `json:"state,omitempty"`
+	TargetController     SerializedEntityTargetController     `json:"targetcontroller,omitempty"`
+	MonitoringController SerializedEntityMonitoringController `json:"monitoringcontroller,omitempty"`
+	entity               *Entity                              `json:"-"`
+	logger               zerolog.Logger                       `json:"-"`
+	lock                 sync.Mutex                           `json:"-"`
+	events               []Event                              `json:"-"`
+}
+
+// RolloutState is state that needs to be serialized to storage
+type RolloutState struct {
+	RolloutVersionInfo `json:",inline"`
+	Options            *RolloutOptions `json:"options,omitempty"`
+	// HistoryID of the rollout history record for current rolling version
+	HistoryID string `json:"historyid,omitempty"`
+	// CanaryResults of last canary analysis
+	CanaryResults []CanaryResult `json:"canaryresults,omitempty"`
+	// Ring progress when GroupOrder is set
+	Ring RingState `json:"ring,omitempty"`
+	// TargetChange is change ticket and note of target version
+	TargetChange ChangeInfo `json:"targetchange,omitempty"`
+	// RollingChange is change ticket and note of rolling version
+	RollingChange ChangeInfo `json:"rollingchange,omitempty"`
+	// QueuedVersions waiting for in progress rollout when ConcurrencyPolicy is queue
+	QueuedVersions []EntityTargetVersion `json:"queuedversions,omitempty"`
+	// Paused holds rollout progression of all groups
+	Paused bool `json:"paused,omitempty"`
+	// PausedGroups holds rollout progression of listed groups while other groups continue
+	PausedGroups []string `json:"pausedgroups,omitempty"`
+	// SnapshotTimestamp of last recorded fleet snapshot
+	SnapshotTimestamp time.Time `json:"snapshottimestamp,omitempty"`
+	// Schedule holds new target versions until scheduled start and maintenance windows
+	Schedule *RolloutSchedule `json:"schedule,omitempty"`
+	// TargetTimestamp is when target version was set, scheduled start is relative to it
+	TargetTimestamp time.Time `json:"targettimestamp,omitempty"`
+	// BatchTimestamp is when last batch of rolling version started
+	BatchTimestamp time.Time `json:"batchtimestamp,omitempty"`
+	// BatchStage is current stage of BatchPlan for rolling version
+	BatchStage int `json:"batchstage,omitempty"`
+	// Split holds targets on several versions with desired percentages instead of rolling out target version
+	Split []VersionWeight `json:"split,omitempty"`
+	// SplitFailed are versions dropped from split after failing monitoring, their targets move to remaining versions
+	SplitFailed []string `json:"splitfailed,omitempty"`
+	// AnalysisTimestamp is when last canary analysis of rolling version ran
+	AnalysisTimestamp time.Time `json:"analysistimestamp,omitempty"`
+	// AnalysisOutcome of last canary analysis of rolling version
+	AnalysisOutcome string `json:"analysisoutcome,omitempty"`
+	// LastKnownGoodHistory of versions marked last known good, most recent last
+	LastKnownGoodHistory []VersionMark `json:"lastknowngoodhistory,omitempty"`
+	// LastKnownBadHistory of versions marked last known bad, most recent last
+	LastKnownBadHistory []VersionMark `json:"lastknownbadhistory,omitempty"`
+	// Checkpoint holds healthy targets of earlier batches on bad rolling version with RollbackScopeBatch
+	Checkpoint *RolloutCheckpoint `json:"checkpoint,omitempty"`
+	// ProgressTimestamp is when rollout last made progress
+	ProgressTimestamp time.Time `json:"progresstimestamp,omitempty"`
+	// ProgressKey fingerprints versions and target counts, progress is recorded when it changes
+	ProgressKey string `json:"progresskey,omitempty"`
+	// ControllerError of last failing target selection or approval controller call
+	ControllerError string `json:"controllererror,omitempty"`
+	// Stall is set once rollout made no progress for StallTimeoutSecs
+	Stall *RolloutStall `json:"stall,omitempty"`
+}
+
+type RolloutVersionInfo struct {
+	TargetVersion        string `json:"targetversion,omitempty"`
+	RollingVersion       string `json:"rollingversion,omitempty"`
+	LastKnownGoodVersion string `json:"lastknowngoodversion,omitempty"`
+	LastKnownBadVersion  string `json:"lastknownbadversion,omitempty"`
+}
+
+// rolloutInfo stores target version, targets state
+type rolloutInfo struct {
+	inRolloutTargets EntityTargets
+	availableTargets EntityTargets
+	successTargets   EntityTargets
+	failedTargets    EntityTargets
+	totalTargets     EntityTargets
+	// analysisPending holds next batch until canary analysis of current batch passed
+	analysisPending bool
+}
+
+// RolloutOptions rollout options
+type RolloutOptions struct {
+	// Percentage of targets in rollout
+	BatchPercent int `json:"batchpercent,omitempty"`
+	// Label selector scoping rollout to matching targets, e.g. "region=us-east,tier in (web,api)"
+	TargetSelector string `json:"targetselector,omitempty"`
+	// Cumulative percentages of targets on rolling version per stage, e.g. [1, 5, 25, 100], overrides BatchPercent
+	BatchPlan []int `json:"batchplan,omitempty"`
+	// Percentage of targets successful to mark rollout as success
+	SuccessPercent int `json:"successpercent,omitempty"`
+	// Timeout in secs to have successful monitoring window
+	SuccessTimeoutSecs int `json:"successtimeoutsecs,omitempty"`
+	// Max Duration timeout in secs to wait to have a successful monitoring window
+	DurationTimeoutSecs int `json:"durationtimeoutsecs,omitempty"`
+	// Failed targets tolerated before rollout aborts, targets reporting errors fail right away instead of after DurationTimeoutSecs
+	MaxFailedTargets int `json:"maxfailedtargets,omitempty"`
+	// Percentage of failed targets tolerated before rollout aborts, smaller of MaxFailedTargets and MaxFailedPercent applies
+	MaxFailedPercent int `json:"maxfailedpercent,omitempty"`
+	// Number of consecutive failures after which target is quarantined, 0 disables quarantine
+	QuarantineFailureCount int `json:"quarantinefailurecount,omitempty"`
+	// Metrics compared between canary and baseline targets, rollout fails if canary is worse
+	CanaryMetrics []CanaryMetric `json:"canarymetrics,omitempty"`
+	// Groups promoted sequentially as rings, groups not listed belong to last ring
+	GroupOrder []string `json:"grouporder,omitempty"`
+	// Bake time in secs after a ring is successful before promoting next ring
+	GroupBakeTimeSecs int `json:"groupbaketimesecs,omitempty"`
+	// Minimum secs between starting batches, paces rollout independently of SuccessTimeoutSecs
+	BatchIntervalSecs int `json:"batchintervalsecs,omitempty"`
+	// Directives returned to agents along with assigned versions
+	AgentDirectives *AgentDirectives `json:"agentdirectives,omitempty"`
+	// Timeout in secs without status or heartbeat after which target in rollout fails monitoring, 0 disables
+	HeartbeatTimeoutSecs int `json:"heartbeattimeoutsecs,omitempty"`
+	// Handling of new target version while rollout is in progress, one of replace (default), queue or reject
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencypolicy,omitempty"`
+	// Batch percent and bake times used for expedited versions, defaults to DefaultEmergencyProfile
+	EmergencyProfile *EmergencyProfile `json:"emergencyprofile,omitempty"`
+	// Ordering of versions, semver rejects target versions lower than last known good version unless forced
+	VersionScheme VersionScheme `json:"versionscheme,omitempty"`
+	// Targets rolled back once rolling version is bad, all (default) or batch keeping healthy earlier batches
+	RollbackScope RollbackScope `json:"rollbackscope,omitempty"`
+	// Times a failing target is moved back to last known good version and reselected in a later batch
+	// before it counts as failed, 0 disables retries
+	FailedTargetRetries int `json:"failedtargetretries,omitempty"`
+	// Secs a retried target waits before it is reselected, doubled with every retry
+	RetryBackoffSecs int `json:"retrybackoffsecs,omitempty"`
+	// Secs without rollout progress after which rollout is reported stalled, defaults to 1800
+	StallTimeoutSecs int `json:"stalltimeoutsecs,omitempty"`
+}
+
+func (o RolloutOptions) MarshalZerologObject(e *zerolog.Event) {
+	e.Int("batchpercent", o.BatchPercent).
+		Ints("batchplan", o.BatchPlan).
+		Str("targetselector", o.TargetSelector).
+		Int("successpercent", o.SuccessPercent).
+		Int("successtimeoutsecs", o.SuccessTimeoutSecs).
+		Int("durationtimeoutsecs", o.DurationTimeoutSecs).
+		Int("maxfailedtargets", o.MaxFailedTargets).
+		Int("maxfailedpercent", o.MaxFailedPercent).
+		Int("quarantinefailurecount", o.QuarantineFailureCount).
+		Strs("grouporder", o.GroupOrder).
+		Int("groupbaketimesecs", o.GroupBakeTimeSecs).
+		Int("batchintervalsecs", o.BatchIntervalSecs).
+		Int("heartbeattimeoutsecs", o.HeartbeatTimeoutSecs).
+		Str("concurrencypolicy", string(o.ConcurrencyPolicy)).
+		Str("versionscheme", string(o.VersionScheme)).
+		Str("rollbackscope", string(o.RollbackScope)).
+		Int("stalltimeoutsecs", o.StallTimeoutSecs).
+		Int("failedtargetretries", o.FailedTargetRetries).
+		Int("retrybackoffsecs", o.RetryBackoffSecs)
+}
+
+// DefaultRolloutOptions conservative settings
+func DefaultRolloutOptions() *RolloutOptions {
+	return &RolloutOptions{
+		BatchPercent:        5,
+		SuccessPercent:      100,
+		SuccessTimeoutSecs:  60,
+		DurationTimeoutSecs: 120,
+	}
+}
+
+func createRolloutInfo(targets EntityTargets) *rolloutInfo {
+	return &rolloutInfo{
+		totalTargets: targets,
+	}
+}
+
+func (r *Rollout) setTargetVersion(entityTargetVersion EntityTargetVersion, force bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !force {
+		if err := r.guardDowngrade(entityTargetVersion.Version); err != nil {
+			return err
+		}
+		if queued, err := r.guardTargetVersion(entityTargetVersion); queued || err != nil {
+			return err
+		}
+	}
+
+	targetVersion := entityTargetVersion.Version
+	r.logger.Info().Str("TargetVersion", targetVersion).Str("Ticket", entityTargetVersion.Ticket).Bool("Expedited", entityTargetVersion.Expedited).Msg("Set TargetVersion")
+	if !strings.EqualFold(r.State.TargetVersion, targetVersion) {
+		r.State.TargetTimestamp = nowUTC()
+	}
+	r.State.TargetVersion = targetVersion
+	r.State.TargetChange = entityTargetVersion.ChangeInfo
+	if force && !strings.EqualFold(r.State.RollingVersion, r.State.LastKnownGoodVersion) && !strings.EqualFold(r.State.RollingVersion, targetVersion) {
+		r.setLastKnownBad(r.State.RollingVersion, VersionMarkForced, "")
+	}
+	return nil
+}
+
+// validateLimits checks percentages and durations of options are in range and consistent, zero durations
+// and counts are unset
+func (o *RolloutOptions) validateLimits() error {
+	switch {
+	case o.BatchPercent < 0 || o.BatchPercent > 100:
+		return fmt.Errorf("%w: batchpercent %d must be between 1 and 100", ErrInvalidRolloutOptions, o.BatchPercent)
+	case o.BatchPercent == 0 && len(o.BatchPlan) <= 0:
+		return fmt.Errorf("%w: batchpercent or batchplan is required, no targets would be rolled out", ErrInvalidRolloutOptions)
+	case o.SuccessPercent < 0 || o.SuccessPercent > 100:
+		return fmt.Errorf("%w: successpercent %d must be between 0 and 100", ErrInvalidRolloutOptions, o.SuccessPercent)
+	case o.SuccessTimeoutSecs < 0 || o.DurationTimeoutSecs < 0 || o.GroupBakeTimeSecs < 0 ||
+		o.BatchIntervalSecs < 0 || o.HeartbeatTimeoutSecs < 0 || o.StallTimeoutSecs < 0 || o.RetryBackoffSecs < 0:
+		return fmt.Errorf("%w: timeouts must not be negative", ErrInvalidRolloutOptions)
+	case o.DurationTimeoutSecs > 0 && o.DurationTimeoutSecs < o.SuccessTimeoutSecs:
+		return fmt.Errorf("%w: durationtimeoutsecs %d is less than successtimeoutsecs %d, targets would time out before a successful monitoring window",
+			ErrInvalidRolloutOptions, o.DurationTimeoutSecs, o.SuccessTimeoutSecs)
+	case o.QuarantineFailureCount < 0:
+		return fmt.Errorf("%w: quarantinefailurecount must not be negative", ErrInvalidRolloutOptions)
+	case o.FailedTargetRetries < 0:
+		return fmt.Errorf("%w: failedtargetretries must not be negative", ErrInvalidRolloutOptions)
+	case o.RetryBackoffSecs > 0 && o.FailedTargetRetries <= 0:
+		return fmt.Errorf("%w: retrybackoffsecs requires failedtargetretries", ErrInvalidRolloutOptions)
+	case o.GroupBakeTimeSecs > 0 && len(o.GroupOrder) <= 0:
+		return fmt.Errorf("%w: groupbaketimesecs requires grouporder", ErrInvalidRolloutOptions)
+	}
+	groups := make(map[string]bool, len(o.GroupOrder))
+	for _, group := range o.GroupOrder {
+		if group == "" || groups[group] {
+			return fmt.Errorf("%w: grouporder groups must be unique and not empty, got %q", ErrInvalidRolloutOptions, group)
+		}
+		groups[group] = true
+	}
+	return nil
+}
+
+// Validate checks ranges of percentages, timeouts and counts, concurrency policy, version scheme,
+// rollback scope, emergency profile, batch plan, failure budget and target selector
+func (o *RolloutOptions) Validate() error {
+	if err := o.validateLimits(); err != nil {
+		return err
+	}
+	if err := o.ConcurrencyPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := o.VersionScheme.Validate(); err != nil {
+		return err
+	}
+	if err := o.RollbackScope.Validate(); err != nil {
+		return err
+	}
+	if err := o.EmergencyProfile.Validate(); err != nil {
+		return err
+	}
+	if err := o.validateBatchPlan(); err != nil {
+		return err
+	}
+	if err := o.validateFailureBudget(); err != nil {
+		return err
+	}
+	_, err := parseLabelSelector(o.TargetSelector)
+	return err
+}
+
+func (r *Rollout) setRolloutOptions(options *RolloutOptions) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if options == nil {
+		options = DefaultRolloutOptions()
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	r.logger.Info().EmbedObject(options).Msg("Set RolloutOptions")
+	r.State.Options = options
+	return nil
+}
+
+func (r *Rollout) setTargetController(controller EntityTargetController) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.logger.Info().Msg("Set Rollout TargetController")
+	r.TargetController = SerializedEntityTargetController{EntityTargetController: controller}
+	return nil
+}
+
+func (r *Rollout) setMonitoringController(controller EntityMonitoringController) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.logger.Info().Msg("Set Rollout MonitoringController")
+	r.MonitoringController = SerializedEntityMonitoringController{EntityMonitoringController: controller}
+	return nil
+}
+
+func (r *Rollout) updateLastKnownVersions(state *rolloutInfo) error {
+	successThreshold := int((r.State.Options.SuccessPercent * len(state.totalTargets)) / 100)
+	failureThreshold := len(state.totalTargets) - successThreshold
+
+	if failureThreshold <= 0 {
+		failureThreshold = 1
+	}
+
+	if len(state.failedTargets) >= failureThreshold || r.failureBudgetExceeded(state) {
+		if r.State.RollingVersion != r.State.LastKnownGoodVersion {
+			r.setLastKnownBad(r.State.RollingVersion, VersionMarkRolloutFailed, "")
+		}
+		return nil
+	}
+
+	if len(state.successTargets) < successThreshold {
+		return nil
+	}
+
+	if r.State.RollingVersion != r.State.LastKnownBadVersion {
+		r.setLastKnownGood(r.State.RollingVersion, VersionMarkRolloutSucceeded, "")
+	}
+
+	return nil
+}
+
+func (r *Rollout) updateRollingVersion(state *rolloutInfo) error {
+	successThreshold := int((r.State.Options.SuccessPercent * len(state.totalTargets)) / 100)
+
+	if len(state.successTargets) < successThreshold &&
+		!strings.EqualFold(r.State.RollingVersion, r.State.LastKnownGoodVersion) &&
+		!strings.EqualFold(r.State.RollingVersion, r.State.LastKnownBadVersion) {
+		return nil
+	}
+
+	// Promote next queued version once target version is rolled out
+	r.dequeueTargetVersion()
+
+	if r.versionPending() {
+		if held, err := r.promotionHeld(); held || err != nil {
+			return err
+		}
+	}
+
+	r.logger.Info().Str("RollingVersion", r.State.RollingVersion).Str("TargetVersion", r.State.TargetVersion).Msgf("Updating rolling version to new target version")
+
+	// Update rolling version to latest target version, since current rolling version is successful
+	r.State.RollingVersion = r.State.TargetVersion
+	r.State.RollingChange = r.State.TargetChange
+	r.State.BatchTimestamp = time.Time{}
+	r.State.BatchStage = 0
+	r.State.AnalysisTimestamp = time.Time{}
+	r.State.AnalysisOutcome = ""
+
+	return nil
+}
+
+func (r *Rollout) isStateChanged(state *rolloutInfo) (bool, error) {
+	lastKnownBadVersion := r.State.LastKnownBadVersion
+	// check if any of the versions changed, if so abandon this
+	if err := r.updateLastKnownVersions(state); err != nil {
+		// on any error mark as state changed
+		return true, err
+	}
+
+	if lastKnownBadVersion != r.State.LastKnownBadVersion {
+		// state changed, abandon this call
+		r.logger.Info().Str("PreviousKnownBadVersion", lastKnownBadVersion).Str("LastKnownBadVersion", r.State.LastKnownBadVersion).Msg("State changed for rollout, lastknownbadversion updated")
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (r *Rollout) determineCurrentState(state *rolloutInfo) error {
+	targetVersion := r.State.RollingVersion
+
+	if targetVersion == r.State.LastKnownBadVersion {
+		r.logger.Info().Str("Version", r.State.LastKnownGoodVersion).Msg("Rolling back to version")
+		targetVersion = r.State.LastKnownGoodVersion
+	}
+
+	r.logger.Info().Int("TotalTargets", len(state.totalTargets)).Msg("Determine current rollout state of targets")
+	for _, entityTarget := range state.totalTargets {
+		if entityTarget.State.TargetVersion.Version != targetVersion {
+			state.availableTargets = addEntityTarget(state.availableTargets, entityTarget)
+			continue
+		}
+		state.inRolloutTargets = addEntityTarget(state.inRolloutTargets, entityTarget)
+	}
+
+	r.logger.Info().Int("AvailableTargets", len(state.availableTargets)).Int("InRolloutTargets", len(state.inRolloutTargets)).Send()
+
+	return nil
+}
+
+func removeEntityTarget(entityTargets []*EntityTarget, removeTarget *EntityTarget) EntityTargets {
+	var removedTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if entityTarget.Name == removeTarget.Name && entityTarget.Group == removeTarget.Group {
+			continue
+		}
+		removedTargets = append(removedTargets, entityTarget)
+	}
+	return removedTargets
+}
+
+func removeClientTarget(entityTargets []*EntityTarget, clientTarget *ClientState) EntityTargets {
+	var removedTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if entityTarget.Name == clientTarget.Name && entityTarget.Group == clientTarget.Group {
+			continue
+		}
+		removedTargets = append(removedTargets, entityTarget)
+	}
+	return removedTargets
+}
+
+func addEntityTarget(entityTargets []*EntityTarget, addTarget *EntityTarget) EntityTargets {
+	for _, entityTarget := range entityTargets {
+		if entityTarget.Name == addTarget.Name && entityTarget.Group == addTarget.Group {
+			return entityTargets
+		}
+	}
+	return append(entityTargets, addTarget)
+}
+
+func (r *Rollout) monitorTargets(state *rolloutInfo) error {
+	targetVersion := r.State.RollingVersion
+
+	if targetVersion == r.State.LastKnownBadVersion {
+		targetVersion = r.State.LastKnownGoodVersion
+	}
+
+	r.logger.Info().Int("InRolloutTargets", len(state.inRolloutTargets)).Msg("Checking target external health monitoring")
+
+	if err := r.MonitoringController.ExternalMonitoring(getClientTargets(state.inRolloutTargets)); err != nil {
+		return err
+	}
+
+	unhealthyTargets, err := r.unhealthyTargets(state, targetVersion)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info().Int("InRolloutTargets", len(state.inRolloutTargets)).Msg("Checking inRollout target health")
+
+	for _, entityTarget := range state.inRolloutTargets {
+		// target stopped reporting, treat as monitoring failure
+		if r.heartbeatMissed(entityTarget) {
+			r.logger.Error().Str("EntityTarget", entityTarget.Name).Time("LastSeen", entityTarget.State.LastSeenTimestamp).Msg("failed monitoring, no heartbeat")
+			if err := r.failTarget(state, entityTarget, heartbeatMessage(entityTarget)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// version is probably assigned but target hasnt yet switched version
+		// keep this target in rollout
+		if entityTarget.State.CurrentVersion.Version == targetVersion {
+			// call external target monitoring first, if that says failed, then its failed
+			if err := r.TargetController.TargetMonitoring(getClientTarget(entityTarget)); err != nil {
+				r.logger.Error().Err(err).Str("EntityTarget", entityTarget.Name).Str("Version", targetVersion).Msg("Target failed monitoring")
+				if err := r.failTarget(state, entityTarget, fmt.Sprintf("Monitoring Failed %s", err)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// monitoring controller failed target
+			if message, ok := unhealthyTargets[entityTarget.Group+"/"+entityTarget.Name]; ok {
+				r.logger.Error().Str("EntityTarget", entityTarget.Name).Str("Version", targetVersion).Str("Reason", message).Msg("Target failed external monitoring")
+				if err := r.failTarget(state, entityTarget, fmt.Sprintf("Monitoring Failed %s", message)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// with a failure budget, reported errors count as failures without waiting for DurationTimeoutSecs
+			if entityTarget.State.CurrentVersion.LastMessage.IsError && r.options().hasFailureBudget() {
+				errMessage := fmt.Sprintf("failed monitoring, reported error %s", entityTarget.State.CurrentVersion.LastMessage.Message)
+				r.logger.Error().Str("EntityTarget", entityTarget.Name).Str("Version", targetVersion).Msg("Target reported error")
+				if err := r.failTarget(state, entityTarget, errMessage); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// check for error
+			if !entityTarget.State.CurrentVersion.LastMessage.IsError {
+				duration := timeSince(entityTarget.State.CurrentVersion.LastMessage.Timestamp)
+				lastMessageDuration := timeSince(entityTarget.State.LastUpdatedTimestamp)
+				// if there are no errors, check if success time has passed
+				// also make sure that there was a message in the success time
+				if int(duration.Seconds()) > r.options().SuccessTimeoutSecs &&
+					int(lastMessageDuration.Seconds()) <= int(duration.Seconds()) {
+					successMessage := fmt.Sprintf("monitoring successful, success since %s", entityTarget.State.CurrentVersion.LastMessage.Timestamp)
+					r.logger.Info().Str("EntityTarget", entityTarget.Name).Time("LastMessage", entityTarget.State.CurrentVersion.LastMessage.Timestamp).Msg("monitoring successful")
+					entityTarget.State.TargetVersion.LastMessage.Success(successMessage)
+					r.recordTargetSuccess(entityTarget)
+					if err := r.entity.saveEntityTarget(entityTarget); err != nil {
+						return err
+					}
+					state.successTargets = addEntityTarget(state.successTargets, entityTarget)
+					state.inRolloutTargets = removeEntityTarget(state.inRolloutTargets, entityTarget)
+					continue
+				}
+			}
+		}
+
+		if entityTarget.State.TargetVersion.Version == targetVersion {
+			// if the target never switched, may be there is some issue,
+			// mark as failure after duration sec
+			duration := timeSince(entityTarget.State.TargetVersion.ChangeTimestamp)
+			// check to make sure assigned < duration
+			if int(duration.Seconds()) > r.options().DurationTimeoutSecs {
+				errMessage := fmt.Sprintf("failed monitoring, no success message since %s, last message at %s",
+					entityTarget.State.TargetVersion.ChangeTimestamp, entityTarget.State.CurrentVersion.LastMessage.Timestamp)
+				r.logger.Error().Str("EntityTarget", entityTarget.Name).Time("LastChange", entityTarget.State.TargetVersion.ChangeTimestamp).Time("LastMessage", entityTarget.State.CurrentVersion.LastMessage.Timestamp).Msg("failed monitoring, no success message")
+				if err := r.failTarget(state, entityTarget, errMessage); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Rollout) selectTargets(state *rolloutInfo) error {
+
+	batchSizeCount := r.batchSize(state)
+
+	if batchSizeCount == 0 {
+		batchSizeCount = 1
+	}
+
+	r.logger.Info().Int("InRolloutTargets", len(state.inRolloutTargets)).Int("BatchSize", batchSizeCount).Msg("Current State")
+
+	// If there are targets already in rollout == batchSizeCount return
+	if len(state.inRolloutTargets) >= batchSizeCount {
+		// clear out since we should stop processing at this point
+		state.availableTargets = nil
+		return nil
+	}
+
+	availableSlots := batchSizeCount - len(state.inRolloutTargets)
+
+	if len(state.availableTargets) <= 0 || availableSlots <= 0 {
+		// nothing to select
+		state.availableTargets = nil
+		return nil
+	}
+
+	r.logger.Info().Int("AvailableTargets", len(state.availableTargets)).Int("AvailableSlots", availableSlots).Msg("Calling external target selection")
+
+	availableTargets, err := r.TargetController.TargetSelection(getClientTargets(state.availableTargets), availableSlots)
+	r.recordControllerError(err)
+
+	if err != nil {
+		state.availableTargets = nil
+		return nil
+	}
+
+	var selectedTargets EntityTargets
+	for _, availableTarget := range availableTargets {
+		if availableSlots <= 0 {
+			break
+		}
+		found := false
+		for _, entityTarget := range state.availableTargets {
+			if entityTarget.Name == availableTarget.Name && entityTarget.Group == availableTarget.Group {
+				selectedTargets = append(selectedTargets, entityTarget)
+				found = true
+				availableSlots--
+				break
+			}
+		}
+		if !found {
+			entityTarget, err := r.entity.findOrCreateEntityTarget(availableTarget)
+			if err != nil {
+				return err
+			}
+			// controller may select targets not available for rollout
+			if entityTarget.State.Pin != nil || entityTarget.State.Quarantined {
+				r.logger.Info().Str("EntityTarget", entityTarget.Name).Msg("Skipping pinned or quarantined target selected by controller")
+				continue
+			}
+			selectedTargets = append(selectedTargets, entityTarget)
+		}
+	}
+
+	state.availableTargets = selectedTargets
+
+	return nil
+}
+
+func (r *Rollout) rolloutNewTargets(state *rolloutInfo) error {
+
+	if len(state.availableTargets) <= 0 {
+		r.logger.Info().Msg("No Available targets")
+		return nil
+	}
+
+	targetVersion := r.State.RollingVersion
+	message := fmt.Sprintf("Rollout new version %s", targetVersion)
+	eventType := TargetEventSelected
+
+	if targetVersion == r.State.LastKnownGoodVersion {
+		// set all the targets to lkg and update
+		message = fmt.Sprintf("Setting LKG to version %s", targetVersion)
+	}
+
+	if targetVersion == r.State.LastKnownBadVersion {
+		targetVersion = r.State.LastKnownGoodVersion
+		message = fmt.Sprintf("Rolling back to lkg version %s", targetVersion)
+		eventType = TargetEventRolledBack
+	}
+
+	r.logger.Info().Int("AvailableTargets", len(state.availableTargets)).Msg("Calling external target approval")
+
+	approvedTargets, err := r.TargetController.TargetApproval(getClientTargets(state.availableTargets))
+	r.recordControllerError(err)
+
+	if err != nil {
+		return nil
+	}
+
+	r.logger.Info().Str("TargetVersion", targetVersion).Int("ApprovedTargets", len(approvedTargets)).Msg("Assigning version to approved targets")
+
+	var batchTargets EntityTargets
+	for _, approvedTarget := range approvedTargets {
+		for _, entityTarget := range state.availableTargets {
+			if entityTarget.Name != approvedTarget.Name || entityTarget.Group != approvedTarget.Group {
+				continue
+			}
+
+			if entityTarget.State.TargetVersion.Version != targetVersion {
+				batchTargets = addEntityTarget(batchTargets, entityTarget)
+			}
+		}
+	}
+
+	if len(batchTargets) <= 0 {
+		return nil
+	}
+
+	// journal the batch before assigning, so a crash mid batch could be reconciled on startup
+	if err := r.entity.beginBatch(targetVersion, batchTargets); err != nil {
+		return err
+	}
+
+	r.addEvent(Event{Type: EventBatchStarted, Version: targetVersion, Targets: len(batchTargets)})
+	r.State.BatchTimestamp = nowUTC()
+
+	for _, entityTarget := range batchTargets {
+		r.logger.Debug().Str("TargetVersion", targetVersion).Str("EntityTarget", entityTarget.Name).Msg("Assigning version to entitytarget")
+		entityTarget.State.TargetVersion.Version = targetVersion
+		entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+		entityTarget.State.TargetVersion.LastMessage.Success(message)
+		entityTarget.addEvent(eventType, targetVersion, message)
+	}
+	return r.entity.saveEntityTargets(batchTargets)
+}
+
+func (r *Rollout) setAllLastKnownGood(entityTargets EntityTargets) error {
+	r.logger.Info().Str("RollingVersion", r.State.RollingVersion).Msg("New Entity setting LKG to version")
+	r.setLastKnownGood(r.State.RollingVersion, VersionMarkInitial, "")
+	// This could have been a rollout, but when we dont have something established,
+	// its better to mass assign lkg, could be a scope for improvement later
+	targetVersion := r.State.LastKnownGoodVersion
+	var assignedTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if entityTarget.State.TargetVersion.Version != targetVersion {
+			r.logger.Debug().Str("TargetVersion", targetVersion).Str("EntityTarget", entityTarget.Name).Msg("Assigning version to entitytarget")
+			entityTarget.State.TargetVersion.Version = targetVersion
+			entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+			entityTarget.State.TargetVersion.LastMessage.Success(fmt.Sprintf("New Entity, setting LKG to version %s", targetVersion))
+			entityTarget.addEvent(TargetEventVersionAssigned, targetVersion, entityTarget.State.TargetVersion.LastMessage.Message)
+			assignedTargets = append(assignedTargets, entityTarget)
+		}
+	}
+	return r.entity.saveEntityTargets(assignedTargets)
+}
+
+func (r *Rollout) removeTargets(state *rolloutInfo) error {
+	// if its a forward rollout, remove old targets with amount of new success targets
+	// else if its a rollback, remove new targets with amount of batch size
+
+	batchSizeCount := int(r.options().BatchPercent * len(state.totalTargets) / 100)
+
+	if batchSizeCount <= 0 {
+		batchSizeCount = 1
+	}
+
+	keepVersion := r.State.RollingVersion
+	targetCount := len(state.successTargets) + len(state.failedTargets) - len(state.inRolloutTargets)
+
+	if targetCount > batchSizeCount {
+		targetCount = batchSizeCount
+	}
+
+	if r.State.RollingVersion == r.State.LastKnownBadVersion {
+		r.logger.Info().Msg("Rolling back to LastKnownGoodVersion")
+		keepVersion = r.State.LastKnownGoodVersion
+	}
+
+	if targetCount <= 0 {
+		r.logger.Info().Str("Version", keepVersion).Int("InRolloutTargets", len(state.inRolloutTargets)).Msg("Removing no targets with version since targets are still in rollout")
+		return nil
+	}
+
+	r.logger.Info().Str("Version", keepVersion).Int("RemoveCount", targetCount).Msg("Keeping targets with version, removing targets")
+
+	var removeTargets EntityTargets
+
+	// TODO: Remove targets only part of success/failure
+	for _, entityTarget := range state.totalTargets {
+		if entityTarget.State.CurrentVersion.Version != keepVersion {
+			removeTargets = append(removeTargets, entityTarget)
+		}
+	}
+
+	// No point in calling if there are zero targets to remove
+	if len(removeTargets) <= 0 {
+		return nil
+	}
+
+	removedClientTargets, err := r.TargetController.TargetRemoval(getClientTargets(removeTargets), targetCount)
+
+	if err != nil {
+		return err
+	}
+
+	for _, clientTarget := range removedClientTargets {
+		removeClientTarget(state.totalTargets, clientTarget)
+		removeClientTarget(state.inRolloutTargets, clientTarget)
+		removeClientTarget(state.availableTargets, clientTarget)
+		removeClientTarget(state.successTargets, clientTarget)
+		removeClientTarget(state.failedTargets, clientTarget)
+		if err := r.entity.deleteEntityTarget(clientTarget); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tracePhase runs rollout phase within a span, controllers make external calls as part of the phase span,
+// phase is not run once ctx is done
+func (r *Rollout) tracePhase(ctx context.Context, name string, phase func(*rolloutInfo) error, state *rolloutInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := tracing.Start(ctx, name)
+	defer span.End()
+
+	r.setControllerContext(ctx)
+	err := phase(state)
+	tracing.RecordError(span, err)
+	return err
+}
+
+// setControllerContext sets context on controllers making external calls and rollout on controllers keeping state
+func (r *Rollout) setControllerContext(ctx context.Context) {
+	if controller, ok := r.TargetController.EntityTargetController.(contextController); ok {
+		controller.setContext(ctx)
+	}
+	if controller, ok := r.TargetController.EntityTargetController.(rolloutController); ok {
+		controller.setRollout(r)
+	}
+	if controller, ok := r.MonitoringController.EntityMonitoringController.(contextController); ok {
+		controller.setContext(ctx)
+	}
+}
+
+// Orchestrate performs following actions in a continuous loop
+//   - Determine Current state
+//   - Select from intial set of targets
+//   - Rollout New Version
+//   - Monitoring
+//   - Determine Target State
+func (r *Rollout) orchestrate(ctx context.Context, targets EntityTargets) (err error) {
+	// Lock here instead of individual functions
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	previous := r.State.RolloutVersionInfo
+
+	if len(r.State.Split) > 0 {
+		return r.convergeSplit(r.selectedEntityTargets(activeEntityTargets(targets)))
+	}
+
+	if len(r.State.RollingVersion) <= 0 && len(r.State.TargetVersion) > 0 {
+		if held, err := r.promotionHeld(); held || err != nil {
+			return err
+		}
+	}
+
+	if len(r.State.RollingVersion) <= 0 {
+		r.State.RollingVersion = r.State.TargetVersion
+		r.State.RollingChange = r.State.TargetChange
+	}
+
+	if len(r.State.RollingVersion) <= 0 {
+		// if its empty no point in rolling out
+		return errors.New("empty target version, please set target version before calling orchestrate")
+	}
+
+	// if LKG is empty then set LKG as rolling version thinking this is first time scenario
+	// dont set LKG to all targets, this could help running ops which may start from blank state
+	// if len(r.State.LastKnownGoodVersion) <= 0 {
+	// 	return r.setAllLastKnownGood(targets)
+	// }
+
+	r.logger = r.logger.With().Str("RollingVersion", r.State.RollingVersion).
+		Str("LastKnownGoodVersion", r.State.LastKnownGoodVersion).
+		Str("LastKnownBadVersion", r.State.LastKnownBadVersion).Logger()
+
+	r.logger.Info().Msg("Creating new rollout state")
+
+	// Keep healthy targets of earlier batches on bad rolling version with RollbackScopeBatch
+	r.updateCheckpoint(targets)
+
+	// Create Rollout State, quarantined, pinned, checkpointed and targets outside selector are not part of rollout
+	state := createRolloutInfo(r.withoutCheckpointTargets(r.selectedEntityTargets(activeEntityTargets(targets))))
+
+	// Record metrics, rollout history and publish events once orchestration completes successfully
+	r.events = nil
+	defer func() {
+		if err == nil {
+			r.recordMetrics(previous, state)
+			r.recordProgress(state)
+			r.publishEvents(previous, state)
+			err = r.recordHistory(previous, state)
+		}
+	}()
+
+	r.setControllerContext(ctx)
+
+	// Determine current state
+	if err := r.tracePhase(ctx, "determineCurrentState", r.determineCurrentState, state); err != nil {
+		return err
+	}
+
+	// Monitor already rolled out targets
+	if err := r.tracePhase(ctx, "monitorTargets", r.monitorTargets, state); err != nil {
+		return err
+	}
+
+	// Compare canary targets against baseline, fails rolling version if canary is worse
+	if failed, err := r.analyzeCanary(state); failed || err != nil {
+		return err
+	}
+
+	// Analyze current batch with metric provider, fails rolling version if canary is worse
+	if failed, err := r.runAnalysis(ctx, state); failed || err != nil {
+		return err
+	}
+
+	if ok, err := r.isStateChanged(state); ok {
+		return err
+	}
+
+	// we should get rid of any old targets, otherwise we might be creating new ones unnecessarily
+	if err := r.tracePhase(ctx, "removeTargets", r.removeTargets, state); err != nil {
+		return err
+	}
+
+	// Restrict available targets to current ring
+	r.filterRingTargets(state)
+
+	// Hold targets of paused groups
+	r.filterPausedTargets(state)
+
+	// Hold retried targets until retry backoff passed
+	r.filterRetryTargets(state)
+
+	// Hold new batches outside maintenance windows
+	r.filterScheduledTargets(state)
+
+	// Hold next batch until batch interval passed
+	r.filterPacedTargets(state)
+
+	// Hold next batch until canary analysis passed
+	r.filterAnalysisTargets(state)
+
+	// Select New Targets if allowed
+	if err := r.tracePhase(ctx, "selectTargets", r.selectTargets, state); err != nil {
+		return err
+	}
+
+	// Rollout New Version to targets if any
+	if err := r.tracePhase(ctx, "rolloutNewTargets", r.rolloutNewTargets, state); err != nil {
+		return err
+	}
+
+	if err := r.updateLastKnownVersions(state); err != nil {
+		return err
+	}
+
+	if err := r.updateRollingVersion(state); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,4 +1,4 @@
-package core
+package engine
 
 import (
 	"fmt"
@@ -618,7 +618,7 @@ func TestForceRollingVersion(t *testing.T) {
 	rollout.State.LastKnownGoodVersion = "v0"
 	rollout.State.LastKnownBadVersion = ""
 
-	if err := rollout.setTargetVersion("v2", true); err != nil {
+	if err := rollout.setTargetVersion(EntityTargetVersion{Version: "v2"}, true); err != nil {
 		t.Fatalf("Failed to forceRollingVersion '%s'", err)
 		return
 	}
@@ -643,3 +643,24 @@ func TestForceRollingVersion(t *testing.T) {
 		return
 	}
 }
+
+func TestValidateRolloutOptions(t *testing.T) {
+	assert.NoError(t, DefaultRolloutOptions().Validate())
+	assert.NoError(t, (&RolloutOptions{BatchPercent: 100}).Validate())
+	assert.NoError(t, (&RolloutOptions{BatchPlan: []int{10, 100}, SuccessTimeoutSecs: 60}).Validate())
+	assert.NoError(t, (&RolloutOptions{BatchPercent: 10, GroupOrder: []string{"canary", "prod"}, GroupBakeTimeSecs: 60}).Validate())
+
+	for name, options := range map[string]*RolloutOptions{
+		"batch percent above 100":   {BatchPercent: 101},
+		"negative batch percent":    {BatchPercent: -1},
+		"no batch percent or plan":  {SuccessPercent: 100},
+		"success percent above 100": {BatchPercent: 10, SuccessPercent: 150},
+		"duration below success":    {BatchPercent: 10, SuccessTimeoutSecs: 120, DurationTimeoutSecs: 60},
+		"negative timeout":          {BatchPercent: 10, HeartbeatTimeoutSecs: -1},
+		"negative quarantine":       {BatchPercent: 10, QuarantineFailureCount: -1},
+		"bake time without rings":   {BatchPercent: 10, GroupBakeTimeSecs: 60},
+		"duplicate ring":            {BatchPercent: 10, GroupOrder: []string{"canary", "canary"}},
+	} {
+		assert.ErrorIs(t, options.Validate(), ErrInvalidRolloutOptions, name)
+	}
+}
@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scheduleCheckInterval is how often leader starts rollouts whose scheduled start has passed
+const scheduleCheckInterval = time.Minute
+
+// MaintenanceWindow opens at every occurrence of Cron and stays open for DurationSecs
+type MaintenanceWindow struct {
+	// Cron expression of window start, e.g. "0 22 * * sat" opens window every saturday 22:00
+	Cron         string `json:"cron"`
+	DurationSecs int    `json:"durationsecs"`
+}
+
+// RolloutSchedule holds new target versions until scheduled start, and restricts rollouts to maintenance windows
+type RolloutSchedule struct {
+	// StartTime before which new target version does not start rolling
+	StartTime time.Time `json:"starttime,omitempty"`
+	// Cron expression, new target version starts rolling at first occurrence after it was set
+	Cron string `json:"cron,omitempty"`
+	// Windows new target versions start rolling and batches are assigned in, any time if empty
+	Windows []MaintenanceWindow `json:"windows,omitempty"`
+	// Timezone of cron expressions, defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ScheduleStatus is schedule of entity and target version waiting for it
+type ScheduleStatus struct {
+	Schedule *RolloutSchedule `json:"schedule,omitempty"`
+	// PendingVersion is target version not yet rolling
+	PendingVersion string `json:"pendingversion,omitempty"`
+	// StartsAt is earliest time pending version starts rolling
+	StartsAt *time.Time `json:"startsat,omitempty"`
+	// InWindow is true if a maintenance window is open or schedule has no windows
+	InWindow bool `json:"inwindow"`
+}
+
+func (s *RolloutSchedule) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	if s.Cron != "" {
+		if _, err := parseCron(s.Cron); err != nil {
+			return err
+		}
+	}
+	for _, window := range s.Windows {
+		if _, err := parseCron(window.Cron); err != nil {
+			return err
+		}
+		if window.DurationSecs <= 0 {
+			return fmt.Errorf("%w: window %q must have positive durationsecs", ErrInvalidSchedule, window.Cron)
+		}
+	}
+	return nil
+}
+
+func (s *RolloutSchedule) location() *time.Location {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// inWindow returns true if a maintenance window is open at t or schedule has no windows
+func (s *RolloutSchedule) inWindow(t time.Time) bool {
+	if s == nil || len(s.Windows) <= 0 {
+		return true
+	}
+	t = t.In(s.location())
+	for _, window := range s.Windows {
+		cron, err := parseCron(window.Cron)
+		if err != nil {
+			continue
+		}
+		// window is open if it started within its duration before t
+		start := cron.next(t.Add(-time.Duration(window.DurationSecs) * time.Second))
+		if !start.IsZero() && !start.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindow returns t if a window is open at t, otherwise when next window opens, zero time if never
+func (s *RolloutSchedule) nextWindow(t time.Time) time.Time {
+	if s.inWindow(t) {
+		return t
+	}
+	var next time.Time
+	for _, window := range s.Windows {
+		cron, err := parseCron(window.Cron)
+		if err != nil {
+			continue
+		}
+		start := cron.next(t.In(s.location()))
+		if !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next.UTC()
+}
+
+// startsAt returns earliest time target version set at setAt starts rolling, zero time if never
+func (s *RolloutSchedule) startsAt(setAt time.Time) time.Time {
+	start := s.StartTime.UTC()
+	if s.Cron != "" {
+		cron, err := parseCron(s.Cron)
+		if err != nil {
+			return time.Time{}
+		}
+		scheduled := cron.next(setAt.In(s.location()))
+		if scheduled.IsZero() {
+			return time.Time{}
+		}
+		if scheduled.After(start) {
+			start = scheduled.UTC()
+		}
+	}
+	if start.Before(setAt) {
+		start = setAt
+	}
+	return s.nextWindow(start)
+}
+
+// scheduleAllows returns true if pending target version may start rolling now
+func (r *Rollout) scheduleAllows() bool {
+	schedule := r.State.Schedule
+	if schedule == nil {
+		return true
+	}
+	start := schedule.startsAt(r.State.TargetTimestamp)
+	now := nowUTC()
+	return !start.IsZero() && !start.After(now) && schedule.inWindow(now)
+}
+
+// versionPending returns true if target version is set but not rolling yet
+func (r *Rollout) versionPending() bool {
+	return r.State.TargetVersion != "" && !strings.EqualFold(r.State.TargetVersion, r.State.RollingVersion)
+}
+
+// filterScheduledTargets holds new batches of a forward rollout outside maintenance windows, rollbacks continue
+func (r *Rollout) filterScheduledTargets(state *rolloutInfo) {
+	if !r.rolloutInProgress() || r.State.Schedule.inWindow(nowUTC()) {
+		return
+	}
+
+	r.logger.Info().Int("HeldTargets", len(state.availableTargets)).Msg("Holding targets outside maintenance window")
+	state.availableTargets = nil
+}
+
+func (r *Rollout) setSchedule(schedule *RolloutSchedule) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	r.logger.Info().Interface("Schedule", schedule).Msg("Set rollout schedule")
+	r.State.Schedule = schedule
+	return nil
+}
+
+func (r *Rollout) scheduleStatus() *ScheduleStatus {
+	status := &ScheduleStatus{Schedule: r.State.Schedule, InWindow: r.State.Schedule.inWindow(nowUTC())}
+	if r.versionPending() {
+		status.PendingVersion = r.State.TargetVersion
+		if r.State.Schedule != nil {
+			if start := r.State.Schedule.startsAt(r.State.TargetTimestamp); !start.IsZero() {
+				status.StartsAt = &start
+			}
+		}
+	}
+	return status
+}
+
+func (e *Entity) setSchedule(schedule *RolloutSchedule) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err := rollout.setSchedule(schedule); err != nil {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+func (e *Entity) getSchedule() (*ScheduleStatus, error) {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+	return rollout.scheduleStatus(), nil
+}
+
+func (n *Namespace) setSchedule(entityName string, schedule *RolloutSchedule) error {
+	entity, err := n.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.setSchedule(schedule)
+}
+
+func (n *Namespace) getSchedule(entityName string) (*ScheduleStatus, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getSchedule()
+}
+
+// runScheduledRollouts is background job starting rollouts once their scheduled start has passed,
+// without waiting for next status report
+func (e *Engine) runScheduledRollouts(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.orchestrateScheduled(); err != nil {
+				e.logger.Error().Err(err).Msg("failed to start scheduled rollouts")
+			}
+		}
+	}
+}
+
+// orchestrateScheduled enqueues orchestration of entities whose pending target version may start rolling
+func (e *Engine) orchestrateScheduled() error {
+	var due []string
+	err := e.store.LoadValues(rolloutPrefix, func(key, value any) error {
+		// controllers are not needed to evaluate schedule
+		var stored struct {
+			State RolloutState `json:"state"`
+		}
+		if err := json.Unmarshal([]byte(value.(string)), &stored); err != nil {
+			return err
+		}
+		rollout := &Rollout{State: stored.State}
+		if rollout.State.Schedule != nil && rollout.versionPending() && rollout.scheduleAllows() {
+			due = append(due, strings.TrimPrefix(key.(string), rolloutPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range due {
+		namespaceName, entityName, _ := strings.Cut(key, "/")
+		namespace, err := e.findNamespace(namespaceName)
+		if err != nil {
+			return err
+		}
+		entity, err := namespace.findEntity(entityName)
+		if err != nil {
+			return err
+		}
+
+		e.logger.Info().Str("Namespace", namespaceName).Str("Entity", entityName).Msg("Starting scheduled rollout")
+		err = e.enqueueOrchestration(namespaceName, entityName, func(ctx context.Context) {
+			if err := entity.rolloutOrchestrate(ctx); err != nil {
+				entity.logger.Error().Err(err).Msg("Scheduled rollout orchestrate failed")
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleWindows(t *testing.T) {
+	// saturday 22:00 for 4 hours
+	schedule := &RolloutSchedule{Windows: []MaintenanceWindow{{Cron: "0 22 * * sat", DurationSecs: 4 * 3600}}}
+	require.NoError(t, schedule.Validate())
+
+	friday := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	open := time.Date(2024, time.March, 16, 22, 0, 0, 0, time.UTC)
+	assert.False(t, schedule.inWindow(friday))
+	assert.True(t, schedule.inWindow(open))
+	assert.True(t, schedule.inWindow(open.Add(3*time.Hour)))
+	assert.False(t, schedule.inWindow(open.Add(5*time.Hour)))
+	assert.Equal(t, open, schedule.startsAt(friday))
+	assert.Equal(t, open.Add(time.Hour), schedule.startsAt(open.Add(time.Hour)))
+
+	// start time after window opened
+	schedule.StartTime = open.Add(time.Hour)
+	assert.Equal(t, open.Add(time.Hour), schedule.startsAt(friday))
+
+	// cron start in timezone
+	schedule = &RolloutSchedule{Cron: "0 9 * * *", Timezone: "America/New_York"}
+	require.NoError(t, schedule.Validate())
+	assert.Equal(t, time.Date(2024, time.March, 15, 13, 0, 0, 0, time.UTC), schedule.startsAt(friday))
+
+	assert.ErrorIs(t, (&RolloutSchedule{Timezone: "Nowhere/Nothing"}).Validate(), ErrInvalidSchedule)
+	assert.ErrorIs(t, (&RolloutSchedule{Windows: []MaintenanceWindow{{Cron: "0 22 * * sat"}}}).Validate(), ErrInvalidSchedule)
+}
+
+func TestScheduledRollout(t *testing.T) {
+	const testName = "TestScheduledRollout"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*ClientState{{Name: "clientTarget0", Version: "v1"}, {Name: "clientTarget1", Version: "v1"}}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	startTime := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, engine.SetSchedule(testName, testName, &RolloutSchedule{StartTime: startTime}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+
+	for i := 0; i < 2; i++ {
+		assigned, err := engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+		for _, clientTarget := range assigned {
+			assert.Equal(t, "v1", clientTarget.Version)
+		}
+	}
+
+	status, err := engine.GetSchedule(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", status.PendingVersion)
+	require.NotNil(t, status.StartsAt)
+	assert.Equal(t, startTime, *status.StartsAt)
+	assert.True(t, status.InWindow)
+
+	// scheduled start passed
+	require.NoError(t, engine.SetSchedule(testName, testName, &RolloutSchedule{StartTime: time.Now().UTC().Add(-time.Minute)}))
+	var assigned []*ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	for _, clientTarget := range assigned {
+		assert.Equal(t, "v2", clientTarget.Version)
+	}
+
+	status, err = engine.GetSchedule(testName, testName)
+	require.NoError(t, err)
+	assert.Empty(t, status.PendingVersion)
+	assert.ErrorIs(t, engine.SetSchedule(testName, testName, &RolloutSchedule{Cron: "bad"}), ErrInvalidSchedule)
+}
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type selectorOperator int
+
+const (
+	selectorEquals selectorOperator = iota
+	selectorNotEquals
+	selectorIn
+	selectorNotIn
+	selectorExists
+	selectorNotExists
+)
+
+// labelRequirement is a single requirement of label selector
+type labelRequirement struct {
+	key      string
+	operator selectorOperator
+	values   []string
+}
+
+// labelSelector matches targets whose labels satisfy every requirement
+type labelSelector []labelRequirement
+
+// parseLabelSelector parses comma separated requirements, each one of key=value, key==value, key!=value,
+// key in (a,b), key notin (a,b), key or !key, empty selector matches every target
+func parseLabelSelector(expr string) (labelSelector, error) {
+	var selector labelSelector
+	for _, part := range splitSelector(expr) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("%w: empty requirement in %q", ErrInvalidTargetSelector, expr)
+		}
+		requirement, err := parseLabelRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, requirement)
+	}
+	return selector, nil
+}
+
+// splitSelector splits requirements on commas outside of parentheses
+func splitSelector(expr string) []string {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+func parseLabelRequirement(part string) (labelRequirement, error) {
+	if key, values, ok := parseSetRequirement(part, " notin "); ok {
+		return labelRequirement{key: key, operator: selectorNotIn, values: values}, validateSelectorKey(key, part)
+	}
+	if key, values, ok := parseSetRequirement(part, " in "); ok {
+		return labelRequirement{key: key, operator: selectorIn, values: values}, validateSelectorKey(key, part)
+	}
+	if key, value, ok := strings.Cut(part, "!="); ok {
+		return labelRequirement{key: strings.TrimSpace(key), operator: selectorNotEquals, values: []string{strings.TrimSpace(value)}}, validateSelectorKey(strings.TrimSpace(key), part)
+	}
+	if key, value, ok := strings.Cut(part, "="); ok {
+		value = strings.TrimPrefix(value, "=")
+		return labelRequirement{key: strings.TrimSpace(key), operator: selectorEquals, values: []string{strings.TrimSpace(value)}}, validateSelectorKey(strings.TrimSpace(key), part)
+	}
+	if key, ok := strings.CutPrefix(part, "!"); ok {
+		return labelRequirement{key: strings.TrimSpace(key), operator: selectorNotExists}, validateSelectorKey(strings.TrimSpace(key), part)
+	}
+	return labelRequirement{key: part, operator: selectorExists}, validateSelectorKey(part, part)
+}
+
+// parseSetRequirement parses "key in (a,b)" style requirement for operator
+func parseSetRequirement(part, operator string) (string, []string, bool) {
+	key, set, ok := strings.Cut(part, operator)
+	if !ok {
+		return "", nil, false
+	}
+	set = strings.TrimSpace(set)
+	if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+		return "", nil, false
+	}
+	var values []string
+	for _, value := range strings.Split(set[1:len(set)-1], ",") {
+		values = append(values, strings.TrimSpace(value))
+	}
+	return strings.TrimSpace(key), values, true
+}
+
+func validateSelectorKey(key, part string) error {
+	if key == "" || strings.ContainsAny(key, " =!(),") {
+		return fmt.Errorf("%w: invalid requirement %q", ErrInvalidTargetSelector, part)
+	}
+	return nil
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, exists := labels[r.key]
+	switch r.operator {
+	case selectorEquals:
+		return exists && value == r.values[0]
+	case selectorNotEquals:
+		return !exists || value != r.values[0]
+	case selectorIn:
+		return exists && slices.Contains(r.values, value)
+	case selectorNotIn:
+		return !exists || !slices.Contains(r.values, value)
+	case selectorExists:
+		return exists
+	case selectorNotExists:
+		return !exists
+	}
+	return false
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		if !requirement.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedEntityTargets restricts rollout to targets matching TargetSelector,
+// targets outside selector keep their version and are not part of percentage calculations
+func (r *Rollout) selectedEntityTargets(entityTargets EntityTargets) EntityTargets {
+	if r.State.Options == nil || r.State.Options.TargetSelector == "" {
+		return entityTargets
+	}
+
+	selector, err := parseLabelSelector(r.State.Options.TargetSelector)
+	if err != nil {
+		// validated when options are set
+		r.logger.Error().Err(err).Msg("Invalid target selector, no targets selected")
+		return nil
+	}
+
+	var selectedTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if selector.matches(entityTarget.Labels) {
+			selectedTargets = append(selectedTargets, entityTarget)
+		}
+	}
+	r.logger.Info().Str("TargetSelector", r.State.Options.TargetSelector).Int("SelectedTargets", len(selectedTargets)).Msg("Restricting rollout to selected targets")
+	return selectedTargets
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"region": "us-east", "tier": "web"}
+	tests := []struct {
+		expr    string
+		matches bool
+	}{
+		{"", true},
+		{"region=us-east", true},
+		{"region==us-east", true},
+		{"region=eu-west", false},
+		{"region!=eu-west", true},
+		{"region=us-east, tier=web", true},
+		{"region=us-east,tier=db", false},
+		{"tier in (web,api)", true},
+		{"tier in (db, cache)", false},
+		{"tier notin (db,cache),region", true},
+		{"canary", false},
+		{"!canary", true},
+		{"!region", false},
+	}
+	for _, test := range tests {
+		selector, err := parseLabelSelector(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.matches, selector.matches(labels), test.expr)
+	}
+
+	for _, expr := range []string{"region=us-east,", "=us-east", "!", "tier in (web", "a b"} {
+		_, err := parseLabelSelector(expr)
+		assert.ErrorIs(t, err, ErrInvalidTargetSelector, expr)
+	}
+}
+
+func TestTargetSelectorRollout(t *testing.T) {
+	const testName = "TestTargetSelectorRollout"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*ClientState
+	for i, region := range []string{"us-east", "us-east", "eu-west", "eu-west"} {
+		clientTargets = append(clientTargets, &ClientState{
+			Name:    fmt.Sprintf("clientTarget%d", i),
+			Version: "v1",
+			Labels:  map[string]string{"region": region},
+		})
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100, TargetSelector: "region in (us-east"}), ErrInvalidTargetSelector)
+
+	// 50% of selected targets is one target
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{
+		BatchPercent:        50,
+		SuccessPercent:      100,
+		SuccessTimeoutSecs:  60,
+		DurationTimeoutSecs: 120,
+		TargetSelector:      "region=us-east",
+	}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+
+	var assigned []*ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, countVersion(assigned, "v2"))
+	for _, clientTarget := range assigned {
+		if clientTarget.Version == "v2" {
+			assert.Equal(t, "us-east", clientTarget.Labels["region"])
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nixmade/orchestrator/store"
+	"github.com/rs/zerolog"
+)
+
+// RolloutSimulationRequest is proposed rollout of version with options, nothing is persisted
+type RolloutSimulationRequest struct {
+	// Version rolled out, defaults to target version of rollout
+	Version string `json:"version,omitempty"`
+	// Expedited simulates rollout with emergency profile of options
+	Expedited bool `json:"expedited,omitempty"`
+	// Options proposed for rollout, defaults to current options
+	Options *RolloutOptions `json:"options,omitempty"`
+}
+
+// SimulatedBatch is targets selected together in one batch of simulated rollout
+type SimulatedBatch struct {
+	Batch int `json:"batch"`
+	// Ring is group of GroupOrder batch belongs to, empty without GroupOrder
+	Ring    string         `json:"ring,omitempty"`
+	Targets []*ClientState `json:"targets"`
+	// Percent of rollout targets on version once batch succeeds
+	Percent int `json:"percent"`
+}
+
+// RolloutSimulation is outcome of simulated rollout, every batch is assumed to succeed
+type RolloutSimulation struct {
+	Version string `json:"version"`
+	// TotalTargets of entity, RolloutTargets are part of rollout, others are pinned, quarantined or outside selector
+	TotalTargets   int `json:"totaltargets"`
+	RolloutTargets int `json:"rollouttargets"`
+	// AssignedTargets are already assigned version, they are not part of any batch
+	AssignedTargets int               `json:"assignedtargets"`
+	Batches         []*SimulatedBatch `json:"batches"`
+	// ExternalSelection is true if target controller selects targets with external calls, which are not made
+	// while simulating, batches list targets in store order and the controller may select others
+	ExternalSelection bool `json:"externalselection,omitempty"`
+}
+
+// SimulateRolloutContext runs target selection and batching of a rollout of version with proposed options
+// against current targets, without persisting anything or calling external controllers
+func (e *Engine) SimulateRolloutContext(ctx context.Context, namespaceName, entityName string, request *RolloutSimulationRequest) (*RolloutSimulation, error) {
+	namespace, err := e.findNamespaceContext(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := namespace.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.simulateRollout(request)
+}
+
+// localSelection is true for target controllers selecting targets without external calls
+func localSelection(controller EntityTargetController) bool {
+	switch controller.(type) {
+	case nil, *NoOpEntityTargetController, *HashCohortTargetController:
+		return true
+	}
+	return false
+}
+
+func (e *Entity) simulateRollout(request *RolloutSimulationRequest) (*RolloutSimulation, error) {
+	rollout := &Rollout{}
+	if err := e.store.LoadJSON(e.rolloutKey(), rollout); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return nil, err
+	}
+	rollout.entity = e
+	rollout.logger = zerolog.Nop()
+
+	version := request.Version
+	if version == "" {
+		version = rollout.State.TargetVersion
+	}
+	if version == "" {
+		return nil, fmt.Errorf("%w: version is required, entity has no target version", ErrInvalidTargetVersion)
+	}
+
+	options := request.Options
+	if options == nil {
+		options = rollout.State.Options
+	}
+	if options == nil {
+		options = DefaultRolloutOptions()
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// fresh rollout of version, state is not saved
+	rollout.State.Options = options
+	rollout.State.TargetVersion = version
+	rollout.State.RollingVersion = version
+	rollout.State.RollingChange = ChangeInfo{Expedited: request.Expedited}
+	rollout.State.BatchStage = 0
+
+	entityTargets, err := e.getEntityTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	state := createRolloutInfo(rollout.selectedEntityTargets(activeEntityTargets(entityTargets)))
+	simulation := &RolloutSimulation{
+		Version:           version,
+		TotalTargets:      len(entityTargets),
+		RolloutTargets:    len(state.totalTargets),
+		Batches:           []*SimulatedBatch{},
+		ExternalSelection: !localSelection(rollout.TargetController.EntityTargetController),
+	}
+	for _, entityTarget := range state.totalTargets {
+		if entityTarget.State.TargetVersion.Version == version {
+			state.successTargets = append(state.successTargets, entityTarget)
+			continue
+		}
+		state.availableTargets = append(state.availableTargets, entityTarget)
+	}
+	simulation.AssignedTargets = len(state.successTargets)
+
+	for len(state.availableTargets) > 0 {
+		batchSize := max(rollout.batchSize(state), 1)
+
+		// rings are promoted one after another, assuming each ring succeeds before the next starts
+		candidates, ring := state.availableTargets, ""
+		if groupOrder := options.GroupOrder; len(groupOrder) > 0 {
+			index := len(groupOrder) - 1
+			for _, entityTarget := range state.availableTargets {
+				index = min(index, rollout.ringIndex(entityTarget.Group))
+			}
+			ring = groupOrder[index]
+			candidates = nil
+			for _, entityTarget := range state.availableTargets {
+				if rollout.ringIndex(entityTarget.Group) == index {
+					candidates = append(candidates, entityTarget)
+				}
+			}
+		}
+
+		clientTargets := getClientTargets(candidates)
+		if !simulation.ExternalSelection && rollout.TargetController.EntityTargetController != nil {
+			if clientTargets, err = rollout.TargetController.TargetSelection(clientTargets, batchSize); err != nil {
+				return nil, err
+			}
+		}
+
+		var batchTargets []*ClientState
+		for _, clientTarget := range clientTargets {
+			if len(batchTargets) >= batchSize {
+				break
+			}
+			for _, entityTarget := range candidates {
+				if entityTarget.Name == clientTarget.Name && entityTarget.Group == clientTarget.Group {
+					batchTargets = append(batchTargets, getClientTarget(entityTarget))
+					state.successTargets = append(state.successTargets, entityTarget)
+					state.availableTargets = removeEntityTarget(state.availableTargets, entityTarget)
+					break
+				}
+			}
+		}
+		if len(batchTargets) <= 0 {
+			// controller selected none of the available targets, rollout would not progress
+			break
+		}
+
+		simulation.Batches = append(simulation.Batches, &SimulatedBatch{
+			Batch:   len(simulation.Batches) + 1,
+			Ring:    ring,
+			Targets: batchTargets,
+			Percent: len(state.successTargets) * 100 / len(state.totalTargets),
+		})
+	}
+
+	return simulation, nil
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batchSizes(simulation *RolloutSimulation) []int {
+	var sizes []int
+	for _, batch := range simulation.Batches {
+		sizes = append(sizes, len(batch.Targets))
+	}
+	return sizes
+}
+
+func TestSimulateRollout(t *testing.T) {
+	const testName = "TestSimulateRollout"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = setupNamespace(engine, testName, testName, 10)
+	require.NoError(t, err)
+	before, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	simulation, err := engine.SimulateRolloutContext(ctx, testName, testName, &RolloutSimulationRequest{
+		Version: "v3",
+		Options: &RolloutOptions{BatchPercent: 20, SuccessPercent: 100},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, simulation.TotalTargets)
+	assert.Equal(t, 10, simulation.RolloutTargets)
+	assert.Zero(t, simulation.AssignedTargets)
+	assert.Equal(t, []int{2, 2, 2, 2, 2}, batchSizes(simulation))
+	assert.Equal(t, 100, simulation.Batches[4].Percent)
+	assert.False(t, simulation.ExternalSelection)
+
+	// every target is in exactly one batch
+	seen := map[string]bool{}
+	for _, batch := range simulation.Batches {
+		for _, target := range batch.Targets {
+			assert.False(t, seen[target.Name], target.Name)
+			seen[target.Name] = true
+		}
+	}
+	assert.Len(t, seen, 10)
+
+	simulation, err = engine.SimulateRolloutContext(ctx, testName, testName, &RolloutSimulationRequest{
+		Version: "v3",
+		Options: &RolloutOptions{BatchPlan: []int{10, 50, 100}, SuccessPercent: 100},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4, 5}, batchSizes(simulation))
+
+	simulation, err = engine.SimulateRolloutContext(ctx, testName, testName, &RolloutSimulationRequest{
+		Version: "v3",
+		Options: &RolloutOptions{BatchPercent: 100, TargetSelector: "region=us-east"},
+	})
+	require.NoError(t, err)
+	assert.Zero(t, simulation.RolloutTargets)
+	assert.Empty(t, simulation.Batches)
+
+	// targets already assigned version are not part of batches
+	const assignedEntity = "assigned"
+	require.NoError(t, engine.SetRolloutOptions(testName, assignedEntity, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, assignedEntity, EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*ClientState
+	for i := 0; i < 4; i++ {
+		clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = engine.Orchestrate(testName, assignedEntity, clientTargets)
+	require.NoError(t, err)
+	simulation, err = engine.SimulateRolloutContext(ctx, testName, assignedEntity, &RolloutSimulationRequest{Version: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, simulation.AssignedTargets)
+	assert.Empty(t, simulation.Batches)
+
+	_, err = engine.SimulateRolloutContext(ctx, testName, testName, &RolloutSimulationRequest{
+		Version: "v3",
+		Options: &RolloutOptions{BatchPlan: []int{50, 10}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidBatchPlan)
+
+	_, err = engine.SimulateRolloutContext(ctx, testName, "missing", &RolloutSimulationRequest{Version: "v3"})
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+
+	// nothing is persisted
+	after, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, before.TargetVersion, after.TargetVersion)
+	assert.Equal(t, before.RollingVersion, after.RollingVersion)
+	assert.Equal(t, before.Options, after.Options)
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	slackPrefix = "slack:"
+)
+
+// SlackConfig posts rollout started, succeeded and rollback events to a slack incoming webhook
+type SlackConfig struct {
+	// WebhookURL is slack incoming webhook, empty URL disables slack notifications
+	WebhookURL string `json:"webhookurl,omitempty"`
+	// Channel overrides default channel of the webhook
+	Channel string `json:"channel,omitempty"`
+}
+
+type slackMessage struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []slackBlock `json:"blocks,omitempty"`
+	// ReplaceOriginal and ResponseType are used when responding to interactions via response url
+	ReplaceOriginal bool   `json:"replace_original,omitempty"`
+	ResponseType    string `json:"response_type,omitempty"`
+}
+
+func namespaceSlackKey(namespace string) string {
+	return slackPrefix + namespace
+}
+
+func (e *Entity) slackKey() string {
+	return fmt.Sprintf("%s%s/%s", slackPrefix, e.Namespace, e.Name)
+}
+
+// setSlackConfig saves slack config for all entities in namespace
+func (n *Namespace) setSlackConfig(config *SlackConfig) error {
+	n.logger.Info().Msg("Set namespace SlackConfig")
+	return n.store.SaveJSON(namespaceSlackKey(n.Name), config)
+}
+
+// setSlackConfig saves slack config for the entity, overrides namespace config
+func (e *Entity) setSlackConfig(config *SlackConfig) error {
+	e.logger.Info().Msg("Set SlackConfig")
+	return e.store.SaveJSON(e.slackKey(), config)
+}
+
+// findSlackConfig returns entity config if present otherwise namespace config, nil if neither is configured
+func (e *Entity) findSlackConfig() (*SlackConfig, error) {
+	for _, key := range []string{e.slackKey(), namespaceSlackKey(e.Namespace)} {
+		config := &SlackConfig{}
+		err := e.store.LoadJSON(key, config)
+		if err == store.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+	return nil, nil
+}
+
+// slackText formats event for slack, returns empty text for events not posted to slack
+func slackText(event Event) string {
+	var title string
+	switch event.Type {
+	case EventRolloutStarted:
+		title = fmt.Sprintf(":rocket: Rollout of *%s* started", event.Version)
+	case EventRolloutSucceeded:
+		title = fmt.Sprintf(":white_check_mark: Rollout of *%s* succeeded", event.Version)
+	case EventRollbackInitiated:
+		title = fmt.Sprintf(":rotating_light: Rollout of *%s* failed, rolling back", event.Version)
+	case EventRolloutStalled:
+		title = fmt.Sprintf(":hourglass: Rollout of *%s* stalled", event.Version)
+	default:
+		return ""
+	}
+
+	text := fmt.Sprintf("%s for `%s/%s`\nLKG: %s, LKB: %s, failed targets: %d/%d",
+		title, event.Namespace, event.Entity,
+		emptyVersion(event.LastKnownGoodVersion), emptyVersion(event.LastKnownBadVersion),
+		event.FailedTargets, event.Targets)
+	if event.Type == EventRolloutStalled {
+		text += fmt.Sprintf("\nReason: %s", event.Message)
+	}
+	if event.Ticket != "" {
+		text += fmt.Sprintf("\nTicket: %s", event.Ticket)
+	}
+	if event.Expedited {
+		text += "\nExpedited using emergency profile"
+	}
+	if event.Note != "" {
+		text += fmt.Sprintf("\nNote: %s", event.Note)
+	}
+	return text
+}
+
+func emptyVersion(version string) string {
+	if version == "" {
+		return "none"
+	}
+	return version
+}
+
+// postSlack posts text to slack webhook
+func postSlack(ctx context.Context, config *SlackConfig, text string) error {
+	return postSlackMessage(ctx, config.WebhookURL, &slackMessage{Channel: config.Channel, Text: text})
+}
+
+// postSlackMessage posts message to slack webhook or interaction response url
+func postSlackMessage(ctx context.Context, url string, message *slackMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// notifySlack posts rollout lifecycle events to slack in background, failures are logged
+func (e *Entity) notifySlack(events []Event) {
+	config, err := e.findSlackConfig()
+	if err != nil {
+		e.logger.Error().Err(err).Msg("Failed to load slack config")
+		return
+	}
+	if config == nil || config.WebhookURL == "" {
+		return
+	}
+
+	go func() {
+		for _, event := range events {
+			text := slackText(event)
+			if text == "" {
+				continue
+			}
+			if err := postSlack(context.Background(), config, text); err != nil {
+				e.logger.Error().Err(err).Str("Event", string(event.Type)).Msg("Failed to post slack notification")
+			}
+		}
+	}()
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSlackConfig(t *testing.T) {
+	e, err := createEntity("TestFindSlackConfig", getLogger())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	config, err := e.findSlackConfig()
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	namespace := &Namespace{Name: e.Namespace, store: e.store, logger: e.logger}
+	require.NoError(t, namespace.setSlackConfig(&SlackConfig{WebhookURL: "https://namespace"}))
+
+	config, err = e.findSlackConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://namespace", config.WebhookURL)
+
+	require.NoError(t, e.setSlackConfig(&SlackConfig{WebhookURL: "https://entity"}))
+
+	config, err = e.findSlackConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://entity", config.WebhookURL)
+}
+
+func TestPostSlack(t *testing.T) {
+	var message slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+	}))
+	defer server.Close()
+
+	text := slackText(Event{
+		Type:                 EventRollbackInitiated,
+		Namespace:            "ns",
+		Entity:               "entity",
+		Version:              "v2",
+		LastKnownGoodVersion: "v1",
+		LastKnownBadVersion:  "v2",
+		Targets:              10,
+		FailedTargets:        3,
+	})
+	assert.Contains(t, text, "LKG: v1, LKB: v2, failed targets: 3/10")
+	assert.Empty(t, slackText(Event{Type: EventBatchStarted}))
+
+	require.NoError(t, postSlack(context.Background(), &SlackConfig{WebhookURL: server.URL, Channel: "#deploys"}, text))
+	assert.Equal(t, "#deploys", message.Channel)
+	assert.Equal(t, text, message.Text)
+}
@@ -0,0 +1,319 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	slackApprovalPrefix = "slackapproval:"
+
+	// ApprovalPending is waiting for someone to approve or deny
+	ApprovalPending = "Pending"
+	// ApprovalApproved batch was approved
+	ApprovalApproved = "Approved"
+	// ApprovalDenied batch was denied, version is held until target version changes
+	ApprovalDenied = "Denied"
+
+	slackApproveAction = "approve"
+	slackDenyAction    = "deny"
+	// maxSlackApprovalTargets listed in approval message, remaining targets are counted
+	maxSlackApprovalTargets = 20
+)
+
+// EntitySlackApprovalController posts an interactive slack message listing targets of every batch
+// and holds the batch until someone approves or denies it, all other callbacks behave like noop controller
+type EntitySlackApprovalController struct {
+	NoOpEntityTargetController
+	// WebhookURL of slack app incoming webhook, defaults to entity or namespace slack config
+	WebhookURL string `json:"webhookurl,omitempty"`
+	// Channel overrides default channel of the webhook
+	Channel string `json:"channel,omitempty"`
+
+	ctx     context.Context
+	rollout *Rollout
+}
+
+// SlackApproval is approval requested for a batch of targets
+type SlackApproval struct {
+	ID                string         `json:"id,omitempty"`
+	Version           string         `json:"version,omitempty"`
+	Targets           []*ClientState `json:"targets,omitempty"`
+	Status            string         `json:"status,omitempty"`
+	User              string         `json:"user,omitempty"`
+	RequestTimestamp  time.Time      `json:"requesttimestamp,omitempty"`
+	DecisionTimestamp time.Time      `json:"decisiontimestamp,omitempty"`
+	// Recorded is set once decision was recorded in rollout history
+	Recorded bool `json:"recorded,omitempty"`
+}
+
+// slackApprovalAction is value of approve and deny buttons
+type slackApprovalAction struct {
+	Namespace string `json:"namespace"`
+	Entity    string `json:"entity"`
+	ID        string `json:"id"`
+}
+
+type slackTextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackElement struct {
+	Type     string           `json:"type"`
+	Text     *slackTextObject `json:"text,omitempty"`
+	ActionID string           `json:"action_id,omitempty"`
+	Style    string           `json:"style,omitempty"`
+	Value    string           `json:"value,omitempty"`
+}
+
+type slackBlock struct {
+	Type     string           `json:"type"`
+	BlockID  string           `json:"block_id,omitempty"`
+	Text     *slackTextObject `json:"text,omitempty"`
+	Elements []slackElement   `json:"elements,omitempty"`
+}
+
+func (e *Entity) slackApprovalKey() string {
+	return fmt.Sprintf("%s%s/%s", slackApprovalPrefix, e.Namespace, e.Name)
+}
+
+// findSlackApproval returns latest approval of the entity, nil if none was requested
+func (e *Entity) findSlackApproval() (*SlackApproval, error) {
+	approval := &SlackApproval{}
+	err := e.store.LoadJSON(e.slackApprovalKey(), approval)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+func (e *Entity) saveSlackApproval(approval *SlackApproval) error {
+	return e.store.SaveJSON(e.slackApprovalKey(), approval)
+}
+
+// decideSlackApproval approves or denies pending approval id on behalf of user
+func (e *Entity) decideSlackApproval(id string, approved bool, user string) (*SlackApproval, error) {
+	approval, err := e.findSlackApproval()
+	if err != nil {
+		return nil, err
+	}
+	if approval == nil || approval.ID != id || approval.Status != ApprovalPending {
+		return nil, ErrApprovalNotPending
+	}
+
+	approval.Status = ApprovalDenied
+	if approved {
+		approval.Status = ApprovalApproved
+	}
+	approval.User = user
+	approval.DecisionTimestamp = nowUTC()
+
+	e.logger.Info().Str("ApprovalID", id).Str("Version", approval.Version).Str("Status", approval.Status).Str("User", user).Msg("Slack approval decided")
+	if err := e.saveSlackApproval(approval); err != nil {
+		return nil, err
+	}
+	return approval, e.recordAudit(&AuditRecord{
+		Action: AuditApproval,
+		Caller: "slack:" + user,
+		Old:    AuditValue(&ApprovalDecision{Version: approval.Version, Status: ApprovalPending}),
+		New:    AuditValue(&ApprovalDecision{Version: approval.Version, Status: approval.Status, User: user, Timestamp: approval.DecisionTimestamp}),
+	})
+}
+
+func (e *EntitySlackApprovalController) slackConfig() (*SlackConfig, error) {
+	config := &SlackConfig{WebhookURL: e.WebhookURL}
+	if config.WebhookURL == "" {
+		entityConfig, err := e.rollout.entity.findSlackConfig()
+		if err != nil {
+			return nil, err
+		}
+		if entityConfig != nil {
+			config = entityConfig
+		}
+	}
+	if config.WebhookURL == "" {
+		return nil, fmt.Errorf("%w: slack webhook is not configured", ErrInvalidTargetController)
+	}
+	if e.Channel != "" {
+		config.Channel = e.Channel
+	}
+	return config, nil
+}
+
+// approvalVersion is version batch would be assigned, lkg if rolling version failed
+func (e *EntitySlackApprovalController) approvalVersion() string {
+	state := e.rollout.State
+	if state.RollingVersion == state.LastKnownBadVersion {
+		return state.LastKnownGoodVersion
+	}
+	return state.RollingVersion
+}
+
+// TargetApproval requests approval of targets on slack, targets are held until approval is granted
+func (e *EntitySlackApprovalController) TargetApproval(clientTargets []*ClientState) ([]*ClientState, error) {
+	if e.rollout == nil {
+		return nil, errors.New("slack approval controller is not attached to a rollout")
+	}
+	entity := e.rollout.entity
+	version := e.approvalVersion()
+
+	approval, err := entity.findSlackApproval()
+	if err != nil {
+		return nil, err
+	}
+
+	if approval != nil && approval.Version == version {
+		switch approval.Status {
+		case ApprovalPending:
+			return nil, nil
+		case ApprovalDenied:
+			// denied versions stay held, decision is recorded once
+			if !approval.Recorded {
+				if err := e.recordDecision(approval); err != nil {
+					return nil, err
+				}
+				approval.Recorded = true
+				return nil, entity.saveSlackApproval(approval)
+			}
+			return nil, nil
+		case ApprovalApproved:
+			if err := e.recordDecision(approval); err != nil {
+				return nil, err
+			}
+			if err := entity.store.Delete(entity.slackApprovalKey()); err != nil {
+				return nil, err
+			}
+			// only targets approved and still waiting are rolled out, others need next approval
+			var approvedTargets []*ClientState
+			for _, clientTarget := range clientTargets {
+				for _, target := range approval.Targets {
+					if clientTarget.Name == target.Name && clientTarget.Group == target.Group {
+						approvedTargets = append(approvedTargets, clientTarget)
+						break
+					}
+				}
+			}
+			return approvedTargets, nil
+		}
+	}
+
+	return nil, e.requestApproval(version, clientTargets)
+}
+
+// requestApproval posts approval message for targets, replacing approval of any previous version
+func (e *EntitySlackApprovalController) requestApproval(version string, clientTargets []*ClientState) error {
+	entity := e.rollout.entity
+	config, err := e.slackConfig()
+	if err != nil {
+		return err
+	}
+
+	nowTime := nowUTC()
+	approval := &SlackApproval{
+		ID:               fmt.Sprintf("%020d", nowTime.UnixNano()),
+		Version:          version,
+		Status:           ApprovalPending,
+		RequestTimestamp: nowTime,
+	}
+	for _, clientTarget := range clientTargets {
+		approval.Targets = append(approval.Targets, &ClientState{Name: clientTarget.Name, Group: clientTarget.Group})
+	}
+
+	message, err := slackApprovalMessage(entity, approval)
+	if err != nil {
+		return err
+	}
+	message.Channel = config.Channel
+	if err := postSlackMessage(controllerContext(e.ctx), config.WebhookURL, message); err != nil {
+		return fmt.Errorf("%w: slack approval: %w", ErrExternalControllerFailure, err)
+	}
+
+	entity.logger.Info().Str("ApprovalID", approval.ID).Str("Version", version).Int("Targets", len(approval.Targets)).Msg("Requested slack approval")
+	e.rollout.addEvent(Event{Type: EventApprovalRequested, Version: version, Targets: len(approval.Targets)})
+	return entity.saveSlackApproval(approval)
+}
+
+// recordDecision adds approval decision to rollout events and history
+func (e *EntitySlackApprovalController) recordDecision(approval *SlackApproval) error {
+	eventType := EventApprovalDenied
+	if approval.Status == ApprovalApproved {
+		eventType = EventApprovalGranted
+	}
+	e.rollout.addEvent(Event{
+		Type:    eventType,
+		Version: approval.Version,
+		Targets: len(approval.Targets),
+		Message: fmt.Sprintf("%s by %s", strings.ToLower(approval.Status), approval.User),
+	})
+
+	decision := &ApprovalDecision{
+		Version:   approval.Version,
+		Status:    approval.Status,
+		User:      approval.User,
+		Timestamp: approval.DecisionTimestamp,
+	}
+	for _, target := range approval.Targets {
+		decision.Targets = append(decision.Targets, target.Name)
+	}
+	return e.rollout.recordApproval(decision)
+}
+
+// slackApprovalMessage lists targets of approval with approve and deny buttons
+func slackApprovalMessage(entity *Entity, approval *SlackApproval) (*slackMessage, error) {
+	value, err := json.Marshal(&slackApprovalAction{Namespace: entity.Namespace, Entity: entity.Name, ID: approval.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	title := fmt.Sprintf(":raised_hand: Approval required to roll out *%s* to %d targets of `%s/%s`",
+		approval.Version, len(approval.Targets), entity.Namespace, entity.Name)
+
+	var names []string
+	for i, target := range approval.Targets {
+		if i >= maxSlackApprovalTargets {
+			names = append(names, fmt.Sprintf("and %d more", len(approval.Targets)-i))
+			break
+		}
+		names = append(names, fmt.Sprintf("`%s`", target.Name))
+	}
+
+	return &slackMessage{
+		Text: title,
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackTextObject{Type: "mrkdwn", Text: title + "\n" + strings.Join(names, ", ")}},
+			{
+				Type:    "actions",
+				BlockID: "orchestrator_approval",
+				Elements: []slackElement{
+					{Type: "button", Text: &slackTextObject{Type: "plain_text", Text: "Approve"}, ActionID: slackApproveAction, Style: "primary", Value: string(value)},
+					{Type: "button", Text: &slackTextObject{Type: "plain_text", Text: "Deny"}, ActionID: slackDenyAction, Style: "danger", Value: string(value)},
+				},
+			},
+		},
+	}, nil
+}
+
+func (e *EntitySlackApprovalController) Validate() error {
+	if e.WebhookURL != "" && !strings.HasPrefix(e.WebhookURL, "https://") && !strings.HasPrefix(e.WebhookURL, "http://") {
+		return fmt.Errorf("%w: webhookurl must be an http url", ErrInvalidTargetController)
+	}
+	return nil
+}
+
+func (e *EntitySlackApprovalController) setContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+func (e *EntitySlackApprovalController) setRollout(r *Rollout) {
+	e.rollout = r
+}
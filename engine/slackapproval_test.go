@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slackStub records messages posted to webhook and response urls
+type slackStub struct {
+	lock     sync.Mutex
+	messages []slackMessage
+}
+
+func (s *slackStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var message slackMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func (s *slackStub) last() slackMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.messages) <= 0 {
+		return slackMessage{}
+	}
+	return s.messages[len(s.messages)-1]
+}
+
+func (s *slackStub) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.messages)
+}
+
+// approvals counts approval messages, rollout notifications are posted to same webhook
+func (s *slackStub) approvals() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var count int
+	for _, message := range s.messages {
+		if strings.HasPrefix(message.Text, ":raised_hand:") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSlackApprovalDenied(t *testing.T) {
+	const testName = "TestSlackApprovalDenied"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	stub := &slackStub{}
+	slack := httptest.NewServer(stub)
+	defer slack.Close()
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	clientTargets := []*ClientState{{Name: "clientTarget0", Version: "v1"}}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.SetSlackConfig(testName, testName, &SlackConfig{WebhookURL: slack.URL}))
+	require.NoError(t, engine.SetEntityTargetController(testName, testName, &EntitySlackApprovalController{}))
+
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v2"}))
+	for i := 0; i < 2; i++ {
+		_, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, stub.approvals())
+
+	namespace, err := engine.findNamespace(testName)
+	require.NoError(t, err)
+	entity, err := namespace.findEntity(testName)
+	require.NoError(t, err)
+	approval, err := entity.findSlackApproval()
+	require.NoError(t, err)
+	_, err = engine.DecideSlackApproval(testName, testName, approval.ID, false, "@oncall")
+	require.NoError(t, err)
+
+	records, err := engine.GetAuditRecords(testName, testName, AuditFilter{Action: AuditApproval}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "slack:@oncall", records[0].Caller)
+	assert.Contains(t, string(records[0].New), ApprovalDenied)
+
+	// denied version stays held without asking again
+	var assigned []*ClientState
+	for i := 0; i < 2; i++ {
+		assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, countVersion(assigned, "v2"))
+	assert.Equal(t, 1, stub.approvals())
+
+	histories, err := engine.GetRolloutHistory(testName, testName, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, histories, 1)
+	require.Len(t, histories[0].Approvals, 1)
+	assert.Equal(t, ApprovalDenied, histories[0].Approvals[0].Status)
+
+	// new version asks again
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v3"}))
+	for i := 0; i < 2; i++ {
+		_, err = engine.Orchestrate(testName, testName, clientTargets)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, stub.approvals())
+	assert.Contains(t, stub.last().Text, "*v3*")
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotPrefix = "snapshot:"
+	// snapshots are recorded at most once per interval during orchestrate
+	snapshotInterval = time.Minute
+	// snapshots older than retention are pruned
+	snapshotRetention = 7 * 24 * time.Hour
+)
+
+// FleetSnapshot is a compact record of current versions of targets at a point in time
+type FleetSnapshot struct {
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	Total     int       `json:"total,omitempty"`
+	// Versions is count of targets by current version
+	Versions map[string]int `json:"versions,omitempty"`
+	// Groups is count of targets by group and current version
+	Groups map[string]map[string]int `json:"groups,omitempty"`
+}
+
+func (e *Entity) snapshotPrefix() string {
+	return fmt.Sprintf("%s%s/%s/", snapshotPrefix, e.Namespace, e.Name)
+}
+
+func (e *Entity) snapshotKey(timestamp time.Time) string {
+	return fmt.Sprintf("%s%020d", e.snapshotPrefix(), timestamp.UnixNano())
+}
+
+// createFleetSnapshot counts targets by version and group
+func createFleetSnapshot(entityTargets EntityTargets, timestamp time.Time) *FleetSnapshot {
+	snapshot := &FleetSnapshot{
+		Timestamp: timestamp,
+		Total:     len(entityTargets),
+		Versions:  make(map[string]int),
+		Groups:    make(map[string]map[string]int),
+	}
+
+	for _, entityTarget := range entityTargets {
+		version := entityTarget.State.CurrentVersion.Version
+		snapshot.Versions[version]++
+		if _, ok := snapshot.Groups[entityTarget.Group]; !ok {
+			snapshot.Groups[entityTarget.Group] = make(map[string]int)
+		}
+		snapshot.Groups[entityTarget.Group][version]++
+	}
+
+	return snapshot
+}
+
+// recordSnapshot records fleet snapshot if last snapshot is older than snapshotInterval
+func (e *Entity) recordSnapshot(rollout *Rollout, entityTargets EntityTargets) error {
+	nowTime := nowUTC()
+	if nowTime.Sub(rollout.State.SnapshotTimestamp) < snapshotInterval {
+		return nil
+	}
+
+	if err := e.store.SaveJSON(e.snapshotKey(nowTime), createFleetSnapshot(entityTargets, nowTime)); err != nil {
+		return err
+	}
+	rollout.State.SnapshotTimestamp = nowTime
+
+	return e.pruneSnapshots(nowTime.Add(-snapshotRetention))
+}
+
+// pruneSnapshots deletes snapshots recorded before cutoff
+func (e *Entity) pruneSnapshots(cutoff time.Time) error {
+	keys, err := e.store.LoadKeys(e.snapshotPrefix())
+	if err != nil {
+		return err
+	}
+
+	cutoffKey := e.snapshotKey(cutoff)
+	for _, key := range keys {
+		if strings.Compare(key, cutoffKey) >= 0 {
+			continue
+		}
+		if err := e.store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getSnapshots returns snapshots recorded since, oldest first
+func (e *Entity) getSnapshots(since time.Time) ([]*FleetSnapshot, error) {
+	snapshots := []*FleetSnapshot{}
+	snapshotItr := func(key any, value any) error {
+		snapshot := &FleetSnapshot{}
+		if err := json.Unmarshal([]byte(value.(string)), snapshot); err != nil {
+			return err
+		}
+		if snapshot.Timestamp.Before(since) {
+			return nil
+		}
+		snapshots = append(snapshots, snapshot)
+		return nil
+	}
+
+	if err := e.store.LoadValues(e.snapshotPrefix(), snapshotItr); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+
+	return snapshots, nil
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleetSnapshots(t *testing.T) {
+	e, _, err := setupEntity()
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, e.store.Close())
+	}()
+
+	rollout, err := e.findOrCreateRollout()
+	require.NoError(t, err)
+
+	targets, err := e.getEntityTargets()
+	require.NoError(t, err)
+	targets[0].Group = "canary"
+	targets[0].State.CurrentVersion.Version = "v2"
+
+	require.NoError(t, e.recordSnapshot(rollout, targets))
+	// within snapshot interval, not recorded
+	require.NoError(t, e.recordSnapshot(rollout, targets))
+
+	// old snapshot gets pruned with next recorded snapshot
+	require.NoError(t, e.store.SaveJSON(e.snapshotKey(time.Now().Add(-2*snapshotRetention)), &FleetSnapshot{}))
+	rollout.State.SnapshotTimestamp = time.Time{}
+	require.NoError(t, e.recordSnapshot(rollout, targets))
+
+	snapshots, err := e.getSnapshots(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.True(t, snapshots[0].Timestamp.Before(snapshots[1].Timestamp))
+	assert.Equal(t, len(targets), snapshots[0].Total)
+	assert.Equal(t, 1, snapshots[0].Versions["v2"])
+	assert.Equal(t, len(targets)-1, snapshots[0].Versions["v1"])
+	assert.Equal(t, 1, snapshots[0].Groups["canary"]["v2"])
+
+	snapshots, err = e.getSnapshots(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
@@ -0,0 +1,346 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// VersionWeight is desired percentage of targets on version
+type VersionWeight struct {
+	Version string `json:"version"`
+	Percent int    `json:"percent"`
+}
+
+// VersionSplitStatus is desired and assigned targets of a version in split
+type VersionSplitStatus struct {
+	VersionWeight `json:",inline"`
+	Desired       int `json:"desired"`
+	Assigned      int `json:"assigned"`
+}
+
+// VersionSplit is split of targets across versions held simultaneously, e.g. long lived canary
+type VersionSplit struct {
+	Versions []VersionSplitStatus `json:"versions,omitempty"`
+	// Converged is true once assigned targets match desired split
+	Converged bool `json:"converged"`
+	// Failed versions were dropped from split after failing monitoring
+	Failed []string `json:"failed,omitempty"`
+}
+
+// validateSplit checks versions are unique and percentages add up to 100
+func validateSplit(split []VersionWeight) error {
+	if len(split) <= 0 {
+		return nil
+	}
+
+	total := 0
+	versions := make(map[string]bool, len(split))
+	for _, weight := range split {
+		if weight.Version == "" {
+			return fmt.Errorf("%w: version must not be empty", ErrInvalidVersionSplit)
+		}
+		if versions[weight.Version] {
+			return fmt.Errorf("%w: version %s listed more than once", ErrInvalidVersionSplit, weight.Version)
+		}
+		if weight.Percent <= 0 {
+			return fmt.Errorf("%w: percent of version %s must be positive", ErrInvalidVersionSplit, weight.Version)
+		}
+		versions[weight.Version] = true
+		total += weight.Percent
+	}
+	if total != 100 {
+		return fmt.Errorf("%w: percentages add up to %d instead of 100", ErrInvalidVersionSplit, total)
+	}
+	return nil
+}
+
+// desiredSplit returns desired targets per version, remainders go to versions with largest fractions
+func desiredSplit(split []VersionWeight, totalTargets int) []int {
+	desired := make([]int, len(split))
+	if len(split) <= 0 {
+		return desired
+	}
+	remaining := totalTargets
+	for i, weight := range split {
+		desired[i] = weight.Percent * totalTargets / 100
+		remaining -= desired[i]
+	}
+
+	order := make([]int, len(split))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return split[order[i]].Percent*totalTargets%100 > split[order[j]].Percent*totalTargets%100
+	})
+	for i := 0; remaining > 0; i = (i + 1) % len(order) {
+		desired[order[i]]++
+		remaining--
+	}
+	return desired
+}
+
+// splitTargets groups targets by assigned split version, targets on other versions are free
+func splitTargets(split []VersionWeight, entityTargets EntityTargets) ([]EntityTargets, EntityTargets) {
+	sorted := append(EntityTargets{}, entityTargets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Group != sorted[j].Group {
+			return sorted[i].Group < sorted[j].Group
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	assigned := make([]EntityTargets, len(split))
+	var free EntityTargets
+	for _, entityTarget := range sorted {
+		index := -1
+		for i, weight := range split {
+			if entityTarget.State.TargetVersion.Version == weight.Version {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			free = append(free, entityTarget)
+			continue
+		}
+		assigned[index] = append(assigned[index], entityTarget)
+	}
+	return assigned, free
+}
+
+// convergeSplit monitors targets of split versions and moves targets towards desired split, at most BatchPercent
+// of targets per call. Versions held by schedule, change freeze or dependencies get no new targets except those
+// of failed versions moving back, normal rollout and last known versions are suspended while split is set
+func (r *Rollout) convergeSplit(entityTargets EntityTargets) error {
+	if err := r.monitorSplit(entityTargets); err != nil {
+		return err
+	}
+
+	split := r.State.Split
+	desired := desiredSplit(split, len(entityTargets))
+	assigned, free := splitTargets(split, entityTargets)
+
+	// targets above desired count of their version are reassigned
+	for i := range split {
+		if extra := len(assigned[i]) - desired[i]; extra > 0 {
+			free = append(free, assigned[i][desired[i]:]...)
+			assigned[i] = assigned[i][:desired[i]]
+		}
+	}
+	// targets of failed versions move first
+	sort.SliceStable(free, func(i, j int) bool {
+		return r.splitFailed(free[i]) && !r.splitFailed(free[j])
+	})
+
+	batchSize := max(r.options().BatchPercent*len(entityTargets)/100, 1)
+	var changedTargets EntityTargets
+	for i, weight := range split {
+		held, err := r.versionHeld(weight.Version)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < len(free) && len(assigned[i]) < desired[i] && len(changedTargets) < batchSize; {
+			entityTarget := free[j]
+			if held && !r.splitFailed(entityTarget) {
+				j++
+				continue
+			}
+			free = slices.Delete(free, j, j+1)
+			entityTarget.State.TargetVersion.Version = weight.Version
+			entityTarget.State.TargetVersion.ChangeTimestamp = nowUTC()
+			entityTarget.State.TargetVersion.LastMessage.Success(fmt.Sprintf("Split version %s at %d%%", weight.Version, weight.Percent))
+			entityTarget.addEvent(TargetEventVersionAssigned, weight.Version, entityTarget.State.TargetVersion.LastMessage.Message)
+			assigned[i] = append(assigned[i], entityTarget)
+			changedTargets = append(changedTargets, entityTarget)
+		}
+	}
+
+	r.logger.Info().Int("Targets", len(entityTargets)).Int("ChangedTargets", len(changedTargets)).Msg("Converging version split")
+	return r.entity.saveEntityTargets(changedTargets)
+}
+
+// splitFailed returns true if target is assigned a version dropped from split
+func (r *Rollout) splitFailed(entityTarget *EntityTarget) bool {
+	return slices.Contains(r.State.SplitFailed, entityTarget.State.TargetVersion.Version)
+}
+
+// monitorSplit checks targets running split versions, a version whose target fails monitoring is dropped from
+// split and its percent goes to remaining versions, the last remaining version is kept
+func (r *Rollout) monitorSplit(entityTargets EntityTargets) error {
+	if err := r.MonitoringController.ExternalMonitoring(getClientTargets(entityTargets)); err != nil {
+		return err
+	}
+
+	assigned, _ := splitTargets(r.State.Split, entityTargets)
+	var failed []string
+	for i, weight := range r.State.Split {
+		failedTarget, err := r.monitorSplitVersion(weight.Version, assigned[i])
+		if err != nil {
+			return err
+		}
+		if failedTarget == nil {
+			continue
+		}
+		if len(failed) >= len(r.State.Split)-1 {
+			r.logger.Error().Str("Version", weight.Version).Str("EntityTarget", failedTarget.Name).Msg("Last version of split failed monitoring, keeping it")
+			continue
+		}
+		r.logger.Error().Str("Version", weight.Version).Str("EntityTarget", failedTarget.Name).Msg("Split version failed monitoring, moving its targets to other versions")
+		failed = append(failed, weight.Version)
+	}
+	if len(failed) <= 0 {
+		return nil
+	}
+
+	var split []VersionWeight
+	for _, weight := range r.State.Split {
+		if !slices.Contains(failed, weight.Version) {
+			split = append(split, weight)
+		}
+	}
+	r.State.Split = shrinkSplit(split)
+	r.State.SplitFailed = append(r.State.SplitFailed, failed...)
+	return nil
+}
+
+// monitorSplitVersion returns first target running version which failed target or external monitoring or reported
+// an error, failure is recorded on target
+func (r *Rollout) monitorSplitVersion(version string, entityTargets EntityTargets) (*EntityTarget, error) {
+	var runningTargets EntityTargets
+	for _, entityTarget := range entityTargets {
+		if entityTarget.State.CurrentVersion.Version == version {
+			runningTargets = append(runningTargets, entityTarget)
+		}
+	}
+
+	unhealthyTargets, err := r.unhealthyTargets(&rolloutInfo{inRolloutTargets: runningTargets}, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entityTarget := range runningTargets {
+		message := ""
+		if err := r.TargetController.TargetMonitoring(getClientTarget(entityTarget)); err != nil {
+			message = fmt.Sprintf("Monitoring Failed %s", err)
+		} else if unhealthy, ok := unhealthyTargets[entityTarget.Group+"/"+entityTarget.Name]; ok {
+			message = fmt.Sprintf("Monitoring Failed %s", unhealthy)
+		} else if entityTarget.State.CurrentVersion.LastMessage.IsError {
+			message = fmt.Sprintf("failed monitoring, reported error %s", entityTarget.State.CurrentVersion.LastMessage.Message)
+		}
+		if message == "" {
+			continue
+		}
+
+		entityTarget.State.TargetVersion.LastMessage.Error(message)
+		r.recordTargetFailure(entityTarget)
+		return entityTarget, r.entity.saveEntityTarget(entityTarget)
+	}
+	return nil, nil
+}
+
+// shrinkSplit scales percentages of remaining versions back to 100, remainder goes to first version
+func shrinkSplit(split []VersionWeight) []VersionWeight {
+	total := 0
+	for _, weight := range split {
+		total += weight.Percent
+	}
+	shrunk := make([]VersionWeight, len(split))
+	remaining := 100
+	for i, weight := range split {
+		shrunk[i] = VersionWeight{Version: weight.Version, Percent: weight.Percent * 100 / total}
+		remaining -= shrunk[i].Percent
+	}
+	shrunk[0].Percent += remaining
+	return shrunk
+}
+
+func (r *Rollout) setSplit(split []VersionWeight) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := validateSplit(split); err != nil {
+		return err
+	}
+	r.logger.Info().Interface("Split", split).Msg("Set version split")
+	r.State.Split = split
+	r.State.SplitFailed = nil
+	return nil
+}
+
+func (e *Entity) setSplit(split []VersionWeight) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if err := rollout.setSplit(split); err != nil {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+func (e *Entity) getSplit() (*VersionSplit, error) {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+
+	entityTargets, err := e.getEntityTargetSummaries()
+	if err != nil {
+		return nil, err
+	}
+	entityTargets = rollout.selectedEntityTargets(activeEntityTargets(entityTargets))
+
+	split := rollout.State.Split
+	desired := desiredSplit(split, len(entityTargets))
+	assigned, _ := splitTargets(split, entityTargets)
+
+	status := &VersionSplit{Converged: true, Failed: rollout.State.SplitFailed}
+	for i, weight := range split {
+		status.Versions = append(status.Versions, VersionSplitStatus{VersionWeight: weight, Desired: desired[i], Assigned: len(assigned[i])})
+		if desired[i] != len(assigned[i]) {
+			status.Converged = false
+		}
+	}
+	return status, nil
+}
+
+func (n *Namespace) setSplit(entityName string, split []VersionWeight) error {
+	entity, err := n.findorCreateEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.setSplit(split)
+}
+
+func (n *Namespace) getSplit(entityName string) (*VersionSplit, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getSplit()
+}
+
+// SetVersionSplit holds targets of entity on several versions with desired percentages,
+// empty split resumes rolling out target version
+func (e *Engine) SetVersionSplit(namespaceName, entityName string, split []VersionWeight) error {
+	namespace, err := e.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+
+	return namespace.setSplit(entityName, split)
+}
+
+// GetVersionSplit returns desired and assigned targets per version of split
+func (e *Engine) GetVersionSplit(namespaceName, entityName string) (*VersionSplit, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getSplit(entityName)
+}
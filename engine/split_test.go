@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDesiredSplit(t *testing.T) {
+	assert.Equal(t, []int{9, 1}, desiredSplit([]VersionWeight{{"v1", 90}, {"v2", 10}}, 10))
+	assert.Equal(t, []int{3, 3, 1}, desiredSplit([]VersionWeight{{"v1", 45}, {"v2", 45}, {"v3", 10}}, 7))
+	assert.Equal(t, []int{1, 0}, desiredSplit([]VersionWeight{{"v1", 90}, {"v2", 10}}, 1))
+	assert.Empty(t, desiredSplit(nil, 10))
+
+	assert.NoError(t, validateSplit(nil))
+	assert.ErrorIs(t, validateSplit([]VersionWeight{{"v1", 90}, {"v2", 5}}), ErrInvalidVersionSplit)
+	assert.ErrorIs(t, validateSplit([]VersionWeight{{"v1", 50}, {"v1", 50}}), ErrInvalidVersionSplit)
+	assert.ErrorIs(t, validateSplit([]VersionWeight{{"v1", 100}, {"v2", 0}}), ErrInvalidVersionSplit)
+	assert.ErrorIs(t, validateSplit([]VersionWeight{{"", 100}}), ErrInvalidVersionSplit)
+}
+
+func TestVersionSplitHeldAndMonitored(t *testing.T) {
+	const testName = "TestVersionSplitHeldAndMonitored"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, &RolloutOptions{BatchPercent: 100}))
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1"}))
+	var clientTargets []*ClientState
+	for i := 0; i < 10; i++ {
+		clientTargets = append(clientTargets, &ClientState{Name: fmt.Sprintf("clientTarget%d", i), Version: "v1"})
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+
+	// split versions do not roll during change freeze
+	require.NoError(t, engine.SetNamespaceFreeze(testName, &FreezeState{Frozen: true, Reason: "holidays"}))
+	require.NoError(t, engine.SetVersionSplit(testName, testName, []VersionWeight{{"v1", 90}, {"v2", 10}}))
+	assigned, err := engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 0, countVersion(assigned, "v2"))
+
+	require.NoError(t, engine.SetNamespaceFreeze(testName, &FreezeState{}))
+	assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	require.Equal(t, 1, countVersion(assigned, "v2"))
+
+	// canary failing on v2 shrinks split back to v1
+	for _, clientTarget := range clientTargets {
+		if findClientTarget(assigned, clientTarget.Name).Version == "v2" {
+			clientTarget.Version = "v2"
+			clientTarget.IsError = true
+			clientTarget.Message = "crashing"
+		}
+	}
+	assigned, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	assert.Equal(t, 10, countVersion(assigned, "v1"))
+
+	split, err := engine.GetVersionSplit(testName, testName)
+	require.NoError(t, err)
+	assert.True(t, split.Converged)
+	assert.Equal(t, []string{"v2"}, split.Failed)
+	assert.Equal(t, []VersionSplitStatus{{VersionWeight: VersionWeight{"v1", 100}, Desired: 10, Assigned: 10}}, split.Versions)
+}
+
+func TestShrinkSplit(t *testing.T) {
+	assert.Equal(t, []VersionWeight{{"v1", 100}}, shrinkSplit([]VersionWeight{{"v1", 90}}))
+	assert.Equal(t, []VersionWeight{{"v1", 34}, {"v3", 66}}, shrinkSplit([]VersionWeight{{"v1", 10}, {"v3", 20}}))
+}
+
+func findClientTarget(clientTargets []*ClientState, name string) *ClientState {
+	for _, clientTarget := range clientTargets {
+		if clientTarget.Name == name {
+			return clientTarget
+		}
+	}
+	return nil
+}
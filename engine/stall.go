@@ -0,0 +1,330 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// stallCheckInterval is how often leader looks for rollouts without progress
+const stallCheckInterval = time.Minute
+
+// defaultStallTimeout is used when StallTimeoutSecs of rollout options is not set
+const defaultStallTimeout = 30 * time.Minute
+
+// StallReason is the most likely cause of a stalled rollout
+type StallReason string
+
+const (
+	// StallWaitingApproval rollout is waiting for approval of next batch
+	StallWaitingApproval StallReason = "waiting approval"
+	// StallControllerFailing target selection or approval controller returns errors
+	StallControllerFailing StallReason = "controller failing"
+	// StallNoTargetsReporting no target of the rollout reported status or heartbeat since last progress
+	StallNoTargetsReporting StallReason = "no targets reporting"
+	// StallNoProgress targets report but rollout does not move on, e.g. targets never reach rolling version
+	StallNoProgress StallReason = "no progress"
+)
+
+// RolloutStall is recorded once rollout made no progress for StallTimeoutSecs
+type RolloutStall struct {
+	// Since is when rollout last made progress
+	Since time.Time `json:"since,omitempty"`
+	// DetectedAt is when stall was detected
+	DetectedAt time.Time   `json:"detectedat,omitempty"`
+	Reason     StallReason `json:"reason,omitempty"`
+	Message    string      `json:"message,omitempty"`
+}
+
+// RolloutProgress summarizes progress of rolling version, along with stall reason if rollout is stuck
+type RolloutProgress struct {
+	RolloutVersionInfo `json:",inline"`
+	// Version targets are moving to, last known good version during rollback
+	Version    string `json:"version,omitempty"`
+	InProgress bool   `json:"inprogress,omitempty"`
+	Paused     bool   `json:"paused,omitempty"`
+	// TotalTargets part of rollout, quarantined, pinned and targets outside selector are excluded
+	TotalTargets int `json:"totaltargets"`
+	// UpdatedTargets report version without errors
+	UpdatedTargets int `json:"updatedtargets"`
+	// PendingTargets are assigned version but do not report it yet
+	PendingTargets int `json:"pendingtargets"`
+	// FailedTargets report version with errors
+	FailedTargets int `json:"failedtargets"`
+	// Percent of targets updated
+	Percent           int           `json:"percent"`
+	ProgressTimestamp time.Time     `json:"progresstimestamp,omitempty"`
+	Stall             *RolloutStall `json:"stall,omitempty"`
+}
+
+func (o *RolloutOptions) stallTimeout() time.Duration {
+	if o == nil || o.StallTimeoutSecs <= 0 {
+		return defaultStallTimeout
+	}
+	return time.Duration(o.StallTimeoutSecs) * time.Second
+}
+
+// recordControllerError remembers last controller error, rollout keeps going but may be stalled by it
+func (r *Rollout) recordControllerError(err error) {
+	if err == nil {
+		r.State.ControllerError = ""
+		return
+	}
+	r.logger.Error().Err(err).Msg("Controller failed")
+	r.State.ControllerError = err.Error()
+}
+
+// progressHeld returns true if rollout is intentionally not progressing
+func (r *Rollout) progressHeld() bool {
+	return r.State.Paused || !r.State.Schedule.inWindow(nowUTC())
+}
+
+// recordProgress updates progress timestamp once versions or target counts changed, clearing any stall
+func (r *Rollout) recordProgress(state *rolloutInfo) {
+	key := fmt.Sprintf("%s|%s|%s|%d|%s|%d|%d|%d",
+		r.State.RollingVersion, r.State.LastKnownGoodVersion, r.State.LastKnownBadVersion,
+		r.State.BatchStage, r.State.BatchTimestamp.Format(time.RFC3339Nano),
+		len(state.successTargets), len(state.inRolloutTargets), len(state.failedTargets))
+	// held rollouts are not stalled, stall timeout starts once they are released
+	if key == r.State.ProgressKey && !r.progressHeld() {
+		return
+	}
+	r.State.ProgressKey = key
+	r.State.ProgressTimestamp = nowUTC()
+
+	if r.State.Stall != nil {
+		r.logger.Info().Str("Reason", string(r.State.Stall.Reason)).Msg("Stalled rollout is progressing again")
+		r.State.Stall = nil
+		rolloutsStalled.WithLabelValues(r.entity.Namespace, r.entity.Name).Set(0)
+	}
+}
+
+// stallDue returns true if in progress rollout made no progress for stall timeout and stall is not recorded yet
+func (r *Rollout) stallDue(now time.Time) bool {
+	return r.State.Stall == nil &&
+		len(r.State.Split) <= 0 &&
+		r.rolloutInProgress() &&
+		!r.progressHeld() &&
+		!r.State.ProgressTimestamp.IsZero() &&
+		now.Sub(r.State.ProgressTimestamp) >= r.State.Options.stallTimeout()
+}
+
+// newStall determines most likely reason rollout is not progressing
+func (r *Rollout) newStall(targets EntityTargets, now time.Time) (*RolloutStall, error) {
+	stall := &RolloutStall{Since: r.State.ProgressTimestamp, DetectedAt: now}
+
+	approval, err := r.entity.findSlackApproval()
+	if err != nil {
+		return nil, err
+	}
+
+	reporting := 0
+	for _, entityTarget := range targets {
+		if entityTarget.State.LastSeenTimestamp.After(r.State.ProgressTimestamp) {
+			reporting++
+		}
+	}
+
+	switch {
+	case approval != nil && approval.Status == ApprovalPending:
+		stall.Reason = StallWaitingApproval
+		stall.Message = fmt.Sprintf("approval of version %s pending since %s", approval.Version, approval.RequestTimestamp.Format(time.RFC3339))
+	case r.State.ControllerError != "":
+		stall.Reason = StallControllerFailing
+		stall.Message = r.State.ControllerError
+	case reporting <= 0:
+		stall.Reason = StallNoTargetsReporting
+		stall.Message = fmt.Sprintf("none of %d targets reported since %s", len(targets), r.State.ProgressTimestamp.Format(time.RFC3339))
+	default:
+		stall.Reason = StallNoProgress
+		stall.Message = fmt.Sprintf("%d of %d targets reported without progress since %s", reporting, len(targets), r.State.ProgressTimestamp.Format(time.RFC3339))
+	}
+	return stall, nil
+}
+
+// detectStall records stall and publishes RolloutStalled event, returns true if rollout stalled
+func (r *Rollout) detectStall(targets EntityTargets) (bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := nowUTC()
+	if !r.stallDue(now) {
+		return false, nil
+	}
+
+	targets = r.withoutCheckpointTargets(r.selectedEntityTargets(activeEntityTargets(targets)))
+	stall, err := r.newStall(targets, now)
+	if err != nil {
+		return false, err
+	}
+
+	r.logger.Warn().Str("Reason", string(stall.Reason)).Str("StallMessage", stall.Message).
+		Time("Since", stall.Since).Msg("Rollout stalled")
+	r.State.Stall = stall
+	rolloutsStalled.WithLabelValues(r.entity.Namespace, r.entity.Name).Set(1)
+
+	r.events = nil
+	r.addEvent(Event{
+		Type:    EventRolloutStalled,
+		Targets: len(targets),
+		Message: fmt.Sprintf("%s: %s", stall.Reason, stall.Message),
+	})
+	r.publishEvents(r.State.RolloutVersionInfo, createRolloutInfo(targets))
+	return true, nil
+}
+
+func (r *Rollout) progress(targets EntityTargets) (*RolloutProgress, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	targets = r.withoutCheckpointTargets(r.selectedEntityTargets(activeEntityTargets(targets)))
+
+	version := r.State.RollingVersion
+	if strings.EqualFold(version, r.State.LastKnownBadVersion) {
+		version = r.State.LastKnownGoodVersion
+	}
+
+	progress := &RolloutProgress{
+		RolloutVersionInfo: r.State.RolloutVersionInfo,
+		Version:            version,
+		InProgress:         r.rolloutInProgress(),
+		Paused:             r.State.Paused,
+		TotalTargets:       len(targets),
+		ProgressTimestamp:  r.State.ProgressTimestamp,
+		Stall:              r.State.Stall,
+	}
+
+	for _, entityTarget := range targets {
+		switch {
+		case strings.EqualFold(entityTarget.State.CurrentVersion.Version, version):
+			if entityTarget.State.CurrentVersion.LastMessage.IsError {
+				progress.FailedTargets++
+			} else {
+				progress.UpdatedTargets++
+			}
+		case strings.EqualFold(entityTarget.State.TargetVersion.Version, version):
+			progress.PendingTargets++
+		}
+	}
+	if progress.TotalTargets > 0 {
+		progress.Percent = progress.UpdatedTargets * 100 / progress.TotalTargets
+	}
+
+	// stall may not be recorded yet by background job
+	now := nowUTC()
+	if r.stallDue(now) {
+		stall, err := r.newStall(targets, now)
+		if err != nil {
+			return nil, err
+		}
+		progress.Stall = stall
+	}
+	return progress, nil
+}
+
+func (e *Entity) detectStall() error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	entityTargets, err := e.getEntityTargets()
+	if err != nil {
+		return err
+	}
+
+	stalled, err := rollout.detectStall(entityTargets)
+	if err != nil || !stalled {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+func (e *Entity) getProgress() (*RolloutProgress, error) {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return nil, err
+	}
+
+	entityTargets, err := e.getEntityTargetSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	return rollout.progress(entityTargets)
+}
+
+func (n *Namespace) getProgress(entityName string) (*RolloutProgress, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getProgress()
+}
+
+// runStallDetection is background job recording rollouts that made no progress for their stall timeout
+func (e *Engine) runStallDetection(ctx context.Context) {
+	ticker := time.NewTicker(stallCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.detectStalledRollouts(); err != nil {
+				e.logger.Error().Err(err).Msg("failed to detect stalled rollouts")
+			}
+		}
+	}
+}
+
+// detectStalledRollouts enqueues stall detection of entities whose rollout made no progress for stall timeout
+func (e *Engine) detectStalledRollouts() error {
+	var due []string
+	now := nowUTC()
+	err := e.store.LoadValues(rolloutPrefix, func(key, value any) error {
+		// controllers are not needed to evaluate progress
+		var stored struct {
+			State RolloutState `json:"state"`
+		}
+		if err := json.Unmarshal([]byte(value.(string)), &stored); err != nil {
+			return err
+		}
+		rollout := &Rollout{State: stored.State}
+		if rollout.stallDue(now) {
+			due = append(due, strings.TrimPrefix(key.(string), rolloutPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range due {
+		namespaceName, entityName, _ := strings.Cut(key, "/")
+		namespace, err := e.findNamespace(namespaceName)
+		if err != nil {
+			e.logger.Error().Err(err).Str("Namespace", namespaceName).Msg("Stall detection skipped rollout")
+			continue
+		}
+		entity, err := namespace.findEntity(entityName)
+		if err != nil {
+			e.logger.Error().Err(err).Str("Namespace", namespaceName).Str("Entity", entityName).Msg("Stall detection skipped rollout")
+			continue
+		}
+
+		err = e.enqueueOrchestration(namespaceName, entityName, func(context.Context) {
+			if err := entity.detectStall(); err != nil {
+				entity.logger.Error().Err(err).Msg("Stall detection failed")
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStalledRollout(t *testing.T) {
+	const testName = "TestStalledRollout"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	clock := &offsetClock{}
+	DefaultClock = clock
+	defer func() { DefaultClock = systemClock{} }()
+
+	var stalled []Event
+	unsubscribe := engine.events.Subscribe(func(event Event) {
+		if event.Type == EventRolloutStalled && event.Namespace == testName {
+			stalled = append(stalled, event)
+		}
+	})
+	defer unsubscribe()
+
+	clientTargets, err := setupNamespace(engine, testName, testName, 4)
+	require.NoError(t, err)
+
+	progress, err := engine.GetRolloutProgress(testName, testName)
+	require.NoError(t, err)
+	assert.True(t, progress.InProgress)
+	assert.Equal(t, "v2", progress.Version)
+	assert.Equal(t, 4, progress.TotalTargets)
+	assert.False(t, progress.ProgressTimestamp.IsZero())
+	assert.Nil(t, progress.Stall)
+
+	// no target reported since rollout last progressed
+	clock.offset = defaultStallTimeout + time.Minute
+	progress, err = engine.GetRolloutProgress(testName, testName)
+	require.NoError(t, err)
+	require.NotNil(t, progress.Stall)
+	assert.Equal(t, StallNoTargetsReporting, progress.Stall.Reason)
+
+	// rollout left behind by a deleted namespace does not stop detection
+	orphaned := map[string]any{}
+	require.NoError(t, engine.store.LoadJSON(rolloutPrefix+testName+"/"+testName, &orphaned))
+	require.NoError(t, engine.store.SaveJSON(rolloutPrefix+"orphaned/"+testName, orphaned))
+
+	require.NoError(t, engine.detectStalledRollouts())
+	require.Eventually(t, func() bool {
+		rollout, err := engine.GetRolloutInfo(testName, testName)
+		return err == nil && rollout.Stall != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, stalled, 1)
+	assert.Contains(t, stalled[0].Message, string(StallNoTargetsReporting))
+
+	// stall is recorded once
+	require.NoError(t, engine.detectStalledRollouts())
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, stalled, 1)
+
+	// targets reaching rolling version clear stall
+	for _, clientTarget := range clientTargets {
+		clientTarget.Version = "v2"
+	}
+	_, err = engine.Orchestrate(testName, testName, clientTargets)
+	require.NoError(t, err)
+	rollout, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Nil(t, rollout.Stall)
+
+	_, err = engine.GetRolloutProgress(testName, "missing")
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+}
+
+func TestStallReason(t *testing.T) {
+	since := nowUTC().Add(-time.Hour)
+	rollout := &Rollout{State: RolloutState{
+		RolloutVersionInfo: RolloutVersionInfo{RollingVersion: "v2", LastKnownGoodVersion: "v1"},
+		ProgressTimestamp:  since,
+	}}
+	assert.True(t, rollout.stallDue(nowUTC()))
+
+	rollout.State.Options = &RolloutOptions{StallTimeoutSecs: 7200}
+	assert.False(t, rollout.stallDue(nowUTC()))
+	rollout.State.Options = nil
+
+	rollout.State.Paused = true
+	assert.False(t, rollout.stallDue(nowUTC()))
+	rollout.State.Paused = false
+
+	rollout.State.RollingVersion = "v1"
+	assert.False(t, rollout.stallDue(nowUTC()))
+
+	assert.ErrorIs(t, (&RolloutOptions{BatchPercent: 10, StallTimeoutSecs: -1}).Validate(), ErrInvalidRolloutOptions)
+}
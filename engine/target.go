@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClientState as reported by clients,
+//
+//		transformed to EntityTarget
+//	 Orchestrator updates with target state
+//	 Client could belong to a specific group making it easier to orchestrate
+type ClientState struct {
+	Name    string `json:"name,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Tags    string `json:"tags,omitempty"`
+	Version string `json:"version,omitempty"`
+	Message string `json:"message,omitempty"`
+	IsError bool   `json:"isError,omitempty"`
+	// Labels matched by TargetSelector of rollout options
+	Labels map[string]string `json:"labels,omitempty"`
+	// Metrics reported by target, used for canary analysis
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	// Directives returned by orchestrator with assigned version, ignored when reported by clients
+	Directives *AgentDirectives `json:"directives,omitempty"`
+	// LastSeen is last time target reported status or heartbeat, ignored when reported by clients
+	LastSeen time.Time `json:"lastseen,omitempty"`
+	// Pin of target pinned to a version or excluded from rollouts, ignored when reported by clients
+	Pin *TargetPin `json:"pin,omitempty"`
+	// Quarantined targets failed repeatedly and are excluded from rollouts until released, ignored when reported by clients
+	Quarantined bool `json:"quarantined,omitempty"`
+	// VersionOrder of version relative to last known good version, one of older, equal or newer, only set for
+	// entities with semver version scheme, ignored when reported by clients
+	VersionOrder string `json:"versionorder,omitempty"`
+}
+
+// Message reported for each target
+type Message struct {
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	IsError   bool      `json:"isError,omitempty"`
+}
+
+// ChangeInfo links a version change back to its approval record
+type ChangeInfo struct {
+	// Ticket is change ticket ID approving the change
+	Ticket string `json:"ticket,omitempty"`
+	// Note describing the change
+	Note string `json:"note,omitempty"`
+	// Expedited rolls out version using emergency profile of rollout options
+	Expedited bool `json:"expedited,omitempty"`
+}
+
+// EntityTargetVersion used as an input, otherwise unused anywhere else
+type EntityTargetVersion struct {
+	Version    string `json:"version,omitempty"`
+	ChangeInfo `json:",inline"`
+}
+
+// EntityVersionInfo contains version information
+type EntityVersionInfo struct {
+	Version string `json:"version,omitempty"`
+	// changeTimestamp at which this version was created
+	// typically this is the time the version was switched
+	ChangeTimestamp time.Time `json:"changetimestamp,omitempty"`
+	// last message
+	LastMessage Message `json:"lastmessage,omitempty"`
+}
+
+// EntityTargetState and list of properties stored internally
+type EntityTargetState struct {
+	CurrentVersion       EntityVersionInfo `json:"currentversion,omitempty"`
+	TargetVersion        EntityVersionInfo `json:"targetversion,omitempty"`
+	LastUpdatedTimestamp time.Time         `json:"lastupdatedtimestamp,omitempty"`
+	// last time target reported status or heartbeat
+	LastSeenTimestamp time.Time `json:"lastseentimestamp,omitempty"`
+	// last reported metrics for current version
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	// number of consecutive monitoring failures
+	ConsecutiveFailures int `json:"consecutivefailures,omitempty"`
+	// quarantined targets are excluded from rollout until released
+	Quarantined         bool      `json:"quarantined,omitempty"`
+	QuarantineTimestamp time.Time `json:"quarantinetimestamp,omitempty"`
+	// pinned targets are excluded from rollout until unpinned
+	Pin *TargetPin `json:"pin,omitempty"`
+	// retries of rolling version after target failed it
+	Retry *TargetRetry `json:"retry,omitempty"`
+}
+
+// EntityTarget contains Entity name, and any properties,
+// to uniquely identify a target
+type EntityTarget struct {
+	Name   string            `json:"name,omitempty"`
+	Group  string            `json:"group,omitempty"`
+	Tags   string            `json:"tags,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	State  EntityTargetState `json:"state,omitempty"`
+	// events queued for timeline of the target, saved along with target
+	events []*TargetEvent
+}
+
+type EntityTargets = []*EntityTarget
+
+// TargetState of target with respect to rollouts
+type TargetState string
+
+const (
+	// TargetStateActive targets are part of rollouts
+	TargetStateActive TargetState = "active"
+	// TargetStateQuarantined targets failed repeatedly and are excluded from rollouts until released
+	TargetStateQuarantined TargetState = "quarantined"
+	// TargetStatePinned targets are pinned to a version or excluded from rollouts until unpinned
+	TargetStatePinned TargetState = "pinned"
+)
+
+func (s TargetState) Validate() error {
+	switch s {
+	case "", TargetStateActive, TargetStateQuarantined, TargetStatePinned:
+		return nil
+	}
+	return fmt.Errorf("%w: %s, must be one of active, quarantined or pinned", ErrInvalidTargetState, s)
+}
+
+// TargetFilter selects targets by assigned version, error state and target state, empty fields match every target
+type TargetFilter struct {
+	Version string
+	IsError *bool
+	State   TargetState
+}
+
+func (f TargetFilter) matches(entityTarget *EntityTarget) bool {
+	if f.Version != "" && entityTarget.State.TargetVersion.Version != f.Version {
+		return false
+	}
+	if f.IsError != nil && entityTarget.State.TargetVersion.LastMessage.IsError != *f.IsError {
+		return false
+	}
+	switch f.State {
+	case TargetStateActive:
+		return !entityTarget.State.Quarantined && entityTarget.State.Pin == nil
+	case TargetStateQuarantined:
+		return entityTarget.State.Quarantined
+	case TargetStatePinned:
+		return entityTarget.State.Pin != nil
+	}
+	return true
+}
+
+func (m *Message) Success(message string) {
+	m.Message = message
+	m.Timestamp = nowUTC()
+	m.IsError = false
+}
+
+func (m *Message) Error(message string) {
+	m.Message = message
+	m.Timestamp = nowUTC()
+	m.IsError = true
+}
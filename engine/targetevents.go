@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+const (
+	targetEventsPrefix = "targetevents:"
+	// MaxTargetEvents is number of events kept per target, oldest events are dropped first
+	MaxTargetEvents = 100
+)
+
+// TargetEventType is type of event in timeline of a target
+type TargetEventType string
+
+const (
+	// TargetEventCreated is recorded when target reports for the first time
+	TargetEventCreated TargetEventType = "Created"
+	// TargetEventVersionReported is recorded when target reports running a different version
+	TargetEventVersionReported TargetEventType = "VersionReported"
+	// TargetEventErrorReported is recorded when target starts reporting errors
+	TargetEventErrorReported TargetEventType = "ErrorReported"
+	// TargetEventErrorCleared is recorded when target stops reporting errors
+	TargetEventErrorCleared TargetEventType = "ErrorCleared"
+	// TargetEventSelected is recorded when target is selected in a batch and assigned rolling version
+	TargetEventSelected TargetEventType = "Selected"
+	// TargetEventRolledBack is recorded when target is assigned lkg version after rolling version is marked bad
+	TargetEventRolledBack TargetEventType = "RolledBack"
+	// TargetEventVersionAssigned is recorded when target is assigned a version outside of a batch
+	TargetEventVersionAssigned TargetEventType = "VersionAssigned"
+	// TargetEventSucceeded is recorded when target passes monitoring of assigned version
+	TargetEventSucceeded TargetEventType = "Succeeded"
+	// TargetEventFailed is recorded when target fails monitoring of assigned version
+	TargetEventFailed TargetEventType = "Failed"
+	// TargetEventRetried is recorded when failing target is moved back to last known good version to be retried
+	TargetEventRetried TargetEventType = "Retried"
+	// TargetEventQuarantined is recorded when target is quarantined after consecutive failures
+	TargetEventQuarantined TargetEventType = "Quarantined"
+	// TargetEventReleased is recorded when target is released from quarantine
+	TargetEventReleased TargetEventType = "Released"
+	// TargetEventPinned is recorded when target is pinned to a version or excluded from rollouts
+	TargetEventPinned TargetEventType = "Pinned"
+	// TargetEventUnpinned is recorded when target is unpinned
+	TargetEventUnpinned TargetEventType = "Unpinned"
+)
+
+// TargetEvent is a change of version or error state of a target
+type TargetEvent struct {
+	Type TargetEventType `json:"type,omitempty"`
+	// Version reported by target for reported events, otherwise version assigned to target
+	Version   string    `json:"version,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// targetTimeline is bounded event history of a target, oldest first
+type targetTimeline struct {
+	Events []*TargetEvent `json:"events,omitempty"`
+}
+
+// addEvent queues event of target, queued events are saved along with target
+func (t *EntityTarget) addEvent(eventType TargetEventType, version, message string) {
+	t.events = append(t.events, &TargetEvent{
+		Type:      eventType,
+		Version:   version,
+		Message:   message,
+		Timestamp: nowUTC(),
+	})
+}
+
+func (e *Entity) targetEventsPrefix() string {
+	return fmt.Sprintf("%s%s/%s/", targetEventsPrefix, e.Namespace, e.Name)
+}
+
+func (e *Entity) targetEventsKey(group, name string) string {
+	return fmt.Sprintf("%s%s/%s", e.targetEventsPrefix(), group, name)
+}
+
+func (e *Entity) findTargetTimeline(group, name string) (*targetTimeline, error) {
+	timeline := &targetTimeline{}
+	if err := e.store.LoadJSON(e.targetEventsKey(group, name), timeline); err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+	return timeline, nil
+}
+
+// saveTargetEvents appends queued events of targets to their timelines, targets without events are skipped
+func (e *Entity) saveTargetEvents(entityTargets ...*EntityTarget) error {
+	batch := make(map[string]any)
+	for _, entityTarget := range entityTargets {
+		if len(entityTarget.events) <= 0 {
+			continue
+		}
+		key := e.targetEventsKey(entityTarget.Group, entityTarget.Name)
+		timeline, ok := batch[key].(*targetTimeline)
+		if !ok {
+			var err error
+			if timeline, err = e.findTargetTimeline(entityTarget.Group, entityTarget.Name); err != nil {
+				return err
+			}
+		}
+		timeline.Events = append(timeline.Events, entityTarget.events...)
+		if len(timeline.Events) > MaxTargetEvents {
+			timeline.Events = timeline.Events[len(timeline.Events)-MaxTargetEvents:]
+		}
+		entityTarget.events = nil
+		batch[key] = timeline
+	}
+	if len(batch) <= 0 {
+		return nil
+	}
+	return e.store.SaveJSONBatch(batch)
+}
+
+// getTargetEvents returns events of target newest first, skipping offset events and returning at most limit events
+func (e *Entity) getTargetEvents(group, name string, offset, limit int) ([]*TargetEvent, error) {
+	if err := e.store.LoadJSON(e.entityTargetKey(group, name), &EntityTarget{}); err != nil {
+		return nil, err
+	}
+
+	timeline, err := e.findTargetTimeline(group, name)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*TargetEvent, 0, len(timeline.Events))
+	for i := len(timeline.Events) - 1; i >= 0; i-- {
+		events = append(events, timeline.Events[i])
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(events) {
+		return []*TargetEvent{}, nil
+	}
+	events = events[offset:]
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (n *Namespace) getTargetEvents(entityName, group, name string, offset, limit int) ([]*TargetEvent, error) {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.getTargetEvents(group, name, offset, limit)
+}
+
+// GetTargetEvents returns version changes, error transitions, selections and rollbacks of target newest first,
+// skipping offset events and returning at most limit events
+func (e *Engine) GetTargetEvents(namespaceName, entityName, group, name string, offset, limit int) ([]*TargetEvent, error) {
+	namespace, err := e.findNamespace(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.getTargetEvents(entityName, group, name, offset, limit)
+}
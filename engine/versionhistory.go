@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxVersionHistory is number of last known good and bad versions kept per entity
+const maxVersionHistory = 20
+
+// Reasons of versions marked last known good or bad
+const (
+	VersionMarkInitial          = "initial version of entity"
+	VersionMarkRolloutSucceeded = "rollout succeeded"
+	VersionMarkRolloutFailed    = "rollout failed"
+	VersionMarkAnalysisFailed   = "canary analysis failed"
+	VersionMarkForced           = "replaced by forced target version"
+	VersionMarkManual           = "manual"
+	VersionMarkCleared          = "cleared manually"
+)
+
+// VersionMark records version becoming last known good or bad version of entity, version is empty once
+// last known bad version is cleared
+type VersionMark struct {
+	Version   string    `json:"version,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason,omitempty"`
+	// Note of manual changes
+	Note string `json:"note,omitempty"`
+}
+
+// VersionOverride manually sets last known good or bad version of entity
+type VersionOverride struct {
+	Version string `json:"version"`
+	// Note describing why version is overridden, e.g. flaky monitor
+	Note string `json:"note,omitempty"`
+}
+
+// appendVersionMark appends mark keeping the last maxVersionHistory marks
+func appendVersionMark(history []VersionMark, mark VersionMark) []VersionMark {
+	history = append(history, mark)
+	if len(history) > maxVersionHistory {
+		history = slices.Clone(history[len(history)-maxVersionHistory:])
+	}
+	return history
+}
+
+// setLastKnownGood changes last known good version and records it in history
+func (r *Rollout) setLastKnownGood(version, reason, note string) {
+	if r.State.LastKnownGoodVersion == version {
+		return
+	}
+	r.State.LastKnownGoodVersion = version
+	r.State.LastKnownGoodHistory = appendVersionMark(r.State.LastKnownGoodHistory,
+		VersionMark{Version: version, Timestamp: nowUTC(), Reason: reason, Note: note})
+}
+
+// setLastKnownBad changes last known bad version and records it in history
+func (r *Rollout) setLastKnownBad(version, reason, note string) {
+	if r.State.LastKnownBadVersion == version {
+		return
+	}
+	r.State.LastKnownBadVersion = version
+	r.State.LastKnownBadHistory = appendVersionMark(r.State.LastKnownBadHistory,
+		VersionMark{Version: version, Timestamp: nowUTC(), Reason: reason, Note: note})
+}
+
+// overrideLastKnownGood pins last known good version to a version which was last known good before, rollbacks
+// and targets outside rollout move to it
+func (r *Rollout) overrideLastKnownGood(override *VersionOverride) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	known := slices.ContainsFunc(r.State.LastKnownGoodHistory, func(mark VersionMark) bool {
+		return strings.EqualFold(mark.Version, override.Version)
+	})
+	if override.Version == "" || !known {
+		return fmt.Errorf("%w: %q was never last known good version", ErrInvalidTargetVersion, override.Version)
+	}
+	if strings.EqualFold(override.Version, r.State.LastKnownBadVersion) {
+		return fmt.Errorf("%w: %s is last known bad version, clear it first", ErrVersionConflict, override.Version)
+	}
+
+	r.logger.Info().Str("LastKnownGoodVersion", override.Version).Str("Note", override.Note).Msg("Overriding last known good version")
+	r.setLastKnownGood(override.Version, VersionMarkManual, override.Note)
+	return nil
+}
+
+// overrideLastKnownBad marks version bad, nil override clears last known bad version so a version wrongly
+// marked bad, e.g. by a flaky monitor, rolls out again
+func (r *Rollout) overrideLastKnownBad(override *VersionOverride) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if override == nil {
+		r.logger.Info().Str("LastKnownBadVersion", r.State.LastKnownBadVersion).Msg("Clearing last known bad version")
+		r.setLastKnownBad("", VersionMarkCleared, "")
+		return nil
+	}
+	if override.Version == "" {
+		return fmt.Errorf("%w: version is required", ErrInvalidTargetVersion)
+	}
+	if strings.EqualFold(override.Version, r.State.LastKnownGoodVersion) {
+		return fmt.Errorf("%w: %s is last known good version", ErrVersionConflict, override.Version)
+	}
+
+	r.logger.Info().Str("LastKnownBadVersion", override.Version).Str("Note", override.Note).Msg("Overriding last known bad version")
+	r.setLastKnownBad(override.Version, VersionMarkManual, override.Note)
+	return nil
+}
+
+func (e *Entity) overrideLastKnownVersion(good bool, override *VersionOverride) error {
+	rollout, err := e.findOrCreateRollout()
+	if err != nil {
+		return err
+	}
+
+	if good {
+		err = rollout.overrideLastKnownGood(override)
+	} else {
+		err = rollout.overrideLastKnownBad(override)
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.store.SaveJSON(e.rolloutKey(), rollout)
+}
+
+func (n *Namespace) overrideLastKnownVersion(entityName string, good bool, override *VersionOverride) error {
+	entity, err := n.findEntity(entityName)
+	if err != nil {
+		return err
+	}
+	return entity.overrideLastKnownVersion(good, override)
+}
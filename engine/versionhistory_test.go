@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendVersionMark(t *testing.T) {
+	var history []VersionMark
+	for i := 0; i < maxVersionHistory+5; i++ {
+		history = appendVersionMark(history, VersionMark{Version: fmt.Sprintf("v%d", i)})
+	}
+	require.Len(t, history, maxVersionHistory)
+	assert.Equal(t, "v5", history[0].Version)
+	assert.Equal(t, fmt.Sprintf("v%d", maxVersionHistory+4), history[maxVersionHistory-1].Version)
+}
+
+func TestLastKnownVersionOverrides(t *testing.T) {
+	const testName = "TestLastKnownVersionOverrides"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = setupNamespace(engine, testName, testName, 4)
+	require.NoError(t, err)
+
+	rollout, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	require.NotEmpty(t, rollout.LastKnownGoodHistory)
+	assert.Equal(t, "v1", rollout.LastKnownGoodHistory[len(rollout.LastKnownGoodHistory)-1].Version)
+
+	// rolling version wrongly marked bad is cleared
+	require.NoError(t, engine.SetLastKnownBad(testName, testName, &VersionOverride{Version: "v2", Note: "flaky monitor"}))
+	rollout, err = engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rollout.LastKnownBadVersion)
+	mark := rollout.LastKnownBadHistory[len(rollout.LastKnownBadHistory)-1]
+	assert.Equal(t, "v2", mark.Version)
+	assert.Equal(t, VersionMarkManual, mark.Reason)
+	assert.Equal(t, "flaky monitor", mark.Note)
+
+	require.NoError(t, engine.ClearLastKnownBad(testName, testName))
+	rollout, err = engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Empty(t, rollout.LastKnownBadVersion)
+	mark = rollout.LastKnownBadHistory[len(rollout.LastKnownBadHistory)-1]
+	assert.Empty(t, mark.Version)
+	assert.Equal(t, VersionMarkCleared, mark.Reason)
+
+	assert.ErrorIs(t, engine.SetLastKnownBad(testName, testName, &VersionOverride{Version: "v1"}), ErrVersionConflict)
+	assert.ErrorIs(t, engine.SetLastKnownBad(testName, testName, &VersionOverride{}), ErrInvalidTargetVersion)
+
+	// only versions of history may be pinned as last known good
+	assert.ErrorIs(t, engine.SetLastKnownGood(testName, testName, &VersionOverride{Version: "v9"}), ErrInvalidTargetVersion)
+	require.NoError(t, engine.SetLastKnownGood(testName, testName, &VersionOverride{Version: "v1"}))
+
+	assert.ErrorIs(t, engine.ClearLastKnownBad(testName, "missing"), ErrEntityNotFound)
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nixmade/orchestrator/store"
+)
+
+// VersionScheme decides how versions of entity are ordered
+type VersionScheme string
+
+const (
+	// VersionSchemeNone does not order versions, any version may be set as target version, default
+	VersionSchemeNone VersionScheme = ""
+	// VersionSchemeSemver orders versions as semantic versions, e.g. v1.2.3, target versions lower than
+	// last known good version are rejected unless forced
+	VersionSchemeSemver VersionScheme = "semver"
+)
+
+// Version order of target version relative to last known good version of entities with VersionSchemeSemver
+const (
+	VersionOrderOlder = "older"
+	VersionOrderEqual = "equal"
+	VersionOrderNewer = "newer"
+)
+
+func (s VersionScheme) Validate() error {
+	switch s {
+	case VersionSchemeNone, VersionSchemeSemver:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidVersionScheme, s)
+	}
+}
+
+// versionScheme returns configured scheme, defaults to VersionSchemeNone
+func (o *RolloutOptions) versionScheme() VersionScheme {
+	if o == nil {
+		return VersionSchemeNone
+	}
+	return o.VersionScheme
+}
+
+// guardDowngrade rejects target versions which are not semantic versions or are lower than last known good
+// version of entities with VersionSchemeSemver
+func (r *Rollout) guardDowngrade(version string) error {
+	if r.State.Options.versionScheme() != VersionSchemeSemver {
+		return nil
+	}
+	if _, _, ok := parseTagVersion(version); !ok {
+		return fmt.Errorf("%w: %s is not a semantic version", ErrInvalidTargetVersion, version)
+	}
+	lastKnownGood := r.State.LastKnownGoodVersion
+	if _, _, ok := parseTagVersion(lastKnownGood); !ok {
+		// no last known good version or it predates version scheme
+		return nil
+	}
+	if compareTags(version, lastKnownGood) < 0 {
+		return fmt.Errorf("%w: %s is lower than last known good version %s, force to downgrade",
+			ErrVersionDowngrade, version, lastKnownGood)
+	}
+	return nil
+}
+
+// versionOrder returns order of version relative to last known good version, empty if either is not a
+// semantic version
+func versionOrder(version, lastKnownGood string) string {
+	if _, _, ok := parseTagVersion(version); !ok {
+		return ""
+	}
+	if _, _, ok := parseTagVersion(lastKnownGood); !ok {
+		return ""
+	}
+	switch compareTags(version, lastKnownGood) {
+	case -1:
+		return VersionOrderOlder
+	case 1:
+		return VersionOrderNewer
+	}
+	return VersionOrderEqual
+}
+
+// findVersionOrder returns function ordering versions of targets against last known good version,
+// nil unless entity has VersionSchemeSemver
+func (e *Entity) findVersionOrder() (func(version string) string, error) {
+	rollout := &Rollout{}
+	if err := e.store.LoadJSON(e.rolloutKey(), rollout); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if rollout.State.Options.versionScheme() != VersionSchemeSemver {
+		return nil, nil
+	}
+
+	lastKnownGood := rollout.State.LastKnownGoodVersion
+	return func(version string) string {
+		return versionOrder(version, lastKnownGood)
+	}, nil
+}
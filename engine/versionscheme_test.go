@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionOrder(t *testing.T) {
+	assert.Equal(t, VersionOrderOlder, versionOrder("v1.9.0", "v1.10.0"))
+	assert.Equal(t, VersionOrderEqual, versionOrder("1.2.3", "v1.2.3"))
+	assert.Equal(t, VersionOrderNewer, versionOrder("v1.2.3", "v1.2.3-rc1"))
+	assert.Empty(t, versionOrder("latest", "v1.2.3"))
+	assert.Empty(t, versionOrder("v1.2.3", ""))
+
+	assert.NoError(t, VersionSchemeSemver.Validate())
+	assert.ErrorIs(t, VersionScheme("calver").Validate(), ErrInvalidVersionScheme)
+}
+
+func TestSemverDowngradeProtection(t *testing.T) {
+	const testName = "TestSemverDowngradeProtection"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	_, err = setupNamespace(engine, testName, testName, 4)
+	require.NoError(t, err)
+
+	// without version scheme any version may be set
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v0.9.0"}))
+
+	options := DefaultRolloutOptions()
+	options.VersionScheme = VersionSchemeSemver
+	require.NoError(t, engine.SetRolloutOptions(testName, testName, options))
+
+	err = engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v0.9.1"})
+	assert.ErrorIs(t, err, ErrVersionDowngrade)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.ErrorIs(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "latest"}), ErrInvalidTargetVersion)
+	require.NoError(t, engine.SetTargetVersion(testName, testName, EntityTargetVersion{Version: "v1.1.0"}))
+
+	require.NoError(t, engine.ForceTargetVersion(testName, testName, EntityTargetVersion{Version: "v0.9.1"}))
+	rollout, err := engine.GetRolloutInfo(testName, testName)
+	require.NoError(t, err)
+	assert.Equal(t, "v0.9.1", rollout.TargetVersion)
+
+	clientStates, err := engine.GetClientState(testName, testName)
+	require.NoError(t, err)
+	require.Len(t, clientStates, 4)
+	for _, clientState := range clientStates {
+		assert.Equal(t, versionOrder(clientState.Version, rollout.LastKnownGoodVersion), clientState.VersionOrder, clientState.Name)
+		assert.NotEmpty(t, clientState.VersionOrder, clientState.Name)
+	}
+}
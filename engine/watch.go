@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nixmade/orchestrator/redact"
+	"github.com/nixmade/orchestrator/store"
+)
+
+// watchedPrefixes are keys whose state is cached by engine, changes invalidate cached state
+var watchedPrefixes = []string{redactionPrefix}
+
+// watchStore invalidates cached state whenever watched keys change, including changes written by other replicas
+func (e *Engine) watchStore() {
+	prefixes := watchedPrefixes
+	if e.cache != nil {
+		prefixes = append(append([]string{}, watchedPrefixes...), cachedPrefixes...)
+	}
+	for _, prefix := range prefixes {
+		events, cancel := e.store.Watch(prefix)
+		e.stopWatches = append(e.stopWatches, cancel)
+		go func() {
+			for event := range events {
+				e.invalidate(event)
+			}
+		}()
+	}
+}
+
+// stopWatchingStore cancels store watches started by watchStore
+func (e *Engine) stopWatchingStore() {
+	for _, cancel := range e.stopWatches {
+		cancel()
+	}
+	e.stopWatches = nil
+}
+
+// invalidate refreshes state cached from changed key, state is reloaded from store
+// so events delivered late never overwrite newer state
+func (e *Engine) invalidate(event store.KeyEvent) {
+	switch {
+	case e.cache != nil && (strings.HasPrefix(event.Key, rolloutPrefix) || strings.HasPrefix(event.Key, entityTargetPrefix)):
+		e.cache.invalidate(event)
+	case strings.HasPrefix(event.Key, redactionPrefix):
+		rules := redact.Rules{}
+		if err := e.store.LoadJSON(event.Key, &rules); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+			e.logger.Error().Err(err).Str("Key", event.Key).Msg("failed to refresh redaction rules")
+			return
+		}
+		if err := redact.Default.SetNamespaceRules(strings.TrimPrefix(event.Key, redactionPrefix), rules); err != nil {
+			e.logger.Error().Err(err).Str("Key", event.Key).Msg("failed to refresh redaction rules")
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchInvalidatesRedactionRules(t *testing.T) {
+	const testName = "TestWatchInvalidatesRedactionRules"
+	engine, err := setupTestEngine(testName)
+	require.NoError(t, err)
+	defer cleanupTestEngine(t, engine, testName)
+
+	redacted := func() bool {
+		record := redact.Default.Record(map[string]any{"Namespace": testName, "Message": "sensitive"})
+		return record["Message"] == redact.Redacted
+	}
+
+	// rules written by another replica sharing the store, written again until badger subscription is registered
+	require.Eventually(t, func() bool {
+		assert.NoError(t, engine.store.SaveJSON(namespaceRedactionKey(testName), &redact.Rules{Fields: []string{"Message"}}))
+		return redacted()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, engine.store.Delete(namespaceRedactionKey(testName)))
+	require.Eventually(t, func() bool { return !redacted() }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nixmade/orchestrator/signature"
+)
+
+// WebControllerAuth authenticates calls of web controllers to their endpoints
+type WebControllerAuth struct {
+	// BearerToken sent as Authorization: Bearer <token>
+	BearerToken string `json:"bearertoken,omitempty"`
+	// Username and Password sent as basic auth
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// HMACSecret signs timestamp and request body, see signature package to verify on endpoints
+	HMACSecret string `json:"hmacsecret,omitempty"`
+}
+
+func (a *WebControllerAuth) Validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.BearerToken != "" && a.Username != "" {
+		return fmt.Errorf("%w: bearertoken and basic auth are exclusive", ErrInvalidWebControllerAuth)
+	}
+	if a.Password != "" && a.Username == "" {
+		return fmt.Errorf("%w: basic auth requires username", ErrInvalidWebControllerAuth)
+	}
+	return nil
+}
+
+// apply sets authorization and signature headers of request with body
+func (a *WebControllerAuth) apply(req *http.Request, body []byte) {
+	if a == nil {
+		return
+	}
+	switch {
+	case a.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	case a.Username != "":
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	if a.HMACSecret != "" {
+		signature.SignRequest(req, a.HMACSecret, body, time.Now())
+	}
+}
+
+func (e *EntityWebTargetController) Validate() error {
+	if err := e.WebControllerPolicy.Validate(); err != nil {
+		return err
+	}
+	return e.Auth.Validate()
+}
+
+func (e *EntityWebMonitoringController) Validate() error {
+	if err := e.WebControllerPolicy.Validate(); err != nil {
+		return err
+	}
+	return e.Auth.Validate()
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebControllerAuth(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		var err error
+		body, err = io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		fmt.Fprint(w, `{"status": "ok"}`)
+	}))
+	defer srv.Close()
+
+	controller := &EntityWebTargetController{MonitoringEndpoint: srv.URL, Auth: &WebControllerAuth{BearerToken: "token"}}
+	require.NoError(t, controller.TargetMonitoring(&ClientState{Name: "target"}))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Empty(t, header.Get(SignatureHeader))
+
+	controller.Auth = &WebControllerAuth{Username: "user", Password: "pass", HMACSecret: "secret"}
+	require.NoError(t, controller.TargetMonitoring(&ClientState{Name: "target"}))
+	req := &http.Request{Header: header}
+	username, password, ok := req.BasicAuth()
+	require.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+	assert.NoError(t, signature.Verify(header, body, "secret", signature.DefaultTolerance, time.Now()))
+
+	monitoringController := &EntityWebMonitoringController{ExternalMonitoringEndpoint: srv.URL, Auth: &WebControllerAuth{HMACSecret: "secret"}}
+	require.NoError(t, monitoringController.ExternalMonitoring([]*ClientState{{Name: "target"}}))
+	assert.Empty(t, header.Get("Authorization"))
+	assert.NoError(t, signature.Verify(header, body, "secret", signature.DefaultTolerance, time.Now()))
+	assert.ErrorIs(t, signature.Verify(header, body, "other", signature.DefaultTolerance, time.Now()), signature.ErrInvalidSignature)
+
+	assert.NoError(t, (&EntityWebTargetController{}).Validate())
+	assert.ErrorIs(t, (&EntityWebTargetController{Auth: &WebControllerAuth{BearerToken: "token", Username: "user"}}).Validate(), ErrInvalidWebControllerAuth)
+	assert.ErrorIs(t, (&EntityWebMonitoringController{Auth: &WebControllerAuth{Password: "pass"}}).Validate(), ErrInvalidWebControllerAuth)
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const (
+	// FailClosed fails call of web controller if endpoint is unreachable, default
+	FailClosed = "closed"
+	// FailOpen proceeds as if endpoint was not configured if it is unreachable
+	FailOpen = "open"
+
+	defaultBackoffMillis    = 100
+	defaultMaxBackoffMillis = 5000
+)
+
+// WebControllerPolicy controls timeout, retries and failure handling of web controller endpoint calls
+type WebControllerPolicy struct {
+	// TimeoutSecs of each attempt, 0 waits for endpoint indefinitely
+	TimeoutSecs int `json:"timeoutsecs,omitempty"`
+	// Retries after first attempt if endpoint is unreachable, times out or returns 429 or 5xx
+	Retries int `json:"retries,omitempty"`
+	// BackoffMillis before first retry doubles with every retry up to MaxBackoffMillis, with jitter,
+	// defaults to 100 and 5000
+	BackoffMillis    int `json:"backoffmillis,omitempty"`
+	MaxBackoffMillis int `json:"maxbackoffmillis,omitempty"`
+	// FailurePolicy once retries are exhausted, closed fails the call and open proceeds as if
+	// endpoint was not configured, selecting and approving all targets, removing none and passing monitoring
+	FailurePolicy string `json:"failurepolicy,omitempty"`
+}
+
+// endpointStatusError is a non 200 response of web controller endpoint
+type endpointStatusError struct {
+	url        string
+	statusCode int
+}
+
+func (e *endpointStatusError) Error() string {
+	return fmt.Sprintf("%s: %s returned status %d", ErrExternalControllerFailure, e.url, e.statusCode)
+}
+
+func (e *endpointStatusError) Unwrap() error {
+	return ErrExternalControllerFailure
+}
+
+// cancelOnClose cancels attempt timeout once response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func (p *WebControllerPolicy) Validate() error {
+	if p.TimeoutSecs < 0 || p.Retries < 0 || p.BackoffMillis < 0 || p.MaxBackoffMillis < 0 {
+		return fmt.Errorf("%w: timeoutsecs, retries and backoff must not be negative", ErrInvalidWebControllerPolicy)
+	}
+	switch p.FailurePolicy {
+	case "", FailClosed, FailOpen:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown failure policy %q", ErrInvalidWebControllerPolicy, p.FailurePolicy)
+}
+
+// backoff returns delay before retry, doubling with every retry and jittered between half and full delay
+func (p *WebControllerPolicy) backoff(retry int) time.Duration {
+	base, maxBackoff := p.BackoffMillis, p.MaxBackoffMillis
+	if base <= 0 {
+		base = defaultBackoffMillis
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoffMillis
+	}
+
+	delay := time.Duration(base) * time.Millisecond
+	for i := 0; i < retry && delay < time.Duration(maxBackoff)*time.Millisecond; i++ {
+		delay *= 2
+	}
+	delay = min(delay, time.Duration(maxBackoff)*time.Millisecond)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// failOpen checks if call failed because endpoint is unreachable and proceeds as if endpoint was not configured,
+// endpoint responding with a client error still fails the call
+func (p *WebControllerPolicy) failOpen(err error) bool {
+	return err != nil && p.FailurePolicy == FailOpen && retryable(err)
+}
+
+func retryable(err error) bool {
+	var statusErr *endpointStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode == http.StatusTooManyRequests || statusErr.statusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// post sends postBuf to url retrying unreachable endpoint with backoff, returns body of successful response
+func (p *WebControllerPolicy) post(ctx context.Context, url string, postBuf []byte, auth *WebControllerAuth) (io.ReadCloser, error) {
+	ctx = controllerContext(ctx)
+	for retry := 0; ; retry++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.TimeoutSecs > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, time.Duration(p.TimeoutSecs)*time.Second)
+		}
+
+		respBody, err := makeRequest(attemptCtx, url, postBuf, auth)
+		if err == nil {
+			return &cancelOnClose{ReadCloser: respBody, cancel: cancel}, nil
+		}
+		cancel()
+
+		if retry >= p.Retries || !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(p.backoff(retry)):
+		}
+	}
+}
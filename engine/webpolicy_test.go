@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebControllerPolicyBackoff(t *testing.T) {
+	policy := &WebControllerPolicy{BackoffMillis: 100, MaxBackoffMillis: 1000}
+	for retry, limit := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		delay := policy.backoff(retry)
+		assert.GreaterOrEqual(t, delay, limit*time.Millisecond/2, retry)
+		assert.LessOrEqual(t, delay, limit*time.Millisecond, retry)
+	}
+
+	assert.NoError(t, (&WebControllerPolicy{FailurePolicy: FailOpen}).Validate())
+	assert.ErrorIs(t, (&WebControllerPolicy{FailurePolicy: "sometimes"}).Validate(), ErrInvalidWebControllerPolicy)
+	assert.ErrorIs(t, (&WebControllerPolicy{Retries: -1}).Validate(), ErrInvalidWebControllerPolicy)
+}
+
+func TestWebControllerRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// unavailable for first two calls
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"status": "ok"}`)
+	}))
+	defer srv.Close()
+
+	controller := &EntityWebTargetController{MonitoringEndpoint: srv.URL}
+	controller.BackoffMillis = 1
+	assert.ErrorIs(t, controller.TargetMonitoring(&ClientState{Name: "target"}), ErrExternalControllerFailure)
+	assert.Equal(t, int32(1), calls.Load())
+
+	calls.Store(0)
+	controller.Retries = 2
+	assert.NoError(t, controller.TargetMonitoring(&ClientState{Name: "target"}))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestWebControllerClientErrorNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	controller := &EntityWebTargetController{ApprovalEndpoint: srv.URL}
+	controller.Retries = 3
+	controller.FailurePolicy = FailOpen
+	_, err := controller.TargetApproval([]*ClientState{{Name: "target"}})
+	assert.ErrorIs(t, err, ErrExternalControllerFailure)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWebControllerFailurePolicy(t *testing.T) {
+	// endpoint which does not respond within timeout, body is drained so client disconnect cancels request context
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	targets := []*ClientState{{Name: "target0"}, {Name: "target1"}}
+	controller := &EntityWebTargetController{
+		SelectionEndpoint:  srv.URL,
+		ApprovalEndpoint:   srv.URL,
+		MonitoringEndpoint: srv.URL,
+		RemovalEndpoint:    srv.URL,
+	}
+	controller.TimeoutSecs = 1
+
+	_, err := controller.TargetApproval(targets)
+	assert.Error(t, err)
+
+	controller.FailurePolicy = FailOpen
+	selected, err := controller.TargetSelection(targets, 1)
+	require.NoError(t, err)
+	assert.Equal(t, targets, selected)
+
+	approved, err := controller.TargetApproval(targets)
+	require.NoError(t, err)
+	assert.Equal(t, targets, approved)
+
+	assert.NoError(t, controller.TargetMonitoring(targets[0]))
+
+	removed, err := controller.TargetRemoval(targets, 1)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	// unreachable endpoint
+	srv.Close()
+	monitoringController := &EntityWebMonitoringController{ExternalMonitoringEndpoint: srv.URL}
+	assert.Error(t, monitoringController.ExternalMonitoring(targets))
+	monitoringController.FailurePolicy = FailOpen
+	assert.NoError(t, monitoringController.ExternalMonitoring(targets))
+}
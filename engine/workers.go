@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultWorkers is number of async orchestration workers if not configured
+const DefaultWorkers = 8
+
+type jobState int
+
+const (
+	jobQueued jobState = iota
+	jobRunning
+	// jobRerun is a running job enqueued again, it is queued once current run completes
+	jobRerun
+)
+
+// orchestrationJob orchestrates entity key namespace/entity
+type orchestrationJob struct {
+	namespace string
+	key       string
+	run       func()
+}
+
+// workerPool runs async orchestrations with a bounded number of workers, namespaces are served round robin
+// so a busy namespace does not starve others, and each entity is orchestrated by at most one worker at a time
+type workerPool struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	// namespaces with queued jobs in round robin order
+	namespaces []string
+	queues     map[string][]*orchestrationJob
+	states     map[string]jobState
+	reruns     map[string]*orchestrationJob
+	closed     bool
+	workers    sync.WaitGroup
+}
+
+func newWorkerPool(workers int) *workerPool {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	p := &workerPool{
+		queues: make(map[string][]*orchestrationJob),
+		states: make(map[string]jobState),
+		reruns: make(map[string]*orchestrationJob),
+	}
+	p.cond = sync.NewCond(&p.lock)
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// enqueue queues job, a job already queued for the same entity is coalesced since orchestration
+// reads latest state, a running job is run again once it completes
+func (p *workerPool) enqueue(job *orchestrationJob) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrEngineShutdown
+	}
+
+	state, ok := p.states[job.key]
+	switch {
+	case !ok:
+		p.push(job)
+	case state == jobRunning || state == jobRerun:
+		p.states[job.key] = jobRerun
+		p.reruns[job.key] = job
+	}
+	return nil
+}
+
+// push queues job, caller holds lock
+func (p *workerPool) push(job *orchestrationJob) {
+	if len(p.queues[job.namespace]) <= 0 {
+		p.namespaces = append(p.namespaces, job.namespace)
+	}
+	p.queues[job.namespace] = append(p.queues[job.namespace], job)
+	p.states[job.key] = jobQueued
+	p.cond.Signal()
+}
+
+// next returns next job of next namespace, nil once pool is closed and drained, caller holds lock
+func (p *workerPool) next() *orchestrationJob {
+	for len(p.namespaces) <= 0 {
+		if p.closed {
+			return nil
+		}
+		p.cond.Wait()
+	}
+
+	namespace := p.namespaces[0]
+	p.namespaces = p.namespaces[1:]
+	job := p.queues[namespace][0]
+	p.queues[namespace] = p.queues[namespace][1:]
+	if len(p.queues[namespace]) > 0 {
+		p.namespaces = append(p.namespaces, namespace)
+	} else {
+		delete(p.queues, namespace)
+	}
+	p.states[job.key] = jobRunning
+	return job
+}
+
+func (p *workerPool) work() {
+	defer p.workers.Done()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for {
+		job := p.next()
+		if job == nil {
+			return
+		}
+
+		p.lock.Unlock()
+		job.run()
+		p.lock.Lock()
+
+		if p.states[job.key] == jobRerun {
+			rerun := p.reruns[job.key]
+			delete(p.reruns, job.key)
+			p.push(rerun)
+			continue
+		}
+		delete(p.states, job.key)
+	}
+}
+
+// close stops accepting jobs and waits for queued jobs to complete
+func (p *workerPool) close() {
+	p.lock.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.lock.Unlock()
+
+	p.workers.Wait()
+}
+
+// enqueueOrchestration queues run on worker pool, run holds entity lock and its ctx is cancelled once
+// lock is lost
+func (e *Engine) enqueueOrchestration(namespaceName, entityName string, run func(ctx context.Context)) error {
+	return e.workers.enqueue(&orchestrationJob{
+		namespace: namespaceName,
+		key:       entityLockKey(namespaceName, entityName),
+		run: func() {
+			ctx, unlock, err := e.lockEntityContext(e.ctx, namespaceName, entityName)
+			if err != nil {
+				e.logger.Error().Err(err).Str("Namespace", namespaceName).Str("Entity", entityName).Msg("Async rollout orchestrate failed")
+				return
+			}
+			defer unlock()
+			run(ctx)
+		},
+	})
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPoolBounded(t *testing.T) {
+	pool := newWorkerPool(2)
+
+	var running, maxRunning, completed atomic.Int32
+	release := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		require.NoError(t, pool.enqueue(&orchestrationJob{
+			namespace: fmt.Sprintf("ns%d", i%3),
+			key:       fmt.Sprintf("ns%d/entity%d", i%3, i),
+			run: func() {
+				current := running.Add(1)
+				for {
+					max := maxRunning.Load()
+					if current <= max || maxRunning.CompareAndSwap(max, current) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+				completed.Add(1)
+			},
+		}))
+	}
+
+	require.Eventually(t, func() bool { return running.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	pool.close()
+
+	assert.Equal(t, int32(2), maxRunning.Load())
+	assert.Equal(t, int32(10), completed.Load())
+	assert.ErrorIs(t, pool.enqueue(&orchestrationJob{namespace: "ns", key: "ns/entity", run: func() {}}), ErrEngineShutdown)
+}
+
+func TestWorkerPoolSerializesEntity(t *testing.T) {
+	pool := newWorkerPool(4)
+
+	var running, runs atomic.Int32
+	overlapped := false
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	job := &orchestrationJob{
+		namespace: "ns",
+		key:       "ns/entity",
+		run: func() {
+			if running.Add(1) > 1 {
+				overlapped = true
+			}
+			runs.Add(1)
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+			running.Add(-1)
+		},
+	}
+
+	require.NoError(t, pool.enqueue(job))
+	<-started
+	// enqueued while running, coalesced into a single rerun
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pool.enqueue(job))
+	}
+	close(release)
+	pool.close()
+
+	assert.False(t, overlapped)
+	assert.Equal(t, int32(2), runs.Load())
+}
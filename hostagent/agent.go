@@ -0,0 +1,196 @@
+// Package hostagent reports the version installed on a host as an orchestrator target and runs an upgrade
+// command when the orchestrator assigns the host a new version
+package hostagent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nixmade/orchestrator/agentutil"
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultCommandTimeout = 10 * time.Minute
+	defaultRetries        = 3
+	defaultRetryBackoff   = 5 * time.Second
+	maxRetryBackoff       = 2 * time.Minute
+
+	// VersionEnv and PreviousVersionEnv are set for upgrade command to the assigned and installed versions
+	VersionEnv         = "ORCHESTRATOR_VERSION"
+	PreviousVersionEnv = "ORCHESTRATOR_PREVIOUS_VERSION"
+)
+
+// Options configures target reported by agent and commands it runs
+type Options struct {
+	// Namespace is orchestrator namespace and Entity is orchestrator entity host is a target of
+	Namespace string
+	Entity    string
+	agentutil.Options
+
+	// Name of target, usually hostname, and Group it belongs to e.g. datacenter
+	Name  string
+	Group string
+	// Labels of target for target selectors
+	Labels map[string]string
+
+	// VersionCommand prints installed version on stdout
+	VersionCommand string
+	// UpgradeCommand installs version in ORCHESTRATOR_VERSION, it must be safe to rerun after failures
+	UpgradeCommand string
+	// HealthCommand optionally checks installed version, target reports error while it fails
+	HealthCommand string
+	// CommandTimeout limits every command run, defaults to 10m
+	CommandTimeout time.Duration
+
+	// Retries of failed upgrade command before reporting failure, defaults to 3, negative disables retries
+	Retries int
+	// RetryBackoff before first retry doubles with every retry up to 2m, defaults to 5s
+	RetryBackoff time.Duration
+}
+
+// Agent reports host version to orchestrator and applies assigned versions
+type Agent struct {
+	orchestrator client.Interface
+	options      Options
+	logger       zerolog.Logger
+
+	// failed is last upgrade failure, reported until an upgrade succeeds or installed version is assigned again
+	failed error
+}
+
+// New creates agent reporting host to orchestrator
+func New(orchestrator client.Interface, options Options, logger zerolog.Logger) *Agent {
+	if options.CommandTimeout <= 0 {
+		options.CommandTimeout = defaultCommandTimeout
+	}
+	if options.Retries == 0 {
+		options.Retries = defaultRetries
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = defaultRetryBackoff
+	}
+	return &Agent{orchestrator: orchestrator, options: options, logger: logger}
+}
+
+// Run syncs host with agentutil.Run
+func (a *Agent) Run(ctx context.Context) error {
+	return agentutil.Run(ctx, a.options.Interval, a.logger, "Failed to sync host", a.Sync)
+}
+
+// state detects installed version and health of host
+func (a *Agent) state(ctx context.Context) (*engine.ClientState, error) {
+	version, err := runCommand(ctx, a.options.VersionCommand, a.options.CommandTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("version command: %w", err)
+	}
+	// last line is the version, scripts may log before printing it
+	if lines := strings.Split(version, "\n"); len(lines) > 0 {
+		version = strings.TrimSpace(lines[len(lines)-1])
+	}
+	if version == "" {
+		return nil, ErrEmptyVersion
+	}
+
+	state := &engine.ClientState{
+		Name:    a.options.Name,
+		Group:   a.options.Group,
+		Version: version,
+		Message: "running",
+		Labels:  a.options.Labels,
+	}
+	if a.options.HealthCommand != "" {
+		if _, err := runCommand(ctx, a.options.HealthCommand, a.options.CommandTimeout, VersionEnv+"="+version); err != nil {
+			state.Message = fmt.Sprintf("health command: %v", err)
+			state.IsError = true
+		}
+	}
+	if a.failed != nil {
+		state.Message = a.failed.Error()
+		state.IsError = true
+	}
+	return state, nil
+}
+
+// report posts state of host and returns version assigned to it, empty if host is not assigned
+func (a *Agent) report(ctx context.Context, state *engine.ClientState) (string, error) {
+	assigned, err := a.orchestrator.Orchestrate(ctx, a.options.Namespace, a.options.Entity, []*engine.ClientState{state})
+	if err != nil {
+		return "", err
+	}
+	for _, assignment := range assigned {
+		if assignment.Name == state.Name && assignment.Group == state.Group {
+			return assignment.Version, nil
+		}
+	}
+	return "", nil
+}
+
+// Sync reports host to orchestrator once, upgrades host if it is assigned a different version and reports the result
+func (a *Agent) Sync(ctx context.Context) error {
+	state, err := a.state(ctx)
+	if err != nil {
+		return err
+	}
+	version, err := a.report(ctx, state)
+	if err != nil {
+		return err
+	}
+	if version == state.Version {
+		// failure is resolved once host is assigned its installed version again, e.g. after a rollback
+		a.failed = nil
+	}
+	if version == "" || version == state.Version {
+		return nil
+	}
+
+	a.failed = a.upgrade(ctx, state.Version, version)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// report outcome right away, orchestrator should not wait an interval to see the new version or failure
+	state, err = a.state(ctx)
+	if err != nil {
+		return err
+	}
+	if a.failed == nil && state.Version != version {
+		a.failed = fmt.Errorf("upgrade to %s succeeded but version command reports %s", version, state.Version)
+		state.Message = a.failed.Error()
+		state.IsError = true
+	}
+	if _, err := a.report(ctx, state); err != nil {
+		return err
+	}
+	return a.failed
+}
+
+// upgrade runs upgrade command, retrying with backoff if it fails
+func (a *Agent) upgrade(ctx context.Context, previous, version string) error {
+	backoff := a.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		a.logger.Info().Str("Version", version).Str("PreviousVersion", previous).Int("Attempt", attempt+1).Msg("Upgrading host")
+
+		started := time.Now()
+		output, err := runCommand(ctx, a.options.UpgradeCommand, a.options.CommandTimeout, VersionEnv+"="+version, PreviousVersionEnv+"="+previous)
+		if err == nil {
+			a.logger.Info().Str("Version", version).Dur("Duration", time.Since(started)).Str("Output", output).Msg("Upgraded host")
+			return nil
+		}
+		a.logger.Error().Err(err).Str("Version", version).Int("Attempt", attempt+1).Str("Output", output).Msg("Upgrade command failed")
+
+		if attempt >= a.options.Retries {
+			return fmt.Errorf("upgrade to %s failed after %d attempts: %w", version, attempt+1, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
@@ -0,0 +1,97 @@
+package hostagent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient/clienttest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAgent(t *testing.T, version, upgradeCommand string) (*Agent, *clienttest.Server, string) {
+	orchestrator := clienttest.NewServer(t)
+	require.NoError(t, orchestrator.SetTargetVersion(context.Background(), "shop", "web", &engine.EntityTargetVersion{Version: version}))
+
+	dir := t.TempDir()
+	versionFile := filepath.Join(dir, "version")
+	require.NoError(t, os.WriteFile(versionFile, []byte("v1\n"), 0o600))
+	t.Setenv("VERSION_FILE", versionFile)
+	t.Setenv("ATTEMPTS_FILE", filepath.Join(dir, "attempts"))
+
+	return New(orchestrator.Client(), Options{
+		Namespace:      "shop",
+		Entity:         "web",
+		Name:           "host1",
+		Group:          "dc1",
+		VersionCommand: `echo detecting >&2; cat "$VERSION_FILE"`,
+		UpgradeCommand: upgradeCommand,
+		Retries:        2,
+		RetryBackoff:   time.Millisecond,
+	}, zerolog.Nop()), orchestrator, versionFile
+}
+
+func TestAgentSyncUpgrades(t *testing.T) {
+	agent, orchestrator, versionFile := newTestAgent(t, "v2",
+		`test "$ORCHESTRATOR_PREVIOUS_VERSION" = v1 && echo "$ORCHESTRATOR_VERSION" > "$VERSION_FILE"`)
+
+	require.NoError(t, agent.Sync(context.Background()))
+
+	data, err := os.ReadFile(versionFile)
+	require.NoError(t, err)
+	assert.Equal(t, "v2\n", string(data))
+
+	reported := orchestrator.Reported("shop", "web")
+	require.Len(t, reported, 2)
+	assert.Equal(t, "v1", reported[0].Version)
+	assert.Equal(t, "v2", reported[1].Version)
+	assert.Equal(t, "host1", reported[1].Name)
+	assert.Equal(t, "dc1", reported[1].Group)
+	assert.False(t, reported[1].IsError)
+}
+
+func TestAgentSyncRetries(t *testing.T) {
+	// fails first attempt
+	agent, _, versionFile := newTestAgent(t, "v2",
+		`echo x >> "$ATTEMPTS_FILE"; test "$(wc -l < "$ATTEMPTS_FILE")" -ge 2 && echo "$ORCHESTRATOR_VERSION" > "$VERSION_FILE"`)
+
+	require.NoError(t, agent.Sync(context.Background()))
+	data, err := os.ReadFile(versionFile)
+	require.NoError(t, err)
+	assert.Equal(t, "v2\n", string(data))
+}
+
+func TestAgentSyncFailure(t *testing.T) {
+	agent, orchestrator, _ := newTestAgent(t, "v2", `echo "disk full" >&2; exit 1`)
+
+	err := agent.Sync(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed after 3 attempts")
+	assert.Contains(t, err.Error(), "disk full")
+
+	reported := orchestrator.Reported("shop", "web")
+	require.Len(t, reported, 2)
+	assert.True(t, reported[1].IsError)
+	assert.Equal(t, "v1", reported[1].Version)
+
+	// rollback to installed version clears failure
+	require.NoError(t, orchestrator.SetTargetVersion(context.Background(), "shop", "web", &engine.EntityTargetVersion{Version: "v1"}))
+
+	require.NoError(t, agent.Sync(context.Background()))
+	require.NoError(t, agent.Sync(context.Background()))
+
+	reported = orchestrator.Reported("shop", "web")[2:]
+	require.Len(t, reported, 2)
+	assert.True(t, reported[0].IsError)
+	assert.False(t, reported[1].IsError)
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	_, err := runCommand(context.Background(), "sleep 5", 50*time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,64 @@
+package hostagent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// maxOutput is output of commands kept for logs and target messages, commands may be chatty package managers
+	maxOutput = 4 << 10
+	// waitDelay is how long pipes of a killed command may stay open, children of the shell may inherit them
+	waitDelay = 5 * time.Second
+)
+
+// ErrEmptyVersion returns an error if version command succeeded without printing a version
+var ErrEmptyVersion = errors.New("version command printed no version")
+
+// tailBuffer keeps the last maxOutput bytes written
+type tailBuffer struct {
+	bytes.Buffer
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n, err := b.Buffer.Write(p)
+	if b.Len() > maxOutput {
+		b.Next(b.Len() - maxOutput)
+	}
+	return n, err
+}
+
+// runCommand runs command with sh -c, env is appended to agent environment, returns trimmed stdout and
+// error including trimmed stderr if command fails or does not finish within timeout
+func runCommand(ctx context.Context, command string, timeout time.Duration, env ...string) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.WaitDelay = waitDelay
+	killGroup(cmd)
+	var stdout, stderr tailBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return strings.TrimSpace(stdout.String()), fmt.Errorf("%w: %s", err, output)
+		}
+		return strings.TrimSpace(stdout.String()), err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
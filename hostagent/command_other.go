@@ -0,0 +1,8 @@
+//go:build !unix
+
+package hostagent
+
+import "os/exec"
+
+// killGroup is a no op, only the shell is killed on cancel
+func killGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package hostagent
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup runs command in its own process group and kills the whole group on cancel,
+// otherwise processes started by the shell outlive a timed out upgrade
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
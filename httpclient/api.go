@@ -1,6 +1,10 @@
 package httpclient
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
 
 type API struct {
 	endpoint string
@@ -16,54 +20,323 @@ func (api *API) URL() string {
 	return fmt.Sprintf("%s/%s/%s", api.endpoint, api.version, api.resource)
 }
 
+type AdminAPI struct {
+	*API
+}
+
+func NewAdminAPI(endpoint string) *AdminAPI {
+	return &AdminAPI{API: NewAPI(endpoint, "v1", "admin")}
+}
+
+// Deprecated: use client.Client.ReadOnly
+func (api *AdminAPI) ReadOnly() string {
+	return fmt.Sprintf("%s/readonly", api.URL())
+}
+
+func (api *AdminAPI) Freeze() string {
+	return fmt.Sprintf("%s/freeze", api.URL())
+}
+
+// Deprecated: use client.Client.LogLevel
+func (api *AdminAPI) LogLevel() string {
+	return fmt.Sprintf("%s/loglevel", api.URL())
+}
+
 type OrchestratorAPI struct {
 	*API
+	retry   RetryPolicy
+	breaker *CircuitBreaker
 }
 
-func NewOrchestratorAPI(endpoint string) *OrchestratorAPI {
-	return &OrchestratorAPI{API: NewAPI(endpoint, "v1", "orchestrate")}
+// NewOrchestratorAPI builds urls of orchestrator at endpoint, its GetJSON and PostJSON
+// send requests once unless opts add retries or circuit breaker
+func NewOrchestratorAPI(endpoint string, opts ...ClientOption) *OrchestratorAPI {
+	api := &OrchestratorAPI{API: NewAPI(endpoint, "v1", "orchestrate")}
+	for _, opt := range opts {
+		opt(api)
+	}
+	return api
 }
 
+// Deprecated: use client.Client.Orchestrate
 func (api *OrchestratorAPI) Orchestrate(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s", api.URL(), namespace, entity)
 }
 
+// Deprecated: use client.Client.OrchestrateBatch
+func (api *OrchestratorAPI) OrchestrateBatch() string {
+	return fmt.Sprintf("%s/batch", api.URL())
+}
+
+func (api *OrchestratorAPI) Namespace(namespace string) string {
+	return fmt.Sprintf("%s/%s", api.URL(), namespace)
+}
+
+// Deprecated: use client.Client.DeleteEntity
+func (api *OrchestratorAPI) Entity(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Target(namespace, entity, group, name string) string {
+	return fmt.Sprintf("%s/%s/%s/target/%s?group=%s", api.URL(), namespace, entity, name, url.QueryEscape(group))
+}
+
+func (api *OrchestratorAPI) TargetPin(namespace, entity, group, name string) string {
+	return fmt.Sprintf("%s/%s/%s/target/%s/pin?group=%s", api.URL(), namespace, entity, name, url.QueryEscape(group))
+}
+
+func (api *OrchestratorAPI) TargetEvents(namespace, entity, group, name string) string {
+	return fmt.Sprintf("%s/%s/%s/target/%s/events?group=%s", api.URL(), namespace, entity, name, url.QueryEscape(group))
+}
+
+func (api *OrchestratorAPI) TargetHeartbeat(namespace, entity, group, name string) string {
+	return fmt.Sprintf("%s/%s/%s/target/%s/heartbeat?group=%s", api.URL(), namespace, entity, name, url.QueryEscape(group))
+}
+
+// Deprecated: use client.Client.SetTargetVersion
 func (api *OrchestratorAPI) TargetVersion(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/version", api.URL(), namespace, entity)
 }
 
+// Deprecated: use client.Client.ForceTargetVersion
+func (api *OrchestratorAPI) ForceTargetVersion(namespace, entity string) string {
+	return api.TargetVersion(namespace, entity) + "?force=true"
+}
+
+func (api *OrchestratorAPI) QueuedVersions(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/version/queue", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Schedule(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/schedule", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Split(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/split", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Analysis(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/analysis", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) AnalysisRuns(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/analysis/runs", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) RegistryWatch(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/registrywatch", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) RegistryWatchApprove(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/registrywatch/approve", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) EntityConfig(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/config", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Audit(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/audit", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.SetRolloutOptions
 func (api *OrchestratorAPI) RolloutOptions(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/options", api.URL(), namespace, entity)
 }
 
+// Deprecated: use client.Client.ValidateRolloutOptions
+func (api *OrchestratorAPI) ValidateRolloutOptions(namespace, entity string) string {
+	return api.RolloutOptions(namespace, entity) + "?validate=true"
+}
+
 func (api *OrchestratorAPI) EntityTargetController(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/target/controller", api.URL(), namespace, entity)
 }
 
+func (api *OrchestratorAPI) HashCohortTargetController(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/target/cohort", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) GrpcTargetController(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/target/grpc", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) WasmTargetController(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/target/wasm", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) SlackApprovalController(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/target/slack", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) ConsulTargetController(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/target/consul", api.URL(), namespace, entity)
+}
+
 func (api *OrchestratorAPI) EntityMonitoringController(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/monitoring/controller", api.URL(), namespace, entity)
 }
 
+func (api *OrchestratorAPI) PromMonitoringController(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/monitoring/prometheus", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.Status
 func (api *OrchestratorAPI) Status(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/status", api.URL(), namespace, entity)
 }
 
+// StatusPage returns url of up to limit targets after cursor, next cursor is returned in NextCursorHeader
+//
+// Deprecated: use client.Client.StatusPage
+func (api *OrchestratorAPI) StatusPage(namespace, entity, cursor string, limit int) string {
+	return fmt.Sprintf("%s/%s/%s/status?cursor=%s&limit=%d", api.URL(), namespace, entity, url.QueryEscape(cursor), limit)
+}
+
+// StatusAtVersion returns url of targets assigned version
+//
+// Deprecated: use client.Client.StatusAtVersion
+func (api *OrchestratorAPI) StatusAtVersion(namespace, entity, version string) string {
+	return fmt.Sprintf("%s/%s/%s/status?version=%s", api.URL(), namespace, entity, url.QueryEscape(version))
+}
+
+// StatusInState returns url of targets in state, one of active, quarantined or pinned
+//
+// Deprecated: use client.Client.StatusInState
+func (api *OrchestratorAPI) StatusInState(namespace, entity, state string) string {
+	return fmt.Sprintf("%s/%s/%s/status?state=%s", api.URL(), namespace, entity, url.QueryEscape(state))
+}
+
+// ErrorStatus returns url of targets in error
+//
+// Deprecated: use client.Client.ErrorStatus
+func (api *OrchestratorAPI) ErrorStatus(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/status?error=true", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.Namespaces
 func (api *OrchestratorAPI) Namespaces() string {
 	return fmt.Sprintf("%s/namespaces", api.URL())
 }
 
+func (api *OrchestratorAPI) Controllers() string {
+	return fmt.Sprintf("%s/controllers", api.URL())
+}
+
+// Deprecated: use client.Client.Entities
 func (api *OrchestratorAPI) Entities(namespace string) string {
 	return fmt.Sprintf("%s/%s/entities", api.URL(), namespace)
 }
 
+// Deprecated: use client.Client.RolloutInfo
 func (api *OrchestratorAPI) RolloutInfo(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/rollout", api.URL(), namespace, entity)
 }
 
+// Deprecated: use client.Client.RolloutProgress
+func (api *OrchestratorAPI) RolloutProgress(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/progress", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.SimulateRollout
+func (api *OrchestratorAPI) SimulateRollout(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/rollout/simulate", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.RolloutHistory
+func (api *OrchestratorAPI) RolloutHistory(namespace, entity string, offset, limit int) string {
+	return fmt.Sprintf("%s/%s/%s/rollouts?offset=%d&limit=%d", api.URL(), namespace, entity, offset, limit)
+}
+
+func (api *OrchestratorAPI) Snapshots(namespace, entity string, sinceSecs int) string {
+	return fmt.Sprintf("%s/%s/%s/snapshots?sincesecs=%d", api.URL(), namespace, entity, sinceSecs)
+}
+
+func (api *OrchestratorAPI) Quarantine(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/quarantine", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.ReleaseQuarantinedTarget
+func (api *OrchestratorAPI) QuarantineRelease(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/quarantine/release", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.Pause
+func (api *OrchestratorAPI) Pause(namespace, entity, group string) string {
+	return fmt.Sprintf("%s/%s/%s/pause?group=%s", api.URL(), namespace, entity, url.QueryEscape(group))
+}
+
+// Deprecated: use client.Client.Resume
+func (api *OrchestratorAPI) Resume(namespace, entity, group string) string {
+	return fmt.Sprintf("%s/%s/%s/resume?group=%s", api.URL(), namespace, entity, url.QueryEscape(group))
+}
+
+// Deprecated: use client.Client.SetLastKnownGood
+func (api *OrchestratorAPI) LastKnownGood(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/lastknowngood", api.URL(), namespace, entity)
+}
+
+// Deprecated: use client.Client.SetLastKnownBad
+func (api *OrchestratorAPI) LastKnownBad(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/lastknownbad", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Notifications(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/notifications", api.URL(), namespace, entity)
+}
+
+func (api *OrchestratorAPI) Quota(namespace string) string {
+	return fmt.Sprintf("%s/namespace/%s/quota", api.URL(), namespace)
+}
+
+func (api *OrchestratorAPI) GroupRules(namespace string) string {
+	return fmt.Sprintf("%s/namespace/%s/grouprules", api.URL(), namespace)
+}
+
+// Deprecated: use client.Client.DependencyGraph
+func (api *OrchestratorAPI) Dependencies(namespace string) string {
+	return fmt.Sprintf("%s/namespace/%s/dependencies", api.URL(), namespace)
+}
+
+func (api *OrchestratorAPI) Freeze(namespace string) string {
+	return fmt.Sprintf("%s/namespace/%s/freeze", api.URL(), namespace)
+}
+
+func (api *OrchestratorAPI) Redaction(namespace string) string {
+	return fmt.Sprintf("%s/namespace/%s/redaction", api.URL(), namespace)
+}
+
+func (api *OrchestratorAPI) NamespaceSlack(namespace string) string {
+	return fmt.Sprintf("%s/namespace/%s/slack", api.URL(), namespace)
+}
+
+func (api *OrchestratorAPI) Slack(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/slack", api.URL(), namespace, entity)
+}
+
+// StatusStream returns url of server sent events of target state updates
+//
+// Deprecated: use client.Client.WatchStatus
+func (api *OrchestratorAPI) StatusStream(namespace, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/status/stream", api.URL(), namespace, entity)
+}
+
+// AgentSocket returns websocket url for long lived agents
+//
+// Deprecated: use client.Client.AgentSocketURL
+func (api *OrchestratorAPI) AgentSocket(namespace, entity string) string {
+	url := fmt.Sprintf("%s/%s/%s/agent", api.URL(), namespace, entity)
+	if strings.HasPrefix(url, "https://") {
+		return "wss://" + strings.TrimPrefix(url, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(url, "http://")
+}
+
 func (api *OrchestratorAPI) Targets(namespace, entity string) string {
 	return fmt.Sprintf("%s/%s/%s/targets", api.URL(), namespace, entity)
 }
 
+// Deprecated: use client.Client.GroupStatus
 func (api *OrchestratorAPI) GroupStatus(namespace, entity, group string) string {
 	return fmt.Sprintf("%s/%s/%s/%s/status", api.URL(), namespace, entity, group)
 }
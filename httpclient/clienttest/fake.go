@@ -0,0 +1,230 @@
+// Package clienttest provides a fake orchestrator for unit tests of agents and other api consumers.
+// Fake implements client.Interface in memory and Server serves the same fake over http for code using
+// client.Client, neither boots the engine, server or badger store.
+package clienttest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+)
+
+// Method names of Fake, errors set with Fail are returned by the method of the name
+const (
+	MethodOrchestrate       = "Orchestrate"
+	MethodReportStatus      = "ReportStatus"
+	MethodSetTargetVersion  = "SetTargetVersion"
+	MethodSetRolloutOptions = "SetRolloutOptions"
+	MethodStatus            = "Status"
+	MethodGroupStatus       = "GroupStatus"
+)
+
+// fakeEntity is state of an entity kept by Fake
+type fakeEntity struct {
+	version  string
+	options  *engine.RolloutOptions
+	targets  map[string]*engine.ClientState
+	reported []*engine.ClientState
+}
+
+// Fake orchestrator assigns target version of entity to every reported target, or version returned by Assign,
+// targets keep their reported version until entity has a target version. It is safe for concurrent use
+type Fake struct {
+	// Assign overrides version assigned to target reported for entity, called with lock of fake held
+	Assign func(namespace, entity string, target *engine.ClientState) string
+
+	lock     sync.Mutex
+	entities map[string]*fakeEntity
+	errors   map[string]error
+}
+
+var _ client.Interface = (*Fake)(nil)
+
+// NewFake creates fake without entities
+func NewFake() *Fake {
+	return &Fake{entities: make(map[string]*fakeEntity), errors: make(map[string]error)}
+}
+
+func entityKey(namespace, entity string) string {
+	return namespace + "/" + entity
+}
+
+func targetKey(target *engine.ClientState) string {
+	return target.Group + "/" + target.Name
+}
+
+// notFound is same error client returns for entities orchestrator does not know of
+func notFound(namespace, entity string) error {
+	return &httpclient.StatusError{
+		URL:        entityKey(namespace, entity),
+		StatusCode: http.StatusNotFound,
+		Message:    fmt.Sprintf("entity not found: %s/%s", namespace, entity),
+	}
+}
+
+// Fail makes method return err until it is called again with nil err, use *httpclient.StatusError
+// to fail with a status code such as 503, other errors are served by Server as 500
+func (f *Fake) Fail(method string, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err == nil {
+		delete(f.errors, method)
+		return
+	}
+	f.errors[method] = err
+}
+
+// findOrCreateEntity returns entity, creating it if missing, must be called with lock held
+func (f *Fake) findOrCreateEntity(namespace, entity string) *fakeEntity {
+	key := entityKey(namespace, entity)
+	e, ok := f.entities[key]
+	if !ok {
+		e = &fakeEntity{targets: make(map[string]*engine.ClientState)}
+		f.entities[key] = e
+	}
+	return e
+}
+
+// report records targets and assigns versions to them, must be called with lock held
+func (f *Fake) report(namespace, entity string, targets []*engine.ClientState) []*engine.ClientState {
+	e := f.findOrCreateEntity(namespace, entity)
+	assigned := make([]*engine.ClientState, 0, len(targets))
+	for _, target := range targets {
+		reported := *target
+		e.reported = append(e.reported, &reported)
+
+		version := reported.Version
+		if f.Assign != nil {
+			version = f.Assign(namespace, entity, &reported)
+		} else if e.version != "" {
+			version = e.version
+		}
+		current := reported
+		current.Version = version
+		e.targets[targetKey(&current)] = &current
+		assigned = append(assigned, &engine.ClientState{Name: reported.Name, Group: reported.Group, Version: version})
+	}
+	return assigned
+}
+
+// Orchestrate records targets and returns versions assigned to them
+func (f *Fake) Orchestrate(_ context.Context, namespace, entity string, targets []*engine.ClientState) ([]*engine.ClientState, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.errors[MethodOrchestrate]; err != nil {
+		return nil, err
+	}
+	return f.report(namespace, entity, targets), nil
+}
+
+// ReportStatus records targets and assigns versions to them without returning them
+func (f *Fake) ReportStatus(_ context.Context, namespace, entity string, targets []*engine.ClientState) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.errors[MethodReportStatus]; err != nil {
+		return err
+	}
+	f.report(namespace, entity, targets)
+	return nil
+}
+
+// SetTargetVersion sets version assigned to targets reported from now on
+func (f *Fake) SetTargetVersion(_ context.Context, namespace, entity string, version *engine.EntityTargetVersion) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.errors[MethodSetTargetVersion]; err != nil {
+		return err
+	}
+	f.findOrCreateEntity(namespace, entity).version = version.Version
+	return nil
+}
+
+// SetRolloutOptions records options of entity, fake does not roll out in batches
+func (f *Fake) SetRolloutOptions(_ context.Context, namespace, entity string, options *engine.RolloutOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.errors[MethodSetRolloutOptions]; err != nil {
+		return err
+	}
+	f.findOrCreateEntity(namespace, entity).options = options
+	return nil
+}
+
+// Status returns last reported state of targets of entity with their assigned versions
+func (f *Fake) Status(_ context.Context, namespace, entity string) ([]*engine.ClientState, error) {
+	return f.status(MethodStatus, namespace, entity, nil)
+}
+
+// GroupStatus returns last reported state of targets of group of entity with their assigned versions
+func (f *Fake) GroupStatus(_ context.Context, namespace, entity, group string) ([]*engine.ClientState, error) {
+	return f.status(MethodGroupStatus, namespace, entity, &group)
+}
+
+func (f *Fake) status(method, namespace, entity string, group *string) ([]*engine.ClientState, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.errors[method]; err != nil {
+		return nil, err
+	}
+	e, ok := f.entities[entityKey(namespace, entity)]
+	if !ok {
+		return nil, notFound(namespace, entity)
+	}
+
+	keys := make([]string, 0, len(e.targets))
+	for key, target := range e.targets {
+		if group == nil || target.Group == *group {
+			keys = append(keys, key)
+		}
+	}
+	// same key order as orchestrator
+	sort.Strings(keys)
+	clientStates := make([]*engine.ClientState, 0, len(keys))
+	for _, key := range keys {
+		clientState := *e.targets[key]
+		clientStates = append(clientStates, &clientState)
+	}
+	return clientStates, nil
+}
+
+// Reported returns every target reported for entity in order of reports
+func (f *Fake) Reported(namespace, entity string) []*engine.ClientState {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	e, ok := f.entities[entityKey(namespace, entity)]
+	if !ok {
+		return nil
+	}
+	reported := make([]*engine.ClientState, 0, len(e.reported))
+	for _, target := range e.reported {
+		clientState := *target
+		reported = append(reported, &clientState)
+	}
+	return reported
+}
+
+// TargetVersion returns version set for entity, empty if none
+func (f *Fake) TargetVersion(namespace, entity string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if e, ok := f.entities[entityKey(namespace, entity)]; ok {
+		return e.version
+	}
+	return ""
+}
+
+// RolloutOptions returns options set for entity, nil if none
+func (f *Fake) RolloutOptions(namespace, entity string) *engine.RolloutOptions {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if e, ok := f.entities[entityKey(namespace, entity)]; ok {
+		return e.options
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package clienttest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	_, err := fake.Status(ctx, "namespace", "entity")
+	require.ErrorIs(t, err, httpclient.ErrNotFound)
+
+	targets := []*engine.ClientState{
+		{Name: "target2", Group: "group1", Version: "v1"},
+		{Name: "target1", Group: "group1", Version: "v1"},
+		{Name: "target1", Group: "group2", Version: "v1"},
+	}
+	// targets keep reported version until entity has target version
+	assigned, err := fake.Orchestrate(ctx, "namespace", "entity", targets)
+	require.NoError(t, err)
+	for _, target := range assigned {
+		assert.Equal(t, "v1", target.Version)
+	}
+
+	require.NoError(t, fake.SetTargetVersion(ctx, "namespace", "entity", &engine.EntityTargetVersion{Version: "v2"}))
+	assert.Equal(t, "v2", fake.TargetVersion("namespace", "entity"))
+	assigned, err = fake.Orchestrate(ctx, "namespace", "entity", targets[:1])
+	require.NoError(t, err)
+	require.Len(t, assigned, 1)
+	assert.Equal(t, "v2", assigned[0].Version)
+
+	status, err := fake.GroupStatus(ctx, "namespace", "entity", "group1")
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	assert.Equal(t, "target1", status[0].Name)
+	assert.Equal(t, "v1", status[0].Version)
+	assert.Equal(t, "target2", status[1].Name)
+	assert.Equal(t, "v2", status[1].Version)
+
+	assert.Len(t, fake.Reported("namespace", "entity"), 4)
+
+	fake.Assign = func(namespace, entity string, target *engine.ClientState) string {
+		return "canary"
+	}
+	assigned, err = fake.Orchestrate(ctx, "namespace", "entity", targets[:1])
+	require.NoError(t, err)
+	assert.Equal(t, "canary", assigned[0].Version)
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer(t)
+	c := server.Client()
+
+	require.NoError(t, c.SetRolloutOptions(ctx, "namespace", "entity", &engine.RolloutOptions{BatchPercent: 50}))
+	assert.Equal(t, 50, server.RolloutOptions("namespace", "entity").BatchPercent)
+	require.NoError(t, c.SetTargetVersion(ctx, "namespace", "entity", &engine.EntityTargetVersion{Version: "v2"}))
+
+	targets := []*engine.ClientState{{Name: "target1", Group: "group1", Version: "v1"}}
+	assigned, err := c.Orchestrate(ctx, "namespace", "entity", targets)
+	require.NoError(t, err)
+	require.Len(t, assigned, 1)
+	assert.Equal(t, "v2", assigned[0].Version)
+
+	require.NoError(t, c.ReportStatus(ctx, "namespace", "entity", targets))
+	assert.Len(t, server.Reported("namespace", "entity"), 2)
+
+	status, err := c.GroupStatus(ctx, "namespace", "entity", "group1")
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	assert.Equal(t, "v2", status[0].Version)
+
+	_, err = c.Status(ctx, "namespace", "missing")
+	require.ErrorIs(t, err, httpclient.ErrNotFound)
+
+	server.Fail(MethodOrchestrate, &httpclient.StatusError{StatusCode: http.StatusConflict, Message: "rollout paused"})
+	_, err = c.Orchestrate(ctx, "namespace", "entity", targets)
+	require.ErrorIs(t, err, httpclient.ErrConflict)
+
+	server.Fail(MethodOrchestrate, nil)
+	_, err = c.Orchestrate(ctx, "namespace", "entity", targets)
+	require.NoError(t, err)
+}
@@ -0,0 +1,145 @@
+package clienttest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/nixmade/orchestrator/response"
+)
+
+// Server serves Fake on a random local port with routes of orchestrator for orchestrate, status,
+// target version and rollout options, so clients of the api can be tested over http
+type Server struct {
+	*Fake
+	Server *httptest.Server
+
+	t testing.TB
+}
+
+// NewServer creates server of new fake, server is closed when test completes
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{Fake: NewFake(), t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/orchestrate/{namespace}/{entity}", s.orchestrate)
+	mux.HandleFunc("POST /v1/orchestrate/{namespace}/{entity}/status", s.reportStatus)
+	mux.HandleFunc("POST /v1/orchestrate/{namespace}/{entity}/version", s.setTargetVersion)
+	mux.HandleFunc("POST /v1/orchestrate/{namespace}/{entity}/options", s.setRolloutOptions)
+	mux.HandleFunc("GET /v1/orchestrate/{namespace}/{entity}/status", s.status)
+	mux.HandleFunc("GET /v1/orchestrate/{namespace}/{entity}/{group}/status", s.status)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Server.Close)
+	return s
+}
+
+// Client creates typed client of server
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	s.t.Helper()
+	c, err := client.New(s.Server.URL, opts...)
+	if err != nil {
+		s.t.Fatalf("failed to create client of fake orchestrator: %s", err)
+	}
+	return c
+}
+
+// decode reads json body of request, decompressing bodies httpclient gzips
+func decode(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return false
+		}
+		body = reader
+	}
+	if err := json.NewDecoder(body).Decode(value); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// writeError writes status code of StatusError, 500 for other errors
+func writeError(w http.ResponseWriter, err error) {
+	var statusErr *httpclient.StatusError
+	if errors.As(err, &statusErr) {
+		response.Error(w, statusErr.StatusCode, statusErr.Message)
+		return
+	}
+	response.Error(w, http.StatusInternalServerError, err.Error())
+}
+
+func (s *Server) orchestrate(w http.ResponseWriter, r *http.Request) {
+	var targets []*engine.ClientState
+	if !decode(w, r, &targets) {
+		return
+	}
+	assigned, err := s.Orchestrate(r.Context(), r.PathValue("namespace"), r.PathValue("entity"), targets)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, assigned)
+}
+
+func (s *Server) reportStatus(w http.ResponseWriter, r *http.Request) {
+	var targets []*engine.ClientState
+	if !decode(w, r, &targets) {
+		return
+	}
+	if err := s.ReportStatus(r.Context(), r.PathValue("namespace"), r.PathValue("entity"), targets); err != nil {
+		writeError(w, err)
+		return
+	}
+	response.OK(w, "ok")
+}
+
+func (s *Server) setTargetVersion(w http.ResponseWriter, r *http.Request) {
+	version := &engine.EntityTargetVersion{}
+	if !decode(w, r, version) {
+		return
+	}
+	if err := s.SetTargetVersion(r.Context(), r.PathValue("namespace"), r.PathValue("entity"), version); err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, version)
+}
+
+func (s *Server) setRolloutOptions(w http.ResponseWriter, r *http.Request) {
+	options := &engine.RolloutOptions{}
+	if !decode(w, r, options) {
+		return
+	}
+	if err := s.SetRolloutOptions(r.Context(), r.PathValue("namespace"), r.PathValue("entity"), options); err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, options)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	var clientStates []*engine.ClientState
+	var err error
+	if group := r.PathValue("group"); group != "" {
+		clientStates, err = s.GroupStatus(r.Context(), r.PathValue("namespace"), r.PathValue("entity"), group)
+	} else {
+		clientStates, err = s.Status(r.Context(), r.PathValue("namespace"), r.PathValue("entity"))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, clientStates)
+}
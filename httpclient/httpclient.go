@@ -2,19 +2,84 @@ package httpclient
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"time"
+
+	"github.com/nixmade/orchestrator/tracing"
+)
+
+const (
+	// NextCursorHeader is set on paged status responses with cursor of the next page
+	NextCursorHeader = "X-Next-Cursor"
+	// NDJSONContentType is content type of status streamed one target per line
+	NDJSONContentType = "application/x-ndjson"
+	// RequestIDHeader of responses identifies request in server logs
+	RequestIDHeader = "X-Request-Id"
+	// gzipMinSize is size of request bodies above which they are sent gzip compressed,
+	// responses are decompressed by transport which asks for gzip on its own
+	gzipMinSize = 64 * 1024
+)
+
+var (
+	// ErrNotFound matches StatusError of namespace, entity or target that does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict matches StatusError of request conflicting with rolling version or paused rollout
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed matches StatusError of request based on a stale version
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrNotModified is returned by conditional gets of resources still matching etag of the request
+	ErrNotModified = errors.New("not modified")
 )
 
 type HttpError struct {
 	Message string `json:"message"`
 }
 
+// StatusError is returned for non 200 responses, use errors.Is with ErrNotFound,
+// ErrConflict or ErrPreconditionFailed to branch on kind of error
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Message    string
+	// RetryAfter is wait asked by server with Retry-After header, zero if absent
+	RetryAfter time.Duration
+	// RequestID is X-Request-Id of response, server logs of the request have the same RequestID
+	RequestID string
+}
+
+func (e *StatusError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s returned %d, details: %s, request id: %s", e.URL, e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%s returned %d, details: %s", e.URL, e.StatusCode, e.Message)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
+
 func defaultTransport() http.RoundTripper {
+	return NewTransport()
+}
+
+// NewTransport creates transport of orchestrator clients, TLS 1.3 only
+func NewTransport() *http.Transport {
 	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -37,11 +102,42 @@ func defaultTransport() http.RoundTripper {
 }
 
 func GetJSON(url, token string, value interface{}) error {
+	return GetJSONContext(context.Background(), url, token, value)
+}
+
+// GetJSONContext gets json with ctx, propagating trace context if any
+func GetJSONContext(ctx context.Context, url, token string, value interface{}) error {
+	_, err := getJSON(ctx, url, token, "", value)
+	return err
+}
+
+// GetJSONIfNoneMatch gets json of url unless it still has etag, returns etag of response to pass to the next get.
+// ErrNotModified is returned without decoding into value if nothing changed, empty etag always gets json
+func GetJSONIfNoneMatch(ctx context.Context, url, token, etag string, value interface{}) (string, error) {
+	header, err := getJSON(ctx, url, token, etag, value)
+	if header == nil {
+		return "", err
+	}
+	return header.Get("ETag"), err
+}
+
+// GetJSONPage gets page of paged status url, returns cursor of next page, empty on last page
+func GetJSONPage(url, token string, value interface{}) (string, error) {
+	header, err := getJSON(context.Background(), url, token, "", value)
+	if err != nil {
+		return "", err
+	}
+	return header.Get(NextCursorHeader), nil
+}
+
+// GetNDJSON streams targets of status url one line at a time, fn is called with each value as it is decoded
+// so large entities are never held in memory at once
+func GetNDJSON[T any](url, token string, fn func(*T) error) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", NDJSONContentType)
 	req.Header.Add("Authorization", token)
 	req.Close = true
 	http.DefaultClient.Transport = defaultTransport()
@@ -62,21 +158,96 @@ func GetJSON(url, token string, value interface{}) error {
 		return errorMessage(url, resp)
 	}
 
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		value := new(T)
+		if err := decoder.Decode(value); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(value); err != nil {
+			return err
+		}
+	}
+}
+
+func getJSON(ctx context.Context, url, token, etag string, value interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	tracing.Inject(ctx, req.Header)
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", token)
+	req.Close = true
+	http.DefaultClient.Transport = defaultTransport()
+	defer http.DefaultClient.CloseIdleConnections()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return resp.Header, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorMessage(url, resp)
+	}
+
 	if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
-		return err
+		return nil, err
 	}
 
-	return err
+	return resp.Header, err
 }
 
 func errorMessage(url string, resp *http.Response) error {
 	var httpError HttpError
 	if resp.Body != nil {
 		if err := json.NewDecoder(resp.Body).Decode(&httpError); err != nil {
-			return fmt.Errorf("%s returned %d, error: %s", url, resp.StatusCode, resp.Status)
+			httpError.Message = resp.Status
 		}
 	}
-	return fmt.Errorf("%s returned %d, details: %s", url, resp.StatusCode, httpError.Message)
+	return &StatusError{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Message:    httpError.Message,
+		RetryAfter: retryAfter(resp),
+		RequestID:  resp.Header.Get(RequestIDHeader),
+	}
+}
+
+// CheckResponse returns StatusError with message of non 200 response
+func CheckResponse(url string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	return errorMessage(url, resp)
+}
+
+// NewJSONRequest creates request with ctx and json body of in, if any, gzip compressed when large,
+// propagating trace context of ctx
+func NewJSONRequest(ctx context.Context, verb, url string, in interface{}) (*http.Request, error) {
+	req, err := newRequest(verb, url, in)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
 }
 
 func newRequest(verb, url string, in interface{}) (*http.Request, error) {
@@ -87,15 +258,38 @@ func newRequest(verb, url string, in interface{}) (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
-	return http.NewRequest(verb, url, bytes.NewBuffer(post))
+	if len(post) < gzipMinSize {
+		return http.NewRequest(verb, url, bytes.NewBuffer(post))
+	}
 
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(post); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(verb, url, &compressed)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	return req, nil
 }
 
 func PostJSON(url, token string, in interface{}, out interface{}) error {
+	return PostJSONContext(context.Background(), url, token, in, out)
+}
+
+// PostJSONContext posts json with ctx, propagating trace context if any
+func PostJSONContext(ctx context.Context, url, token string, in interface{}, out interface{}) error {
 	req, err := newRequest("POST", url, in)
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
 
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Authorization", token)
@@ -127,6 +321,46 @@ func PostJSON(url, token string, in interface{}, out interface{}) error {
 	return err
 }
 
+// PutJSON puts json replacing resource, non empty version is sent as If-Match so put fails if resource changed
+func PutJSON(url, token, version string, in interface{}, out interface{}) error {
+	req, err := newRequest("PUT", url, in)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", token)
+	if version != "" {
+		req.Header.Add("If-Match", fmt.Sprintf("%q", version))
+	}
+	req.Close = true
+	http.DefaultClient.Transport = defaultTransport()
+	defer http.DefaultClient.CloseIdleConnections()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return errorMessage(url, resp)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
 func Delete(url, token string) error {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJSONIfNoneMatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `W/"v1"`)
+		if r.Header.Get("If-None-Match") == `W/"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(`["target1"]`))
+	}))
+	defer server.Close()
+
+	var targets []string
+	etag, err := GetJSONIfNoneMatch(context.Background(), server.URL, "", "", &targets)
+	require.NoError(t, err)
+	assert.Equal(t, `W/"v1"`, etag)
+	assert.Equal(t, []string{"target1"}, targets)
+
+	var unchanged []string
+	etag, err = GetJSONIfNoneMatch(context.Background(), server.URL, "", etag, &unchanged)
+	require.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, `W/"v1"`, etag)
+	assert.Nil(t, unchanged)
+}
+
+func TestStatusErrorRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, "req-1")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message":"store unavailable"}`))
+	}))
+	defer server.Close()
+
+	var targets []string
+	err := GetJSON(server.URL, "", &targets)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "req-1", statusErr.RequestID)
+	assert.Contains(t, err.Error(), "request id: req-1")
+}
@@ -0,0 +1,198 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending requests while circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open, orchestrator failing")
+
+// RetryPolicy retries requests failing with transport errors or retryable status codes,
+// waiting InitialBackoff before first retry, doubled with every retry up to MaxBackoff
+type RetryPolicy struct {
+	// MaxAttempts includes first attempt, 1 or less sends requests once
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy retries 3 times, starting at 200ms, on 429 and unavailable server or gateway
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// backoff before retry attempt, half of it is jittered so agents do not retry in lockstep,
+// Retry-After of server is honoured up to MaxBackoff
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff > 0 {
+		backoff = backoff/2 + rand.N(backoff/2+1)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > backoff {
+		backoff = statusErr.RetryAfter
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// retryable is true for transport errors and status codes of policy, not for cancelled requests
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(p.RetryableStatusCodes, statusErr.StatusCode)
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// retryAfter parses Retry-After seconds of response, zero if absent
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CircuitBreaker fails requests fast with ErrCircuitOpen once Threshold consecutive requests fail,
+// after Cooldown a single trial request is let through, its success closes the circuit
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	lock     sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker opens after threshold consecutive failures for cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// allow returns ErrCircuitOpen while open, nil breaker allows all requests
+func (b *CircuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.Threshold <= 0 || b.failures < b.Threshold {
+		return nil
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.Cooldown {
+		return ErrCircuitOpen
+	}
+	b.trial = true
+	return nil
+}
+
+// record counts result of request, failures are transport errors and retryable status codes of policy
+func (b *CircuitBreaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.Threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// ClientOption configures requests sent through OrchestratorAPI
+type ClientOption func(*OrchestratorAPI)
+
+// WithRetry retries requests of OrchestratorAPI with policy
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(api *OrchestratorAPI) {
+		api.retry = policy
+	}
+}
+
+// WithCircuitBreaker fails requests of OrchestratorAPI fast while breaker is open
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(api *OrchestratorAPI) {
+		api.breaker = breaker
+	}
+}
+
+// Do calls request with retry policy and circuit breaker of api, request is called again for each attempt
+// so bodies are rebuilt, waits between attempts stop once ctx is done
+func (api *OrchestratorAPI) Do(ctx context.Context, request func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err := api.breaker.allow(); err != nil {
+			return err
+		}
+		err = request()
+		retryable := err != nil && api.retry.retryable(err)
+		api.breaker.record(retryable)
+		if !retryable || attempt >= api.retry.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(api.retry.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// GetJSON gets json of url with retry policy and circuit breaker of api
+func (api *OrchestratorAPI) GetJSON(ctx context.Context, url, token string, value interface{}) error {
+	return api.Do(ctx, func() error {
+		return GetJSONContext(ctx, url, token, value)
+	})
+}
+
+// PostJSON posts json to url with retry policy and circuit breaker of api
+func (api *OrchestratorAPI) PostJSON(ctx context.Context, url, token string, in interface{}, out interface{}) error {
+	return api.Do(ctx, func() error {
+		return PostJSONContext(ctx, url, token, in, out)
+	})
+}
@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"readonly":true}`))
+	}))
+	defer server.Close()
+
+	var state struct {
+		ReadOnly bool `json:"readonly"`
+	}
+	api := NewOrchestratorAPI(server.URL, WithRetry(testRetryPolicy()))
+	require.NoError(t, api.GetJSON(context.Background(), server.URL, "", &state))
+	assert.True(t, state.ReadOnly)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// without policy requests are sent once
+	requests.Store(0)
+	err := NewOrchestratorAPI(server.URL).GetJSON(context.Background(), server.URL, "", &state)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	api := NewOrchestratorAPI(server.URL, WithRetry(testRetryPolicy()))
+	err := api.PostJSON(context.Background(), server.URL, "", map[string]string{"version": "v1"}, nil)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.InDelta(t, 75*time.Millisecond, policy.backoff(1, nil), float64(25*time.Millisecond))
+	assert.InDelta(t, 150*time.Millisecond, policy.backoff(2, nil), float64(50*time.Millisecond))
+	assert.InDelta(t, 750*time.Millisecond, policy.backoff(10, nil), float64(250*time.Millisecond))
+	assert.Equal(t, time.Second, policy.backoff(1, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+	api := NewOrchestratorAPI(server.URL, WithRetry(testRetryPolicy()), WithCircuitBreaker(breaker))
+
+	var out map[string]interface{}
+	err := api.GetJSON(context.Background(), server.URL, "", &out)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// open circuit fails without requests until cooldown
+	assert.ErrorIs(t, api.GetJSON(context.Background(), server.URL, "", &out), ErrCircuitOpen)
+	assert.Equal(t, int32(2), requests.Load())
+
+	now = now.Add(time.Minute)
+	healthy.Store(true)
+	require.NoError(t, api.GetJSON(context.Background(), server.URL, "", &out))
+	assert.Equal(t, int32(3), requests.Load())
+	require.NoError(t, api.GetJSON(context.Background(), server.URL, "", &out))
+}
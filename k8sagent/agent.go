@@ -0,0 +1,151 @@
+// Package k8sagent reports deployments and statefulsets of a kubernetes cluster as orchestrator targets
+// and rolls out versions assigned by the orchestrator by patching their image tag
+package k8sagent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nixmade/orchestrator/agentutil"
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// ClusterLabel, NamespaceLabel and KindLabel are added to labels of reported targets for target selectors
+	ClusterLabel   = "orchestrator.nixmade.io/cluster"
+	NamespaceLabel = "orchestrator.nixmade.io/namespace"
+	KindLabel      = "orchestrator.nixmade.io/kind"
+)
+
+// Options selects workloads agent manages
+type Options struct {
+	// Namespace is orchestrator namespace and Entity is orchestrator entity workloads are targets of
+	Namespace string
+	Entity    string
+	agentutil.Options
+
+	// Cluster is group of reported targets, agents of multiple clusters report to the same entity
+	Cluster string
+	// KubeNamespace limits workloads to a kubernetes namespace, empty watches all namespaces
+	KubeNamespace string
+	// Selector is label selector of workloads, e.g. app.kubernetes.io/part-of=shop
+	Selector string
+	// Resources are deployments and/or statefulsets, defaults to both
+	Resources []string
+	// Container whose image tag is the version, defaults to first container
+	Container string
+}
+
+// Agent reports workloads to orchestrator and applies assigned versions
+type Agent struct {
+	kube         *Client
+	orchestrator client.Interface
+	options      Options
+	logger       zerolog.Logger
+}
+
+// target is a workload reported to orchestrator
+type target struct {
+	resource  string
+	workload  workload
+	container *container
+	state     *engine.ClientState
+}
+
+// New creates agent reporting workloads of kube to orchestrator
+func New(kube *Client, orchestrator client.Interface, options Options, logger zerolog.Logger) *Agent {
+	if len(options.Resources) <= 0 {
+		options.Resources = []string{Deployments, StatefulSets}
+	}
+	return &Agent{kube: kube, orchestrator: orchestrator, options: options, logger: logger}
+}
+
+// Run syncs workloads with agentutil.Run
+func (a *Agent) Run(ctx context.Context) error {
+	return agentutil.Run(ctx, a.options.Interval, a.logger, "Failed to sync workloads", a.Sync)
+}
+
+// targetName is unique per cluster, targets are named kind:namespace:name since names may not contain slashes
+func targetName(resource, namespace, name string) string {
+	return fmt.Sprintf("%s:%s:%s", strings.TrimSuffix(resource, "s"), namespace, name)
+}
+
+// discover lists workloads as targets
+func (a *Agent) discover(ctx context.Context) (map[string]*target, []*engine.ClientState, error) {
+	targets := make(map[string]*target)
+	var clientStates []*engine.ClientState
+	for _, resource := range a.options.Resources {
+		workloads, err := a.kube.listWorkloads(ctx, resource, a.options.KubeNamespace, a.options.Selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, workload := range workloads {
+			container, err := workload.container(a.options.Container)
+			if err != nil {
+				a.logger.Warn().Err(err).Msg("Skipping workload")
+				continue
+			}
+			_, version := agentutil.SplitImage(container.Image)
+			message, failed := workload.health()
+
+			labels := make(map[string]string, len(workload.Metadata.Labels)+3)
+			for key, value := range workload.Metadata.Labels {
+				labels[key] = value
+			}
+			labels[ClusterLabel] = a.options.Cluster
+			labels[NamespaceLabel] = workload.Metadata.Namespace
+			labels[KindLabel] = strings.TrimSuffix(resource, "s")
+
+			state := &engine.ClientState{
+				Name:    targetName(resource, workload.Metadata.Namespace, workload.Metadata.Name),
+				Group:   a.options.Cluster,
+				Version: version,
+				Message: message,
+				IsError: failed,
+				Labels:  labels,
+			}
+			targets[state.Name] = &target{resource: resource, workload: workload, container: container, state: state}
+			clientStates = append(clientStates, state)
+		}
+	}
+	return targets, clientStates, nil
+}
+
+// Sync reports workloads to orchestrator once and patches workloads assigned a different version
+func (a *Agent) Sync(ctx context.Context) error {
+	targets, clientStates, err := a.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if len(clientStates) <= 0 {
+		a.logger.Info().Str("Selector", a.options.Selector).Msg("No workloads found")
+		return nil
+	}
+
+	assigned, err := a.orchestrator.Orchestrate(ctx, a.options.Namespace, a.options.Entity, clientStates)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, assignment := range assigned {
+		target, ok := targets[assignment.Name]
+		if !ok || assignment.Group != a.options.Cluster || assignment.Version == "" || assignment.Version == target.state.Version {
+			continue
+		}
+
+		repository, _ := agentutil.SplitImage(target.container.Image)
+		image := agentutil.JoinImage(repository, assignment.Version)
+		a.logger.Info().Str("Target", assignment.Name).Str("Version", assignment.Version).Str("Image", image).Msg("Applying assigned version")
+		if err := a.kube.setImage(ctx, target.resource, target.workload.Metadata.Namespace, target.workload.Metadata.Name, target.container.Name, image); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", assignment.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply %d of %d assignments: %w", len(errs), len(assigned), errs[0])
+	}
+	return nil
+}
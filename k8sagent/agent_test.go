@@ -0,0 +1,182 @@
+package k8sagent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(`
+apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev}
+- name: prod
+  context: {cluster: prod, user: prod}
+clusters:
+- name: dev
+  cluster: {server: "https://dev:6443", insecure-skip-tls-verify: true}
+- name: prod
+  cluster: {server: "https://prod:6443"}
+users:
+- name: dev
+  user: {token: devtoken}
+- name: prod
+  user: {token: prodtoken}
+`), 0o600))
+
+	config, err := LoadKubeconfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev:6443", config.Server)
+	assert.Equal(t, "devtoken", config.Token)
+	assert.True(t, config.Insecure)
+
+	config, err = LoadKubeconfig(path, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod:6443", config.Server)
+	assert.Equal(t, "prodtoken", config.Token)
+
+	_, err = LoadKubeconfig(path, "missing")
+	assert.ErrorIs(t, err, ErrInvalidKubeconfig)
+}
+
+// fakeKube serves workloads and records image patches
+type fakeKube struct {
+	lock      sync.Mutex
+	workloads map[string]string
+	patches   map[string]string
+}
+
+func (f *fakeKube) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer kubetoken" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("labelSelector") != "app=web" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, f.workloads[r.URL.Path])
+	case http.MethodPatch:
+		if r.Header.Get("Content-Type") != strategicMergePatch {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.patches[r.URL.Path] = string(body)
+		_, _ = io.WriteString(w, "{}")
+	}
+}
+
+func TestAgentSync(t *testing.T) {
+	kube := &fakeKube{
+		workloads: map[string]string{
+			"/apis/apps/v1/namespaces/shop/deployments": `{"items": [{
+				"metadata": {"name": "web", "namespace": "shop", "labels": {"app": "web", "tier": "frontend"}, "generation": 2},
+				"spec": {"replicas": 3, "template": {"spec": {"containers": [
+					{"name": "sidecar", "image": "envoy:1.29"},
+					{"name": "web", "image": "registry:5000/shop/web:v1"}
+				]}}},
+				"status": {"observedGeneration": 2, "replicas": 3, "updatedReplicas": 3, "readyReplicas": 3, "availableReplicas": 3}
+			}]}`,
+			"/apis/apps/v1/namespaces/shop/statefulsets": `{"items": [{
+				"metadata": {"name": "cache", "namespace": "shop", "labels": {"app": "web"}, "generation": 1},
+				"spec": {"template": {"spec": {"containers": [{"name": "web", "image": "shop/cache:v1"}]}}},
+				"status": {"observedGeneration": 1, "replicas": 1, "updatedReplicas": 0, "readyReplicas": 0}
+			}]}`,
+		},
+		patches: make(map[string]string),
+	}
+	kubeServer := httptest.NewServer(kube)
+	defer kubeServer.Close()
+
+	var reported []*engine.ClientState
+	orchestrator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/orchestrate/shop/web", r.URL.Path)
+		assert.Equal(t, "Bearer orchestratortoken", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reported))
+		// web is assigned v2, cache keeps v1
+		assigned := []*engine.ClientState{
+			{Name: "deployment:shop:web", Group: "east", Version: "v2"},
+			{Name: "statefulset:shop:cache", Group: "east", Version: "v1"},
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(assigned))
+	}))
+	defer orchestrator.Close()
+
+	kubeClient, err := NewClient(&KubeConfig{Server: kubeServer.URL, Token: "kubetoken"})
+	require.NoError(t, err)
+	orchestratorClient, err := client.New(orchestrator.URL, client.WithAPIKey("orchestratortoken"))
+	require.NoError(t, err)
+	agent := New(kubeClient, orchestratorClient, Options{
+		Namespace:     "shop",
+		Entity:        "web",
+		Cluster:       "east",
+		KubeNamespace: "shop",
+		Selector:      "app=web",
+		Container:     "web",
+	}, zerolog.Nop())
+
+	require.NoError(t, agent.Sync(context.Background()))
+
+	require.Len(t, reported, 2)
+	web, cache := reported[0], reported[1]
+	assert.Equal(t, "deployment:shop:web", web.Name)
+	assert.Equal(t, "east", web.Group)
+	assert.Equal(t, "v1", web.Version)
+	assert.False(t, web.IsError)
+	assert.Equal(t, "frontend", web.Labels["tier"])
+	assert.Equal(t, "east", web.Labels[ClusterLabel])
+	assert.Equal(t, "deployment", web.Labels[KindLabel])
+	assert.Equal(t, "statefulset:shop:cache", cache.Name)
+	assert.Contains(t, cache.Message, "rolling out, 0/1 updated")
+
+	kube.lock.Lock()
+	defer kube.lock.Unlock()
+	require.Len(t, kube.patches, 1)
+	assert.JSONEq(t, `{"spec":{"template":{"spec":{"containers":[{"name":"web","image":"registry:5000/shop/web:v2"}]}}}}`,
+		kube.patches["/apis/apps/v1/namespaces/shop/deployments/web"])
+}
+
+func TestWorkloadHealth(t *testing.T) {
+	var w workload
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"metadata": {"generation": 3},
+		"spec": {"replicas": 2},
+		"status": {"observedGeneration": 3, "conditions": [
+			{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded", "message": "ReplicaSet web-7d9 has timed out progressing."}
+		]}
+	}`), &w))
+	message, failed := w.health()
+	assert.True(t, failed)
+	assert.Contains(t, message, "ProgressDeadlineExceeded")
+
+	w.Status.Conditions = nil
+	w.Metadata.Generation = 4
+	message, failed = w.health()
+	assert.False(t, failed)
+	assert.Equal(t, "waiting for controller to observe spec", message)
+}
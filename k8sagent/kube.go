@@ -0,0 +1,248 @@
+package k8sagent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubeTimeout       = 30 * time.Second
+)
+
+var (
+	// ErrNotInCluster returns an error if in cluster config is requested outside a pod
+	ErrNotInCluster = errors.New("not running in a kubernetes cluster")
+	// ErrInvalidKubeconfig returns an error if kubeconfig has no usable context, cluster or user
+	ErrInvalidKubeconfig = errors.New("invalid kubeconfig")
+)
+
+// KubeConfig is connection to kubernetes api server
+type KubeConfig struct {
+	Server string
+	// Token is bearer token, TokenFile is re-read on every request since projected tokens rotate
+	Token     string
+	TokenFile string
+	// CAData verifies api server, system roots are used if empty
+	CAData []byte
+	// CertData and KeyData authenticate with client certificate
+	CertData []byte
+	KeyData  []byte
+	Insecure bool
+}
+
+// InClusterConfig uses service account of the pod agent runs in
+func InClusterConfig() (*KubeConfig, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	caData, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	return &KubeConfig{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		TokenFile: filepath.Join(serviceAccountDir, "token"),
+		CAData:    caData,
+	}, nil
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// LoadKubeconfig reads cluster and user of context from kubeconfig file, empty context uses current context.
+// Only token and client certificate users are supported, exec and auth provider plugins are not
+func LoadKubeconfig(path, contextName string) (*KubeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKubeconfig, err)
+	}
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	// relative file references are relative to kubeconfig
+	dir := filepath.Dir(path)
+	readData := func(data, file string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return os.ReadFile(file)
+	}
+
+	for _, namedContext := range config.Contexts {
+		if namedContext.Name != contextName {
+			continue
+		}
+		kubeConfig := &KubeConfig{}
+		found := false
+		for _, cluster := range config.Clusters {
+			if cluster.Name != namedContext.Context.Cluster {
+				continue
+			}
+			found = true
+			kubeConfig.Server = cluster.Cluster.Server
+			kubeConfig.Insecure = cluster.Cluster.InsecureSkipTLSVerify
+			if kubeConfig.CAData, err = readData(cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority); err != nil {
+				return nil, err
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: cluster %s not found", ErrInvalidKubeconfig, namedContext.Context.Cluster)
+		}
+		for _, user := range config.Users {
+			if user.Name != namedContext.Context.User {
+				continue
+			}
+			kubeConfig.Token = user.User.Token
+			kubeConfig.TokenFile = user.User.TokenFile
+			if kubeConfig.CertData, err = readData(user.User.ClientCertificateData, user.User.ClientCertificate); err != nil {
+				return nil, err
+			}
+			if kubeConfig.KeyData, err = readData(user.User.ClientKeyData, user.User.ClientKey); err != nil {
+				return nil, err
+			}
+		}
+		return kubeConfig, nil
+	}
+	return nil, fmt.Errorf("%w: context %q not found", ErrInvalidKubeconfig, contextName)
+}
+
+// Client is a minimal kubernetes api client for workloads agent manages
+type Client struct {
+	server    string
+	token     string
+	tokenFile string
+	http      *http.Client
+}
+
+// NewClient creates client for api server of config
+func NewClient(config *KubeConfig) (*Client, error) {
+	// insecure is opt in from kubeconfig, for local test clusters
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: config.Insecure}
+	if len(config.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CAData) {
+			return nil, fmt.Errorf("%w: certificate authority is not valid PEM", ErrInvalidKubeconfig)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(config.CertData) > 0 {
+		cert, err := tls.X509KeyPair(config.CertData, config.KeyData)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{
+		server:    strings.TrimSuffix(config.Server, "/"),
+		token:     config.Token,
+		tokenFile: config.TokenFile,
+		http:      &http.Client{Transport: transport, Timeout: kubeTimeout},
+	}, nil
+}
+
+// kubeStatus is error returned by api server
+type kubeStatus struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	token := c.token
+	if c.tokenFile != "" {
+		data, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var status kubeStatus
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			return fmt.Errorf("%s %s: %d %s: %s", method, path, resp.StatusCode, status.Reason, status.Message)
+		}
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
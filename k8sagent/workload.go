@@ -0,0 +1,139 @@
+package k8sagent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+const (
+	// Deployments are apps/v1 deployments
+	Deployments = "deployments"
+	// StatefulSets are apps/v1 statefulsets
+	StatefulSets = "statefulsets"
+
+	strategicMergePatch = "application/strategic-merge-patch+json"
+)
+
+type container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+type condition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// workload is the part of deployments and statefulsets agent reads
+type workload struct {
+	Metadata struct {
+		Name       string            `json:"name"`
+		Namespace  string            `json:"namespace"`
+		Labels     map[string]string `json:"labels,omitempty"`
+		Generation int64             `json:"generation,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas *int32 `json:"replicas,omitempty"`
+		Template struct {
+			Spec struct {
+				Containers []container `json:"containers"`
+			} `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+	Status struct {
+		ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+		Replicas           int32       `json:"replicas,omitempty"`
+		UpdatedReplicas    int32       `json:"updatedReplicas,omitempty"`
+		ReadyReplicas      int32       `json:"readyReplicas,omitempty"`
+		AvailableReplicas  int32       `json:"availableReplicas,omitempty"`
+		Conditions         []condition `json:"conditions,omitempty"`
+	} `json:"status"`
+}
+
+type workloadList struct {
+	Items []workload `json:"items"`
+}
+
+// replicas desired by spec, defaults to 1 like api server
+func (w *workload) replicas() int32 {
+	if w.Spec.Replicas == nil {
+		return 1
+	}
+	return *w.Spec.Replicas
+}
+
+// container returns named container or first container if name is empty
+func (w *workload) container(name string) (*container, error) {
+	for i := range w.Spec.Template.Spec.Containers {
+		if name == "" || w.Spec.Template.Spec.Containers[i].Name == name {
+			return &w.Spec.Template.Spec.Containers[i], nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s/%s has no containers", w.Metadata.Namespace, w.Metadata.Name)
+	}
+	return nil, fmt.Errorf("%s/%s has no container %s", w.Metadata.Namespace, w.Metadata.Name, name)
+}
+
+// health reports rollout progress of workload, failed is set if pods can not be rolled out
+func (w *workload) health() (message string, failed bool) {
+	for _, condition := range w.Status.Conditions {
+		if condition.Type == "Progressing" && condition.Status == "False" ||
+			condition.Type == "ReplicaFailure" && condition.Status == "True" {
+			return fmt.Sprintf("%s: %s", condition.Reason, condition.Message), true
+		}
+	}
+
+	replicas := w.replicas()
+	if w.Status.ObservedGeneration < w.Metadata.Generation {
+		return "waiting for controller to observe spec", false
+	}
+	if w.Status.UpdatedReplicas < replicas || w.Status.AvailableReplicas < replicas || w.Status.ReadyReplicas < replicas {
+		return fmt.Sprintf("rolling out, %d/%d updated, %d ready, %d available",
+			w.Status.UpdatedReplicas, replicas, w.Status.ReadyReplicas, w.Status.AvailableReplicas), false
+	}
+	return fmt.Sprintf("%d/%d replicas available", w.Status.AvailableReplicas, replicas), false
+}
+
+func workloadsPath(resource, namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/apps/v1/%s", resource)
+	}
+	return fmt.Sprintf("/apis/apps/v1/namespaces/%s/%s", url.PathEscape(namespace), resource)
+}
+
+// listWorkloads lists resource in namespace matching label selector, empty namespace lists all namespaces
+func (c *Client) listWorkloads(ctx context.Context, resource, namespace, selector string) ([]workload, error) {
+	path := workloadsPath(resource, namespace)
+	if selector != "" {
+		path += "?labelSelector=" + url.QueryEscape(selector)
+	}
+	var list workloadList
+	if err := c.do(ctx, "GET", path, "", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// setImage patches image of container, strategic merge keeps other containers untouched
+func (c *Client) setImage(ctx context.Context, resource, namespace, name, containerName, image string) error {
+	patch := map[string]any{
+		"spec": map[string]any{
+			"template": map[string]any{
+				"spec": map[string]any{
+					"containers": []container{{Name: containerName, Image: image}},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/%s", workloadsPath(resource, namespace), url.PathEscape(name))
+	return c.do(ctx, "PATCH", path, strategicMergePatch, body, nil)
+}
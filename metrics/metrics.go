@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultRegistry is used by orchestrator for all of its metrics
+var DefaultRegistry = NewRegistry()
+
+// Registry holds list of metrics, exposed in prometheus text format
+type Registry struct {
+	*prometheus.Registry
+}
+
+// NewRegistry creates a registry with process and go runtime collectors
+func NewRegistry() *Registry {
+	r := &Registry{Registry: prometheus.NewRegistry()}
+	r.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), collectors.NewGoCollector())
+	return r
+}
+
+// Handler serves registered metrics
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{Registry: r})
+}
+
+// Handler serves metrics registered with DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// NewCounterVec creates and registers a counter partitioned by labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	r.MustRegister(c)
+	return c
+}
+
+// NewGauge creates and registers a gauge
+func (r *Registry) NewGauge(name, help string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	r.MustRegister(g)
+	return g
+}
+
+// NewGaugeVec creates and registers a gauge partitioned by labels
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	r.MustRegister(g)
+	return g
+}
+
+// NewHistogramVec creates and registers a histogram partitioned by labels, uses prometheus.DefBuckets
+// if buckets is empty
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	r.MustRegister(h)
+	return h
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryGather(t *testing.T) {
+	registry := NewRegistry()
+
+	counter := registry.NewCounterVec("test_total", "test counter", "namespace")
+	counter.WithLabelValues("ns1").Inc()
+	counter.WithLabelValues("ns1").Add(2)
+	counter.WithLabelValues("ns2").Inc()
+
+	gauge := registry.NewGauge("test_gauge", "test gauge")
+	gauge.Set(5)
+	gauge.Add(-2)
+
+	histogram := registry.NewHistogramVec("test_seconds", "test histogram", []float64{1, 0.1}, "operation")
+	histogram.WithLabelValues("load").Observe(0.05)
+	histogram.WithLabelValues("load").Observe(0.5)
+	histogram.WithLabelValues("load").Observe(5)
+
+	expected := `# HELP test_total test counter
+# TYPE test_total counter
+test_total{namespace="ns1"} 3
+test_total{namespace="ns2"} 1
+# HELP test_gauge test gauge
+# TYPE test_gauge gauge
+test_gauge 3
+# HELP test_seconds test histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{operation="load",le="0.1"} 1
+test_seconds_bucket{operation="load",le="1"} 2
+test_seconds_bucket{operation="load",le="+Inf"} 3
+test_seconds_sum{operation="load"} 5.55
+test_seconds_count{operation="load"} 3
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_total", "test_gauge", "test_seconds"))
+}
+
+func TestRegistryHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("test_total", "test counter").WithLabelValues().Inc()
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "test_total 1\n")
+	assert.Contains(t, recorder.Body.String(), "process_cpu_seconds_total")
+	assert.Contains(t, recorder.Body.String(), "go_goroutines")
+}
+
+func TestLabelEscaping(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "test counter", "entity")
+	counter.WithLabelValues(`quoted "name"`).Inc()
+	counter.WithLabelValues(`back\slash`).Inc()
+	counter.WithLabelValues("new\nline").Inc()
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, `test_total{entity="quoted \"name\""} 1`)
+	assert.Contains(t, body, `test_total{entity="back\\slash"} 1`)
+	assert.Contains(t, body, `test_total{entity="new\nline"} 1`)
+}
+
+func TestLabelMismatch(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "test counter", "namespace")
+
+	assert.Panics(t, func() { counter.WithLabelValues().Inc() })
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewGauge("test_gauge", "test gauge")
+
+	assert.Panics(t, func() { registry.NewGauge("test_gauge", "test gauge") })
+}
@@ -0,0 +1,291 @@
+// Package nomadagent reports allocations of nomad jobs as orchestrator targets and rolls out versions
+// assigned by the orchestrator by updating image tag or artifact version of the job
+package nomadagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nixmade/orchestrator/agentutil"
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// VersionMetaKey is job meta holding version of tasks fetching artifacts, the version is replaced
+	// in artifact sources when a new version is applied
+	VersionMetaKey = "orchestrator_version"
+
+	// ClusterLabel, JobLabel, TaskGroupLabel and NodeLabel are added to labels of reported targets for target selectors
+	ClusterLabel   = "orchestrator.nixmade.io/cluster"
+	JobLabel       = "orchestrator.nixmade.io/job"
+	TaskGroupLabel = "orchestrator.nixmade.io/taskgroup"
+	NodeLabel      = "orchestrator.nixmade.io/node"
+)
+
+// ErrNoVersion returns an error if version of a task can not be determined
+var ErrNoVersion = errors.New("task has no image or versioned artifacts")
+
+// Options selects jobs agent manages
+type Options struct {
+	// Namespace is orchestrator namespace and Entity is orchestrator entity allocations are targets of
+	Namespace string
+	Entity    string
+	agentutil.Options
+
+	// Cluster is group of reported targets, agents of multiple regions report to the same entity
+	Cluster string
+	// Jobs whose allocations are reported
+	Jobs []string
+	// Task whose image or artifacts are the version, defaults to first task of each group
+	Task string
+}
+
+// Agent reports allocations to orchestrator and applies assigned versions to jobs
+type Agent struct {
+	nomad        *Client
+	orchestrator client.Interface
+	options      Options
+	logger       zerolog.Logger
+}
+
+// target is an allocation reported to orchestrator
+type target struct {
+	jobID     string
+	taskGroup string
+	state     *engine.ClientState
+}
+
+// New creates agent reporting allocations of nomad jobs to orchestrator
+func New(nomad *Client, orchestrator client.Interface, options Options, logger zerolog.Logger) *Agent {
+	return &Agent{nomad: nomad, orchestrator: orchestrator, options: options, logger: logger}
+}
+
+// Run syncs allocations of jobs with agentutil.Run
+func (a *Agent) Run(ctx context.Context) error {
+	return agentutil.Run(ctx, a.options.Interval, a.logger, "Failed to sync jobs", a.Sync)
+}
+
+// findTask returns named task of group or first task if name is empty
+func (j *job) findTask(group, name string) (*task, error) {
+	for _, taskGroup := range j.TaskGroups {
+		if taskGroup.Name != group {
+			continue
+		}
+		for i := range taskGroup.Tasks {
+			if name == "" || taskGroup.Tasks[i].Name == name {
+				return &taskGroup.Tasks[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("job %s group %s has no task %q", j.ID, group, name)
+}
+
+// taskVersion returns image tag of task, or version meta of job if task fetches artifacts
+func (j *job) taskVersion(group, name string) (string, error) {
+	task, err := j.findTask(group, name)
+	if err != nil {
+		return "", err
+	}
+	if image, ok := task.Config["image"].(string); ok && image != "" {
+		_, version := agentutil.SplitImage(image)
+		return version, nil
+	}
+	if version := j.Meta[VersionMetaKey]; version != "" && len(task.Artifacts) > 0 {
+		return version, nil
+	}
+	return "", fmt.Errorf("%w: job %s task %s", ErrNoVersion, j.ID, task.Name)
+}
+
+// allocationHealth reports allocation status, failed is set if allocation failed or is unhealthy
+func allocationHealth(alloc *allocation) (message string, failed bool) {
+	message = alloc.ClientStatus
+	if alloc.ClientDescription != "" {
+		message = fmt.Sprintf("%s: %s", alloc.ClientStatus, alloc.ClientDescription)
+	}
+	switch alloc.ClientStatus {
+	case "failed", "lost":
+		return message, true
+	}
+	if alloc.DeploymentStatus != nil && alloc.DeploymentStatus.Healthy != nil && !*alloc.DeploymentStatus.Healthy {
+		return message + ", unhealthy", true
+	}
+	return message, false
+}
+
+// discover lists running allocations of jobs as targets
+func (a *Agent) discover(ctx context.Context) (map[string]*target, []*engine.ClientState, error) {
+	targets := make(map[string]*target)
+	var clientStates []*engine.ClientState
+	for _, jobID := range a.options.Jobs {
+		allocations, err := a.nomad.allocations(ctx, jobID)
+		if err != nil {
+			return nil, nil, err
+		}
+		versions, err := a.nomad.versions(ctx, jobID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// replacements keep allocation name, only latest allocation desired to run is reported
+		latest := make(map[string]*allocation)
+		for i := range allocations {
+			alloc := &allocations[i]
+			if alloc.DesiredStatus != "run" {
+				continue
+			}
+			if current, ok := latest[alloc.Name]; !ok || alloc.CreateIndex > current.CreateIndex {
+				latest[alloc.Name] = alloc
+			}
+		}
+
+		for _, alloc := range latest {
+			spec, ok := versions[alloc.JobVersion]
+			if !ok {
+				a.logger.Warn().Str("Allocation", alloc.ID).Uint64("JobVersion", alloc.JobVersion).Msg("Skipping allocation of unknown job version")
+				continue
+			}
+			version, err := spec.taskVersion(alloc.TaskGroup, a.options.Task)
+			if err != nil {
+				a.logger.Warn().Err(err).Str("Allocation", alloc.ID).Msg("Skipping allocation")
+				continue
+			}
+			message, failed := allocationHealth(alloc)
+
+			state := &engine.ClientState{
+				Name:    alloc.Name,
+				Group:   a.options.Cluster,
+				Version: version,
+				Message: message,
+				IsError: failed,
+				Labels: map[string]string{
+					ClusterLabel:   a.options.Cluster,
+					JobLabel:       alloc.JobID,
+					TaskGroupLabel: alloc.TaskGroup,
+					NodeLabel:      alloc.NodeName,
+				},
+			}
+			targets[state.Name] = &target{jobID: alloc.JobID, taskGroup: alloc.TaskGroup, state: state}
+			clientStates = append(clientStates, state)
+		}
+	}
+	return targets, clientStates, nil
+}
+
+// Sync reports allocations to orchestrator once and updates jobs of allocations assigned a different version,
+// nomad replaces allocations of the task group following its update stanza
+func (a *Agent) Sync(ctx context.Context) error {
+	targets, clientStates, err := a.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if len(clientStates) <= 0 {
+		a.logger.Info().Strs("Jobs", a.options.Jobs).Msg("No allocations found")
+		return nil
+	}
+
+	assigned, err := a.orchestrator.Orchestrate(ctx, a.options.Namespace, a.options.Entity, clientStates)
+	if err != nil {
+		return err
+	}
+
+	// task groups are updated once even if several of their allocations are assigned
+	updates := make(map[[2]string]string)
+	for _, assignment := range assigned {
+		target, ok := targets[assignment.Name]
+		if !ok || assignment.Group != a.options.Cluster || assignment.Version == "" || assignment.Version == target.state.Version {
+			continue
+		}
+		key := [2]string{target.jobID, target.taskGroup}
+		if version, ok := updates[key]; ok && version != assignment.Version {
+			a.logger.Warn().Str("Job", target.jobID).Str("TaskGroup", target.taskGroup).Str("Version", assignment.Version).Msg("Task group assigned multiple versions, keeping first")
+			continue
+		}
+		updates[key] = assignment.Version
+	}
+
+	var errs []error
+	for key, version := range updates {
+		if err := a.apply(ctx, key[0], key[1], version); err != nil {
+			errs = append(errs, fmt.Errorf("job %s group %s: %w", key[0], key[1], err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply %d of %d task group updates: %w", len(errs), len(updates), errs[0])
+	}
+	return nil
+}
+
+// apply updates task version of job group unless job already has version
+func (a *Agent) apply(ctx context.Context, jobID, group, version string) error {
+	spec, err := a.nomad.rawJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	changed, err := setTaskVersion(spec, group, a.options.Task, version)
+	if err != nil || !changed {
+		return err
+	}
+	a.logger.Info().Str("Job", jobID).Str("TaskGroup", group).Str("Version", version).Msg("Applying assigned version")
+	return a.nomad.register(ctx, jobID, spec)
+}
+
+// setTaskVersion sets version of task in raw job spec, returns false if task already has version
+func setTaskVersion(spec map[string]any, group, name, version string) (bool, error) {
+	for _, rawGroup := range asSlice(spec["TaskGroups"]) {
+		taskGroup := asMap(rawGroup)
+		if taskGroup["Name"] != group {
+			continue
+		}
+		for _, rawTask := range asSlice(taskGroup["Tasks"]) {
+			task := asMap(rawTask)
+			if name != "" && task["Name"] != name {
+				continue
+			}
+
+			if image, ok := asMap(task["Config"])["image"].(string); ok && image != "" {
+				repository, current := agentutil.SplitImage(image)
+				if current == version {
+					return false, nil
+				}
+				asMap(task["Config"])["image"] = agentutil.JoinImage(repository, version)
+				return true, nil
+			}
+
+			meta := asMap(spec["Meta"])
+			current, _ := meta[VersionMetaKey].(string)
+			artifacts := asSlice(task["Artifacts"])
+			if current == "" || len(artifacts) <= 0 {
+				return false, fmt.Errorf("%w: task %v", ErrNoVersion, task["Name"])
+			}
+			if current == version {
+				return false, nil
+			}
+			for _, rawArtifact := range artifacts {
+				artifact := asMap(rawArtifact)
+				if source, ok := artifact["GetterSource"].(string); ok {
+					artifact["GetterSource"] = strings.ReplaceAll(source, current, version)
+				}
+			}
+			meta[VersionMetaKey] = version
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("task group %s has no task %q", group, name)
+}
+
+func asMap(value any) map[string]any {
+	m, _ := value.(map[string]any)
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
+
+func asSlice(value any) []any {
+	s, _ := value.([]any)
+	return s
+}
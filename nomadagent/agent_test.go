@@ -0,0 +1,159 @@
+package nomadagent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nixmade/orchestrator/client"
+	"github.com/nixmade/orchestrator/engine"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNomad serves allocations and job versions and records registered jobs
+type fakeNomad struct {
+	lock       sync.Mutex
+	responses  map[string]string
+	registered map[string]map[string]any
+}
+
+func (f *fakeNomad) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.Header.Get("X-Nomad-Token") != "nomadtoken" || r.URL.Query().Get("namespace") != "shop" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		response, ok := f.responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, response)
+	case http.MethodPost:
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.registered[r.URL.Path] = request
+		_, _ = io.WriteString(w, "{}")
+	}
+}
+
+func TestAgentSync(t *testing.T) {
+	v0 := `{"ID": "web", "Version": 0, "Meta": {"orchestrator_version": "w1"}, "TaskGroups": [
+		{"Name": "frontend", "Tasks": [{"Name": "web", "Config": {"image": "shop/web:v1"}}]},
+		{"Name": "worker", "Tasks": [{"Name": "worker", "Config": {"command": "worker"}, "Artifacts": [{"GetterSource": "https://releases.example.com/worker/w1/worker.tar.gz"}]}]}
+	]}`
+	nomad := &fakeNomad{
+		responses: map[string]string{
+			"/v1/job/web/allocations": `[
+				{"ID": "a1", "Name": "web.frontend[0]", "NodeName": "node1", "JobID": "web", "JobVersion": 0, "TaskGroup": "frontend",
+				 "DesiredStatus": "run", "ClientStatus": "running", "CreateIndex": 10, "DeploymentStatus": {"Healthy": true}},
+				{"ID": "a0", "Name": "web.frontend[1]", "NodeName": "node1", "JobID": "web", "JobVersion": 0, "TaskGroup": "frontend",
+				 "DesiredStatus": "stop", "ClientStatus": "complete", "CreateIndex": 9},
+				{"ID": "a2", "Name": "web.frontend[1]", "NodeName": "node2", "JobID": "web", "JobVersion": 0, "TaskGroup": "frontend",
+				 "DesiredStatus": "run", "ClientStatus": "failed", "ClientDescription": "Failed tasks", "CreateIndex": 11},
+				{"ID": "a3", "Name": "web.worker[0]", "NodeName": "node2", "JobID": "web", "JobVersion": 0, "TaskGroup": "worker",
+				 "DesiredStatus": "run", "ClientStatus": "running", "CreateIndex": 12}
+			]`,
+			"/v1/job/web/versions": `{"Versions": [` + v0 + `]}`,
+			"/v1/job/web": `{"ID": "web", "Version": 0, "JobModifyIndex": 42, "Meta": {"orchestrator_version": "w1"}, "TaskGroups": [
+				{"Name": "frontend", "Count": 2, "Tasks": [{"Name": "web", "Config": {"image": "shop/web:v1", "ports": ["http"]}}]},
+				{"Name": "worker", "Count": 1, "Tasks": [{"Name": "worker", "Config": {"command": "worker"},
+					"Artifacts": [{"GetterSource": "https://releases.example.com/worker/w1/worker.tar.gz"}]}]}
+			]}`,
+		},
+		registered: make(map[string]map[string]any),
+	}
+	nomadServer := httptest.NewServer(nomad)
+	defer nomadServer.Close()
+
+	var reported []*engine.ClientState
+	orchestrator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/orchestrate/shop/web", r.URL.Path)
+		assert.Equal(t, "Bearer orchestratortoken", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reported))
+		// frontend is assigned v2 on one allocation, worker keeps w1
+		assigned := []*engine.ClientState{
+			{Name: "web.frontend[0]", Group: "global", Version: "v2"},
+			{Name: "web.frontend[1]", Group: "global", Version: "v1"},
+			{Name: "web.worker[0]", Group: "global", Version: "w1"},
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(assigned))
+	}))
+	defer orchestrator.Close()
+
+	orchestratorClient, err := client.New(orchestrator.URL, client.WithAPIKey("orchestratortoken"))
+	require.NoError(t, err)
+	agent := New(NewClient(nomadServer.URL, "nomadtoken", "shop", ""), orchestratorClient, Options{
+		Namespace: "shop",
+		Entity:    "web",
+		Cluster:   "global",
+		Jobs:      []string{"web"},
+	}, zerolog.Nop())
+
+	require.NoError(t, agent.Sync(context.Background()))
+
+	require.Len(t, reported, 3)
+	states := make(map[string]*engine.ClientState)
+	for _, state := range reported {
+		states[state.Name] = state
+	}
+	require.Contains(t, states, "web.frontend[0]")
+	assert.Equal(t, "v1", states["web.frontend[0]"].Version)
+	assert.Equal(t, "global", states["web.frontend[0]"].Group)
+	assert.False(t, states["web.frontend[0]"].IsError)
+	assert.Equal(t, "node1", states["web.frontend[0]"].Labels[NodeLabel])
+	assert.Equal(t, "frontend", states["web.frontend[0]"].Labels[TaskGroupLabel])
+	require.Contains(t, states, "web.frontend[1]")
+	assert.True(t, states["web.frontend[1]"].IsError)
+	assert.Equal(t, "failed: Failed tasks", states["web.frontend[1]"].Message)
+	require.Contains(t, states, "web.worker[0]")
+	assert.Equal(t, "w1", states["web.worker[0]"].Version)
+
+	nomad.lock.Lock()
+	defer nomad.lock.Unlock()
+	require.Len(t, nomad.registered, 1)
+	request := nomad.registered["/v1/job/web"]
+	require.NotNil(t, request)
+	assert.Equal(t, true, request["EnforceIndex"])
+	assert.Equal(t, float64(42), request["JobModifyIndex"])
+	job := request["Job"].(map[string]any)
+	frontend := job["TaskGroups"].([]any)[0].(map[string]any)["Tasks"].([]any)[0].(map[string]any)
+	assert.Equal(t, "shop/web:v2", frontend["Config"].(map[string]any)["image"])
+	assert.Equal(t, []any{"http"}, frontend["Config"].(map[string]any)["ports"])
+}
+
+func TestSetTaskVersionArtifact(t *testing.T) {
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{"Meta": {"orchestrator_version": "w1"}, "TaskGroups": [
+		{"Name": "worker", "Tasks": [{"Name": "worker", "Config": {"command": "worker"},
+			"Artifacts": [{"GetterSource": "https://releases.example.com/worker/w1/worker.tar.gz"}]}]}
+	]}`), &spec))
+
+	changed, err := setTaskVersion(spec, "worker", "", "w2")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "w2", spec["Meta"].(map[string]any)[VersionMetaKey])
+	artifact := spec["TaskGroups"].([]any)[0].(map[string]any)["Tasks"].([]any)[0].(map[string]any)["Artifacts"].([]any)[0].(map[string]any)
+	assert.Equal(t, "https://releases.example.com/worker/w2/worker.tar.gz", artifact["GetterSource"])
+
+	changed, err = setTaskVersion(spec, "worker", "", "w2")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	_, err = setTaskVersion(spec, "missing", "", "w2")
+	assert.Error(t, err)
+}
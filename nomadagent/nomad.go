@@ -0,0 +1,164 @@
+package nomadagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const nomadTimeout = 30 * time.Second
+
+// Client is a minimal nomad http api client for jobs agent manages
+type Client struct {
+	address   string
+	token     string
+	namespace string
+	region    string
+	http      *http.Client
+}
+
+// NewClient creates client for nomad agent at address, token is sent as X-Nomad-Token if set
+func NewClient(address, token, namespace, region string) *Client {
+	return &Client{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: namespace,
+		region:    region,
+		http:      &http.Client{Timeout: nomadTimeout},
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	query := url.Values{}
+	if c.namespace != "" {
+		query.Set("namespace", c.namespace)
+	}
+	if c.region != "" {
+		query.Set("region", c.region)
+	}
+	endpoint := c.address + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Nomad-Token", c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(resp.Body)
+	// jobs are sent back on update, numbers must survive the round trip exactly
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
+
+// allocation is the part of allocation list stub agent reads
+type allocation struct {
+	ID                string `json:"ID"`
+	Name              string `json:"Name"`
+	NodeName          string `json:"NodeName"`
+	JobID             string `json:"JobID"`
+	JobVersion        uint64 `json:"JobVersion"`
+	TaskGroup         string `json:"TaskGroup"`
+	DesiredStatus     string `json:"DesiredStatus"`
+	ClientStatus      string `json:"ClientStatus"`
+	ClientDescription string `json:"ClientDescription"`
+	CreateIndex       uint64 `json:"CreateIndex"`
+	DeploymentStatus  *struct {
+		Healthy *bool `json:"Healthy"`
+	} `json:"DeploymentStatus"`
+}
+
+type artifact struct {
+	GetterSource string `json:"GetterSource"`
+}
+
+type task struct {
+	Name      string         `json:"Name"`
+	Config    map[string]any `json:"Config"`
+	Artifacts []artifact     `json:"Artifacts"`
+}
+
+type taskGroup struct {
+	Name  string `json:"Name"`
+	Tasks []task `json:"Tasks"`
+}
+
+// job is the part of job spec agent reads
+type job struct {
+	ID         string            `json:"ID"`
+	Version    uint64            `json:"Version"`
+	Meta       map[string]string `json:"Meta"`
+	TaskGroups []taskGroup       `json:"TaskGroups"`
+}
+
+func (c *Client) allocations(ctx context.Context, jobID string) ([]allocation, error) {
+	var allocations []allocation
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/job/%s/allocations", url.PathEscape(jobID)), nil, &allocations)
+	return allocations, err
+}
+
+// versions returns specs of job by job version, allocations run one of them
+func (c *Client) versions(ctx context.Context, jobID string) (map[uint64]*job, error) {
+	var response struct {
+		Versions []*job `json:"Versions"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/job/%s/versions", url.PathEscape(jobID)), nil, &response); err != nil {
+		return nil, err
+	}
+	versions := make(map[uint64]*job, len(response.Versions))
+	for _, version := range response.Versions {
+		versions[version.Version] = version
+	}
+	return versions, nil
+}
+
+// rawJob returns job spec as generic json so fields agent does not know about are submitted unchanged
+func (c *Client) rawJob(ctx context.Context, jobID string) (map[string]any, error) {
+	var spec map[string]any
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/job/%s", url.PathEscape(jobID)), nil, &spec)
+	return spec, err
+}
+
+// register submits job, update is rejected if job was modified since it was read
+func (c *Client) register(ctx context.Context, jobID string, spec map[string]any) error {
+	request := map[string]any{
+		"Job":            spec,
+		"EnforceIndex":   true,
+		"JobModifyIndex": spec["JobModifyIndex"],
+	}
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/job/%s", url.PathEscape(jobID)), request, nil)
+}
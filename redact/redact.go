@@ -0,0 +1,216 @@
+// Package redact removes sensitive fields and values from structured logs
+package redact
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// Redacted replaces sensitive values
+	Redacted = "[REDACTED]"
+	// NamespaceField is the log field used to apply namespace rules
+	NamespaceField = "Namespace"
+)
+
+// Rules are field names (case insensitive) whose values are redacted,
+// and patterns whose matches are redacted from any string value
+type Rules struct {
+	Fields   []string `json:"fields,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+type compiledRules struct {
+	fields   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func compile(rules Rules) (*compiledRules, error) {
+	compiled := &compiledRules{fields: make(map[string]bool)}
+	for _, field := range rules.Fields {
+		compiled.fields[strings.ToLower(field)] = true
+	}
+	for _, pattern := range rules.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled.patterns = append(compiled.patterns, re)
+	}
+	return compiled, nil
+}
+
+func (c *compiledRules) empty() bool {
+	return c == nil || (len(c.fields) <= 0 && len(c.patterns) <= 0)
+}
+
+// Redactor applies global rules to every record and namespace rules to records of that namespace
+type Redactor struct {
+	lock       sync.RWMutex
+	global     *compiledRules
+	namespaces map[string]*compiledRules
+}
+
+// Default redactor used by orchestrator loggers
+var Default = NewRedactor()
+
+// NewRedactor creates redactor without any rules
+func NewRedactor() *Redactor {
+	return &Redactor{namespaces: make(map[string]*compiledRules)}
+}
+
+// SetRules sets global rules applied to all records
+func (r *Redactor) SetRules(rules Rules) error {
+	compiled, err := compile(rules)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.global = compiled
+	return nil
+}
+
+// SetNamespaceRules sets rules applied only to records of the namespace, in addition to global rules
+func (r *Redactor) SetNamespaceRules(namespace string, rules Rules) error {
+	compiled, err := compile(rules)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if compiled.empty() {
+		delete(r.namespaces, namespace)
+		return nil
+	}
+	r.namespaces[namespace] = compiled
+	return nil
+}
+
+func (r *Redactor) enabled() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return !r.global.empty() || len(r.namespaces) > 0
+}
+
+func redactString(value string, rules []*compiledRules) string {
+	for _, rule := range rules {
+		for _, pattern := range rule.patterns {
+			value = pattern.ReplaceAllString(value, Redacted)
+		}
+	}
+	return value
+}
+
+func redactValue(value any, rules []*compiledRules) any {
+	switch v := value.(type) {
+	case string:
+		return redactString(v, rules)
+	case map[string]any:
+		for key, field := range v {
+			if isSensitive(key, rules) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactValue(field, rules)
+		}
+		return v
+	case []any:
+		for i, field := range v {
+			v[i] = redactValue(field, rules)
+		}
+		return v
+	}
+	return value
+}
+
+func isSensitive(field string, rules []*compiledRules) bool {
+	field = strings.ToLower(field)
+	for _, rule := range rules {
+		if rule.fields[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// Record redacts a structured record in place
+func (r *Redactor) Record(record map[string]any) map[string]any {
+	r.lock.RLock()
+	var rules []*compiledRules
+	if !r.global.empty() {
+		rules = append(rules, r.global)
+	}
+	if namespace, ok := record[NamespaceField].(string); ok {
+		if rule, ok := r.namespaces[namespace]; ok {
+			rules = append(rules, rule)
+		}
+	}
+	r.lock.RUnlock()
+
+	if len(rules) <= 0 {
+		return record
+	}
+	return redactValue(record, rules).(map[string]any)
+}
+
+// JSON redacts a json object, non objects are returned as is
+func (r *Redactor) JSON(data []byte) []byte {
+	if !r.enabled() {
+		return data
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data
+	}
+
+	redacted, err := json.Marshal(r.Record(record))
+	if err != nil {
+		return data
+	}
+	if len(data) > 0 && data[len(data)-1] == '\n' {
+		redacted = append(redacted, '\n')
+	}
+	return redacted
+}
+
+type writer struct {
+	out      io.Writer
+	redactor *Redactor
+}
+
+// NewWriter redacts json log records before writing to out
+func NewWriter(out io.Writer, redactor *Redactor) io.Writer {
+	return &writer{out: out, redactor: redactor}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(w.redactor.JSON(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// ConfigureFromEnv sets global rules of Default redactor from
+// APP_REDACT_FIELDS (comma separated field names) and APP_REDACT_PATTERNS (comma separated regular expressions)
+func ConfigureFromEnv() error {
+	return Default.SetRules(Rules{
+		Fields:   splitList(os.Getenv("APP_REDACT_FIELDS")),
+		Patterns: splitList(os.Getenv("APP_REDACT_PATTERNS")),
+	})
+}
@@ -0,0 +1,39 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	redactor := NewRedactor()
+	require.NoError(t, redactor.SetRules(Rules{Fields: []string{"tags"}, Patterns: []string{`token=\w+`}}))
+	require.NoError(t, redactor.SetNamespaceRules("regulated", Rules{Fields: []string{"LastMessage"}}))
+
+	var out bytes.Buffer
+	logger := zerolog.New(NewWriter(&out, redactor))
+
+	logger.Info().Str("Tags", "customer=acme").Str("LastMessage", "ok").Msg("calling with token=s3cr3t")
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, Redacted, record["Tags"])
+	assert.Equal(t, "ok", record["LastMessage"])
+	assert.Equal(t, "calling with "+Redacted, record["message"])
+
+	out.Reset()
+	logger.Info().Str("Namespace", "regulated").Str("LastMessage", "patient record").Send()
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, Redacted, record["LastMessage"])
+
+	assert.Error(t, redactor.SetRules(Rules{Patterns: []string{"("}}))
+}
+
+func TestDisabled(t *testing.T) {
+	data := []byte(`{"level":"info","message":"token=abc"}` + "\n")
+	assert.Equal(t, data, NewRedactor().JSON(data))
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nixmade/orchestrator/response"
+)
+
+var (
+	ErrAuthNotConfigured       = errors.New("jwt authentication requires hmac secret or jwks url")
+	ErrMissingToken            = errors.New("missing bearer token")
+	ErrUnexpectedSigningMethod = errors.New("unexpected token signing method")
+	ErrSigningKeyNotFound      = errors.New("token signing key not found")
+)
+
+// AuthConfig configures JWT bearer token validation
+type AuthConfig struct {
+	// Shared secret validating HS256, HS384 and HS512 signed tokens
+	HMACSecret string
+	// URL serving JSON web key set validating RSA and ECDSA signed tokens
+	JWKSURL string
+	// Expected iss claim, not validated when empty
+	Issuer string
+	// Expected aud claim, not validated when empty
+	Audience string
+}
+
+// Enabled returns true when either hmac secret or jwks url is set
+func (c *AuthConfig) Enabled() bool {
+	return c != nil && (c.HMACSecret != "" || c.JWKSURL != "")
+}
+
+type claimsContextKey struct{}
+
+// Authenticator validates JWT bearer tokens, nil Authenticator allows all requests
+type Authenticator struct {
+	config *AuthConfig
+	keys   *jwksKeys
+	parser *jwt.Parser
+}
+
+// NewAuthenticator creates authenticator for hmac and/or jwks signed tokens
+func NewAuthenticator(config *AuthConfig) (*Authenticator, error) {
+	if !config.Enabled() {
+		return nil, ErrAuthNotConfigured
+	}
+
+	options := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	a := &Authenticator{
+		config: config,
+		parser: jwt.NewParser(options...),
+	}
+	if config.JWKSURL != "" {
+		a.keys = newJWKSKeys(config.JWKSURL)
+	}
+	return a, nil
+}
+
+func (a *Authenticator) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if a.config.HMACSecret == "" {
+				return nil, ErrUnexpectedSigningMethod
+			}
+			return []byte(a.config.HMACSecret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+			if a.keys == nil {
+				return nil, ErrUnexpectedSigningMethod
+			}
+			kid, _ := token.Header["kid"].(string)
+			return a.keys.key(ctx, kid)
+		default:
+			return nil, ErrUnexpectedSigningMethod
+		}
+	}
+}
+
+// Verify validates bearer token of the request and returns its claims
+func (a *Authenticator) Verify(r *http.Request) (jwt.MapClaims, error) {
+	tokenString, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || tokenString == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(tokenString, claims, a.keyFunc(r.Context())); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Required middleware rejects requests without valid bearer token with 401,
+// claims of valid tokens are available to handlers with ClaimsFromContext
+func (a *Authenticator) Required(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := a.Verify(r)
+		if err != nil {
+			response.Error(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+	})
+}
+
+// ClaimsFromContext returns claims of authenticated request
+func ClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(jwt.MapClaims)
+	return claims, ok
+}
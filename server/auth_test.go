@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func claimsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	subject, _ := claims.GetSubject()
+	_, _ = w.Write([]byte(subject))
+}
+
+func authRequest(t *testing.T, handler http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "agent",
+		"iss": "orchestrator",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	auth, err := NewAuthenticator(&AuthConfig{HMACSecret: "secret", Issuer: "orchestrator"})
+	require.NoError(t, err)
+	handler := auth.Required(http.HandlerFunc(claimsHandler))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	rec := authRequest(t, handler, token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "agent", rec.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(t, handler, "").Code)
+
+	invalid, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("wrong"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(t, handler, invalid).Code)
+
+	claims := testClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(t, handler, expired).Code)
+
+	claims = testClaims()
+	claims["iss"] = "other"
+	otherIssuer, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(t, handler, otherIssuer).Code)
+}
+
+func TestJWKSAuthenticator(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	fetches := 0
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		keySet := jsonWebKeySet{Keys: []jsonWebKey{{
+			Kid: "key1",
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		}}}
+		require.NoError(t, json.NewEncoder(w).Encode(keySet))
+	}))
+	defer jwksServer.Close()
+
+	auth, err := NewAuthenticator(&AuthConfig{JWKSURL: jwksServer.URL})
+	require.NoError(t, err)
+	handler := auth.Required(http.HandlerFunc(claimsHandler))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+	token.Header["kid"] = "key1"
+	signed, err := token.SignedString(privateKey)
+	require.NoError(t, err)
+
+	rec := authRequest(t, handler, signed)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "agent", rec.Body.String())
+	assert.Equal(t, http.StatusOK, authRequest(t, handler, signed).Code)
+	assert.Equal(t, 1, fetches)
+
+	// hmac tokens are rejected when only jwks is configured
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(t, handler, hmacToken).Code)
+
+	token.Header["kid"] = "unknown"
+	signed, err = token.SignedString(privateKey)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(t, handler, signed).Code)
+}
+
+func TestJWKSRefreshKeepsServingCachedKeys(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		keySet := jsonWebKeySet{Keys: []jsonWebKey{{
+			Kid: "key1",
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		}}}
+		assert.NoError(t, json.NewEncoder(w).Encode(keySet))
+	}))
+	defer jwksServer.Close()
+
+	keys := newJWKSKeys(jwksServer.URL)
+	ctx := context.Background()
+	_, err = keys.key(ctx, "key1")
+	require.NoError(t, err)
+
+	// stale key set is refetched by one request while others keep using cached key
+	keys.lock.Lock()
+	keys.fetched = time.Now().Add(-jwksRefreshInterval)
+	keys.lock.Unlock()
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := keys.key(ctx, "key1")
+		refreshed <- err
+	}()
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	key, err := keys.key(ctx, "key1")
+	require.NoError(t, err)
+	assert.NotNil(t, key)
+
+	close(release)
+	require.NoError(t, <-refreshed)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestNilAuthenticator(t *testing.T) {
+	_, err := NewAuthenticator(&AuthConfig{})
+	assert.ErrorIs(t, err, ErrAuthNotConfigured)
+
+	var auth *Authenticator
+	handler := auth.Required(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	assert.Equal(t, http.StatusNoContent, authRequest(t, handler, "").Code)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nixmade/orchestrator/response"
+)
+
+const (
+	// compressionLevel of gzip responses, favours speed over size
+	compressionLevel = 5
+	// NDJSONContentType is content type of json values streamed one per line
+	NDJSONContentType = "application/x-ndjson"
+)
+
+// Compress middleware gzips json and ndjson responses of clients accepting gzip
+func Compress(next http.Handler) http.Handler {
+	return middleware.Compress(compressionLevel, "application/json", NDJSONContentType)(next)
+}
+
+// gzipBody closes gzip reader along with request body
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	if err := b.Reader.Close(); err != nil {
+		return err
+	}
+	return b.body.Close()
+}
+
+// Decompress middleware decodes request bodies sent with Content-Encoding gzip,
+// requests with other encodings are rejected with 415
+func Decompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+		case "", "identity":
+		case "gzip":
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "invalid gzip request body: "+err.Error())
+				return
+			}
+			r.Body = &gzipBody{Reader: reader, body: r.Body}
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+		default:
+			response.Error(w, http.StatusUnsupportedMediaType, "unsupported content encoding "+encoding)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,20 +2,29 @@ package server
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/redact"
 	"github.com/rs/zerolog"
 )
 
+// shutdownTimeout to drain in flight requests on shutdown
+const shutdownTimeout = 30 * time.Second
+
 type AppContext interface {
 	Name() string
 	Create(zerolog.Logger) error
@@ -23,11 +32,30 @@ type AppContext interface {
 	Handler() http.Handler
 }
 
+// ConfigProvider is implemented by apps supplying listen address, TLS and log level,
+// apps not implementing it are configured using environment variables
+type ConfigProvider interface {
+	Config() *config.Config
+}
+
+func appConfig(app AppContext) *config.Config {
+	if provider, ok := app.(ConfigProvider); ok {
+		if cfg := provider.Config(); cfg != nil {
+			return cfg
+		}
+	}
+	if cfg, err := config.FromEnv(); err == nil {
+		return cfg
+	}
+	return config.Default()
+}
+
 // Context stores local and aggregate stores
 type Context struct {
 	srv    *http.Server
 	logger zerolog.Logger
 	app    AppContext
+	config *config.Config
 }
 
 // Create App context creating router handling multiple REST API
@@ -36,14 +64,27 @@ func (ctx *Context) Create() error {
 		return err
 	}
 
+	// long lived streams observe base context, cancelled as soon as shutdown starts
+	baseCtx, cancel := context.WithCancel(context.Background())
 	ctx.srv = &http.Server{
-		Addr:         "127.0.0.1:8080",
+		Addr:         ctx.config.ListenAddress(),
 		Handler:      ctx.app.Handler(),
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second}
+		WriteTimeout: 10 * time.Second,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
+	}
+	ctx.srv.RegisterOnShutdown(cancel)
 
+	tls := ctx.config.Server.TLS
+	ctx.logger.Info().Str("Address", ctx.srv.Addr).Bool("TLS", tls.Enabled()).Msg("Starting HTTP server")
 	go func() {
-		if err := ctx.srv.ListenAndServe(); err != nil {
+		var err error
+		if tls.Enabled() {
+			err = ctx.srv.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+		} else {
+			err = ctx.srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			msg := fmt.Sprintf("%s", err)
 			if flag.Lookup("test.v") == nil {
 				ctx.logger.Fatal().Msg(msg)
@@ -59,14 +100,23 @@ func (ctx *Context) Create() error {
 // Create creates and sets up context, stores and starts HTTP Server
 func Create(app AppContext) (*Context, error) {
 	appName := os.Getenv("APP_NAME")
-	ctx := &Context{app: app}
+	ctx := &Context{app: app, config: appConfig(app)}
 
 	// register default and app routes
-	level, err := zerolog.ParseLevel(strings.ToLower(os.Getenv("APP_LOG_LEVEL")))
+	level, err := zerolog.ParseLevel(strings.ToLower(ctx.config.Log.Level))
 	if err != nil {
 		level = zerolog.FatalLevel
 	}
-	logger := zerolog.New(os.Stderr).With().Caller().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(level)
+	if err := redact.ConfigureFromEnv(); err != nil {
+		return nil, err
+	}
+	var out io.Writer = zerolog.ConsoleWriter{Out: os.Stderr}
+	if ctx.config.Log.Format == config.LogFormatJSON {
+		out = os.Stderr
+	}
+	// level is global so it can be changed at runtime
+	zerolog.SetGlobalLevel(level)
+	logger := zerolog.New(os.Stderr).With().Caller().Timestamp().Logger().Output(redact.NewWriter(out, redact.Default))
 
 	// Use the right ID below
 	ctx.logger = logger.With().Str("Application", appName).Logger()
@@ -82,51 +132,52 @@ func Create(app AppContext) (*Context, error) {
 }
 
 // DeleteContext app context and HTTP Server
+// HTTP server stops accepting requests and drains in flight requests first,
+// app is deleted afterwards so pending store writes are flushed before store is closed
 func (ctx *Context) Delete() error {
 	if ctx == nil {
 		return nil
 	}
-	if err := ctx.app.Delete(); err != nil {
-		return err
+
+	ctx.logger.Info().Dur("Timeout", shutdownTimeout).Msg("Draining in flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	// even if there is an error shutting down HTTP its ok to ignore, app still needs to be deleted
+	if err := ctx.srv.Shutdown(shutdownCtx); err != nil {
+		ctx.logger.Error().Err(err).Msg("Failed to drain in flight requests")
 	}
-	// Shutdown HTTP server
-	// even if there is an error shutting down HTTP its ok to ignore
-	return ctx.srv.Shutdown(context.TODO())
+
+	return ctx.app.Delete()
 }
 
 func DefaultRouter() *chi.Mux {
 	router := chi.NewRouter()
-	router.Use(middleware.RequestID)
-	//router.Use(NewStructuedLogger(logger))
+	router.Use(RequestID)
 	router.Use(middleware.Recoverer)
+	router.Use(Decompress)
+	router.Use(Compress)
 	router.Use(middleware.URLFormat)
 	router.Use(render.SetContentType(render.ContentTypeJSON))
 
-	// Seek, verify and validate JWT tokens
-	// router.Use(jwtauth.Verifier(auth))
-
-	// Handle valid / invalid tokens.
-	// router.Use(jwtauth.Authenticator(auth))
-
-	// Validating claims
-	// Might require to validate different claims for dashboard and API
-	//_, claims, _ := jwtauth.FromContext(router.Context())
+	// JWT tokens are enforced per route with Authenticator.Required,
+	// handlers read validated claims with ClaimsFromContext
 
 	return router
 }
 
-func waitForCtrlC() {
+func waitForSignal() os.Signal {
 	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, os.Interrupt)
-	<-sigc
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	return <-sigc
 }
 
-// Execute starts application and waits for ctrl+c
+// Execute starts application and waits for ctrl+c or SIGTERM, then shuts down gracefully
 func Execute(app AppContext) error {
 	ctx, err := Create(app)
 	if err != nil {
 		return err
 	}
-	waitForCtrlC()
+	sig := waitForSignal()
+	ctx.logger.Info().Str("Signal", sig.String()).Msg("Shutting down")
 	return ctx.Delete()
 }
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// keys are refetched after this interval to pick up rotations
+	jwksRefreshInterval = time.Hour
+	// unknown kid triggers refetch at most once within this interval
+	jwksMinRefreshInterval = 30 * time.Second
+)
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jwksKeys caches public keys served by jwks url
+type jwksKeys struct {
+	url     string
+	client  *http.Client
+	lock    sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+func newJWKSKeys(url string) *jwksKeys {
+	return &jwksKeys{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   map[string]interface{}{},
+	}
+}
+
+// key returns public key for kid, refetching key set when stale or kid is unknown,
+// key set is fetched without holding lock so concurrent requests keep using cached keys
+func (k *jwksKeys) key(ctx context.Context, kid string) (interface{}, error) {
+	k.lock.Lock()
+	key, found := k.keys[kid]
+	sinceFetch := time.Since(k.fetched)
+	cached := (found && sinceFetch < jwksRefreshInterval) || (!found && sinceFetch < jwksMinRefreshInterval)
+	if !cached {
+		// other requests use cached keys while this one refetches
+		k.fetched = time.Now()
+	}
+	k.lock.Unlock()
+
+	if cached {
+		if !found {
+			return nil, ErrSigningKeyNotFound
+		}
+		return key, nil
+	}
+
+	keys, err := k.fetch(ctx)
+	if err != nil {
+		// keep serving cached key if jwks url is temporarily unavailable
+		if found {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	k.lock.Lock()
+	k.keys = keys
+	k.lock.Unlock()
+
+	if key, found = keys[kid]; !found {
+		return nil, ErrSigningKeyNotFound
+	}
+	return key, nil
+}
+
+// fetch loads key set from jwks url
+func (k *jwksKeys) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			if err != nil {
+				err = closeErr
+			}
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks %s, status %d", k.url, resp.StatusCode)
+	}
+
+	var keySet jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keySet); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, webKey := range keySet.Keys {
+		key, err := webKey.publicKey()
+		if err != nil {
+			// skip unsupported keys, such as encryption keys
+			continue
+		}
+		keys[webKey.Kid] = key
+	}
+	return keys, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+func (w jsonWebKey) publicKey() (interface{}, error) {
+	switch w.Kty {
+	case "RSA":
+		n, err := decodeBigInt(w.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(w.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch w.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", w.Crv)
+		}
+		x, err := decodeBigInt(w.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(w.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", w.Kty)
+	}
+}
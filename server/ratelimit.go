@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/config"
+	"github.com/nixmade/orchestrator/response"
+)
+
+const (
+	// rateLimitSweepInterval is how often buckets refilled to burst are forgotten
+	rateLimitSweepInterval = time.Minute
+	// rateLimitMaxBuckets bounds callers tracked at once, least recently seen callers are forgotten first
+	rateLimitMaxBuckets = 10000
+)
+
+// rateLimit is refill rate and capacity of buckets of a class of routes
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+func newRateLimit(rate float64, burst int) rateLimit {
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return rateLimit{rate: rate, burst: float64(burst)}
+}
+
+// bucket holds tokens of a caller, refilled at rate of its class up to burst
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  rateLimit
+	// seen is time of last request of caller, last is also moved by sweeps
+	seen time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.limit.burst, b.tokens+now.Sub(b.last).Seconds()*b.limit.rate)
+	b.last = now
+}
+
+// RateLimiter limits requests of each caller with token buckets, callers are identified by subject of
+// verified token or remote address, reads and mutations have separate buckets. It must run after
+// Authenticator.Required so tokens are verified. nil RateLimiter allows all requests
+type RateLimiter struct {
+	reads      rateLimit
+	writes     rateLimit
+	now        func() time.Time
+	maxBuckets int
+
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates rate limiter, nil if config limits neither reads nor writes
+func NewRateLimiter(config config.RateLimitConfig) *RateLimiter {
+	if !config.Enabled() {
+		return nil
+	}
+	return &RateLimiter{
+		reads:      newRateLimit(config.ReadsPerSecond, config.ReadBurst),
+		writes:     newRateLimit(config.WritesPerSecond, config.WriteBurst),
+		now:        time.Now,
+		maxBuckets: rateLimitMaxBuckets,
+		buckets:    make(map[string]*bucket),
+	}
+}
+
+// rateLimitKey identifies caller by subject of verified token, or remote host if request is not authenticated,
+// unverified authorization headers are ignored so callers can not get fresh buckets by changing them
+func rateLimitKey(r *http.Request) string {
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		if subject, err := claims.GetSubject(); err == nil && subject != "" {
+			return "sub:" + subject
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// allow takes a token from bucket of key, returns how long to wait for a token if there is none
+func (l *RateLimiter) allow(key string, limit rateLimit) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxBuckets {
+			l.sweep(now)
+		}
+		if len(l.buckets) >= l.maxBuckets {
+			l.evict()
+		}
+		b = &bucket{tokens: limit.burst, last: now, limit: limit}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	b.seen = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.rate * float64(time.Second))
+}
+
+// sweep forgets buckets refilled to burst, they are created full again on next request
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.limit.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// evict forgets least recently seen bucket
+func (l *RateLimiter) evict() {
+	var oldest string
+	for key, b := range l.buckets {
+		if oldest == "" || b.seen.Before(l.buckets[oldest].seen) {
+			oldest = key
+		}
+	}
+	delete(l.buckets, oldest)
+}
+
+// Limit middleware rejects requests of callers out of tokens with 429 and Retry-After seconds,
+// GET and HEAD requests take tokens of reads, other methods of writes
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, class := l.writes, "writes"
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			limit, class = l.reads, "reads"
+		}
+		if limit.rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, wait := l.allow(class+"/"+rateLimitKey(r), limit)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.Error(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit of %g %s per second exceeded", limit.rate, class))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nixmade/orchestrator/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, NewRateLimiter(config.RateLimitConfig{}))
+
+	limiter := NewRateLimiter(config.RateLimitConfig{ReadsPerSecond: 1, ReadBurst: 2, WritesPerSecond: 0.5})
+	require.NotNil(t, limiter)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	// subject is set on requests verified by authenticator
+	request := func(method, subject, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if subject != "" {
+			req = req.WithContext(context.WithValue(req.Context(), claimsContextKey{}, jwt.MapClaims{"sub": subject}))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// reads burst to 2, then wait a second for next token
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "a", "10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "a", "10.0.0.1:1000").Code)
+	w := request(http.MethodGet, "a", "10.0.0.1:1000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// other subjects and writes have their own buckets, write burst defaults to 1
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "b", "10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "a", "10.0.0.1:1000").Code)
+	w = request(http.MethodPost, "a", "10.0.0.1:1000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// unauthenticated callers are identified by remote host, unverified tokens are ignored
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "", "10.0.0.2:1000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(http.MethodPost, "", "10.0.0.2:2000").Code)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.2:3000"
+	req.Header.Set("Authorization", "Bearer forged")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "a", "10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(http.MethodPost, "a", "10.0.0.1:1000").Code)
+
+	// buckets refilled to burst are forgotten
+	now = now.Add(rateLimitSweepInterval)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "c", "10.0.0.1:1000").Code)
+	limiter.lock.Lock()
+	assert.Len(t, limiter.buckets, 1)
+	limiter.lock.Unlock()
+}
+
+func TestRateLimiterMaxBuckets(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitConfig{ReadsPerSecond: 1})
+	limiter.maxBuckets = 2
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	allowed, _ := limiter.allow("a", limiter.reads)
+	assert.True(t, allowed)
+	now = now.Add(time.Millisecond)
+	allowed, _ = limiter.allow("b", limiter.reads)
+	assert.True(t, allowed)
+
+	// least recently seen caller is forgotten once callers exceed max buckets
+	now = now.Add(time.Millisecond)
+	allowed, _ = limiter.allow("c", limiter.reads)
+	assert.True(t, allowed)
+	limiter.lock.Lock()
+	assert.Len(t, limiter.buckets, 2)
+	assert.NotContains(t, limiter.buckets, "a")
+	limiter.lock.Unlock()
+}
+
+func TestRateLimiterUnlimitedClass(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitConfig{WritesPerSecond: 1})
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// RequestIDHeader carries id of request, set by callers to correlate their logs or generated by server,
+	// returned in every response
+	RequestIDHeader = "X-Request-Id"
+	// maxRequestID is longest request id accepted from callers, longer ids are replaced
+	maxRequestID = 128
+)
+
+// validRequestID accepts ids of callers made of letters, digits and -._: so they are safe in log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.', c == '_', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// RequestID middleware keeps valid X-Request-Id of request or generates one, sets it on response and
+// in request context, where middleware.GetReqID finds it
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LogRequests middleware adds logger with RequestID, Method and Path of request to request context, where
+// zerolog.Ctx finds it, and logs completion of each request. Server errors are logged as errors, other
+// requests at debug level
+func LogRequests(logger zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestLogger := logger.With().
+				Str("RequestID", middleware.GetReqID(r.Context())).
+				Str("Method", r.Method).
+				Str("Path", r.URL.Path).
+				Logger()
+
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(requestLogger.WithContext(r.Context())))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			event := requestLogger.Debug()
+			if status >= http.StatusInternalServerError {
+				event = requestLogger.Error()
+			}
+			event.Int("Status", status).Int("Bytes", ww.BytesWritten()).Dur("Duration", time.Since(start)).Msg("Request completed")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = middleware.GetReqID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+
+	// id of caller is kept
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "agent-42:report.1")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "agent-42:report.1", seen)
+	assert.Equal(t, "agent-42:report.1", w.Header().Get(RequestIDHeader))
+
+	// ids unsafe in logs are replaced
+	for _, id := range []string{"agent 42", "agent\n42", strings.Repeat("a", maxRequestID+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.NotEqual(t, id, seen)
+		assert.Len(t, seen, 32)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs).Level(zerolog.DebugLevel)
+	handler := RequestID(LogRequests(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).Info().Msg("handling")
+		http.Error(w, "failed", http.StatusInternalServerError)
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/orchestrate/ns/entity", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, "req-1", record["RequestID"])
+		assert.Equal(t, "/v1/orchestrate/ns/entity", record["Path"])
+	}
+
+	var completed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &completed))
+	assert.Equal(t, "error", completed["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), completed["Status"])
+}
@@ -0,0 +1,152 @@
+// Package signature signs requests orchestrator sends to controllers and webhooks, and verifies them on receivers
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Header carries signature of request
+	Header = "X-Orchestrator-Signature"
+	// TimestampHeader carries unix seconds request was signed at
+	TimestampHeader = "X-Orchestrator-Timestamp"
+	// SlackHeader carries signature of requests sent by Slack
+	SlackHeader = "X-Slack-Signature"
+	// SlackTimestampHeader carries unix seconds Slack signed request at
+	SlackTimestampHeader = "X-Slack-Request-Timestamp"
+	// DefaultTolerance is how far signed timestamp may be from receiver clock before request is rejected as replay
+	DefaultTolerance = 5 * time.Minute
+
+	versionPrefix = "v1="
+	payloadPrefix = "sha256="
+	slackPrefix   = "v0="
+)
+
+var (
+	// ErrMissingSignature returns an error if request has no signature or timestamp
+	ErrMissingSignature = errors.New("missing request signature")
+	// ErrInvalidSignature returns an error if signature does not match body signed with secret
+	ErrInvalidSignature = errors.New("invalid request signature")
+	// ErrTimestampExpired returns an error if signed timestamp is outside tolerance
+	ErrTimestampExpired = errors.New("request signature timestamp outside tolerance")
+)
+
+func mac(secret string, parts ...[]byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// Sign returns v1=<hex> HMAC-SHA256 of "<unix timestamp>." followed by body
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return versionPrefix + hex.EncodeToString(mac(secret, []byte(strconv.FormatInt(timestamp.Unix(), 10)+"."), body))
+}
+
+// SignRequest sets timestamp and signature headers of request with body
+func SignRequest(req *http.Request, secret string, body []byte, now time.Time) {
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(Header, Sign(secret, now, body))
+}
+
+// Verify checks signature headers of request with body, timestamp must be within tolerance of now
+func Verify(header http.Header, body []byte, secret string, tolerance time.Duration, now time.Time) error {
+	signature, timestamp := header.Get(Header), header.Get(TimestampHeader)
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signedAt := time.Unix(seconds, 0)
+	if now.Sub(signedAt).Abs() > tolerance {
+		return ErrTimestampExpired
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, signedAt, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyRequest reads and verifies body of request, body is restored for handlers
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err := r.Body.Close(); err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return Verify(r.Header, body, secret, tolerance, time.Now())
+}
+
+// Middleware rejects requests without valid signature with 401
+func Middleware(secret string, tolerance time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := VerifyRequest(r, secret, tolerance); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SignPayload returns sha256=<hex> HMAC-SHA256 of payload, sent by notification webhooks without timestamp
+func SignPayload(secret string, payload []byte) string {
+	return payloadPrefix + hex.EncodeToString(mac(secret, payload))
+}
+
+// VerifyPayload checks sha256=<hex> signature of notification webhook payload
+func VerifyPayload(signature string, payload []byte, secret string) error {
+	if !strings.HasPrefix(signature, payloadPrefix) {
+		return ErrMissingSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignPayload(secret, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignSlack returns v0=<hex> HMAC-SHA256 of "v0:<unix timestamp>:" followed by body, as Slack signs requests
+func SignSlack(secret string, timestamp time.Time, body []byte) string {
+	return slackPrefix + hex.EncodeToString(mac(secret, []byte("v0:"+strconv.FormatInt(timestamp.Unix(), 10)+":"), body))
+}
+
+// VerifySlack checks Slack signature headers of request with body signed with app signing secret,
+// timestamp must be within tolerance of now
+func VerifySlack(header http.Header, body []byte, secret string, tolerance time.Duration, now time.Time) error {
+	signature, timestamp := header.Get(SlackHeader), header.Get(SlackTimestampHeader)
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signedAt := time.Unix(seconds, 0)
+	if now.Sub(signedAt).Abs() > tolerance {
+		return ErrTimestampExpired
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(SignSlack(secret, signedAt, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package signature
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"targets":[{"name":"target0"}]}`)
+	now := time.Now()
+
+	req := httptest.NewRequest(http.MethodPost, "/approval", bytes.NewReader(body))
+	SignRequest(req, "secret", body, now)
+	assert.Equal(t, strconv.FormatInt(now.Unix(), 10), req.Header.Get(TimestampHeader))
+
+	assert.NoError(t, Verify(req.Header, body, "secret", DefaultTolerance, now.Add(time.Minute)))
+	assert.ErrorIs(t, Verify(req.Header, body, "other", DefaultTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(req.Header, []byte(`{"targets":[]}`), "secret", DefaultTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(req.Header, body, "secret", DefaultTolerance, now.Add(10*time.Minute)), ErrTimestampExpired)
+	assert.ErrorIs(t, Verify(http.Header{}, body, "secret", DefaultTolerance, now), ErrMissingSignature)
+
+	// timestamp is part of signature
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+	assert.ErrorIs(t, Verify(req.Header, body, "secret", DefaultTolerance, now), ErrInvalidSignature)
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware("secret", DefaultTolerance)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		_, err = w.Write(body)
+		assert.NoError(t, err)
+	}))
+
+	body := []byte(`{"status":"ok"}`)
+	req := httptest.NewRequest(http.MethodPost, "/monitoring", bytes.NewReader(body))
+	SignRequest(req, "secret", body, time.Now())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, rec.Body.Bytes())
+
+	req = httptest.NewRequest(http.MethodPost, "/monitoring", bytes.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestVerifyPayload(t *testing.T) {
+	payload := []byte(`{"type":"RolloutStarted"}`)
+	assert.NoError(t, VerifyPayload(SignPayload("secret", payload), payload, "secret"))
+	assert.ErrorIs(t, VerifyPayload(SignPayload("other", payload), payload, "secret"), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyPayload("", payload, "secret"), ErrMissingSignature)
+}
+
+func TestVerifySlack(t *testing.T) {
+	// example from Slack request verification docs
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c")
+	secret := "8f742231b10e8888abcd99yyyzzz85a5"
+	now := time.Unix(1531420618, 0)
+
+	header := http.Header{}
+	header.Set(SlackTimestampHeader, "1531420618")
+	header.Set(SlackHeader, "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503")
+	assert.NoError(t, VerifySlack(header, body, secret, DefaultTolerance, now))
+	assert.ErrorIs(t, VerifySlack(header, body, "other", DefaultTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySlack(header, body, secret, DefaultTolerance, now.Add(10*time.Minute)), ErrTimestampExpired)
+	assert.ErrorIs(t, VerifySlack(http.Header{}, body, secret, DefaultTolerance, now), ErrMissingSignature)
+}
@@ -1,12 +1,12 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
-	"sort"
+	"errors"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/ohler55/ojg/jp"
-	"github.com/ohler55/ojg/oj"
+	"github.com/dgraph-io/badger/v4/pb"
 )
 
 // BadgerDBStore store for db
@@ -40,6 +40,16 @@ func (s *BadgerDBStore) Close() error {
 	return s.db.Close()
 }
 
+// Ping checks db is open and readable
+func (s *BadgerDBStore) Ping() error {
+	if s.db.IsClosed() {
+		return ErrStoreClosed
+	}
+	return s.db.View(func(txn *badger.Txn) error {
+		return nil
+	})
+}
+
 // Save db with key value pair
 func (s *BadgerDBStore) save(key, value string) error {
 	// Update DB
@@ -60,6 +70,68 @@ func (s *BadgerDBStore) SaveJSON(key string, jsonValue interface{}) error {
 	return s.save(key, string(value))
 }
 
+// SaveJSONBatch saves key json values using a write batch, large batches are split into
+// multiple transactions so the batch is not atomic
+func (s *BadgerDBStore) SaveJSONBatch(values map[string]any) error {
+	marshalled, err := marshalBatch(values)
+	if err != nil {
+		return err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for key, value := range marshalled {
+		if err := wb.Set([]byte(key), []byte(value)); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// badgerTxn adapts badger transaction to StoreTxn
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t *badgerTxn) SaveJSON(key string, jsonValue interface{}) error {
+	value, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	return t.txn.Set([]byte(key), value)
+}
+
+func (t *badgerTxn) Delete(key string) error {
+	return t.txn.Delete([]byte(key))
+}
+
+func (t *badgerTxn) LoadJSON(key string, value interface{}) error {
+	item, err := t.txn.Get([]byte(key))
+	if err == badger.ErrKeyNotFound {
+		return ErrKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return item.Value(func(byteVal []byte) error {
+		return json.Unmarshal(byteVal, value)
+	})
+}
+
+// Txn runs fn in a badger read write transaction, committed if fn returns nil
+func (s *BadgerDBStore) Txn(fn func(txn StoreTxn) error) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn: txn})
+	})
+}
+
+// Lock acquires lease key, transactions writing the same lease key concurrently conflict
+func (s *BadgerDBStore) Lock(ctx context.Context, name string) (context.Context, UnlockFunc, error) {
+	return leaseLock(ctx, s, name, func(err error) bool {
+		return errors.Is(err, badger.ErrConflict)
+	})
+}
+
 // Delete deletes key from db
 func (s *BadgerDBStore) Delete(key string) error {
 	// Update DB
@@ -140,6 +212,39 @@ func (s *BadgerDBStore) LoadKeys(prefix string) ([]string, error) {
 	return keys, err
 }
 
+// LoadKeysN loads up to limit keys with prefix after cursor in key order
+func (s *BadgerDBStore) LoadKeysN(prefix, cursor string, limit int) ([]string, string, error) {
+	var keys []string
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		start := []byte(prefix)
+		if cursor > prefix {
+			start = []byte(cursor)
+		}
+		for it.Seek(start); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			key := string(it.Item().Key())
+			if key == cursor {
+				continue
+			}
+			keys = append(keys, key)
+			if limit > 0 && len(keys) > limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys, next := pageKeys(keys, limit)
+	return keys, next, nil
+}
+
 func (s *BadgerDBStore) LoadValues(prefix string, iter ValueIterator) error {
 	err := s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
@@ -171,117 +276,55 @@ func (s *BadgerDBStore) Count(prefix string) (uint64, error) {
 	return uint64(len(keys)), nil
 }
 
-func (s *BadgerDBStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error {
-	valueIter := func(key any, value interface{}) error {
-		obj, err := oj.ParseString(value.(string))
-		if err != nil {
-			return err
-		}
-		path, err := jp.ParseString(jsonPath)
-		if err != nil {
-			return err
-		}
-
-		for _, res := range path.Get(obj) {
-			return iter(key, res)
-		}
-
-		return nil
+// Watch sends changes of keys with prefix using badger subscription, changes committed before
+// subscription is registered in background are not sent
+func (s *BadgerDBStore) Watch(prefix string) (<-chan KeyEvent, CancelFunc) {
+	if s.db.IsClosed() {
+		return closedWatch()
 	}
-	return s.LoadValues(prefix, valueIter)
-}
-
-func (s *BadgerDBStore) CountJsonPath(prefix, jsonPath string, iter ValueIterator) error {
-	valCount := make(map[any]int64)
-	valueIter := func(key any, value any) error {
-		obj, err := oj.ParseString(value.(string))
-		if err != nil {
-			return err
-		}
-		path, err := jp.ParseString(jsonPath)
-		if err != nil {
-			return err
-		}
 
-		for _, val := range path.Get(obj) {
-			if count, ok := valCount[val]; ok {
-				valCount[val] = count + int64(1)
-			} else {
-				valCount[val] = int64(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan KeyEvent, watchBufferSize)
+	go func() {
+		defer close(events)
+		// returns once ctx is cancelled or db is closed
+		_ = s.db.Subscribe(ctx, func(kvs *badger.KVList) error {
+			for _, kv := range kvs.Kv {
+				// deletes are published without value, json values are never empty
+				event := KeyEvent{Key: string(kv.Key), Value: string(kv.Value), Deleted: len(kv.Value) == 0}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
-		}
-
-		return nil
-	}
-	err := s.LoadValues(prefix, valueIter)
-	if err != nil {
-		return err
-	}
-
-	for key, value := range valCount {
-		if err := iter(key, value); err != nil {
-			return err
-		}
-	}
-
-	return nil
+			return nil
+		}, []pb.Match{{Prefix: []byte(prefix)}})
+	}()
+	return events, CancelFunc(cancel)
 }
 
-func (s *BadgerDBStore) SortedAscN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
-	return s.sortedN(prefix, jsonPath, "ASC", limit, iter)
+func (s *BadgerDBStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	return queryJsonPath(s.LoadValues, prefix, jsonPath, iter)
 }
 
-func (s *BadgerDBStore) SortedDescN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
-	return s.sortedN(prefix, jsonPath, "DESC", limit, iter)
+func (s *BadgerDBStore) QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error {
+	return queryJsonPaths(s.LoadValues, prefix, projection, iter)
 }
 
-func (s *BadgerDBStore) sortedN(prefix, jsonPath string, order string, limit int64, iter ValueIterator) error {
-	// Major problem here is that the ordering is done by converting the value to strings
-	// This is terrible for integers
-	var sorted [][]string
-	keyVal := make(map[string]string)
-	valueIter := func(key any, value interface{}) error {
-		obj, err := oj.ParseString(value.(string))
-		if err != nil {
-			return err
-		}
-		path, err := jp.ParseString(jsonPath)
-		if err != nil {
-			return err
-		}
-
-		for _, res := range path.Get(obj) {
-			sorted = append(sorted, []string{oj.JSON(res), key.(string)})
-		}
-
-		keyVal[key.(string)] = value.(string)
+// QueryEquals returns key and json value of values with jsonPath equal to value, values are filtered client side
+func (s *BadgerDBStore) QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error {
+	return queryEquals(s.LoadValues, prefix, jsonPath, value, iter)
+}
 
-		return nil
-	}
-	if err := s.LoadValues(prefix, valueIter); err != nil {
-		return err
-	}
+func (s *BadgerDBStore) CountJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	return countJsonPath(s.LoadValues, prefix, jsonPath, iter)
+}
 
-	sort.Slice(sorted, func(i, j int) bool {
-		if order == "DESC" {
-			return sorted[i][0] > sorted[j][0]
-		}
-		return sorted[i][0] < sorted[j][0]
-	})
+func (s *BadgerDBStore) SortedAscN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
+	return sortedN(s.LoadValues, prefix, jsonPath, "ASC", limit, iter)
+}
 
-	count := int64(limit)
-	if count <= 0 {
-		count = int64(len(sorted))
-	}
-	for _, pathKey := range sorted {
-		if count <= 0 {
-			break
-		}
-		key := pathKey[1]
-		if err := iter(key, keyVal[key]); err != nil {
-			return err
-		}
-		count -= int64(1)
-	}
-	return nil
+func (s *BadgerDBStore) SortedDescN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
+	return sortedN(s.LoadValues, prefix, jsonPath, "DESC", limit, iter)
 }
@@ -0,0 +1,113 @@
+package store
+
+import "context"
+
+// ContextStore wraps store, reads and scans fail with error of context once it is done,
+// writes are not cancelled so a change that started is applied as a whole
+type ContextStore struct {
+	Store
+	ctx context.Context
+}
+
+// WithContext returns store bound to ctx, store is returned as is if ctx is never done
+func WithContext(ctx context.Context, s Store) Store {
+	if ctx == nil || ctx.Done() == nil {
+		return s
+	}
+	if bound, ok := s.(*ContextStore); ok {
+		s = bound.Store
+	}
+	return &ContextStore{Store: s, ctx: ctx}
+}
+
+// Context returns context store is bound to
+func (s *ContextStore) Context() context.Context {
+	return s.ctx
+}
+
+// wrap returns iterator stopping with error of context once it is done
+func (s *ContextStore) wrap(iter ValueIterator) ValueIterator {
+	return func(key any, value any) error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		return iter(key, value)
+	}
+}
+
+func (s *ContextStore) LoadJSON(key string, value interface{}) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.LoadJSON(key, value)
+}
+
+func (s *ContextStore) LoadKeys(prefix string) ([]string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Store.LoadKeys(prefix)
+}
+
+func (s *ContextStore) LoadKeysN(prefix, cursor string, limit int) ([]string, string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return s.Store.LoadKeysN(prefix, cursor, limit)
+}
+
+func (s *ContextStore) LoadValues(prefix string, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.LoadValues(prefix, s.wrap(iter))
+}
+
+func (s *ContextStore) Count(prefix string) (uint64, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.Store.Count(prefix)
+}
+
+func (s *ContextStore) CountJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.CountJsonPath(prefix, jsonPath, s.wrap(iter))
+}
+
+func (s *ContextStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.QueryJsonPath(prefix, jsonPath, s.wrap(iter))
+}
+
+func (s *ContextStore) QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.QueryJsonPaths(prefix, projection, s.wrap(iter))
+}
+
+func (s *ContextStore) QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.QueryEquals(prefix, jsonPath, value, s.wrap(iter))
+}
+
+func (s *ContextStore) SortedAscN(prefix string, jsonPath string, limit int64, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.SortedAscN(prefix, jsonPath, limit, s.wrap(iter))
+}
+
+func (s *ContextStore) SortedDescN(prefix string, jsonPath string, limit int64, iter ValueIterator) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.SortedDescN(prefix, jsonPath, limit, s.wrap(iter))
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextStore(t *testing.T) {
+	badgerStore, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, badgerStore.Close())
+	}()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, badgerStore.SaveJSON(fmt.Sprintf("ctx:%d", i), i))
+	}
+
+	// store is not wrapped if context is never done
+	assert.Equal(t, badgerStore, WithContext(context.Background(), badgerStore))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store := WithContext(ctx, badgerStore)
+
+	items := 0
+	err = store.LoadValues("ctx:", func(key any, value any) error {
+		items++
+		if items == 3 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3, items)
+
+	var value int
+	assert.ErrorIs(t, store.LoadJSON("ctx:0", &value), context.Canceled)
+	_, err = store.LoadKeys("ctx:")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// writes are not cancelled
+	require.NoError(t, store.SaveJSON("ctx:10", 10))
+	require.NoError(t, badgerStore.LoadJSON("ctx:10", &value))
+	assert.Equal(t, 10, value)
+}
@@ -5,4 +5,24 @@ import "errors"
 var (
 	// ErrKeyNotFound returns an error if key is not found in store
 	ErrKeyNotFound = errors.New("key not found in store")
+	// ErrScanCancelled returns an error if scan was cancelled through ScanTracker
+	ErrScanCancelled = errors.New("store scan cancelled")
+	// ErrScanNotFound returns an error if scan is not in progress
+	ErrScanNotFound = errors.New("store scan not found")
+	// ErrStoreClosed returns an error if store is already closed
+	ErrStoreClosed = errors.New("store is closed")
+	// ErrUnknownDriver returns an error if store driver is not registered
+	ErrUnknownDriver = errors.New("unknown store driver")
+	// ErrInvalidExport returns an error if dump is not written by Export or has unsupported version
+	ErrInvalidExport = errors.New("invalid store export")
+	// ErrChecksumMismatch returns an error if migrated store does not match source store
+	ErrChecksumMismatch = errors.New("store checksum mismatch")
+	// ErrEncryptionKeyConflict returns an error if both encryption key and key provider are configured
+	ErrEncryptionKeyConflict = errors.New("encryption key and key provider are mutually exclusive")
+	// ErrTxnConflict returns an error if keys read in transaction were modified before it committed
+	ErrTxnConflict = errors.New("store transaction conflict")
+	// ErrLockTimeout returns an error if lock is held by another replica until context is done
+	ErrLockTimeout = errors.New("timed out acquiring store lock")
+	// ErrLockLost returns an error if store released lock before its holder unlocked it
+	ErrLockLost = errors.New("store lock lost")
 )
@@ -0,0 +1,376 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// etcdPageSize is number of keys loaded per range request
+	etcdPageSize    = 1000
+	etcdDialTimeout = 5 * time.Second
+	// etcdMaxTxnOps is default limit of operations in a single etcd transaction
+	etcdMaxTxnOps = 128
+)
+
+// EtcdStore stores json values in etcd so multiple replicas share state with strong consistency,
+// json paths are evaluated client side
+type EtcdStore struct {
+	client *clientv3.Client
+	// keyPrefix isolates keys when etcd cluster is shared
+	keyPrefix string
+}
+
+// NewEtcdStore creates a new etcd store, keys are stored with keyPrefix
+func NewEtcdStore(endpoints []string, keyPrefix string) (Store, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EtcdStore{client: client, keyPrefix: keyPrefix}
+	if err := s.Ping(); err != nil {
+		return nil, errors.Join(err, client.Close())
+	}
+	return s, nil
+}
+
+func (s *EtcdStore) etcdKey(key string) string {
+	return s.keyPrefix + key
+}
+
+// Close closes connections to etcd
+func (s *EtcdStore) Close() error {
+	return s.client.Close()
+}
+
+// Ping checks etcd cluster is reachable
+func (s *EtcdStore) Ping() error {
+	if s.client.Ctx().Err() != nil {
+		return ErrStoreClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdDialTimeout)
+	defer cancel()
+	_, err := s.client.Get(ctx, s.etcdKey(""), clientv3.WithCountOnly(), clientv3.WithLimit(1))
+	return err
+}
+
+// SaveJSON saves key with json value
+func (s *EtcdStore) SaveJSON(key string, jsonValue interface{}) error {
+	value, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(context.Background(), s.etcdKey(key), string(value))
+	return err
+}
+
+// SaveJSONBatch saves key json values in transactions of up to etcdMaxTxnOps keys,
+// batches larger than that are not atomic
+func (s *EtcdStore) SaveJSONBatch(values map[string]any) error {
+	marshalled, err := marshalBatch(values)
+	if err != nil {
+		return err
+	}
+
+	ops := make([]clientv3.Op, 0, min(len(marshalled), etcdMaxTxnOps))
+	for key, value := range marshalled {
+		ops = append(ops, clientv3.OpPut(s.etcdKey(key), value))
+		if len(ops) >= etcdMaxTxnOps {
+			if _, err := s.client.Txn(context.Background()).Then(ops...).Commit(); err != nil {
+				return err
+			}
+			ops = ops[:0]
+		}
+	}
+	if len(ops) > 0 {
+		_, err = s.client.Txn(context.Background()).Then(ops...).Commit()
+	}
+	return err
+}
+
+// Txn buffers writes of fn and applies them in a single etcd transaction if fn returns nil,
+// transaction fails with ErrTxnConflict if any key read by fn was modified meanwhile
+func (s *EtcdStore) Txn(fn func(txn StoreTxn) error) error {
+	revisions := make(map[string]int64)
+	txn := newBufferedTxn(func(key string) (string, error) {
+		resp, err := s.client.Get(context.Background(), s.etcdKey(key))
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Kvs) <= 0 {
+			revisions[key] = 0
+			return "", ErrKeyNotFound
+		}
+		revisions[key] = resp.Kvs[0].ModRevision
+		return string(resp.Kvs[0].Value), nil
+	})
+	if err := fn(txn); err != nil {
+		return err
+	}
+	if len(txn.ops) <= 0 {
+		return nil
+	}
+
+	cmps := make([]clientv3.Cmp, 0, len(revisions))
+	for key, revision := range revisions {
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(s.etcdKey(key)), "=", revision))
+	}
+	ops := make([]clientv3.Op, 0, len(txn.ops))
+	for _, op := range txn.ops {
+		if op.value == nil {
+			ops = append(ops, clientv3.OpDelete(s.etcdKey(op.key)))
+		} else {
+			ops = append(ops, clientv3.OpPut(s.etcdKey(op.key), *op.value))
+		}
+	}
+
+	resp, err := s.client.Txn(context.Background()).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrTxnConflict
+	}
+	return nil
+}
+
+// Lock creates lock key attached to a lease kept alive while lock is held,
+// lock key is removed with the lease if replica crashes, lock is lost once keep alive of lease stops
+func (s *EtcdStore) Lock(ctx context.Context, name string) (context.Context, UnlockFunc, error) {
+	key := s.etcdKey(lockPrefix + name)
+	grant, err := s.client.Grant(ctx, int64(lockTTL/time.Second))
+	if err != nil {
+		return nil, nil, err
+	}
+	revoke := func() error {
+		revokeCtx, cancel := context.WithTimeout(context.Background(), etcdDialTimeout)
+		defer cancel()
+		_, err := s.client.Revoke(revokeCtx, grant.ID)
+		return err
+	}
+
+	err = retryLock(ctx, name, func() (bool, error) {
+		resp, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, "", clientv3.WithLease(grant.ID))).
+			Commit()
+		if err != nil {
+			return false, err
+		}
+		return resp.Succeeded, nil
+	})
+	if err != nil {
+		return nil, nil, errors.Join(err, revoke())
+	}
+
+	keepAliveCtx, stop := context.WithCancel(context.Background())
+	keepAlive, err := s.client.KeepAlive(keepAliveCtx, grant.ID)
+	if err != nil {
+		stop()
+		return nil, nil, errors.Join(err, revoke())
+	}
+	holderCtx, cancel := holderContext(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// keep alive channel closes once lease expired or was revoked, or keep alive was stopped
+		for range keepAlive {
+		}
+		if keepAliveCtx.Err() == nil {
+			cancel(fmt.Errorf("%w %s: lease %x expired", ErrLockLost, name, grant.ID))
+		}
+	}()
+	return holderCtx, func() error {
+		stop()
+		<-stopped
+		defer cancel(nil)
+		return lostLock(holderCtx, revoke())
+	}, nil
+}
+
+// Delete deletes key
+func (s *EtcdStore) Delete(key string) error {
+	_, err := s.client.Delete(context.Background(), s.etcdKey(key))
+	return err
+}
+
+// DeletePrefix deletes all keys with prefix
+func (s *EtcdStore) DeletePrefix(prefix string) error {
+	_, err := s.client.Delete(context.Background(), s.etcdKey(prefix), clientv3.WithPrefix())
+	return err
+}
+
+// LoadJSON loads key and unmarshals json value
+func (s *EtcdStore) LoadJSON(key string, value interface{}) error {
+	resp, err := s.client.Get(context.Background(), s.etcdKey(key))
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) <= 0 {
+		return ErrKeyNotFound
+	}
+	return json.Unmarshal(resp.Kvs[0].Value, value)
+}
+
+// rangePrefix pages through keys with prefix in key order, all pages are read at the same revision
+func (s *EtcdStore) rangePrefix(prefix string, keysOnly bool, iter func(key, value string) error) error {
+	key := s.etcdKey(prefix)
+	end := clientv3.GetPrefixRangeEnd(key)
+	var revision int64
+
+	for {
+		opts := []clientv3.OpOption{
+			clientv3.WithRange(end),
+			clientv3.WithLimit(etcdPageSize),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+			clientv3.WithRev(revision),
+		}
+		if keysOnly {
+			opts = append(opts, clientv3.WithKeysOnly())
+		}
+
+		resp, err := s.client.Get(context.Background(), key, opts...)
+		if err != nil {
+			return err
+		}
+		revision = resp.Header.Revision
+
+		for _, kv := range resp.Kvs {
+			if err := iter(strings.TrimPrefix(string(kv.Key), s.keyPrefix), string(kv.Value)); err != nil {
+				return err
+			}
+		}
+
+		if !resp.More || len(resp.Kvs) <= 0 {
+			return nil
+		}
+		// continue after last key of this page
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+// LoadKeys loads all keys with prefix
+func (s *EtcdStore) LoadKeys(prefix string) ([]string, error) {
+	var keys []string
+	err := s.rangePrefix(prefix, true, func(key, _ string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+// LoadKeysN loads up to limit keys with prefix after cursor in key order
+func (s *EtcdStore) LoadKeysN(prefix, cursor string, limit int) ([]string, string, error) {
+	if limit <= 0 {
+		keys, err := s.LoadKeys(prefix)
+		return keys, "", err
+	}
+
+	key := s.etcdKey(prefix)
+	end := clientv3.GetPrefixRangeEnd(key)
+	if cursor > prefix {
+		// continue after cursor
+		key = s.etcdKey(cursor) + "\x00"
+	}
+
+	resp, err := s.client.Get(context.Background(), key,
+		clientv3.WithRange(end),
+		clientv3.WithLimit(int64(limit+1)),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+		clientv3.WithKeysOnly(),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys = append(keys, strings.TrimPrefix(string(kv.Key), s.keyPrefix))
+	}
+
+	keys, next := pageKeys(keys, limit)
+	return keys, next, nil
+}
+
+// LoadValues loads all keys and values with prefix
+func (s *EtcdStore) LoadValues(prefix string, iter ValueIterator) error {
+	return s.rangePrefix(prefix, false, func(key, value string) error {
+		return iter(key, value)
+	})
+}
+
+// Count returns number of keys with prefix
+func (s *EtcdStore) Count(prefix string) (uint64, error) {
+	resp, err := s.client.Get(context.Background(), s.etcdKey(prefix), clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return uint64(resp.Count), nil
+}
+
+// Watch sends changes of keys with prefix made by any replica until cancelled or store is closed
+func (s *EtcdStore) Watch(prefix string) (<-chan KeyEvent, CancelFunc) {
+	if s.client.Ctx().Err() != nil {
+		return closedWatch()
+	}
+
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(s.client.Ctx()))
+	events := make(chan KeyEvent, watchBufferSize)
+	watch := s.client.Watch(ctx, s.etcdKey(prefix), clientv3.WithPrefix())
+	go func() {
+		defer close(events)
+		for resp := range watch {
+			if resp.Err() != nil {
+				return
+			}
+			for _, event := range resp.Events {
+				keyEvent := KeyEvent{
+					Key:     strings.TrimPrefix(string(event.Kv.Key), s.keyPrefix),
+					Value:   string(event.Kv.Value),
+					Deleted: event.Type == clientv3.EventTypeDelete,
+				}
+				select {
+				case events <- keyEvent:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, CancelFunc(cancel)
+}
+
+func (s *EtcdStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	return queryJsonPath(s.LoadValues, prefix, jsonPath, iter)
+}
+
+func (s *EtcdStore) QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error {
+	return queryJsonPaths(s.LoadValues, prefix, projection, iter)
+}
+
+// QueryEquals returns key and json value of values with jsonPath equal to value, values are filtered client side
+func (s *EtcdStore) QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error {
+	return queryEquals(s.LoadValues, prefix, jsonPath, value, iter)
+}
+
+func (s *EtcdStore) CountJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	return countJsonPath(s.LoadValues, prefix, jsonPath, iter)
+}
+
+func (s *EtcdStore) SortedAscN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
+	return sortedN(s.LoadValues, prefix, jsonPath, "ASC", limit, iter)
+}
+
+func (s *EtcdStore) SortedDescN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
+	return sortedN(s.LoadValues, prefix, jsonPath, "DESC", limit, iter)
+}
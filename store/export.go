@@ -0,0 +1,82 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// ExportVersion is version of json lines dump written by Export
+	ExportVersion = 1
+	// exportMaxLine is longest value accepted by Import
+	exportMaxLine = 64 << 20
+)
+
+// ExportHeader is first line of a dump, followed by one ExportRecord per line
+type ExportHeader struct {
+	Version  int       `json:"version"`
+	Exported time.Time `json:"exported"`
+}
+
+// ExportRecord is key with raw json value
+type ExportRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Export writes all keys and values of store to w as versioned json lines, returns number of keys written
+func Export(s Store, w io.Writer) (int64, error) {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(ExportHeader{Version: ExportVersion, Exported: time.Now().UTC()}); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := s.LoadValues("", func(key, value any) error {
+		count++
+		return encoder.Encode(ExportRecord{Key: key.(string), Value: json.RawMessage(value.(string))})
+	})
+	return count, err
+}
+
+// Import saves keys and values from dump written by Export, existing keys are overwritten,
+// returns number of keys imported
+func Import(s Store, r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), exportMaxLine)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, ErrInvalidExport
+	}
+
+	var header ExportHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+	if header.Version != ExportVersion {
+		return 0, fmt.Errorf("%w: version %d", ErrInvalidExport, header.Version)
+	}
+
+	var count int64
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) <= 0 {
+			continue
+		}
+		var record ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("%w: line %d: %w", ErrInvalidExport, line, err)
+		}
+		if err := s.SaveJSON(record.Key, record.Value); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, scanner.Err()
+}
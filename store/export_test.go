@@ -0,0 +1,54 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	source, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, source.Close())
+	}()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, source.SaveJSON(fmt.Sprintf("export:%d", i), map[string]int{"Value": i}))
+	}
+
+	var dump bytes.Buffer
+	count, err := Export(source, &dump)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+	assert.Equal(t, 11, strings.Count(dump.String(), "\n"))
+
+	destination, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, destination.Close())
+	}()
+
+	count, err = Import(destination, &dump)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+
+	for i := 0; i < 10; i++ {
+		var value map[string]int
+		require.NoError(t, destination.LoadJSON(fmt.Sprintf("export:%d", i), &value))
+		assert.Equal(t, map[string]int{"Value": i}, value)
+	}
+
+	_, err = Import(destination, strings.NewReader(""))
+	require.ErrorIs(t, err, ErrInvalidExport)
+
+	_, err = Import(destination, strings.NewReader(`{"version":2}`+"\n"))
+	require.ErrorIs(t, err, ErrInvalidExport)
+
+	_, err = Import(destination, strings.NewReader(`{"version":1}`+"\n"+`{"key":`+"\n"))
+	require.ErrorIs(t, err, ErrInvalidExport)
+}
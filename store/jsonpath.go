@@ -0,0 +1,194 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// loadValuesFunc loads keys and values with prefix, used by stores evaluating json paths client side
+type loadValuesFunc func(prefix string, iter ValueIterator) error
+
+// queryJsonPath evaluates jsonPath client side over values loaded by loadValues
+func queryJsonPath(loadValues loadValuesFunc, prefix, jsonPath string, iter ValueIterator) error {
+	valueIter := func(key any, value interface{}) error {
+		obj, err := oj.ParseString(value.(string))
+		if err != nil {
+			return err
+		}
+		path, err := jp.ParseString(jsonPath)
+		if err != nil {
+			return err
+		}
+
+		for _, res := range path.Get(obj) {
+			return iter(key, res)
+		}
+
+		return nil
+	}
+	return loadValues(prefix, valueIter)
+}
+
+// ProjectValues evaluates projection of QueryJsonPaths client side over values loaded by loadValues, for
+// stores serving values from elsewhere, such as caches in front of a store
+func ProjectValues(loadValues func(prefix string, iter ValueIterator) error, prefix string, projection map[string]string, iter ValueIterator) error {
+	return queryJsonPaths(loadValues, prefix, projection, iter)
+}
+
+// queryJsonPaths evaluates projection client side over values loaded by loadValues
+func queryJsonPaths(loadValues loadValuesFunc, prefix string, projection map[string]string, iter ValueIterator) error {
+	paths := make(map[string]jp.Expr, len(projection))
+	for name, jsonPath := range projection {
+		path, err := jp.ParseString(jsonPath)
+		if err != nil {
+			return err
+		}
+		paths[name] = path
+	}
+
+	valueIter := func(key any, value interface{}) error {
+		obj, err := oj.ParseString(value.(string))
+		if err != nil {
+			return err
+		}
+
+		projected := make(map[string]any, len(paths))
+		for name, path := range paths {
+			for _, res := range path.Get(obj) {
+				projected[name] = res
+				break
+			}
+		}
+
+		return iter(key, projected)
+	}
+	return loadValues(prefix, valueIter)
+}
+
+// queryEquals returns key and json value of values loaded by loadValues where jsonPath equals value,
+// values not containing encoded value are skipped without being parsed
+func queryEquals(loadValues loadValuesFunc, prefix, jsonPath string, value any, iter ValueIterator) error {
+	path, err := jp.ParseString(jsonPath)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	want, err := oj.Parse(encoded)
+	if err != nil {
+		return err
+	}
+
+	valueIter := func(key any, jsonValue any) error {
+		if !strings.Contains(jsonValue.(string), string(encoded)) {
+			return nil
+		}
+		obj, err := oj.ParseString(jsonValue.(string))
+		if err != nil {
+			return err
+		}
+		for _, res := range path.Get(obj) {
+			if reflect.DeepEqual(res, want) {
+				return iter(key, jsonValue)
+			}
+		}
+		return nil
+	}
+	return loadValues(prefix, valueIter)
+}
+
+// countJsonPath counts values of jsonPath client side over values loaded by loadValues
+func countJsonPath(loadValues loadValuesFunc, prefix, jsonPath string, iter ValueIterator) error {
+	valCount := make(map[any]int64)
+	valueIter := func(key any, value any) error {
+		obj, err := oj.ParseString(value.(string))
+		if err != nil {
+			return err
+		}
+		path, err := jp.ParseString(jsonPath)
+		if err != nil {
+			return err
+		}
+
+		for _, val := range path.Get(obj) {
+			if count, ok := valCount[val]; ok {
+				valCount[val] = count + int64(1)
+			} else {
+				valCount[val] = int64(1)
+			}
+		}
+
+		return nil
+	}
+	err := loadValues(prefix, valueIter)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range valCount {
+		if err := iter(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedN sorts values by jsonPath client side over values loaded by loadValues
+func sortedN(loadValues loadValuesFunc, prefix, jsonPath string, order string, limit int64, iter ValueIterator) error {
+	// Major problem here is that the ordering is done by converting the value to strings
+	// This is terrible for integers
+	var sorted [][]string
+	keyVal := make(map[string]string)
+	valueIter := func(key any, value interface{}) error {
+		obj, err := oj.ParseString(value.(string))
+		if err != nil {
+			return err
+		}
+		path, err := jp.ParseString(jsonPath)
+		if err != nil {
+			return err
+		}
+
+		for _, res := range path.Get(obj) {
+			sorted = append(sorted, []string{oj.JSON(res), key.(string)})
+		}
+
+		keyVal[key.(string)] = value.(string)
+
+		return nil
+	}
+	if err := loadValues(prefix, valueIter); err != nil {
+		return err
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if order == "DESC" {
+			return sorted[i][0] > sorted[j][0]
+		}
+		return sorted[i][0] < sorted[j][0]
+	})
+
+	count := int64(limit)
+	if count <= 0 {
+		count = int64(len(sorted))
+	}
+	for _, pathKey := range sorted {
+		if count <= 0 {
+			break
+		}
+		key := pathKey[1]
+		if err := iter(key, keyVal[key]); err != nil {
+			return err
+		}
+		count -= int64(1)
+	}
+	return nil
+}
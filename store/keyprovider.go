@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// dataKeyFile stores data key wrapped by KeyProvider next to badger files
+	dataKeyFile = "datakey.wrapped"
+	// keyProviderTimeout bounds each call to KeyProvider
+	keyProviderTimeout = 30 * time.Second
+)
+
+// KeyProvider wraps and unwraps data keys encrypting store at rest, so only the wrapped data key
+// is kept on disk and the key encryption key never leaves Vault or KMS
+type KeyProvider interface {
+	// GenerateDataKey returns new plaintext data key and the same key wrapped by the key encryption key
+	GenerateDataKey(ctx context.Context) (plaintext []byte, wrapped []byte, err error)
+	// Decrypt unwraps data key returned by GenerateDataKey
+	Decrypt(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// DataKey returns plaintext data key for store in dir, a new data key is generated and its wrapped form
+// persisted in dir on first use, in memory stores (empty dir) get a new data key every time.
+// Data key is unwrapped once when store is opened and kept in memory by badger until store is closed,
+// so revoking access to the Vault or KMS key only takes effect on next open, rotating the key encryption
+// key neither re-wraps the persisted data key nor re-encrypts the store
+func DataKey(provider KeyProvider, dir string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	if dir == "" {
+		plaintext, _, err := provider.GenerateDataKey(ctx)
+		return plaintext, err
+	}
+
+	path := filepath.Join(dir, dataKeyFile)
+	wrapped, err := os.ReadFile(path)
+	if err == nil {
+		return provider.Decrypt(ctx, wrapped)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	plaintext, wrapped, err := provider.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, wrapped, 0o600); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault implements transit datakey and decrypt by prefixing plaintext
+func fakeVault(t *testing.T, decrypts *atomic.Int64, failing *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "root", r.Header.Get("X-Vault-Token"))
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"errors": []string{"sealed"}}))
+			return
+		}
+
+		var in map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/orchestrator":
+			key := make([]byte, 32)
+			_, err := rand.Read(key)
+			assert.NoError(t, err)
+			plaintext := base64.StdEncoding.EncodeToString(key)
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"plaintext": plaintext, "ciphertext": "vault:v1:" + plaintext,
+			}}))
+		case "/v1/transit/decrypt/orchestrator":
+			decrypts.Add(1)
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"plaintext": strings.TrimPrefix(in["ciphertext"].(string), "vault:v1:"),
+			}}))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"errors": []string{"not found"}}))
+		}
+	}))
+}
+
+func TestVaultKeyProvider(t *testing.T) {
+	var decrypts atomic.Int64
+	var failing atomic.Bool
+	server := fakeVault(t, &decrypts, &failing)
+	defer server.Close()
+
+	dir := t.TempDir()
+	provider := NewVaultKeyProvider(server.URL, "root", "", "orchestrator")
+
+	store, err := Open(BadgerDriver, dir, Options{KeyProvider: provider})
+	require.NoError(t, err)
+	require.NoError(t, store.SaveJSON("key", "value"))
+	require.NoError(t, store.Close())
+
+	wrapped, err := os.ReadFile(filepath.Join(dir, dataKeyFile))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(wrapped), "vault:v1:"))
+
+	// reopening unwraps persisted data key
+	store, err = Open(BadgerDriver, dir, Options{KeyProvider: provider})
+	require.NoError(t, err)
+	var value string
+	require.NoError(t, store.LoadJSON("key", &value))
+	assert.Equal(t, "value", value)
+	require.NoError(t, store.Close())
+	assert.Equal(t, int64(1), decrypts.Load())
+
+	_, err = Open(BadgerDriver, dir, Options{KeyProvider: provider, EncryptionKey: "0123456789abcdef"})
+	require.ErrorIs(t, err, ErrEncryptionKeyConflict)
+
+	_, err = NewVaultKeyProvider(server.URL, "root", "transit", "missing").Decrypt(context.Background(), wrapped)
+	require.Error(t, err)
+
+	// data key is only unwrapped on open, sealed vault prevents reopening store
+	failing.Store(true)
+	_, err = Open(BadgerDriver, dir, Options{KeyProvider: provider})
+	require.Error(t, err)
+}
+
+func TestAWSKMSKeyProvider(t *testing.T) {
+	// credentials are resolved by default credential chain, isolate it from host config and instance metadata
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-amz-json-1.1", r.Header.Get("Content-Type"))
+		assert.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/")
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-west-2/kms/aws4_request")
+
+		var in struct {
+			KeyId          string
+			KeySpec        string
+			CiphertextBlob []byte
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "alias/orchestrator", in.KeyId)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GenerateDataKey":
+			assert.Equal(t, "AES_256", in.KeySpec)
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{
+				"KeyId": in.KeyId, "Plaintext": bytes.Repeat([]byte{1}, 32), "CiphertextBlob": []byte("wrapped"),
+			}))
+		case "TrentService.Decrypt":
+			if !bytes.Equal(in.CiphertextBlob, []byte("wrapped")) {
+				w.WriteHeader(http.StatusBadRequest)
+				assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"__type": "InvalidCiphertextException"}))
+				return
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"KeyId": in.KeyId, "Plaintext": bytes.Repeat([]byte{1}, 32)}))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewAWSKMSKeyProvider(context.Background(), "us-west-2", "alias/orchestrator", server.URL)
+	require.NoError(t, err)
+
+	plaintext, wrapped, err := provider.GenerateDataKey(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, plaintext, 32)
+	assert.Equal(t, []byte("wrapped"), wrapped)
+
+	key, err := provider.Decrypt(context.Background(), wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, key)
+
+	_, err = provider.Decrypt(context.Background(), []byte("tampered"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "InvalidCiphertextException")
+}
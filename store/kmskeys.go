@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// AWSKMSKeyProvider wraps data keys with an AWS KMS key
+type AWSKMSKeyProvider struct {
+	keyID  string
+	client *kms.Client
+}
+
+// NewAWSKMSKeyProvider creates key provider using KMS key keyID (id, arn or alias) in region, credentials are
+// resolved by the default AWS credential chain (environment, shared config, web identity, ECS or EC2 roles),
+// endpoint overrides KMS endpoint if set
+func NewAWSKMSKeyProvider(ctx context.Context, region, keyID, endpoint string) (*AWSKMSKeyProvider, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	client := kms.NewFromConfig(cfg, func(o *kms.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &AWSKMSKeyProvider{keyID: keyID, client: client}, nil
+}
+
+// GenerateDataKey generates AES 256 data key wrapped by KMS key
+func (p *AWSKMSKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := p.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(p.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// Decrypt unwraps data key with KMS key
+func (p *AWSKMSKeyProvider) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := p.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(p.keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
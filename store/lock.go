@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// lockPrefix stores lease keys of stores without native locks
+	lockPrefix = "lock:"
+	// lockTTL expires locks of crashed replicas, held locks are renewed every lockTTL/3
+	lockTTL = 30 * time.Second
+	// lockRetry is initial wait between attempts to acquire a held lock, doubled up to lockMaxRetry
+	lockRetry    = 20 * time.Millisecond
+	lockMaxRetry = 500 * time.Millisecond
+)
+
+// UnlockFunc releases lock acquired with Store.Lock, returns ErrLockLost if lock was lost before
+type UnlockFunc func() error
+
+// holderContext returns context of lock holder with values of ctx, cancelled with ErrLockLost as cause
+// once lock is lost and with context.Canceled once lock is released
+func holderContext(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	return context.WithCancelCause(context.WithoutCancel(ctx))
+}
+
+// lostLock returns ErrLockLost if lock of holder context was lost, otherwise err of releasing it
+func lostLock(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) {
+		return cause
+	}
+	return err
+}
+
+// lockOwner returns random token identifying lock holder
+func lockOwner() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// retryLock calls tryLock until it acquires lock, backing off while lock is held by another owner,
+// returns ErrLockTimeout once ctx is done
+func retryLock(ctx context.Context, name string, tryLock func() (bool, error)) error {
+	wait := lockRetry
+	for {
+		acquired, err := tryLock()
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w %s: %w", ErrLockTimeout, name, ctx.Err())
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, lockMaxRetry)
+	}
+}
+
+// renewLock calls renew every ttl/3 until returned stop func is called, lock is lost once renew
+// reports it is no longer held or it was not renewed for ttl, since another owner may have taken it
+// in between, lost cancels holder with ErrLockLost and renewing stops
+func renewLock(name string, ttl time.Duration, renew func() (bool, error), lost context.CancelCauseFunc) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				expired := time.Since(renewed) >= ttl
+				held, err := renew()
+				switch {
+				case err == nil && !held:
+					lost(fmt.Errorf("%w %s", ErrLockLost, name))
+					return
+				case expired:
+					lost(fmt.Errorf("%w %s: not renewed for %s", ErrLockLost, name, ttl))
+					return
+				case err == nil:
+					renewed = time.Now()
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// lease is value of lease key
+type lease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// leaseLock acquires lock by writing lease key in store transaction, for stores whose Txn
+// fails if lease key was written concurrently
+func leaseLock(ctx context.Context, s Store, name string, isConflict func(error) bool) (context.Context, UnlockFunc, error) {
+	key := lockPrefix + name
+	owner := lockOwner()
+
+	// writeLease extends lease of owner, false if lease is held by another owner
+	writeLease := func() (bool, error) {
+		acquired := false
+		err := s.Txn(func(txn StoreTxn) error {
+			current := lease{}
+			err := txn.LoadJSON(key, &current)
+			if err != nil && !errors.Is(err, ErrKeyNotFound) {
+				return err
+			}
+			if err == nil && current.Owner != owner && time.Now().Before(current.Expires) {
+				return nil
+			}
+			acquired = true
+			return txn.SaveJSON(key, &lease{Owner: owner, Expires: time.Now().Add(lockTTL)})
+		})
+		if err != nil && isConflict(err) {
+			return false, nil
+		}
+		return acquired && err == nil, err
+	}
+
+	if err := retryLock(ctx, name, writeLease); err != nil {
+		return nil, nil, err
+	}
+
+	holderCtx, cancel := holderContext(ctx)
+	stop := renewLock(name, lockTTL, writeLease, cancel)
+	return holderCtx, func() error {
+		stop()
+		defer cancel(nil)
+		// lease is deleted only if still held by owner
+		err := s.Txn(func(txn StoreTxn) error {
+			current := lease{}
+			if err := txn.LoadJSON(key, &current); err != nil {
+				if errors.Is(err, ErrKeyNotFound) {
+					return nil
+				}
+				return err
+			}
+			if current.Owner != owner {
+				return nil
+			}
+			return txn.Delete(key)
+		})
+		return lostLock(holderCtx, err)
+	}, nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/nixmade/orchestrator/metrics"
+)
+
+var storeOperationDuration = metrics.DefaultRegistry.NewHistogramVec(
+	"orchestrator_store_operation_duration_seconds",
+	"Latency of store operations in seconds",
+	nil,
+	"operation",
+)
+
+// MetricsStore wraps store, recording latency of every store operation
+type MetricsStore struct {
+	Store
+}
+
+// NewMetricsStore wraps store with latency metrics
+func NewMetricsStore(s Store) Store {
+	return &MetricsStore{Store: s}
+}
+
+func observe(operation string, start time.Time) {
+	storeOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *MetricsStore) SaveJSON(key string, value interface{}) error {
+	defer observe("SaveJSON", time.Now())
+	return s.Store.SaveJSON(key, value)
+}
+
+func (s *MetricsStore) SaveJSONBatch(values map[string]any) error {
+	defer observe("SaveJSONBatch", time.Now())
+	return s.Store.SaveJSONBatch(values)
+}
+
+func (s *MetricsStore) Txn(fn func(txn StoreTxn) error) error {
+	defer observe("Txn", time.Now())
+	return s.Store.Txn(fn)
+}
+
+func (s *MetricsStore) Lock(ctx context.Context, name string) (context.Context, UnlockFunc, error) {
+	defer observe("Lock", time.Now())
+	return s.Store.Lock(ctx, name)
+}
+
+func (s *MetricsStore) Delete(key string) error {
+	defer observe("Delete", time.Now())
+	return s.Store.Delete(key)
+}
+
+func (s *MetricsStore) LoadJSON(key string, value interface{}) error {
+	defer observe("LoadJSON", time.Now())
+	return s.Store.LoadJSON(key, value)
+}
+
+func (s *MetricsStore) LoadKeys(prefix string) ([]string, error) {
+	defer observe("LoadKeys", time.Now())
+	return s.Store.LoadKeys(prefix)
+}
+
+func (s *MetricsStore) LoadKeysN(prefix, cursor string, limit int) ([]string, string, error) {
+	defer observe("LoadKeysN", time.Now())
+	return s.Store.LoadKeysN(prefix, cursor, limit)
+}
+
+func (s *MetricsStore) LoadValues(prefix string, iter ValueIterator) error {
+	defer observe("LoadValues", time.Now())
+	return s.Store.LoadValues(prefix, iter)
+}
+
+func (s *MetricsStore) Count(prefix string) (uint64, error) {
+	defer observe("Count", time.Now())
+	return s.Store.Count(prefix)
+}
+
+func (s *MetricsStore) CountJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	defer observe("CountJsonPath", time.Now())
+	return s.Store.CountJsonPath(prefix, jsonPath, iter)
+}
+
+func (s *MetricsStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	defer observe("QueryJsonPath", time.Now())
+	return s.Store.QueryJsonPath(prefix, jsonPath, iter)
+}
+
+func (s *MetricsStore) QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error {
+	defer observe("QueryJsonPaths", time.Now())
+	return s.Store.QueryJsonPaths(prefix, projection, iter)
+}
+
+func (s *MetricsStore) QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error {
+	defer observe("QueryEquals", time.Now())
+	return s.Store.QueryEquals(prefix, jsonPath, value, iter)
+}
+
+func (s *MetricsStore) SortedAscN(prefix string, jsonPath string, limit int64, iter ValueIterator) error {
+	defer observe("SortedAscN", time.Now())
+	return s.Store.SortedAscN(prefix, jsonPath, limit, iter)
+}
+
+func (s *MetricsStore) SortedDescN(prefix string, jsonPath string, limit int64, iter ValueIterator) error {
+	defer observe("SortedDescN", time.Now())
+	return s.Store.SortedDescN(prefix, jsonPath, limit, iter)
+}
+
+func (s *MetricsStore) DeletePrefix(prefix string) error {
+	defer observe("DeletePrefix", time.Now())
+	return s.Store.DeletePrefix(prefix)
+}
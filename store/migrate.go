@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// migrateProgressInterval is number of keys copied between progress reports
+const migrateProgressInterval = 1000
+
+// MigrateProgress is called periodically with number of keys copied so far
+type MigrateProgress func(copied int64)
+
+// MigrateResult describes keys copied by Migrate
+type MigrateResult struct {
+	Keys     int64  `json:"keys"`
+	Checksum string `json:"checksum"`
+}
+
+// ParseDSN splits driver:dsn used to address a store on command line, urls such as postgres://host/db
+// use the scheme as driver and keep the whole url as dsn, badger:/var/lib/orch uses the rest as dsn
+func ParseDSN(spec string) (string, string, error) {
+	driver, dsn, found := strings.Cut(spec, ":")
+	if !found {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownDriver, spec)
+	}
+	driver = strings.ToLower(driver)
+
+	driversLock.RLock()
+	_, ok := drivers[driver]
+	driversLock.RUnlock()
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+	}
+
+	if strings.HasPrefix(dsn, "//") {
+		return driver, spec, nil
+	}
+	return driver, dsn, nil
+}
+
+// Migrate copies all keys from src to dst while reporting progress, then verifies dst has the same
+// keys and values by comparing checksums, dst is expected to be empty
+func Migrate(src, dst Store, progress MigrateProgress) (*MigrateResult, error) {
+	var copied int64
+	sum := newChecksum()
+	err := src.LoadValues("", func(key, value any) error {
+		if err := sum.add(key.(string), value.(string)); err != nil {
+			return err
+		}
+		if err := dst.SaveJSON(key.(string), json.RawMessage(value.(string))); err != nil {
+			return err
+		}
+		copied++
+		if progress != nil && copied%migrateProgressInterval == 0 {
+			progress(copied)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if progress != nil && copied%migrateProgressInterval != 0 {
+		progress(copied)
+	}
+
+	result := &MigrateResult{Keys: copied, Checksum: sum.String()}
+
+	keys, checksum, err := Checksum(dst)
+	if err != nil {
+		return result, err
+	}
+	if keys != result.Keys || checksum != result.Checksum {
+		return result, fmt.Errorf("%w: copied %d keys %s, destination has %d keys %s",
+			ErrChecksumMismatch, result.Keys, result.Checksum, keys, checksum)
+	}
+
+	return result, nil
+}
+
+// Checksum returns number of keys and order independent checksum of all keys and values in store,
+// values are compared as canonical json so checksums match across backends normalizing json
+func Checksum(s Store) (int64, string, error) {
+	var keys int64
+	sum := newChecksum()
+	err := s.LoadValues("", func(key, value any) error {
+		keys++
+		return sum.add(key.(string), value.(string))
+	})
+	return keys, sum.String(), err
+}
+
+// checksum xors sha256 of every key and value, so it does not depend on iteration order
+type checksum [sha256.Size]byte
+
+func newChecksum() *checksum {
+	return &checksum{}
+}
+
+func (c *checksum) add(key, value string) error {
+	canonical, err := canonicalJSON(value)
+	if err != nil {
+		return fmt.Errorf("key %s: %w", key, err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(canonical)
+	for i, b := range h.Sum(nil) {
+		c[i] ^= b
+	}
+	return nil
+}
+
+func (c *checksum) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+// canonicalJSON re-encodes value with sorted object keys and no insignificant whitespace
+func canonicalJSON(value string) ([]byte, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
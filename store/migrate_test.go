@@ -0,0 +1,77 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDSN(t *testing.T) {
+	driver, dsn, err := ParseDSN("badger:/var/lib/orch")
+	require.NoError(t, err)
+	assert.Equal(t, BadgerDriver, driver)
+	assert.Equal(t, "/var/lib/orch", dsn)
+
+	driver, dsn, err = ParseDSN("postgres://user@localhost:5432/orch")
+	require.NoError(t, err)
+	assert.Equal(t, PostgresDriver, driver)
+	assert.Equal(t, "postgres://user@localhost:5432/orch", dsn)
+
+	driver, dsn, err = ParseDSN("badger:")
+	require.NoError(t, err)
+	assert.Equal(t, BadgerDriver, driver)
+	assert.Empty(t, dsn)
+
+	_, _, err = ParseDSN("consul://localhost")
+	require.ErrorIs(t, err, ErrUnknownDriver)
+
+	_, _, err = ParseDSN("/var/lib/orch")
+	require.ErrorIs(t, err, ErrUnknownDriver)
+}
+
+func TestMigrate(t *testing.T) {
+	source, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, source.Close())
+	}()
+
+	for i := 0; i < 2500; i++ {
+		require.NoError(t, source.SaveJSON(fmt.Sprintf("migrate:%d", i), map[string]int{"B": i, "A": i}))
+	}
+
+	destination, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, destination.Close())
+	}()
+
+	var reported []int64
+	result, err := Migrate(source, destination, func(copied int64) {
+		reported = append(reported, copied)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500), result.Keys)
+	assert.Equal(t, []int64{1000, 2000, 2500}, reported)
+
+	keys, checksum, err := Checksum(source)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500), keys)
+	assert.Equal(t, result.Checksum, checksum)
+
+	// whitespace and key order do not change checksum
+	require.NoError(t, destination.SaveJSON("migrate:0", map[string]int{"A": 0, "B": 0}))
+	_, checksum, err = Checksum(destination)
+	require.NoError(t, err)
+	assert.Equal(t, result.Checksum, checksum)
+
+	require.NoError(t, destination.SaveJSON("migrate:0", map[string]int{"A": 1, "B": 0}))
+	_, err = Migrate(source, destination, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, destination.SaveJSON("extra", 1))
+	_, err = Migrate(source, destination, nil)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
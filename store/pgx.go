@@ -3,7 +3,11 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
@@ -19,6 +23,16 @@ type PgxStore struct {
 	pgconn *pgx.Conn
 	schema string
 	table  string
+	// databaseURL opens dedicated connection listening for key events
+	databaseURL string
+	// lockConn holds advisory locks, session locks are released if connection is lost
+	lockConn *pgx.Conn
+	// lockConnID is incremented whenever lockConn is opened, locks taken on an earlier connection are lost
+	lockConnID uint64
+	// held are names locked and id of connection they were locked on, session locks are reentrant
+	// so held names are not locked again
+	held   map[string]uint64
+	lockMu sync.Mutex
 }
 
 // NewPgxStore creates a new postgres store
@@ -28,9 +42,10 @@ func NewPgxStore(databaseURL, schema, table string) (Store, error) {
 		return nil, err
 	}
 	return &PgxStore{
-		pgconn: pgconn,
-		schema: schema,
-		table:  table,
+		pgconn:      pgconn,
+		schema:      schema,
+		table:       table,
+		databaseURL: databaseURL,
 	}, nil
 }
 
@@ -40,16 +55,31 @@ func NewDefaultPgxStore(databaseURL string) (Store, error) {
 		return nil, err
 	}
 	return &PgxStore{
-		pgconn: pgconn,
-		schema: PUBLIC_SCHEMA,
-		table:  TABLE_NAME,
+		pgconn:      pgconn,
+		schema:      PUBLIC_SCHEMA,
+		table:       TABLE_NAME,
+		databaseURL: databaseURL,
 	}, nil
 }
 
-// Save saves to store
+// channel is notified with key events of table
+func (s *PgxStore) channel() string {
+	return s.schema + "." + s.table
+}
+
+// saveQuery upserts key, notifying watchers of key with channel as first argument
+func (s *PgxStore) saveQuery(key, value string) string {
+	return fmt.Sprintf("WITH saved AS (INSERT INTO %s.%s (KEY, VALUE) VALUES ('%s', '%s') ON CONFLICT(KEY) DO UPDATE SET VALUE = '%s' RETURNING KEY) SELECT pg_notify($1, json_build_object('key', KEY)::text) FROM saved;", s.schema, s.table, key, value, value)
+}
+
+// deleteQuery deletes key, notifying watchers of key with channel as first argument
+func (s *PgxStore) deleteQuery(key string) string {
+	return fmt.Sprintf("WITH deleted AS (DELETE FROM %s.%s WHERE KEY = '%s' RETURNING KEY) SELECT pg_notify($1, json_build_object('key', KEY, 'deleted', true)::text) FROM deleted;", s.schema, s.table, key)
+}
+
+// Save saves to store, notifying watchers of key
 func (s *PgxStore) save(key, value string) error {
-	query := fmt.Sprintf("INSERT INTO %s.%s (KEY, VALUE) VALUES ('%s', '%s') ON CONFLICT(KEY) DO UPDATE SET VALUE = '%s';", s.schema, s.table, key, value, value)
-	_, err := s.pgconn.Exec(context.Background(), query)
+	_, err := s.pgconn.Exec(context.Background(), s.saveQuery(key, value), s.channel())
 	return err
 }
 
@@ -64,10 +94,67 @@ func (s *PgxStore) SaveJSON(key string, jsonValue interface{}) error {
 	return s.save(key, string(value))
 }
 
+// SaveJSONBatch saves key json values pipelined in a single round trip and transaction
+func (s *PgxStore) SaveJSONBatch(values map[string]any) error {
+	marshalled, err := marshalBatch(values)
+	if err != nil {
+		return err
+	}
+	if len(marshalled) <= 0 {
+		return nil
+	}
+
+	return pgx.BeginFunc(context.Background(), s.pgconn, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for key, value := range marshalled {
+			batch.Queue(s.saveQuery(key, value), s.channel())
+		}
+		return tx.SendBatch(context.Background(), batch).Close()
+	})
+}
+
+// pgxTxn adapts postgres transaction to StoreTxn, watchers are notified on commit
+type pgxTxn struct {
+	store *PgxStore
+	tx    pgx.Tx
+}
+
+func (t *pgxTxn) SaveJSON(key string, jsonValue interface{}) error {
+	value, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	_, err = t.tx.Exec(context.Background(), t.store.saveQuery(key, string(value)), t.store.channel())
+	return err
+}
+
+func (t *pgxTxn) Delete(key string) error {
+	_, err := t.tx.Exec(context.Background(), t.store.deleteQuery(key), t.store.channel())
+	return err
+}
+
+func (t *pgxTxn) LoadJSON(key string, value interface{}) error {
+	query := fmt.Sprintf("SELECT VALUE FROM %s.%s WHERE KEY = '%s';", t.store.schema, t.store.table, key)
+	jsonValue := ""
+	if err := t.tx.QueryRow(context.Background(), query).Scan(&jsonValue); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrKeyNotFound
+		}
+		return err
+	}
+	return json.Unmarshal([]byte(jsonValue), value)
+}
+
+// Txn runs fn in a postgres transaction, committed if fn returns nil and rolled back otherwise
+func (s *PgxStore) Txn(fn func(txn StoreTxn) error) error {
+	return pgx.BeginFunc(context.Background(), s.pgconn, func(tx pgx.Tx) error {
+		return fn(&pgxTxn{store: s, tx: tx})
+	})
+}
+
 // Delete deletes key from store
 func (s *PgxStore) Delete(key string) error {
-	query := fmt.Sprintf("DELETE FROM %s.%s WHERE KEY = '%s';", s.schema, s.table, key)
-	_, err := s.pgconn.Exec(context.Background(), query)
+	_, err := s.pgconn.Exec(context.Background(), s.deleteQuery(key), s.channel())
 	if err != nil {
 		if err.Error() == "no rows in result set" {
 			return nil
@@ -79,8 +166,8 @@ func (s *PgxStore) Delete(key string) error {
 
 // Delete deletes prefix pattern from store
 func (s *PgxStore) DeletePrefix(prefix string) error {
-	query := fmt.Sprintf("DELETE FROM %s.%s WHERE KEY LIKE '%s%%';", s.schema, s.table, prefix)
-	_, err := s.pgconn.Exec(context.Background(), query)
+	query := fmt.Sprintf("WITH deleted AS (DELETE FROM %s.%s WHERE KEY LIKE '%s%%' RETURNING KEY) SELECT pg_notify($1, json_build_object('key', KEY, 'deleted', true)::text) FROM deleted;", s.schema, s.table, prefix)
+	_, err := s.pgconn.Exec(context.Background(), query, s.channel())
 	if err != nil {
 		if err.Error() == "no rows in result set" {
 			return nil
@@ -132,6 +219,35 @@ func (s *PgxStore) LoadKeys(prefix string) ([]string, error) {
 	return keys, nil
 }
 
+// LoadKeysN loads up to limit keys with prefix after cursor in key order
+func (s *PgxStore) LoadKeysN(prefix, cursor string, limit int) ([]string, string, error) {
+	query := fmt.Sprintf("SELECT KEY FROM %s.%s WHERE KEY LIKE '%s%%' AND KEY > '%s' ORDER BY KEY", s.schema, s.table, prefix, cursor)
+	if limit > 0 {
+		// one more key tells if there is a next page
+		query = fmt.Sprintf("%s LIMIT %d", query, limit+1)
+	}
+	rows, err := s.pgconn.Query(context.Background(), query+";")
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	keys, next := pageKeys(keys, limit)
+	return keys, next, nil
+}
+
 func (s *PgxStore) LoadValues(prefix string, iter ValueIterator) error {
 	query := fmt.Sprintf("SELECT KEY, VALUE FROM %s.%s WHERE KEY LIKE '%s%%';", s.schema, s.table, prefix)
 	rows, err := s.pgconn.Query(context.Background(), query)
@@ -174,6 +290,66 @@ func (s *PgxStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) er
 	return nil
 }
 
+// QueryEquals returns key and json value of values with jsonPath equal to value using jsonpath predicate,
+// which is served by a GIN (VALUE jsonb_path_ops) index on the table
+func (s *PgxStore) QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT KEY, VALUE FROM %s.%s WHERE KEY LIKE '%s%%' AND VALUE @@ $1::jsonpath;", s.schema, s.table, prefix)
+	rows, err := s.pgconn.Query(context.Background(), query, fmt.Sprintf("%s == %s", jsonPath, encoded))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var jsonValue string
+		if err := rows.Scan(&key, &jsonValue); err != nil {
+			return err
+		}
+		if err := iter(key, jsonValue); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *PgxStore) QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error {
+	// example: {"version": "$.state.currentversion.version", "group": "$.group"}
+	names := make([]string, 0, len(projection))
+	for name := range projection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fields []string
+	for _, name := range names {
+		fields = append(fields, fmt.Sprintf("'%s', jsonb_path_query_first(\"value\",'%s')", name, projection[name]))
+	}
+
+	query := fmt.Sprintf("SELECT KEY, jsonb_strip_nulls(jsonb_build_object(%s)) AS PROJECTION FROM %s.%s WHERE KEY LIKE '%s%%';", strings.Join(fields, ", "), s.schema, s.table, prefix)
+	rows, err := s.pgconn.Query(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var value map[string]any
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return err
+		}
+		if err = iter(key, value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (s *PgxStore) SortedAscN(prefix, jsonPath string, limit int64, iter ValueIterator) error {
 	return s.sortedN(prefix, jsonPath, "ASC", limit, iter)
 }
@@ -239,9 +415,174 @@ func (s *PgxStore) Count(prefix string) (uint64, error) {
 
 // Close
 func (s *PgxStore) Close() error {
+	s.lockMu.Lock()
+	if s.lockConn != nil {
+		_ = s.lockConn.Close(context.Background())
+	}
+	s.lockMu.Unlock()
 	return s.pgconn.Close(context.Background())
 }
 
+// Ping checks database connection is alive and held locks were not lost
+func (s *PgxStore) Ping() error {
+	if s.pgconn.IsClosed() {
+		return ErrStoreClosed
+	}
+	if err := s.pgconn.Ping(context.Background()); err != nil {
+		return err
+	}
+
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+	return s.lockLost(context.Background())
+}
+
+// Watch sends changes of keys with prefix notified by any orchestrator sharing the table,
+// listening on a dedicated connection until cancelled
+func (s *PgxStore) Watch(prefix string) (<-chan KeyEvent, CancelFunc) {
+	if s.pgconn.IsClosed() {
+		return closedWatch()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := pgx.Connect(ctx, s.databaseURL)
+	if err != nil {
+		cancel()
+		return closedWatch()
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{s.channel()}.Sanitize()); err != nil {
+		cancel()
+		_ = conn.Close(context.Background())
+		return closedWatch()
+	}
+
+	events := make(chan KeyEvent, watchBufferSize)
+	go func() {
+		defer close(events)
+		defer func() { _ = conn.Close(context.Background()) }()
+
+		query := fmt.Sprintf("SELECT VALUE FROM %s.%s WHERE KEY = $1;", s.schema, s.table)
+		for {
+			notification, err := conn.WaitForNotification(ctx)
+			if err != nil {
+				return
+			}
+
+			var event KeyEvent
+			if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil || !strings.HasPrefix(event.Key, prefix) {
+				continue
+			}
+			if !event.Deleted {
+				// notifications carry key only, values may exceed payload limit
+				if err := conn.QueryRow(ctx, query, event.Key).Scan(&event.Value); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					event.Deleted = errors.Is(err, pgx.ErrNoRows)
+					if !event.Deleted {
+						continue
+					}
+				}
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, CancelFunc(cancel)
+}
+
+// lockLost returns ErrLockLost if any held lock was taken on a lock connection which is gone,
+// postgres released its session locks so holders must stop, caller holds lockMu
+func (s *PgxStore) lockLost(ctx context.Context) error {
+	if len(s.held) <= 0 {
+		return nil
+	}
+	if s.lockConn == nil || s.lockConn.IsClosed() || s.lockConn.Ping(ctx) != nil {
+		return ErrLockLost
+	}
+	for name, connID := range s.held {
+		if connID != s.lockConnID {
+			return fmt.Errorf("%w %s", ErrLockLost, name)
+		}
+	}
+	return nil
+}
+
+// advisoryLock runs advisory lock function of name on lock connection, opened on first use or once
+// previous connection is lost, caller holds lockMu
+func (s *PgxStore) advisoryLock(ctx context.Context, function, name string) (bool, error) {
+	if s.lockConn == nil || s.lockConn.IsClosed() {
+		conn, err := pgx.Connect(ctx, s.databaseURL)
+		if err != nil {
+			return false, err
+		}
+		// locks still held on previous connection stay in held until their holders unlock,
+		// Ping and unlock report them lost
+		s.lockConn = conn
+		s.lockConnID++
+		if s.held == nil {
+			s.held = make(map[string]uint64)
+		}
+	}
+
+	var result bool
+	query := fmt.Sprintf("SELECT %s(hashtextextended($1, 0));", function)
+	err := s.lockConn.QueryRow(ctx, query, s.channel()+":"+name).Scan(&result)
+	return result, err
+}
+
+// Lock takes postgres session advisory lock of name, shared by orchestrators using the same table,
+// lock is lost along with lock connection
+func (s *PgxStore) Lock(ctx context.Context, name string) (context.Context, UnlockFunc, error) {
+	var connID uint64
+	err := retryLock(ctx, name, func() (bool, error) {
+		s.lockMu.Lock()
+		defer s.lockMu.Unlock()
+		if _, ok := s.held[name]; ok {
+			return false, nil
+		}
+		acquired, err := s.advisoryLock(ctx, "pg_try_advisory_lock", name)
+		if acquired {
+			s.held[name] = s.lockConnID
+			connID = s.lockConnID
+		}
+		return acquired, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	holderCtx, cancel := holderContext(ctx)
+	// postgres releases session lock once lock connection is gone, which is checked on renewal
+	stop := renewLock(name, lockTTL, func() (bool, error) {
+		s.lockMu.Lock()
+		defer s.lockMu.Unlock()
+		if s.lockConn == nil || s.lockConn.IsClosed() || connID != s.lockConnID {
+			return false, nil
+		}
+		pingCtx, cancelPing := context.WithTimeout(context.Background(), lockTTL/3)
+		defer cancelPing()
+		return s.lockConn.Ping(pingCtx) == nil, nil
+	}, cancel)
+	return holderCtx, func() error {
+		stop()
+		defer cancel(nil)
+		s.lockMu.Lock()
+		defer s.lockMu.Unlock()
+		delete(s.held, name)
+		if s.lockConn == nil || s.lockConn.IsClosed() || connID != s.lockConnID {
+			// postgres released session lock along with lost connection
+			return fmt.Errorf("%w %s", ErrLockLost, name)
+		}
+		_, err := s.advisoryLock(context.Background(), "pg_advisory_unlock", name)
+		return lostLock(holderCtx, err)
+	}, nil
+}
+
 type PgxStoreTest struct {
 	PgxStore
 }
@@ -265,8 +606,12 @@ func NewPgxStoreWithTable(databaseURL, table string) (Store, error) {
 	if err != nil {
 		return nil, err
 	}
+	_, err = pgconn.Exec(context.Background(), fmt.Sprintf("CREATE INDEX %s_value_idx ON %s USING GIN (VALUE jsonb_path_ops);", table, table))
+	if err != nil {
+		return nil, err
+	}
 	return &PgxStoreTest{
-		PgxStore: PgxStore{pgconn: pgconn, schema: PUBLIC_SCHEMA, table: table},
+		PgxStore: PgxStore{pgconn: pgconn, schema: PUBLIC_SCHEMA, table: table, databaseURL: databaseURL},
 	}, nil
 }
 
@@ -276,5 +621,5 @@ func (s *PgxStoreTest) Close() error {
 	if err != nil {
 		return err
 	}
-	return s.pgconn.Close(context.Background())
+	return s.PgxStore.Close()
 }
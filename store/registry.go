@@ -0,0 +1,114 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// BadgerDriver opens badger store, dsn is db directory, empty dsn opens in memory store
+	BadgerDriver = "badger"
+	// PostgresDriver opens postgres store, dsn is database url
+	PostgresDriver = "postgres"
+	// RedisDriver opens redis store, dsn is redis url, Params["keyprefix"] prefixes stored keys
+	RedisDriver = "redis"
+	// EtcdDriver opens etcd store, dsn is comma separated endpoints, Params["keyprefix"] prefixes stored keys
+	EtcdDriver = "etcd"
+)
+
+// Options passed to driver when opening store
+type Options struct {
+	// EncryptionKey encrypts data at rest, if supported by driver
+	EncryptionKey string
+	// KeyProvider supplies data key encrypting data at rest instead of EncryptionKey, if supported by driver
+	KeyProvider KeyProvider
+	// Schema and Table storing key values, if supported by driver
+	Schema string
+	Table  string
+	// Params are driver specific options
+	Params map[string]string
+}
+
+// Driver opens store for dsn
+type Driver func(dsn string, opts Options) (Store, error)
+
+var (
+	driversLock sync.RWMutex
+	drivers     = make(map[string]Driver)
+)
+
+// Register makes store driver available by name, typically called from init of the package implementing Store,
+// panics if driver is nil or name is already registered
+func Register(name string, driver Driver) {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+
+	name = strings.ToLower(name)
+	if driver == nil {
+		panic("store: Register driver is nil")
+	}
+	if _, ok := drivers[name]; ok {
+		panic("store: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers returns sorted list of registered driver names
+func Drivers() []string {
+	driversLock.RLock()
+	defer driversLock.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens store using registered driver
+func Open(driver, dsn string, opts Options) (Store, error) {
+	driversLock.RLock()
+	open, ok := drivers[strings.ToLower(driver)]
+	driversLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+	}
+
+	return open(dsn, opts)
+}
+
+func init() {
+	Register(BadgerDriver, func(dsn string, opts Options) (Store, error) {
+		if opts.KeyProvider != nil {
+			if opts.EncryptionKey != "" {
+				return nil, ErrEncryptionKeyConflict
+			}
+			key, err := DataKey(opts.KeyProvider, dsn)
+			if err != nil {
+				return nil, err
+			}
+			return NewBadgerDBStore(dsn, string(key))
+		}
+		return NewBadgerDBStore(dsn, opts.EncryptionKey)
+	})
+	Register(PostgresDriver, func(dsn string, opts Options) (Store, error) {
+		schema, table := opts.Schema, opts.Table
+		if schema == "" {
+			schema = PUBLIC_SCHEMA
+		}
+		if table == "" {
+			table = TABLE_NAME
+		}
+		return NewPgxStore(dsn, schema, table)
+	})
+	Register(RedisDriver, func(dsn string, opts Options) (Store, error) {
+		return NewRedisStore(dsn, opts.Params["keyprefix"])
+	})
+	Register(EtcdDriver, func(dsn string, opts Options) (Store, error) {
+		return NewEtcdStore(strings.Split(dsn, ","), opts.Params["keyprefix"])
+	})
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenBadgerDriver(t *testing.T) {
+	store, err := Open("Badger", "", Options{})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	require.NoError(t, store.SaveJSON("key", "value"))
+	var value string
+	require.NoError(t, store.LoadJSON("key", &value))
+	assert.Equal(t, "value", value)
+}
+
+func TestRegisterDriver(t *testing.T) {
+	var opened Options
+	Register("memory-test", func(dsn string, opts Options) (Store, error) {
+		opened = opts
+		return NewBadgerDBStore("", "")
+	})
+
+	assert.Contains(t, Drivers(), "memory-test")
+	assert.Contains(t, Drivers(), BadgerDriver)
+	assert.Contains(t, Drivers(), PostgresDriver)
+	assert.Contains(t, Drivers(), EtcdDriver)
+
+	store, err := Open("memory-test", "mem://", Options{Params: map[string]string{"size": "1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "1", opened.Params["size"])
+	require.NoError(t, store.Close())
+
+	assert.Panics(t, func() {
+		Register("memory-test", func(dsn string, opts Options) (Store, error) { return nil, nil })
+	})
+
+	_, err = Open("consul", "", Options{})
+	require.ErrorIs(t, err, ErrUnknownDriver)
+}
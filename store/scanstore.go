@@ -0,0 +1,159 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ScanInfo describes an in progress store scan
+type ScanInfo struct {
+	ID        uint64    `json:"id"`
+	Operation string    `json:"operation,omitempty"`
+	Prefix    string    `json:"prefix,omitempty"`
+	Started   time.Time `json:"started,omitempty"`
+	Items     int64     `json:"items"`
+	Cancelled bool      `json:"cancelled,omitempty"`
+}
+
+type scan struct {
+	info      ScanInfo
+	items     atomic.Int64
+	cancelled atomic.Bool
+}
+
+// ScanTracker tracks in progress store scans, scans could be cancelled between items
+type ScanTracker struct {
+	lock   sync.Mutex
+	nextID uint64
+	scans  map[uint64]*scan
+}
+
+// DefaultScanTracker tracks scans of stores created by orchestrator
+var DefaultScanTracker = NewScanTracker()
+
+// NewScanTracker creates an empty scan tracker
+func NewScanTracker() *ScanTracker {
+	return &ScanTracker{scans: make(map[uint64]*scan)}
+}
+
+func (t *ScanTracker) start(operation, prefix string) *scan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.nextID++
+	s := &scan{info: ScanInfo{ID: t.nextID, Operation: operation, Prefix: prefix, Started: time.Now().UTC()}}
+	t.scans[s.info.ID] = s
+	return s
+}
+
+func (t *ScanTracker) finish(s *scan) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.scans, s.info.ID)
+}
+
+// Scans returns in progress scans running longer than minAge, oldest first
+func (t *ScanTracker) Scans(minAge time.Duration) []ScanInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	scans := []ScanInfo{}
+	for _, s := range t.scans {
+		if time.Since(s.info.Started) < minAge {
+			continue
+		}
+		info := s.info
+		info.Items = s.items.Load()
+		info.Cancelled = s.cancelled.Load()
+		scans = append(scans, info)
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].ID < scans[j].ID
+	})
+	return scans
+}
+
+// Cancel cancels scan, scan stops with ErrScanCancelled before its next item
+func (t *ScanTracker) Cancel(id uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s, ok := t.scans[id]
+	if !ok {
+		return ErrScanNotFound
+	}
+	s.cancelled.Store(true)
+	return nil
+}
+
+// wrap returns iterator counting items and stopping once scan is cancelled
+func (s *scan) wrap(iter ValueIterator) ValueIterator {
+	return func(key any, value any) error {
+		if s.cancelled.Load() {
+			return ErrScanCancelled
+		}
+		s.items.Add(1)
+		return iter(key, value)
+	}
+}
+
+// ScanTrackingStore wraps store, tracking iterator based scans so they could be listed and cancelled
+type ScanTrackingStore struct {
+	Store
+	tracker *ScanTracker
+}
+
+// NewScanTrackingStore wraps store with scan tracking
+func NewScanTrackingStore(s Store, tracker *ScanTracker) Store {
+	return &ScanTrackingStore{Store: s, tracker: tracker}
+}
+
+func (s *ScanTrackingStore) track(operation, prefix string, iter ValueIterator, run func(ValueIterator) error) error {
+	sc := s.tracker.start(operation, prefix)
+	defer s.tracker.finish(sc)
+	return run(sc.wrap(iter))
+}
+
+func (s *ScanTrackingStore) LoadValues(prefix string, iter ValueIterator) error {
+	return s.track("LoadValues", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.LoadValues(prefix, iter)
+	})
+}
+
+func (s *ScanTrackingStore) CountJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	return s.track("CountJsonPath", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.CountJsonPath(prefix, jsonPath, iter)
+	})
+}
+
+func (s *ScanTrackingStore) QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error {
+	return s.track("QueryJsonPath", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.QueryJsonPath(prefix, jsonPath, iter)
+	})
+}
+
+func (s *ScanTrackingStore) QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error {
+	return s.track("QueryJsonPaths", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.QueryJsonPaths(prefix, projection, iter)
+	})
+}
+
+func (s *ScanTrackingStore) QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error {
+	return s.track("QueryEquals", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.QueryEquals(prefix, jsonPath, value, iter)
+	})
+}
+
+func (s *ScanTrackingStore) SortedAscN(prefix string, jsonPath string, limit int64, iter ValueIterator) error {
+	return s.track("SortedAscN", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.SortedAscN(prefix, jsonPath, limit, iter)
+	})
+}
+
+func (s *ScanTrackingStore) SortedDescN(prefix string, jsonPath string, limit int64, iter ValueIterator) error {
+	return s.track("SortedDescN", prefix, iter, func(iter ValueIterator) error {
+		return s.Store.SortedDescN(prefix, jsonPath, limit, iter)
+	})
+}
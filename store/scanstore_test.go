@@ -0,0 +1,46 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanTrackingStore(t *testing.T) {
+	badgerStore, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, badgerStore.Close())
+	}()
+
+	tracker := NewScanTracker()
+	store := NewScanTrackingStore(badgerStore, tracker)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.SaveJSON(fmt.Sprintf("scan:%d", i), i))
+	}
+
+	items := 0
+	err = store.LoadValues("scan:", func(key any, value any) error {
+		items++
+		scans := tracker.Scans(0)
+		require.Len(t, scans, 1)
+		assert.Equal(t, "LoadValues", scans[0].Operation)
+		assert.Equal(t, "scan:", scans[0].Prefix)
+		assert.Equal(t, int64(items), scans[0].Items)
+
+		if items == 3 {
+			require.NoError(t, tracker.Cancel(scans[0].ID))
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrScanCancelled)
+	assert.Equal(t, 3, items)
+
+	assert.Empty(t, tracker.Scans(0))
+	assert.Empty(t, tracker.Scans(time.Hour))
+	assert.ErrorIs(t, tracker.Cancel(1), ErrScanNotFound)
+}
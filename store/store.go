@@ -1,19 +1,38 @@
 package store
 
+import "context"
+
 type ValueIterator func(any, any) error
 
 // Store provides a way for defining multiple stores
 type Store interface {
-	SaveJSON(key string, value interface{}) error                                      // Save key json value to store, returns error on failure
-	Delete(key string) error                                                           // Delete key from store, returns error on failure
-	LoadJSON(key string, value interface{}) error                                      // Load key from store, unmarshals json value, returns error on failure
-	LoadKeys(prefix string) ([]string, error)                                          // Load all keys from store, returns error on failure
-	LoadValues(prefix string, iter ValueIterator) error                                // Loads all keys and values from store, return error on failure
-	Count(prefix string) (uint64, error)                                               // returns count of specified prefix, or error on failure
-	CountJsonPath(prefix, jsonPath string, iter ValueIterator) error                   // returns grouped count of jsonpath, returns error on failure
-	QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error                   // returns key and value of jsonpath, returns error on failure
-	SortedAscN(prefix string, jsonPath string, limit int64, iter ValueIterator) error  // returns N key values, sorted ascending order by jsonpath, returns error on failure
-	SortedDescN(prefix string, jsonPath string, limit int64, iter ValueIterator) error // returns N key values, sorted descending order by jsonpath, returns error on failure
-	DeletePrefix(prefix string) error                                                  // Delete prefix pattern from store, returns error on failure
+	SaveJSON(key string, value interface{}) error                                         // Save key json value to store, returns error on failure
+	SaveJSONBatch(values map[string]any) error                                            // Save key json values to store in as few round trips as possible, returns error on failure
+	Txn(fn func(txn StoreTxn) error) error                                                // Applies writes of fn atomically if fn returns nil, returns error of fn or on failure
+	Delete(key string) error                                                              // Delete key from store, returns error on failure
+	LoadJSON(key string, value interface{}) error                                         // Load key from store, unmarshals json value, returns error on failure
+	LoadKeys(prefix string) ([]string, error)                                             // Load all keys from store, returns error on failure
+	LoadKeysN(prefix, cursor string, limit int) ([]string, string, error)                 // Load up to limit keys after cursor in key order and next cursor, empty on last page, returns error on failure
+	LoadValues(prefix string, iter ValueIterator) error                                   // Loads all keys and values from store, return error on failure
+	Count(prefix string) (uint64, error)                                                  // returns count of specified prefix, or error on failure
+	CountJsonPath(prefix, jsonPath string, iter ValueIterator) error                      // returns grouped count of jsonpath, returns error on failure
+	QueryJsonPath(prefix, jsonPath string, iter ValueIterator) error                      // returns key and value of jsonpath, returns error on failure
+	QueryJsonPaths(prefix string, projection map[string]string, iter ValueIterator) error // returns key and map of projected name to value of jsonpath in one pass, returns error on failure
+	QueryEquals(prefix, jsonPath string, value any, iter ValueIterator) error             // returns key and json value of values where jsonpath equals value, returns error on failure
+	SortedAscN(prefix string, jsonPath string, limit int64, iter ValueIterator) error     // returns N key values, sorted ascending order by jsonpath, returns error on failure
+	SortedDescN(prefix string, jsonPath string, limit int64, iter ValueIterator) error    // returns N key values, sorted descending order by jsonpath, returns error on failure
+	DeletePrefix(prefix string) error                                                     // Delete prefix pattern from store, returns error on failure
+	Ping() error                                                                          // Checks store is reachable, returns error on failure
+	Watch(prefix string) (<-chan KeyEvent, CancelFunc)                                    // Sends changes of keys with prefix, including other replicas for shared stores, until cancelled
+	Lock(ctx context.Context, name string) (context.Context, UnlockFunc, error)           // Acquires named lock held across replicas sharing store, blocks until acquired or ctx is done, returned context is cancelled with ErrLockLost cause if lock is lost
 	Close() error
 }
+
+// pageKeys trims keys loaded with one extra key to limit, returning next cursor if there were more keys
+func pageKeys(keys []string, limit int) ([]string, string) {
+	if limit <= 0 || len(keys) <= limit {
+		return keys, ""
+	}
+	keys = keys[:limit]
+	return keys, keys[limit-1]
+}
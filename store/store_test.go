@@ -1,14 +1,17 @@
 package store
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -82,6 +85,14 @@ func testStore(t *testing.T, store Store) error {
 	require.NoError(t, store.QueryJsonPath("jsonpathtest", "$.locked", qItr))
 	require.True(t, reflect.DeepEqual(call, map[string]interface{}{"jsonpathtest1": false, "jsonpathtest2": false, "jsonpathtest3": true}))
 
+	call = make(map[string]interface{})
+	require.NoError(t, store.QueryJsonPaths("jsonpathtest", map[string]string{"state": "$.state", "locked": "$.locked", "missing": "$.missing"}, qItr))
+	require.Equal(t, map[string]interface{}{
+		"jsonpathtest1": map[string]any{"state": "Success", "locked": false},
+		"jsonpathtest2": map[string]any{"state": "Failed", "locked": false},
+		"jsonpathtest3": map[string]any{"state": "Unknown", "locked": true},
+	}, call)
+
 	call = make(map[string]interface{})
 	require.NoError(t, store.CountJsonPath("jsonpathtest", "$.state", qItr))
 	require.True(t, reflect.DeepEqual(call, map[string]interface{}{"Failed": int64(1), "Success": int64(1), "Unknown": int64(1)}))
@@ -116,9 +127,192 @@ func testStore(t *testing.T, store Store) error {
 	require.NoError(t, err)
 	require.Empty(t, keys)
 
+	testStoreBatch(t, store)
+	testStorePages(t, store)
+	testStoreQueryEquals(t, store)
+	testStoreLock(t, store)
+
 	return nil
 }
 
+func testStoreLock(t *testing.T, store Store) {
+	holderCtx, unlock, err := store.Lock(context.Background(), "TestLock")
+	require.NoError(t, err)
+	require.NoError(t, holderCtx.Err())
+
+	// held lock is not acquired again
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err = store.Lock(ctx, "TestLock")
+	require.ErrorIs(t, err, ErrLockTimeout)
+
+	_, other, err := store.Lock(context.Background(), "OtherLock")
+	require.NoError(t, err)
+	require.NoError(t, other())
+
+	// waiting lock is acquired once released
+	acquired := make(chan UnlockFunc)
+	go func() {
+		_, next, err := store.Lock(context.Background(), "TestLock")
+		assert.NoError(t, err)
+		acquired <- next
+	}()
+	require.NoError(t, unlock())
+	require.ErrorIs(t, holderCtx.Err(), context.Canceled)
+	require.NotErrorIs(t, context.Cause(holderCtx), ErrLockLost)
+	next := <-acquired
+	require.NotNil(t, next)
+	require.NoError(t, next())
+}
+
+func TestRenewLock(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+
+	// lock held by another owner is lost
+	ctx, cancel := holderContext(context.Background())
+	stop := renewLock("TestRenewLock", ttl, func() (bool, error) { return false, nil }, cancel)
+	<-ctx.Done()
+	stop()
+	require.ErrorIs(t, context.Cause(ctx), ErrLockLost)
+	require.ErrorIs(t, lostLock(ctx, nil), ErrLockLost)
+
+	// lock not renewed for ttl is lost
+	ctx, cancel = holderContext(context.Background())
+	stop = renewLock("TestRenewLock", ttl, func() (bool, error) { return false, ErrStoreClosed }, cancel)
+	<-ctx.Done()
+	stop()
+	require.ErrorIs(t, context.Cause(ctx), ErrLockLost)
+
+	// renewed lock is held
+	ctx, cancel = holderContext(context.Background())
+	stop = renewLock("TestRenewLock", ttl, func() (bool, error) { return true, nil }, cancel)
+	time.Sleep(3 * ttl)
+	stop()
+	require.NoError(t, ctx.Err())
+	cancel(nil)
+	require.NoError(t, lostLock(ctx, nil))
+}
+
+func testStoreQueryEquals(t *testing.T, store Store) {
+	require.NoError(t, store.SaveJSON("EqualsKey1", map[string]any{"state": map[string]any{"version": "v1", "error": true, "count": 1}}))
+	require.NoError(t, store.SaveJSON("EqualsKey2", map[string]any{"state": map[string]any{"version": "v2", "count": 2}}))
+	require.NoError(t, store.SaveJSON("EqualsKey3", map[string]any{"state": map[string]any{"version": "v1"}, "other": "v2"}))
+
+	query := func(jsonPath string, value any) []string {
+		var keys []string
+		err := store.QueryEquals("EqualsKey", jsonPath, value, func(key, value any) error {
+			var decoded map[string]any
+			require.NoError(t, json.Unmarshal([]byte(value.(string)), &decoded))
+			keys = append(keys, key.(string))
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Strings(keys)
+		return keys
+	}
+
+	require.Equal(t, []string{"EqualsKey1", "EqualsKey3"}, query("$.state.version", "v1"))
+	require.Equal(t, []string{"EqualsKey2"}, query("$.state.version", "v2"))
+	require.Equal(t, []string{"EqualsKey1"}, query("$.state.error", true))
+	require.Equal(t, []string{"EqualsKey2"}, query("$.state.count", 2))
+	require.Empty(t, query("$.state.version", "v3"))
+
+	require.NoError(t, store.DeletePrefix("EqualsKey"))
+}
+
+func testStorePages(t *testing.T, store Store) {
+	for i := 0; i < 7; i++ {
+		require.NoError(t, store.SaveJSON(fmt.Sprintf("PagedKey%d", i), i))
+	}
+	require.NoError(t, store.SaveJSON("PagedOther", 0))
+
+	var keys []string
+	cursor := ""
+	for {
+		page, next, err := store.LoadKeysN("PagedKey", cursor, 3)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 3)
+		keys = append(keys, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	require.Equal(t, []string{"PagedKey0", "PagedKey1", "PagedKey2", "PagedKey3", "PagedKey4", "PagedKey5", "PagedKey6"}, keys)
+
+	// exact multiple of limit ends with empty cursor on last full page
+	page, next, err := store.LoadKeysN("PagedKey", "PagedKey3", 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"PagedKey4", "PagedKey5", "PagedKey6"}, page)
+	require.Empty(t, next)
+
+	page, next, err = store.LoadKeysN("PagedKey", "", 0)
+	require.NoError(t, err)
+	require.Len(t, page, 7)
+	require.Empty(t, next)
+
+	require.NoError(t, store.DeletePrefix("Paged"))
+}
+
+func testStoreBatch(t *testing.T, store Store) {
+	batch := make(map[string]any)
+	for i := 0; i < 300; i++ {
+		batch[fmt.Sprintf("BatchKey%03d", i)] = map[string]int{"index": i}
+	}
+	require.NoError(t, store.SaveJSONBatch(batch))
+	require.NoError(t, store.SaveJSONBatch(nil))
+
+	count, err := store.Count("BatchKey")
+	require.NoError(t, err)
+	require.Equal(t, uint64(300), count)
+
+	var batchVal map[string]int
+	require.NoError(t, store.LoadJSON("BatchKey123", &batchVal))
+	require.Equal(t, map[string]int{"index": 123}, batchVal)
+
+	// writes are visible within transaction and applied once it returns nil
+	err = store.Txn(func(txn StoreTxn) error {
+		if err := txn.SaveJSON("TxnKey", "saved"); err != nil {
+			return err
+		}
+		var saved string
+		if err := txn.LoadJSON("TxnKey", &saved); err != nil {
+			return err
+		}
+		require.Equal(t, "saved", saved)
+		if err := txn.Delete("BatchKey000"); err != nil {
+			return err
+		}
+		require.ErrorIs(t, txn.LoadJSON("BatchKey000", &batchVal), ErrKeyNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var saved string
+	require.NoError(t, store.LoadJSON("TxnKey", &saved))
+	require.Equal(t, "saved", saved)
+	require.ErrorIs(t, store.LoadJSON("BatchKey000", &batchVal), ErrKeyNotFound)
+
+	// writes are discarded when transaction fails
+	errAbort := fmt.Errorf("abort")
+	err = store.Txn(func(txn StoreTxn) error {
+		if err := txn.SaveJSON("TxnKey", "discarded"); err != nil {
+			return err
+		}
+		if err := txn.Delete("BatchKey001"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+	require.NoError(t, store.LoadJSON("TxnKey", &saved))
+	require.Equal(t, "saved", saved)
+	require.NoError(t, store.LoadJSON("BatchKey001", &batchVal))
+
+	require.NoError(t, store.DeletePrefix("BatchKey"))
+	require.NoError(t, store.Delete("TxnKey"))
+}
+
 func TestInMemoryStore(t *testing.T) {
 	store, err := NewBadgerDBStore("", "")
 	if err != nil {
@@ -183,3 +377,111 @@ func TestPgxStore(t *testing.T) {
 		return
 	}
 }
+
+func TestStorePing(t *testing.T) {
+	store, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+
+	assert.NoError(t, store.Ping())
+	assert.NoError(t, NewMetricsStore(store).Ping())
+
+	require.NoError(t, store.Close())
+	assert.ErrorIs(t, store.Ping(), ErrStoreClosed)
+}
+
+func TestBadgerStoreWatch(t *testing.T) {
+	store, err := NewBadgerDBStore("", "")
+	require.NoError(t, err)
+
+	events, cancel := store.Watch("Watched")
+	defer cancel()
+
+	// subscription is registered in background, write until first event is received
+	var event KeyEvent
+	require.Eventually(t, func() bool {
+		assert.NoError(t, store.SaveJSON("Unwatched", 1))
+		assert.NoError(t, store.SaveJSON("WatchedKey", 1))
+		select {
+		case event = <-events:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, KeyEvent{Key: "WatchedKey", Value: "1"}, event)
+
+	require.NoError(t, store.Delete("WatchedKey"))
+	for event = range events {
+		if event.Deleted {
+			break
+		}
+	}
+	assert.Equal(t, KeyEvent{Key: "WatchedKey", Deleted: true}, event)
+
+	// watch ends once store is closed
+	require.NoError(t, store.Close())
+	for range events {
+		// drained until watch is stopped
+	}
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	// unrelated keys sharing redis database are not visible
+	server.Set("Dummy", "unrelated")
+
+	store, err := NewRedisStore("redis://"+server.Addr(), "orchestrator:")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Ping())
+	require.NoError(t, testStore(t, store))
+	assert.True(t, server.Exists("orchestrator:Dummy"))
+
+	// glob characters in prefix are matched literally
+	require.NoError(t, store.SaveJSON("glob*key", 1))
+	require.NoError(t, store.SaveJSON("globbedkey", 1))
+	keys, err := store.LoadKeys("glob*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"glob*key"}, keys)
+
+	events, cancel := store.Watch("Watched")
+	require.NoError(t, store.SaveJSON("Unwatched", 1))
+	require.NoError(t, store.SaveJSON("WatchedKey", 1))
+	require.NoError(t, store.DeletePrefix("Watched"))
+	assert.Equal(t, KeyEvent{Key: "WatchedKey", Value: "1"}, <-events)
+	assert.Equal(t, KeyEvent{Key: "WatchedKey", Deleted: true}, <-events)
+	cancel()
+	for range events {
+		// drained until watch is stopped
+	}
+
+	require.NoError(t, store.Close())
+	require.ErrorIs(t, store.Ping(), ErrStoreClosed)
+}
+
+func TestEtcdStore(t *testing.T) {
+	if os.Getenv("ETCD_ENDPOINTS") == "" {
+		// skip testing if etcd endpoints are not defined
+		return
+	}
+
+	store, err := Open(EtcdDriver, os.Getenv("ETCD_ENDPOINTS"), Options{Params: map[string]string{"keyprefix": "TestEtcdStore:"}})
+	require.NoError(t, err)
+	require.NoError(t, store.DeletePrefix(""))
+
+	events, cancel := store.Watch("Watched")
+	defer cancel()
+
+	require.NoError(t, testStore(t, store))
+
+	require.NoError(t, store.SaveJSON("WatchedKey", 1))
+	require.NoError(t, store.Delete("WatchedKey"))
+	assert.Equal(t, KeyEvent{Key: "WatchedKey", Value: "1"}, <-events)
+	assert.Equal(t, KeyEvent{Key: "WatchedKey", Deleted: true}, <-events)
+
+	require.NoError(t, store.DeletePrefix(""))
+	require.NoError(t, store.Close())
+	require.ErrorIs(t, store.Ping(), ErrStoreClosed)
+}
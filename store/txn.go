@@ -0,0 +1,73 @@
+package store
+
+import "encoding/json"
+
+// StoreTxn reads and writes keys within Store.Txn, writes are applied together when the
+// transaction function returns nil and discarded otherwise
+type StoreTxn interface {
+	SaveJSON(key string, value interface{}) error // Save key json value within transaction, returns error on failure
+	Delete(key string) error                      // Delete key within transaction, returns error on failure
+	LoadJSON(key string, value interface{}) error // Load key seeing writes made earlier in transaction, returns error on failure
+}
+
+// txnOp is a write buffered by bufferedTxn, value is nil for deletes
+type txnOp struct {
+	key   string
+	value *string
+}
+
+// bufferedTxn buffers writes in order for stores applying them in a single atomic request,
+// reads of keys not written in transaction fall through to load
+type bufferedTxn struct {
+	load   func(key string) (string, error)
+	ops    []txnOp
+	latest map[string]*string
+}
+
+func newBufferedTxn(load func(key string) (string, error)) *bufferedTxn {
+	return &bufferedTxn{load: load, latest: make(map[string]*string)}
+}
+
+func (t *bufferedTxn) SaveJSON(key string, jsonValue interface{}) error {
+	value, err := json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	saved := string(value)
+	t.ops = append(t.ops, txnOp{key: key, value: &saved})
+	t.latest[key] = &saved
+	return nil
+}
+
+func (t *bufferedTxn) Delete(key string) error {
+	t.ops = append(t.ops, txnOp{key: key})
+	t.latest[key] = nil
+	return nil
+}
+
+func (t *bufferedTxn) LoadJSON(key string, value interface{}) error {
+	if latest, ok := t.latest[key]; ok {
+		if latest == nil {
+			return ErrKeyNotFound
+		}
+		return json.Unmarshal([]byte(*latest), value)
+	}
+	jsonValue, err := t.load(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(jsonValue), value)
+}
+
+// marshalBatch marshals every value of batch, so a bad value fails the batch before anything is written
+func marshalBatch(values map[string]any) (map[string]string, error) {
+	marshalled := make(map[string]string, len(values))
+	for key, jsonValue := range values {
+		value, err := json.Marshal(jsonValue)
+		if err != nil {
+			return nil, err
+		}
+		marshalled[key] = string(value)
+	}
+	return marshalled, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultKeyProvider wraps data keys with a Vault transit key
+type VaultKeyProvider struct {
+	address string
+	token   string
+	mount   string
+	keyName string
+	client  *http.Client
+}
+
+// NewVaultKeyProvider creates key provider using transit key keyName mounted at mount (default transit),
+// address is Vault url such as https://vault:8200
+func NewVaultKeyProvider(address, token, mount, keyName string) *VaultKeyProvider {
+	if mount == "" {
+		mount = "transit"
+	}
+	return &VaultKeyProvider{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		mount:   strings.Trim(mount, "/"),
+		keyName: keyName,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type vaultResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (p *VaultKeyProvider) post(ctx context.Context, operation string, in any) (*vaultResponse, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", p.address, p.mount, operation, p.keyName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("vault %s returned %d: %w", operation, resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault %s returned %d: %s", operation, resp.StatusCode, strings.Join(out.Errors, ", "))
+	}
+	return &out, nil
+}
+
+// GenerateDataKey generates 256 bit data key wrapped by transit key
+func (p *VaultKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := p.post(ctx, "datakey/plaintext", map[string]any{"bits": 256})
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(out.Data.Plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, []byte(out.Data.Ciphertext), nil
+}
+
+// Decrypt unwraps data key with transit key
+func (p *VaultKeyProvider) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := p.post(ctx, "decrypt", map[string]any{"ciphertext": string(wrapped)})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Data.Plaintext)
+}
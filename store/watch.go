@@ -0,0 +1,21 @@
+package store
+
+// watchBufferSize is number of key events buffered before watch blocks on slow consumer
+const watchBufferSize = 256
+
+// KeyEvent is a key change observed by Watch, Value is the new json value unless key was deleted
+type KeyEvent struct {
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// CancelFunc stops watch and closes its event channel
+type CancelFunc func()
+
+// closedWatch is returned when watch could not be started, e.g. store is closed
+func closedWatch() (<-chan KeyEvent, CancelFunc) {
+	events := make(chan KeyEvent)
+	close(events)
+	return events, func() {}
+}
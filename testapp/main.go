@@ -1,55 +1,51 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
-	"github.com/nixmade/orchestrator/httpclient"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/nixmade/orchestrator/client"
 	"github.com/rs/zerolog"
 
 	"github.com/nixmade/orchestrator/core"
+	"github.com/nixmade/orchestrator/engine"
 )
 
 type testApp struct {
-	endpoint    string
+	ctx         context.Context
 	bearerToken string
 	namespace   string
 	entity      string
 	logger      zerolog.Logger
-	*httpclient.OrchestratorAPI
+	*client.Client
 }
 
-func (t *testApp) getClientState(group string) ([]*core.ClientState, error) {
-	url := t.Status(t.namespace, t.entity)
-
+func (t *testApp) getClientState(group string) ([]*engine.ClientState, error) {
 	if group != "" {
-		url = t.GroupStatus(t.namespace, t.entity, group)
-	}
-
-	var clientTargets []*core.ClientState
-	err := httpclient.GetJSON(url, t.bearerToken, &clientTargets)
-	if err != nil {
-		return nil, err
+		return t.GroupStatus(t.ctx, t.namespace, t.entity, group)
 	}
-
-	return clientTargets, nil
+	return t.Status(t.ctx, t.namespace, t.entity)
 }
 
 func (t *testApp) setTargetVersion(targetVersion string) error {
-	return httpclient.PostJSON(t.TargetVersion(t.namespace, t.entity), t.bearerToken, &core.EntityTargetVersion{Version: targetVersion}, nil)
+	return t.SetTargetVersion(t.ctx, t.namespace, t.entity, &engine.EntityTargetVersion{Version: targetVersion})
 }
 
-func (t *testApp) setRolloutOptions(options *core.RolloutOptions) error {
-	return httpclient.PostJSON(t.RolloutOptions(t.namespace, t.entity), t.bearerToken, options, nil)
+func (t *testApp) setRolloutOptions(options *engine.RolloutOptions) error {
+	return t.SetRolloutOptions(t.ctx, t.namespace, t.entity, options)
 }
 
-func (t *testApp) postClientTargets(clientTargets []*core.ClientState) error {
-	return httpclient.PostJSON(t.Status(t.namespace, t.entity), t.bearerToken, clientTargets, nil)
+func (t *testApp) postClientTargets(clientTargets []*engine.ClientState) error {
+	return t.ReportStatus(t.ctx, t.namespace, t.entity, clientTargets)
 }
 
-func (t *testApp) rollout(version, group string, clientTargets []*core.ClientState) ([]*core.ClientState, error) {
+func (t *testApp) rollout(version, group string, clientTargets []*engine.ClientState) ([]*engine.ClientState, error) {
 	if err := t.setTargetVersion(version); err != nil {
 		return nil, err
 	}
@@ -62,6 +58,81 @@ func (t *testApp) rollout(version, group string, clientTargets []*core.ClientSta
 	return t.getClientState(group)
 }
 
+// simulateTargets marks targets on version as failing when simulating errors
+func simulateTargets(clientTargets []*engine.ClientState, version string, isError bool) {
+	for _, clientTarget := range clientTargets {
+		clientTarget.Message = "running successfully"
+		clientTarget.IsError = false
+		if isError && clientTarget.Version == version {
+			clientTarget.Message = "simulating error"
+			clientTarget.IsError = isError
+		}
+	}
+}
+
+// runAgent keeps a websocket open, reports targets periodically and applies pushed assignments immediately
+func (t *testApp) runAgent(version string, isError bool, clientTargets []*engine.ClientState) error {
+	ctx := t.ctx
+	options := &websocket.DialOptions{}
+	if t.bearerToken != "" {
+		options.HTTPHeader = http.Header{"Authorization": []string{t.bearerToken}}
+	}
+	conn, _, err := websocket.Dial(ctx, t.AgentSocketURL(t.namespace, t.entity), options)
+	if err != nil {
+		return err
+	}
+	defer conn.CloseNow()
+
+	assignments := make(chan *core.AgentAssignment)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			assignment := &core.AgentAssignment{}
+			if err := wsjson.Read(ctx, conn, assignment); err != nil {
+				errs <- err
+				return
+			}
+			assignments <- assignment
+		}
+	}()
+
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	report := true
+	for {
+		if report {
+			simulateTargets(clientTargets, version, isError)
+			if err := wsjson.Write(ctx, conn, &core.AgentMessage{Targets: clientTargets}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+			report = true
+		case assignment := <-assignments:
+			// report right away only when version changed, replies to reports are otherwise unchanged
+			report = false
+			if assignment.Error != "" {
+				t.logger.Error().Str("Error", assignment.Error).Msg("Agent assignment failed")
+				continue
+			}
+			for _, assigned := range assignment.Targets {
+				for _, clientTarget := range clientTargets {
+					if clientTarget.Name == assigned.Name && clientTarget.Group == assigned.Group && clientTarget.Version != assigned.Version {
+						t.logger.Info().Str("Target", clientTarget.Name).Str("Version", assigned.Version).Msg("Applying pushed assignment")
+						clientTarget.Version = assigned.Version
+						report = true
+					}
+				}
+			}
+		}
+	}
+}
+
 func main() {
 	logger := zerolog.New(os.Stderr).With().Caller().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.DebugLevel)
 
@@ -78,10 +149,11 @@ func main() {
 	batchPercent := flag.Int("batchPercent", 5, "batch percent")
 	successPercent := flag.Int("successPercent", 95, "success percent")
 	isError := flag.Bool("error", false, "check true if version needs to be reported error")
+	useWebsocket := flag.Bool("websocket", false, "use websocket agent protocol instead of polling")
 
 	flag.Parse()
 
-	options := core.DefaultRolloutOptions()
+	options := engine.DefaultRolloutOptions()
 	options.BatchPercent = *batchPercent
 	options.SuccessPercent = *successPercent
 	options.SuccessTimeoutSecs = *successTimeout
@@ -97,23 +169,26 @@ func main() {
 		Int("NumGroups", *numGroups).
 		EmbedObject(options).Send()
 
-	// This should be an input to this tool, for now create it
-	// jwtToken := os.Getenv("ORCHESTRATOR_API_KEY")
-
-	// if jwtToken == "" {
-	// 	log.Error("failed to use jwt token since environment variable is not set ORCHESTRATOR_API_KEY")
-	// 	return
-	// }
+	// bearer token is required only when server enforces jwt authentication
+	bearerToken := ""
+	if jwtToken := os.Getenv("ORCHESTRATOR_API_KEY"); jwtToken != "" {
+		logger.Info().Msg("Using JWT from ORCHESTRATOR_API_KEY")
+		bearerToken = fmt.Sprintf("Bearer %s", jwtToken)
+	}
 
-	// log.Infof("Using JWT %s", jwtToken)
+	orchestratorClient, err := client.New(endpoint, client.WithAuthorization(bearerToken))
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create orchestrator client")
+		return
+	}
 
 	testapp := testApp{
-		endpoint:        endpoint,
-		bearerToken:     "", //fmt.Sprintf("Bearer %s", jwtToken),
-		namespace:       *namespace,
-		entity:          *entity,
-		logger:          logger,
-		OrchestratorAPI: httpclient.NewOrchestratorAPI(endpoint),
+		ctx:         context.Background(),
+		bearerToken: bearerToken,
+		namespace:   *namespace,
+		entity:      *entity,
+		logger:      logger,
+		Client:      orchestratorClient,
 	}
 
 	if err := testapp.setRolloutOptions(options); err != nil {
@@ -121,7 +196,7 @@ func main() {
 		return
 	}
 
-	clientGroupTargets := make(map[string][]*core.ClientState)
+	clientGroupTargets := make(map[string][]*engine.ClientState)
 	for i := 0; i < *numGroups; i++ {
 		group := fmt.Sprintf("Group%d", i)
 		clientTargets, err := testapp.getClientState(group)
@@ -132,7 +207,7 @@ func main() {
 		if len(clientTargets) <= 0 {
 			logger.Info().Msg("No Targets found, creating new targets")
 			for i := 0; i < *numTargets; i++ {
-				clientTarget := &core.ClientState{
+				clientTarget := &engine.ClientState{
 					Name:    fmt.Sprintf("clientTarget%d", i),
 					Group:   group,
 					Version: "",
@@ -154,17 +229,21 @@ func main() {
 
 	time.Sleep(5 * time.Second)
 
+	if *useWebsocket {
+		var clientTargets []*engine.ClientState
+		for _, clientGroupTarget := range clientGroupTargets {
+			clientTargets = append(clientTargets, clientGroupTarget...)
+		}
+		if err := testapp.runAgent(*version, *isError, clientTargets); err != nil {
+			logger.Error().Err(err).Msg("Agent disconnected")
+		}
+		return
+	}
+
 	for {
 		for group, clientGroupTarget := range clientGroupTargets {
 			logger.Info().Str("Group", group).Str("Version", *version).Bool("IsError", *isError).Msg("Rolling out")
-			for _, clientTarget := range clientGroupTarget {
-				clientTarget.Message = "running successfully"
-				clientTarget.IsError = false
-				if *isError && clientTarget.Version == *version {
-					clientTarget.Message = "simulating error"
-					clientTarget.IsError = *isError
-				}
-			}
+			simulateTargets(clientGroupTarget, *version, *isError)
 			clientTargets, err := testapp.rollout(*version, group, clientGroupTarget)
 			if err != nil {
 				logger.Error().Err(err).Msg("Failed to rollout testApp")
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ConfigureFromEnv enables OTLP tracing if OTEL_EXPORTER_OTLP_ENDPOINT is set,
+// OTEL_SERVICE_NAME overrides serviceName
+func ConfigureFromEnv(serviceName string, logger zerolog.Logger) bool {
+	return Configure(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), serviceName, logger)
+}
+
+// Configure enables OTLP/HTTP tracing to endpoint/v1/traces, empty endpoint leaves tracing disabled.
+// Spans are batched and sampled by OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG, parent based
+// always on by default, export failures are logged to logger
+func Configure(endpoint, serviceName string, logger zerolog.Logger) bool {
+	if endpoint == "" {
+		return false
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		serviceName = name
+	}
+	if serviceName == "" {
+		serviceName = "orchestrator"
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		logger.Error().Err(err).Str("Endpoint", endpoint).Msg("failed to create trace exporter")
+		return false
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error().Err(err).Msg("failed to export traces")
+	}))
+	SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	))
+	return true
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	instrumentationName = "github.com/nixmade/orchestrator"
+	// shutdownTimeout bounds flushing pending spans on shutdown
+	shutdownTimeout = 10 * time.Second
+)
+
+// propagator reads and writes w3c traceparent and tracestate headers, sampled flag of incoming
+// requests is kept so upstream sampling decisions are honored
+var propagator = propagation.TraceContext{}
+
+var (
+	lock     sync.Mutex
+	provider *sdktrace.TracerProvider
+)
+
+// SetTracerProvider sets global tracer provider, nil disables tracing
+// previously configured provider is shutdown
+func SetTracerProvider(tp *sdktrace.TracerProvider) {
+	lock.Lock()
+	previous := provider
+	provider = tp
+	lock.Unlock()
+
+	if tp != nil {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagator)
+	} else {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+
+	if previous != nil && previous != tp {
+		_ = shutdown(previous)
+	}
+}
+
+// Shutdown flushes pending spans and disables tracing
+func Shutdown() error {
+	lock.Lock()
+	tp := provider
+	provider = nil
+	lock.Unlock()
+
+	if tp == nil {
+		return nil
+	}
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	return shutdown(tp)
+}
+
+func shutdown(tp *sdktrace.TracerProvider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return tp.Shutdown(ctx)
+}
+
+// Start starts a new span as child of span in ctx
+// when tracing is disabled returned span is a no-op
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return StartWithKind(ctx, name, trace.SpanKindInternal)
+}
+
+// StartWithKind starts a new span of kind as child of span in ctx
+func StartWithKind(ctx context.Context, name string, kind trace.SpanKind) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind))
+}
+
+// RecordError marks span as failed, nil err is ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject sets w3c traceparent header from span in ctx
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract reads w3c traceparent header, spans started from returned ctx are part of remote trace
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Middleware extracts incoming trace context and starts a server span for every request
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := StartWithKind(Extract(r.Context(), r.Header), fmt.Sprintf("%s %s", r.Method, r.URL.Path), trace.SpanKindServer)
+		defer span.End()
+		span.SetAttributes(attribute.String("http.method", r.Method), attribute.String("http.target", r.URL.Path))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
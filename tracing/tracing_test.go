@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const traceparentHeader = "traceparent"
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { SetTracerProvider(nil) })
+	return recorder
+}
+
+func TestSpanDisabled(t *testing.T) {
+	SetTracerProvider(nil)
+
+	ctx, span := Start(context.Background(), "disabled")
+	RecordError(span, errors.New("failed"))
+	span.End()
+	assert.False(t, span.IsRecording())
+
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Empty(t, header.Get(traceparentHeader))
+}
+
+func TestSpanParentPropagation(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	childCtx, child := Start(ctx, "child")
+	RecordError(child, errors.New("failed"))
+	child.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+
+	header := http.Header{}
+	Inject(childCtx, header)
+	assert.True(t, strings.HasSuffix(header.Get(traceparentHeader), "-01"))
+
+	_, remote := Start(Extract(context.Background(), header), "remote")
+	remote.End()
+	assert.Equal(t, child.SpanContext().TraceID(), remote.SpanContext().TraceID())
+	require.Len(t, recorder.Ended(), 3)
+	assert.Equal(t, child.SpanContext().SpanID(), recorder.Ended()[2].Parent().SpanID())
+
+	header.Set(traceparentHeader, "invalid")
+	_, orphan := Start(Extract(context.Background(), header), "orphan")
+	orphan.End()
+	assert.NotEqual(t, child.SpanContext().TraceID(), orphan.SpanContext().TraceID())
+}
+
+func TestSampledFlagPropagation(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	// upstream decided not to sample, spans are not recorded and decision is passed on
+	header := http.Header{}
+	header.Set(traceparentHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	ctx, span := Start(Extract(context.Background(), header), "unsampled")
+	span.End()
+	assert.False(t, span.IsRecording())
+	assert.Empty(t, recorder.Ended())
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	assert.True(t, strings.HasPrefix(outgoing.Get(traceparentHeader), "00-0af7651916cd43dd8448eb211c80319c-"))
+	assert.True(t, strings.HasSuffix(outgoing.Get(traceparentHeader), "-00"))
+
+	header.Set(traceparentHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	_, span = Start(Extract(context.Background(), header), "sampled")
+	span.End()
+	assert.Len(t, recorder.Ended(), 1)
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/orchestrate", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /v1/orchestrate", spans[0].Name())
+}
+
+func TestConfigureSampler(t *testing.T) {
+	t.Setenv("OTEL_TRACES_SAMPLER", "always_off")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	require.True(t, Configure(srv.URL, "test", zerolog.Nop()))
+	defer func() {
+		assert.NoError(t, Shutdown())
+	}()
+
+	_, span := Start(context.Background(), "dropped")
+	span.End()
+	assert.False(t, span.IsRecording())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	assert.False(t, Configure("", "test", zerolog.Nop()))
+	require.True(t, Configure(srv.URL, "test", zerolog.Nop()))
+
+	_, span := Start(context.Background(), "exported")
+	span.End()
+
+	require.NoError(t, Shutdown())
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestOTLPExporterFailureLogged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	require.True(t, Configure(srv.URL, "test", zerolog.New(&logs)))
+
+	_, span := Start(context.Background(), "rejected")
+	span.End()
+
+	_ = Shutdown()
+	assert.Contains(t, logs.String(), "failed to export traces")
+}